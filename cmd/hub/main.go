@@ -84,6 +84,7 @@ func main() {
 	// Initialize repository, service, and handlers
 	repo := repository.NewRepository(db)
 	svc := service.NewService(repo, rdb, encryptionKEK)
+	svc.SetRestoreServiceURL(getenv("HUB_RESTORE_SERVICE_URL", "http://localhost:8082"))
 	h := handlers.NewHandlers(svc)
 
 	// Initialize auth service and handlers
@@ -141,6 +142,9 @@ func main() {
 	api.Get("/sources", jwtMiddleware, h.HandleListSources)
 	api.Get("/sources/:id", jwtMiddleware, h.HandleGetSource)
 
+	// Quota routes
+	api.Get("/quota", jwtMiddleware, h.HandleGetQuota)
+
 	// Schedule routes
 	api.Post("/schedules", jwtMiddleware, h.HandleCreateSchedule)
 	api.Get("/schedules", jwtMiddleware, h.HandleListSchedules)
@@ -153,6 +157,7 @@ func main() {
 	// Snapshot routes
 	api.Get("/snapshots", jwtMiddleware, h.HandleListSnapshots)
 	api.Get("/snapshots/:id", jwtMiddleware, h.HandleGetSnapshot)
+	api.Get("/snapshots/:id/files", jwtMiddleware, h.HandleListSnapshotFiles)
 
 	// Restore routes
 	api.Post("/snapshots/:id/restore", jwtMiddleware, h.HandleEnqueueRestoreJob)
@@ -175,6 +180,9 @@ func main() {
 	admin.Get("/settings/:key", h.HandleGetSetting)
 	admin.Put("/settings/:key", h.HandleUpdateSetting)
 
+	// Platform KEK (admin only)
+	admin.Post("/kek/reencrypt", h.HandleReencryptSecretsAdmin)
+
 	// User management (admin only)
 	admin.Get("/users", h.HandleListUsers)
 	admin.Get("/users/:id", h.HandleGetUser)
@@ -186,6 +194,23 @@ func main() {
 	admin.Get("/tenants", h.HandleListTenants)
 	admin.Get("/tenants/:id", h.HandleGetTenantAdmin)
 	admin.Delete("/tenants/:id", h.HandleDeleteTenant)
+	admin.Put("/tenants/:id/plan", h.HandleSetTenantPlanAdmin)
+
+	// Quota management (admin only)
+	admin.Get("/quotas", h.HandleListQuotasAdmin)
+	admin.Get("/tenants/:id/quota", h.HandleGetTenantQuotaAdmin)
+	admin.Put("/tenants/:id/quota", h.HandleSetTenantQuotaAdmin)
+	admin.Delete("/tenants/:id/quota", h.HandleDeleteTenantQuotaAdmin)
+	admin.Put("/plans/:plan/quota", h.HandleSetPlanQuotaAdmin)
+	admin.Delete("/plans/:plan/quota", h.HandleDeletePlanQuotaAdmin)
+
+	// Offsite targets (admin only)
+	admin.Get("/tenants/:id/offsite-target", h.HandleGetOffsiteTargetAdmin)
+	admin.Put("/tenants/:id/offsite-target", h.HandleSetOffsiteTargetAdmin)
+	admin.Delete("/tenants/:id/offsite-target", h.HandleDeleteOffsiteTargetAdmin)
+	admin.Get("/tenants/:id/keys", h.HandleListTenantKeysAdmin)
+	admin.Post("/tenants/:id/keys/rotate", h.HandleRotateTenantKeyAdmin)
+	admin.Post("/tenants/:id/keys/rekey", h.HandleRekeyTenantSnapshotsAdmin)
 
 	// Source management (admin only)
 	admin.Post("/sources/test-connection", h.HandleTestConnection) // Must be before :id routes
@@ -204,11 +229,37 @@ func main() {
 	admin.Put("/schedules/:id", h.HandleUpdateScheduleAdmin)
 	admin.Delete("/schedules/:id", h.HandleDeleteScheduleAdmin)
 
+	// Verify schedule management (admin only)
+	admin.Get("/verify-schedules", h.HandleListVerifySchedulesAdmin)
+	admin.Post("/verify-schedules", h.HandleCreateVerifyScheduleAdmin)
+	admin.Put("/verify-schedules/:id", h.HandleUpdateVerifyScheduleAdmin)
+	admin.Delete("/verify-schedules/:id", h.HandleDeleteVerifyScheduleAdmin)
+
+	// Replication policy management (admin only)
+	admin.Get("/replication-policies", h.HandleListReplicationPoliciesAdmin)
+	admin.Post("/replication-policies", h.HandleCreateReplicationPolicyAdmin)
+	admin.Put("/replication-policies/:id", h.HandleUpdateReplicationPolicyAdmin)
+	admin.Delete("/replication-policies/:id", h.HandleDeleteReplicationPolicyAdmin)
+
+	// Tiering policy management (admin only)
+	admin.Get("/tiering-policies", h.HandleListTieringPoliciesAdmin)
+	admin.Post("/tiering-policies", h.HandleCreateTieringPolicyAdmin)
+	admin.Put("/tiering-policies/:id", h.HandleUpdateTieringPolicyAdmin)
+	admin.Delete("/tiering-policies/:id", h.HandleDeleteTieringPolicyAdmin)
+
 	// Snapshot management (admin only)
 	admin.Get("/snapshots", h.HandleListSnapshotsAdmin)
 	admin.Get("/snapshots/:id", h.HandleGetSnapshotAdmin)
 	admin.Get("/snapshots/:id/logs", h.HandleGetLogsForSnapshot)
 	admin.Delete("/snapshots/:id", h.HandleDeleteSnapshotAdmin)
+	admin.Post("/snapshots/:id/verify", h.HandleVerifySnapshotAdmin)
+	admin.Post("/snapshots/:id/replicate", h.HandleReplicateSnapshotAdmin)
+	admin.Get("/snapshots/:id/replicas", h.HandleListSnapshotReplicasAdmin)
+	admin.Post("/snapshots/:id/offsite", h.HandlePushSnapshotOffsiteAdmin)
+	admin.Post("/snapshots/:id/migrate", h.HandleMigrateSnapshotAdmin)
+	admin.Post("/snapshots/:id/hold", h.HandleHoldSnapshotAdmin)
+	admin.Delete("/snapshots/:id/hold", h.HandleReleaseSnapshotHoldAdmin)
+	admin.Put("/snapshots/:id/lock", h.HandleLockSnapshotAdmin)
 
 	// System-wide logs (admin only)
 	admin.Get("/logs", h.HandleListAllLogsAdmin)
@@ -219,6 +270,13 @@ func main() {
 	// Worker monitoring (admin only)
 	admin.Get("/workers", h.HandleListWorkersAdmin)
 	admin.Get("/workers/:id", h.HandleGetWorkerAdmin)
+	admin.Post("/workers/:id/reconcile", h.HandleReconcileWorkerStorage)
+	admin.Post("/workers/:id/reset-identity", h.HandleResetWorkerIdentity)
+
+	// Storage reconciliation (admin only)
+	admin.Get("/reconciliations", h.HandleListStorageReconciliations)
+	admin.Get("/reconciliations/:id", h.HandleGetStorageReconciliation)
+	admin.Post("/reconciliations/:id/orphans", h.HandleResolveOrphans)
 
 	// Internal/Worker routes
 	internal := app.Group("/internal")
@@ -244,6 +302,19 @@ func main() {
 	internal.Post("/workers/register", h.HandleRegisterWorker)
 	internal.Post("/workers/heartbeat", h.HandleWorkerHeartbeat)
 
+	// Snapshot integrity reports (for workers)
+	internal.Post("/snapshots/:id/integrity", h.HandleReportSnapshotIntegrity)
+	internal.Get("/snapshots/:id/lock", h.HandleGetSnapshotLockStatus)
+
+	// Replica transfers (for worker replication servers)
+	internal.Post("/replicas/authorize", h.HandleAuthorizeReplicaTransfer)
+
+	// File listings (for the restore service, redeeming the hub's listing tokens)
+	internal.Post("/file-listings/authorize", h.HandleAuthorizeFileListing)
+
+	// Offsite targets (for workers pushing to them and restore services reading them)
+	internal.Get("/offsite-targets/:id", h.HandleGetOffsiteTargetAccess)
+
 	// Internal settings (for restore service)
 	internal.Get("/settings/download-expiration", h.HandleGetDownloadExpiration)
 
@@ -268,6 +339,30 @@ func main() {
 	}
 	go startBackupScheduler(svc, schedulerInterval)
 
+	// Start verify scheduler in background
+	verifySchedulerInterval := getenv("VERIFY_SCHEDULER_INTERVAL_SECONDS", "300")
+	verifyInterval, err := time.ParseDuration(verifySchedulerInterval + "s")
+	if err != nil {
+		log.Printf("invalid VERIFY_SCHEDULER_INTERVAL_SECONDS, using default 300s: %v", err)
+		verifyInterval = 300 * time.Second
+	}
+	go startVerifyScheduler(svc, verifyInterval)
+
+	// Start tiering scheduler in background
+	tieringSchedulerInterval := getenv("TIERING_SCHEDULER_INTERVAL_SECONDS", "3600")
+	tieringInterval, err := time.ParseDuration(tieringSchedulerInterval + "s")
+	if err != nil {
+		log.Printf("invalid TIERING_SCHEDULER_INTERVAL_SECONDS, using default 3600s: %v", err)
+		tieringInterval = 3600 * time.Second
+	}
+	go startTieringScheduler(svc, tieringInterval)
+
+	// Re-encrypt secrets still in the legacy KEK format in background; defer it with
+	// HUB_KEK_REENCRYPT=false until every worker reads the versioned format
+	if getenv("HUB_KEK_REENCRYPT", "true") == "true" {
+		go runKEKMigration(svc)
+	}
+
 	log.Printf("hub listening on %s", addr)
 	if err := app.Listen(addr); err != nil {
 		log.Fatalf("hub server error: %v", err)
@@ -305,6 +400,85 @@ func runBackupScheduler(svc *service.Service) {
 	}
 }
 
+// startVerifyScheduler runs periodic verify schedule processing
+func startVerifyScheduler(svc *service.Service, interval time.Duration) {
+	log.Printf("starting verify scheduler (interval: %v)", interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// Run once on startup after a short delay
+	time.Sleep(20 * time.Second)
+	runVerifyScheduler(svc)
+
+	for range ticker.C {
+		runVerifyScheduler(svc)
+	}
+}
+
+// runVerifyScheduler processes due verify schedules and enqueues verify jobs
+func runVerifyScheduler(svc *service.Service) {
+	ctx, cancel := contextWithTimeout(1 * time.Minute)
+	defer cancel()
+
+	jobsCreated, err := svc.ProcessDueVerifySchedules(ctx)
+	if err != nil {
+		log.Printf("verify scheduler error: %v", err)
+		return
+	}
+
+	if jobsCreated > 0 {
+		log.Printf("verify scheduler: created %d verify job(s)", jobsCreated)
+	}
+}
+
+// startTieringScheduler runs periodic tiering policy processing
+func startTieringScheduler(svc *service.Service, interval time.Duration) {
+	log.Printf("starting tiering scheduler (interval: %v)", interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// Run once on startup after a short delay
+	time.Sleep(40 * time.Second)
+	runTieringScheduler(svc)
+
+	for range ticker.C {
+		runTieringScheduler(svc)
+	}
+}
+
+// runTieringScheduler enqueues migrate jobs for the snapshots tiering policies cover
+func runTieringScheduler(svc *service.Service) {
+	ctx, cancel := contextWithTimeout(5 * time.Minute)
+	defer cancel()
+
+	jobsCreated, err := svc.ProcessTieringPolicies(ctx)
+	if err != nil {
+		log.Printf("tiering scheduler error: %v", err)
+		return
+	}
+
+	if jobsCreated > 0 {
+		log.Printf("tiering scheduler: created %d migrate job(s)", jobsCreated)
+	}
+}
+
+// runKEKMigration re-encrypts tenant keys and credentials in the legacy KEK format
+func runKEKMigration(svc *service.Service) {
+	ctx, cancel := contextWithTimeout(5 * time.Minute)
+	defer cancel()
+
+	report, err := svc.ReencryptLegacySecrets(ctx)
+	if err != nil {
+		log.Printf("KEK re-encryption failed: %v", err)
+		return
+	}
+
+	if report.TenantKeys+report.Credentials+report.Failed > 0 {
+		log.Printf("KEK re-encryption complete: kek_id=%s, tenant_keys=%d, credentials=%d, failed=%d, remaining=%d",
+			report.KEKID, report.TenantKeys, report.Credentials, report.Failed, report.RemainingTenantKeys+report.RemainingCredentials)
+	}
+}
+
 // startRetentionScheduler runs periodic retention evaluation
 func startRetentionScheduler(svc *service.Service, interval time.Duration) {
 	log.Printf("starting retention scheduler (interval: %v)", interval)
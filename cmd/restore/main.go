@@ -11,6 +11,7 @@ import (
 	"xvault/internal/restore/client"
 	"xvault/internal/restore/download"
 	"xvault/internal/restore/orchestrator"
+	"xvault/pkg/backend"
 )
 
 func main() {
@@ -48,6 +49,20 @@ func main() {
 
 	// Create orchestrator
 	orch := orchestrator.NewOrchestrator(serviceID, hubClient, workerStorage, downloadBaseURL, downloadSrv)
+	downloadSrv.SetFileLister(orch)
+
+	// Snapshots uploaded to object storage name their bucket in their locator; the
+	// endpoint and credentials are shared with the workers
+	if endpoint := os.Getenv("S3_ENDPOINT"); endpoint != "" {
+		orch.SetObjectStore(backend.S3Config{
+			Endpoint:        endpoint,
+			Region:          getenv("S3_REGION", "us-east-1"),
+			AccessKeyID:     mustGetenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: mustGetenv("S3_SECRET_ACCESS_KEY"),
+			PathStyle:       getenv("S3_PATH_STYLE", "false") == "true",
+		})
+		log.Printf("object store restores enabled: endpoint=%s", endpoint)
+	}
 
 	// Handle shutdown signals
 	sigChan := make(chan os.Signal, 1)
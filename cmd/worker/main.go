@@ -5,25 +5,79 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 
 	"xvault/internal/worker/orchestrator"
 	"xvault/internal/worker/client"
+	"xvault/internal/worker/replication"
+	"xvault/pkg/backend"
+	"xvault/pkg/crypto"
 )
 
 func main() {
 	workerID := mustGetenv("WORKER_ID")
 	hubBaseURL := mustGetenv("HUB_BASE_URL")
 	storageBase := getenv("WORKER_STORAGE_BASE", "/var/lib/xvault/backups")
+	scratchDir := getenv("WORKER_SCRATCH_DIR", "/tmp/gobackup")
 	encryptionKEK := mustGetenv("WORKER_ENCRYPTION_KEK")
+	storageMode := getenv("WORKER_STORAGE_MODE", "artifact") // artifact or repository
+	identityKeyPath := getenv("WORKER_IDENTITY_KEY_PATH", "/var/lib/xvault/worker/identity.key")
+	volumeSizeMB, err := strconv.ParseInt(getenv("WORKER_ARTIFACT_VOLUME_SIZE_MB", "1024"), 10, 64)
+	if err != nil || volumeSizeMB < 0 {
+		log.Fatalf("invalid WORKER_ARTIFACT_VOLUME_SIZE_MB: must be a non-negative integer")
+	}
+	storageBackend := getenv("WORKER_STORAGE_BACKEND", "local_fs") // local_fs or s3
+	replicationAddr := getenv("WORKER_REPLICATION_ADDR", "") // empty disables the replication server
+	tierBucket := getenv("WORKER_TIER_S3_BUCKET", "")         // empty disables tiering
 
-	log.Printf("worker starting: worker_id=%s hub=%s storage=%s", workerID, hubBaseURL, storageBase)
+	log.Printf("worker starting: worker_id=%s hub=%s storage=%s scratch=%s backend=%s mode=%s", workerID, hubBaseURL, storageBase, scratchDir, storageBackend, storageMode)
 
 	// Create Hub client
 	hubClient := client.NewHubClient(hubBaseURL)
 
 	// Create orchestrator (without download server - restore is handled by separate service)
 	orch := orchestrator.NewOrchestrator(workerID, hubClient, storageBase, encryptionKEK)
+	orch.SetRepositoryMode(storageMode == "repository")
+	orch.SetArtifactVolumeSize(volumeSizeMB << 20)
+	orch.SetScratchDir(scratchDir)
+	orch.SetAdmissionThresholds(admissionThresholds())
+
+	// With an object store, snapshots are staged in WORKER_STORAGE_BASE and uploaded
+	switch storageBackend {
+	case "local_fs":
+	case "s3":
+		if storageMode == "repository" {
+			log.Fatalf("WORKER_STORAGE_MODE=repository is not supported with WORKER_STORAGE_BACKEND=s3")
+		}
+		if err := orch.SetObjectStore(s3Config(mustGetenv("WORKER_S3_BUCKET"))); err != nil {
+			log.Fatalf("%v", err)
+		}
+	default:
+		log.Fatalf("invalid WORKER_STORAGE_BACKEND %q: must be local_fs or s3", storageBackend)
+	}
+
+	// Tiering policies move older snapshots from local storage to the tier bucket
+	if tierBucket != "" {
+		if err := orch.SetTierStore(s3Config(tierBucket)); err != nil {
+			log.Fatalf("%v", err)
+		}
+	}
+
+	// Snapshot manifests are signed with the worker's identity key, created on first start
+	identityPublicKey, identityKey, err := crypto.LoadOrCreateSigningKey(identityKeyPath)
+	if err != nil {
+		log.Fatalf("failed to load worker identity key: %v", err)
+	}
+	orch.SetIdentityKey(identityPublicKey, identityKey)
+
+	// Other workers copy the snapshots this worker holds from its replication server
+	var replicationServer *replication.Server
+	if replicationAddr != "" {
+		orch.SetReplicationURL(mustGetenv("WORKER_REPLICATION_URL"))
+		replicationServer = replication.NewServer(replicationAddr, workerID, hubClient, orch.Storage())
+		replicationServer.Start()
+	}
 
 	// Setup context with cancellation
 	ctx, cancel := context.WithCancel(context.Background())
@@ -47,6 +101,11 @@ func main() {
 		if err := orch.Shutdown(context.Background()); err != nil {
 			log.Printf("shutdown error: %v", err)
 		}
+		if replicationServer != nil {
+			if err := replicationServer.Shutdown(context.Background()); err != nil {
+				log.Printf("replication server shutdown error: %v", err)
+			}
+		}
 	case err := <-errChan:
 		if err != nil {
 			log.Fatalf("worker error: %v", err)
@@ -56,6 +115,45 @@ func main() {
 	log.Printf("worker %s stopped", workerID)
 }
 
+// s3Config reads the object store connection settings shared with the restore service
+func s3Config(bucket string) backend.S3Config {
+	partSizeMB, err := strconv.ParseInt(getenv("WORKER_S3_PART_SIZE_MB", "64"), 10, 64)
+	if err != nil || partSizeMB < 5 {
+		log.Fatalf("invalid WORKER_S3_PART_SIZE_MB: must be an integer of at least 5")
+	}
+	return backend.S3Config{
+		Endpoint:        mustGetenv("S3_ENDPOINT"),
+		Region:          getenv("S3_REGION", "us-east-1"),
+		Bucket:          bucket,
+		AccessKeyID:     mustGetenv("S3_ACCESS_KEY_ID"),
+		SecretAccessKey: mustGetenv("S3_SECRET_ACCESS_KEY"),
+		PathStyle:       getenv("S3_PATH_STYLE", "false") == "true",
+		PartSize:        partSizeMB << 20,
+	}
+}
+
+// admissionThresholds reads the resource limits past which the worker stops claiming jobs
+func admissionThresholds() orchestrator.AdmissionThresholds {
+	minDiskFreeMB, err := strconv.ParseInt(getenv("WORKER_ADMISSION_MIN_DISK_FREE_MB", "0"), 10, 64)
+	if err != nil || minDiskFreeMB < 0 {
+		log.Fatalf("invalid WORKER_ADMISSION_MIN_DISK_FREE_MB: must be a non-negative integer")
+	}
+	return orchestrator.AdmissionThresholds{
+		MinDiskFreeBytes: minDiskFreeMB << 20,
+		MaxMemoryPercent: getenvPercent("WORKER_ADMISSION_MAX_MEMORY_PERCENT"),
+		MaxCPUPercent:    getenvPercent("WORKER_ADMISSION_MAX_CPU_PERCENT"),
+	}
+}
+
+// getenvPercent reads a percentage; unset is 0
+func getenvPercent(key string) float64 {
+	v, err := strconv.ParseFloat(getenv(key, "0"), 64)
+	if err != nil || v < 0 || v > 100 {
+		log.Fatalf("invalid %s: must be a number between 0 and 100", key)
+	}
+	return v
+}
+
 func getenv(key, fallback string) string {
 	v := os.Getenv(key)
 	if v == "" {
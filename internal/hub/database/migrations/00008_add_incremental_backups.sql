@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE schedules ADD COLUMN IF NOT EXISTS full_every INT NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+COMMENT ON COLUMN schedules.full_every IS 'Number of incremental backups between full backups; 0 disables incremental backups';
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE snapshots ADD COLUMN IF NOT EXISTS backup_mode TEXT NOT NULL DEFAULT 'full';
+ALTER TABLE snapshots ADD COLUMN IF NOT EXISTS base_snapshot_id UUID;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+COMMENT ON COLUMN snapshots.base_snapshot_id IS 'Snapshot whose file index an incremental backup was compared against; informational, the snapshot itself is self-contained';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE snapshots DROP COLUMN IF EXISTS base_snapshot_id;
+ALTER TABLE snapshots DROP COLUMN IF EXISTS backup_mode;
+ALTER TABLE schedules DROP COLUMN IF EXISTS full_every;
+-- +goose StatementEnd
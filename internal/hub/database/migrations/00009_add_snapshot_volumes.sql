@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE snapshots ADD COLUMN IF NOT EXISTS volumes JSONB;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+COMMENT ON COLUMN snapshots.volumes IS 'Numbered volumes of a multi-volume artifact in order, each with its size and SHA-256; NULL for single-file artifacts';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE snapshots DROP COLUMN IF EXISTS volumes;
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE workers ADD COLUMN IF NOT EXISTS public_key TEXT;
+ALTER TABLE snapshots ADD COLUMN IF NOT EXISTS manifest_signature TEXT;
+ALTER TABLE snapshots ADD COLUMN IF NOT EXISTS manifest_signing_key TEXT;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+COMMENT ON COLUMN workers.public_key IS 'Base64 Ed25519 identity key the worker signs snapshot manifests with';
+COMMENT ON COLUMN snapshots.manifest_signature IS 'Worker Ed25519 signature over the canonical manifest JSON';
+COMMENT ON COLUMN snapshots.manifest_signing_key IS 'Worker identity key that verified manifest_signature when the snapshot was recorded';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE snapshots DROP COLUMN IF EXISTS manifest_signing_key;
+ALTER TABLE snapshots DROP COLUMN IF EXISTS manifest_signature;
+ALTER TABLE workers DROP COLUMN IF EXISTS public_key;
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE snapshots ADD COLUMN IF NOT EXISTS integrity_status TEXT;
+ALTER TABLE snapshots ADD COLUMN IF NOT EXISTS integrity_error TEXT;
+ALTER TABLE snapshots ADD COLUMN IF NOT EXISTS integrity_checked_at TIMESTAMPTZ;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+COMMENT ON COLUMN snapshots.integrity_status IS 'Result of the last integrity check of the stored files; NULL when never checked';
+COMMENT ON COLUMN snapshots.integrity_error IS 'What the last integrity check found wrong';
+COMMENT ON COLUMN snapshots.integrity_checked_at IS 'When integrity_status was last set';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE snapshots DROP COLUMN IF EXISTS integrity_checked_at;
+ALTER TABLE snapshots DROP COLUMN IF EXISTS integrity_error;
+ALTER TABLE snapshots DROP COLUMN IF EXISTS integrity_status;
+-- +goose StatementEnd
@@ -0,0 +1,41 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TYPE job_type ADD VALUE IF NOT EXISTS 'reconcile_storage';
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- reconcile_storage jobs belong to a worker, not a tenant
+ALTER TABLE jobs ALTER COLUMN tenant_id DROP NOT NULL;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TABLE storage_reconciliations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    worker_id TEXT NOT NULL REFERENCES workers(id) ON DELETE CASCADE,
+    job_id UUID REFERENCES jobs(id) ON DELETE SET NULL,
+    inventory_count INT NOT NULL DEFAULT 0,
+    missing_count INT NOT NULL DEFAULT 0,
+    orphaned_count INT NOT NULL DEFAULT 0,
+    size_mismatch_count INT NOT NULL DEFAULT 0,
+    report JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX idx_storage_reconciliations_worker ON storage_reconciliations(worker_id, created_at DESC);
+COMMENT ON TABLE storage_reconciliations IS 'Diffs between worker disks and snapshot records, produced by reconcile_storage jobs';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_storage_reconciliations_worker;
+DROP TABLE IF EXISTS storage_reconciliations;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DELETE FROM jobs WHERE tenant_id IS NULL;
+ALTER TABLE jobs ALTER COLUMN tenant_id SET NOT NULL;
+-- +goose StatementEnd
+
+-- Enum values cannot be dropped; reconcile_storage stays in job_type
@@ -0,0 +1,44 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TYPE job_type ADD VALUE IF NOT EXISTS 'verify_snapshot';
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE snapshots ADD COLUMN IF NOT EXISTS last_verified_at TIMESTAMPTZ;
+COMMENT ON COLUMN snapshots.last_verified_at IS 'When a verify_snapshot job last checked the snapshot; its result is in integrity_status';
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TABLE verify_schedules (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    source_id UUID REFERENCES sources(id) ON DELETE CASCADE,
+    interval_minutes INT NOT NULL,
+    test_decrypt BOOLEAN NOT NULL DEFAULT false,
+    status schedule_status NOT NULL DEFAULT 'enabled',
+    last_run_at TIMESTAMP,
+    next_run_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP NOT NULL DEFAULT now()
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- One schedule per source, plus one per tenant (source_id NULL) for its other sources
+CREATE UNIQUE INDEX idx_verify_schedules_tenant ON verify_schedules(tenant_id) WHERE source_id IS NULL;
+CREATE UNIQUE INDEX idx_verify_schedules_source ON verify_schedules(source_id) WHERE source_id IS NOT NULL;
+CREATE INDEX idx_verify_schedules_next_run ON verify_schedules(next_run_at) WHERE status = 'enabled';
+COMMENT ON TABLE verify_schedules IS 'How often the snapshots of a tenant or source are re-verified by verify_snapshot jobs';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS verify_schedules;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DELETE FROM jobs WHERE type = 'verify_snapshot';
+ALTER TABLE snapshots DROP COLUMN IF EXISTS last_verified_at;
+-- +goose StatementEnd
+
+-- Enum values cannot be dropped; verify_snapshot stays in job_type
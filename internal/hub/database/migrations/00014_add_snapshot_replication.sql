@@ -0,0 +1,68 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TYPE job_type ADD VALUE IF NOT EXISTS 'replicate_snapshot';
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TABLE replication_policies (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    source_id UUID REFERENCES sources(id) ON DELETE CASCADE,
+    copies INT NOT NULL DEFAULT 2 CHECK (copies >= 1),
+    object_store_copy BOOLEAN NOT NULL DEFAULT false,
+    status schedule_status NOT NULL DEFAULT 'enabled',
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP NOT NULL DEFAULT now()
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- One policy per source, plus one per tenant (source_id NULL) for its other sources
+CREATE UNIQUE INDEX idx_replication_policies_tenant ON replication_policies(tenant_id) WHERE source_id IS NULL;
+CREATE UNIQUE INDEX idx_replication_policies_source ON replication_policies(source_id) WHERE source_id IS NOT NULL;
+COMMENT ON TABLE replication_policies IS 'How many copies of each snapshot of a tenant or source are kept on different workers';
+COMMENT ON COLUMN replication_policies.copies IS 'Copies to keep, the original included, each on a different worker';
+COMMENT ON COLUMN replication_policies.object_store_copy IS 'Whether one of the copies must be held in an object store';
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TABLE snapshot_replicas (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    snapshot_id UUID NOT NULL REFERENCES snapshots(id) ON DELETE CASCADE,
+    job_id UUID REFERENCES jobs(id) ON DELETE SET NULL,
+    worker_id TEXT NOT NULL REFERENCES workers(id) ON DELETE CASCADE,
+    source_worker_id TEXT REFERENCES workers(id) ON DELETE SET NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'completed', 'failed')),
+    storage_backend storage_backend NOT NULL DEFAULT 'local_fs',
+    local_path TEXT,
+    bucket TEXT,
+    object_key TEXT,
+    etag TEXT,
+    volumes JSONB,
+    transfer_token_hash TEXT,
+    error TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP NOT NULL DEFAULT now()
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE UNIQUE INDEX idx_snapshot_replicas_snapshot_worker ON snapshot_replicas(snapshot_id, worker_id);
+CREATE INDEX idx_snapshot_replicas_worker ON snapshot_replicas(worker_id);
+CREATE UNIQUE INDEX idx_snapshot_replicas_transfer_token ON snapshot_replicas(transfer_token_hash) WHERE transfer_token_hash IS NOT NULL;
+COMMENT ON TABLE snapshot_replicas IS 'Copies of snapshots held by workers other than the one that took them';
+COMMENT ON COLUMN snapshot_replicas.source_worker_id IS 'Worker the copy is made from';
+COMMENT ON COLUMN snapshot_replicas.transfer_token_hash IS 'SHA-256 of the token the copying worker presents to the source worker; cleared when the copy finishes';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS snapshot_replicas;
+DROP TABLE IF EXISTS replication_policies;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DELETE FROM jobs WHERE type = 'replicate_snapshot';
+-- +goose StatementEnd
+
+-- Enum values cannot be dropped; replicate_snapshot stays in job_type
@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TYPE quota_overage_action AS ENUM ('reject', 'warn', 'force_retention');
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TABLE quotas (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID UNIQUE REFERENCES tenants(id) ON DELETE CASCADE,
+    plan TEXT UNIQUE,
+    max_storage_bytes BIGINT CHECK (max_storage_bytes >= 0),
+    max_sources INT CHECK (max_sources >= 0),
+    max_snapshots_per_source INT CHECK (max_snapshots_per_source >= 1),
+    overage_action quota_overage_action NOT NULL DEFAULT 'reject',
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP NOT NULL DEFAULT now(),
+    CHECK ((tenant_id IS NULL) <> (plan IS NULL))
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+COMMENT ON TABLE quotas IS 'Storage limits for the tenants on a plan, or for one tenant; a tenant quota replaces its plan''s';
+COMMENT ON COLUMN quotas.max_storage_bytes IS 'Bytes of completed snapshots the tenant may store; NULL is unlimited';
+COMMENT ON COLUMN quotas.max_sources IS 'Sources the tenant may create; NULL is unlimited';
+COMMENT ON COLUMN quotas.max_snapshots_per_source IS 'Completed snapshots kept per source; NULL is unlimited';
+COMMENT ON COLUMN quotas.overage_action IS 'What a backup over quota does: reject it, run it with a warning, or delete the oldest snapshots first';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS quotas;
+DROP TYPE IF EXISTS quota_overage_action;
+-- +goose StatementEnd
@@ -0,0 +1,47 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE snapshots ADD COLUMN IF NOT EXISTS legal_hold BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE snapshots ADD COLUMN IF NOT EXISTS legal_hold_reason TEXT;
+ALTER TABLE snapshots ADD COLUMN IF NOT EXISTS locked_until TIMESTAMPTZ;
+COMMENT ON COLUMN snapshots.legal_hold IS 'Snapshot cannot be deleted until the hold is released';
+COMMENT ON COLUMN snapshots.locked_until IS 'Snapshot cannot be deleted before this time; the lock can be extended but never shortened';
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX idx_snapshots_locked ON snapshots(tenant_id, source_id) WHERE legal_hold OR locked_until IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- Backstop for the hub's checks: a held or locked snapshot row cannot be deleted,
+-- directly or by cascade from its source or tenant, and its lock cannot be shortened
+CREATE OR REPLACE FUNCTION protect_locked_snapshots() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        IF OLD.legal_hold OR OLD.locked_until > now() THEN
+            RAISE EXCEPTION 'snapshot % is under legal hold or locked until %', OLD.id, OLD.locked_until;
+        END IF;
+        RETURN OLD;
+    END IF;
+    IF OLD.locked_until > now() AND (NEW.locked_until IS NULL OR NEW.locked_until < OLD.locked_until) THEN
+        RAISE EXCEPTION 'lock of snapshot % cannot be shortened', OLD.id;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER snapshots_protect_locked
+    BEFORE DELETE OR UPDATE OF locked_until ON snapshots
+    FOR EACH ROW EXECUTE FUNCTION protect_locked_snapshots();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS snapshots_protect_locked ON snapshots;
+DROP FUNCTION IF EXISTS protect_locked_snapshots();
+DROP INDEX IF EXISTS idx_snapshots_locked;
+ALTER TABLE snapshots DROP COLUMN IF EXISTS locked_until;
+ALTER TABLE snapshots DROP COLUMN IF EXISTS legal_hold_reason;
+ALTER TABLE snapshots DROP COLUMN IF EXISTS legal_hold;
+-- +goose StatementEnd
@@ -0,0 +1,50 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TYPE storage_backend ADD VALUE IF NOT EXISTS 'sftp';
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TABLE offsite_targets (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL UNIQUE REFERENCES tenants(id) ON DELETE CASCADE,
+    credential_id UUID NOT NULL REFERENCES credentials(id),
+    host TEXT NOT NULL,
+    port INT NOT NULL DEFAULT 22 CHECK (port BETWEEN 1 AND 65535),
+    username TEXT NOT NULL,
+    use_private_key BOOLEAN NOT NULL DEFAULT false,
+    base_path TEXT NOT NULL DEFAULT '',
+    host_key TEXT,
+    status schedule_status NOT NULL DEFAULT 'enabled',
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP NOT NULL DEFAULT now()
+);
+COMMENT ON TABLE offsite_targets IS 'SFTP servers owned by tenants that every new snapshot of theirs is pushed to, still encrypted';
+COMMENT ON COLUMN offsite_targets.credential_id IS 'Storage credential holding the password or private key';
+COMMENT ON COLUMN offsite_targets.base_path IS 'Remote directory snapshots are stored under; relative paths start at the login directory';
+COMMENT ON COLUMN offsite_targets.host_key IS 'Server public key in authorized_keys format; the key is not checked when NULL';
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE snapshot_replicas ADD COLUMN IF NOT EXISTS offsite_target_id UUID REFERENCES offsite_targets(id) ON DELETE CASCADE;
+COMMENT ON COLUMN snapshot_replicas.offsite_target_id IS 'Offsite target the copy was pushed to; worker_id is then the worker that pushed it';
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- A worker that pushes a snapshot offsite may also hold a replica of it, so the
+-- one-replica-per-worker rule only covers copies held by workers
+DROP INDEX IF EXISTS idx_snapshot_replicas_snapshot_worker;
+CREATE UNIQUE INDEX idx_snapshot_replicas_snapshot_worker ON snapshot_replicas(snapshot_id, worker_id) WHERE offsite_target_id IS NULL;
+CREATE UNIQUE INDEX idx_snapshot_replicas_snapshot_offsite ON snapshot_replicas(snapshot_id, offsite_target_id) WHERE offsite_target_id IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM snapshot_replicas WHERE offsite_target_id IS NOT NULL;
+DROP INDEX IF EXISTS idx_snapshot_replicas_snapshot_offsite;
+DROP INDEX IF EXISTS idx_snapshot_replicas_snapshot_worker;
+CREATE UNIQUE INDEX idx_snapshot_replicas_snapshot_worker ON snapshot_replicas(snapshot_id, worker_id);
+ALTER TABLE snapshot_replicas DROP COLUMN IF EXISTS offsite_target_id;
+DROP TABLE IF EXISTS offsite_targets;
+-- +goose StatementEnd
+
+-- Enum values cannot be dropped; sftp stays in storage_backend
@@ -0,0 +1,43 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TYPE job_type ADD VALUE IF NOT EXISTS 'migrate_snapshot';
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE snapshots ADD COLUMN IF NOT EXISTS tiered_at TIMESTAMPTZ;
+COMMENT ON COLUMN snapshots.tiered_at IS 'When a migrate_snapshot job moved the snapshot from worker disk to colder storage';
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TABLE tiering_policies (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    source_id UUID REFERENCES sources(id) ON DELETE CASCADE,
+    min_age_days INT NOT NULL CHECK (min_age_days >= 1),
+    target_backend storage_backend NOT NULL DEFAULT 's3' CHECK (target_backend = 's3'),
+    status schedule_status NOT NULL DEFAULT 'enabled',
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP NOT NULL DEFAULT now()
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- One policy per source, plus one per tenant (source_id NULL) for its other sources
+CREATE UNIQUE INDEX idx_tiering_policies_tenant ON tiering_policies(tenant_id) WHERE source_id IS NULL;
+CREATE UNIQUE INDEX idx_tiering_policies_source ON tiering_policies(source_id) WHERE source_id IS NOT NULL;
+COMMENT ON TABLE tiering_policies IS 'When the snapshots of a tenant or source are moved from worker disk to colder storage';
+COMMENT ON COLUMN tiering_policies.min_age_days IS 'Snapshots older than this many days are moved';
+COMMENT ON COLUMN tiering_policies.target_backend IS 'Backend snapshots are moved to: the tier bucket of the worker holding them';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS tiering_policies;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DELETE FROM jobs WHERE type = 'migrate_snapshot';
+ALTER TABLE snapshots DROP COLUMN IF EXISTS tiered_at;
+-- +goose StatementEnd
+
+-- Enum values cannot be dropped; migrate_snapshot stays in job_type
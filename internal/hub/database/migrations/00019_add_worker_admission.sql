@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TYPE worker_status ADD VALUE IF NOT EXISTS 'degraded';
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE workers ADD COLUMN IF NOT EXISTS status_reasons JSONB NOT NULL DEFAULT '[]';
+COMMENT ON COLUMN workers.status_reasons IS 'Admission thresholds a degraded worker is over, which keep it from claiming jobs: check, value, limit, message';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+UPDATE workers SET status = 'online' WHERE status = 'degraded';
+ALTER TABLE workers DROP COLUMN IF EXISTS status_reasons;
+-- +goose StatementEnd
+
+-- Enum values cannot be dropped; degraded stays in worker_status
@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TYPE job_type ADD VALUE IF NOT EXISTS 'rekey_snapshot';
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE tenant_keys ADD COLUMN IF NOT EXISTS rotated_at TIMESTAMPTZ;
+COMMENT ON COLUMN tenant_keys.rotated_at IS 'When a newer key replaced this one; rotated keys still decrypt the snapshots encrypted to them';
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- Snapshots recorded before their key was tracked name it in the manifest by the
+-- first 16 characters of its public key
+UPDATE snapshots s
+SET encryption_key_id = k.id, encryption_recipient = k.public_key
+FROM tenant_keys k
+WHERE s.encryption_key_id IS NULL
+  AND k.tenant_id = s.tenant_id
+  AND length(s.manifest_json->>'encryption_key_id') = 16
+  AND left(k.public_key, 16) = s.manifest_json->>'encryption_key_id';
+COMMENT ON COLUMN snapshots.encryption_key_id IS 'Tenant key the snapshot is encrypted to; repository snapshots may also hold chunks encrypted to earlier keys';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM jobs WHERE type = 'rekey_snapshot';
+ALTER TABLE tenant_keys DROP COLUMN IF EXISTS rotated_at;
+-- +goose StatementEnd
+
+-- Enum values cannot be dropped; rekey_snapshot stays in job_type
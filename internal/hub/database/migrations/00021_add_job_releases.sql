@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS released_by TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS released_at TIMESTAMPTZ;
+COMMENT ON COLUMN jobs.released_by IS 'Workers that handed the job back, e.g. for lack of space; they only claim it again once the release back-off has passed';
+COMMENT ON COLUMN jobs.released_at IS 'When a worker last handed the job back';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE jobs DROP COLUMN IF EXISTS released_at;
+ALTER TABLE jobs DROP COLUMN IF EXISTS released_by;
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Offsite targets are only connected to with a pinned host key. Targets set without
+-- one are disabled until an admin sets it, rather than pushed to unchecked.
+UPDATE offsite_targets SET status = 'disabled', updated_at = now() WHERE host_key IS NULL AND status = 'enabled';
+COMMENT ON COLUMN offsite_targets.host_key IS 'Server public key in authorized_keys format; connections to a server presenting another key are refused, and targets without one are not connected to';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+COMMENT ON COLUMN offsite_targets.host_key IS 'Server public key in authorized_keys format; the key is not checked when NULL';
+-- +goose StatementEnd
+
+-- Targets disabled by the Up migration stay disabled
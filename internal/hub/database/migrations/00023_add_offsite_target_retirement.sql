@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE offsite_targets ADD COLUMN IF NOT EXISTS retired_at TIMESTAMPTZ;
+COMMENT ON COLUMN offsite_targets.retired_at IS 'When the tenant moved its target to another server or directory; a retired target is kept for the copies already pushed to it and deleted once it holds none';
+ALTER TABLE offsite_targets DROP CONSTRAINT IF EXISTS offsite_targets_tenant_id_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_offsite_targets_tenant_current ON offsite_targets(tenant_id) WHERE retired_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM offsite_targets WHERE retired_at IS NOT NULL;
+DROP INDEX IF EXISTS idx_offsite_targets_tenant_current;
+ALTER TABLE offsite_targets ADD CONSTRAINT offsite_targets_tenant_id_key UNIQUE (tenant_id);
+ALTER TABLE offsite_targets DROP COLUMN IF EXISTS retired_at;
+-- +goose StatementEnd
@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE snapshots ADD COLUMN IF NOT EXISTS download_manifest_verification TEXT;
+COMMENT ON COLUMN snapshots.download_manifest_verification IS 'How the restore behind the current download authenticated the manifest: verified against its recorded signature, or unsigned';
+COMMENT ON COLUMN workers.public_key IS 'Base64 Ed25519 identity key the worker signs snapshot manifests with; registration never replaces it, only an admin reset clears it';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+COMMENT ON COLUMN workers.public_key IS 'Base64 Ed25519 identity key the worker signs snapshot manifests with';
+ALTER TABLE snapshots DROP COLUMN IF EXISTS download_manifest_verification;
+-- +goose StatementEnd
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"time"
@@ -127,6 +128,9 @@ func (h *Handlers) HandleCreateSource(c *fiber.Ctx) error {
 	source, err := h.service.CreateSource(ctx, req)
 	if err != nil {
 		log.Printf("failed to create source: %v", err)
+		if errors.Is(err, service.ErrQuotaExceeded) {
+			return sendError(c, fiber.StatusForbidden, err, "Quota exceeded")
+		}
 		return sendError(c, fiber.StatusInternalServerError, err, "Failed to create source")
 	}
 
@@ -172,6 +176,29 @@ func (h *Handlers) HandleGetSource(c *fiber.Ctx) error {
 	return c.JSON(source)
 }
 
+// Quota handlers
+
+// HandleGetQuota handles GET /api/v1/quota
+// Returns the caller's tenant quota next to its usage
+func (h *Handlers) HandleGetQuota(c *fiber.Ctx) error {
+	ctx, cancel := contextWithTimeout(5 * time.Second)
+	defer cancel()
+
+	// Get tenant_id from JWT context
+	tenantID, err := middlewarepkg.GetTenantID(c)
+	if err != nil {
+		return sendError(c, fiber.StatusUnauthorized, err, "Authentication required")
+	}
+
+	status, err := h.service.GetTenantQuotaStatus(ctx, tenantID)
+	if err != nil {
+		log.Printf("failed to get quota: %v", err)
+		return sendError(c, fiber.StatusInternalServerError, err, "Failed to get quota")
+	}
+
+	return c.JSON(status)
+}
+
 // Job handlers
 
 // HandleEnqueueBackupJob handles POST /api/v1/jobs
@@ -197,6 +224,9 @@ func (h *Handlers) HandleEnqueueBackupJob(c *fiber.Ctx) error {
 	job, err := h.service.EnqueueBackupJob(ctx, tenantID, req)
 	if err != nil {
 		log.Printf("failed to enqueue job: %v", err)
+		if errors.Is(err, service.ErrQuotaExceeded) {
+			return sendError(c, fiber.StatusForbidden, err, "Quota exceeded")
+		}
 		return sendError(c, fiber.StatusInternalServerError, err, "Failed to enqueue job")
 	}
 
@@ -293,8 +323,9 @@ func (h *Handlers) HandleGetTenantPublicKey(c *fiber.Ctx) error {
 		return sendError(c, fiber.StatusNotFound, err, "Tenant key not found")
 	}
 
-	// Return only the public key
+	// Return only the public key, and the ID manifests record it by
 	return c.JSON(fiber.Map{
+		"id":         key.ID,
 		"tenant_id":  key.TenantID,
 		"public_key": key.PublicKey,
 		"algorithm":  key.Algorithm,
@@ -302,7 +333,8 @@ func (h *Handlers) HandleGetTenantPublicKey(c *fiber.Ctx) error {
 }
 
 // HandleGetTenantPrivateKey handles GET /internal/tenants/:id/private-key
-// This returns the DECRYPTED private key for restore operations
+// This returns the DECRYPTED private key for restore operations: the key ?key_id=
+// names, or every key that is not disabled, one per line
 // For v0, this is only accessible via internal API (worker to hub)
 func (h *Handlers) HandleGetTenantPrivateKey(c *fiber.Ctx) error {
 	ctx, cancel := contextWithTimeout(5 * time.Second)
@@ -313,9 +345,12 @@ func (h *Handlers) HandleGetTenantPrivateKey(c *fiber.Ctx) error {
 		return sendError(c, fiber.StatusBadRequest, fmt.Errorf("tenant_id is required"), "Validation failed")
 	}
 
-	privateKey, err := h.service.GetTenantPrivateKeyForWorker(ctx, tenantID)
+	privateKey, err := h.service.GetTenantPrivateKeyForWorker(ctx, tenantID, c.Query("key_id"))
 	if err != nil {
 		log.Printf("failed to get tenant private key: %v", err)
+		if errors.Is(err, service.ErrTenantKeyDisabled) {
+			return sendError(c, fiber.StatusConflict, err, "Tenant key is disabled")
+		}
 		return sendError(c, fiber.StatusNotFound, err, "Tenant key not found")
 	}
 
@@ -326,6 +361,29 @@ func (h *Handlers) HandleGetTenantPrivateKey(c *fiber.Ctx) error {
 	})
 }
 
+// HandleGetOffsiteTargetAccess handles GET /internal/offsite-targets/:id
+// Returns an offsite target with its decrypted secret, for pushes and restores
+func (h *Handlers) HandleGetOffsiteTargetAccess(c *fiber.Ctx) error {
+	ctx, cancel := contextWithTimeout(5 * time.Second)
+	defer cancel()
+
+	targetID := c.Params("id")
+	if targetID == "" {
+		return sendError(c, fiber.StatusBadRequest, fmt.Errorf("offsite_target_id is required"), "Validation failed")
+	}
+
+	access, err := h.service.GetOffsiteTargetAccess(ctx, targetID)
+	if err != nil {
+		log.Printf("failed to get offsite target: %v", err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return sendError(c, fiber.StatusNotFound, err, "Offsite target not found")
+		}
+		return sendError(c, fiber.StatusInternalServerError, err, "Failed to get offsite target")
+	}
+
+	return c.JSON(access)
+}
+
 // HandleRegisterWorker handles POST /internal/workers/register
 func (h *Handlers) HandleRegisterWorker(c *fiber.Ctx) error {
 	ctx, cancel := contextWithTimeout(5 * time.Second)
@@ -347,6 +405,9 @@ func (h *Handlers) HandleRegisterWorker(c *fiber.Ctx) error {
 	worker, err := h.service.RegisterWorker(ctx, req)
 	if err != nil {
 		log.Printf("failed to register worker: %v", err)
+		if errors.Is(err, service.ErrWorkerIdentityMismatch) {
+			return sendError(c, fiber.StatusConflict, err, "Worker identity key does not match; an admin must reset it")
+		}
 		return sendError(c, fiber.StatusInternalServerError, err, "Failed to register worker")
 	}
 
@@ -375,6 +436,114 @@ func (h *Handlers) HandleWorkerHeartbeat(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{"ok": true})
 }
 
+// HandleReportSnapshotIntegrity handles POST /internal/snapshots/:id/integrity
+func (h *Handlers) HandleReportSnapshotIntegrity(c *fiber.Ctx) error {
+	ctx, cancel := contextWithTimeout(5 * time.Second)
+	defer cancel()
+
+	snapshotID := c.Params("id")
+	if snapshotID == "" {
+		return sendError(c, fiber.StatusBadRequest, fmt.Errorf("snapshot id is required"), "Validation failed")
+	}
+
+	var req types.SnapshotIntegrityRequest
+	if err := c.BodyParser(&req); err != nil {
+		return sendError(c, fiber.StatusBadRequest, err, "Invalid request body")
+	}
+
+	if req.WorkerID == "" || req.Status == "" {
+		return sendError(c, fiber.StatusBadRequest, fmt.Errorf("worker_id and status are required"), "Validation failed")
+	}
+
+	recorded, err := h.service.ReportSnapshotIntegrity(ctx, snapshotID, req)
+	if err != nil {
+		log.Printf("failed to record snapshot integrity: %v", err)
+		if errors.Is(err, service.ErrSnapshotWorkerMismatch) {
+			return sendError(c, fiber.StatusForbidden, err, "Snapshot is stored by another worker")
+		}
+		return sendError(c, fiber.StatusInternalServerError, err, "Failed to record snapshot integrity")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"recorded": recorded})
+}
+
+// HandleGetSnapshotLockStatus handles GET /internal/snapshots/:id/lock
+// Workers check it before deleting a snapshot's files
+func (h *Handlers) HandleGetSnapshotLockStatus(c *fiber.Ctx) error {
+	ctx, cancel := contextWithTimeout(5 * time.Second)
+	defer cancel()
+
+	snapshotID := c.Params("id")
+	if snapshotID == "" {
+		return sendError(c, fiber.StatusBadRequest, fmt.Errorf("snapshot id is required"), "Validation failed")
+	}
+
+	status, err := h.service.GetSnapshotLockStatus(ctx, snapshotID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return sendError(c, fiber.StatusNotFound, err, "Snapshot not found")
+		}
+		log.Printf("failed to get snapshot lock status: %v", err)
+		return sendError(c, fiber.StatusInternalServerError, err, "Failed to get snapshot lock status")
+	}
+
+	return c.JSON(status)
+}
+
+// HandleAuthorizeReplicaTransfer handles POST /internal/replicas/authorize
+// A worker's replication server checks a transfer token before serving a snapshot
+func (h *Handlers) HandleAuthorizeReplicaTransfer(c *fiber.Ctx) error {
+	ctx, cancel := contextWithTimeout(5 * time.Second)
+	defer cancel()
+
+	var req types.ReplicaTransferRequest
+	if err := c.BodyParser(&req); err != nil {
+		return sendError(c, fiber.StatusBadRequest, err, "Invalid request body")
+	}
+
+	if req.WorkerID == "" || req.Token == "" {
+		return sendError(c, fiber.StatusBadRequest, fmt.Errorf("worker_id and token are required"), "Validation failed")
+	}
+
+	transfer, err := h.service.AuthorizeReplicaTransfer(ctx, req)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return sendError(c, fiber.StatusForbidden, fmt.Errorf("invalid transfer token"), "Transfer not authorized")
+		}
+		log.Printf("failed to authorize replica transfer: %v", err)
+		return sendError(c, fiber.StatusInternalServerError, err, "Failed to authorize transfer")
+	}
+
+	return c.JSON(transfer)
+}
+
+// HandleAuthorizeFileListing handles POST /internal/file-listings/authorize
+// The restore service redeems the token of a file listing request for its snapshot
+func (h *Handlers) HandleAuthorizeFileListing(c *fiber.Ctx) error {
+	ctx, cancel := contextWithTimeout(5 * time.Second)
+	defer cancel()
+
+	var req types.FileListingAuthorizeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return sendError(c, fiber.StatusBadRequest, err, "Invalid request body")
+	}
+
+	if req.Token == "" {
+		return sendError(c, fiber.StatusBadRequest, fmt.Errorf("token is required"), "Validation failed")
+	}
+
+	listing, err := h.service.AuthorizeFileListing(ctx, req)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return sendError(c, fiber.StatusForbidden, fmt.Errorf("invalid file listing token"), "File listing not authorized")
+		}
+		log.Printf("failed to authorize file listing: %v", err)
+		return sendError(c, fiber.StatusInternalServerError, err, "Failed to authorize file listing")
+	}
+
+	return c.JSON(listing)
+}
+
 // Snapshot handlers
 
 // HandleListSnapshots handles GET /api/v1/snapshots
@@ -639,7 +808,7 @@ func (h *Handlers) HandleListSchedules(c *fiber.Ctx) error {
 // Restore handlers
 
 // HandleEnqueueRestoreJob handles POST /api/v1/snapshots/:id/restore
-// This creates a restore job and returns a job ID
+// This creates a restore job, optionally limited to some paths, and returns a job ID
 func (h *Handlers) HandleEnqueueRestoreJob(c *fiber.Ctx) error {
 	ctx, cancel := contextWithTimeout(5 * time.Second)
 	defer cancel()
@@ -655,7 +824,19 @@ func (h *Handlers) HandleEnqueueRestoreJob(c *fiber.Ctx) error {
 		return sendError(c, fiber.StatusUnauthorized, err, "Authentication required")
 	}
 
-	job, err := h.service.EnqueueRestoreJob(ctx, tenantID, snapshotID)
+	// The body is optional; paths narrows the restore to a file or subtree and
+	// original_locations lays it out by the paths the trees were backed up from
+	var req struct {
+		Paths             []string `json:"paths"`
+		OriginalLocations bool     `json:"original_locations"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return sendError(c, fiber.StatusBadRequest, err, "Invalid request body")
+		}
+	}
+
+	job, err := h.service.EnqueueRestoreJob(ctx, tenantID, snapshotID, req.Paths, req.OriginalLocations)
 	if err != nil {
 		log.Printf("failed to enqueue restore job: %v", err)
 		return sendError(c, fiber.StatusInternalServerError, err, "Failed to enqueue restore job")
@@ -664,6 +845,35 @@ func (h *Handlers) HandleEnqueueRestoreJob(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusCreated).JSON(job)
 }
 
+// HandleListSnapshotFiles handles GET /api/v1/snapshots/:id/files?prefix=
+// This lists the files recorded in the snapshot's file index
+func (h *Handlers) HandleListSnapshotFiles(c *fiber.Ctx) error {
+	ctx, cancel := contextWithTimeout(30 * time.Second)
+	defer cancel()
+
+	snapshotID := c.Params("id")
+	if snapshotID == "" {
+		return sendError(c, fiber.StatusBadRequest, fmt.Errorf("snapshot_id is required"), "Validation failed")
+	}
+
+	// Get tenant_id from JWT context
+	tenantID, err := middlewarepkg.GetTenantID(c)
+	if err != nil {
+		return sendError(c, fiber.StatusUnauthorized, err, "Authentication required")
+	}
+
+	files, err := h.service.ListSnapshotFiles(ctx, tenantID, snapshotID, c.Query("prefix"))
+	if err != nil {
+		log.Printf("failed to list snapshot files: %v", err)
+		if errors.Is(err, service.ErrFileIndexUnavailable) {
+			return sendError(c, fiber.StatusNotFound, err, "Snapshot has no file index")
+		}
+		return sendError(c, fiber.StatusInternalServerError, err, "Failed to list snapshot files")
+	}
+
+	return c.JSON(files)
+}
+
 // Internal/Restore Service handlers
 
 // HandleClaimRestoreJob handles POST /internal/restore-jobs/claim
@@ -836,6 +1046,24 @@ func (h *Handlers) HandleUpdateSetting(c *fiber.Ctx) error {
 	return c.JSON(setting)
 }
 
+// HandleReencryptSecretsAdmin handles POST /api/v1/admin/kek/reencrypt
+// Re-encrypts tenant keys and credentials still in the legacy KEK format (admin only)
+func (h *Handlers) HandleReencryptSecretsAdmin(c *fiber.Ctx) error {
+	ctx, cancel := contextWithTimeout(5 * time.Minute)
+	defer cancel()
+
+	report, err := h.service.ReencryptLegacySecrets(ctx)
+	if err != nil {
+		log.Printf("failed to re-encrypt secrets: %v", err)
+		return sendError(c, fiber.StatusInternalServerError, err, "Failed to re-encrypt secrets")
+	}
+
+	details, _ := json.Marshal(report)
+	h.createAuditEvent(ctx, c, service.AuditActionReencryptSecrets, service.AuditTargetKEK, report.KEKID, report.KEKID, nil, details)
+
+	return c.JSON(report)
+}
+
 // Admin / User handlers
 
 // HandleListUsers handles GET /api/v1/admin/users
@@ -1021,6 +1249,9 @@ func (h *Handlers) HandleDeleteTenant(c *fiber.Ctx) error {
 
 	if err := h.service.DeleteTenant(ctx, id); err != nil {
 		log.Printf("failed to delete tenant: %v", err)
+		if errors.Is(err, service.ErrSnapshotLocked) {
+			return sendError(c, fiber.StatusConflict, err, "Tenant has snapshots under legal hold or locked")
+		}
 		return sendError(c, fiber.StatusInternalServerError, err, "Failed to delete tenant")
 	}
 
@@ -1108,6 +1339,9 @@ func (h *Handlers) HandleCreateSourceAdmin(c *fiber.Ctx) error {
 	source, err := h.service.CreateSourceAdmin(ctx, req)
 	if err != nil {
 		log.Printf("failed to create source: %v", err)
+		if errors.Is(err, service.ErrQuotaExceeded) {
+			return sendError(c, fiber.StatusForbidden, err, "Quota exceeded")
+		}
 		return sendError(c, fiber.StatusInternalServerError, err, "Failed to create source")
 	}
 
@@ -1167,6 +1401,9 @@ func (h *Handlers) HandleDeleteSourceAdmin(c *fiber.Ctx) error {
 
 	if err := h.service.DeleteSource(ctx, id); err != nil {
 		log.Printf("failed to delete source: %v", err)
+		if errors.Is(err, service.ErrSnapshotLocked) {
+			return sendError(c, fiber.StatusConflict, err, "Source has snapshots under legal hold or locked")
+		}
 		return sendError(c, fiber.StatusInternalServerError, err, "Failed to delete source")
 	}
 
@@ -1223,6 +1460,9 @@ func (h *Handlers) HandleTriggerBackupAdmin(c *fiber.Ctx) error {
 	job, err := h.service.TriggerBackupAdmin(ctx, id)
 	if err != nil {
 		log.Printf("failed to trigger backup: %v", err)
+		if errors.Is(err, service.ErrQuotaExceeded) {
+			return sendError(c, fiber.StatusForbidden, err, "Quota exceeded")
+		}
 		return sendError(c, fiber.StatusInternalServerError, err, "Failed to trigger backup")
 	}
 
@@ -1359,32 +1599,53 @@ func (h *Handlers) HandleDeleteScheduleAdmin(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusNoContent).Send(nil)
 }
 
-// Admin / Snapshot handlers
+// Admin / Verify schedule handlers
 
-// HandleListSnapshotsAdmin handles GET /api/v1/admin/snapshots
-// Returns all snapshots and in-progress jobs across all tenants with source/tenant info (admin only)
-func (h *Handlers) HandleListSnapshotsAdmin(c *fiber.Ctx) error {
+// HandleListVerifySchedulesAdmin handles GET /api/v1/admin/verify-schedules
+// Returns verify schedules, optionally for one tenant (admin only)
+func (h *Handlers) HandleListVerifySchedulesAdmin(c *fiber.Ctx) error {
 	ctx, cancel := contextWithTimeout(5 * time.Second)
 	defer cancel()
 
-	limit := c.QueryInt("limit", 200)
-	if limit > 500 {
-		limit = 500
+	schedules, err := h.service.ListVerifySchedules(ctx, c.Query("tenant_id"))
+	if err != nil {
+		log.Printf("failed to list verify schedules: %v", err)
+		return sendError(c, fiber.StatusInternalServerError, err, "Failed to list verify schedules")
 	}
 
-	// Use the new method that includes both snapshots and jobs
-	snapshots, err := h.service.ListAllSnapshotsAndJobsAdmin(ctx, limit)
+	return c.JSON(fiber.Map{"verify_schedules": schedules})
+}
+
+// HandleCreateVerifyScheduleAdmin handles POST /api/v1/admin/verify-schedules
+// Creates a verify schedule for a tenant or one of its sources (admin only)
+func (h *Handlers) HandleCreateVerifyScheduleAdmin(c *fiber.Ctx) error {
+	ctx, cancel := contextWithTimeout(5 * time.Second)
+	defer cancel()
+
+	var req service.CreateVerifyScheduleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return sendError(c, fiber.StatusBadRequest, err, "Invalid request body")
+	}
+
+	if req.TenantID == "" {
+		return sendError(c, fiber.StatusBadRequest, fmt.Errorf("tenant_id is required"), "Validation failed")
+	}
+
+	schedule, err := h.service.CreateVerifySchedule(ctx, req)
 	if err != nil {
-		log.Printf("failed to list all snapshots: %v", err)
-		return sendError(c, fiber.StatusInternalServerError, err, "Failed to list snapshots")
+		log.Printf("failed to create verify schedule: %v", err)
+		return sendError(c, fiber.StatusBadRequest, err, "Failed to create verify schedule")
 	}
 
-	return c.JSON(fiber.Map{"snapshots": snapshots})
+	// Audit log
+	h.createAuditEvent(ctx, c, service.AuditActionCreateVerifySchedule, service.AuditTargetSchedule, schedule.ID, "Verify schedule "+schedule.ID[:8], &schedule.TenantID, nil)
+
+	return c.Status(fiber.StatusCreated).JSON(schedule)
 }
 
-// HandleGetSnapshotAdmin handles GET /api/v1/admin/snapshots/:id
-// Returns a specific snapshot (admin only)
-func (h *Handlers) HandleGetSnapshotAdmin(c *fiber.Ctx) error {
+// HandleUpdateVerifyScheduleAdmin handles PUT /api/v1/admin/verify-schedules/:id
+// Updates a verify schedule (admin only)
+func (h *Handlers) HandleUpdateVerifyScheduleAdmin(c *fiber.Ctx) error {
 	ctx, cancel := contextWithTimeout(5 * time.Second)
 	defer cancel()
 
@@ -1393,103 +1654,1071 @@ func (h *Handlers) HandleGetSnapshotAdmin(c *fiber.Ctx) error {
 		return sendError(c, fiber.StatusBadRequest, fmt.Errorf("id is required"), "Validation failed")
 	}
 
-	snapshot, err := h.service.GetSnapshot(ctx, id)
+	var req service.UpdateVerifyScheduleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return sendError(c, fiber.StatusBadRequest, err, "Invalid request body")
+	}
+
+	schedule, err := h.service.UpdateVerifySchedule(ctx, id, req)
 	if err != nil {
-		log.Printf("failed to get snapshot: %v", err)
-		return sendError(c, fiber.StatusNotFound, err, "Snapshot not found")
+		log.Printf("failed to update verify schedule: %v", err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return sendError(c, fiber.StatusNotFound, err, "Verify schedule not found")
+		}
+		return sendError(c, fiber.StatusBadRequest, err, "Failed to update verify schedule")
 	}
 
-	return c.JSON(snapshot)
+	// Audit log
+	h.createAuditEvent(ctx, c, service.AuditActionUpdateVerifySchedule, service.AuditTargetSchedule, id, "Verify schedule "+id[:8], &schedule.TenantID, nil)
+
+	return c.JSON(schedule)
 }
 
-// HandleGetLogsForSnapshot handles GET /api/v1/admin/snapshots/:id/logs
-// Returns logs for a specific snapshot (admin only)
-func (h *Handlers) HandleGetLogsForSnapshot(c *fiber.Ctx) error {
+// HandleDeleteVerifyScheduleAdmin handles DELETE /api/v1/admin/verify-schedules/:id
+// Deletes a verify schedule (admin only)
+func (h *Handlers) HandleDeleteVerifyScheduleAdmin(c *fiber.Ctx) error {
 	ctx, cancel := contextWithTimeout(5 * time.Second)
 	defer cancel()
 
-	snapshotID := c.Params("id")
-	if snapshotID == "" {
-		return sendError(c, fiber.StatusBadRequest, fmt.Errorf("snapshot_id is required"), "Validation failed")
+	id := c.Params("id")
+	if id == "" {
+		return sendError(c, fiber.StatusBadRequest, fmt.Errorf("id is required"), "Validation failed")
 	}
 
-	limit := c.QueryInt("limit", 100)
-	if limit > 500 {
-		limit = 500
+	// Get schedule info before deletion for audit log
+	schedule, _ := h.service.GetVerifySchedule(ctx, id)
+	var tenantID *string
+	if schedule != nil {
+		tenantID = &schedule.TenantID
 	}
 
-	logs, err := h.service.GetLogsForSnapshot(ctx, snapshotID, limit)
+	if err := h.service.DeleteVerifySchedule(ctx, id); err != nil {
+		log.Printf("failed to delete verify schedule: %v", err)
+		return sendError(c, fiber.StatusInternalServerError, err, "Failed to delete verify schedule")
+	}
+
+	// Audit log
+	h.createAuditEvent(ctx, c, service.AuditActionDeleteVerifySchedule, service.AuditTargetSchedule, id, "Verify schedule "+id[:8], tenantID, nil)
+
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
+
+// Admin / Replication policy handlers
+
+// HandleListReplicationPoliciesAdmin handles GET /api/v1/admin/replication-policies
+// Returns replication policies, optionally for one tenant (admin only)
+func (h *Handlers) HandleListReplicationPoliciesAdmin(c *fiber.Ctx) error {
+	ctx, cancel := contextWithTimeout(5 * time.Second)
+	defer cancel()
+
+	policies, err := h.service.ListReplicationPolicies(ctx, c.Query("tenant_id"))
 	if err != nil {
-		log.Printf("failed to get logs for snapshot: %v", err)
-		return sendError(c, fiber.StatusInternalServerError, err, "Failed to get logs")
+		log.Printf("failed to list replication policies: %v", err)
+		return sendError(c, fiber.StatusInternalServerError, err, "Failed to list replication policies")
 	}
 
-	return c.JSON(fiber.Map{"logs": logs})
+	return c.JSON(fiber.Map{"replication_policies": policies})
 }
 
-// HandleDeleteSnapshotAdmin handles DELETE /api/v1/admin/snapshots/:id
-// Deletes a snapshot by enqueuing a delete_snapshot job to clean up worker storage
-// and remove the database record (admin only)
-func (h *Handlers) HandleDeleteSnapshotAdmin(c *fiber.Ctx) error {
-	ctx, cancel := contextWithTimeout(10 * time.Second)
+// HandleCreateReplicationPolicyAdmin handles POST /api/v1/admin/replication-policies
+// Creates a replication policy for a tenant or one of its sources (admin only)
+func (h *Handlers) HandleCreateReplicationPolicyAdmin(c *fiber.Ctx) error {
+	ctx, cancel := contextWithTimeout(5 * time.Second)
 	defer cancel()
 
-	snapshotID := c.Params("id")
-	if snapshotID == "" {
-		return sendError(c, fiber.StatusBadRequest, fmt.Errorf("snapshot_id is required"), "Validation failed")
+	var req service.CreateReplicationPolicyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return sendError(c, fiber.StatusBadRequest, err, "Invalid request body")
 	}
 
-	// Get snapshot info for audit log
-	snapshot, _ := h.service.GetSnapshot(ctx, snapshotID)
-	var tenantID *string
-	snapshotName := "Snapshot " + snapshotID[:8]
-	if snapshot != nil {
-		tenantID = &snapshot.TenantID
+	if req.TenantID == "" {
+		return sendError(c, fiber.StatusBadRequest, fmt.Errorf("tenant_id is required"), "Validation failed")
 	}
 
-	// Enqueue delete job (worker will clean up storage, then DB record is removed)
-	job, err := h.service.EnqueueDeleteJob(ctx, snapshotID)
+	policy, err := h.service.CreateReplicationPolicy(ctx, req)
 	if err != nil {
-		log.Printf("failed to enqueue delete job for snapshot: %v", err)
-		return sendError(c, fiber.StatusInternalServerError, err, "Failed to enqueue delete job")
+		log.Printf("failed to create replication policy: %v", err)
+		return sendError(c, fiber.StatusBadRequest, err, "Failed to create replication policy")
 	}
 
 	// Audit log
-	h.createAuditEvent(ctx, c, service.AuditActionDeleteSnapshot, service.AuditTargetSnapshot, snapshotID, snapshotName, tenantID, nil)
-
-	log.Printf("enqueued delete job %s for snapshot %s", job.ID, snapshotID)
+	details, _ := json.Marshal(map[string]any{"copies": policy.Copies, "object_store_copy": policy.ObjectStoreCopy, "source_id": policy.SourceID})
+	h.createAuditEvent(ctx, c, service.AuditActionCreateReplicationPolicy, service.AuditTargetReplicationPolicy, policy.ID, "Replication policy "+policy.ID[:8], &policy.TenantID, details)
 
-	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
-		"message":     "Delete job enqueued successfully",
-		"job_id":      job.ID,
-		"snapshot_id": snapshotID,
-		"worker_id":   job.TargetWorkerID,
-	})
+	return c.Status(fiber.StatusCreated).JSON(policy)
 }
 
-// HandleGetLogsForSource handles GET /api/v1/admin/sources/:id/logs
-// Returns logs for a specific source (admin only)
-func (h *Handlers) HandleGetLogsForSource(c *fiber.Ctx) error {
+// HandleUpdateReplicationPolicyAdmin handles PUT /api/v1/admin/replication-policies/:id
+// Updates a replication policy (admin only)
+func (h *Handlers) HandleUpdateReplicationPolicyAdmin(c *fiber.Ctx) error {
 	ctx, cancel := contextWithTimeout(5 * time.Second)
 	defer cancel()
 
-	sourceID := c.Params("id")
-	if sourceID == "" {
-		return sendError(c, fiber.StatusBadRequest, fmt.Errorf("source_id is required"), "Validation failed")
+	id := c.Params("id")
+	if id == "" {
+		return sendError(c, fiber.StatusBadRequest, fmt.Errorf("id is required"), "Validation failed")
 	}
 
-	limit := c.QueryInt("limit", 100)
-	if limit > 500 {
-		limit = 500
+	var req service.UpdateReplicationPolicyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return sendError(c, fiber.StatusBadRequest, err, "Invalid request body")
 	}
 
-	logs, err := h.service.GetLogsForSource(ctx, sourceID, limit)
+	policy, err := h.service.UpdateReplicationPolicy(ctx, id, req)
 	if err != nil {
-		log.Printf("failed to get logs for source: %v", err)
-		return sendError(c, fiber.StatusInternalServerError, err, "Failed to get logs")
+		log.Printf("failed to update replication policy: %v", err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return sendError(c, fiber.StatusNotFound, err, "Replication policy not found")
+		}
+		return sendError(c, fiber.StatusBadRequest, err, "Failed to update replication policy")
 	}
 
-	return c.JSON(fiber.Map{"logs": logs})
+	// Audit log
+	details, _ := json.Marshal(map[string]any{"copies": policy.Copies, "object_store_copy": policy.ObjectStoreCopy, "status": policy.Status})
+	h.createAuditEvent(ctx, c, service.AuditActionUpdateReplicationPolicy, service.AuditTargetReplicationPolicy, id, "Replication policy "+id[:8], &policy.TenantID, details)
+
+	return c.JSON(policy)
+}
+
+// HandleDeleteReplicationPolicyAdmin handles DELETE /api/v1/admin/replication-policies/:id
+// Deletes a replication policy; existing replicas are kept (admin only)
+func (h *Handlers) HandleDeleteReplicationPolicyAdmin(c *fiber.Ctx) error {
+	ctx, cancel := contextWithTimeout(5 * time.Second)
+	defer cancel()
+
+	id := c.Params("id")
+	if id == "" {
+		return sendError(c, fiber.StatusBadRequest, fmt.Errorf("id is required"), "Validation failed")
+	}
+
+	// Get policy info before deletion for audit log
+	policy, _ := h.service.GetReplicationPolicy(ctx, id)
+	var tenantID *string
+	if policy != nil {
+		tenantID = &policy.TenantID
+	}
+
+	if err := h.service.DeleteReplicationPolicy(ctx, id); err != nil {
+		log.Printf("failed to delete replication policy: %v", err)
+		return sendError(c, fiber.StatusInternalServerError, err, "Failed to delete replication policy")
+	}
+
+	// Audit log
+	h.createAuditEvent(ctx, c, service.AuditActionDeleteReplicationPolicy, service.AuditTargetReplicationPolicy, id, "Replication policy "+id[:8], tenantID, nil)
+
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
+
+// HandleListTieringPoliciesAdmin handles GET /api/v1/admin/tiering-policies
+// Returns tiering policies, optionally for one tenant (admin only)
+func (h *Handlers) HandleListTieringPoliciesAdmin(c *fiber.Ctx) error {
+	ctx, cancel := contextWithTimeout(5 * time.Second)
+	defer cancel()
+
+	policies, err := h.service.ListTieringPolicies(ctx, c.Query("tenant_id"))
+	if err != nil {
+		log.Printf("failed to list tiering policies: %v", err)
+		return sendError(c, fiber.StatusInternalServerError, err, "Failed to list tiering policies")
+	}
+
+	return c.JSON(fiber.Map{"tiering_policies": policies})
+}
+
+// HandleCreateTieringPolicyAdmin handles POST /api/v1/admin/tiering-policies
+// Creates a tiering policy for a tenant or one of its sources (admin only)
+func (h *Handlers) HandleCreateTieringPolicyAdmin(c *fiber.Ctx) error {
+	ctx, cancel := contextWithTimeout(5 * time.Second)
+	defer cancel()
+
+	var req service.CreateTieringPolicyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return sendError(c, fiber.StatusBadRequest, err, "Invalid request body")
+	}
+
+	if req.TenantID == "" {
+		return sendError(c, fiber.StatusBadRequest, fmt.Errorf("tenant_id is required"), "Validation failed")
+	}
+
+	policy, err := h.service.CreateTieringPolicy(ctx, req)
+	if err != nil {
+		log.Printf("failed to create tiering policy: %v", err)
+		return sendError(c, fiber.StatusBadRequest, err, "Failed to create tiering policy")
+	}
+
+	// Audit log
+	details, _ := json.Marshal(map[string]any{"min_age_days": policy.MinAgeDays, "target_backend": policy.TargetBackend, "source_id": policy.SourceID})
+	h.createAuditEvent(ctx, c, service.AuditActionCreateTieringPolicy, service.AuditTargetTieringPolicy, policy.ID, "Tiering policy "+policy.ID[:8], &policy.TenantID, details)
+
+	return c.Status(fiber.StatusCreated).JSON(policy)
+}
+
+// HandleUpdateTieringPolicyAdmin handles PUT /api/v1/admin/tiering-policies/:id
+// Updates a tiering policy (admin only)
+func (h *Handlers) HandleUpdateTieringPolicyAdmin(c *fiber.Ctx) error {
+	ctx, cancel := contextWithTimeout(5 * time.Second)
+	defer cancel()
+
+	id := c.Params("id")
+	if id == "" {
+		return sendError(c, fiber.StatusBadRequest, fmt.Errorf("id is required"), "Validation failed")
+	}
+
+	var req service.UpdateTieringPolicyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return sendError(c, fiber.StatusBadRequest, err, "Invalid request body")
+	}
+
+	policy, err := h.service.UpdateTieringPolicy(ctx, id, req)
+	if err != nil {
+		log.Printf("failed to update tiering policy: %v", err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return sendError(c, fiber.StatusNotFound, err, "Tiering policy not found")
+		}
+		return sendError(c, fiber.StatusBadRequest, err, "Failed to update tiering policy")
+	}
+
+	// Audit log
+	details, _ := json.Marshal(map[string]any{"min_age_days": policy.MinAgeDays, "target_backend": policy.TargetBackend, "status": policy.Status})
+	h.createAuditEvent(ctx, c, service.AuditActionUpdateTieringPolicy, service.AuditTargetTieringPolicy, id, "Tiering policy "+id[:8], &policy.TenantID, details)
+
+	return c.JSON(policy)
+}
+
+// HandleDeleteTieringPolicyAdmin handles DELETE /api/v1/admin/tiering-policies/:id
+// Deletes a tiering policy; snapshots already moved stay where they are (admin only)
+func (h *Handlers) HandleDeleteTieringPolicyAdmin(c *fiber.Ctx) error {
+	ctx, cancel := contextWithTimeout(5 * time.Second)
+	defer cancel()
+
+	id := c.Params("id")
+	if id == "" {
+		return sendError(c, fiber.StatusBadRequest, fmt.Errorf("id is required"), "Validation failed")
+	}
+
+	// Get policy info before deletion for audit log
+	policy, _ := h.service.GetTieringPolicy(ctx, id)
+	var tenantID *string
+	if policy != nil {
+		tenantID = &policy.TenantID
+	}
+
+	if err := h.service.DeleteTieringPolicy(ctx, id); err != nil {
+		log.Printf("failed to delete tiering policy: %v", err)
+		return sendError(c, fiber.StatusInternalServerError, err, "Failed to delete tiering policy")
+	}
+
+	// Audit log
+	h.createAuditEvent(ctx, c, service.AuditActionDeleteTieringPolicy, service.AuditTargetTieringPolicy, id, "Tiering policy "+id[:8], tenantID, nil)
+
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
+
+// Admin / Quota handlers
+
+// HandleListQuotasAdmin handles GET /api/v1/admin/quotas
+// Returns every tenant and plan quota (admin only)
+func (h *Handlers) HandleListQuotasAdmin(c *fiber.Ctx) error {
+	ctx, cancel := contextWithTimeout(5 * time.Second)
+	defer cancel()
+
+	quotas, err := h.service.ListQuotas(ctx)
+	if err != nil {
+		log.Printf("failed to list quotas: %v", err)
+		return sendError(c, fiber.StatusInternalServerError, err, "Failed to list quotas")
+	}
+
+	return c.JSON(fiber.Map{"quotas": quotas})
+}
+
+// HandleGetTenantQuotaAdmin handles GET /api/v1/admin/tenants/:id/quota
+// Returns a tenant's quota next to its usage (admin only)
+func (h *Handlers) HandleGetTenantQuotaAdmin(c *fiber.Ctx) error {
+	ctx, cancel := contextWithTimeout(5 * time.Second)
+	defer cancel()
+
+	id := c.Params("id")
+	if id == "" {
+		return sendError(c, fiber.StatusBadRequest, fmt.Errorf("id is required"), "Validation failed")
+	}
+
+	status, err := h.service.GetTenantQuotaStatus(ctx, id)
+	if err != nil {
+		log.Printf("failed to get tenant quota: %v", err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return sendError(c, fiber.StatusNotFound, err, "Tenant not found")
+		}
+		return sendError(c, fiber.StatusInternalServerError, err, "Failed to get tenant quota")
+	}
+
+	return c.JSON(status)
+}
+
+// HandleSetTenantQuotaAdmin handles PUT /api/v1/admin/tenants/:id/quota
+// Sets a tenant's own quota, replacing its plan's (admin only)
+func (h *Handlers) HandleSetTenantQuotaAdmin(c *fiber.Ctx) error {
+	ctx, cancel := contextWithTimeout(5 * time.Second)
+	defer cancel()
+
+	id := c.Params("id")
+	if id == "" {
+		return sendError(c, fiber.StatusBadRequest, fmt.Errorf("id is required"), "Validation failed")
+	}
+
+	var req service.SetQuotaRequest
+	if err := c.BodyParser(&req); err != nil {
+		return sendError(c, fiber.StatusBadRequest, err, "Invalid request body")
+	}
+
+	quota, err := h.service.SetTenantQuota(ctx, id, req)
+	if err != nil {
+		log.Printf("failed to set tenant quota: %v", err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return sendError(c, fiber.StatusNotFound, err, "Tenant not found")
+		}
+		return sendError(c, fiber.StatusBadRequest, err, "Failed to set tenant quota")
+	}
+
+	// Audit log
+	tenantName := id
+	if tenant, _ := h.service.GetTenant(ctx, id); tenant != nil {
+		tenantName = tenant.Name
+	}
+	details, _ := json.Marshal(quota)
+	h.createAuditEvent(ctx, c, service.AuditActionUpdateQuota, service.AuditTargetTenant, id, tenantName, &id, details)
+
+	return c.JSON(quota)
+}
+
+// HandleDeleteTenantQuotaAdmin handles DELETE /api/v1/admin/tenants/:id/quota
+// Removes a tenant's own quota, so its plan's applies again (admin only)
+func (h *Handlers) HandleDeleteTenantQuotaAdmin(c *fiber.Ctx) error {
+	ctx, cancel := contextWithTimeout(5 * time.Second)
+	defer cancel()
+
+	id := c.Params("id")
+	if id == "" {
+		return sendError(c, fiber.StatusBadRequest, fmt.Errorf("id is required"), "Validation failed")
+	}
+
+	if err := h.service.DeleteTenantQuota(ctx, id); err != nil {
+		log.Printf("failed to delete tenant quota: %v", err)
+		return sendError(c, fiber.StatusInternalServerError, err, "Failed to delete tenant quota")
+	}
+
+	// Audit log
+	tenantName := id
+	if tenant, _ := h.service.GetTenant(ctx, id); tenant != nil {
+		tenantName = tenant.Name
+	}
+	h.createAuditEvent(ctx, c, service.AuditActionDeleteQuota, service.AuditTargetTenant, id, tenantName, &id, nil)
+
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
+
+// HandleSetTenantPlanAdmin handles PUT /api/v1/admin/tenants/:id/plan
+// Moves a tenant to another plan (admin only)
+func (h *Handlers) HandleSetTenantPlanAdmin(c *fiber.Ctx) error {
+	ctx, cancel := contextWithTimeout(5 * time.Second)
+	defer cancel()
+
+	id := c.Params("id")
+	if id == "" {
+		return sendError(c, fiber.StatusBadRequest, fmt.Errorf("id is required"), "Validation failed")
+	}
+
+	var req service.SetTenantPlanRequest
+	if err := c.BodyParser(&req); err != nil {
+		return sendError(c, fiber.StatusBadRequest, err, "Invalid request body")
+	}
+
+	tenant, err := h.service.SetTenantPlan(ctx, id, req)
+	if err != nil {
+		log.Printf("failed to set tenant plan: %v", err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return sendError(c, fiber.StatusNotFound, err, "Tenant not found")
+		}
+		return sendError(c, fiber.StatusBadRequest, err, "Failed to set tenant plan")
+	}
+
+	// Audit log
+	details, _ := json.Marshal(map[string]any{"plan": tenant.Plan})
+	h.createAuditEvent(ctx, c, service.AuditActionUpdateTenantPlan, service.AuditTargetTenant, id, tenant.Name, &id, details)
+
+	return c.JSON(tenant)
+}
+
+// HandleSetPlanQuotaAdmin handles PUT /api/v1/admin/plans/:plan/quota
+// Sets the quota of the tenants on a plan (admin only)
+func (h *Handlers) HandleSetPlanQuotaAdmin(c *fiber.Ctx) error {
+	ctx, cancel := contextWithTimeout(5 * time.Second)
+	defer cancel()
+
+	plan := c.Params("plan")
+	if plan == "" {
+		return sendError(c, fiber.StatusBadRequest, fmt.Errorf("plan is required"), "Validation failed")
+	}
+
+	var req service.SetQuotaRequest
+	if err := c.BodyParser(&req); err != nil {
+		return sendError(c, fiber.StatusBadRequest, err, "Invalid request body")
+	}
+
+	quota, err := h.service.SetPlanQuota(ctx, plan, req)
+	if err != nil {
+		log.Printf("failed to set plan quota: %v", err)
+		return sendError(c, fiber.StatusBadRequest, err, "Failed to set plan quota")
+	}
+
+	// Audit log
+	details, _ := json.Marshal(quota)
+	h.createAuditEvent(ctx, c, service.AuditActionUpdateQuota, service.AuditTargetPlan, plan, plan, nil, details)
+
+	return c.JSON(quota)
+}
+
+// HandleDeletePlanQuotaAdmin handles DELETE /api/v1/admin/plans/:plan/quota
+// Removes the quota of a plan (admin only)
+func (h *Handlers) HandleDeletePlanQuotaAdmin(c *fiber.Ctx) error {
+	ctx, cancel := contextWithTimeout(5 * time.Second)
+	defer cancel()
+
+	plan := c.Params("plan")
+	if plan == "" {
+		return sendError(c, fiber.StatusBadRequest, fmt.Errorf("plan is required"), "Validation failed")
+	}
+
+	if err := h.service.DeletePlanQuota(ctx, plan); err != nil {
+		log.Printf("failed to delete plan quota: %v", err)
+		return sendError(c, fiber.StatusInternalServerError, err, "Failed to delete plan quota")
+	}
+
+	// Audit log
+	h.createAuditEvent(ctx, c, service.AuditActionDeleteQuota, service.AuditTargetPlan, plan, plan, nil, nil)
+
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
+
+// Admin / Snapshot handlers
+
+// HandleListSnapshotsAdmin handles GET /api/v1/admin/snapshots
+// Returns all snapshots and in-progress jobs across all tenants with source/tenant info (admin only)
+func (h *Handlers) HandleListSnapshotsAdmin(c *fiber.Ctx) error {
+	ctx, cancel := contextWithTimeout(5 * time.Second)
+	defer cancel()
+
+	limit := c.QueryInt("limit", 200)
+	if limit > 500 {
+		limit = 500
+	}
+
+	// Use the new method that includes both snapshots and jobs
+	snapshots, err := h.service.ListAllSnapshotsAndJobsAdmin(ctx, limit)
+	if err != nil {
+		log.Printf("failed to list all snapshots: %v", err)
+		return sendError(c, fiber.StatusInternalServerError, err, "Failed to list snapshots")
+	}
+
+	return c.JSON(fiber.Map{"snapshots": snapshots})
+}
+
+// HandleGetSnapshotAdmin handles GET /api/v1/admin/snapshots/:id
+// Returns a specific snapshot (admin only)
+func (h *Handlers) HandleGetSnapshotAdmin(c *fiber.Ctx) error {
+	ctx, cancel := contextWithTimeout(5 * time.Second)
+	defer cancel()
+
+	id := c.Params("id")
+	if id == "" {
+		return sendError(c, fiber.StatusBadRequest, fmt.Errorf("id is required"), "Validation failed")
+	}
+
+	snapshot, err := h.service.GetSnapshot(ctx, id)
+	if err != nil {
+		log.Printf("failed to get snapshot: %v", err)
+		return sendError(c, fiber.StatusNotFound, err, "Snapshot not found")
+	}
+
+	return c.JSON(snapshot)
+}
+
+// HandleGetLogsForSnapshot handles GET /api/v1/admin/snapshots/:id/logs
+// Returns logs for a specific snapshot (admin only)
+func (h *Handlers) HandleGetLogsForSnapshot(c *fiber.Ctx) error {
+	ctx, cancel := contextWithTimeout(5 * time.Second)
+	defer cancel()
+
+	snapshotID := c.Params("id")
+	if snapshotID == "" {
+		return sendError(c, fiber.StatusBadRequest, fmt.Errorf("snapshot_id is required"), "Validation failed")
+	}
+
+	limit := c.QueryInt("limit", 100)
+	if limit > 500 {
+		limit = 500
+	}
+
+	logs, err := h.service.GetLogsForSnapshot(ctx, snapshotID, limit)
+	if err != nil {
+		log.Printf("failed to get logs for snapshot: %v", err)
+		return sendError(c, fiber.StatusInternalServerError, err, "Failed to get logs")
+	}
+
+	return c.JSON(fiber.Map{"logs": logs})
+}
+
+// HandleDeleteSnapshotAdmin handles DELETE /api/v1/admin/snapshots/:id
+// Deletes a snapshot by enqueuing a delete_snapshot job to clean up worker storage
+// and remove the database record (admin only)
+func (h *Handlers) HandleDeleteSnapshotAdmin(c *fiber.Ctx) error {
+	ctx, cancel := contextWithTimeout(10 * time.Second)
+	defer cancel()
+
+	snapshotID := c.Params("id")
+	if snapshotID == "" {
+		return sendError(c, fiber.StatusBadRequest, fmt.Errorf("snapshot_id is required"), "Validation failed")
+	}
+
+	// Get snapshot info for audit log
+	snapshot, _ := h.service.GetSnapshot(ctx, snapshotID)
+	var tenantID *string
+	snapshotName := "Snapshot " + snapshotID[:8]
+	if snapshot != nil {
+		tenantID = &snapshot.TenantID
+	}
+
+	// Enqueue delete job (worker will clean up storage, then DB record is removed)
+	job, err := h.service.EnqueueDeleteJob(ctx, snapshotID)
+	if err != nil {
+		log.Printf("failed to enqueue delete job for snapshot: %v", err)
+		if errors.Is(err, service.ErrSnapshotLocked) {
+			return sendError(c, fiber.StatusConflict, err, "Snapshot is under legal hold or locked")
+		}
+		return sendError(c, fiber.StatusInternalServerError, err, "Failed to enqueue delete job")
+	}
+
+	// Audit log
+	h.createAuditEvent(ctx, c, service.AuditActionDeleteSnapshot, service.AuditTargetSnapshot, snapshotID, snapshotName, tenantID, nil)
+
+	log.Printf("enqueued delete job %s for snapshot %s", job.ID, snapshotID)
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message":     "Delete job enqueued successfully",
+		"job_id":      job.ID,
+		"snapshot_id": snapshotID,
+		"worker_id":   job.TargetWorkerID,
+	})
+}
+
+// HandleVerifySnapshotAdmin handles POST /api/v1/admin/snapshots/:id/verify
+// Enqueues a verify_snapshot job on the worker that stored the snapshot (admin only)
+func (h *Handlers) HandleVerifySnapshotAdmin(c *fiber.Ctx) error {
+	ctx, cancel := contextWithTimeout(10 * time.Second)
+	defer cancel()
+
+	snapshotID := c.Params("id")
+	if snapshotID == "" {
+		return sendError(c, fiber.StatusBadRequest, fmt.Errorf("snapshot_id is required"), "Validation failed")
+	}
+
+	var req service.VerifySnapshotRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return sendError(c, fiber.StatusBadRequest, err, "Invalid request body")
+		}
+	}
+
+	job, err := h.service.EnqueueVerifyJob(ctx, snapshotID, req.TestDecrypt)
+	if err != nil {
+		log.Printf("failed to enqueue verify job for snapshot: %v", err)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return sendError(c, fiber.StatusNotFound, err, "Snapshot not found")
+		case errors.Is(err, service.ErrSnapshotNotVerifiable):
+			return sendError(c, fiber.StatusConflict, err, "Snapshot cannot be verified")
+		}
+		return sendError(c, fiber.StatusInternalServerError, err, "Failed to enqueue verify job")
+	}
+
+	// Audit log
+	details, _ := json.Marshal(map[string]any{"test_decrypt": req.TestDecrypt})
+	h.createAuditEvent(ctx, c, service.AuditActionVerifySnapshot, service.AuditTargetSnapshot, snapshotID, "Snapshot "+snapshotID[:8], &job.TenantID, details)
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message":     "Verify job enqueued successfully",
+		"job_id":      job.ID,
+		"snapshot_id": snapshotID,
+		"worker_id":   job.TargetWorkerID,
+	})
+}
+
+// HandleReplicateSnapshotAdmin handles POST /api/v1/admin/snapshots/:id/replicate
+// Enqueues replicate_snapshot jobs for the copies the snapshot's replication policy
+// is short of (admin only)
+func (h *Handlers) HandleReplicateSnapshotAdmin(c *fiber.Ctx) error {
+	ctx, cancel := contextWithTimeout(10 * time.Second)
+	defer cancel()
+
+	snapshotID := c.Params("id")
+	if snapshotID == "" {
+		return sendError(c, fiber.StatusBadRequest, fmt.Errorf("snapshot_id is required"), "Validation failed")
+	}
+
+	jobs, err := h.service.ReplicateSnapshot(ctx, snapshotID)
+	if err != nil {
+		log.Printf("failed to replicate snapshot: %v", err)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return sendError(c, fiber.StatusNotFound, err, "Snapshot not found")
+		case errors.Is(err, service.ErrSnapshotNotReplicable), errors.Is(err, service.ErrNoReplicationSource):
+			return sendError(c, fiber.StatusConflict, err, "Snapshot cannot be replicated")
+		}
+		return sendError(c, fiber.StatusInternalServerError, err, "Failed to enqueue replicate jobs")
+	}
+
+	if len(jobs) == 0 {
+		return c.JSON(fiber.Map{
+			"message":     "Snapshot already has the copies its policy asks for",
+			"snapshot_id": snapshotID,
+			"job_ids":     []string{},
+		})
+	}
+
+	jobIDs := make([]string, len(jobs))
+	workerIDs := make([]string, len(jobs))
+	for i, job := range jobs {
+		jobIDs[i] = job.ID
+		if job.TargetWorkerID != nil {
+			workerIDs[i] = *job.TargetWorkerID
+		}
+	}
+
+	// Audit log
+	details, _ := json.Marshal(map[string]any{"job_ids": jobIDs, "worker_ids": workerIDs})
+	h.createAuditEvent(ctx, c, service.AuditActionReplicateSnapshot, service.AuditTargetSnapshot, snapshotID, "Snapshot "+snapshotID[:8], &jobs[0].TenantID, details)
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message":     "Replicate jobs enqueued successfully",
+		"snapshot_id": snapshotID,
+		"job_ids":     jobIDs,
+		"worker_ids":  workerIDs,
+	})
+}
+
+// HandleListSnapshotReplicasAdmin handles GET /api/v1/admin/snapshots/:id/replicas
+// Returns the replicas of a snapshot (admin only)
+func (h *Handlers) HandleListSnapshotReplicasAdmin(c *fiber.Ctx) error {
+	ctx, cancel := contextWithTimeout(5 * time.Second)
+	defer cancel()
+
+	snapshotID := c.Params("id")
+	if snapshotID == "" {
+		return sendError(c, fiber.StatusBadRequest, fmt.Errorf("snapshot_id is required"), "Validation failed")
+	}
+
+	replicas, err := h.service.ListSnapshotReplicas(ctx, snapshotID)
+	if err != nil {
+		log.Printf("failed to list snapshot replicas: %v", err)
+		return sendError(c, fiber.StatusInternalServerError, err, "Failed to list snapshot replicas")
+	}
+
+	return c.JSON(fiber.Map{"replicas": replicas})
+}
+
+// HandlePushSnapshotOffsiteAdmin handles POST /api/v1/admin/snapshots/:id/offsite
+// Enqueues a replicate_snapshot job pushing the snapshot to its tenant's offsite
+// target (admin only)
+func (h *Handlers) HandlePushSnapshotOffsiteAdmin(c *fiber.Ctx) error {
+	ctx, cancel := contextWithTimeout(10 * time.Second)
+	defer cancel()
+
+	snapshotID := c.Params("id")
+	if snapshotID == "" {
+		return sendError(c, fiber.StatusBadRequest, fmt.Errorf("snapshot_id is required"), "Validation failed")
+	}
+
+	job, err := h.service.PushSnapshotOffsite(ctx, snapshotID)
+	if err != nil {
+		log.Printf("failed to push snapshot offsite: %v", err)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return sendError(c, fiber.StatusNotFound, err, "Snapshot not found")
+		case errors.Is(err, service.ErrSnapshotNotReplicable), errors.Is(err, service.ErrNoOffsiteTarget):
+			return sendError(c, fiber.StatusConflict, err, "Snapshot cannot be pushed offsite")
+		}
+		return sendError(c, fiber.StatusInternalServerError, err, "Failed to enqueue offsite push")
+	}
+
+	// Audit log
+	details, _ := json.Marshal(map[string]any{"job_id": job.ID, "worker_id": job.TargetWorkerID})
+	h.createAuditEvent(ctx, c, service.AuditActionPushSnapshotOffsite, service.AuditTargetSnapshot, snapshotID, "Snapshot "+snapshotID[:8], &job.TenantID, details)
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message":     "Offsite push enqueued successfully",
+		"snapshot_id": snapshotID,
+		"job_id":      job.ID,
+		"worker_id":   job.TargetWorkerID,
+	})
+}
+
+// HandleMigrateSnapshotAdmin handles POST /api/v1/admin/snapshots/:id/migrate
+// Enqueues a migrate_snapshot job that moves a snapshot from worker disk to the
+// worker's tier storage (admin only)
+func (h *Handlers) HandleMigrateSnapshotAdmin(c *fiber.Ctx) error {
+	ctx, cancel := contextWithTimeout(10 * time.Second)
+	defer cancel()
+
+	snapshotID := c.Params("id")
+	if snapshotID == "" {
+		return sendError(c, fiber.StatusBadRequest, fmt.Errorf("snapshot_id is required"), "Validation failed")
+	}
+
+	job, err := h.service.MigrateSnapshot(ctx, snapshotID)
+	if err != nil {
+		log.Printf("failed to migrate snapshot: %v", err)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return sendError(c, fiber.StatusNotFound, err, "Snapshot not found")
+		case errors.Is(err, service.ErrSnapshotNotTierable):
+			return sendError(c, fiber.StatusConflict, err, "Snapshot cannot be moved to colder storage")
+		}
+		return sendError(c, fiber.StatusInternalServerError, err, "Failed to enqueue migrate job")
+	}
+
+	// Audit log
+	details, _ := json.Marshal(map[string]any{"job_id": job.ID, "worker_id": job.TargetWorkerID})
+	h.createAuditEvent(ctx, c, service.AuditActionMigrateSnapshot, service.AuditTargetSnapshot, snapshotID, "Snapshot "+snapshotID[:8], &job.TenantID, details)
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message":     "Migrate job enqueued successfully",
+		"snapshot_id": snapshotID,
+		"job_id":      job.ID,
+		"worker_id":   job.TargetWorkerID,
+	})
+}
+
+// HandleListTenantKeysAdmin handles GET /api/v1/admin/tenants/:id/keys
+// Lists a tenant's encryption keys, without their private halves (admin only)
+func (h *Handlers) HandleListTenantKeysAdmin(c *fiber.Ctx) error {
+	ctx, cancel := contextWithTimeout(5 * time.Second)
+	defer cancel()
+
+	id := c.Params("id")
+	if id == "" {
+		return sendError(c, fiber.StatusBadRequest, fmt.Errorf("id is required"), "Validation failed")
+	}
+
+	keys, err := h.service.ListTenantKeys(ctx, id)
+	if err != nil {
+		log.Printf("failed to list tenant keys: %v", err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return sendError(c, fiber.StatusNotFound, err, "Tenant not found")
+		}
+		return sendError(c, fiber.StatusInternalServerError, err, "Failed to list tenant keys")
+	}
+
+	return c.JSON(fiber.Map{"keys": keys})
+}
+
+// HandleRotateTenantKeyAdmin handles POST /api/v1/admin/tenants/:id/keys/rotate
+// Generates a new active key for a tenant; the previous one is kept for decryption
+// (admin only)
+func (h *Handlers) HandleRotateTenantKeyAdmin(c *fiber.Ctx) error {
+	ctx, cancel := contextWithTimeout(30 * time.Second)
+	defer cancel()
+
+	id := c.Params("id")
+	if id == "" {
+		return sendError(c, fiber.StatusBadRequest, fmt.Errorf("id is required"), "Validation failed")
+	}
+
+	var req service.RotateTenantKeyRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return sendError(c, fiber.StatusBadRequest, err, "Invalid request body")
+		}
+	}
+
+	result, err := h.service.RotateTenantKey(ctx, id, req)
+	if err != nil {
+		log.Printf("failed to rotate tenant key: %v", err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return sendError(c, fiber.StatusNotFound, err, "Tenant not found")
+		}
+		return sendError(c, fiber.StatusInternalServerError, err, "Failed to rotate tenant key")
+	}
+
+	// Audit log
+	tenantName := id
+	if tenant, _ := h.service.GetTenant(ctx, id); tenant != nil {
+		tenantName = tenant.Name
+	}
+	details, _ := json.Marshal(map[string]any{
+		"key_id":              result.Key.ID,
+		"reencrypt_snapshots": req.ReencryptSnapshots,
+		"rekey_jobs":          len(result.RekeyJobIDs),
+	})
+	h.createAuditEvent(ctx, c, service.AuditActionRotateTenantKey, service.AuditTargetTenant, id, tenantName, &id, details)
+
+	return c.Status(fiber.StatusCreated).JSON(result)
+}
+
+// HandleRekeyTenantSnapshotsAdmin handles POST /api/v1/admin/tenants/:id/keys/rekey
+// Enqueues rekey_snapshot jobs that re-encrypt a tenant's snapshots to its active key
+// (admin only)
+func (h *Handlers) HandleRekeyTenantSnapshotsAdmin(c *fiber.Ctx) error {
+	ctx, cancel := contextWithTimeout(30 * time.Second)
+	defer cancel()
+
+	id := c.Params("id")
+	if id == "" {
+		return sendError(c, fiber.StatusBadRequest, fmt.Errorf("id is required"), "Validation failed")
+	}
+
+	jobIDs, err := h.service.RekeyTenantSnapshots(ctx, id)
+	if err != nil && len(jobIDs) == 0 {
+		log.Printf("failed to enqueue rekey jobs: %v", err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return sendError(c, fiber.StatusNotFound, err, "Tenant key not found")
+		}
+		return sendError(c, fiber.StatusInternalServerError, err, "Failed to enqueue rekey jobs")
+	}
+
+	// Audit log
+	tenantName := id
+	if tenant, _ := h.service.GetTenant(ctx, id); tenant != nil {
+		tenantName = tenant.Name
+	}
+	details, _ := json.Marshal(map[string]any{"jobs": len(jobIDs)})
+	h.createAuditEvent(ctx, c, service.AuditActionRekeySnapshots, service.AuditTargetTenant, id, tenantName, &id, details)
+
+	resp := fiber.Map{
+		"message": "Rekey jobs enqueued successfully",
+		"job_ids": jobIDs,
+	}
+	if err != nil {
+		log.Printf("failed to enqueue some rekey jobs: %v", err)
+		resp["error"] = err.Error()
+	}
+	return c.Status(fiber.StatusAccepted).JSON(resp)
+}
+
+// HandleGetOffsiteTargetAdmin handles GET /api/v1/admin/tenants/:id/offsite-target
+// Returns a tenant's offsite target (admin only)
+func (h *Handlers) HandleGetOffsiteTargetAdmin(c *fiber.Ctx) error {
+	ctx, cancel := contextWithTimeout(5 * time.Second)
+	defer cancel()
+
+	id := c.Params("id")
+	if id == "" {
+		return sendError(c, fiber.StatusBadRequest, fmt.Errorf("id is required"), "Validation failed")
+	}
+
+	target, err := h.service.GetOffsiteTarget(ctx, id)
+	if err != nil {
+		log.Printf("failed to get offsite target: %v", err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return sendError(c, fiber.StatusNotFound, err, "Offsite target not found")
+		}
+		return sendError(c, fiber.StatusInternalServerError, err, "Failed to get offsite target")
+	}
+
+	return c.JSON(target)
+}
+
+// HandleSetOffsiteTargetAdmin handles PUT /api/v1/admin/tenants/:id/offsite-target
+// Sets the SFTP server a tenant's new snapshots are pushed to (admin only)
+func (h *Handlers) HandleSetOffsiteTargetAdmin(c *fiber.Ctx) error {
+	ctx, cancel := contextWithTimeout(5 * time.Second)
+	defer cancel()
+
+	id := c.Params("id")
+	if id == "" {
+		return sendError(c, fiber.StatusBadRequest, fmt.Errorf("id is required"), "Validation failed")
+	}
+
+	var req service.SetOffsiteTargetRequest
+	if err := c.BodyParser(&req); err != nil {
+		return sendError(c, fiber.StatusBadRequest, err, "Invalid request body")
+	}
+
+	target, err := h.service.SetOffsiteTarget(ctx, id, req)
+	if err != nil {
+		log.Printf("failed to set offsite target: %v", err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return sendError(c, fiber.StatusNotFound, err, "Tenant not found")
+		}
+		return sendError(c, fiber.StatusBadRequest, err, "Failed to set offsite target")
+	}
+
+	// Audit log (the credential is never logged)
+	tenantName := id
+	if tenant, _ := h.service.GetTenant(ctx, id); tenant != nil {
+		tenantName = tenant.Name
+	}
+	details, _ := json.Marshal(map[string]any{
+		"offsite_target_id":  target.ID,
+		"host":               target.Host,
+		"port":               target.Port,
+		"username":           target.Username,
+		"base_path":          target.BasePath,
+		"status":             target.Status,
+		"credential_rotated": req.Credential != "",
+	})
+	h.createAuditEvent(ctx, c, service.AuditActionUpdateOffsiteTarget, service.AuditTargetTenant, id, tenantName, &id, details)
+
+	return c.JSON(target)
+}
+
+// HandleDeleteOffsiteTargetAdmin handles DELETE /api/v1/admin/tenants/:id/offsite-target
+// Removes a tenant's offsite target once it holds no copies (admin only)
+func (h *Handlers) HandleDeleteOffsiteTargetAdmin(c *fiber.Ctx) error {
+	ctx, cancel := contextWithTimeout(5 * time.Second)
+	defer cancel()
+
+	id := c.Params("id")
+	if id == "" {
+		return sendError(c, fiber.StatusBadRequest, fmt.Errorf("id is required"), "Validation failed")
+	}
+
+	if err := h.service.DeleteOffsiteTarget(ctx, id); err != nil {
+		log.Printf("failed to delete offsite target: %v", err)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return sendError(c, fiber.StatusNotFound, err, "Offsite target not found")
+		case errors.Is(err, service.ErrOffsiteTargetInUse):
+			return sendError(c, fiber.StatusConflict, err, "Offsite target still holds snapshot copies")
+		}
+		return sendError(c, fiber.StatusInternalServerError, err, "Failed to delete offsite target")
+	}
+
+	// Audit log
+	tenantName := id
+	if tenant, _ := h.service.GetTenant(ctx, id); tenant != nil {
+		tenantName = tenant.Name
+	}
+	h.createAuditEvent(ctx, c, service.AuditActionDeleteOffsiteTarget, service.AuditTargetTenant, id, tenantName, &id, nil)
+
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
+
+// HandleHoldSnapshotAdmin handles POST /api/v1/admin/snapshots/:id/hold
+// Places a legal hold on a snapshot (admin only)
+func (h *Handlers) HandleHoldSnapshotAdmin(c *fiber.Ctx) error {
+	ctx, cancel := contextWithTimeout(5 * time.Second)
+	defer cancel()
+
+	snapshotID := c.Params("id")
+	if snapshotID == "" {
+		return sendError(c, fiber.StatusBadRequest, fmt.Errorf("snapshot_id is required"), "Validation failed")
+	}
+
+	var req service.HoldSnapshotRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return sendError(c, fiber.StatusBadRequest, err, "Invalid request body")
+		}
+	}
+
+	snapshot, err := h.service.HoldSnapshot(ctx, snapshotID, req)
+	if err != nil {
+		log.Printf("failed to hold snapshot: %v", err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return sendError(c, fiber.StatusNotFound, err, "Snapshot not found")
+		}
+		return sendError(c, fiber.StatusInternalServerError, err, "Failed to hold snapshot")
+	}
+
+	// Audit log
+	details, _ := json.Marshal(map[string]any{"reason": req.Reason})
+	h.createAuditEvent(ctx, c, service.AuditActionHoldSnapshot, service.AuditTargetSnapshot, snapshotID, "Snapshot "+snapshotID[:8], &snapshot.TenantID, details)
+
+	return c.JSON(snapshot)
+}
+
+// HandleReleaseSnapshotHoldAdmin handles DELETE /api/v1/admin/snapshots/:id/hold
+// Releases the legal hold on a snapshot (admin only)
+func (h *Handlers) HandleReleaseSnapshotHoldAdmin(c *fiber.Ctx) error {
+	ctx, cancel := contextWithTimeout(5 * time.Second)
+	defer cancel()
+
+	snapshotID := c.Params("id")
+	if snapshotID == "" {
+		return sendError(c, fiber.StatusBadRequest, fmt.Errorf("snapshot_id is required"), "Validation failed")
+	}
+
+	snapshot, err := h.service.ReleaseSnapshotHold(ctx, snapshotID)
+	if err != nil {
+		log.Printf("failed to release snapshot hold: %v", err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return sendError(c, fiber.StatusNotFound, err, "Snapshot not found")
+		}
+		return sendError(c, fiber.StatusInternalServerError, err, "Failed to release snapshot hold")
+	}
+
+	// Audit log
+	h.createAuditEvent(ctx, c, service.AuditActionReleaseSnapshotHold, service.AuditTargetSnapshot, snapshotID, "Snapshot "+snapshotID[:8], &snapshot.TenantID, nil)
+
+	return c.JSON(snapshot)
+}
+
+// HandleLockSnapshotAdmin handles PUT /api/v1/admin/snapshots/:id/lock
+// Locks a snapshot against deletion until a given time (admin only)
+func (h *Handlers) HandleLockSnapshotAdmin(c *fiber.Ctx) error {
+	ctx, cancel := contextWithTimeout(5 * time.Second)
+	defer cancel()
+
+	snapshotID := c.Params("id")
+	if snapshotID == "" {
+		return sendError(c, fiber.StatusBadRequest, fmt.Errorf("snapshot_id is required"), "Validation failed")
+	}
+
+	var req service.LockSnapshotRequest
+	if err := c.BodyParser(&req); err != nil {
+		return sendError(c, fiber.StatusBadRequest, err, "Invalid request body")
+	}
+
+	snapshot, err := h.service.LockSnapshot(ctx, snapshotID, req)
+	if err != nil {
+		log.Printf("failed to lock snapshot: %v", err)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return sendError(c, fiber.StatusNotFound, err, "Snapshot not found")
+		case errors.Is(err, service.ErrLockShortened):
+			return sendError(c, fiber.StatusConflict, err, "Snapshot is locked until later")
+		}
+		return sendError(c, fiber.StatusBadRequest, err, "Failed to lock snapshot")
+	}
+
+	// Audit log
+	details, _ := json.Marshal(map[string]any{"locked_until": snapshot.LockedUntil})
+	h.createAuditEvent(ctx, c, service.AuditActionLockSnapshot, service.AuditTargetSnapshot, snapshotID, "Snapshot "+snapshotID[:8], &snapshot.TenantID, details)
+
+	return c.JSON(snapshot)
+}
+
+// HandleGetLogsForSource handles GET /api/v1/admin/sources/:id/logs
+// Returns logs for a specific source (admin only)
+func (h *Handlers) HandleGetLogsForSource(c *fiber.Ctx) error {
+	ctx, cancel := contextWithTimeout(5 * time.Second)
+	defer cancel()
+
+	sourceID := c.Params("id")
+	if sourceID == "" {
+		return sendError(c, fiber.StatusBadRequest, fmt.Errorf("source_id is required"), "Validation failed")
+	}
+
+	limit := c.QueryInt("limit", 100)
+	if limit > 500 {
+		limit = 500
+	}
+
+	logs, err := h.service.GetLogsForSource(ctx, sourceID, limit)
+	if err != nil {
+		log.Printf("failed to get logs for source: %v", err)
+		return sendError(c, fiber.StatusInternalServerError, err, "Failed to get logs")
+	}
+
+	return c.JSON(fiber.Map{"logs": logs})
 }
 
 // HandleListAllLogsAdmin handles GET /api/v1/admin/logs
@@ -1639,6 +2868,7 @@ func (h *Handlers) HandleListWorkersAdmin(c *fiber.Ctx) error {
 		Capabilities    json.RawMessage `json:"capabilities"`
 		StorageBasePath string          `json:"storage_base_path"`
 		SystemMetrics   json.RawMessage `json:"system_metrics,omitempty"`
+		StatusReasons   json.RawMessage `json:"status_reasons,omitempty"`
 		LastSeenAt      *time.Time      `json:"last_seen_at,omitempty"`
 		CreatedAt       time.Time       `json:"created_at"`
 		UpdatedAt       time.Time       `json:"updated_at"`
@@ -1657,6 +2887,7 @@ func (h *Handlers) HandleListWorkersAdmin(c *fiber.Ctx) error {
 			Capabilities:    w.Capabilities,
 			StorageBasePath: w.StorageBasePath,
 			SystemMetrics:   w.SystemMetrics,
+			StatusReasons:   w.StatusReasons,
 			LastSeenAt:      w.LastSeenAt,
 			CreatedAt:       w.CreatedAt,
 			UpdatedAt:       w.UpdatedAt,
@@ -1669,6 +2900,30 @@ func (h *Handlers) HandleListWorkersAdmin(c *fiber.Ctx) error {
 	})
 }
 
+// HandleResetWorkerIdentity handles POST /api/v1/admin/workers/:id/reset-identity
+// Clears a worker's identity key so the next key it registers is accepted (admin only)
+func (h *Handlers) HandleResetWorkerIdentity(c *fiber.Ctx) error {
+	ctx, cancel := contextWithTimeout(10 * time.Second)
+	defer cancel()
+
+	workerID := c.Params("id")
+	if workerID == "" {
+		return sendError(c, fiber.StatusBadRequest, fmt.Errorf("worker id is required"), "Validation failed")
+	}
+
+	if err := h.service.ResetWorkerIdentity(ctx, workerID); err != nil {
+		log.Printf("failed to reset worker identity: %v", err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return sendError(c, fiber.StatusNotFound, err, "Worker not found")
+		}
+		return sendError(c, fiber.StatusInternalServerError, err, "Failed to reset worker identity")
+	}
+
+	h.createAuditEvent(ctx, c, service.AuditActionResetWorkerIdentity, service.AuditTargetWorker, workerID, workerID, nil, nil)
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
 // HandleGetWorkerAdmin handles GET /api/v1/admin/workers/:id
 // Returns a single worker with its status and system metrics (admin only)
 func (h *Handlers) HandleGetWorkerAdmin(c *fiber.Ctx) error {
@@ -1696,12 +2951,128 @@ func (h *Handlers) HandleGetWorkerAdmin(c *fiber.Ctx) error {
 		"capabilities":      worker.Capabilities,
 		"storage_base_path": worker.StorageBasePath,
 		"system_metrics":    worker.SystemMetrics,
+		"status_reasons":    worker.StatusReasons,
 		"last_seen_at":      worker.LastSeenAt,
 		"created_at":        worker.CreatedAt,
 		"updated_at":        worker.UpdatedAt,
 	})
 }
 
+// HandleReconcileWorkerStorage handles POST /api/v1/admin/workers/:id/reconcile
+// Enqueues a reconcile_storage job that inventories the worker's snapshot directories (admin only)
+func (h *Handlers) HandleReconcileWorkerStorage(c *fiber.Ctx) error {
+	ctx, cancel := contextWithTimeout(10 * time.Second)
+	defer cancel()
+
+	workerID := c.Params("id")
+	if workerID == "" {
+		return sendError(c, fiber.StatusBadRequest, fmt.Errorf("worker id is required"), "Validation failed")
+	}
+
+	job, err := h.service.EnqueueStorageReconcile(ctx, workerID)
+	if err != nil {
+		log.Printf("failed to enqueue reconcile job: %v", err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return sendError(c, fiber.StatusNotFound, err, "Worker not found")
+		}
+		return sendError(c, fiber.StatusInternalServerError, err, "Failed to enqueue reconcile job")
+	}
+
+	h.createAuditEvent(ctx, c, service.AuditActionReconcileStorage, service.AuditTargetWorker, workerID, workerID, nil, nil)
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message":   "Reconcile job enqueued successfully",
+		"job_id":    job.ID,
+		"worker_id": workerID,
+	})
+}
+
+// HandleListStorageReconciliations handles GET /api/v1/admin/reconciliations
+// Returns reconciliation summaries, newest first, optionally filtered by worker_id (admin only)
+func (h *Handlers) HandleListStorageReconciliations(c *fiber.Ctx) error {
+	ctx, cancel := contextWithTimeout(10 * time.Second)
+	defer cancel()
+
+	limit := c.QueryInt("limit", 50)
+	if limit > 500 {
+		limit = 500
+	}
+
+	recs, err := h.service.ListStorageReconciliations(ctx, c.Query("worker_id"), limit)
+	if err != nil {
+		log.Printf("failed to list storage reconciliations: %v", err)
+		return sendError(c, fiber.StatusInternalServerError, err, "Failed to list storage reconciliations")
+	}
+	if recs == nil {
+		recs = []*repository.StorageReconciliation{}
+	}
+
+	return c.JSON(fiber.Map{"reconciliations": recs})
+}
+
+// HandleGetStorageReconciliation handles GET /api/v1/admin/reconciliations/:id
+// Returns a reconciliation with its missing, orphaned and size mismatch lists (admin only)
+func (h *Handlers) HandleGetStorageReconciliation(c *fiber.Ctx) error {
+	ctx, cancel := contextWithTimeout(10 * time.Second)
+	defer cancel()
+
+	rec, err := h.service.GetStorageReconciliation(ctx, c.Params("id"))
+	if err != nil {
+		log.Printf("failed to get storage reconciliation: %v", err)
+		return sendError(c, fiber.StatusNotFound, err, "Reconciliation not found")
+	}
+
+	return c.JSON(rec)
+}
+
+// HandleResolveOrphans handles POST /api/v1/admin/reconciliations/:id/orphans
+// Enqueues a job on the worker that quarantines or purges orphaned snapshot directories (admin only)
+func (h *Handlers) HandleResolveOrphans(c *fiber.Ctx) error {
+	ctx, cancel := contextWithTimeout(10 * time.Second)
+	defer cancel()
+
+	reconciliationID := c.Params("id")
+
+	var req service.ResolveOrphansRequest
+	if err := c.BodyParser(&req); err != nil {
+		return sendError(c, fiber.StatusBadRequest, err, "Invalid request body")
+	}
+	if req.Action != types.ReconcileActionQuarantine && req.Action != types.ReconcileActionPurge {
+		return sendError(c, fiber.StatusBadRequest, fmt.Errorf("action must be quarantine or purge"), "Validation failed")
+	}
+
+	job, targets, err := h.service.ResolveOrphans(ctx, reconciliationID, req.Action, req.SnapshotIDs)
+	if err != nil {
+		log.Printf("failed to resolve orphans: %v", err)
+		switch {
+		case errors.Is(err, service.ErrNoOrphans):
+			return sendError(c, fiber.StatusConflict, err, "No orphans to act on")
+		case errors.Is(err, sql.ErrNoRows):
+			return sendError(c, fiber.StatusNotFound, err, "Reconciliation not found")
+		}
+		return sendError(c, fiber.StatusInternalServerError, err, "Failed to enqueue orphan job")
+	}
+
+	action := service.AuditActionQuarantineOrphans
+	if req.Action == types.ReconcileActionPurge {
+		action = service.AuditActionPurgeOrphans
+	}
+	snapshotIDs := make([]string, len(targets))
+	for i, target := range targets {
+		snapshotIDs[i] = target.SnapshotID
+	}
+	details, _ := json.Marshal(map[string]any{"reconciliation_id": reconciliationID, "snapshot_ids": snapshotIDs})
+	h.createAuditEvent(ctx, c, action, service.AuditTargetWorker, *job.TargetWorkerID, *job.TargetWorkerID, nil, details)
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message":      "Orphan job enqueued successfully",
+		"job_id":       job.ID,
+		"worker_id":    job.TargetWorkerID,
+		"action":       req.Action,
+		"snapshot_ids": snapshotIDs,
+	})
+}
+
 // computeWorkerHealth calculates the health status of a worker based on last_seen and metrics
 func computeWorkerHealth(w *repository.Worker) string {
 	// Check if worker is offline (no heartbeat in 2 minutes)
@@ -1728,5 +3099,10 @@ func computeWorkerHealth(w *repository.Worker) string {
 		}
 	}
 
+	// A degraded worker is over one of its own admission thresholds and not claiming jobs
+	if w.Status == "degraded" {
+		return "warning"
+	}
+
 	return "healthy"
 }
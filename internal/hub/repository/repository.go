@@ -11,6 +11,7 @@ import (
 	"xvault/pkg/types"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 // Repository handles database operations for the Hub
@@ -67,16 +68,19 @@ func (r *Repository) GetTenant(ctx context.Context, id string) (*Tenant, error)
 	return &tenant, nil
 }
 
-// TenantKey represents a tenant encryption key record
+// TenantKey represents a tenant encryption key record. A tenant has one active key,
+// which new snapshots are encrypted to; the keys it replaced are rotated and still
+// decrypt the snapshots encrypted to them.
 type TenantKey struct {
-	ID                  string    `json:"id"`
-	TenantID            string    `json:"tenant_id"`
-	Algorithm           string    `json:"algorithm"`
-	PublicKey           string    `json:"public_key"`
-	EncryptedPrivateKey string    `json:"encrypted_private_key"`
-	KeyStatus           string    `json:"key_status"`
-	CreatedAt           time.Time `json:"created_at"`
-	UpdatedAt           time.Time `json:"updated_at"`
+	ID                  string     `json:"id"`
+	TenantID            string     `json:"tenant_id"`
+	Algorithm           string     `json:"algorithm"`
+	PublicKey           string     `json:"public_key"`
+	EncryptedPrivateKey string     `json:"encrypted_private_key"`
+	KeyStatus           string     `json:"key_status"`
+	RotatedAt           *time.Time `json:"rotated_at,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
 }
 
 // CreateTenantKey creates a new tenant encryption key
@@ -86,11 +90,11 @@ func (r *Repository) CreateTenantKey(ctx context.Context, tenantID, algorithm, p
 
 	query := `INSERT INTO tenant_keys (id, tenant_id, algorithm, public_key, encrypted_private_key, key_status, created_at, updated_at)
 	          VALUES ($1, $2, $3, $4, $5, 'active', $6, $7)
-	          RETURNING id, tenant_id, algorithm, public_key, encrypted_private_key, key_status, created_at, updated_at`
+	          RETURNING id, tenant_id, algorithm, public_key, encrypted_private_key, key_status, rotated_at, created_at, updated_at`
 
 	var key TenantKey
 	err := r.db.QueryRowContext(ctx, query, id, tenantID, algorithm, publicKey, encryptedPrivateKey, now, now).Scan(
-		&key.ID, &key.TenantID, &key.Algorithm, &key.PublicKey, &key.EncryptedPrivateKey, &key.KeyStatus, &key.CreatedAt, &key.UpdatedAt,
+		&key.ID, &key.TenantID, &key.Algorithm, &key.PublicKey, &key.EncryptedPrivateKey, &key.KeyStatus, &key.RotatedAt, &key.CreatedAt, &key.UpdatedAt,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create tenant key: %w", err)
@@ -99,9 +103,9 @@ func (r *Repository) CreateTenantKey(ctx context.Context, tenantID, algorithm, p
 	return &key, nil
 }
 
-// GetActiveTenantKey retrieves the active key for a tenant
+// GetActiveTenantKey retrieves the key a tenant's new snapshots are encrypted to
 func (r *Repository) GetActiveTenantKey(ctx context.Context, tenantID string) (*TenantKey, error) {
-	query := `SELECT id, tenant_id, algorithm, public_key, encrypted_private_key, key_status, created_at, updated_at
+	query := `SELECT id, tenant_id, algorithm, public_key, encrypted_private_key, key_status, rotated_at, created_at, updated_at
 	          FROM tenant_keys
 	          WHERE tenant_id = $1 AND key_status = 'active'
 	          ORDER BY created_at DESC
@@ -109,7 +113,7 @@ func (r *Repository) GetActiveTenantKey(ctx context.Context, tenantID string) (*
 
 	var key TenantKey
 	err := r.db.QueryRowContext(ctx, query, tenantID).Scan(
-		&key.ID, &key.TenantID, &key.Algorithm, &key.PublicKey, &key.EncryptedPrivateKey, &key.KeyStatus, &key.CreatedAt, &key.UpdatedAt,
+		&key.ID, &key.TenantID, &key.Algorithm, &key.PublicKey, &key.EncryptedPrivateKey, &key.KeyStatus, &key.RotatedAt, &key.CreatedAt, &key.UpdatedAt,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get active tenant key: %w", err)
@@ -118,6 +122,119 @@ func (r *Repository) GetActiveTenantKey(ctx context.Context, tenantID string) (*
 	return &key, nil
 }
 
+// GetTenantKey retrieves one of a tenant's keys by ID, or by the first 16 characters
+// of its public key, which is how manifests named keys before they were tracked.
+// Returns sql.ErrNoRows when the tenant has no such key.
+func (r *Repository) GetTenantKey(ctx context.Context, tenantID, keyID string) (*TenantKey, error) {
+	query := `SELECT id, tenant_id, algorithm, public_key, encrypted_private_key, key_status, rotated_at, created_at, updated_at
+	          FROM tenant_keys
+	          WHERE tenant_id = $1 AND (id::text = $2 OR (length($2) = 16 AND left(public_key, 16) = $2))
+	          ORDER BY created_at DESC
+	          LIMIT 1`
+
+	var key TenantKey
+	err := r.db.QueryRowContext(ctx, query, tenantID, keyID).Scan(
+		&key.ID, &key.TenantID, &key.Algorithm, &key.PublicKey, &key.EncryptedPrivateKey, &key.KeyStatus, &key.RotatedAt, &key.CreatedAt, &key.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tenant key: %w", err)
+	}
+
+	return &key, nil
+}
+
+// ListTenantKeys retrieves a tenant's keys, the active one first and then newest first
+func (r *Repository) ListTenantKeys(ctx context.Context, tenantID string) ([]*TenantKey, error) {
+	query := `SELECT id, tenant_id, algorithm, public_key, encrypted_private_key, key_status, rotated_at, created_at, updated_at
+	          FROM tenant_keys
+	          WHERE tenant_id = $1
+	          ORDER BY key_status = 'active' DESC, created_at DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tenant keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []*TenantKey
+	for rows.Next() {
+		var key TenantKey
+		if err := rows.Scan(
+			&key.ID, &key.TenantID, &key.Algorithm, &key.PublicKey, &key.EncryptedPrivateKey, &key.KeyStatus, &key.RotatedAt, &key.CreatedAt, &key.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan tenant key: %w", err)
+		}
+		keys = append(keys, &key)
+	}
+
+	return keys, rows.Err()
+}
+
+// RotateTenantKey makes a new key the tenant's active key and marks the keys it
+// replaces rotated, in one transaction. The tenant row is locked meanwhile, so
+// concurrent rotations cannot leave two active keys.
+func (r *Repository) RotateTenantKey(ctx context.Context, tenantID, algorithm, publicKey, encryptedPrivateKey string) (*TenantKey, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var lockedID string
+	if err := tx.QueryRowContext(ctx, `SELECT id FROM tenants WHERE id = $1 FOR UPDATE`, tenantID).Scan(&lockedID); err != nil {
+		return nil, fmt.Errorf("failed to lock tenant: %w", err)
+	}
+
+	now := time.Now()
+	if _, err := tx.ExecContext(ctx, `UPDATE tenant_keys SET key_status = 'rotated', rotated_at = $2, updated_at = $2
+	                                   WHERE tenant_id = $1 AND key_status = 'active'`, tenantID, now); err != nil {
+		return nil, fmt.Errorf("failed to rotate tenant keys: %w", err)
+	}
+
+	query := `INSERT INTO tenant_keys (id, tenant_id, algorithm, public_key, encrypted_private_key, key_status, created_at, updated_at)
+	          VALUES ($1, $2, $3, $4, $5, 'active', $6, $6)
+	          RETURNING id, tenant_id, algorithm, public_key, encrypted_private_key, key_status, rotated_at, created_at, updated_at`
+
+	var key TenantKey
+	err = tx.QueryRowContext(ctx, query, uuid.New().String(), tenantID, algorithm, publicKey, encryptedPrivateKey, now).Scan(
+		&key.ID, &key.TenantID, &key.Algorithm, &key.PublicKey, &key.EncryptedPrivateKey, &key.KeyStatus, &key.RotatedAt, &key.CreatedAt, &key.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tenant key: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit key rotation: %w", err)
+	}
+	return &key, nil
+}
+
+// CountSnapshotsByKey counts a tenant's snapshots by the key they are encrypted to.
+// Snapshots whose key is not known are counted under "".
+func (r *Repository) CountSnapshotsByKey(ctx context.Context, tenantID string) (map[string]int, error) {
+	query := `SELECT COALESCE(encryption_key_id::text, ''), COUNT(*) FROM snapshots
+	          WHERE tenant_id = $1
+	          GROUP BY 1`
+
+	rows, err := r.db.QueryContext(ctx, query, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count snapshots by key: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var keyID string
+		var count int
+		if err := rows.Scan(&keyID, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan snapshot count: %w", err)
+		}
+		counts[keyID] = count
+	}
+
+	return counts, rows.Err()
+}
+
 // Credential represents an encrypted credential record
 type Credential struct {
 	ID         string    `json:"id"`
@@ -243,7 +360,7 @@ func (r *Repository) ListSources(ctx context.Context, tenantID string) ([]*Sourc
 // Job represents a job record
 type Job struct {
 	ID             string          `json:"id"`
-	TenantID       string          `json:"tenant_id"`
+	TenantID       string          `json:"tenant_id"` // empty for worker jobs such as reconcile_storage
 	SourceID       *string         `json:"source_id,omitempty"`
 	Type           string          `json:"type"`
 	Status         string          `json:"status"`
@@ -267,7 +384,7 @@ func (r *Repository) CreateJob(ctx context.Context, tenantID string, jobType typ
 
 	query := `INSERT INTO jobs (id, tenant_id, source_id, type, status, priority, payload, created_at, updated_at)
 	          VALUES ($1, $2, $3, $4, 'queued', $5, $6, $7, $8)
-	          RETURNING id, tenant_id, source_id, type, status, priority, target_worker_id, lease_expires_at,
+	          RETURNING id, COALESCE(tenant_id::text, ''), source_id, type, status, priority, target_worker_id, lease_expires_at,
 	                    attempt, payload, started_at, finished_at, error_code, error_message, created_at, updated_at`
 
 	var job Job
@@ -290,7 +407,7 @@ func (r *Repository) CreateJobWithTargetWorker(ctx context.Context, tenantID str
 
 	query := `INSERT INTO jobs (id, tenant_id, source_id, type, status, priority, target_worker_id, payload, created_at, updated_at)
 	          VALUES ($1, $2, $3, $4, 'queued', $5, $6, $7, $8, $9)
-	          RETURNING id, tenant_id, source_id, type, status, priority, target_worker_id, lease_expires_at,
+	          RETURNING id, COALESCE(tenant_id::text, ''), source_id, type, status, priority, target_worker_id, lease_expires_at,
 	                    attempt, payload, started_at, finished_at, error_code, error_message, created_at, updated_at`
 
 	var job Job
@@ -305,8 +422,34 @@ func (r *Repository) CreateJobWithTargetWorker(ctx context.Context, tenantID str
 	return &job, nil
 }
 
-// ClaimJob updates a job to running status and sets a lease
-func (r *Repository) ClaimJob(ctx context.Context, workerID string, leaseDuration time.Duration) (*Job, error) {
+// CreateWorkerJob creates a job that belongs to a worker rather than a tenant, such as
+// reconcile_storage. Only that worker can claim it.
+func (r *Repository) CreateWorkerJob(ctx context.Context, jobType types.JobType, workerID string, payload json.RawMessage, priority int) (*Job, error) {
+	id := uuid.New().String()
+	now := time.Now()
+
+	query := `INSERT INTO jobs (id, tenant_id, type, status, priority, target_worker_id, payload, created_at, updated_at)
+	          VALUES ($1, NULL, $2, 'queued', $3, $4, $5, $6, $7)
+	          RETURNING id, COALESCE(tenant_id::text, ''), source_id, type, status, priority, target_worker_id, lease_expires_at,
+	                    attempt, payload, started_at, finished_at, error_code, error_message, created_at, updated_at`
+
+	var job Job
+	err := r.db.QueryRowContext(ctx, query, id, string(jobType), priority, workerID, payload, now, now).Scan(
+		&job.ID, &job.TenantID, &job.SourceID, &job.Type, &job.Status, &job.Priority, &job.TargetWorkerID, &job.LeaseExpiresAt,
+		&job.Attempt, &job.Payload, &job.StartedAt, &job.FinishedAt, &job.ErrorCode, &job.ErrorMessage, &job.CreatedAt, &job.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create worker job: %w", err)
+	}
+
+	return &job, nil
+}
+
+// ClaimJob updates a job to running status and sets a lease. Jobs created for a
+// specific worker are only handed to that worker, jobs the worker released are
+// skipped until releaseBackoff has passed since the last release, and jobs on a
+// snapshot being re-encrypted wait for the rekey (see jobNotHeldByRekey).
+func (r *Repository) ClaimJob(ctx context.Context, workerID string, leaseDuration, releaseBackoff time.Duration) (*Job, error) {
 	now := time.Now()
 	leaseExpires := now.Add(leaseDuration)
 
@@ -318,17 +461,20 @@ func (r *Repository) ClaimJob(ctx context.Context, workerID string, leaseDuratio
 	              attempt = attempt + 1,
 	              updated_at = $3
 	          WHERE id = (
-	              SELECT id FROM jobs
+	              SELECT id FROM jobs c
 	              WHERE status = 'queued' AND type != 'restore'
+	                AND (target_worker_id IS NULL OR target_worker_id = $1)
+	                AND NOT ($1 = ANY(released_by) AND released_at > $4)
+	                AND ` + jobNotHeldByRekey + `
 	              ORDER BY priority DESC, created_at ASC
 	              LIMIT 1
 	              FOR UPDATE SKIP LOCKED
 	          )
-	          RETURNING id, tenant_id, source_id, type, status, priority, target_worker_id, lease_expires_at,
+	          RETURNING id, COALESCE(tenant_id::text, ''), source_id, type, status, priority, target_worker_id, lease_expires_at,
 	                    attempt, payload, started_at, finished_at, error_code, error_message, created_at, updated_at`
 
 	var job Job
-	err := r.db.QueryRowContext(ctx, query, workerID, leaseExpires, now).Scan(
+	err := r.db.QueryRowContext(ctx, query, workerID, leaseExpires, now, now.Add(-releaseBackoff)).Scan(
 		&job.ID, &job.TenantID, &job.SourceID, &job.Type, &job.Status, &job.Priority, &job.TargetWorkerID, &job.LeaseExpiresAt,
 		&job.Attempt, &job.Payload, &job.StartedAt, &job.FinishedAt, &job.ErrorCode, &job.ErrorMessage, &job.CreatedAt, &job.UpdatedAt,
 	)
@@ -343,7 +489,8 @@ func (r *Repository) ClaimJob(ctx context.Context, workerID string, leaseDuratio
 	return &job, nil
 }
 
-// ClaimRestoreJob claims the next queued restore job for a restore service
+// ClaimRestoreJob claims the next queued restore job for a restore service. Restores
+// of a snapshot being re-encrypted wait for the rekey.
 func (r *Repository) ClaimRestoreJob(ctx context.Context, serviceID string) (*Job, error) {
 	now := time.Now()
 	leaseExpires := now.Add(30 * time.Minute)
@@ -356,13 +503,14 @@ func (r *Repository) ClaimRestoreJob(ctx context.Context, serviceID string) (*Jo
 	              attempt = attempt + 1,
 	              updated_at = $3
 	          WHERE id = (
-	              SELECT id FROM jobs
+	              SELECT id FROM jobs c
 	              WHERE status = 'queued' AND type = 'restore'
+	                AND ` + jobNotHeldByRekey + `
 	              ORDER BY priority DESC, created_at ASC
 	              LIMIT 1
 	              FOR UPDATE SKIP LOCKED
 	          )
-	          RETURNING id, tenant_id, source_id, type, status, priority, target_worker_id, lease_expires_at,
+	          RETURNING id, COALESCE(tenant_id::text, ''), source_id, type, status, priority, target_worker_id, lease_expires_at,
 	                    attempt, payload, started_at, finished_at, error_code, error_message, created_at, updated_at`
 
 	var job Job
@@ -382,17 +530,18 @@ func (r *Repository) ClaimRestoreJob(ctx context.Context, serviceID string) (*Jo
 }
 
 // CompleteJob marks a job as completed or failed
-func (r *Repository) CompleteJob(ctx context.Context, jobID string, status types.JobStatus, errorMsg *string) error {
+func (r *Repository) CompleteJob(ctx context.Context, jobID string, status types.JobStatus, errorCode, errorMsg *string) error {
 	now := time.Now()
 
 	query := `UPDATE jobs
 	          SET status = $1,
 	              finished_at = $2,
-	              error_message = $3,
+	              error_code = $3,
+	              error_message = $4,
 	              updated_at = $2
-	          WHERE id = $4`
+	          WHERE id = $5`
 
-	_, err := r.db.ExecContext(ctx, query, string(status), now, errorMsg, jobID)
+	_, err := r.db.ExecContext(ctx, query, string(status), now, errorCode, errorMsg, jobID)
 	if err != nil {
 		return fmt.Errorf("failed to complete job: %w", err)
 	}
@@ -400,9 +549,35 @@ func (r *Repository) CompleteJob(ctx context.Context, jobID string, status types
 	return nil
 }
 
+// ReleaseJob puts a running job back in the queue, keeping the reason it was released
+// and the worker that released it, which ClaimJob passes over for a while. The attempt
+// count is kept.
+func (r *Repository) ReleaseJob(ctx context.Context, jobID, workerID string, errorCode, errorMsg *string) error {
+	now := time.Now()
+
+	query := `UPDATE jobs
+	          SET status = 'queued',
+	              target_worker_id = NULL,
+	              lease_expires_at = NULL,
+	              started_at = NULL,
+	              error_code = $1,
+	              error_message = $2,
+	              released_by = CASE WHEN $5 = ANY(released_by) THEN released_by ELSE array_append(released_by, $5) END,
+	              released_at = $3,
+	              updated_at = $3
+	          WHERE id = $4 AND status = 'running'`
+
+	_, err := r.db.ExecContext(ctx, query, errorCode, errorMsg, now, jobID, workerID)
+	if err != nil {
+		return fmt.Errorf("failed to release job: %w", err)
+	}
+
+	return nil
+}
+
 // GetJob retrieves a job by ID
 func (r *Repository) GetJob(ctx context.Context, jobID string) (*Job, error) {
-	query := `SELECT id, tenant_id, source_id, type, status, priority, target_worker_id, lease_expires_at,
+	query := `SELECT id, COALESCE(tenant_id::text, ''), source_id, type, status, priority, target_worker_id, lease_expires_at,
 	          attempt, payload, started_at, finished_at, error_code, error_message, created_at, updated_at
 	          FROM jobs WHERE id = $1`
 
@@ -420,34 +595,74 @@ func (r *Repository) GetJob(ctx context.Context, jobID string) (*Job, error) {
 
 // Snapshot represents a snapshot record
 type Snapshot struct {
-	ID                  string          `json:"id"`
-	TenantID            string          `json:"tenant_id"`
-	SourceID            string          `json:"source_id"`
-	JobID               string          `json:"job_id"`
-	Status              string          `json:"status"`
-	SizeBytes           int64           `json:"size_bytes"`
-	StartedAt           time.Time       `json:"started_at"`
-	FinishedAt          time.Time       `json:"finished_at"`
-	DurationMs          *int64          `json:"duration_ms,omitempty"`
-	ManifestJSON        json.RawMessage `json:"manifest_json,omitempty"`
-	EncryptionAlgorithm string          `json:"encryption_algorithm"`
-	EncryptionKeyID     *string         `json:"encryption_key_id,omitempty"`
-	EncryptionRecipient *string         `json:"encryption_recipient,omitempty"`
-	StorageBackend      string          `json:"storage_backend"`
-	WorkerID            *string         `json:"worker_id,omitempty"`
-	LocalPath           *string         `json:"local_path,omitempty"`
-	Bucket              *string         `json:"bucket,omitempty"`
-	ObjectKey           *string         `json:"object_key,omitempty"`
-	ETag                *string         `json:"etag,omitempty"`
-	DownloadToken       *string         `json:"download_token,omitempty"`
-	DownloadExpiresAt   *time.Time      `json:"download_expires_at,omitempty"`
-	DownloadURL         *string         `json:"download_url,omitempty"`
-	CreatedAt           time.Time       `json:"created_at"`
-	UpdatedAt           time.Time       `json:"updated_at"`
-}
-
-// CreateSnapshot creates a new snapshot record
-func (r *Repository) CreateSnapshot(ctx context.Context, tenantID, sourceID, jobID string, result types.SnapshotResult) (*Snapshot, error) {
+	ID                  string                `json:"id"`
+	TenantID            string                `json:"tenant_id"`
+	SourceID            string                `json:"source_id"`
+	JobID               string                `json:"job_id"`
+	Status              string                `json:"status"`
+	SizeBytes           int64                 `json:"size_bytes"`
+	StartedAt           time.Time             `json:"started_at"`
+	FinishedAt          time.Time             `json:"finished_at"`
+	DurationMs          *int64                `json:"duration_ms,omitempty"`
+	ManifestJSON        json.RawMessage       `json:"manifest_json,omitempty"`
+	EncryptionAlgorithm string                `json:"encryption_algorithm"`
+	EncryptionKeyID     *string               `json:"encryption_key_id,omitempty"`
+	EncryptionRecipient *string               `json:"encryption_recipient,omitempty"`
+	StorageBackend      string                `json:"storage_backend"`
+	WorkerID            *string               `json:"worker_id,omitempty"`
+	LocalPath           *string               `json:"local_path,omitempty"`
+	Bucket              *string               `json:"bucket,omitempty"`
+	ObjectKey           *string               `json:"object_key,omitempty"`
+	ETag                *string               `json:"etag,omitempty"`
+	DownloadToken       *string               `json:"download_token,omitempty"`
+	DownloadExpiresAt   *time.Time            `json:"download_expires_at,omitempty"`
+	DownloadURL         *string               `json:"download_url,omitempty"`
+	BackupMode          string                `json:"backup_mode"`
+	BaseSnapshotID      *string               `json:"base_snapshot_id,omitempty"`
+	Volumes             types.ArtifactVolumes `json:"volumes,omitempty"`
+	ManifestSignature   *string               `json:"manifest_signature,omitempty"`
+	ManifestSigningKey  *string               `json:"manifest_signing_key,omitempty"`
+	IntegrityStatus     *string               `json:"integrity_status,omitempty"`
+	IntegrityError      *string               `json:"integrity_error,omitempty"`
+	IntegrityCheckedAt  *time.Time            `json:"integrity_checked_at,omitempty"`
+	LastVerifiedAt      *time.Time            `json:"last_verified_at,omitempty"`
+	LegalHold           bool                  `json:"legal_hold"`
+	LegalHoldReason     *string               `json:"legal_hold_reason,omitempty"`
+	LockedUntil         *time.Time            `json:"locked_until,omitempty"`
+	TieredAt            *time.Time            `json:"tiered_at,omitempty"`
+	CreatedAt           time.Time             `json:"created_at"`
+	UpdatedAt           time.Time             `json:"updated_at"`
+}
+
+// IsLocked reports whether the snapshot is under legal hold or locked at now
+func (s *Snapshot) IsLocked(now time.Time) bool {
+	return s.LegalHold || (s.LockedUntil != nil && s.LockedUntil.After(now))
+}
+
+// Locator returns where the snapshot is stored
+func (s *Snapshot) Locator() types.SnapshotLocator {
+	deref := func(v *string) string {
+		if v == nil {
+			return ""
+		}
+		return *v
+	}
+	return types.SnapshotLocator{
+		StorageBackend: types.StorageBackend(s.StorageBackend),
+		WorkerID:       deref(s.WorkerID),
+		LocalPath:      deref(s.LocalPath),
+		Bucket:         deref(s.Bucket),
+		ObjectKey:      deref(s.ObjectKey),
+		ETag:           deref(s.ETag),
+		Volumes:        s.Volumes,
+	}
+}
+
+// CreateSnapshot creates a new snapshot record. signingKey is the worker identity key
+// that verifies result.ManifestSignature; both are stored only when signed. key is the
+// tenant key the snapshot is encrypted to, nil when its manifest names none the hub
+// knows.
+func (r *Repository) CreateSnapshot(ctx context.Context, tenantID, sourceID, jobID string, result types.SnapshotResult, signingKey string, key *TenantKey) (*Snapshot, error) {
 	// Use the snapshot ID provided by the worker to ensure logs reference the correct snapshot
 	id := result.SnapshotID
 	if id == "" {
@@ -456,28 +671,66 @@ func (r *Repository) CreateSnapshot(ctx context.Context, tenantID, sourceID, job
 	}
 	now := time.Now()
 
+	backupMode := result.BackupMode
+	if backupMode == "" {
+		backupMode = types.BackupModeFull
+	}
+	var baseSnapshotID *string
+	if result.BaseSnapshotID != "" {
+		baseSnapshotID = &result.BaseSnapshotID
+	}
+	var signature, signingKeyValue *string
+	if result.ManifestSignature != "" && signingKey != "" {
+		signature, signingKeyValue = &result.ManifestSignature, &signingKey
+	}
+	// Object storage coordinates stay NULL for local_fs snapshots
+	var bucket, objectKey, etag *string
+	if result.Locator.Bucket != "" {
+		bucket = &result.Locator.Bucket
+	}
+	if result.Locator.ObjectKey != "" {
+		objectKey = &result.Locator.ObjectKey
+	}
+	if result.Locator.ETag != "" {
+		etag = &result.Locator.ETag
+	}
+	var encryptionKeyID, encryptionRecipient *string
+	if key != nil {
+		encryptionKeyID, encryptionRecipient = &key.ID, &key.PublicKey
+	}
+
 	query := `INSERT INTO snapshots
 	          (id, tenant_id, source_id, job_id, status, size_bytes, started_at, finished_at, duration_ms,
-	           manifest_json, encryption_algorithm, storage_backend, worker_id, local_path,
-	           created_at, updated_at)
-	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+	           manifest_json, encryption_algorithm, storage_backend, worker_id, local_path, bucket, object_key, etag,
+	           backup_mode, base_snapshot_id, volumes, manifest_signature, manifest_signing_key,
+	           encryption_key_id, encryption_recipient, created_at, updated_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26)
 	          RETURNING id, tenant_id, source_id, job_id, status, size_bytes, started_at, finished_at, duration_ms,
 	                    manifest_json, encryption_algorithm, encryption_key_id, encryption_recipient,
 	                    storage_backend, worker_id, local_path, bucket, object_key, etag,
 	                    download_token, download_expires_at, download_url,
+	                    backup_mode, base_snapshot_id, volumes, manifest_signature, manifest_signing_key,
+	                    integrity_status, integrity_error, integrity_checked_at, last_verified_at,
+	                    legal_hold, legal_hold_reason, locked_until, tiered_at,
 	                    created_at, updated_at`
 
 	var snapshot Snapshot
 	err := r.db.QueryRowContext(ctx, query,
 		id, tenantID, sourceID, jobID, string(result.Status), result.SizeBytes, result.StartedAt, result.FinishedAt,
 		result.DurationMs, result.ManifestJSON, result.EncryptionAlgorithm, result.Locator.StorageBackend,
-		result.Locator.WorkerID, result.Locator.LocalPath, now, now,
+		result.Locator.WorkerID, result.Locator.LocalPath, bucket, objectKey, etag, backupMode, baseSnapshotID,
+		types.ArtifactVolumes(result.Locator.Volumes), signature, signingKeyValue,
+		encryptionKeyID, encryptionRecipient, now, now,
 	).Scan(
 		&snapshot.ID, &snapshot.TenantID, &snapshot.SourceID, &snapshot.JobID, &snapshot.Status, &snapshot.SizeBytes,
 		&snapshot.StartedAt, &snapshot.FinishedAt, &snapshot.DurationMs, &snapshot.ManifestJSON, &snapshot.EncryptionAlgorithm,
 		&snapshot.EncryptionKeyID, &snapshot.EncryptionRecipient, &snapshot.StorageBackend, &snapshot.WorkerID,
 		&snapshot.LocalPath, &snapshot.Bucket, &snapshot.ObjectKey, &snapshot.ETag,
 		&snapshot.DownloadToken, &snapshot.DownloadExpiresAt, &snapshot.DownloadURL,
+		&snapshot.BackupMode, &snapshot.BaseSnapshotID, &snapshot.Volumes,
+		&snapshot.ManifestSignature, &snapshot.ManifestSigningKey,
+		&snapshot.IntegrityStatus, &snapshot.IntegrityError, &snapshot.IntegrityCheckedAt, &snapshot.LastVerifiedAt,
+		&snapshot.LegalHold, &snapshot.LegalHoldReason, &snapshot.LockedUntil, &snapshot.TieredAt,
 		&snapshot.CreatedAt, &snapshot.UpdatedAt,
 	)
 	if err != nil {
@@ -493,6 +746,9 @@ func (r *Repository) ListSnapshots(ctx context.Context, tenantID, sourceID strin
 	          manifest_json, encryption_algorithm, encryption_key_id, encryption_recipient,
 	          storage_backend, worker_id, local_path, bucket, object_key, etag,
 	          download_token, download_expires_at, download_url,
+	          backup_mode, base_snapshot_id, volumes, manifest_signature, manifest_signing_key,
+	          integrity_status, integrity_error, integrity_checked_at, last_verified_at,
+	          legal_hold, legal_hold_reason, locked_until, tiered_at,
 	          created_at, updated_at
 	          FROM snapshots
 	          WHERE tenant_id = $1 AND source_id = $2
@@ -514,6 +770,10 @@ func (r *Repository) ListSnapshots(ctx context.Context, tenantID, sourceID strin
 			&snap.EncryptionKeyID, &snap.EncryptionRecipient, &snap.StorageBackend, &snap.WorkerID,
 			&snap.LocalPath, &snap.Bucket, &snap.ObjectKey, &snap.ETag,
 			&snap.DownloadToken, &snap.DownloadExpiresAt, &snap.DownloadURL,
+			&snap.BackupMode, &snap.BaseSnapshotID, &snap.Volumes,
+			&snap.ManifestSignature, &snap.ManifestSigningKey,
+			&snap.IntegrityStatus, &snap.IntegrityError, &snap.IntegrityCheckedAt, &snap.LastVerifiedAt,
+			&snap.LegalHold, &snap.LegalHoldReason, &snap.LockedUntil, &snap.TieredAt,
 			&snap.CreatedAt, &snap.UpdatedAt,
 		)
 		if err != nil {
@@ -531,6 +791,9 @@ func (r *Repository) GetSnapshot(ctx context.Context, id string) (*Snapshot, err
 	          manifest_json, encryption_algorithm, encryption_key_id, encryption_recipient,
 	          storage_backend, worker_id, local_path, bucket, object_key, etag,
 	          download_token, download_expires_at, download_url,
+	          backup_mode, base_snapshot_id, volumes, manifest_signature, manifest_signing_key,
+	          integrity_status, integrity_error, integrity_checked_at, last_verified_at,
+	          legal_hold, legal_hold_reason, locked_until, tiered_at,
 	          created_at, updated_at
 	          FROM snapshots WHERE id = $1`
 
@@ -541,6 +804,10 @@ func (r *Repository) GetSnapshot(ctx context.Context, id string) (*Snapshot, err
 		&snap.EncryptionKeyID, &snap.EncryptionRecipient, &snap.StorageBackend, &snap.WorkerID,
 		&snap.LocalPath, &snap.Bucket, &snap.ObjectKey, &snap.ETag,
 		&snap.DownloadToken, &snap.DownloadExpiresAt, &snap.DownloadURL,
+		&snap.BackupMode, &snap.BaseSnapshotID, &snap.Volumes,
+		&snap.ManifestSignature, &snap.ManifestSigningKey,
+		&snap.IntegrityStatus, &snap.IntegrityError, &snap.IntegrityCheckedAt, &snap.LastVerifiedAt,
+		&snap.LegalHold, &snap.LegalHoldReason, &snap.LockedUntil, &snap.TieredAt,
 		&snap.CreatedAt, &snap.UpdatedAt,
 	)
 	if err != nil {
@@ -557,51 +824,81 @@ type Worker struct {
 	Status          string          `json:"status"`
 	Capabilities    json.RawMessage `json:"capabilities"`
 	StorageBasePath string          `json:"storage_base_path"`
+	PublicKey       *string         `json:"public_key,omitempty"`
 	SystemMetrics   json.RawMessage `json:"system_metrics,omitempty"`
+	StatusReasons   json.RawMessage `json:"status_reasons,omitempty"` // why a degraded worker is not claiming jobs
 	LastSeenAt      *time.Time      `json:"last_seen_at,omitempty"`
 	CreatedAt       time.Time       `json:"created_at"`
 	UpdatedAt       time.Time       `json:"updated_at"`
 }
 
-// RegisterWorker creates or updates a worker record
-func (r *Repository) RegisterWorker(ctx context.Context, workerID, name, storageBasePath string, capabilities json.RawMessage) (*Worker, error) {
+// RegisterWorker creates or updates a worker record. The first identity key a worker
+// registers is kept: an empty publicKey leaves it as it is, and a different one is
+// refused with sql.ErrNoRows until ResetWorkerIdentity clears it.
+func (r *Repository) RegisterWorker(ctx context.Context, workerID, name, storageBasePath, publicKey string, capabilities json.RawMessage) (*Worker, error) {
 	now := time.Now()
 
+	var key *string
+	if publicKey != "" {
+		key = &publicKey
+	}
+
 	// Try to insert first, then update if exists
-	query := `INSERT INTO workers (id, name, status, capabilities, storage_base_path, last_seen_at, created_at, updated_at)
-	          VALUES ($1, $2, 'online', $3, $4, $5, $6, $7)
+	query := `INSERT INTO workers (id, name, status, capabilities, storage_base_path, public_key, last_seen_at, created_at, updated_at)
+	          VALUES ($1, $2, 'online', $3, $4, $5, $6, $7, $8)
 	          ON CONFLICT (id) DO UPDATE
 	          SET name = EXCLUDED.name,
 	              status = 'online',
+	              status_reasons = '[]',
 	              capabilities = EXCLUDED.capabilities,
 	              storage_base_path = EXCLUDED.storage_base_path,
+	              public_key = COALESCE(workers.public_key, EXCLUDED.public_key),
 	              last_seen_at = EXCLUDED.last_seen_at,
 	              updated_at = EXCLUDED.updated_at
-	          RETURNING id, name, status, capabilities, storage_base_path, last_seen_at, created_at, updated_at`
+	          WHERE workers.public_key IS NULL OR EXCLUDED.public_key IS NULL OR workers.public_key = EXCLUDED.public_key
+	          RETURNING id, name, status, capabilities, storage_base_path, public_key, last_seen_at, created_at, updated_at`
 
 	var worker Worker
-	err := r.db.QueryRowContext(ctx, query, workerID, name, capabilities, storageBasePath, now, now, now).Scan(
-		&worker.ID, &worker.Name, &worker.Status, &worker.Capabilities, &worker.StorageBasePath, &worker.LastSeenAt, &worker.CreatedAt, &worker.UpdatedAt,
+	err := r.db.QueryRowContext(ctx, query, workerID, name, capabilities, storageBasePath, key, now, now, now).Scan(
+		&worker.ID, &worker.Name, &worker.Status, &worker.Capabilities, &worker.StorageBasePath, &worker.PublicKey, &worker.LastSeenAt, &worker.CreatedAt, &worker.UpdatedAt,
 	)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
 		return nil, fmt.Errorf("failed to register worker: %w", err)
 	}
 
 	return &worker, nil
 }
 
-// UpdateWorkerHeartbeat updates the worker's last_seen timestamp and system metrics
-func (r *Repository) UpdateWorkerHeartbeat(ctx context.Context, workerID, status string, systemMetrics json.RawMessage) error {
+// ResetWorkerIdentity clears the identity key of a worker, so the next key it registers
+// is accepted. Returns sql.ErrNoRows if the worker does not exist.
+func (r *Repository) ResetWorkerIdentity(ctx context.Context, workerID string) error {
+	result, err := r.db.ExecContext(ctx, `UPDATE workers SET public_key = NULL, updated_at = $2 WHERE id = $1`, workerID, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to reset worker identity: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// UpdateWorkerHeartbeat updates the worker's last_seen timestamp, status and system
+// metrics. statusReasons replaces the reasons recorded for its status.
+func (r *Repository) UpdateWorkerHeartbeat(ctx context.Context, workerID, status string, systemMetrics, statusReasons json.RawMessage) error {
 	now := time.Now()
 
 	query := `UPDATE workers
 	          SET last_seen_at = $1,
 	              status = $2,
 	              system_metrics = COALESCE($3, system_metrics),
+	              status_reasons = $4,
 	              updated_at = $1
-	          WHERE id = $4`
+	          WHERE id = $5`
 
-	_, err := r.db.ExecContext(ctx, query, now, status, systemMetrics, workerID)
+	_, err := r.db.ExecContext(ctx, query, now, status, systemMetrics, statusReasons, workerID)
 	if err != nil {
 		return fmt.Errorf("failed to update worker heartbeat: %w", err)
 	}
@@ -611,12 +908,12 @@ func (r *Repository) UpdateWorkerHeartbeat(ctx context.Context, workerID, status
 
 // GetWorker retrieves a worker by ID
 func (r *Repository) GetWorker(ctx context.Context, workerID string) (*Worker, error) {
-	query := `SELECT id, name, status, capabilities, storage_base_path, system_metrics, last_seen_at, created_at, updated_at
+	query := `SELECT id, name, status, capabilities, storage_base_path, public_key, system_metrics, status_reasons, last_seen_at, created_at, updated_at
 	          FROM workers WHERE id = $1`
 
 	var worker Worker
 	err := r.db.QueryRowContext(ctx, query, workerID).Scan(
-		&worker.ID, &worker.Name, &worker.Status, &worker.Capabilities, &worker.StorageBasePath, &worker.SystemMetrics, &worker.LastSeenAt, &worker.CreatedAt, &worker.UpdatedAt,
+		&worker.ID, &worker.Name, &worker.Status, &worker.Capabilities, &worker.StorageBasePath, &worker.PublicKey, &worker.SystemMetrics, &worker.StatusReasons, &worker.LastSeenAt, &worker.CreatedAt, &worker.UpdatedAt,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get worker: %w", err)
@@ -627,7 +924,7 @@ func (r *Repository) GetWorker(ctx context.Context, workerID string) (*Worker, e
 
 // ListWorkers retrieves all workers
 func (r *Repository) ListWorkers(ctx context.Context) ([]*Worker, error) {
-	query := `SELECT id, name, status, capabilities, storage_base_path, system_metrics, last_seen_at, created_at, updated_at
+	query := `SELECT id, name, status, capabilities, storage_base_path, public_key, system_metrics, status_reasons, last_seen_at, created_at, updated_at
 	          FROM workers ORDER BY name ASC`
 
 	rows, err := r.db.QueryContext(ctx, query)
@@ -640,7 +937,7 @@ func (r *Repository) ListWorkers(ctx context.Context) ([]*Worker, error) {
 	for rows.Next() {
 		var worker Worker
 		if err := rows.Scan(
-			&worker.ID, &worker.Name, &worker.Status, &worker.Capabilities, &worker.StorageBasePath, &worker.SystemMetrics, &worker.LastSeenAt, &worker.CreatedAt, &worker.UpdatedAt,
+			&worker.ID, &worker.Name, &worker.Status, &worker.Capabilities, &worker.StorageBasePath, &worker.PublicKey, &worker.SystemMetrics, &worker.StatusReasons, &worker.LastSeenAt, &worker.CreatedAt, &worker.UpdatedAt,
 		); err != nil {
 			return nil, fmt.Errorf("failed to scan worker: %w", err)
 		}
@@ -660,6 +957,7 @@ type Schedule struct {
 	Timezone        string          `json:"timezone"`
 	Status          string          `json:"status"`
 	RetentionPolicy json.RawMessage `json:"retention_policy"`
+	FullEvery       int             `json:"full_every"` // incrementals between full backups, 0 = always full
 	LastRunAt       *time.Time      `json:"last_run_at,omitempty"`
 	NextRunAt       *time.Time      `json:"next_run_at,omitempty"`
 	CreatedAt       time.Time       `json:"created_at"`
@@ -668,13 +966,13 @@ type Schedule struct {
 
 // GetScheduleForSource retrieves the schedule for a specific source
 func (r *Repository) GetScheduleForSource(ctx context.Context, sourceID string) (*Schedule, error) {
-	query := `SELECT id, tenant_id, source_id, cron, interval_minutes, timezone, status, retention_policy, last_run_at, next_run_at, created_at, updated_at
+	query := `SELECT id, tenant_id, source_id, cron, interval_minutes, timezone, status, retention_policy, full_every, last_run_at, next_run_at, created_at, updated_at
 	          FROM schedules WHERE source_id = $1::uuid`
 
 	var schedule Schedule
 	err := r.db.QueryRowContext(ctx, query, sourceID).Scan(
 		&schedule.ID, &schedule.TenantID, &schedule.SourceID, &schedule.Cron, &schedule.IntervalMinutes,
-		&schedule.Timezone, &schedule.Status, &schedule.RetentionPolicy, &schedule.LastRunAt, &schedule.NextRunAt, &schedule.CreatedAt, &schedule.UpdatedAt,
+		&schedule.Timezone, &schedule.Status, &schedule.RetentionPolicy, &schedule.FullEvery, &schedule.LastRunAt, &schedule.NextRunAt, &schedule.CreatedAt, &schedule.UpdatedAt,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get schedule: %w", err)
@@ -685,7 +983,7 @@ func (r *Repository) GetScheduleForSource(ctx context.Context, sourceID string)
 
 // ListAllSchedules retrieves all schedules (for retention evaluation)
 func (r *Repository) ListAllSchedules(ctx context.Context) ([]*Schedule, error) {
-	query := `SELECT id, tenant_id, source_id, cron, interval_minutes, timezone, status, retention_policy, last_run_at, next_run_at, created_at, updated_at
+	query := `SELECT id, tenant_id, source_id, cron, interval_minutes, timezone, status, retention_policy, full_every, last_run_at, next_run_at, created_at, updated_at
 	          FROM schedules WHERE status = 'enabled'`
 
 	rows, err := r.db.QueryContext(ctx, query)
@@ -699,7 +997,7 @@ func (r *Repository) ListAllSchedules(ctx context.Context) ([]*Schedule, error)
 		var schedule Schedule
 		err := rows.Scan(
 			&schedule.ID, &schedule.TenantID, &schedule.SourceID, &schedule.Cron, &schedule.IntervalMinutes,
-			&schedule.Timezone, &schedule.Status, &schedule.RetentionPolicy, &schedule.LastRunAt, &schedule.NextRunAt, &schedule.CreatedAt, &schedule.UpdatedAt,
+			&schedule.Timezone, &schedule.Status, &schedule.RetentionPolicy, &schedule.FullEvery, &schedule.LastRunAt, &schedule.NextRunAt, &schedule.CreatedAt, &schedule.UpdatedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan schedule: %w", err)
@@ -716,6 +1014,9 @@ func (r *Repository) ListSnapshotsForRetention(ctx context.Context, tenantID, so
 	          manifest_json, encryption_algorithm, encryption_key_id, encryption_recipient,
 	          storage_backend, worker_id, local_path, bucket, object_key, etag,
 	          download_token, download_expires_at, download_url,
+	          backup_mode, base_snapshot_id, volumes, manifest_signature, manifest_signing_key,
+	          integrity_status, integrity_error, integrity_checked_at, last_verified_at,
+	          legal_hold, legal_hold_reason, locked_until, tiered_at,
 	          created_at, updated_at
 	          FROM snapshots
 	          WHERE tenant_id = $1 AND source_id = $2 AND status = 'completed'
@@ -736,6 +1037,10 @@ func (r *Repository) ListSnapshotsForRetention(ctx context.Context, tenantID, so
 			&snap.EncryptionKeyID, &snap.EncryptionRecipient, &snap.StorageBackend, &snap.WorkerID,
 			&snap.LocalPath, &snap.Bucket, &snap.ObjectKey, &snap.ETag,
 			&snap.DownloadToken, &snap.DownloadExpiresAt, &snap.DownloadURL,
+			&snap.BackupMode, &snap.BaseSnapshotID, &snap.Volumes,
+			&snap.ManifestSignature, &snap.ManifestSigningKey,
+			&snap.IntegrityStatus, &snap.IntegrityError, &snap.IntegrityCheckedAt, &snap.LastVerifiedAt,
+			&snap.LegalHold, &snap.LegalHoldReason, &snap.LockedUntil, &snap.TieredAt,
 			&snap.CreatedAt, &snap.UpdatedAt,
 		)
 		if err != nil {
@@ -747,6 +1052,34 @@ func (r *Repository) ListSnapshotsForRetention(ctx context.Context, tenantID, so
 	return snapshots, nil
 }
 
+// GetBackupChainState returns the most recent completed snapshot of a source and the
+// number of completed incremental snapshots taken since the last full one.
+// latest is nil when the source has no completed snapshot.
+func (r *Repository) GetBackupChainState(ctx context.Context, sourceID string) (latest *Snapshot, incrementalsSinceFull int, err error) {
+	query := `SELECT id, backup_mode, created_at,
+	                 (SELECT COUNT(*) FROM snapshots i
+	                  WHERE i.source_id = $1 AND i.status = 'completed' AND i.backup_mode = 'incremental'
+	                    AND i.created_at > COALESCE(
+	                        (SELECT MAX(f.created_at) FROM snapshots f
+	                         WHERE f.source_id = $1 AND f.status = 'completed' AND f.backup_mode = 'full'),
+	                        '-infinity'::timestamp))
+	          FROM snapshots
+	          WHERE source_id = $1 AND status = 'completed'
+	          ORDER BY created_at DESC
+	          LIMIT 1`
+
+	var snap Snapshot
+	err = r.db.QueryRowContext(ctx, query, sourceID).Scan(&snap.ID, &snap.BackupMode, &snap.CreatedAt, &incrementalsSinceFull)
+	if err == sql.ErrNoRows {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get backup chain state: %w", err)
+	}
+
+	return &snap, incrementalsSinceFull, nil
+}
+
 // DeleteSnapshot removes a snapshot record from the database
 func (r *Repository) DeleteSnapshot(ctx context.Context, snapshotID string) error {
 	query := `DELETE FROM snapshots WHERE id = $1`
@@ -765,18 +1098,18 @@ func (r *Repository) DeleteSnapshot(ctx context.Context, snapshotID string) erro
 }
 
 // CreateSchedule creates a new schedule for a source
-func (r *Repository) CreateSchedule(ctx context.Context, tenantID, sourceID string, cron *string, intervalMinutes *int, timezone string, retentionPolicy json.RawMessage) (*Schedule, error) {
+func (r *Repository) CreateSchedule(ctx context.Context, tenantID, sourceID string, cron *string, intervalMinutes *int, timezone string, retentionPolicy json.RawMessage, fullEvery int) (*Schedule, error) {
 	id := uuid.New().String()
 	now := time.Now()
 
-	query := `INSERT INTO schedules (id, tenant_id, source_id, cron, interval_minutes, timezone, status, retention_policy, created_at, updated_at)
-	          VALUES ($1, $2, $3, $4, $5, $6, 'enabled', $7, $8, $9)
-	          RETURNING id, tenant_id, source_id, cron, interval_minutes, timezone, status, retention_policy, created_at, updated_at`
+	query := `INSERT INTO schedules (id, tenant_id, source_id, cron, interval_minutes, timezone, status, retention_policy, full_every, created_at, updated_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, 'enabled', $7, $8, $9, $10)
+	          RETURNING id, tenant_id, source_id, cron, interval_minutes, timezone, status, retention_policy, full_every, created_at, updated_at`
 
 	var schedule Schedule
-	err := r.db.QueryRowContext(ctx, query, id, tenantID, sourceID, cron, intervalMinutes, timezone, retentionPolicy, now, now).Scan(
+	err := r.db.QueryRowContext(ctx, query, id, tenantID, sourceID, cron, intervalMinutes, timezone, retentionPolicy, fullEvery, now, now).Scan(
 		&schedule.ID, &schedule.TenantID, &schedule.SourceID, &schedule.Cron, &schedule.IntervalMinutes,
-		&schedule.Timezone, &schedule.Status, &schedule.RetentionPolicy, &schedule.CreatedAt, &schedule.UpdatedAt,
+		&schedule.Timezone, &schedule.Status, &schedule.RetentionPolicy, &schedule.FullEvery, &schedule.CreatedAt, &schedule.UpdatedAt,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create schedule: %w", err)
@@ -786,18 +1119,18 @@ func (r *Repository) CreateSchedule(ctx context.Context, tenantID, sourceID stri
 }
 
 // UpdateSchedule updates an existing schedule
-func (r *Repository) UpdateSchedule(ctx context.Context, scheduleID string, cron *string, intervalMinutes *int, timezone string, status string, retentionPolicy json.RawMessage) (*Schedule, error) {
+func (r *Repository) UpdateSchedule(ctx context.Context, scheduleID string, cron *string, intervalMinutes *int, timezone string, status string, retentionPolicy json.RawMessage, fullEvery int) (*Schedule, error) {
 	now := time.Now()
 
 	query := `UPDATE schedules
-	          SET cron = $2, interval_minutes = $3, timezone = $4, status = $5, retention_policy = $6, updated_at = $7
+	          SET cron = $2, interval_minutes = $3, timezone = $4, status = $5, retention_policy = $6, full_every = $8, updated_at = $7
 	          WHERE id = $1::uuid
-	          RETURNING id, tenant_id, source_id, cron, interval_minutes, timezone, status, retention_policy, last_run_at, next_run_at, created_at, updated_at`
+	          RETURNING id, tenant_id, source_id, cron, interval_minutes, timezone, status, retention_policy, full_every, last_run_at, next_run_at, created_at, updated_at`
 
 	var schedule Schedule
-	err := r.db.QueryRowContext(ctx, query, scheduleID, cron, intervalMinutes, timezone, status, retentionPolicy, now).Scan(
+	err := r.db.QueryRowContext(ctx, query, scheduleID, cron, intervalMinutes, timezone, status, retentionPolicy, now, fullEvery).Scan(
 		&schedule.ID, &schedule.TenantID, &schedule.SourceID, &schedule.Cron, &schedule.IntervalMinutes,
-		&schedule.Timezone, &schedule.Status, &schedule.RetentionPolicy, &schedule.LastRunAt, &schedule.NextRunAt, &schedule.CreatedAt, &schedule.UpdatedAt,
+		&schedule.Timezone, &schedule.Status, &schedule.RetentionPolicy, &schedule.FullEvery, &schedule.LastRunAt, &schedule.NextRunAt, &schedule.CreatedAt, &schedule.UpdatedAt,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to update schedule: %w", err)
@@ -813,12 +1146,12 @@ func (r *Repository) UpdateScheduleRetention(ctx context.Context, scheduleID str
 	query := `UPDATE schedules
 	          SET retention_policy = $2, updated_at = $3
 	          WHERE id = $1::uuid
-	          RETURNING id, tenant_id, source_id, cron, interval_minutes, timezone, status, retention_policy, last_run_at, next_run_at, created_at, updated_at`
+	          RETURNING id, tenant_id, source_id, cron, interval_minutes, timezone, status, retention_policy, full_every, last_run_at, next_run_at, created_at, updated_at`
 
 	var schedule Schedule
 	err := r.db.QueryRowContext(ctx, query, scheduleID, retentionPolicy, now).Scan(
 		&schedule.ID, &schedule.TenantID, &schedule.SourceID, &schedule.Cron, &schedule.IntervalMinutes,
-		&schedule.Timezone, &schedule.Status, &schedule.RetentionPolicy, &schedule.LastRunAt, &schedule.NextRunAt, &schedule.CreatedAt, &schedule.UpdatedAt,
+		&schedule.Timezone, &schedule.Status, &schedule.RetentionPolicy, &schedule.FullEvery, &schedule.LastRunAt, &schedule.NextRunAt, &schedule.CreatedAt, &schedule.UpdatedAt,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to update schedule retention policy: %w", err)
@@ -829,7 +1162,7 @@ func (r *Repository) UpdateScheduleRetention(ctx context.Context, scheduleID str
 
 // ListSchedulesByTenant retrieves all schedules for a specific tenant
 func (r *Repository) ListSchedulesByTenant(ctx context.Context, tenantID string) ([]*Schedule, error) {
-	query := `SELECT id, tenant_id, source_id, cron, interval_minutes, timezone, status, retention_policy, last_run_at, next_run_at, created_at, updated_at
+	query := `SELECT id, tenant_id, source_id, cron, interval_minutes, timezone, status, retention_policy, full_every, last_run_at, next_run_at, created_at, updated_at
 	          FROM schedules WHERE tenant_id = $1 ORDER BY created_at DESC`
 
 	rows, err := r.db.QueryContext(ctx, query, tenantID)
@@ -843,7 +1176,7 @@ func (r *Repository) ListSchedulesByTenant(ctx context.Context, tenantID string)
 		var schedule Schedule
 		err := rows.Scan(
 			&schedule.ID, &schedule.TenantID, &schedule.SourceID, &schedule.Cron, &schedule.IntervalMinutes,
-			&schedule.Timezone, &schedule.Status, &schedule.RetentionPolicy, &schedule.LastRunAt, &schedule.NextRunAt, &schedule.CreatedAt, &schedule.UpdatedAt,
+			&schedule.Timezone, &schedule.Status, &schedule.RetentionPolicy, &schedule.FullEvery, &schedule.LastRunAt, &schedule.NextRunAt, &schedule.CreatedAt, &schedule.UpdatedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan schedule: %w", err)
@@ -856,13 +1189,13 @@ func (r *Repository) ListSchedulesByTenant(ctx context.Context, tenantID string)
 
 // GetSchedule retrieves a schedule by ID
 func (r *Repository) GetSchedule(ctx context.Context, scheduleID string) (*Schedule, error) {
-	query := `SELECT id, tenant_id, source_id, cron, interval_minutes, timezone, status, retention_policy, last_run_at, next_run_at, created_at, updated_at
+	query := `SELECT id, tenant_id, source_id, cron, interval_minutes, timezone, status, retention_policy, full_every, last_run_at, next_run_at, created_at, updated_at
 	          FROM schedules WHERE id = $1::uuid`
 
 	var schedule Schedule
 	err := r.db.QueryRowContext(ctx, query, scheduleID).Scan(
 		&schedule.ID, &schedule.TenantID, &schedule.SourceID, &schedule.Cron, &schedule.IntervalMinutes,
-		&schedule.Timezone, &schedule.Status, &schedule.RetentionPolicy, &schedule.LastRunAt, &schedule.NextRunAt, &schedule.CreatedAt, &schedule.UpdatedAt,
+		&schedule.Timezone, &schedule.Status, &schedule.RetentionPolicy, &schedule.FullEvery, &schedule.LastRunAt, &schedule.NextRunAt, &schedule.CreatedAt, &schedule.UpdatedAt,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get schedule: %w", err)
@@ -944,16 +1277,23 @@ func (r *Repository) UpsertSetting(ctx context.Context, key, value, description
 	return &setting, nil
 }
 
-// UpdateSnapshotDownloadInfo updates the download tracking information for a snapshot
-func (r *Repository) UpdateSnapshotDownloadInfo(ctx context.Context, snapshotID, downloadToken, downloadURL string, expiresAt time.Time) error {
+// UpdateSnapshotDownloadInfo updates the download tracking information for a snapshot,
+// with how the restore authenticated the manifest ("" when not reported)
+func (r *Repository) UpdateSnapshotDownloadInfo(ctx context.Context, snapshotID, downloadToken, downloadURL string, expiresAt time.Time, manifestVerification string) error {
+	var verification *string
+	if manifestVerification != "" {
+		verification = &manifestVerification
+	}
+
 	query := `UPDATE snapshots
 	          SET download_token = $2,
 	              download_expires_at = $3,
 	              download_url = $4,
-	              updated_at = $5
+	              download_manifest_verification = $5,
+	              updated_at = $6
 	          WHERE id = $1`
 
-	_, err := r.db.ExecContext(ctx, query, snapshotID, downloadToken, expiresAt, downloadURL, time.Now())
+	_, err := r.db.ExecContext(ctx, query, snapshotID, downloadToken, expiresAt, downloadURL, verification, time.Now())
 	if err != nil {
 		return fmt.Errorf("failed to update snapshot download info: %w", err)
 	}
@@ -961,6 +1301,99 @@ func (r *Repository) UpdateSnapshotDownloadInfo(ctx context.Context, snapshotID,
 	return nil
 }
 
+// UpdateSnapshotIntegrity records the result of an integrity check of a snapshot's files
+func (r *Repository) UpdateSnapshotIntegrity(ctx context.Context, snapshotID, status, checkError string) error {
+	var errorValue *string
+	if checkError != "" {
+		errorValue = &checkError
+	}
+
+	query := `UPDATE snapshots
+	          SET integrity_status = $2,
+	              integrity_error = $3,
+	              integrity_checked_at = $4,
+	              updated_at = $4
+	          WHERE id = $1`
+
+	_, err := r.db.ExecContext(ctx, query, snapshotID, status, errorValue, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to update snapshot integrity: %w", err)
+	}
+
+	return nil
+}
+
+// SetSnapshotLegalHold places or releases a legal hold on a snapshot. Returns
+// sql.ErrNoRows when the snapshot does not exist.
+func (r *Repository) SetSnapshotLegalHold(ctx context.Context, snapshotID string, hold bool, reason *string) error {
+	query := `UPDATE snapshots
+	          SET legal_hold = $2,
+	              legal_hold_reason = $3,
+	              updated_at = $4
+	          WHERE id = $1`
+
+	result, err := r.db.ExecContext(ctx, query, snapshotID, hold, reason, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to set snapshot legal hold: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ExtendSnapshotLock sets the time before which a snapshot cannot be deleted. A lock
+// is never shortened: returns sql.ErrNoRows when the snapshot does not exist or is
+// already locked until later.
+func (r *Repository) ExtendSnapshotLock(ctx context.Context, snapshotID string, lockedUntil time.Time) error {
+	query := `UPDATE snapshots
+	          SET locked_until = $2,
+	              updated_at = $3
+	          WHERE id = $1 AND (locked_until IS NULL OR locked_until <= $2 OR locked_until <= $3)`
+
+	result, err := r.db.ExecContext(ctx, query, snapshotID, lockedUntil, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to extend snapshot lock: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// CountLockedSnapshots counts the snapshots of a tenant, or of one of its sources when
+// sourceID is set, that are under legal hold or locked at now
+func (r *Repository) CountLockedSnapshots(ctx context.Context, tenantID, sourceID string, now time.Time) (int, error) {
+	query := `SELECT COUNT(*) FROM snapshots
+	          WHERE tenant_id = $1::uuid AND ($2 = '' OR source_id::text = $2)
+	            AND (legal_hold OR locked_until > $3)`
+
+	var count int
+	if err := r.db.QueryRowContext(ctx, query, tenantID, sourceID, now).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count locked snapshots: %w", err)
+	}
+	return count, nil
+}
+
+// CancelQueuedDeleteJobs cancels the delete_snapshot jobs of a snapshot, its replicas
+// included, that no worker has claimed yet
+func (r *Repository) CancelQueuedDeleteJobs(ctx context.Context, snapshotID string) (int64, error) {
+	query := `UPDATE jobs
+	          SET status = 'canceled',
+	              finished_at = $2,
+	              error_message = 'snapshot is locked',
+	              updated_at = $2
+	          WHERE type = 'delete_snapshot' AND status = 'queued'
+	            AND payload->>'delete_snapshot_id' = $1`
+
+	result, err := r.db.ExecContext(ctx, query, snapshotID, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to cancel delete jobs: %w", err)
+	}
+	rows, _ := result.RowsAffected()
+	return rows, nil
+}
+
 // GetSnapshotByDownloadToken retrieves a snapshot by its download token
 func (r *Repository) GetSnapshotByDownloadToken(ctx context.Context, token string) (*Snapshot, error) {
 	query := `SELECT id, tenant_id, source_id, job_id, status, size_bytes, started_at, finished_at, duration_ms,
@@ -1434,11 +1867,99 @@ func (r *Repository) DeleteCredential(ctx context.Context, credentialID string)
 	return nil
 }
 
+// ==================== KEK ENVELOPE MIGRATION ====================
+
+// EncryptedSecret is a value sealed under the platform KEK: a tenant private key or a
+// credential ciphertext
+type EncryptedSecret struct {
+	ID         string
+	Ciphertext string
+}
+
+// legacySecretsFilter matches ciphertexts written before the versioned format of
+// crypto.EncryptForStorage, which all start with "v1:"
+const legacySecretsFilter = `NOT LIKE 'v1:%'`
+
+// ListLegacyTenantKeySecrets returns up to limit tenant private keys in the legacy KEK
+// format with an ID after afterID, in ID order
+func (r *Repository) ListLegacyTenantKeySecrets(ctx context.Context, afterID string, limit int) ([]EncryptedSecret, error) {
+	query := `SELECT id, encrypted_private_key FROM tenant_keys
+	          WHERE encrypted_private_key ` + legacySecretsFilter + ` AND id > $1
+	          ORDER BY id LIMIT $2`
+	return r.listEncryptedSecrets(ctx, query, afterID, limit)
+}
+
+// ListLegacyCredentialSecrets returns up to limit credentials in the legacy KEK format
+// with an ID after afterID, in ID order
+func (r *Repository) ListLegacyCredentialSecrets(ctx context.Context, afterID string, limit int) ([]EncryptedSecret, error) {
+	query := `SELECT id, ciphertext FROM credentials
+	          WHERE ciphertext ` + legacySecretsFilter + ` AND id > $1
+	          ORDER BY id LIMIT $2`
+	return r.listEncryptedSecrets(ctx, query, afterID, limit)
+}
+
+func (r *Repository) listEncryptedSecrets(ctx context.Context, query, afterID string, limit int) ([]EncryptedSecret, error) {
+	rows, err := r.db.QueryContext(ctx, query, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list legacy secrets: %w", err)
+	}
+	defer rows.Close()
+
+	var secrets []EncryptedSecret
+	for rows.Next() {
+		var secret EncryptedSecret
+		if err := rows.Scan(&secret.ID, &secret.Ciphertext); err != nil {
+			return nil, fmt.Errorf("failed to scan legacy secret: %w", err)
+		}
+		secrets = append(secrets, secret)
+	}
+	return secrets, rows.Err()
+}
+
+// ReplaceTenantKeySecret swaps a tenant private key's ciphertext, unless it changed
+// since it was read. Returns whether it was replaced.
+func (r *Repository) ReplaceTenantKeySecret(ctx context.Context, id, oldCiphertext, newCiphertext string) (bool, error) {
+	query := `UPDATE tenant_keys SET encrypted_private_key = $1, updated_at = $2
+	          WHERE id = $3 AND encrypted_private_key = $4`
+	return r.replaceEncryptedSecret(ctx, query, id, oldCiphertext, newCiphertext)
+}
+
+// ReplaceCredentialSecret swaps a credential's ciphertext, unless it changed since it
+// was read. Returns whether it was replaced.
+func (r *Repository) ReplaceCredentialSecret(ctx context.Context, id, oldCiphertext, newCiphertext string) (bool, error) {
+	query := `UPDATE credentials SET ciphertext = $1, updated_at = $2
+	          WHERE id = $3 AND ciphertext = $4`
+	return r.replaceEncryptedSecret(ctx, query, id, oldCiphertext, newCiphertext)
+}
+
+func (r *Repository) replaceEncryptedSecret(ctx context.Context, query, id, oldCiphertext, newCiphertext string) (bool, error) {
+	result, err := r.db.ExecContext(ctx, query, newCiphertext, time.Now(), id, oldCiphertext)
+	if err != nil {
+		return false, fmt.Errorf("failed to replace secret: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to replace secret: %w", err)
+	}
+	return rows > 0, nil
+}
+
+// CountLegacySecrets counts the tenant private keys and credentials still in the
+// legacy KEK format
+func (r *Repository) CountLegacySecrets(ctx context.Context) (tenantKeys, credentials int, err error) {
+	query := `SELECT (SELECT COUNT(*) FROM tenant_keys WHERE encrypted_private_key ` + legacySecretsFilter + `),
+	                 (SELECT COUNT(*) FROM credentials WHERE ciphertext ` + legacySecretsFilter + `)`
+	if err := r.db.QueryRowContext(ctx, query).Scan(&tenantKeys, &credentials); err != nil {
+		return 0, 0, fmt.Errorf("failed to count legacy secrets: %w", err)
+	}
+	return tenantKeys, credentials, nil
+}
+
 // ========== Admin Schedule Management ==========
 
 // ListAllSchedulesAdmin retrieves all schedules across all tenants (admin only)
 func (r *Repository) ListAllSchedulesAdmin(ctx context.Context) ([]*Schedule, error) {
-	query := `SELECT id, tenant_id, source_id, cron, interval_minutes, timezone, status, retention_policy, last_run_at, next_run_at, created_at, updated_at
+	query := `SELECT id, tenant_id, source_id, cron, interval_minutes, timezone, status, retention_policy, full_every, last_run_at, next_run_at, created_at, updated_at
 	          FROM schedules ORDER BY created_at DESC`
 
 	rows, err := r.db.QueryContext(ctx, query)
@@ -1452,7 +1973,7 @@ func (r *Repository) ListAllSchedulesAdmin(ctx context.Context) ([]*Schedule, er
 		var schedule Schedule
 		err := rows.Scan(
 			&schedule.ID, &schedule.TenantID, &schedule.SourceID, &schedule.Cron, &schedule.IntervalMinutes,
-			&schedule.Timezone, &schedule.Status, &schedule.RetentionPolicy, &schedule.LastRunAt, &schedule.NextRunAt, &schedule.CreatedAt, &schedule.UpdatedAt,
+			&schedule.Timezone, &schedule.Status, &schedule.RetentionPolicy, &schedule.FullEvery, &schedule.LastRunAt, &schedule.NextRunAt, &schedule.CreatedAt, &schedule.UpdatedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan schedule: %w", err)
@@ -1477,7 +1998,7 @@ func (r *Repository) DeleteSchedule(ctx context.Context, scheduleID string) erro
 
 // GetDueSchedules retrieves all enabled schedules that are due to run
 func (r *Repository) GetDueSchedules(ctx context.Context, now time.Time) ([]*Schedule, error) {
-	query := `SELECT id, tenant_id, source_id, cron, interval_minutes, timezone, status, retention_policy, last_run_at, next_run_at, created_at, updated_at
+	query := `SELECT id, tenant_id, source_id, cron, interval_minutes, timezone, status, retention_policy, full_every, last_run_at, next_run_at, created_at, updated_at
 	          FROM schedules 
 	          WHERE status = 'enabled' 
 	          AND (next_run_at IS NULL OR next_run_at <= $1)
@@ -1494,7 +2015,7 @@ func (r *Repository) GetDueSchedules(ctx context.Context, now time.Time) ([]*Sch
 		var schedule Schedule
 		err := rows.Scan(
 			&schedule.ID, &schedule.TenantID, &schedule.SourceID, &schedule.Cron, &schedule.IntervalMinutes,
-			&schedule.Timezone, &schedule.Status, &schedule.RetentionPolicy, &schedule.LastRunAt, &schedule.NextRunAt, &schedule.CreatedAt, &schedule.UpdatedAt,
+			&schedule.Timezone, &schedule.Status, &schedule.RetentionPolicy, &schedule.FullEvery, &schedule.LastRunAt, &schedule.NextRunAt, &schedule.CreatedAt, &schedule.UpdatedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan schedule: %w", err)
@@ -1536,8 +2057,18 @@ type AdminSnapshot struct {
 	DownloadToken     *string    `json:"download_token,omitempty"`
 	DownloadExpiresAt *time.Time `json:"download_expires_at,omitempty"`
 	DownloadURL       *string    `json:"download_url,omitempty"`
+	BackupMode        *string    `json:"backup_mode,omitempty"`
+	IntegrityStatus   *string    `json:"integrity_status,omitempty"`
+	IntegrityError    *string    `json:"integrity_error,omitempty"`
+	LastVerifiedAt    *time.Time `json:"last_verified_at,omitempty"`
+	LegalHold         bool       `json:"legal_hold"`
+	LockedUntil       *time.Time `json:"locked_until,omitempty"`
 	CreatedAt         time.Time  `json:"created_at"`
 	UpdatedAt         time.Time  `json:"updated_at"`
+
+	// DownloadManifestVerification is how the restore behind the download authenticated
+	// the manifest: "verified" or "unsigned"
+	DownloadManifestVerification *string `json:"download_manifest_verification,omitempty"`
 }
 
 // ListAllSnapshotsAdmin retrieves all snapshots across all tenants with source and tenant info (admin only)
@@ -1550,6 +2081,7 @@ func (r *Repository) ListAllSnapshotsAdmin(ctx context.Context, limit int) ([]*A
 	          s.id, s.tenant_id, t.name as tenant_name, s.source_id, src.name as source_name, src.type as source_type,
 	          s.job_id, s.status, s.size_bytes, s.started_at, s.finished_at, s.duration_ms,
 	          s.storage_backend, s.worker_id, s.download_token, s.download_expires_at, s.download_url,
+	          s.download_manifest_verification, s.backup_mode, s.integrity_status, s.integrity_error, s.last_verified_at, s.legal_hold, s.locked_until,
 	          s.created_at, s.updated_at
 	          FROM snapshots s
 	          LEFT JOIN tenants t ON s.tenant_id = t.id
@@ -1571,6 +2103,8 @@ func (r *Repository) ListAllSnapshotsAdmin(ctx context.Context, limit int) ([]*A
 			&snap.ID, &snap.TenantID, &tenantName, &snap.SourceID, &sourceName, &sourceType,
 			&snap.JobID, &snap.Status, &snap.SizeBytes, &snap.StartedAt, &snap.FinishedAt, &snap.DurationMs,
 			&snap.StorageBackend, &snap.WorkerID, &snap.DownloadToken, &snap.DownloadExpiresAt, &snap.DownloadURL,
+			&snap.DownloadManifestVerification, &snap.BackupMode, &snap.IntegrityStatus, &snap.IntegrityError, &snap.LastVerifiedAt,
+			&snap.LegalHold, &snap.LockedUntil,
 			&snap.CreatedAt, &snap.UpdatedAt,
 		)
 		if err != nil {
@@ -1599,6 +2133,7 @@ func (r *Repository) ListAllSnapshotsAndJobsAdmin(ctx context.Context, limit int
 			s.id, s.tenant_id, t.name as tenant_name, s.source_id, src.name as source_name, src.type::text as source_type,
 			s.job_id, s.status::text, s.size_bytes, s.started_at, s.finished_at, s.duration_ms,
 			s.storage_backend::text, s.worker_id, s.download_token, s.download_expires_at, s.download_url,
+			s.download_manifest_verification, s.backup_mode, s.integrity_status, s.integrity_error, s.last_verified_at, s.legal_hold, s.locked_until,
 			s.created_at, s.updated_at
 		FROM snapshots s
 		LEFT JOIN tenants t ON s.tenant_id = t.id
@@ -1625,6 +2160,13 @@ func (r *Repository) ListAllSnapshotsAndJobsAdmin(ctx context.Context, limit int
 			NULL::text as download_token,
 			NULL::timestamp as download_expires_at,
 			NULL::text as download_url,
+			NULL::text as download_manifest_verification,
+			NULL::text as backup_mode,
+			NULL::text as integrity_status,
+			NULL::text as integrity_error,
+			NULL::timestamptz as last_verified_at,
+			false as legal_hold,
+			NULL::timestamp as locked_until,
 			j.created_at,
 			j.updated_at
 		FROM jobs j
@@ -1653,6 +2195,8 @@ func (r *Repository) ListAllSnapshotsAndJobsAdmin(ctx context.Context, limit int
 			&snap.ID, &snap.TenantID, &tenantName, &sourceID, &sourceName, &sourceType,
 			&snap.JobID, &snap.Status, &snap.SizeBytes, &snap.StartedAt, &snap.FinishedAt, &snap.DurationMs,
 			&storageBackend, &snap.WorkerID, &snap.DownloadToken, &snap.DownloadExpiresAt, &snap.DownloadURL,
+			&snap.DownloadManifestVerification, &snap.BackupMode, &snap.IntegrityStatus, &snap.IntegrityError, &snap.LastVerifiedAt,
+			&snap.LegalHold, &snap.LockedUntil,
 			&snap.CreatedAt, &snap.UpdatedAt,
 		)
 		if err != nil {
@@ -2168,3 +2712,1451 @@ func (r *Repository) ListAuditEventsAdmin(ctx context.Context, params ListAuditE
 		Total:  total,
 	}, nil
 }
+
+// ==================== STORAGE RECONCILIATION ====================
+
+// StoredSnapshot is the part of a snapshot record reconciliation compares with a
+// worker's disk
+type StoredSnapshot struct {
+	ID          string
+	TenantID    string
+	SourceID    string
+	LocalPath   string
+	SizeBytes   int64
+	StorageMode types.StorageMode
+	ReplicaID   string // set when the worker holds a replica of the snapshot
+}
+
+// ListLocalSnapshotsForWorker lists the snapshots stored on a worker's local storage,
+// including the completed replicas it holds
+func (r *Repository) ListLocalSnapshotsForWorker(ctx context.Context, workerID string) ([]*StoredSnapshot, error) {
+	query := `SELECT id, tenant_id, source_id, COALESCE(local_path, ''), size_bytes,
+	          COALESCE(manifest_json->>'storage_mode', 'artifact'), ''
+	          FROM snapshots
+	          WHERE worker_id = $1 AND storage_backend = 'local_fs'
+	          UNION ALL
+	          SELECT s.id, s.tenant_id, s.source_id, COALESCE(sr.local_path, ''), s.size_bytes,
+	          COALESCE(s.manifest_json->>'storage_mode', 'artifact'), sr.id::text
+	          FROM snapshot_replicas sr JOIN snapshots s ON s.id = sr.snapshot_id
+	          WHERE sr.worker_id = $1 AND sr.storage_backend = 'local_fs' AND sr.status = 'completed'`
+
+	rows, err := r.db.QueryContext(ctx, query, workerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list worker snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	var snapshots []*StoredSnapshot
+	for rows.Next() {
+		var snap StoredSnapshot
+		if err := rows.Scan(&snap.ID, &snap.TenantID, &snap.SourceID, &snap.LocalPath, &snap.SizeBytes, &snap.StorageMode, &snap.ReplicaID); err != nil {
+			return nil, fmt.Errorf("failed to scan worker snapshot: %w", err)
+		}
+		snapshots = append(snapshots, &snap)
+	}
+
+	return snapshots, rows.Err()
+}
+
+// RecordedSnapshotIDs returns which of ids have a snapshot record, on any worker or
+// backend. ids must be valid UUIDs, with or without dashes; the result is keyed by
+// the ID as given.
+func (r *Repository) RecordedSnapshotIDs(ctx context.Context, ids []string) (map[string]bool, error) {
+	recorded := make(map[string]bool)
+	if len(ids) == 0 {
+		return recorded, nil
+	}
+
+	query := `SELECT input FROM unnest($1::text[]) AS input
+	          WHERE EXISTS (SELECT 1 FROM snapshots WHERE id = input::uuid)`
+
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up snapshot records: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan snapshot id: %w", err)
+		}
+		recorded[id] = true
+	}
+
+	return recorded, rows.Err()
+}
+
+// StorageReconciliation is a stored reconciliation report
+type StorageReconciliation struct {
+	ID                string          `json:"id"`
+	WorkerID          string          `json:"worker_id"`
+	JobID             *string         `json:"job_id,omitempty"`
+	InventoryCount    int             `json:"inventory_count"`
+	MissingCount      int             `json:"missing_count"`
+	OrphanedCount     int             `json:"orphaned_count"`
+	SizeMismatchCount int             `json:"size_mismatch_count"`
+	Report            json.RawMessage `json:"report,omitempty"`
+	CreatedAt         time.Time       `json:"created_at"`
+}
+
+// CreateStorageReconciliation stores the report of a reconcile_storage job
+func (r *Repository) CreateStorageReconciliation(ctx context.Context, report *types.StorageReconciliation) (*StorageReconciliation, error) {
+	reportJSON, err := json.Marshal(report)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal reconciliation report: %w", err)
+	}
+
+	query := `INSERT INTO storage_reconciliations
+	          (id, worker_id, job_id, inventory_count, missing_count, orphaned_count, size_mismatch_count, report, created_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+
+	rec := StorageReconciliation{
+		ID:                uuid.New().String(),
+		WorkerID:          report.WorkerID,
+		JobID:             &report.JobID,
+		InventoryCount:    report.InventoryCount,
+		MissingCount:      len(report.Missing),
+		OrphanedCount:     len(report.Orphaned),
+		SizeMismatchCount: len(report.SizeMismatches),
+		Report:            reportJSON,
+		CreatedAt:         time.Now(),
+	}
+	_, err = r.db.ExecContext(ctx, query, rec.ID, rec.WorkerID, rec.JobID, rec.InventoryCount, rec.MissingCount,
+		rec.OrphanedCount, rec.SizeMismatchCount, rec.Report, rec.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage reconciliation: %w", err)
+	}
+
+	return &rec, nil
+}
+
+// ListStorageReconciliations lists reconciliation summaries, newest first, optionally
+// for a single worker. Reports are left out.
+func (r *Repository) ListStorageReconciliations(ctx context.Context, workerID string, limit int) ([]*StorageReconciliation, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+
+	query := `SELECT id, worker_id, job_id, inventory_count, missing_count, orphaned_count, size_mismatch_count, created_at
+	          FROM storage_reconciliations
+	          WHERE ($1 = '' OR worker_id = $1)
+	          ORDER BY created_at DESC
+	          LIMIT $2`
+
+	rows, err := r.db.QueryContext(ctx, query, workerID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list storage reconciliations: %w", err)
+	}
+	defer rows.Close()
+
+	var recs []*StorageReconciliation
+	for rows.Next() {
+		var rec StorageReconciliation
+		err := rows.Scan(&rec.ID, &rec.WorkerID, &rec.JobID, &rec.InventoryCount, &rec.MissingCount,
+			&rec.OrphanedCount, &rec.SizeMismatchCount, &rec.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan storage reconciliation: %w", err)
+		}
+		recs = append(recs, &rec)
+	}
+
+	return recs, rows.Err()
+}
+
+// GetStorageReconciliation retrieves a reconciliation with its full report
+func (r *Repository) GetStorageReconciliation(ctx context.Context, id string) (*StorageReconciliation, error) {
+	query := `SELECT id, worker_id, job_id, inventory_count, missing_count, orphaned_count, size_mismatch_count, report, created_at
+	          FROM storage_reconciliations WHERE id = $1`
+
+	var rec StorageReconciliation
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&rec.ID, &rec.WorkerID, &rec.JobID, &rec.InventoryCount,
+		&rec.MissingCount, &rec.OrphanedCount, &rec.SizeMismatchCount, &rec.Report, &rec.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get storage reconciliation: %w", err)
+	}
+
+	return &rec, nil
+}
+
+// ==================== SNAPSHOT VERIFICATION ====================
+
+// VerifySchedule says how often the snapshots of a tenant, or of one of its sources,
+// are re-verified. A source schedule replaces the tenant schedule for that source.
+type VerifySchedule struct {
+	ID              string     `json:"id"`
+	TenantID        string     `json:"tenant_id"`
+	SourceID        *string    `json:"source_id,omitempty"` // nil for the tenant schedule
+	IntervalMinutes int        `json:"interval_minutes"`
+	TestDecrypt     bool       `json:"test_decrypt"`
+	Status          string     `json:"status"`
+	LastRunAt       *time.Time `json:"last_run_at,omitempty"`
+	NextRunAt       *time.Time `json:"next_run_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// CreateVerifySchedule creates a verify schedule for a tenant, or for one of its
+// sources when sourceID is set
+func (r *Repository) CreateVerifySchedule(ctx context.Context, tenantID string, sourceID *string, intervalMinutes int, testDecrypt bool, nextRunAt time.Time) (*VerifySchedule, error) {
+	id := uuid.New().String()
+	now := time.Now()
+
+	query := `INSERT INTO verify_schedules (id, tenant_id, source_id, interval_minutes, test_decrypt, status, next_run_at, created_at, updated_at)
+	          VALUES ($1, $2, $3, $4, $5, 'enabled', $6, $7, $7)
+	          RETURNING id, tenant_id, source_id, interval_minutes, test_decrypt, status, last_run_at, next_run_at, created_at, updated_at`
+
+	var schedule VerifySchedule
+	err := r.db.QueryRowContext(ctx, query, id, tenantID, sourceID, intervalMinutes, testDecrypt, nextRunAt, now).Scan(
+		&schedule.ID, &schedule.TenantID, &schedule.SourceID, &schedule.IntervalMinutes, &schedule.TestDecrypt,
+		&schedule.Status, &schedule.LastRunAt, &schedule.NextRunAt, &schedule.CreatedAt, &schedule.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create verify schedule: %w", err)
+	}
+
+	return &schedule, nil
+}
+
+// UpdateVerifySchedule updates a verify schedule
+func (r *Repository) UpdateVerifySchedule(ctx context.Context, scheduleID string, intervalMinutes int, testDecrypt bool, status string, nextRunAt *time.Time) (*VerifySchedule, error) {
+	query := `UPDATE verify_schedules
+	          SET interval_minutes = $2, test_decrypt = $3, status = $4, next_run_at = COALESCE($5, next_run_at), updated_at = $6
+	          WHERE id = $1::uuid
+	          RETURNING id, tenant_id, source_id, interval_minutes, test_decrypt, status, last_run_at, next_run_at, created_at, updated_at`
+
+	var schedule VerifySchedule
+	err := r.db.QueryRowContext(ctx, query, scheduleID, intervalMinutes, testDecrypt, status, nextRunAt, time.Now()).Scan(
+		&schedule.ID, &schedule.TenantID, &schedule.SourceID, &schedule.IntervalMinutes, &schedule.TestDecrypt,
+		&schedule.Status, &schedule.LastRunAt, &schedule.NextRunAt, &schedule.CreatedAt, &schedule.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update verify schedule: %w", err)
+	}
+
+	return &schedule, nil
+}
+
+// GetVerifySchedule retrieves a verify schedule by ID
+func (r *Repository) GetVerifySchedule(ctx context.Context, scheduleID string) (*VerifySchedule, error) {
+	query := `SELECT id, tenant_id, source_id, interval_minutes, test_decrypt, status, last_run_at, next_run_at, created_at, updated_at
+	          FROM verify_schedules WHERE id = $1::uuid`
+
+	var schedule VerifySchedule
+	err := r.db.QueryRowContext(ctx, query, scheduleID).Scan(
+		&schedule.ID, &schedule.TenantID, &schedule.SourceID, &schedule.IntervalMinutes, &schedule.TestDecrypt,
+		&schedule.Status, &schedule.LastRunAt, &schedule.NextRunAt, &schedule.CreatedAt, &schedule.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get verify schedule: %w", err)
+	}
+
+	return &schedule, nil
+}
+
+// ListVerifySchedules lists verify schedules, optionally for a single tenant
+func (r *Repository) ListVerifySchedules(ctx context.Context, tenantID string) ([]*VerifySchedule, error) {
+	query := `SELECT id, tenant_id, source_id, interval_minutes, test_decrypt, status, last_run_at, next_run_at, created_at, updated_at
+	          FROM verify_schedules
+	          WHERE ($1 = '' OR tenant_id::text = $1)
+	          ORDER BY created_at DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list verify schedules: %w", err)
+	}
+	defer rows.Close()
+
+	var schedules []*VerifySchedule
+	for rows.Next() {
+		var schedule VerifySchedule
+		err := rows.Scan(
+			&schedule.ID, &schedule.TenantID, &schedule.SourceID, &schedule.IntervalMinutes, &schedule.TestDecrypt,
+			&schedule.Status, &schedule.LastRunAt, &schedule.NextRunAt, &schedule.CreatedAt, &schedule.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan verify schedule: %w", err)
+		}
+		schedules = append(schedules, &schedule)
+	}
+
+	return schedules, rows.Err()
+}
+
+// DeleteVerifySchedule deletes a verify schedule
+func (r *Repository) DeleteVerifySchedule(ctx context.Context, scheduleID string) error {
+	query := `DELETE FROM verify_schedules WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, scheduleID)
+	if err != nil {
+		return fmt.Errorf("failed to delete verify schedule: %w", err)
+	}
+	return nil
+}
+
+// GetDueVerifySchedules retrieves all enabled verify schedules that are due to run
+func (r *Repository) GetDueVerifySchedules(ctx context.Context, now time.Time) ([]*VerifySchedule, error) {
+	query := `SELECT id, tenant_id, source_id, interval_minutes, test_decrypt, status, last_run_at, next_run_at, created_at, updated_at
+	          FROM verify_schedules
+	          WHERE status = 'enabled'
+	          AND (next_run_at IS NULL OR next_run_at <= $1)
+	          ORDER BY next_run_at ASC NULLS FIRST`
+
+	rows, err := r.db.QueryContext(ctx, query, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get due verify schedules: %w", err)
+	}
+	defer rows.Close()
+
+	var schedules []*VerifySchedule
+	for rows.Next() {
+		var schedule VerifySchedule
+		err := rows.Scan(
+			&schedule.ID, &schedule.TenantID, &schedule.SourceID, &schedule.IntervalMinutes, &schedule.TestDecrypt,
+			&schedule.Status, &schedule.LastRunAt, &schedule.NextRunAt, &schedule.CreatedAt, &schedule.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan verify schedule: %w", err)
+		}
+		schedules = append(schedules, &schedule)
+	}
+
+	return schedules, rows.Err()
+}
+
+// UpdateVerifyScheduleRunTimes updates the last_run_at and next_run_at for a verify schedule
+func (r *Repository) UpdateVerifyScheduleRunTimes(ctx context.Context, scheduleID string, lastRunAt, nextRunAt time.Time) error {
+	query := `UPDATE verify_schedules SET last_run_at = $2, next_run_at = $3, updated_at = $4 WHERE id = $1::uuid`
+	_, err := r.db.ExecContext(ctx, query, scheduleID, lastRunAt, nextRunAt, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to update verify schedule run times: %w", err)
+	}
+	return nil
+}
+
+// ListSnapshotsDueForVerify returns the completed snapshots a verify schedule covers
+// that were not verified since verifiedBefore and have no verify job pending, least
+// recently verified first. A tenant schedule (sourceID "") skips sources that have a
+// schedule of their own.
+func (r *Repository) ListSnapshotsDueForVerify(ctx context.Context, tenantID, sourceID string, verifiedBefore time.Time) ([]string, error) {
+	query := `SELECT s.id FROM snapshots s
+	          WHERE s.tenant_id = $1::uuid
+	            AND s.status = 'completed'
+	            AND (($2 = '' AND NOT EXISTS (SELECT 1 FROM verify_schedules vs WHERE vs.source_id = s.source_id))
+	                 OR s.source_id::text = $2)
+	            AND (s.last_verified_at IS NULL OR s.last_verified_at < $3)
+	            AND NOT EXISTS (
+	                SELECT 1 FROM jobs j
+	                WHERE j.type = 'verify_snapshot' AND j.status IN ('queued', 'running')
+	                  AND j.payload->>'verify_snapshot_id' = s.id::text
+	            )
+	          ORDER BY s.last_verified_at ASC NULLS FIRST, s.created_at ASC`
+
+	rows, err := r.db.QueryContext(ctx, query, tenantID, sourceID, verifiedBefore)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots due for verification: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan snapshot id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// RecordSnapshotVerification stores the result of a verify job as the snapshot's
+// integrity status and sets last_verified_at
+func (r *Repository) RecordSnapshotVerification(ctx context.Context, snapshotID, status, verifyError string) error {
+	var errorValue *string
+	if verifyError != "" {
+		errorValue = &verifyError
+	}
+
+	query := `UPDATE snapshots
+	          SET integrity_status = $2,
+	              integrity_error = $3,
+	              integrity_checked_at = $4,
+	              last_verified_at = $4,
+	              updated_at = $4
+	          WHERE id = $1`
+
+	_, err := r.db.ExecContext(ctx, query, snapshotID, status, errorValue, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to record snapshot verification: %w", err)
+	}
+
+	return nil
+}
+
+// ==================== SNAPSHOT REPLICATION ====================
+
+// ReplicationPolicy says how many copies of each snapshot of a tenant, or of one of
+// its sources, are kept on different workers. A source policy replaces the tenant
+// policy for that source.
+type ReplicationPolicy struct {
+	ID              string    `json:"id"`
+	TenantID        string    `json:"tenant_id"`
+	SourceID        *string   `json:"source_id,omitempty"` // nil for the tenant policy
+	Copies          int       `json:"copies"`
+	ObjectStoreCopy bool      `json:"object_store_copy"`
+	Status          string    `json:"status"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// CreateReplicationPolicy creates a replication policy for a tenant, or for one of
+// its sources when sourceID is set
+func (r *Repository) CreateReplicationPolicy(ctx context.Context, tenantID string, sourceID *string, copies int, objectStoreCopy bool) (*ReplicationPolicy, error) {
+	id := uuid.New().String()
+	now := time.Now()
+
+	query := `INSERT INTO replication_policies (id, tenant_id, source_id, copies, object_store_copy, status, created_at, updated_at)
+	          VALUES ($1, $2, $3, $4, $5, 'enabled', $6, $6)
+	          RETURNING id, tenant_id, source_id, copies, object_store_copy, status, created_at, updated_at`
+
+	var policy ReplicationPolicy
+	err := r.db.QueryRowContext(ctx, query, id, tenantID, sourceID, copies, objectStoreCopy, now).Scan(
+		&policy.ID, &policy.TenantID, &policy.SourceID, &policy.Copies, &policy.ObjectStoreCopy,
+		&policy.Status, &policy.CreatedAt, &policy.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create replication policy: %w", err)
+	}
+
+	return &policy, nil
+}
+
+// UpdateReplicationPolicy updates a replication policy
+func (r *Repository) UpdateReplicationPolicy(ctx context.Context, policyID string, copies int, objectStoreCopy bool, status string) (*ReplicationPolicy, error) {
+	query := `UPDATE replication_policies
+	          SET copies = $2, object_store_copy = $3, status = $4, updated_at = $5
+	          WHERE id = $1::uuid
+	          RETURNING id, tenant_id, source_id, copies, object_store_copy, status, created_at, updated_at`
+
+	var policy ReplicationPolicy
+	err := r.db.QueryRowContext(ctx, query, policyID, copies, objectStoreCopy, status, time.Now()).Scan(
+		&policy.ID, &policy.TenantID, &policy.SourceID, &policy.Copies, &policy.ObjectStoreCopy,
+		&policy.Status, &policy.CreatedAt, &policy.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update replication policy: %w", err)
+	}
+
+	return &policy, nil
+}
+
+// GetReplicationPolicy retrieves a replication policy by ID
+func (r *Repository) GetReplicationPolicy(ctx context.Context, policyID string) (*ReplicationPolicy, error) {
+	query := `SELECT id, tenant_id, source_id, copies, object_store_copy, status, created_at, updated_at
+	          FROM replication_policies WHERE id = $1::uuid`
+
+	var policy ReplicationPolicy
+	err := r.db.QueryRowContext(ctx, query, policyID).Scan(
+		&policy.ID, &policy.TenantID, &policy.SourceID, &policy.Copies, &policy.ObjectStoreCopy,
+		&policy.Status, &policy.CreatedAt, &policy.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get replication policy: %w", err)
+	}
+
+	return &policy, nil
+}
+
+// GetReplicationPolicyForSource returns the enabled policy that covers a source: its
+// own policy, or else its tenant's
+func (r *Repository) GetReplicationPolicyForSource(ctx context.Context, tenantID, sourceID string) (*ReplicationPolicy, error) {
+	query := `SELECT id, tenant_id, source_id, copies, object_store_copy, status, created_at, updated_at
+	          FROM replication_policies
+	          WHERE tenant_id = $1::uuid AND (source_id = $2::uuid OR source_id IS NULL)
+	            AND status = 'enabled'
+	          ORDER BY source_id NULLS LAST
+	          LIMIT 1`
+
+	var policy ReplicationPolicy
+	err := r.db.QueryRowContext(ctx, query, tenantID, sourceID).Scan(
+		&policy.ID, &policy.TenantID, &policy.SourceID, &policy.Copies, &policy.ObjectStoreCopy,
+		&policy.Status, &policy.CreatedAt, &policy.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &policy, nil
+}
+
+// ListReplicationPolicies lists replication policies, optionally for a single tenant
+func (r *Repository) ListReplicationPolicies(ctx context.Context, tenantID string) ([]*ReplicationPolicy, error) {
+	query := `SELECT id, tenant_id, source_id, copies, object_store_copy, status, created_at, updated_at
+	          FROM replication_policies
+	          WHERE ($1 = '' OR tenant_id::text = $1)
+	          ORDER BY created_at DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list replication policies: %w", err)
+	}
+	defer rows.Close()
+
+	var policies []*ReplicationPolicy
+	for rows.Next() {
+		var policy ReplicationPolicy
+		err := rows.Scan(
+			&policy.ID, &policy.TenantID, &policy.SourceID, &policy.Copies, &policy.ObjectStoreCopy,
+			&policy.Status, &policy.CreatedAt, &policy.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan replication policy: %w", err)
+		}
+		policies = append(policies, &policy)
+	}
+
+	return policies, rows.Err()
+}
+
+// DeleteReplicationPolicy deletes a replication policy. Replicas already made are
+// kept.
+func (r *Repository) DeleteReplicationPolicy(ctx context.Context, policyID string) error {
+	query := `DELETE FROM replication_policies WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, policyID)
+	if err != nil {
+		return fmt.Errorf("failed to delete replication policy: %w", err)
+	}
+	return nil
+}
+
+// SnapshotReplica is a copy of a snapshot held by a worker other than the one that
+// took it
+type SnapshotReplica struct {
+	ID             string                `json:"id"`
+	SnapshotID     string                `json:"snapshot_id"`
+	JobID          *string               `json:"job_id,omitempty"`
+	WorkerID       string                `json:"worker_id"`
+	SourceWorkerID *string               `json:"source_worker_id,omitempty"`
+	Status         string                `json:"status"`
+	StorageBackend string                `json:"storage_backend"`
+	LocalPath      *string               `json:"local_path,omitempty"`
+	Bucket         *string               `json:"bucket,omitempty"`
+	ObjectKey      *string               `json:"object_key,omitempty"`
+	ETag           *string               `json:"etag,omitempty"`
+	Volumes        types.ArtifactVolumes `json:"volumes,omitempty"`
+	// OffsiteTargetID is set when the copy was pushed to the tenant's offsite
+	// target; WorkerID is then the worker that pushed it
+	OffsiteTargetID *string   `json:"offsite_target_id,omitempty"`
+	Error           *string   `json:"error,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// IsOffsite reports whether the replica is held on an offsite target rather than by
+// a worker
+func (sr *SnapshotReplica) IsOffsite() bool {
+	return sr.OffsiteTargetID != nil
+}
+
+// Locator returns where the replica is stored
+func (sr *SnapshotReplica) Locator() types.SnapshotLocator {
+	deref := func(v *string) string {
+		if v == nil {
+			return ""
+		}
+		return *v
+	}
+	return types.SnapshotLocator{
+		StorageBackend:  types.StorageBackend(sr.StorageBackend),
+		WorkerID:        sr.WorkerID,
+		LocalPath:       deref(sr.LocalPath),
+		Bucket:          deref(sr.Bucket),
+		ObjectKey:       deref(sr.ObjectKey),
+		ETag:            deref(sr.ETag),
+		Volumes:         sr.Volumes,
+		OffsiteTargetID: deref(sr.OffsiteTargetID),
+	}
+}
+
+// CreateSnapshotReplica records a pending copy of a snapshot on a worker, to be made
+// from sourceWorkerID's copy. A failed replica on the same worker is reset and
+// reused; any other existing replica there is an error.
+func (r *Repository) CreateSnapshotReplica(ctx context.Context, snapshotID, workerID, sourceWorkerID string, storageBackend types.StorageBackend, transferTokenHash string) (*SnapshotReplica, error) {
+	id := uuid.New().String()
+	now := time.Now()
+
+	query := `INSERT INTO snapshot_replicas (id, snapshot_id, worker_id, source_worker_id, status, storage_backend, transfer_token_hash, created_at, updated_at)
+	          VALUES ($1, $2, $3, $4, 'pending', $5, $6, $7, $7)
+	          ON CONFLICT (snapshot_id, worker_id) WHERE offsite_target_id IS NULL DO UPDATE
+	          SET job_id = NULL, source_worker_id = EXCLUDED.source_worker_id, status = 'pending',
+	              storage_backend = EXCLUDED.storage_backend, local_path = NULL, bucket = NULL, object_key = NULL,
+	              etag = NULL, volumes = NULL, transfer_token_hash = EXCLUDED.transfer_token_hash, error = NULL,
+	              updated_at = EXCLUDED.updated_at
+	          WHERE snapshot_replicas.status = 'failed'
+	          RETURNING id, snapshot_id, job_id, worker_id, source_worker_id, status, storage_backend,
+	                    local_path, bucket, object_key, etag, volumes, offsite_target_id, error, created_at, updated_at`
+
+	var replica SnapshotReplica
+	err := r.db.QueryRowContext(ctx, query, id, snapshotID, workerID, sourceWorkerID, storageBackend, transferTokenHash, now).Scan(
+		&replica.ID, &replica.SnapshotID, &replica.JobID, &replica.WorkerID, &replica.SourceWorkerID, &replica.Status, &replica.StorageBackend,
+		&replica.LocalPath, &replica.Bucket, &replica.ObjectKey, &replica.ETag, &replica.Volumes, &replica.OffsiteTargetID,
+		&replica.Error, &replica.CreatedAt, &replica.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("worker %s already holds a replica of snapshot %s", workerID, snapshotID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create snapshot replica: %w", err)
+	}
+
+	return &replica, nil
+}
+
+// CreateOffsiteReplica records a pending copy of a snapshot on an offsite target, to
+// be pushed there by workerID from its own copy. A failed copy on the same target is
+// reset and reused; any other existing copy there is an error.
+func (r *Repository) CreateOffsiteReplica(ctx context.Context, snapshotID, workerID, offsiteTargetID string) (*SnapshotReplica, error) {
+	id := uuid.New().String()
+	now := time.Now()
+
+	query := `INSERT INTO snapshot_replicas (id, snapshot_id, worker_id, source_worker_id, status, storage_backend, offsite_target_id, created_at, updated_at)
+	          VALUES ($1, $2, $3, $3, 'pending', 'sftp', $4, $5, $5)
+	          ON CONFLICT (snapshot_id, offsite_target_id) WHERE offsite_target_id IS NOT NULL DO UPDATE
+	          SET job_id = NULL, worker_id = EXCLUDED.worker_id, source_worker_id = EXCLUDED.source_worker_id,
+	              status = 'pending', local_path = NULL, bucket = NULL, object_key = NULL, etag = NULL, volumes = NULL,
+	              error = NULL, updated_at = EXCLUDED.updated_at
+	          WHERE snapshot_replicas.status = 'failed'
+	          RETURNING id, snapshot_id, job_id, worker_id, source_worker_id, status, storage_backend,
+	                    local_path, bucket, object_key, etag, volumes, offsite_target_id, error, created_at, updated_at`
+
+	var replica SnapshotReplica
+	err := r.db.QueryRowContext(ctx, query, id, snapshotID, workerID, offsiteTargetID, now).Scan(
+		&replica.ID, &replica.SnapshotID, &replica.JobID, &replica.WorkerID, &replica.SourceWorkerID, &replica.Status, &replica.StorageBackend,
+		&replica.LocalPath, &replica.Bucket, &replica.ObjectKey, &replica.ETag, &replica.Volumes, &replica.OffsiteTargetID,
+		&replica.Error, &replica.CreatedAt, &replica.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("snapshot %s is already on offsite target %s", snapshotID, offsiteTargetID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create offsite replica: %w", err)
+	}
+
+	return &replica, nil
+}
+
+// SetSnapshotReplicaJob links a replica to the replicate_snapshot job making it
+func (r *Repository) SetSnapshotReplicaJob(ctx context.Context, replicaID, jobID string) error {
+	query := `UPDATE snapshot_replicas SET job_id = $2, updated_at = $3 WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, replicaID, jobID, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to set replica job: %w", err)
+	}
+	return nil
+}
+
+// ListSnapshotReplicas lists the replicas of a snapshot, oldest first
+func (r *Repository) ListSnapshotReplicas(ctx context.Context, snapshotID string) ([]*SnapshotReplica, error) {
+	query := `SELECT id, snapshot_id, job_id, worker_id, source_worker_id, status, storage_backend,
+	          local_path, bucket, object_key, etag, volumes, offsite_target_id, error, created_at, updated_at
+	          FROM snapshot_replicas
+	          WHERE snapshot_id = $1
+	          ORDER BY created_at ASC`
+
+	rows, err := r.db.QueryContext(ctx, query, snapshotID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshot replicas: %w", err)
+	}
+	defer rows.Close()
+
+	var replicas []*SnapshotReplica
+	for rows.Next() {
+		var replica SnapshotReplica
+		err := rows.Scan(
+			&replica.ID, &replica.SnapshotID, &replica.JobID, &replica.WorkerID, &replica.SourceWorkerID, &replica.Status, &replica.StorageBackend,
+			&replica.LocalPath, &replica.Bucket, &replica.ObjectKey, &replica.ETag, &replica.Volumes, &replica.OffsiteTargetID,
+			&replica.Error, &replica.CreatedAt, &replica.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan snapshot replica: %w", err)
+		}
+		replicas = append(replicas, &replica)
+	}
+
+	return replicas, rows.Err()
+}
+
+// GetPendingReplicaByTransferToken returns the pending replica that is being copied
+// from sourceWorkerID with the token hashing to transferTokenHash
+func (r *Repository) GetPendingReplicaByTransferToken(ctx context.Context, sourceWorkerID, transferTokenHash string) (*SnapshotReplica, error) {
+	query := `SELECT id, snapshot_id, job_id, worker_id, source_worker_id, status, storage_backend,
+	          local_path, bucket, object_key, etag, volumes, offsite_target_id, error, created_at, updated_at
+	          FROM snapshot_replicas
+	          WHERE transfer_token_hash = $1 AND source_worker_id = $2 AND status = 'pending'`
+
+	var replica SnapshotReplica
+	err := r.db.QueryRowContext(ctx, query, transferTokenHash, sourceWorkerID).Scan(
+		&replica.ID, &replica.SnapshotID, &replica.JobID, &replica.WorkerID, &replica.SourceWorkerID, &replica.Status, &replica.StorageBackend,
+		&replica.LocalPath, &replica.Bucket, &replica.ObjectKey, &replica.ETag, &replica.Volumes, &replica.OffsiteTargetID,
+		&replica.Error, &replica.CreatedAt, &replica.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &replica, nil
+}
+
+// CompleteSnapshotReplica records where a pending replica was stored and revokes its
+// transfer token. Returns sql.ErrNoRows when the replica no longer exists or is not
+// pending, e.g. because its snapshot was deleted during the copy.
+func (r *Repository) CompleteSnapshotReplica(ctx context.Context, replicaID string, locator types.SnapshotLocator) error {
+	nullable := func(v string) *string {
+		if v == "" {
+			return nil
+		}
+		return &v
+	}
+
+	query := `UPDATE snapshot_replicas
+	          SET status = 'completed', storage_backend = $2, local_path = $3, bucket = $4, object_key = $5,
+	              etag = $6, volumes = $7, transfer_token_hash = NULL, error = NULL, updated_at = $8
+	          WHERE id = $1 AND status = 'pending'`
+
+	result, err := r.db.ExecContext(ctx, query, replicaID, locator.StorageBackend, nullable(locator.LocalPath),
+		nullable(locator.Bucket), nullable(locator.ObjectKey), nullable(locator.ETag),
+		types.ArtifactVolumes(locator.Volumes), time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to complete snapshot replica: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// FailSnapshotReplica marks a replica failed with the reason and revokes its transfer
+// token
+func (r *Repository) FailSnapshotReplica(ctx context.Context, replicaID, replicaError string) error {
+	query := `UPDATE snapshot_replicas
+	          SET status = 'failed', error = $2, transfer_token_hash = NULL, updated_at = $3
+	          WHERE id = $1`
+
+	_, err := r.db.ExecContext(ctx, query, replicaID, replicaError, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to mark snapshot replica failed: %w", err)
+	}
+	return nil
+}
+
+// DeleteSnapshotReplica deletes a replica record
+func (r *Repository) DeleteSnapshotReplica(ctx context.Context, replicaID string) error {
+	query := `DELETE FROM snapshot_replicas WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, replicaID)
+	if err != nil {
+		return fmt.Errorf("failed to delete snapshot replica: %w", err)
+	}
+	return nil
+}
+
+// ==================== SNAPSHOT TIERING ====================
+
+// TieringPolicy says when the snapshots of a tenant, or of one of its sources, are
+// moved from worker disk to colder storage. A source policy replaces the tenant
+// policy for that source.
+type TieringPolicy struct {
+	ID            string    `json:"id"`
+	TenantID      string    `json:"tenant_id"`
+	SourceID      *string   `json:"source_id,omitempty"` // nil for the tenant policy
+	MinAgeDays    int       `json:"min_age_days"`
+	TargetBackend string    `json:"target_backend"`
+	Status        string    `json:"status"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// CreateTieringPolicy creates a tiering policy for a tenant, or for one of its
+// sources when sourceID is set
+func (r *Repository) CreateTieringPolicy(ctx context.Context, tenantID string, sourceID *string, minAgeDays int, targetBackend string) (*TieringPolicy, error) {
+	id := uuid.New().String()
+	now := time.Now()
+
+	query := `INSERT INTO tiering_policies (id, tenant_id, source_id, min_age_days, target_backend, status, created_at, updated_at)
+	          VALUES ($1, $2, $3, $4, $5, 'enabled', $6, $6)
+	          RETURNING id, tenant_id, source_id, min_age_days, target_backend, status, created_at, updated_at`
+
+	var policy TieringPolicy
+	err := r.db.QueryRowContext(ctx, query, id, tenantID, sourceID, minAgeDays, targetBackend, now).Scan(
+		&policy.ID, &policy.TenantID, &policy.SourceID, &policy.MinAgeDays, &policy.TargetBackend,
+		&policy.Status, &policy.CreatedAt, &policy.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tiering policy: %w", err)
+	}
+
+	return &policy, nil
+}
+
+// UpdateTieringPolicy updates a tiering policy
+func (r *Repository) UpdateTieringPolicy(ctx context.Context, policyID string, minAgeDays int, targetBackend, status string) (*TieringPolicy, error) {
+	query := `UPDATE tiering_policies
+	          SET min_age_days = $2, target_backend = $3, status = $4, updated_at = $5
+	          WHERE id = $1::uuid
+	          RETURNING id, tenant_id, source_id, min_age_days, target_backend, status, created_at, updated_at`
+
+	var policy TieringPolicy
+	err := r.db.QueryRowContext(ctx, query, policyID, minAgeDays, targetBackend, status, time.Now()).Scan(
+		&policy.ID, &policy.TenantID, &policy.SourceID, &policy.MinAgeDays, &policy.TargetBackend,
+		&policy.Status, &policy.CreatedAt, &policy.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update tiering policy: %w", err)
+	}
+
+	return &policy, nil
+}
+
+// GetTieringPolicy retrieves a tiering policy by ID
+func (r *Repository) GetTieringPolicy(ctx context.Context, policyID string) (*TieringPolicy, error) {
+	query := `SELECT id, tenant_id, source_id, min_age_days, target_backend, status, created_at, updated_at
+	          FROM tiering_policies WHERE id = $1::uuid`
+
+	var policy TieringPolicy
+	err := r.db.QueryRowContext(ctx, query, policyID).Scan(
+		&policy.ID, &policy.TenantID, &policy.SourceID, &policy.MinAgeDays, &policy.TargetBackend,
+		&policy.Status, &policy.CreatedAt, &policy.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tiering policy: %w", err)
+	}
+
+	return &policy, nil
+}
+
+// ListTieringPolicies lists tiering policies, optionally for a single tenant and
+// optionally only enabled ones
+func (r *Repository) ListTieringPolicies(ctx context.Context, tenantID string, enabledOnly bool) ([]*TieringPolicy, error) {
+	query := `SELECT id, tenant_id, source_id, min_age_days, target_backend, status, created_at, updated_at
+	          FROM tiering_policies
+	          WHERE ($1 = '' OR tenant_id::text = $1)
+	            AND (NOT $2 OR status = 'enabled')
+	          ORDER BY created_at DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, tenantID, enabledOnly)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tiering policies: %w", err)
+	}
+	defer rows.Close()
+
+	var policies []*TieringPolicy
+	for rows.Next() {
+		var policy TieringPolicy
+		err := rows.Scan(
+			&policy.ID, &policy.TenantID, &policy.SourceID, &policy.MinAgeDays, &policy.TargetBackend,
+			&policy.Status, &policy.CreatedAt, &policy.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan tiering policy: %w", err)
+		}
+		policies = append(policies, &policy)
+	}
+
+	return policies, rows.Err()
+}
+
+// DeleteTieringPolicy deletes a tiering policy. Snapshots already moved stay where
+// they are.
+func (r *Repository) DeleteTieringPolicy(ctx context.Context, policyID string) error {
+	query := `DELETE FROM tiering_policies WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, policyID)
+	if err != nil {
+		return fmt.Errorf("failed to delete tiering policy: %w", err)
+	}
+	return nil
+}
+
+// ListSnapshotsDueForTiering returns the IDs of the completed artifact snapshots on
+// worker disk created before createdBefore, for a tenant policy (sourceID empty: the
+// tenant's sources without a policy of their own) or a source policy. Snapshots with
+// a migrate_snapshot job in flight, or that failed an integrity check, are skipped.
+func (r *Repository) ListSnapshotsDueForTiering(ctx context.Context, tenantID, sourceID string, createdBefore time.Time) ([]string, error) {
+	query := `SELECT s.id FROM snapshots s
+	          WHERE s.tenant_id = $1::uuid
+	            AND s.status = 'completed'
+	            AND s.storage_backend = 'local_fs'
+	            AND COALESCE(s.manifest_json->>'storage_mode', 'artifact') = 'artifact'
+	            AND (s.integrity_status IS NULL OR s.integrity_status = 'verified')
+	            AND s.created_at < $3
+	            AND (($2 = '' AND NOT EXISTS (SELECT 1 FROM tiering_policies tp WHERE tp.source_id = s.source_id))
+	                 OR s.source_id::text = $2)
+	            AND NOT EXISTS (
+	                SELECT 1 FROM jobs j
+	                WHERE j.type = 'migrate_snapshot' AND j.status IN ('queued', 'running')
+	                  AND j.payload->>'migrate_snapshot_id' = s.id::text
+	            )
+	          ORDER BY s.created_at ASC`
+
+	rows, err := r.db.QueryContext(ctx, query, tenantID, sourceID, createdBefore)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots due for tiering: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan snapshot id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// MoveSnapshot points a snapshot on worker disk at the copy a migrate_snapshot job
+// made in an object store, in one statement. Returns sql.ErrNoRows when the snapshot
+// is gone or no longer on worker disk.
+func (r *Repository) MoveSnapshot(ctx context.Context, snapshotID string, locator types.SnapshotLocator) error {
+	nullable := func(v string) *string {
+		if v == "" {
+			return nil
+		}
+		return &v
+	}
+
+	query := `UPDATE snapshots
+	          SET storage_backend = $2, local_path = NULL, bucket = $3, object_key = $4, etag = $5,
+	              tiered_at = $6, updated_at = $6
+	          WHERE id = $1 AND storage_backend = 'local_fs'`
+
+	result, err := r.db.ExecContext(ctx, query, snapshotID, locator.StorageBackend,
+		nullable(locator.Bucket), nullable(locator.ObjectKey), nullable(locator.ETag), time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to move snapshot: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// jobSnapshotID returns the SQL for the snapshot a job (aliased alias) reads or
+// changes, other than by re-encrypting it: the one it restores, deletes, verifies,
+// replicates or migrates, or the base of an incremental backup
+func jobSnapshotID(alias string) string {
+	return fmt.Sprintf(`COALESCE(%[1]s.payload->>'restore_snapshot_id', %[1]s.payload->>'delete_snapshot_id',
+	    %[1]s.payload->>'verify_snapshot_id', %[1]s.payload->>'replicate_snapshot_id',
+	    %[1]s.payload->>'migrate_snapshot_id', %[1]s.payload->>'base_snapshot_id')`, alias)
+}
+
+// jobNotHeldByRekey keeps a queued job (aliased c) from being claimed while it would
+// race a rekey_snapshot job swapping its snapshot's files: a rekey waits for the other
+// jobs running on its snapshot, and those jobs wait for a rekey queued or running.
+var jobNotHeldByRekey = `CASE WHEN c.type = 'rekey_snapshot' THEN NOT EXISTS (
+	    SELECT 1 FROM jobs j
+	    WHERE j.status IN ('running', 'finalizing') AND j.type <> 'rekey_snapshot'
+	      AND ` + jobSnapshotID("j") + ` = c.payload->>'rekey_snapshot_id')
+	ELSE NOT EXISTS (
+	    SELECT 1 FROM jobs j
+	    WHERE j.type = 'rekey_snapshot' AND j.status IN ('queued', 'running')
+	      AND j.payload->>'rekey_snapshot_id' = ` + jobSnapshotID("c") + `)
+	END`
+
+// CountActiveSnapshotJobs counts the jobs queued or running on a snapshot, rekey jobs
+// included
+func (r *Repository) CountActiveSnapshotJobs(ctx context.Context, snapshotID string) (int, error) {
+	query := `SELECT COUNT(*) FROM jobs j
+	          WHERE j.status IN ('queued', 'running', 'finalizing')
+	            AND (` + jobSnapshotID("j") + ` = $1 OR j.payload->>'rekey_snapshot_id' = $1)`
+
+	var count int
+	if err := r.db.QueryRowContext(ctx, query, snapshotID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count snapshot jobs: %w", err)
+	}
+	return count, nil
+}
+
+// ListSnapshotsToRekey returns the IDs of a tenant's snapshots a rekey_snapshot job
+// can re-encrypt to keyID, oldest first: completed artifact snapshots on worker disk
+// that are encrypted to another key, have no replica or offsite copy, are not held or
+// locked, and have no job of any kind queued or running on them
+func (r *Repository) ListSnapshotsToRekey(ctx context.Context, tenantID, keyID string) ([]string, error) {
+	query := `SELECT s.id FROM snapshots s
+	          WHERE s.tenant_id = $1
+	            AND s.status = 'completed'
+	            AND s.storage_backend = 'local_fs'
+	            AND COALESCE(s.manifest_json->>'storage_mode', 'artifact') = 'artifact'
+	            AND (s.encryption_key_id IS NULL OR s.encryption_key_id <> $2)
+	            AND NOT s.legal_hold
+	            AND (s.locked_until IS NULL OR s.locked_until <= now())
+	            AND NOT EXISTS (
+	                SELECT 1 FROM snapshot_replicas sr
+	                WHERE sr.snapshot_id = s.id AND sr.status <> 'failed'
+	            )
+	            AND NOT EXISTS (
+	                SELECT 1 FROM jobs j
+	                WHERE j.status IN ('queued', 'running', 'finalizing')
+	                  AND (` + jobSnapshotID("j") + ` = s.id::text OR j.payload->>'rekey_snapshot_id' = s.id::text)
+	            )
+	          ORDER BY s.created_at ASC`
+
+	rows, err := r.db.QueryContext(ctx, query, tenantID, keyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots to rekey: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan snapshot id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// RekeySnapshot records the manifest, signature and volumes of a snapshot a
+// rekey_snapshot job re-encrypted to key. Returns sql.ErrNoRows when the snapshot is
+// gone or no longer on worker disk.
+func (r *Repository) RekeySnapshot(ctx context.Context, snapshotID string, key *TenantKey, manifestJSON json.RawMessage, signature, signingKey string, sizeBytes int64, volumes []types.ArtifactVolume) error {
+	var signatureValue, signingKeyValue *string
+	if signature != "" && signingKey != "" {
+		signatureValue, signingKeyValue = &signature, &signingKey
+	}
+
+	query := `UPDATE snapshots
+	          SET manifest_json = $2, manifest_signature = $3, manifest_signing_key = $4, size_bytes = $5,
+	              volumes = $6, encryption_key_id = $7, encryption_recipient = $8, updated_at = $9
+	          WHERE id = $1 AND storage_backend = 'local_fs'`
+
+	result, err := r.db.ExecContext(ctx, query, snapshotID, manifestJSON, signatureValue, signingKeyValue, sizeBytes,
+		types.ArtifactVolumes(volumes), key.ID, key.PublicKey, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to rekey snapshot: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ==================== OFFSITE TARGETS ====================
+
+// OffsiteTarget is an SFTP server owned by a tenant that its new snapshots are pushed
+// to, still encrypted. The password or private key is a storage credential.
+type OffsiteTarget struct {
+	ID            string     `json:"id"`
+	TenantID      string     `json:"tenant_id"`
+	CredentialID  string     `json:"credential_id"`
+	Host          string     `json:"host"`
+	Port          int        `json:"port"`
+	Username      string     `json:"username"`
+	UsePrivateKey bool       `json:"use_private_key"`
+	BasePath      string     `json:"base_path"`
+	HostKey       *string    `json:"host_key,omitempty"`
+	Status        string     `json:"status"`
+	RetiredAt     *time.Time `json:"retired_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// SetOffsiteTarget creates or updates the current offsite target of a tenant in place,
+// keeping its ID
+func (r *Repository) SetOffsiteTarget(ctx context.Context, target OffsiteTarget) (*OffsiteTarget, error) {
+	query := `INSERT INTO offsite_targets (id, tenant_id, credential_id, host, port, username, use_private_key, base_path, host_key, status, created_at, updated_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $11)
+	          ON CONFLICT (tenant_id) WHERE retired_at IS NULL DO UPDATE
+	          SET credential_id = $3, host = $4, port = $5, username = $6, use_private_key = $7, base_path = $8,
+	              host_key = $9, status = $10, updated_at = $11
+	          RETURNING id, tenant_id, credential_id, host, port, username, use_private_key, base_path, host_key, status, retired_at, created_at, updated_at`
+
+	var t OffsiteTarget
+	err := r.db.QueryRowContext(ctx, query, uuid.New().String(), target.TenantID, target.CredentialID, target.Host, target.Port,
+		target.Username, target.UsePrivateKey, target.BasePath, target.HostKey, target.Status, time.Now()).Scan(
+		&t.ID, &t.TenantID, &t.CredentialID, &t.Host, &t.Port, &t.Username, &t.UsePrivateKey,
+		&t.BasePath, &t.HostKey, &t.Status, &t.RetiredAt, &t.CreatedAt, &t.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set offsite target: %w", err)
+	}
+	return &t, nil
+}
+
+// ReplaceOffsiteTarget retires a tenant's current offsite target and creates target
+// as its new one, in one transaction. The retired target keeps its ID and location,
+// so the copies already pushed to it stay readable and deletable. Returns
+// sql.ErrNoRows if oldID is no longer the current target.
+func (r *Repository) ReplaceOffsiteTarget(ctx context.Context, oldID string, target OffsiteTarget) (*OffsiteTarget, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	result, err := tx.ExecContext(ctx, `UPDATE offsite_targets SET status = 'disabled', retired_at = $2, updated_at = $2
+	                                    WHERE id = $1::uuid AND retired_at IS NULL`, oldID, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retire offsite target: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, fmt.Errorf("failed to retire offsite target: %w", err)
+	} else if n == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	query := `INSERT INTO offsite_targets (id, tenant_id, credential_id, host, port, username, use_private_key, base_path, host_key, status, created_at, updated_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $11)
+	          RETURNING id, tenant_id, credential_id, host, port, username, use_private_key, base_path, host_key, status, retired_at, created_at, updated_at`
+
+	var t OffsiteTarget
+	err = tx.QueryRowContext(ctx, query, uuid.New().String(), target.TenantID, target.CredentialID, target.Host, target.Port,
+		target.Username, target.UsePrivateKey, target.BasePath, target.HostKey, target.Status, now).Scan(
+		&t.ID, &t.TenantID, &t.CredentialID, &t.Host, &t.Port, &t.Username, &t.UsePrivateKey,
+		&t.BasePath, &t.HostKey, &t.Status, &t.RetiredAt, &t.CreatedAt, &t.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create offsite target: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit offsite target replacement: %w", err)
+	}
+	return &t, nil
+}
+
+// GetOffsiteTarget retrieves an offsite target by ID, retired or not
+func (r *Repository) GetOffsiteTarget(ctx context.Context, id string) (*OffsiteTarget, error) {
+	query := `SELECT id, tenant_id, credential_id, host, port, username, use_private_key, base_path, host_key, status, retired_at, created_at, updated_at
+	          FROM offsite_targets WHERE id = $1::uuid`
+
+	var t OffsiteTarget
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&t.ID, &t.TenantID, &t.CredentialID, &t.Host, &t.Port, &t.Username, &t.UsePrivateKey,
+		&t.BasePath, &t.HostKey, &t.Status, &t.RetiredAt, &t.CreatedAt, &t.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get offsite target: %w", err)
+	}
+	return &t, nil
+}
+
+// GetOffsiteTargetForTenant retrieves the current offsite target of a tenant
+func (r *Repository) GetOffsiteTargetForTenant(ctx context.Context, tenantID string) (*OffsiteTarget, error) {
+	query := `SELECT id, tenant_id, credential_id, host, port, username, use_private_key, base_path, host_key, status, retired_at, created_at, updated_at
+	          FROM offsite_targets WHERE tenant_id = $1::uuid AND retired_at IS NULL`
+
+	var t OffsiteTarget
+	err := r.db.QueryRowContext(ctx, query, tenantID).Scan(
+		&t.ID, &t.TenantID, &t.CredentialID, &t.Host, &t.Port, &t.Username, &t.UsePrivateKey,
+		&t.BasePath, &t.HostKey, &t.Status, &t.RetiredAt, &t.CreatedAt, &t.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get offsite target: %w", err)
+	}
+	return &t, nil
+}
+
+// CountOffsiteReplicas counts the copies on an offsite target that are pushed or
+// being pushed
+func (r *Repository) CountOffsiteReplicas(ctx context.Context, offsiteTargetID string) (int, error) {
+	query := `SELECT COUNT(*) FROM snapshot_replicas WHERE offsite_target_id = $1::uuid AND status <> 'failed'`
+
+	var count int
+	if err := r.db.QueryRowContext(ctx, query, offsiteTargetID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count offsite replicas: %w", err)
+	}
+	return count, nil
+}
+
+// DeleteOffsiteTarget deletes an offsite target along with its failed copies' records
+func (r *Repository) DeleteOffsiteTarget(ctx context.Context, id string) error {
+	query := `DELETE FROM offsite_targets WHERE id = $1::uuid`
+	_, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete offsite target: %w", err)
+	}
+	return nil
+}
+
+// DeleteEmptyRetiredOffsiteTargets deletes the retired offsite targets no longer
+// holding any copies, along with their failed copies' records. Returns the
+// credentials they used.
+func (r *Repository) DeleteEmptyRetiredOffsiteTargets(ctx context.Context) ([]string, error) {
+	query := `DELETE FROM offsite_targets t
+	          WHERE t.retired_at IS NOT NULL
+	            AND NOT EXISTS (
+	                SELECT 1 FROM snapshot_replicas sr
+	                WHERE sr.offsite_target_id = t.id AND sr.status <> 'failed'
+	            )
+	          RETURNING t.credential_id`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete retired offsite targets: %w", err)
+	}
+	defer rows.Close()
+
+	var credentialIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan credential id: %w", err)
+		}
+		credentialIDs = append(credentialIDs, id)
+	}
+	return credentialIDs, rows.Err()
+}
+
+// DeleteUnusedCredential deletes a credential unless a source or offsite target still
+// uses it
+func (r *Repository) DeleteUnusedCredential(ctx context.Context, credentialID string) error {
+	query := `DELETE FROM credentials c
+	          WHERE c.id = $1::uuid
+	            AND NOT EXISTS (SELECT 1 FROM sources WHERE credential_id = c.id)
+	            AND NOT EXISTS (SELECT 1 FROM offsite_targets WHERE credential_id = c.id)`
+	_, err := r.db.ExecContext(ctx, query, credentialID)
+	if err != nil {
+		return fmt.Errorf("failed to delete credential: %w", err)
+	}
+	return nil
+}
+
+// ==================== TENANT QUOTAS ====================
+
+// Quota limits what a tenant, or every tenant on a plan, may store. Nil limits are
+// unlimited.
+type Quota struct {
+	ID                    string    `json:"id"`
+	TenantID              *string   `json:"tenant_id,omitempty"`
+	Plan                  *string   `json:"plan,omitempty"`
+	MaxStorageBytes       *int64    `json:"max_storage_bytes"`
+	MaxSources            *int      `json:"max_sources"`
+	MaxSnapshotsPerSource *int      `json:"max_snapshots_per_source"`
+	OverageAction         string    `json:"overage_action"`
+	CreatedAt             time.Time `json:"created_at"`
+	UpdatedAt             time.Time `json:"updated_at"`
+}
+
+// SetTenantQuota creates or replaces the quota of a tenant
+func (r *Repository) SetTenantQuota(ctx context.Context, tenantID string, maxStorageBytes *int64, maxSources, maxSnapshotsPerSource *int, overageAction string) (*Quota, error) {
+	query := `INSERT INTO quotas (tenant_id, max_storage_bytes, max_sources, max_snapshots_per_source, overage_action, created_at, updated_at)
+	          VALUES ($1::uuid, $2, $3, $4, $5, $6, $6)
+	          ON CONFLICT (tenant_id) DO UPDATE
+	          SET max_storage_bytes = $2, max_sources = $3, max_snapshots_per_source = $4, overage_action = $5, updated_at = $6
+	          RETURNING id, tenant_id, plan, max_storage_bytes, max_sources, max_snapshots_per_source, overage_action, created_at, updated_at`
+
+	var quota Quota
+	err := r.db.QueryRowContext(ctx, query, tenantID, maxStorageBytes, maxSources, maxSnapshotsPerSource, overageAction, time.Now()).Scan(
+		&quota.ID, &quota.TenantID, &quota.Plan, &quota.MaxStorageBytes, &quota.MaxSources,
+		&quota.MaxSnapshotsPerSource, &quota.OverageAction, &quota.CreatedAt, &quota.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set tenant quota: %w", err)
+	}
+	return &quota, nil
+}
+
+// SetPlanQuota creates or replaces the quota of the tenants on a plan
+func (r *Repository) SetPlanQuota(ctx context.Context, plan string, maxStorageBytes *int64, maxSources, maxSnapshotsPerSource *int, overageAction string) (*Quota, error) {
+	query := `INSERT INTO quotas (plan, max_storage_bytes, max_sources, max_snapshots_per_source, overage_action, created_at, updated_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, $6)
+	          ON CONFLICT (plan) DO UPDATE
+	          SET max_storage_bytes = $2, max_sources = $3, max_snapshots_per_source = $4, overage_action = $5, updated_at = $6
+	          RETURNING id, tenant_id, plan, max_storage_bytes, max_sources, max_snapshots_per_source, overage_action, created_at, updated_at`
+
+	var quota Quota
+	err := r.db.QueryRowContext(ctx, query, plan, maxStorageBytes, maxSources, maxSnapshotsPerSource, overageAction, time.Now()).Scan(
+		&quota.ID, &quota.TenantID, &quota.Plan, &quota.MaxStorageBytes, &quota.MaxSources,
+		&quota.MaxSnapshotsPerSource, &quota.OverageAction, &quota.CreatedAt, &quota.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set plan quota: %w", err)
+	}
+	return &quota, nil
+}
+
+// GetEffectiveQuota returns the quota that applies to a tenant: its own, or else its
+// plan's. Returns sql.ErrNoRows when neither exists.
+func (r *Repository) GetEffectiveQuota(ctx context.Context, tenantID string) (*Quota, error) {
+	query := `SELECT id, tenant_id, plan, max_storage_bytes, max_sources, max_snapshots_per_source, overage_action, created_at, updated_at
+	          FROM quotas
+	          WHERE tenant_id = $1::uuid OR plan = (SELECT plan FROM tenants WHERE id = $1::uuid)
+	          ORDER BY tenant_id IS NULL
+	          LIMIT 1`
+
+	var quota Quota
+	err := r.db.QueryRowContext(ctx, query, tenantID).Scan(
+		&quota.ID, &quota.TenantID, &quota.Plan, &quota.MaxStorageBytes, &quota.MaxSources,
+		&quota.MaxSnapshotsPerSource, &quota.OverageAction, &quota.CreatedAt, &quota.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get effective quota: %w", err)
+	}
+	return &quota, nil
+}
+
+// ListQuotas lists every tenant and plan quota
+func (r *Repository) ListQuotas(ctx context.Context) ([]*Quota, error) {
+	query := `SELECT id, tenant_id, plan, max_storage_bytes, max_sources, max_snapshots_per_source, overage_action, created_at, updated_at
+	          FROM quotas ORDER BY plan NULLS LAST, created_at`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list quotas: %w", err)
+	}
+	defer rows.Close()
+
+	var quotas []*Quota
+	for rows.Next() {
+		var quota Quota
+		if err := rows.Scan(
+			&quota.ID, &quota.TenantID, &quota.Plan, &quota.MaxStorageBytes, &quota.MaxSources,
+			&quota.MaxSnapshotsPerSource, &quota.OverageAction, &quota.CreatedAt, &quota.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan quota: %w", err)
+		}
+		quotas = append(quotas, &quota)
+	}
+	return quotas, rows.Err()
+}
+
+// DeleteTenantQuota removes a tenant's own quota, leaving its plan's in effect
+func (r *Repository) DeleteTenantQuota(ctx context.Context, tenantID string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM quotas WHERE tenant_id = $1::uuid`, tenantID)
+	if err != nil {
+		return fmt.Errorf("failed to delete tenant quota: %w", err)
+	}
+	return nil
+}
+
+// DeletePlanQuota removes the quota of a plan
+func (r *Repository) DeletePlanQuota(ctx context.Context, plan string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM quotas WHERE plan = $1`, plan)
+	if err != nil {
+		return fmt.Errorf("failed to delete plan quota: %w", err)
+	}
+	return nil
+}
+
+// UpdateTenantPlan moves a tenant to another plan
+func (r *Repository) UpdateTenantPlan(ctx context.Context, tenantID, plan string) (*Tenant, error) {
+	query := `UPDATE tenants SET plan = $2, updated_at = $3 WHERE id = $1::uuid
+	          RETURNING id, name, plan, created_at, updated_at`
+
+	var tenant Tenant
+	err := r.db.QueryRowContext(ctx, query, tenantID, plan, time.Now()).Scan(
+		&tenant.ID, &tenant.Name, &tenant.Plan, &tenant.CreatedAt, &tenant.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update tenant plan: %w", err)
+	}
+	return &tenant, nil
+}
+
+// snapshotNotPendingDeletion excludes snapshots (aliased sn) with a delete job
+// still queued or running for their primary copy
+const snapshotNotPendingDeletion = `NOT EXISTS (
+	SELECT 1 FROM jobs j
+	WHERE j.type = 'delete_snapshot' AND j.status IN ('queued', 'running', 'finalizing')
+	  AND j.payload->>'delete_snapshot_id' = sn.id::text
+	  AND j.payload->>'delete_replica_id' IS NULL)`
+
+// SourceUsage is what one source of a tenant stores
+type SourceUsage struct {
+	SourceID     string `json:"source_id"`
+	SourceName   string `json:"source_name"`
+	Snapshots    int    `json:"snapshots"`
+	StorageBytes int64  `json:"storage_bytes"`
+}
+
+// TenantUsage is what a tenant stores, counting completed snapshots that are not
+// being deleted
+type TenantUsage struct {
+	StorageBytes int64          `json:"storage_bytes"`
+	Sources      int            `json:"sources"`
+	Snapshots    int            `json:"snapshots"`
+	BySource     []*SourceUsage `json:"by_source"`
+}
+
+// SourceSnapshots returns the snapshots stored for a source
+func (u *TenantUsage) SourceSnapshots(sourceID string) int {
+	for _, source := range u.BySource {
+		if source.SourceID == sourceID {
+			return source.Snapshots
+		}
+	}
+	return 0
+}
+
+// GetTenantUsage sums the snapshot sizes and counts of a tenant per source
+func (r *Repository) GetTenantUsage(ctx context.Context, tenantID string) (*TenantUsage, error) {
+	query := `SELECT so.id, so.name, COUNT(sn.id), COALESCE(SUM(sn.size_bytes), 0)
+	          FROM sources so
+	          LEFT JOIN snapshots sn ON sn.source_id = so.id AND sn.status = 'completed' AND ` + snapshotNotPendingDeletion + `
+	          WHERE so.tenant_id = $1::uuid
+	          GROUP BY so.id, so.name, so.created_at
+	          ORDER BY so.created_at`
+
+	rows, err := r.db.QueryContext(ctx, query, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tenant usage: %w", err)
+	}
+	defer rows.Close()
+
+	usage := &TenantUsage{BySource: []*SourceUsage{}}
+	for rows.Next() {
+		var source SourceUsage
+		if err := rows.Scan(&source.SourceID, &source.SourceName, &source.Snapshots, &source.StorageBytes); err != nil {
+			return nil, fmt.Errorf("failed to scan source usage: %w", err)
+		}
+		usage.Sources++
+		usage.Snapshots += source.Snapshots
+		usage.StorageBytes += source.StorageBytes
+		usage.BySource = append(usage.BySource, &source)
+	}
+	return usage, rows.Err()
+}
+
+// QuotaRetentionCandidate is a snapshot quota enforcement may delete
+type QuotaRetentionCandidate struct {
+	SnapshotID string
+	SourceID   string
+	SizeBytes  int64
+}
+
+// ListQuotaRetentionCandidates lists a tenant's completed snapshots, optionally of one
+// source, oldest first. Each source's newest snapshot, locked snapshots and snapshots
+// already being deleted are left out.
+func (r *Repository) ListQuotaRetentionCandidates(ctx context.Context, tenantID, sourceID string) ([]*QuotaRetentionCandidate, error) {
+	query := `SELECT id, source_id, size_bytes FROM (
+	              SELECT sn.id, sn.source_id, sn.size_bytes, sn.created_at,
+	                     ROW_NUMBER() OVER (PARTITION BY sn.source_id ORDER BY sn.created_at DESC) AS newest
+	              FROM snapshots sn
+	              WHERE sn.tenant_id = $1::uuid AND ($2 = '' OR sn.source_id::text = $2)
+	                AND sn.status = 'completed' AND ` + snapshotNotPendingDeletion + `
+	                AND NOT sn.legal_hold AND (sn.locked_until IS NULL OR sn.locked_until <= $3)
+	          ) ranked
+	          WHERE newest > 1
+	          ORDER BY created_at ASC`
+
+	rows, err := r.db.QueryContext(ctx, query, tenantID, sourceID, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list quota retention candidates: %w", err)
+	}
+	defer rows.Close()
+
+	var candidates []*QuotaRetentionCandidate
+	for rows.Next() {
+		var candidate QuotaRetentionCandidate
+		if err := rows.Scan(&candidate.SnapshotID, &candidate.SourceID, &candidate.SizeBytes); err != nil {
+			return nil, fmt.Errorf("failed to scan quota retention candidate: %w", err)
+		}
+		candidates = append(candidates, &candidate)
+	}
+	return candidates, rows.Err()
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"xvault/internal/hub/repository"
+	"xvault/pkg/crypto"
+)
+
+// kekMigrationBatchSize is how many legacy secrets are read per query while
+// re-encrypting
+const kekMigrationBatchSize = 100
+
+// KEKMigrationReport is the result of re-encrypting legacy secrets
+type KEKMigrationReport struct {
+	KEKID       string `json:"kek_id"`
+	TenantKeys  int    `json:"tenant_keys"` // re-encrypted
+	Credentials int    `json:"credentials"` // re-encrypted
+	Failed      int    `json:"failed"`
+	// Legacy secrets left afterwards: failed ones, and any written meanwhile by a hub
+	// that predates the versioned format
+	RemainingTenantKeys  int `json:"remaining_tenant_keys"`
+	RemainingCredentials int `json:"remaining_credentials"`
+}
+
+// ReencryptLegacySecrets rewrites the tenant private keys and credentials still in
+// the legacy KEK format (see crypto.EncryptForStorage) in the versioned format. It runs
+// online: each row is swapped only if it has not changed since it was read, and both
+// formats stay readable meanwhile. Tenant keys go first and must decrypt to an age
+// identity; if any does not, the KEK is likely not the one they were written with and
+// credentials, which cannot be checked, are left alone.
+func (s *Service) ReencryptLegacySecrets(ctx context.Context) (*KEKMigrationReport, error) {
+	kekID, err := crypto.KEKID(s.encryptionKEK)
+	if err != nil {
+		return nil, err
+	}
+	report := &KEKMigrationReport{KEKID: kekID}
+
+	isIdentity := func(plaintext []byte) bool {
+		return strings.HasPrefix(string(plaintext), "AGE-SECRET-KEY-")
+	}
+	var failed int
+	report.TenantKeys, failed, err = s.reencryptSecrets(ctx, "tenant key", s.repo.ListLegacyTenantKeySecrets, s.repo.ReplaceTenantKeySecret, isIdentity)
+	report.Failed += failed
+	if err != nil {
+		return report, err
+	}
+
+	if failed == 0 {
+		report.Credentials, failed, err = s.reencryptSecrets(ctx, "credential", s.repo.ListLegacyCredentialSecrets, s.repo.ReplaceCredentialSecret, nil)
+		report.Failed += failed
+		if err != nil {
+			return report, err
+		}
+	}
+
+	report.RemainingTenantKeys, report.RemainingCredentials, err = s.repo.CountLegacySecrets(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	if report.TenantKeys+report.Credentials+report.Failed > 0 {
+		level := "info"
+		if report.Failed > 0 {
+			level = "warn"
+		}
+		s.LogSystemEvent(ctx, level, fmt.Sprintf("re-encrypted %d tenant keys and %d credentials under KEK %s", report.TenantKeys, report.Credentials, kekID), map[string]any{
+			"failed":                report.Failed,
+			"remaining_tenant_keys": report.RemainingTenantKeys,
+			"remaining_credentials": report.RemainingCredentials,
+		})
+	}
+	return report, nil
+}
+
+// reencryptSecrets re-encrypts the legacy secrets list returns, in batches. A secret
+// that cannot be decrypted, or whose plaintext valid rejects, is counted as failed and
+// left as it is.
+func (s *Service) reencryptSecrets(
+	ctx context.Context,
+	kind string,
+	list func(ctx context.Context, afterID string, limit int) ([]repository.EncryptedSecret, error),
+	replace func(ctx context.Context, id, oldCiphertext, newCiphertext string) (bool, error),
+	valid func(plaintext []byte) bool,
+) (reencrypted, failed int, err error) {
+	afterID := uuid.Nil.String()
+	for {
+		secrets, err := list(ctx, afterID, kekMigrationBatchSize)
+		if err != nil {
+			return reencrypted, failed, err
+		}
+		if len(secrets) == 0 {
+			return reencrypted, failed, nil
+		}
+
+		for _, secret := range secrets {
+			afterID = secret.ID
+
+			plaintext, err := crypto.DecryptFromStorage(secret.Ciphertext, s.encryptionKEK)
+			if err == nil && valid != nil && !valid(plaintext) {
+				err = fmt.Errorf("plaintext is not a valid %s", kind)
+			}
+			if err != nil {
+				log.Printf("failed to re-encrypt %s %s: %v", kind, secret.ID, err)
+				failed++
+				continue
+			}
+
+			ciphertext, err := crypto.EncryptForStorage(plaintext, s.encryptionKEK)
+			if err != nil {
+				return reencrypted, failed, fmt.Errorf("failed to encrypt %s %s: %w", kind, secret.ID, err)
+			}
+			// A secret rewritten since it was read is already in the new format
+			replaced, err := replace(ctx, secret.ID, secret.Ciphertext, ciphertext)
+			if err != nil {
+				return reencrypted, failed, err
+			}
+			if replaced {
+				reencrypted++
+			}
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"xvault/internal/hub/repository"
+	"xvault/pkg/crypto"
+	"xvault/pkg/types"
+)
+
+// rekeyJobPriority keeps rekey jobs behind every backup and delete job
+const rekeyJobPriority = -1
+
+// tenantKeyDisabled is the key_status of a key that may no longer decrypt anything
+const tenantKeyDisabled = "disabled"
+
+// ErrTenantKeyDisabled is returned when the key a snapshot is encrypted to has been
+// disabled
+var ErrTenantKeyDisabled = errors.New("tenant key is disabled")
+
+// ErrSnapshotNotRekeyable is returned when a snapshot cannot be re-encrypted to the
+// tenant's current key: it did not complete, is not on worker disk, is stored in a
+// chunk repository, has copies elsewhere, is held or locked, or has other jobs active
+var ErrSnapshotNotRekeyable = errors.New("snapshot cannot be re-encrypted")
+
+// TenantKeyInfo is a tenant key without its private half, with the number of
+// snapshots encrypted to it
+type TenantKeyInfo struct {
+	ID            string     `json:"id"`
+	TenantID      string     `json:"tenant_id"`
+	Algorithm     string     `json:"algorithm"`
+	PublicKey     string     `json:"public_key"`
+	KeyStatus     string     `json:"key_status"`
+	RotatedAt     *time.Time `json:"rotated_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	SnapshotCount int        `json:"snapshot_count"`
+}
+
+// RotateTenantKeyRequest is the request to rotate a tenant's key. With
+// reencrypt_snapshots the snapshots rekey_snapshot jobs can move to the new key are
+// queued straight away.
+type RotateTenantKeyRequest struct {
+	ReencryptSnapshots bool `json:"reencrypt_snapshots,omitempty"`
+}
+
+// RotateTenantKeyResult is the new active key and any rekey jobs queued for it
+type RotateTenantKeyResult struct {
+	Key         *TenantKeyInfo `json:"key"`
+	RekeyJobIDs []string       `json:"rekey_job_ids"`
+}
+
+// tenantKeyInfo strips a key of its private half
+func tenantKeyInfo(key *repository.TenantKey, snapshotCount int) *TenantKeyInfo {
+	return &TenantKeyInfo{
+		ID:            key.ID,
+		TenantID:      key.TenantID,
+		Algorithm:     key.Algorithm,
+		PublicKey:     key.PublicKey,
+		KeyStatus:     key.KeyStatus,
+		RotatedAt:     key.RotatedAt,
+		CreatedAt:     key.CreatedAt,
+		SnapshotCount: snapshotCount,
+	}
+}
+
+// ListTenantKeys lists a tenant's keys, the active one first
+func (s *Service) ListTenantKeys(ctx context.Context, tenantID string) ([]*TenantKeyInfo, error) {
+	if _, err := s.repo.GetTenant(ctx, tenantID); err != nil {
+		return nil, err
+	}
+	keys, err := s.repo.ListTenantKeys(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := s.repo.CountSnapshotsByKey(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]*TenantKeyInfo, len(keys))
+	for i, key := range keys {
+		infos[i] = tenantKeyInfo(key, counts[key.ID])
+	}
+	return infos, nil
+}
+
+// RotateTenantKey generates a new key for a tenant and makes it the one new snapshots
+// are encrypted to. The previous key is marked rotated and still decrypts the
+// snapshots encrypted to it.
+func (s *Service) RotateTenantKey(ctx context.Context, tenantID string, req RotateTenantKeyRequest) (*RotateTenantKeyResult, error) {
+	publicKey, privateKey, err := crypto.GenerateX25519KeyPair()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate keypair: %w", err)
+	}
+	encryptedPrivateKey, err := crypto.EncryptForStorage([]byte(privateKey), s.encryptionKEK)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt private key: %w", err)
+	}
+
+	key, err := s.repo.RotateTenantKey(ctx, tenantID, "age-x25519", publicKey, encryptedPrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	s.LogSystemEvent(ctx, "info", fmt.Sprintf("rotated encryption key of tenant %s", tenantID), map[string]any{
+		"tenant_id": tenantID,
+		"key_id":    key.ID,
+	})
+
+	// The key has rotated even if queueing fails; RekeyTenantSnapshots can be rerun
+	result := &RotateTenantKeyResult{Key: tenantKeyInfo(key, 0), RekeyJobIDs: []string{}}
+	if req.ReencryptSnapshots {
+		if result.RekeyJobIDs, err = s.RekeyTenantSnapshots(ctx, tenantID); err != nil {
+			s.LogSystemError(ctx, fmt.Sprintf("failed to queue rekey jobs for tenant %s", tenantID), err, map[string]any{
+				"tenant_id": tenantID,
+				"key_id":    key.ID,
+			})
+		}
+	}
+	return result, nil
+}
+
+// RekeyTenantSnapshots queues a rekey_snapshot job for each of a tenant's snapshots
+// that can be re-encrypted to its active key and is not yet. Returns the job IDs.
+func (s *Service) RekeyTenantSnapshots(ctx context.Context, tenantID string) ([]string, error) {
+	key, err := s.repo.GetActiveTenantKey(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	snapshotIDs, err := s.repo.ListSnapshotsToRekey(ctx, tenantID, key.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	jobIDs := []string{}
+	for _, snapshotID := range snapshotIDs {
+		snapshot, err := s.repo.GetSnapshot(ctx, snapshotID)
+		if err != nil {
+			log.Printf("rekey: failed to get snapshot %s: %v", snapshotID, err)
+			continue
+		}
+		job, err := s.enqueueRekeyJob(ctx, snapshot, key)
+		if err != nil {
+			if !errors.Is(err, ErrSnapshotNotRekeyable) {
+				return jobIDs, err
+			}
+			log.Printf("rekey: skipping snapshot %s: %v", snapshotID, err)
+			continue
+		}
+		jobIDs = append(jobIDs, job.ID)
+	}
+
+	if len(jobIDs) > 0 {
+		s.LogSystemEvent(ctx, "info", fmt.Sprintf("queued %d rekey job(s) for tenant %s", len(jobIDs), tenantID), map[string]any{
+			"tenant_id": tenantID,
+			"key_id":    key.ID,
+		})
+	}
+	return jobIDs, nil
+}
+
+// enqueueRekeyJob creates a rekey_snapshot job for a snapshot, targeted to the worker
+// whose disk holds it. A snapshot with other jobs queued or running is refused, since
+// the rekey swaps the files they read.
+func (s *Service) enqueueRekeyJob(ctx context.Context, snapshot *repository.Snapshot, key *repository.TenantKey) (*repository.Job, error) {
+	if snapshot.Status != "completed" {
+		return nil, fmt.Errorf("%w: status is %s", ErrSnapshotNotRekeyable, snapshot.Status)
+	}
+	if snapshot.StorageBackend != string(types.StorageBackendLocalFS) {
+		return nil, fmt.Errorf("%w: snapshot is in %s", ErrSnapshotNotRekeyable, snapshot.StorageBackend)
+	}
+	if snapshot.WorkerID == nil || *snapshot.WorkerID == "" {
+		return nil, fmt.Errorf("%w: snapshot has no worker_id", ErrSnapshotNotRekeyable)
+	}
+	active, err := s.repo.CountActiveSnapshotJobs(ctx, snapshot.ID)
+	if err != nil {
+		return nil, err
+	}
+	if active > 0 {
+		return nil, fmt.Errorf("%w: %d job(s) queued or running on it", ErrSnapshotNotRekeyable, active)
+	}
+
+	snapshotID := snapshot.ID
+	payloadJSON, err := json.Marshal(types.JobPayload{
+		RekeySnapshotID: &snapshotID,
+		RekeyKeyID:      key.ID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	job, err := s.repo.CreateJobWithTargetWorker(ctx, snapshot.TenantID, types.JobTypeRekeySnapshot,
+		&snapshot.SourceID, *snapshot.WorkerID, payloadJSON, rekeyJobPriority)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create rekey job: %w", err)
+	}
+
+	jobMsg := map[string]any{
+		"job_id":     job.ID,
+		"tenant_id":  snapshot.TenantID,
+		"type":       string(types.JobTypeRekeySnapshot),
+		"priority":   rekeyJobPriority,
+		"created_at": job.CreatedAt.Format(time.RFC3339),
+	}
+	jobMsgJSON, err := json.Marshal(jobMsg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal job message: %w", err)
+	}
+
+	if err := s.redis.LPush(ctx, JobQueueKey, jobMsgJSON).Err(); err != nil {
+		s.LogSystemError(ctx, "Redis: failed to enqueue rekey snapshot job", err, map[string]any{
+			"job_id":      job.ID,
+			"tenant_id":   snapshot.TenantID,
+			"snapshot_id": snapshot.ID,
+		})
+		return nil, fmt.Errorf("failed to enqueue rekey job: %w", err)
+	}
+
+	return job, nil
+}
+
+// recordRekey points a snapshot at the files its rekey_snapshot job re-encrypted. The
+// new manifest is checked like a backup's: its signature against the worker's
+// identity key, and its key against the one the job asked for.
+func (s *Service) recordRekey(ctx context.Context, job *repository.Job, workerID string, result *types.SnapshotRekey) error {
+	var payload types.JobPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return fmt.Errorf("failed to parse rekey job payload: %w", err)
+	}
+	if payload.RekeySnapshotID == nil || *payload.RekeySnapshotID != result.SnapshotID || payload.RekeyKeyID != result.KeyID {
+		return fmt.Errorf("re-encryption is for snapshot %s and key %s, not the job's", result.SnapshotID, result.KeyID)
+	}
+
+	var manifest struct {
+		SnapshotID      string `json:"snapshot_id"`
+		SizeBytes       int64  `json:"size_bytes"`
+		EncryptionKeyID string `json:"encryption_key_id"`
+	}
+	if err := json.Unmarshal(result.ManifestJSON, &manifest); err != nil {
+		return fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	if manifest.EncryptionKeyID != result.KeyID {
+		return fmt.Errorf("manifest names key %s, not %s", manifest.EncryptionKeyID, result.KeyID)
+	}
+	key, err := s.repo.GetTenantKey(ctx, job.TenantID, result.KeyID)
+	if err != nil {
+		return err
+	}
+
+	signingKey := s.manifestSigningKey(ctx, workerID, &types.SnapshotResult{
+		SnapshotID:        result.SnapshotID,
+		ManifestJSON:      result.ManifestJSON,
+		ManifestSignature: result.ManifestSignature,
+	})
+	if err := s.repo.RekeySnapshot(ctx, result.SnapshotID, key, result.ManifestJSON, result.ManifestSignature, signingKey, manifest.SizeBytes, result.Volumes); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("snapshot %s is no longer on worker disk", result.SnapshotID)
+		}
+		return err
+	}
+
+	log.Printf("rekey: snapshot %s re-encrypted to key %s", result.SnapshotID, key.ID)
+	return nil
+}
+
+// snapshotEncryptionKey finds the tenant key a new snapshot's manifest names, by ID
+// or, for workers that predate key rotation, by public key prefix. A key the hub does
+// not know is logged and the snapshot recorded without one.
+func (s *Service) snapshotEncryptionKey(ctx context.Context, tenantID, snapshotID string, manifestJSON json.RawMessage) *repository.TenantKey {
+	var manifest struct {
+		EncryptionKeyID string `json:"encryption_key_id"`
+	}
+	if err := json.Unmarshal(manifestJSON, &manifest); err != nil || manifest.EncryptionKeyID == "" {
+		return nil
+	}
+
+	key, err := s.repo.GetTenantKey(ctx, tenantID, manifest.EncryptionKeyID)
+	if err != nil {
+		s.LogSystemEvent(ctx, "warn", fmt.Sprintf("snapshot %s is encrypted to key %s, which tenant %s does not have", snapshotID, manifest.EncryptionKeyID, tenantID), map[string]any{
+			"snapshot_id": snapshotID,
+			"tenant_id":   tenantID,
+			"error":       err.Error(),
+		})
+		return nil
+	}
+	return key
+}
@@ -0,0 +1,343 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+
+	"xvault/internal/hub/repository"
+	"xvault/pkg/crypto"
+	"xvault/pkg/types"
+)
+
+// ErrNoOffsiteTarget is returned when pushing a snapshot of a tenant without an
+// enabled offsite target
+var ErrNoOffsiteTarget = errors.New("tenant has no enabled offsite target")
+
+// ErrOffsiteTargetInUse is returned when deleting an offsite target that still holds
+// copies of snapshots
+var ErrOffsiteTargetInUse = errors.New("offsite target still holds snapshot copies")
+
+// SetOffsiteTargetRequest is the request to set a tenant's offsite target. The
+// credential may be omitted when replacing a target to keep its current one.
+type SetOffsiteTargetRequest struct {
+	Host          string `json:"host"`
+	Port          int    `json:"port,omitempty"` // 22 when omitted
+	Username      string `json:"username"`
+	Credential    string `json:"credential,omitempty"` // Base64-encoded password or private key
+	UsePrivateKey bool   `json:"use_private_key"`      // True if credential is a private key
+	BasePath      string `json:"base_path,omitempty"`
+	HostKey       string `json:"host_key"`         // authorized_keys format; servers presenting another key are refused
+	Status        string `json:"status,omitempty"` // "enabled" (default) or "disabled"
+}
+
+// validate checks the request and fills in defaults
+func (req *SetOffsiteTargetRequest) validate() error {
+	req.Host = strings.TrimSpace(req.Host)
+	req.Username = strings.TrimSpace(req.Username)
+	if req.Host == "" || req.Username == "" {
+		return fmt.Errorf("host and username are required")
+	}
+	if req.Port == 0 {
+		req.Port = 22
+	}
+	if req.Port < 1 || req.Port > 65535 {
+		return fmt.Errorf("port must be between 1 and 65535")
+	}
+	if strings.ContainsRune(req.BasePath, 0) {
+		return fmt.Errorf("base_path is not a valid path")
+	}
+	req.HostKey = strings.TrimSpace(req.HostKey)
+	if req.HostKey == "" {
+		return fmt.Errorf("host_key is required")
+	}
+	if _, _, _, _, err := ssh.ParseAuthorizedKey([]byte(req.HostKey)); err != nil {
+		return fmt.Errorf("invalid host_key: %w", err)
+	}
+	if req.Status == "" {
+		req.Status = "enabled"
+	}
+	if req.Status != "enabled" && req.Status != "disabled" {
+		return fmt.Errorf("status must be enabled or disabled")
+	}
+	if req.Credential != "" && req.UsePrivateKey {
+		key, err := base64.StdEncoding.DecodeString(req.Credential)
+		if err != nil {
+			return fmt.Errorf("invalid credential encoding: %w", err)
+		}
+		if _, err := ssh.ParsePrivateKey(key); err != nil {
+			return fmt.Errorf("invalid private key: %w", err)
+		}
+	}
+	return nil
+}
+
+// SetOffsiteTarget creates or replaces the offsite target of a tenant. A new
+// credential is stored as a storage credential, and the one it replaces is deleted.
+// When a target that holds copies is moved to another server, account or directory,
+// it is retired rather than changed, so those copies stay reachable where they were
+// pushed; the new location becomes a target of its own.
+func (s *Service) SetOffsiteTarget(ctx context.Context, tenantID string, req SetOffsiteTargetRequest) (*repository.OffsiteTarget, error) {
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+	if _, err := s.repo.GetTenant(ctx, tenantID); err != nil {
+		return nil, err
+	}
+
+	var credentialID string
+	existing, err := s.repo.GetOffsiteTargetForTenant(ctx, tenantID)
+	switch {
+	case err == nil:
+		credentialID = existing.CredentialID
+	case !errors.Is(err, sql.ErrNoRows):
+		return nil, err
+	case req.Credential == "":
+		return nil, fmt.Errorf("credential is required")
+	default:
+		existing = nil
+	}
+
+	retire := false
+	if existing != nil && offsiteLocationChanged(existing, req) {
+		count, err := s.repo.CountOffsiteReplicas(ctx, existing.ID)
+		if err != nil {
+			return nil, err
+		}
+		retire = count > 0
+	}
+
+	if req.Credential != "" {
+		cred, err := s.CreateCredential(ctx, CreateCredentialRequest{
+			TenantID:  tenantID,
+			Kind:      "storage",
+			Plaintext: req.Credential,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create credential: %w", err)
+		}
+		credentialID = cred.ID
+	}
+
+	target := repository.OffsiteTarget{
+		TenantID:      tenantID,
+		CredentialID:  credentialID,
+		Host:          req.Host,
+		Port:          req.Port,
+		Username:      req.Username,
+		UsePrivateKey: req.UsePrivateKey,
+		BasePath:      req.BasePath,
+		HostKey:       &req.HostKey,
+		Status:        req.Status,
+	}
+	var updated *repository.OffsiteTarget
+	if retire {
+		updated, err = s.repo.ReplaceOffsiteTarget(ctx, existing.ID, target)
+	} else {
+		updated, err = s.repo.SetOffsiteTarget(ctx, target)
+	}
+	if err != nil {
+		if req.Credential != "" {
+			s.deleteUnusedCredential(ctx, credentialID)
+		}
+		return nil, err
+	}
+
+	if retire {
+		log.Printf("offsite: retired target %s of tenant %s, which holds copies at %s; new target is %s", existing.ID, tenantID, existing.Host, updated.ID)
+	} else if existing != nil && existing.CredentialID != credentialID {
+		s.deleteUnusedCredential(ctx, existing.CredentialID)
+	}
+	return updated, nil
+}
+
+// offsiteLocationChanged reports whether a request points a target at another server,
+// account or directory than the one its copies were pushed to
+func offsiteLocationChanged(target *repository.OffsiteTarget, req SetOffsiteTargetRequest) bool {
+	return target.Host != req.Host || target.Port != req.Port || target.Username != req.Username || target.BasePath != req.BasePath
+}
+
+// deleteUnusedCredential deletes a credential nothing uses any more. A failure only
+// leaves the ciphertext behind, so it is logged.
+func (s *Service) deleteUnusedCredential(ctx context.Context, credentialID string) {
+	if err := s.repo.DeleteUnusedCredential(ctx, credentialID); err != nil {
+		log.Printf("offsite: failed to delete credential %s: %v", credentialID, err)
+	}
+}
+
+// pruneRetiredOffsiteTargets deletes retired offsite targets whose last copy has been
+// deleted, and their credentials
+func (s *Service) pruneRetiredOffsiteTargets(ctx context.Context) {
+	credentialIDs, err := s.repo.DeleteEmptyRetiredOffsiteTargets(ctx)
+	if err != nil {
+		log.Printf("offsite: failed to delete retired targets: %v", err)
+		return
+	}
+	for _, credentialID := range credentialIDs {
+		s.deleteUnusedCredential(ctx, credentialID)
+	}
+}
+
+// GetOffsiteTarget returns the offsite target of a tenant
+func (s *Service) GetOffsiteTarget(ctx context.Context, tenantID string) (*repository.OffsiteTarget, error) {
+	return s.repo.GetOffsiteTargetForTenant(ctx, tenantID)
+}
+
+// DeleteOffsiteTarget deletes the offsite target of a tenant. A target still holding
+// copies cannot be deleted until their snapshots are; it can be disabled instead.
+func (s *Service) DeleteOffsiteTarget(ctx context.Context, tenantID string) error {
+	target, err := s.repo.GetOffsiteTargetForTenant(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	count, err := s.repo.CountOffsiteReplicas(ctx, target.ID)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("%w: %d copies", ErrOffsiteTargetInUse, count)
+	}
+	if err := s.repo.DeleteOffsiteTarget(ctx, target.ID); err != nil {
+		return err
+	}
+	s.deleteUnusedCredential(ctx, target.CredentialID)
+	return nil
+}
+
+// GetOffsiteTargetAccess returns an offsite target with its decrypted secret, for a
+// worker pushing to it or a restore service reading from it
+func (s *Service) GetOffsiteTargetAccess(ctx context.Context, targetID string) (*types.OffsiteTargetAccess, error) {
+	target, err := s.repo.GetOffsiteTarget(ctx, targetID)
+	if err != nil {
+		return nil, err
+	}
+	cred, err := s.repo.GetCredential(ctx, target.CredentialID)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := crypto.DecryptFromStorage(cred.Ciphertext, s.encryptionKEK)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt credential: %w", err)
+	}
+
+	access := &types.OffsiteTargetAccess{
+		ID:       target.ID,
+		TenantID: target.TenantID,
+		Host:     target.Host,
+		Port:     target.Port,
+		Username: target.Username,
+		BasePath: target.BasePath,
+	}
+	if target.HostKey != nil {
+		access.HostKey = *target.HostKey
+	}
+	if target.UsePrivateKey {
+		access.PrivateKey = string(secret)
+	} else {
+		access.Password = string(secret)
+	}
+	return access, nil
+}
+
+// PushSnapshotOffsite pushes a snapshot to its tenant's offsite target, e.g. one taken
+// before the target was set or whose push failed. Returns the replicate_snapshot job.
+func (s *Service) PushSnapshotOffsite(ctx context.Context, snapshotID string) (*repository.Job, error) {
+	snapshot, err := s.repo.GetSnapshot(ctx, snapshotID)
+	if err != nil {
+		return nil, err
+	}
+	target, err := s.repo.GetOffsiteTargetForTenant(ctx, snapshot.TenantID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoOffsiteTarget
+	}
+	if err != nil {
+		return nil, err
+	}
+	if target.Status != "enabled" {
+		return nil, fmt.Errorf("%w: target is %s", ErrNoOffsiteTarget, target.Status)
+	}
+	return s.pushOffsite(ctx, snapshot, target)
+}
+
+// maybePushOffsite pushes a new snapshot to its tenant's offsite target when one is
+// enabled. Failures are logged: the backup itself succeeded.
+func (s *Service) maybePushOffsite(ctx context.Context, snapshotID string) {
+	snapshot, err := s.repo.GetSnapshot(ctx, snapshotID)
+	if err != nil {
+		log.Printf("offsite: failed to get snapshot %s: %v", snapshotID, err)
+		return
+	}
+	target, err := s.repo.GetOffsiteTargetForTenant(ctx, snapshot.TenantID)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("offsite: failed to get target of tenant %s: %v", snapshot.TenantID, err)
+		}
+		return
+	}
+	if target.Status != "enabled" {
+		return
+	}
+
+	if _, err := s.pushOffsite(ctx, snapshot, target); err != nil {
+		s.LogSystemError(ctx, "Offsite: failed to push new snapshot", err, map[string]any{
+			"snapshot_id":       snapshotID,
+			"tenant_id":         snapshot.TenantID,
+			"offsite_target_id": target.ID,
+		})
+	}
+}
+
+// pushOffsite records a pending copy of the snapshot on the target and creates the
+// replicate_snapshot job that pushes it. The worker that took the snapshot pushes its
+// copy; when it is offline, an online worker holding a replica pushes that instead.
+func (s *Service) pushOffsite(ctx context.Context, snapshot *repository.Snapshot, target *repository.OffsiteTarget) (*repository.Job, error) {
+	if err := checkReplicable(snapshot); err != nil {
+		return nil, err
+	}
+
+	workers, err := s.replicationWorkers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	workerID, locator := *snapshot.WorkerID, snapshot.Locator()
+	if worker, ok := workers[workerID]; !ok || !worker.available {
+		replicas, err := s.repo.ListSnapshotReplicas(ctx, snapshot.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, replica := range replicas {
+			if replica.Status != string(types.ReplicaStatusCompleted) || replica.IsOffsite() {
+				continue
+			}
+			if worker, ok := workers[replica.WorkerID]; ok && worker.available {
+				workerID, locator = replica.WorkerID, replica.Locator()
+				break
+			}
+		}
+	}
+
+	replica, err := s.repo.CreateOffsiteReplica(ctx, snapshot.ID, workerID, target.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshotID := snapshot.ID
+	job, err := s.createReplicateJob(ctx, snapshot, replica.ID, workerID, types.JobPayload{
+		ReplicateSnapshotID:      &snapshotID,
+		ReplicaID:                replica.ID,
+		ReplicateOffsiteTargetID: target.ID,
+		ReplicateLocator:         &locator,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("offsite: pushing snapshot %s from worker %s to %s (job %s)", snapshot.ID, workerID, target.Host, job.ID)
+	return job, nil
+}
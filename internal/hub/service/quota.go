@@ -0,0 +1,290 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"xvault/internal/hub/repository"
+	"xvault/pkg/types"
+)
+
+// ErrQuotaExceeded is returned when a backup or source would take a tenant over its
+// quota and the quota's overage action does not let it through
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// Quota limits reported in TenantQuotaStatus.LimitsReached
+const (
+	QuotaLimitStorage            = "max_storage_bytes"
+	QuotaLimitSources            = "max_sources"
+	QuotaLimitSnapshotsPerSource = "max_snapshots_per_source"
+)
+
+// SetQuotaRequest is the request to set a tenant's or a plan's quota. Omitted limits
+// are unlimited.
+type SetQuotaRequest struct {
+	MaxStorageBytes       *int64                   `json:"max_storage_bytes"`
+	MaxSources            *int                     `json:"max_sources"`
+	MaxSnapshotsPerSource *int                     `json:"max_snapshots_per_source"`
+	OverageAction         types.QuotaOverageAction `json:"overage_action,omitempty"` // Default "reject"
+}
+
+// SetTenantPlanRequest is the request to move a tenant to another plan
+type SetTenantPlanRequest struct {
+	Plan string `json:"plan"`
+}
+
+// TenantQuotaStatus is a tenant's quota next to what it currently stores
+type TenantQuotaStatus struct {
+	TenantID string                  `json:"tenant_id"`
+	Plan     string                  `json:"plan"`
+	Quota    *repository.Quota       `json:"quota"` // nil when no quota applies
+	Usage    *repository.TenantUsage `json:"usage"`
+	// LimitsReached lists the limits usage has reached; new backups or sources that
+	// would go past them are subject to the overage action
+	LimitsReached []string `json:"limits_reached"`
+}
+
+// validate checks the limits and fills in the default overage action
+func (req *SetQuotaRequest) validate() error {
+	if req.MaxStorageBytes != nil && *req.MaxStorageBytes < 0 {
+		return fmt.Errorf("max_storage_bytes cannot be negative")
+	}
+	if req.MaxSources != nil && *req.MaxSources < 0 {
+		return fmt.Errorf("max_sources cannot be negative")
+	}
+	if req.MaxSnapshotsPerSource != nil && *req.MaxSnapshotsPerSource < 1 {
+		return fmt.Errorf("max_snapshots_per_source must be at least 1")
+	}
+	switch req.OverageAction {
+	case "":
+		req.OverageAction = types.QuotaOverageReject
+	case types.QuotaOverageReject, types.QuotaOverageWarn, types.QuotaOverageForceRetention:
+	default:
+		return fmt.Errorf("invalid overage_action: must be reject, warn or force_retention")
+	}
+	return nil
+}
+
+// GetTenantQuotaStatus returns the quota that applies to a tenant and its usage
+func (s *Service) GetTenantQuotaStatus(ctx context.Context, tenantID string) (*TenantQuotaStatus, error) {
+	tenant, err := s.repo.GetTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	quota, err := s.effectiveQuota(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	usage, err := s.repo.GetTenantUsage(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &TenantQuotaStatus{
+		TenantID:      tenant.ID,
+		Plan:          tenant.Plan,
+		Quota:         quota,
+		Usage:         usage,
+		LimitsReached: []string{},
+	}
+	if quota == nil {
+		return status, nil
+	}
+	if quota.MaxStorageBytes != nil && usage.StorageBytes >= *quota.MaxStorageBytes {
+		status.LimitsReached = append(status.LimitsReached, QuotaLimitStorage)
+	}
+	if quota.MaxSources != nil && usage.Sources >= *quota.MaxSources {
+		status.LimitsReached = append(status.LimitsReached, QuotaLimitSources)
+	}
+	if quota.MaxSnapshotsPerSource != nil {
+		for _, source := range usage.BySource {
+			if source.Snapshots >= *quota.MaxSnapshotsPerSource {
+				status.LimitsReached = append(status.LimitsReached, QuotaLimitSnapshotsPerSource)
+				break
+			}
+		}
+	}
+	return status, nil
+}
+
+// ListQuotas lists every tenant and plan quota
+func (s *Service) ListQuotas(ctx context.Context) ([]*repository.Quota, error) {
+	return s.repo.ListQuotas(ctx)
+}
+
+// SetTenantQuota sets a tenant's own quota, which replaces its plan's
+func (s *Service) SetTenantQuota(ctx context.Context, tenantID string, req SetQuotaRequest) (*repository.Quota, error) {
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+	if _, err := s.repo.GetTenant(ctx, tenantID); err != nil {
+		return nil, err
+	}
+	return s.repo.SetTenantQuota(ctx, tenantID, req.MaxStorageBytes, req.MaxSources, req.MaxSnapshotsPerSource, string(req.OverageAction))
+}
+
+// DeleteTenantQuota removes a tenant's own quota, so its plan's applies again
+func (s *Service) DeleteTenantQuota(ctx context.Context, tenantID string) error {
+	return s.repo.DeleteTenantQuota(ctx, tenantID)
+}
+
+// SetPlanQuota sets the quota of every tenant on a plan that has no quota of its own
+func (s *Service) SetPlanQuota(ctx context.Context, plan string, req SetQuotaRequest) (*repository.Quota, error) {
+	if strings.TrimSpace(plan) == "" {
+		return nil, fmt.Errorf("plan is required")
+	}
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+	return s.repo.SetPlanQuota(ctx, plan, req.MaxStorageBytes, req.MaxSources, req.MaxSnapshotsPerSource, string(req.OverageAction))
+}
+
+// DeletePlanQuota removes the quota of a plan
+func (s *Service) DeletePlanQuota(ctx context.Context, plan string) error {
+	return s.repo.DeletePlanQuota(ctx, plan)
+}
+
+// SetTenantPlan moves a tenant to another plan, and so under that plan's quota
+func (s *Service) SetTenantPlan(ctx context.Context, tenantID string, req SetTenantPlanRequest) (*repository.Tenant, error) {
+	plan := strings.TrimSpace(req.Plan)
+	if plan == "" {
+		return nil, fmt.Errorf("plan is required")
+	}
+	return s.repo.UpdateTenantPlan(ctx, tenantID, plan)
+}
+
+// effectiveQuota returns the quota that applies to a tenant, or nil when none does
+func (s *Service) effectiveQuota(ctx context.Context, tenantID string) (*repository.Quota, error) {
+	quota, err := s.repo.GetEffectiveQuota(ctx, tenantID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return quota, nil
+}
+
+// enforceBackupQuota decides whether a tenant may back up a source. A backup is over
+// quota when the tenant's stored bytes or the source's snapshots have already reached
+// their limit, since the new snapshot's size is not known until it completes.
+func (s *Service) enforceBackupQuota(ctx context.Context, tenantID, sourceID string) error {
+	quota, err := s.effectiveQuota(ctx, tenantID)
+	if err != nil || quota == nil {
+		return err
+	}
+	if quota.MaxStorageBytes == nil && quota.MaxSnapshotsPerSource == nil {
+		return nil
+	}
+
+	usage, err := s.repo.GetTenantUsage(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	storage, snapshots := usage.StorageBytes, usage.SourceSnapshots(sourceID)
+	overStorage := quota.MaxStorageBytes != nil && storage >= *quota.MaxStorageBytes
+	overSnapshots := quota.MaxSnapshotsPerSource != nil && snapshots >= *quota.MaxSnapshotsPerSource
+	if !overStorage && !overSnapshots {
+		return nil
+	}
+
+	var reasons []string
+	if overStorage {
+		reasons = append(reasons, fmt.Sprintf("tenant stores %d of %d bytes", storage, *quota.MaxStorageBytes))
+	}
+	if overSnapshots {
+		reasons = append(reasons, fmt.Sprintf("source has %d of %d snapshots", snapshots, *quota.MaxSnapshotsPerSource))
+	}
+	reason := strings.Join(reasons, ", ")
+	details := map[string]any{
+		"tenant_id":      tenantID,
+		"source_id":      sourceID,
+		"quota_id":       quota.ID,
+		"storage_bytes":  storage,
+		"snapshots":      snapshots,
+		"overage_action": quota.OverageAction,
+	}
+
+	switch types.QuotaOverageAction(quota.OverageAction) {
+	case types.QuotaOverageWarn:
+		s.LogSystemEvent(ctx, "warn", fmt.Sprintf("backup over quota: %s", reason), details)
+		return nil
+	case types.QuotaOverageForceRetention:
+		return s.forceQuotaRetention(ctx, quota, tenantID, sourceID, storage, snapshots, reason, details)
+	default:
+		return fmt.Errorf("%w: %s", ErrQuotaExceeded, reason)
+	}
+}
+
+// forceQuotaRetention deletes a tenant's oldest snapshots until a backup of the source
+// fits its quota. Nothing is deleted when the backup cannot be made to fit, because
+// a source's newest snapshot is never a candidate.
+func (s *Service) forceQuotaRetention(ctx context.Context, quota *repository.Quota, tenantID, sourceID string, storage int64, snapshots int, reason string, details map[string]any) error {
+	candidates, err := s.repo.ListQuotaRetentionCandidates(ctx, tenantID, "")
+	if err != nil {
+		return err
+	}
+
+	overStorage := func() bool { return quota.MaxStorageBytes != nil && storage >= *quota.MaxStorageBytes }
+	overSnapshots := func() bool { return quota.MaxSnapshotsPerSource != nil && snapshots >= *quota.MaxSnapshotsPerSource }
+
+	var expired []*repository.QuotaRetentionCandidate
+	for _, candidate := range candidates {
+		if !overStorage() && !overSnapshots() {
+			break
+		}
+		// Other sources' snapshots only free bytes
+		if !overStorage() && candidate.SourceID != sourceID {
+			continue
+		}
+		expired = append(expired, candidate)
+		storage -= candidate.SizeBytes
+		if candidate.SourceID == sourceID {
+			snapshots--
+		}
+	}
+	if overStorage() || overSnapshots() {
+		return fmt.Errorf("%w: %s, and not enough older snapshots can be deleted", ErrQuotaExceeded, reason)
+	}
+
+	for _, candidate := range expired {
+		if _, err := s.EnqueueDeleteJob(ctx, candidate.SnapshotID); err != nil {
+			return fmt.Errorf("failed to delete snapshot %s over quota: %w", candidate.SnapshotID, err)
+		}
+	}
+	details["deleted_snapshots"] = len(expired)
+	s.LogSystemEvent(ctx, "warn", fmt.Sprintf("backup over quota, deleting %d oldest snapshots: %s", len(expired), reason), details)
+	return nil
+}
+
+// enforceSourceQuota decides whether a tenant may create another source. Only the
+// warn overage action lets the tenant go past max_sources; sources are never deleted
+// to make room.
+func (s *Service) enforceSourceQuota(ctx context.Context, tenantID string) error {
+	quota, err := s.effectiveQuota(ctx, tenantID)
+	if err != nil || quota == nil || quota.MaxSources == nil {
+		return err
+	}
+
+	usage, err := s.repo.GetTenantUsage(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	if usage.Sources < *quota.MaxSources {
+		return nil
+	}
+
+	reason := fmt.Sprintf("tenant has %d of %d sources", usage.Sources, *quota.MaxSources)
+	if types.QuotaOverageAction(quota.OverageAction) == types.QuotaOverageWarn {
+		s.LogSystemEvent(ctx, "warn", fmt.Sprintf("source over quota: %s", reason), map[string]any{
+			"tenant_id": tenantID,
+			"quota_id":  quota.ID,
+		})
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrQuotaExceeded, reason)
+}
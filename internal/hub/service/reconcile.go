@@ -0,0 +1,269 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"xvault/internal/hub/repository"
+	"xvault/pkg/types"
+
+	"github.com/google/uuid"
+)
+
+// ErrNoOrphans is returned when a quarantine or purge request matches no orphaned
+// snapshot directory that is still without a record
+var ErrNoOrphans = errors.New("no orphaned snapshot directories to act on")
+
+// EnqueueStorageReconcile creates a reconcile_storage job that inventories a
+// worker's snapshot directories; the report is stored when the job completes
+func (s *Service) EnqueueStorageReconcile(ctx context.Context, workerID string) (*repository.Job, error) {
+	if _, err := s.repo.GetWorker(ctx, workerID); err != nil {
+		return nil, fmt.Errorf("failed to get worker: %w", err)
+	}
+	return s.enqueueReconcileJob(ctx, workerID, types.JobPayload{ReconcileAction: types.ReconcileActionInventory})
+}
+
+// ResolveOrphansRequest is the request to quarantine or purge orphaned directories
+type ResolveOrphansRequest struct {
+	Action      types.ReconcileAction `json:"action"`                 // quarantine or purge
+	SnapshotIDs []string              `json:"snapshot_ids,omitempty"` // default: every orphan in the report
+}
+
+// ResolveOrphans creates a reconcile_storage job that quarantines or purges the
+// orphaned directories of a reconciliation report. snapshotIDs limits the job to
+// those directories; empty means every orphan in the report. Directories that have
+// gained a snapshot record since the report are skipped. Returns the job and the
+// directories it will act on.
+func (s *Service) ResolveOrphans(ctx context.Context, reconciliationID string, action types.ReconcileAction, snapshotIDs []string) (*repository.Job, []types.StorageInventoryEntry, error) {
+	if action != types.ReconcileActionQuarantine && action != types.ReconcileActionPurge {
+		return nil, nil, fmt.Errorf("invalid action %q: must be quarantine or purge", action)
+	}
+
+	rec, err := s.repo.GetStorageReconciliation(ctx, reconciliationID)
+	if err != nil {
+		return nil, nil, err
+	}
+	var report types.StorageReconciliation
+	if err := json.Unmarshal(rec.Report, &report); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse reconciliation report: %w", err)
+	}
+
+	wanted := make(map[string]bool, len(snapshotIDs))
+	for _, id := range snapshotIDs {
+		wanted[normalizeSnapshotID(id)] = true
+	}
+	var candidates []types.StorageInventoryEntry
+	for _, orphan := range report.Orphaned {
+		if len(wanted) == 0 || wanted[normalizeSnapshotID(orphan.SnapshotID)] {
+			candidates = append(candidates, orphan)
+		}
+	}
+
+	targets, err := s.unrecorded(ctx, candidates)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(targets) == 0 {
+		return nil, nil, ErrNoOrphans
+	}
+
+	job, err := s.enqueueReconcileJob(ctx, rec.WorkerID, types.JobPayload{
+		ReconcileAction:  action,
+		ReconcileTargets: targets,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return job, targets, nil
+}
+
+// ListStorageReconciliations lists reconciliation summaries, optionally for one worker
+func (s *Service) ListStorageReconciliations(ctx context.Context, workerID string, limit int) ([]*repository.StorageReconciliation, error) {
+	return s.repo.ListStorageReconciliations(ctx, workerID, limit)
+}
+
+// GetStorageReconciliation retrieves a reconciliation with its full report
+func (s *Service) GetStorageReconciliation(ctx context.Context, id string) (*repository.StorageReconciliation, error) {
+	return s.repo.GetStorageReconciliation(ctx, id)
+}
+
+// enqueueReconcileJob creates a reconcile_storage job for a worker and pushes it to
+// the job queue
+func (s *Service) enqueueReconcileJob(ctx context.Context, workerID string, payload types.JobPayload) (*repository.Job, error) {
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	job, err := s.repo.CreateWorkerJob(ctx, types.JobTypeReconcileStorage, workerID, payloadJSON, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create reconcile job: %w", err)
+	}
+
+	jobMsg := map[string]any{
+		"job_id":     job.ID,
+		"worker_id":  workerID,
+		"type":       string(types.JobTypeReconcileStorage),
+		"priority":   job.Priority,
+		"created_at": job.CreatedAt.Format(time.RFC3339),
+	}
+	jobMsgJSON, err := json.Marshal(jobMsg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal job message: %w", err)
+	}
+
+	if err := s.redis.LPush(ctx, JobQueueKey, jobMsgJSON).Err(); err != nil {
+		s.LogSystemError(ctx, "Redis: failed to enqueue reconcile storage job", err, map[string]any{
+			"job_id":    job.ID,
+			"worker_id": workerID,
+		})
+		return nil, fmt.Errorf("failed to enqueue reconcile job: %w", err)
+	}
+
+	return job, nil
+}
+
+// recordReconciliation diffs a worker's inventory against the snapshot records that
+// place snapshots on it, stores the report and flags the integrity of records whose
+// files are gone or the wrong size
+func (s *Service) recordReconciliation(ctx context.Context, job *repository.Job, workerID string, inventory *types.StorageInventory) error {
+	records, err := s.repo.ListLocalSnapshotsForWorker(ctx, workerID)
+	if err != nil {
+		return err
+	}
+
+	report := diffStorage(records, inventory.Entries)
+	report.WorkerID = workerID
+	report.JobID = job.ID
+
+	// Workers may share a storage volume, so a directory is only orphaned if no
+	// worker has a record of it
+	if report.Orphaned, err = s.unrecorded(ctx, report.Orphaned); err != nil {
+		return err
+	}
+
+	rec, err := s.repo.CreateStorageReconciliation(ctx, report)
+	if err != nil {
+		return err
+	}
+
+	// A damaged replica is marked failed; the snapshot's other copies are unaffected
+	for _, missing := range report.Missing {
+		problem := "snapshot directory not found on worker"
+		if missing.ReplicaID != "" {
+			err = s.repo.FailSnapshotReplica(ctx, missing.ReplicaID, problem)
+		} else {
+			err = s.repo.UpdateSnapshotIntegrity(ctx, missing.SnapshotID, string(types.SnapshotIntegrityMissing), problem)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for _, mismatch := range report.SizeMismatches {
+		problem := fmt.Sprintf("artifact has %d bytes on worker, %d recorded", mismatch.StoredBytes, mismatch.RecordedBytes)
+		if mismatch.ReplicaID != "" {
+			err = s.repo.FailSnapshotReplica(ctx, mismatch.ReplicaID, problem)
+		} else {
+			err = s.repo.UpdateSnapshotIntegrity(ctx, mismatch.SnapshotID, string(types.SnapshotIntegrityIncomplete), problem)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	level := "info"
+	if rec.MissingCount+rec.OrphanedCount+rec.SizeMismatchCount > 0 {
+		level = "warn"
+	}
+	s.LogSystemEvent(ctx, level, fmt.Sprintf("storage reconciliation of worker %s: %d missing, %d orphaned, %d size mismatches",
+		workerID, rec.MissingCount, rec.OrphanedCount, rec.SizeMismatchCount), map[string]any{
+		"worker_id":         workerID,
+		"job_id":            job.ID,
+		"reconciliation_id": rec.ID,
+	})
+	return nil
+}
+
+// diffStorage compares snapshot records with the directories found on disk.
+// Directories carry snapshot IDs without dashes, so IDs are compared normalized.
+func diffStorage(records []*repository.StoredSnapshot, entries []types.StorageInventoryEntry) *types.StorageReconciliation {
+	report := &types.StorageReconciliation{
+		InventoryCount: len(entries),
+		Missing:        []types.ReconcileMissing{},
+		Orphaned:       []types.StorageInventoryEntry{},
+		SizeMismatches: []types.ReconcileSizeMismatch{},
+	}
+
+	onDisk := make(map[string]types.StorageInventoryEntry, len(entries))
+	for _, entry := range entries {
+		onDisk[normalizeSnapshotID(entry.SnapshotID)] = entry
+	}
+
+	recorded := make(map[string]bool, len(records))
+	for _, snap := range records {
+		id := normalizeSnapshotID(snap.ID)
+		recorded[id] = true
+
+		entry, ok := onDisk[id]
+		if !ok {
+			report.Missing = append(report.Missing, types.ReconcileMissing{
+				SnapshotID: snap.ID,
+				TenantID:   snap.TenantID,
+				SourceID:   snap.SourceID,
+				LocalPath:  snap.LocalPath,
+				ReplicaID:  snap.ReplicaID,
+			})
+			continue
+		}
+		// Repository snapshots record what they newly stored, not a file size
+		if snap.StorageMode != types.StorageModeRepository && entry.ArtifactBytes != snap.SizeBytes {
+			report.SizeMismatches = append(report.SizeMismatches, types.ReconcileSizeMismatch{
+				SnapshotID:    snap.ID,
+				LocalPath:     entry.LocalPath,
+				RecordedBytes: snap.SizeBytes,
+				StoredBytes:   entry.ArtifactBytes,
+				ReplicaID:     snap.ReplicaID,
+			})
+		}
+	}
+
+	for _, entry := range entries {
+		if !recorded[normalizeSnapshotID(entry.SnapshotID)] {
+			report.Orphaned = append(report.Orphaned, entry)
+		}
+	}
+
+	return report
+}
+
+// unrecorded returns the entries whose snapshot ID has no snapshot record at all.
+// Directory names that are not UUIDs cannot have one.
+func (s *Service) unrecorded(ctx context.Context, entries []types.StorageInventoryEntry) ([]types.StorageInventoryEntry, error) {
+	var ids []string
+	for _, entry := range entries {
+		if _, err := uuid.Parse(entry.SnapshotID); err == nil {
+			ids = append(ids, entry.SnapshotID)
+		}
+	}
+	recorded, err := s.repo.RecordedSnapshotIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []types.StorageInventoryEntry{}
+	for _, entry := range entries {
+		if !recorded[entry.SnapshotID] {
+			result = append(result, entry)
+		}
+	}
+	return result, nil
+}
+
+// normalizeSnapshotID drops the dashes of a UUID so hub IDs match directory names
+func normalizeSnapshotID(id string) string {
+	return strings.ToLower(strings.ReplaceAll(id, "-", ""))
+}
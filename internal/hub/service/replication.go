@@ -0,0 +1,521 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"xvault/internal/hub/repository"
+	"xvault/pkg/types"
+)
+
+// replicateJobPriority queues replication alongside regular backups
+const replicateJobPriority = 0
+
+// workerOfflineAfter is how long without a heartbeat before a worker is not
+// considered for holding or serving copies
+const workerOfflineAfter = 2 * time.Minute
+
+// ErrSnapshotNotReplicable is returned when a snapshot cannot be replicated: it did
+// not complete, is stored in a chunk repository, or no policy covers it
+var ErrSnapshotNotReplicable = errors.New("snapshot cannot be replicated")
+
+// ErrNoReplicationSource is returned when no online worker with a replication server
+// holds a copy of the snapshot to copy from
+var ErrNoReplicationSource = errors.New("no online worker can serve a copy of the snapshot")
+
+// workerCapabilities is the part of a worker's registered capabilities replication
+// and tiering read
+type workerCapabilities struct {
+	Storage        []string `json:"storage"`
+	ReplicationURL string   `json:"replication_url"`
+	// Backends the worker can move the snapshots on its disk to
+	TierStorage []string `json:"tier_storage"`
+}
+
+// replicationWorker is a worker considered for holding or serving a copy
+type replicationWorker struct {
+	*repository.Worker
+	capabilities workerCapabilities
+	available    bool
+}
+
+// storageBackend is the backend the worker stores snapshots in
+func (w *replicationWorker) storageBackend() types.StorageBackend {
+	for _, backend := range w.capabilities.Storage {
+		if backend == string(types.StorageBackendS3) {
+			return types.StorageBackendS3
+		}
+	}
+	return types.StorageBackendLocalFS
+}
+
+// snapshotCopy is one copy of a snapshot: the original or a replica
+type snapshotCopy struct {
+	workerID  string
+	backend   types.StorageBackend
+	completed bool
+}
+
+// ReplicateSnapshot tops up the copies of a snapshot to what its replication policy
+// asks for, e.g. after a worker holding one went away. Returns the replicate_snapshot
+// jobs created, none when the snapshot already has enough copies.
+func (s *Service) ReplicateSnapshot(ctx context.Context, snapshotID string) ([]*repository.Job, error) {
+	snapshot, err := s.repo.GetSnapshot(ctx, snapshotID)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := s.repo.GetReplicationPolicyForSource(ctx, snapshot.TenantID, snapshot.SourceID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: no replication policy covers source %s", ErrSnapshotNotReplicable, snapshot.SourceID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get replication policy: %w", err)
+	}
+	return s.replicate(ctx, snapshot, policy)
+}
+
+// maybeReplicateSnapshot replicates a new snapshot when a policy covers its source.
+// Failures are logged: the backup itself succeeded.
+func (s *Service) maybeReplicateSnapshot(ctx context.Context, snapshotID string) {
+	snapshot, err := s.repo.GetSnapshot(ctx, snapshotID)
+	if err != nil {
+		log.Printf("replication: failed to get snapshot %s: %v", snapshotID, err)
+		return
+	}
+	policy, err := s.repo.GetReplicationPolicyForSource(ctx, snapshot.TenantID, snapshot.SourceID)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("replication: failed to get policy for source %s: %v", snapshot.SourceID, err)
+		}
+		return
+	}
+
+	if _, err := s.replicate(ctx, snapshot, policy); err != nil {
+		s.LogSystemError(ctx, "Replication: failed to replicate new snapshot", err, map[string]any{
+			"snapshot_id": snapshotID,
+			"tenant_id":   snapshot.TenantID,
+			"source_id":   snapshot.SourceID,
+			"policy_id":   policy.ID,
+		})
+	}
+}
+
+// replicate creates replicate_snapshot jobs for the copies the policy is short of.
+// Copies on workers that stopped sending heartbeats do not count, except object
+// store copies, which outlive their worker. New copies go to online workers without
+// one, object store workers first when the policy wants an object store copy.
+func (s *Service) replicate(ctx context.Context, snapshot *repository.Snapshot, policy *repository.ReplicationPolicy) ([]*repository.Job, error) {
+	if err := checkReplicable(snapshot); err != nil {
+		return nil, err
+	}
+
+	workers, err := s.replicationWorkers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	replicas, err := s.repo.ListSnapshotReplicas(ctx, snapshot.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Offsite copies belong to the tenant and do not count towards the policy
+	copies := []snapshotCopy{{workerID: *snapshot.WorkerID, backend: types.StorageBackend(snapshot.StorageBackend), completed: true}}
+	for _, replica := range replicas {
+		if replica.Status != string(types.ReplicaStatusFailed) && !replica.IsOffsite() {
+			copies = append(copies, snapshotCopy{
+				workerID:  replica.WorkerID,
+				backend:   types.StorageBackend(replica.StorageBackend),
+				completed: replica.Status == string(types.ReplicaStatusCompleted),
+			})
+		}
+	}
+
+	holders := make(map[string]bool)
+	live, hasObjectCopy := 0, false
+	var source *replicationWorker
+	for _, c := range copies {
+		holders[c.workerID] = true
+		worker, ok := workers[c.workerID]
+		available := ok && worker.available
+		if available || c.backend == types.StorageBackendS3 {
+			live++
+			hasObjectCopy = hasObjectCopy || c.backend == types.StorageBackendS3
+		}
+		if source == nil && c.completed && available && worker.capabilities.ReplicationURL != "" {
+			source = worker
+		}
+	}
+
+	needed := policy.Copies - live
+	wantObjectCopy := policy.ObjectStoreCopy && !hasObjectCopy
+	if wantObjectCopy && needed < 1 {
+		needed = 1
+	}
+	if needed <= 0 {
+		return nil, nil
+	}
+	if source == nil {
+		return nil, ErrNoReplicationSource
+	}
+
+	var candidates []*replicationWorker
+	for _, worker := range workers {
+		if worker.available && !holders[worker.ID] {
+			candidates = append(candidates, worker)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if wantObjectCopy {
+			iS3 := candidates[i].storageBackend() == types.StorageBackendS3
+			jS3 := candidates[j].storageBackend() == types.StorageBackendS3
+			if iS3 != jS3 {
+				return iS3
+			}
+		}
+		return candidates[i].Name < candidates[j].Name
+	})
+	if wantObjectCopy && (len(candidates) == 0 || candidates[0].storageBackend() != types.StorageBackendS3) {
+		s.LogSystemEvent(ctx, "warn", fmt.Sprintf("no online object store worker can take a copy of snapshot %s", snapshot.ID), map[string]any{
+			"snapshot_id": snapshot.ID,
+			"policy_id":   policy.ID,
+		})
+	}
+	if len(candidates) < needed {
+		s.LogSystemEvent(ctx, "warn", fmt.Sprintf("snapshot %s needs %d more copies but only %d workers can take one", snapshot.ID, needed, len(candidates)), map[string]any{
+			"snapshot_id": snapshot.ID,
+			"policy_id":   policy.ID,
+		})
+		needed = len(candidates)
+	}
+
+	var jobs []*repository.Job
+	for _, destination := range candidates[:needed] {
+		job, err := s.enqueueReplicateJob(ctx, snapshot, destination, source)
+		if err != nil {
+			return jobs, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// checkReplicable returns an error wrapping ErrSnapshotNotReplicable unless the
+// snapshot's files can be copied elsewhere
+func checkReplicable(snapshot *repository.Snapshot) error {
+	if snapshot.Status != "completed" {
+		return fmt.Errorf("%w: status is %s", ErrSnapshotNotReplicable, snapshot.Status)
+	}
+	if snapshot.WorkerID == nil || *snapshot.WorkerID == "" {
+		return fmt.Errorf("%w: snapshot has no worker_id", ErrSnapshotNotReplicable)
+	}
+	var manifest struct {
+		StorageMode types.StorageMode `json:"storage_mode"`
+	}
+	if err := json.Unmarshal(snapshot.ManifestJSON, &manifest); err == nil && manifest.StorageMode == types.StorageModeRepository {
+		return fmt.Errorf("%w: repository snapshots share chunks with the tenant's other snapshots", ErrSnapshotNotReplicable)
+	}
+	return nil
+}
+
+// replicationWorkers returns every registered worker by ID
+func (s *Service) replicationWorkers(ctx context.Context) (map[string]*replicationWorker, error) {
+	workers, err := s.repo.ListWorkers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]*replicationWorker, len(workers))
+	for _, worker := range workers {
+		w := &replicationWorker{Worker: worker}
+		json.Unmarshal(worker.Capabilities, &w.capabilities)
+		w.available = worker.Status == "online" && worker.LastSeenAt != nil && time.Since(*worker.LastSeenAt) < workerOfflineAfter
+		result[worker.ID] = w
+	}
+	return result, nil
+}
+
+// enqueueReplicateJob records a pending replica on destination and creates the job
+// that makes it, targeted to destination. The job carries a one-off token that
+// source's replication server checks with the hub before serving the snapshot.
+func (s *Service) enqueueReplicateJob(ctx context.Context, snapshot *repository.Snapshot, destination, source *replicationWorker) (*repository.Job, error) {
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return nil, fmt.Errorf("failed to generate transfer token: %w", err)
+	}
+	token := hex.EncodeToString(tokenBytes)
+
+	replica, err := s.repo.CreateSnapshotReplica(ctx, snapshot.ID, destination.ID, source.ID, destination.storageBackend(), hashTransferToken(token))
+	if err != nil {
+		return nil, err
+	}
+
+	snapshotID := snapshot.ID
+	job, err := s.createReplicateJob(ctx, snapshot, replica.ID, destination.ID, types.JobPayload{
+		ReplicateSnapshotID: &snapshotID,
+		ReplicaID:           replica.ID,
+		ReplicateSourceURL:  source.capabilities.ReplicationURL,
+		ReplicateToken:      token,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("replication: copying snapshot %s from worker %s to worker %s (job %s)", snapshot.ID, source.ID, destination.ID, job.ID)
+	return job, nil
+}
+
+// createReplicateJob creates and queues the replicate_snapshot job that makes a
+// replica, targeted to workerID
+func (s *Service) createReplicateJob(ctx context.Context, snapshot *repository.Snapshot, replicaID, workerID string, payload types.JobPayload) (*repository.Job, error) {
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	job, err := s.repo.CreateJobWithTargetWorker(ctx, snapshot.TenantID, types.JobTypeReplicateSnapshot,
+		&snapshot.SourceID, workerID, payloadJSON, replicateJobPriority)
+	if err != nil {
+		s.repo.FailSnapshotReplica(ctx, replicaID, "failed to create replicate job")
+		return nil, fmt.Errorf("failed to create replicate job: %w", err)
+	}
+	if err := s.repo.SetSnapshotReplicaJob(ctx, replicaID, job.ID); err != nil {
+		return nil, err
+	}
+
+	jobMsg := map[string]any{
+		"job_id":     job.ID,
+		"tenant_id":  snapshot.TenantID,
+		"type":       string(types.JobTypeReplicateSnapshot),
+		"priority":   replicateJobPriority,
+		"created_at": job.CreatedAt.Format(time.RFC3339),
+	}
+	jobMsgJSON, err := json.Marshal(jobMsg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal job message: %w", err)
+	}
+
+	if err := s.redis.LPush(ctx, JobQueueKey, jobMsgJSON).Err(); err != nil {
+		s.LogSystemError(ctx, "Redis: failed to enqueue replicate snapshot job", err, map[string]any{
+			"job_id":      job.ID,
+			"tenant_id":   snapshot.TenantID,
+			"snapshot_id": snapshot.ID,
+		})
+		return nil, fmt.Errorf("failed to enqueue replicate job: %w", err)
+	}
+	return job, nil
+}
+
+// hashTransferToken is how transfer tokens are stored
+func hashTransferToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// AuthorizeReplicaTransfer checks a transfer token presented to a worker's
+// replication server and returns the copy of the snapshot that worker may serve
+// with it
+func (s *Service) AuthorizeReplicaTransfer(ctx context.Context, req types.ReplicaTransferRequest) (*types.ReplicaTransfer, error) {
+	replica, err := s.repo.GetPendingReplicaByTransferToken(ctx, req.WorkerID, hashTransferToken(req.Token))
+	if err != nil {
+		return nil, err
+	}
+	snapshot, err := s.repo.GetSnapshot(ctx, replica.SnapshotID)
+	if err != nil {
+		return nil, err
+	}
+
+	transfer := &types.ReplicaTransfer{
+		ReplicaID:  replica.ID,
+		TenantID:   snapshot.TenantID,
+		SourceID:   snapshot.SourceID,
+		SnapshotID: snapshot.ID,
+	}
+	if snapshot.WorkerID != nil && *snapshot.WorkerID == req.WorkerID {
+		transfer.Locator = snapshot.Locator()
+		return transfer, nil
+	}
+
+	replicas, err := s.repo.ListSnapshotReplicas(ctx, snapshot.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, held := range replicas {
+		if held.WorkerID == req.WorkerID && held.Status == string(types.ReplicaStatusCompleted) && !held.IsOffsite() {
+			transfer.Locator = held.Locator()
+			return transfer, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// recordReplica stores the result of a replicate_snapshot job. A copy finished after
+// its snapshot was deleted is deleted in turn.
+func (s *Service) recordReplica(ctx context.Context, job *repository.Job, status types.JobStatus, errorMsg string, result *types.ReplicaResult) error {
+	var payload types.JobPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return fmt.Errorf("failed to parse replicate job payload: %w", err)
+	}
+	if payload.ReplicaID == "" {
+		return fmt.Errorf("replicate job has no replica_id")
+	}
+
+	if status != types.JobStatusCompleted || result == nil {
+		if errorMsg == "" {
+			errorMsg = "replicate job reported no replica"
+		}
+		return s.repo.FailSnapshotReplica(ctx, payload.ReplicaID, errorMsg)
+	}
+	if result.ReplicaID != payload.ReplicaID {
+		return fmt.Errorf("result is for replica %s, not the job's", result.ReplicaID)
+	}
+
+	err := s.repo.CompleteSnapshotReplica(ctx, payload.ReplicaID, result.Locator)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	// The replica is gone: its snapshot was deleted while the copy was made
+	if job.TargetWorkerID == nil || payload.ReplicateSnapshotID == nil || job.SourceID == nil {
+		return nil
+	}
+	log.Printf("replication: snapshot %s was deleted during its copy to worker %s, deleting the copy", *payload.ReplicateSnapshotID, *job.TargetWorkerID)
+	_, err = s.enqueueDelete(ctx, job.TenantID, *job.SourceID, *payload.ReplicateSnapshotID, *job.TargetWorkerID, result.Locator, nil)
+	return err
+}
+
+// reportReplicaIntegrity marks the replica a worker holds of a snapshot failed when
+// the worker reports its files damaged
+func (s *Service) reportReplicaIntegrity(ctx context.Context, snapshot *repository.Snapshot, req types.SnapshotIntegrityRequest) (bool, error) {
+	replicas, err := s.repo.ListSnapshotReplicas(ctx, snapshot.ID)
+	if err != nil {
+		return false, err
+	}
+	for _, replica := range replicas {
+		if replica.WorkerID != req.WorkerID || replica.IsOffsite() {
+			continue
+		}
+		if err := s.repo.FailSnapshotReplica(ctx, replica.ID, fmt.Sprintf("%s: %s", req.Status, req.Error)); err != nil {
+			return false, err
+		}
+		details, _ := json.Marshal(map[string]any{
+			"replica_id":       replica.ID,
+			"integrity_status": req.Status,
+			"error":            req.Error,
+		})
+		if err := s.repo.CreateLog(ctx, "warn", "Snapshot replica failed integrity check on worker", &req.WorkerID, &snapshot.JobID, &snapshot.ID, &snapshot.SourceID, nil, details); err != nil {
+			log.Printf("failed to log replica integrity report: %v", err)
+		}
+		return true, nil
+	}
+	return false, ErrSnapshotWorkerMismatch
+}
+
+// ListSnapshotReplicas lists the replicas of a snapshot
+func (s *Service) ListSnapshotReplicas(ctx context.Context, snapshotID string) ([]*repository.SnapshotReplica, error) {
+	return s.repo.ListSnapshotReplicas(ctx, snapshotID)
+}
+
+// Replication policies
+
+// CreateReplicationPolicyRequest is the request to create a replication policy.
+// Without a source_id the policy covers every source of the tenant that has no
+// policy of its own.
+type CreateReplicationPolicyRequest struct {
+	TenantID        string  `json:"tenant_id"`
+	SourceID        *string `json:"source_id,omitempty"`
+	Copies          int     `json:"copies"`
+	ObjectStoreCopy bool    `json:"object_store_copy"`
+}
+
+// CreateReplicationPolicy creates a replication policy. It applies to snapshots
+// completed from then on; older ones are replicated with ReplicateSnapshot.
+func (s *Service) CreateReplicationPolicy(ctx context.Context, req CreateReplicationPolicyRequest) (*repository.ReplicationPolicy, error) {
+	if req.Copies < 1 {
+		return nil, fmt.Errorf("copies must be at least 1")
+	}
+	if _, err := s.repo.GetTenant(ctx, req.TenantID); err != nil {
+		return nil, fmt.Errorf("tenant not found: %w", err)
+	}
+	if req.SourceID != nil {
+		source, err := s.repo.GetSource(ctx, *req.SourceID)
+		if err != nil {
+			return nil, fmt.Errorf("source not found: %w", err)
+		}
+		if source.TenantID != req.TenantID {
+			return nil, fmt.Errorf("source does not belong to tenant")
+		}
+	}
+
+	policy, err := s.repo.CreateReplicationPolicy(ctx, req.TenantID, req.SourceID, req.Copies, req.ObjectStoreCopy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create replication policy: %w", err)
+	}
+	return policy, nil
+}
+
+// UpdateReplicationPolicyRequest is the request to update a replication policy
+type UpdateReplicationPolicyRequest struct {
+	Copies          *int    `json:"copies,omitempty"`
+	ObjectStoreCopy *bool   `json:"object_store_copy,omitempty"`
+	Status          *string `json:"status,omitempty"` // "enabled" or "disabled"
+}
+
+// UpdateReplicationPolicy updates a replication policy. Lowering copies does not
+// delete replicas already made.
+func (s *Service) UpdateReplicationPolicy(ctx context.Context, policyID string, req UpdateReplicationPolicyRequest) (*repository.ReplicationPolicy, error) {
+	existing, err := s.repo.GetReplicationPolicy(ctx, policyID)
+	if err != nil {
+		return nil, fmt.Errorf("replication policy not found: %w", err)
+	}
+
+	copies := existing.Copies
+	if req.Copies != nil {
+		if *req.Copies < 1 {
+			return nil, fmt.Errorf("copies must be at least 1")
+		}
+		copies = *req.Copies
+	}
+
+	objectStoreCopy := existing.ObjectStoreCopy
+	if req.ObjectStoreCopy != nil {
+		objectStoreCopy = *req.ObjectStoreCopy
+	}
+
+	status := existing.Status
+	if req.Status != nil {
+		if *req.Status != "enabled" && *req.Status != "disabled" {
+			return nil, fmt.Errorf("status must be enabled or disabled")
+		}
+		status = *req.Status
+	}
+
+	policy, err := s.repo.UpdateReplicationPolicy(ctx, policyID, copies, objectStoreCopy, status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update replication policy: %w", err)
+	}
+	return policy, nil
+}
+
+// GetReplicationPolicy retrieves a replication policy by ID
+func (s *Service) GetReplicationPolicy(ctx context.Context, policyID string) (*repository.ReplicationPolicy, error) {
+	return s.repo.GetReplicationPolicy(ctx, policyID)
+}
+
+// ListReplicationPolicies lists replication policies, optionally for a single tenant
+func (s *Service) ListReplicationPolicies(ctx context.Context, tenantID string) ([]*repository.ReplicationPolicy, error) {
+	return s.repo.ListReplicationPolicies(ctx, tenantID)
+}
+
+// DeleteReplicationPolicy deletes a replication policy
+func (s *Service) DeleteReplicationPolicy(ctx context.Context, policyID string) error {
+	return s.repo.DeleteReplicationPolicy(ctx, policyID)
+}
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"net/http"
 	"path/filepath"
 	"strings"
 	"time"
@@ -30,6 +31,9 @@ const (
 	JobQueueKey = "xvault:jobs:queue"
 	// JobLeaseDuration is how long a worker has to complete a job
 	JobLeaseDuration = 30 * time.Minute
+	// JobReleaseBackoff is how long a worker that released a job waits before it may
+	// claim that job again, so other workers get the first chance at it
+	JobReleaseBackoff = 15 * time.Minute
 )
 
 // Service handles business logic for the Hub
@@ -37,6 +41,8 @@ type Service struct {
 	repo          *repository.Repository
 	redis         *redis.Client
 	encryptionKEK string
+	restoreURL    string // base URL of the restore service, for file listings
+	httpClient    *http.Client
 }
 
 // NewService creates a new service instance
@@ -45,6 +51,7 @@ func NewService(repo *repository.Repository, redis *redis.Client, encryptionKEK
 		repo:          repo,
 		redis:         redis,
 		encryptionKEK: encryptionKEK,
+		httpClient:    &http.Client{Timeout: 30 * time.Second},
 	}
 }
 
@@ -160,6 +167,13 @@ type CreateSourceRequest struct {
 
 // CreateSource creates a new backup source
 func (s *Service) CreateSource(ctx context.Context, req CreateSourceRequest) (*repository.Source, error) {
+	if err := validateSourceConfig(req.Config); err != nil {
+		return nil, err
+	}
+	if err := s.enforceSourceQuota(ctx, req.TenantID); err != nil {
+		return nil, err
+	}
+
 	source, err := s.repo.CreateSource(ctx, req.TenantID, req.Type, req.Name, req.CredentialID, req.Config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create source: %w", err)
@@ -168,6 +182,44 @@ func (s *Service) CreateSource(ctx context.Context, req CreateSourceRequest) (*r
 	return source, nil
 }
 
+// validateSourceConfig checks the settings shared by all source types
+func validateSourceConfig(config json.RawMessage) error {
+	if len(config) == 0 {
+		return nil
+	}
+
+	var common struct {
+		Compression *types.CompressionConfig `json:"compression"`
+		Paths       []string                 `json:"paths"`
+		PathAliases map[string]string        `json:"path_aliases"`
+		types.FileFilter
+	}
+	if err := json.Unmarshal(config, &common); err != nil {
+		return fmt.Errorf("invalid source config: %w", err)
+	}
+	if common.Compression != nil {
+		if err := common.Compression.Validate(); err != nil {
+			return err
+		}
+	}
+	if err := common.FileFilter.Validate(); err != nil {
+		return err
+	}
+
+	// Relative paths are resolved on the source, so only absolute ones can be checked
+	// for overlap here; the worker checks again once they are resolved
+	if err := types.ValidatePathAliases(common.Paths, common.PathAliases); err != nil {
+		return err
+	}
+	var roots []types.SourceRoot
+	for _, p := range common.Paths {
+		if alias := common.PathAliases[p]; alias != "" || strings.HasPrefix(p, "/") {
+			roots = append(roots, types.SourceRoot{Path: p, ArchivePath: types.ArchivePathFor(p, alias)})
+		}
+	}
+	return types.ValidateSourceRoots(roots)
+}
+
 // GetSource retrieves a source by ID
 func (s *Service) GetSource(ctx context.Context, sourceID string) (*repository.Source, error) {
 	source, err := s.repo.GetSource(ctx, sourceID)
@@ -190,8 +242,9 @@ func (s *Service) ListSources(ctx context.Context, tenantID string) ([]*reposito
 
 // EnqueueBackupJobRequest is the request to enqueue a backup job
 type EnqueueBackupJobRequest struct {
-	SourceID string `json:"source_id"`
-	Priority int    `json:"priority,omitempty"` // Default 0
+	SourceID   string           `json:"source_id"`
+	Priority   int              `json:"priority,omitempty"`    // Default 0
+	BackupMode types.BackupMode `json:"backup_mode,omitempty"` // Default "full"
 }
 
 // EnqueueBackupJob creates and enqueues a backup job
@@ -207,6 +260,10 @@ func (s *Service) EnqueueBackupJob(ctx context.Context, tenantID string, req Enq
 		return nil, fmt.Errorf("source does not belong to tenant")
 	}
 
+	if err := s.enforceBackupQuota(ctx, tenantID, req.SourceID); err != nil {
+		return nil, err
+	}
+
 	// Build job payload (includes credential_id, not plaintext secrets)
 	payload := types.JobPayload{
 		SourceID:     req.SourceID,
@@ -214,6 +271,15 @@ func (s *Service) EnqueueBackupJob(ctx context.Context, tenantID string, req Enq
 		SourceConfig: source.Config,
 	}
 
+	switch req.BackupMode {
+	case "", types.BackupModeFull:
+	case types.BackupModeIncremental:
+		// An explicit request only needs a baseline, not a full backup schedule
+		s.planBackupMode(ctx, source, 0, &payload)
+	default:
+		return nil, fmt.Errorf("invalid backup_mode: %s", req.BackupMode)
+	}
+
 	payloadJSON, err := json.Marshal(payload)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal payload: %w", err)
@@ -259,7 +325,7 @@ func (s *Service) EnqueueBackupJob(ctx context.Context, tenantID string, req Enq
 // ClaimJob handles a worker's request to claim a job
 func (s *Service) ClaimJob(ctx context.Context, req types.JobClaimRequest) (*types.JobClaimResponse, error) {
 	// Claim the next available job
-	job, err := s.repo.ClaimJob(ctx, req.WorkerID, JobLeaseDuration)
+	job, err := s.repo.ClaimJob(ctx, req.WorkerID, JobLeaseDuration, JobReleaseBackoff)
 	if err != nil {
 		// Return sql.ErrNoRows directly so handler can identify "no jobs available"
 		if err == sql.ErrNoRows {
@@ -322,20 +388,46 @@ func (s *Service) GetTenantPublicKeyForWorker(ctx context.Context, tenantID stri
 }
 
 // GetTenantPrivateKeyForWorker retrieves and decrypts a tenant's private key for restore operations
-// This is used ONLY by workers for restore jobs (decrypting backup artifacts)
-func (s *Service) GetTenantPrivateKeyForWorker(ctx context.Context, tenantID string) (string, error) {
-	key, err := s.repo.GetActiveTenantKey(ctx, tenantID)
-	if err != nil {
-		return "", fmt.Errorf("failed to get tenant key: %w", err)
+// This is used ONLY by workers for restore jobs (decrypting backup artifacts). keyID
+// selects the key a snapshot manifest names; without it every key that is not disabled
+// is returned, the active one first, one per line as in an age identity file.
+func (s *Service) GetTenantPrivateKeyForWorker(ctx context.Context, tenantID, keyID string) (string, error) {
+	var keys []*repository.TenantKey
+	if keyID != "" {
+		key, err := s.repo.GetTenantKey(ctx, tenantID, keyID)
+		if err != nil {
+			return "", fmt.Errorf("failed to get tenant key: %w", err)
+		}
+		if key.KeyStatus == tenantKeyDisabled {
+			return "", fmt.Errorf("%w: %s", ErrTenantKeyDisabled, key.ID)
+		}
+		keys = append(keys, key)
+	} else {
+		all, err := s.repo.ListTenantKeys(ctx, tenantID)
+		if err != nil {
+			return "", fmt.Errorf("failed to get tenant keys: %w", err)
+		}
+		for _, key := range all {
+			if key.KeyStatus != tenantKeyDisabled {
+				keys = append(keys, key)
+			}
+		}
+		if len(keys) == 0 {
+			return "", fmt.Errorf("failed to get tenant keys: %w", sql.ErrNoRows)
+		}
 	}
 
-	// Decrypt the private key using the platform KEK
-	privateKeyBytes, err := crypto.DecryptFromStorage(key.EncryptedPrivateKey, s.encryptionKEK)
-	if err != nil {
-		return "", fmt.Errorf("failed to decrypt private key: %w", err)
+	identities := make([]string, len(keys))
+	for i, key := range keys {
+		// Decrypt the private key using the platform KEK
+		privateKeyBytes, err := crypto.DecryptFromStorage(key.EncryptedPrivateKey, s.encryptionKEK)
+		if err != nil {
+			return "", fmt.Errorf("failed to decrypt private key %s: %w", key.ID, err)
+		}
+		identities[i] = strings.TrimSpace(string(privateKeyBytes))
 	}
 
-	return string(privateKeyBytes), nil
+	return strings.Join(identities, "\n"), nil
 }
 
 // CompleteJob handles a worker's job completion report
@@ -347,17 +439,22 @@ func (s *Service) CompleteJob(ctx context.Context, jobID string, req types.JobCo
 		finalStatus = types.JobStatusCompleted
 	case "failed":
 		finalStatus = types.JobStatusFailed
+	case types.JobReleased:
+		return s.releaseJob(ctx, jobID, req)
 	default:
 		return fmt.Errorf("invalid job status: %s", req.Status)
 	}
 
 	// Update job status
-	var errorMsg *string
+	var errorCode, errorMsg *string
+	if req.ErrorCode != "" {
+		errorCode = &req.ErrorCode
+	}
 	if req.Error != "" {
 		errorMsg = &req.Error
 	}
 
-	if err := s.repo.CompleteJob(ctx, jobID, finalStatus, errorMsg); err != nil {
+	if err := s.repo.CompleteJob(ctx, jobID, finalStatus, errorCode, errorMsg); err != nil {
 		return fmt.Errorf("failed to update job: %w", err)
 	}
 
@@ -369,16 +466,58 @@ func (s *Service) CompleteJob(ctx context.Context, jobID string, req types.JobCo
 
 	// If snapshot was created, store it
 	if req.Snapshot != nil {
-		_, err = s.repo.CreateSnapshot(ctx, job.TenantID, *job.SourceID, jobID, *req.Snapshot)
+		signingKey := s.manifestSigningKey(ctx, req.WorkerID, req.Snapshot)
+		key := s.snapshotEncryptionKey(ctx, job.TenantID, req.Snapshot.SnapshotID, req.Snapshot.ManifestJSON)
+		snapshot, err := s.repo.CreateSnapshot(ctx, job.TenantID, *job.SourceID, jobID, *req.Snapshot, signingKey, key)
 		if err != nil {
 			return fmt.Errorf("failed to create snapshot: %w", err)
 		}
 
+		// Copy the snapshot to other workers when a replication policy covers it
+		s.maybeReplicateSnapshot(ctx, snapshot.ID)
+		// and to the tenant's offsite target when it has one
+		s.maybePushOffsite(ctx, snapshot.ID)
+
 		// Trigger retention evaluation for this source (debounced)
 		// This runs in background and won't block job completion
 		go s.maybeTriggerRetentionForSource(context.Background(), *job.SourceID)
 	}
 
+	// A reconcile_storage inventory is diffed against the worker's snapshot records
+	if job.Type == string(types.JobTypeReconcileStorage) && finalStatus == types.JobStatusCompleted && req.Inventory != nil {
+		if err := s.recordReconciliation(ctx, job, req.WorkerID, req.Inventory); err != nil {
+			return fmt.Errorf("failed to record storage reconciliation: %w", err)
+		}
+	}
+
+	// A replicate_snapshot job completes or fails its replica
+	if job.Type == string(types.JobTypeReplicateSnapshot) {
+		if err := s.recordReplica(ctx, job, finalStatus, req.Error, req.Replica); err != nil {
+			return fmt.Errorf("failed to record snapshot replica: %w", err)
+		}
+	}
+
+	// A completed migrate_snapshot job moves the snapshot to its new locator
+	if job.Type == string(types.JobTypeMigrateSnapshot) && finalStatus == types.JobStatusCompleted && req.Migration != nil {
+		if err := s.recordMigration(ctx, job, req.Migration); err != nil {
+			return fmt.Errorf("failed to record snapshot migration: %w", err)
+		}
+	}
+
+	// A completed rekey_snapshot job points the snapshot at its re-encrypted files
+	if job.Type == string(types.JobTypeRekeySnapshot) && finalStatus == types.JobStatusCompleted && req.Rekey != nil {
+		if err := s.recordRekey(ctx, job, req.WorkerID, req.Rekey); err != nil {
+			return fmt.Errorf("failed to record snapshot re-encryption: %w", err)
+		}
+	}
+
+	// A verify_snapshot result is recorded whether or not the snapshot passed
+	if job.Type == string(types.JobTypeVerifySnapshot) && req.Verification != nil {
+		if err := s.recordVerification(ctx, job, req.Verification); err != nil {
+			return fmt.Errorf("failed to record snapshot verification: %w", err)
+		}
+	}
+
 	// If delete_snapshot job completed successfully, delete the snapshot record
 	if job.Type == string(types.JobTypeDeleteSnapshot) && finalStatus == types.JobStatusCompleted {
 		// Parse the payload to get the snapshot ID
@@ -387,7 +526,13 @@ func (s *Service) CompleteJob(ctx context.Context, jobID string, req types.JobCo
 			return fmt.Errorf("failed to parse delete job payload: %w", err)
 		}
 
-		if payload.DeleteSnapshotID != nil {
+		// Deleting a replica only drops the replica record
+		if payload.DeleteReplicaID != nil {
+			if err := s.repo.DeleteSnapshotReplica(ctx, *payload.DeleteReplicaID); err != nil {
+				return fmt.Errorf("failed to delete replica record: %w", err)
+			}
+			s.pruneRetiredOffsiteTargets(ctx)
+		} else if payload.DeleteSnapshotID != nil {
 			if err := s.repo.DeleteSnapshot(ctx, *payload.DeleteSnapshotID); err != nil {
 				return fmt.Errorf("failed to delete snapshot record: %w", err)
 			}
@@ -397,6 +542,55 @@ func (s *Service) CompleteJob(ctx context.Context, jobID string, req types.JobCo
 	return nil
 }
 
+// maxJobReleases is how many times a backup job can be claimed and released before
+// the hub fails it instead of queueing it again. Workers that released a job only
+// claim it again after JobReleaseBackoff, so the attempts go to other workers first.
+const maxJobReleases = 3
+
+// releaseJob handles a worker handing a claimed job back, e.g. because it lacks the
+// disk space for it. Backup jobs go back to the queue until they have been claimed
+// maxJobReleases times, for any worker but the ones that released them until
+// JobReleaseBackoff has passed; other jobs, which are created for a specific worker,
+// fail with the worker's error.
+func (s *Service) releaseJob(ctx context.Context, jobID string, req types.JobCompleteRequest) error {
+	job, err := s.repo.GetJob(ctx, jobID)
+	if err != nil {
+		return fmt.Errorf("failed to get job details: %w", err)
+	}
+
+	var errorCode, errorMsg *string
+	if req.ErrorCode != "" {
+		errorCode = &req.ErrorCode
+	}
+	if req.Error != "" {
+		errorMsg = &req.Error
+	}
+	details := map[string]any{
+		"job_id":     jobID,
+		"worker_id":  req.WorkerID,
+		"attempt":    job.Attempt,
+		"error_code": req.ErrorCode,
+	}
+
+	if job.Type != string(types.JobTypeBackup) || job.Attempt >= maxJobReleases {
+		if err := s.repo.CompleteJob(ctx, jobID, types.JobStatusFailed, errorCode, errorMsg); err != nil {
+			return fmt.Errorf("failed to update job: %w", err)
+		}
+		s.LogSystemEvent(ctx, "error", fmt.Sprintf("job %s failed after worker %s released it: %s", jobID, req.WorkerID, req.Error), details)
+		return nil
+	}
+
+	if err := s.repo.ReleaseJob(ctx, jobID, req.WorkerID, errorCode, errorMsg); err != nil {
+		return err
+	}
+	s.LogSystemEvent(ctx, "warn", fmt.Sprintf("worker %s released job %s: %s", req.WorkerID, jobID, req.Error), details)
+	return nil
+}
+
+// ErrWorkerIdentityMismatch is returned when a worker registers an identity key other
+// than the one on record, which only an admin reset of its identity allows
+var ErrWorkerIdentityMismatch = errors.New("worker identity key does not match the registered key")
+
 // RegisterWorker handles a worker registration request
 func (s *Service) RegisterWorker(ctx context.Context, req types.WorkerRegisterRequest) (*repository.Worker, error) {
 	capabilitiesJSON, err := json.Marshal(req.Capabilities)
@@ -404,7 +598,13 @@ func (s *Service) RegisterWorker(ctx context.Context, req types.WorkerRegisterRe
 		return nil, fmt.Errorf("failed to marshal capabilities: %w", err)
 	}
 
-	worker, err := s.repo.RegisterWorker(ctx, req.WorkerID, req.Name, req.StorageBasePath, capabilitiesJSON)
+	worker, err := s.repo.RegisterWorker(ctx, req.WorkerID, req.Name, req.StorageBasePath, req.PublicKey, capabilitiesJSON)
+	if errors.Is(err, sql.ErrNoRows) {
+		s.LogSystemEvent(ctx, "error", fmt.Sprintf("worker %s registered with a different identity key; refused until an admin resets its identity", req.WorkerID), map[string]any{
+			"worker_id": req.WorkerID,
+		})
+		return nil, ErrWorkerIdentityMismatch
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to register worker: %w", err)
 	}
@@ -412,6 +612,42 @@ func (s *Service) RegisterWorker(ctx context.Context, req types.WorkerRegisterRe
 	return worker, nil
 }
 
+// ResetWorkerIdentity forgets a worker's identity key so it can register a new one,
+// e.g. after its key file was lost. Snapshots it signed before keep the key recorded
+// with them.
+func (s *Service) ResetWorkerIdentity(ctx context.Context, workerID string) error {
+	if err := s.repo.ResetWorkerIdentity(ctx, workerID); err != nil {
+		return err
+	}
+	s.LogSystemEvent(ctx, "warn", fmt.Sprintf("identity key of worker %s was reset", workerID), map[string]any{
+		"worker_id": workerID,
+	})
+	return nil
+}
+
+// manifestSigningKey returns the registered identity key of the worker that reported a
+// snapshot, provided it verifies the snapshot's manifest signature. Unsigned snapshots
+// and signatures that do not verify are stored without a key and logged, so restores
+// flag them instead of trusting the manifest.
+func (s *Service) manifestSigningKey(ctx context.Context, workerID string, result *types.SnapshotResult) string {
+	if result.ManifestSignature == "" {
+		return ""
+	}
+
+	details := map[string]any{"worker_id": workerID, "snapshot_id": result.SnapshotID}
+	worker, err := s.repo.GetWorker(ctx, workerID)
+	if err != nil || worker.PublicKey == nil {
+		s.LogSystemEvent(ctx, "warn", fmt.Sprintf("snapshot %s is signed but worker %s has no registered identity key", result.SnapshotID, workerID), details)
+		return ""
+	}
+	if err := crypto.VerifyManifest(result.ManifestJSON, result.ManifestSignature, *worker.PublicKey); err != nil {
+		details["error"] = err.Error()
+		s.LogSystemEvent(ctx, "warn", fmt.Sprintf("manifest signature of snapshot %s does not verify against worker %s", result.SnapshotID, workerID), details)
+		return ""
+	}
+	return *worker.PublicKey
+}
+
 // WorkerHeartbeat handles a worker heartbeat request
 func (s *Service) WorkerHeartbeat(ctx context.Context, req types.WorkerHeartbeatRequest) error {
 	// Serialize system metrics if present
@@ -424,7 +660,17 @@ func (s *Service) WorkerHeartbeat(ctx context.Context, req types.WorkerHeartbeat
 		metricsJSON = data
 	}
 
-	if err := s.repo.UpdateWorkerHeartbeat(ctx, req.WorkerID, req.Status, metricsJSON); err != nil {
+	// Reasons are only kept while the worker reports itself degraded
+	reasons := []types.WorkerStatusReason{}
+	if req.Status == "degraded" && req.StatusReasons != nil {
+		reasons = req.StatusReasons
+	}
+	reasonsJSON, err := json.Marshal(reasons)
+	if err != nil {
+		return fmt.Errorf("failed to marshal status reasons: %w", err)
+	}
+
+	if err := s.repo.UpdateWorkerHeartbeat(ctx, req.WorkerID, req.Status, metricsJSON, reasonsJSON); err != nil {
 		return fmt.Errorf("failed to update heartbeat: %w", err)
 	}
 
@@ -471,6 +717,39 @@ func (s *Service) GetSnapshot(ctx context.Context, snapshotID string) (*reposito
 	return snapshot, nil
 }
 
+// ErrSnapshotWorkerMismatch is returned when a worker reports on a snapshot stored by
+// another worker
+var ErrSnapshotWorkerMismatch = errors.New("snapshot is stored by another worker")
+
+// ReportSnapshotIntegrity records what a worker found when checking the files of a
+// snapshot it stored. It returns false when the hub has no record of the snapshot,
+// which is expected for one the worker never finished writing.
+func (s *Service) ReportSnapshotIntegrity(ctx context.Context, snapshotID string, req types.SnapshotIntegrityRequest) (bool, error) {
+	snapshot, err := s.repo.GetSnapshot(ctx, snapshotID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get snapshot: %w", err)
+	}
+	if snapshot.WorkerID == nil || *snapshot.WorkerID != req.WorkerID {
+		return s.reportReplicaIntegrity(ctx, snapshot, req)
+	}
+
+	if err := s.repo.UpdateSnapshotIntegrity(ctx, snapshot.ID, string(req.Status), req.Error); err != nil {
+		return false, err
+	}
+
+	details, _ := json.Marshal(map[string]any{
+		"integrity_status": req.Status,
+		"error":            req.Error,
+	})
+	if err := s.repo.CreateLog(ctx, "warn", "Snapshot files failed integrity check on worker", &req.WorkerID, &snapshot.JobID, &snapshot.ID, &snapshot.SourceID, nil, details); err != nil {
+		log.Printf("failed to log snapshot integrity report: %v", err)
+	}
+	return true, nil
+}
+
 // EvaluateRetentionPolicy evaluates a retention policy against a list of snapshots
 // and returns which snapshots should be kept and which should be deleted
 func (s *Service) EvaluateRetentionPolicy(ctx context.Context, tenantID, sourceID string, policy types.RetentionPolicy) (*types.RetentionEvaluationResult, error) {
@@ -606,6 +885,12 @@ func (s *Service) EvaluateRetentionPolicy(ctx context.Context, tenantID, sourceI
 	var toDelete, toKeep []string
 
 	for _, snap := range snapshots {
+		// Held and locked snapshots are kept whatever the policy says
+		if snap.IsLocked(now) {
+			toKeep = append(toKeep, snap.ID)
+			continue
+		}
+
 		// Check max age first (overrides protection)
 		if policy.MaxAgeDays != nil && snap.CreatedAt.Before(maxAgeTime) {
 			toDelete = append(toDelete, snap.ID)
@@ -630,7 +915,8 @@ func (s *Service) EvaluateRetentionPolicy(ctx context.Context, tenantID, sourceI
 }
 
 // EnqueueDeleteJob creates a delete_snapshot job for a specific snapshot
-// The job is targeted to the worker that owns the snapshot
+// The job is targeted to the worker that owns the snapshot. Every replica holding a
+// copy gets a delete job of its own, targeted to the replica's worker.
 func (s *Service) EnqueueDeleteJob(ctx context.Context, snapshotID string) (*repository.Job, error) {
 	// Get snapshot details
 	snapshot, err := s.repo.GetSnapshot(ctx, snapshotID)
@@ -638,14 +924,48 @@ func (s *Service) EnqueueDeleteJob(ctx context.Context, snapshotID string) (*rep
 		return nil, fmt.Errorf("failed to get snapshot: %w", err)
 	}
 
+	if snapshot.IsLocked(time.Now()) {
+		return nil, fmt.Errorf("%w: %s", ErrSnapshotLocked, snapshotID)
+	}
+
 	// Verify snapshot has a worker_id (required for local_fs storage)
 	if snapshot.WorkerID == nil || *snapshot.WorkerID == "" {
 		return nil, fmt.Errorf("snapshot has no worker_id, cannot delete")
 	}
 
-	// Build job payload
+	replicas, err := s.repo.ListSnapshotReplicas(ctx, snapshotID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshot replicas: %w", err)
+	}
+
+	// The locator tells the worker which backend holds the snapshot
+	job, err := s.enqueueDelete(ctx, snapshot.TenantID, snapshot.SourceID, snapshotID, *snapshot.WorkerID, snapshot.Locator(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Pending replicas are left to their replicate job, which fails or has its copy
+	// deleted once the snapshot is gone
+	for _, replica := range replicas {
+		if replica.LocalPath == nil && replica.ObjectKey == nil {
+			continue
+		}
+		replicaID := replica.ID
+		if _, err := s.enqueueDelete(ctx, snapshot.TenantID, snapshot.SourceID, snapshotID, replica.WorkerID, replica.Locator(), &replicaID); err != nil {
+			return nil, fmt.Errorf("failed to delete replica on worker %s: %w", replica.WorkerID, err)
+		}
+	}
+
+	return job, nil
+}
+
+// enqueueDelete creates a delete_snapshot job for one copy of a snapshot, targeted to
+// the worker holding it. replicaID is set when the copy is a replica.
+func (s *Service) enqueueDelete(ctx context.Context, tenantID, sourceID, snapshotID, workerID string, locator types.SnapshotLocator, replicaID *string) (*repository.Job, error) {
 	payload := types.JobPayload{
 		DeleteSnapshotID: &snapshotID,
+		DeleteLocator:    &locator,
+		DeleteReplicaID:  replicaID,
 	}
 
 	payloadJSON, err := json.Marshal(payload)
@@ -657,10 +977,10 @@ func (s *Service) EnqueueDeleteJob(ctx context.Context, snapshotID string) (*rep
 	priority := 10 // Higher than normal backup jobs
 	job, err := s.repo.CreateJobWithTargetWorker(
 		ctx,
-		snapshot.TenantID,
+		tenantID,
 		types.JobTypeDeleteSnapshot,
-		&snapshot.SourceID,
-		*snapshot.WorkerID,
+		&sourceID,
+		workerID,
 		payloadJSON,
 		priority,
 	)
@@ -671,7 +991,7 @@ func (s *Service) EnqueueDeleteJob(ctx context.Context, snapshotID string) (*rep
 	// Enqueue to Redis
 	jobMsg := map[string]any{
 		"job_id":     job.ID,
-		"tenant_id":  snapshot.TenantID,
+		"tenant_id":  tenantID,
 		"type":       "delete_snapshot",
 		"priority":   priority,
 		"created_at": job.CreatedAt.Format(time.RFC3339),
@@ -685,7 +1005,7 @@ func (s *Service) EnqueueDeleteJob(ctx context.Context, snapshotID string) (*rep
 	if err := s.redis.LPush(ctx, JobQueueKey, jobMsgJSON).Err(); err != nil {
 		s.LogSystemError(ctx, "Redis: failed to enqueue delete snapshot job", err, map[string]any{
 			"job_id":      job.ID,
-			"tenant_id":   snapshot.TenantID,
+			"tenant_id":   tenantID,
 			"snapshot_id": snapshotID,
 		})
 		return nil, fmt.Errorf("failed to enqueue delete job: %w", err)
@@ -864,6 +1184,7 @@ type CreateScheduleRequest struct {
 	IntervalMinutes *int                  `json:"interval_minutes,omitempty"`
 	Timezone        string                `json:"timezone,omitempty"` // Default "UTC"
 	RetentionPolicy types.RetentionPolicy `json:"retention_policy"`
+	FullEvery       int                   `json:"full_every,omitempty"` // Incrementals between full backups, 0 = always full
 }
 
 // CreateSchedule creates a new schedule for a source
@@ -887,6 +1208,10 @@ func (s *Service) CreateSchedule(ctx context.Context, req CreateScheduleRequest)
 		return nil, fmt.Errorf("cannot specify both cron and interval_minutes")
 	}
 
+	if req.FullEvery < 0 {
+		return nil, fmt.Errorf("full_every must not be negative")
+	}
+
 	timezone := req.Timezone
 	if timezone == "" {
 		timezone = "UTC"
@@ -899,7 +1224,7 @@ func (s *Service) CreateSchedule(ctx context.Context, req CreateScheduleRequest)
 	}
 
 	// Create schedule
-	schedule, err := s.repo.CreateSchedule(ctx, req.TenantID, req.SourceID, req.Cron, req.IntervalMinutes, timezone, retentionPolicyJSON)
+	schedule, err := s.repo.CreateSchedule(ctx, req.TenantID, req.SourceID, req.Cron, req.IntervalMinutes, timezone, retentionPolicyJSON, req.FullEvery)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create schedule: %w", err)
 	}
@@ -922,6 +1247,7 @@ type UpdateScheduleRequest struct {
 	Timezone        *string                `json:"timezone,omitempty"`
 	Status          *string                `json:"status,omitempty"` // "enabled" or "disabled"
 	RetentionPolicy *types.RetentionPolicy `json:"retention_policy,omitempty"`
+	FullEvery       *int                   `json:"full_every,omitempty"`
 }
 
 // UpdateSchedule updates an existing schedule
@@ -961,6 +1287,14 @@ func (s *Service) UpdateSchedule(ctx context.Context, scheduleID string, req Upd
 		status = *req.Status
 	}
 
+	fullEvery := existing.FullEvery
+	if req.FullEvery != nil {
+		if *req.FullEvery < 0 {
+			return nil, fmt.Errorf("full_every must not be negative")
+		}
+		fullEvery = *req.FullEvery
+	}
+
 	// Marshal retention policy
 	var retentionPolicyJSON json.RawMessage
 	if req.RetentionPolicy != nil {
@@ -973,7 +1307,7 @@ func (s *Service) UpdateSchedule(ctx context.Context, scheduleID string, req Upd
 	}
 
 	// Update schedule
-	schedule, err := s.repo.UpdateSchedule(ctx, scheduleID, cron, intervalMinutes, timezone, status, retentionPolicyJSON)
+	schedule, err := s.repo.UpdateSchedule(ctx, scheduleID, cron, intervalMinutes, timezone, status, retentionPolicyJSON, fullEvery)
 	if err != nil {
 		return nil, fmt.Errorf("failed to update schedule: %w", err)
 	}
@@ -1063,11 +1397,39 @@ type RestoreJobClaimRequest struct {
 
 // RestoreJobClaimResponse is the response when claiming a restore job
 type RestoreJobClaimResponse struct {
-	JobID      string `json:"job_id"`
-	TenantID   string `json:"tenant_id"`
-	SourceID   string `json:"source_id"`
-	SnapshotID string `json:"snapshot_id"`
-	LocalPath  string `json:"local_path"` // Actual path to snapshot on worker storage
+	JobID      string   `json:"job_id"`
+	TenantID   string   `json:"tenant_id"`
+	SourceID   string   `json:"source_id"`
+	SnapshotID string   `json:"snapshot_id"`
+	LocalPath  string   `json:"local_path"`      // Actual path to snapshot on worker storage
+	Paths      []string `json:"paths,omitempty"` // Restrict the restore to these archive paths
+	// Lay the restore out by the remote paths the trees were backed up from
+	OriginalLocations bool `json:"original_locations,omitempty"`
+
+	// Backend holding the snapshot; for s3 the snapshot's files live under ObjectKey
+	StorageBackend string `json:"storage_backend"`
+	Bucket         string `json:"bucket,omitempty"`
+	ObjectKey      string `json:"object_key,omitempty"`
+
+	// Worker signature over the snapshot manifest and the key that verifies it; both
+	// empty for unsigned snapshots
+	ManifestSignature  string `json:"manifest_signature,omitempty"`
+	ManifestSigningKey string `json:"manifest_signing_key,omitempty"`
+
+	// Completed replicas of the snapshot, read in order when the original copy is
+	// unavailable
+	Replicas []RestoreReplica `json:"replicas,omitempty"`
+}
+
+// RestoreReplica is another copy of the snapshot a restore can read from
+type RestoreReplica struct {
+	WorkerID       string `json:"worker_id"`
+	StorageBackend string `json:"storage_backend"`
+	LocalPath      string `json:"local_path,omitempty"`
+	Bucket         string `json:"bucket,omitempty"`
+	ObjectKey      string `json:"object_key,omitempty"`
+	// For sftp: the offsite target holding the copy under ObjectKey
+	OffsiteTargetID string `json:"offsite_target_id,omitempty"`
 }
 
 // RestoreJobCompleteRequest is the request to complete a restore job
@@ -1080,10 +1442,21 @@ type RestoreJobCompleteRequest struct {
 	SizeBytes     int64  `json:"size_bytes,omitempty"`
 	ExpiresAt     string `json:"expires_at,omitempty"`
 	DurationMs    int64  `json:"duration_ms,omitempty"`
+	// ManifestVerification is how the manifest was authenticated: "verified" against
+	// the signature the hub recorded, or "unsigned" when there was none
+	ManifestVerification string `json:"manifest_verification,omitempty"`
 }
 
-// EnqueueRestoreJob creates and enqueues a restore job
-func (s *Service) EnqueueRestoreJob(ctx context.Context, tenantID, snapshotID string) (*repository.Job, error) {
+// Outcomes a restore service reports for the manifest it restored from
+const (
+	ManifestVerified = "verified"
+	ManifestUnsigned = "unsigned"
+)
+
+// EnqueueRestoreJob creates and enqueues a restore job. When paths is non-empty only
+// those archive paths and everything below them are restored. originalLocations lays
+// the restore out by the remote paths the trees were backed up from.
+func (s *Service) EnqueueRestoreJob(ctx context.Context, tenantID, snapshotID string, paths []string, originalLocations bool) (*repository.Job, error) {
 	// Get snapshot to verify it exists and get source info
 	snapshot, err := s.repo.GetSnapshot(ctx, snapshotID)
 	if err != nil {
@@ -1102,8 +1475,18 @@ func (s *Service) EnqueueRestoreJob(ctx context.Context, tenantID, snapshotID st
 
 	// Create restore job payload
 	payload := types.JobPayload{
-		SourceID:          snapshot.SourceID,
-		RestoreSnapshotID: &snapshotID,
+		SourceID:                 snapshot.SourceID,
+		RestoreSnapshotID:        &snapshotID,
+		RestoreOriginalLocations: originalLocations,
+	}
+	for _, path := range paths {
+		path = strings.Trim(path, "/")
+		if path == "" {
+			// The archive root selects everything
+			payload.RestorePaths = nil
+			break
+		}
+		payload.RestorePaths = append(payload.RestorePaths, path)
 	}
 	payloadJSON, err := json.Marshal(payload)
 	if err != nil {
@@ -1178,13 +1561,43 @@ func (s *Service) ClaimRestoreJob(ctx context.Context, req RestoreJobClaimReques
 		localPath = filepath.Join("/var/lib/xvault/backups", "tenants", job.TenantID, "sources", sourceID, "snapshots", *payload.RestoreSnapshotID)
 	}
 
-	return &RestoreJobClaimResponse{
-		JobID:      job.ID,
-		TenantID:   job.TenantID,
-		SourceID:   sourceID,
-		SnapshotID: *payload.RestoreSnapshotID,
-		LocalPath:  localPath,
-	}, nil
+	locator := snapshot.Locator()
+	resp := &RestoreJobClaimResponse{
+		JobID:             job.ID,
+		TenantID:          job.TenantID,
+		SourceID:          sourceID,
+		SnapshotID:        *payload.RestoreSnapshotID,
+		LocalPath:         localPath,
+		StorageBackend:    string(locator.StorageBackend),
+		Bucket:            locator.Bucket,
+		ObjectKey:         locator.ObjectKey,
+		Paths:             payload.RestorePaths,
+		OriginalLocations: payload.RestoreOriginalLocations,
+	}
+	if snapshot.ManifestSignature != nil && snapshot.ManifestSigningKey != nil {
+		resp.ManifestSignature = *snapshot.ManifestSignature
+		resp.ManifestSigningKey = *snapshot.ManifestSigningKey
+	}
+
+	replicas, err := s.repo.ListSnapshotReplicas(ctx, snapshot.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshot replicas: %w", err)
+	}
+	for _, replica := range replicas {
+		if replica.Status != string(types.ReplicaStatusCompleted) {
+			continue
+		}
+		replicaLocator := replica.Locator()
+		resp.Replicas = append(resp.Replicas, RestoreReplica{
+			WorkerID:        replicaLocator.WorkerID,
+			StorageBackend:  string(replicaLocator.StorageBackend),
+			LocalPath:       replicaLocator.LocalPath,
+			Bucket:          replicaLocator.Bucket,
+			ObjectKey:       replicaLocator.ObjectKey,
+			OffsiteTargetID: replicaLocator.OffsiteTargetID,
+		})
+	}
+	return resp, nil
 }
 
 // CompleteRestoreJob handles restore service job completion
@@ -1205,7 +1618,7 @@ func (s *Service) CompleteRestoreJob(ctx context.Context, jobID string, req Rest
 		errorMsg = &req.Error
 	}
 
-	if err := s.repo.CompleteJob(ctx, jobID, finalStatus, errorMsg); err != nil {
+	if err := s.repo.CompleteJob(ctx, jobID, finalStatus, nil, errorMsg); err != nil {
 		return fmt.Errorf("failed to update job: %w", err)
 	}
 
@@ -1231,8 +1644,20 @@ func (s *Service) CompleteRestoreJob(ctx context.Context, jobID string, req Rest
 				expiresAt = time.Now().Add(1 * time.Hour) // Default to 1 hour
 			}
 
+			// Restore services that predate the report leave it unknown
+			verification := req.ManifestVerification
+			if verification != ManifestVerified && verification != ManifestUnsigned {
+				verification = ""
+			}
+			if verification == ManifestUnsigned {
+				s.LogSystemEvent(ctx, "warn", fmt.Sprintf("snapshot %s was restored from an unsigned manifest", *payload.RestoreSnapshotID), map[string]any{
+					"job_id":      jobID,
+					"snapshot_id": *payload.RestoreSnapshotID,
+				})
+			}
+
 			// Update snapshot with download info
-			if err := s.UpdateSnapshotDownloadInfo(ctx, *payload.RestoreSnapshotID, req.DownloadToken, req.DownloadURL, expiresAt); err != nil {
+			if err := s.UpdateSnapshotDownloadInfo(ctx, *payload.RestoreSnapshotID, req.DownloadToken, req.DownloadURL, expiresAt, verification); err != nil {
 				log.Printf("failed to update snapshot download info: %v", err)
 				// Don't fail the job completion if we can't store download metadata
 			}
@@ -1349,8 +1774,8 @@ func (s *Service) GetDownloadExpirationHours(ctx context.Context) (int, error) {
 }
 
 // UpdateSnapshotDownloadInfo updates download tracking info for a snapshot
-func (s *Service) UpdateSnapshotDownloadInfo(ctx context.Context, snapshotID, downloadToken, downloadURL string, expiresAt time.Time) error {
-	return s.repo.UpdateSnapshotDownloadInfo(ctx, snapshotID, downloadToken, downloadURL, expiresAt)
+func (s *Service) UpdateSnapshotDownloadInfo(ctx context.Context, snapshotID, downloadToken, downloadURL string, expiresAt time.Time, manifestVerification string) error {
+	return s.repo.UpdateSnapshotDownloadInfo(ctx, snapshotID, downloadToken, downloadURL, expiresAt, manifestVerification)
 }
 
 // ========== Admin User Management ==========
@@ -1491,6 +1916,11 @@ func (s *Service) GetTenant(ctx context.Context, tenantID string) (*repository.T
 // DeleteTenant deletes a tenant and all associated data (admin only)
 // It first enqueues delete_snapshot jobs to clean up worker storage, then deletes the tenant
 func (s *Service) DeleteTenant(ctx context.Context, tenantID string) error {
+	// A tenant holding locked snapshots cannot be deleted at all
+	if err := s.ensureNoLockedSnapshots(ctx, tenantID, ""); err != nil {
+		return err
+	}
+
 	// First, get all snapshots for this tenant so we can enqueue cleanup jobs
 	snapshots, err := s.repo.ListSnapshotsByTenantID(ctx, tenantID)
 	if err != nil {
@@ -1545,11 +1975,18 @@ func (s *Service) CreateSourceAdmin(ctx context.Context, req CreateSourceAdminRe
 		return nil, fmt.Errorf("invalid source type: must be ssh, sftp, ftp, mysql, or postgresql")
 	}
 
+	if err := validateSourceConfig(req.Config); err != nil {
+		return nil, err
+	}
+
 	// Verify tenant exists
 	_, err := s.repo.GetTenant(ctx, req.TenantID)
 	if err != nil {
 		return nil, fmt.Errorf("tenant not found: %w", err)
 	}
+	if err := s.enforceSourceQuota(ctx, req.TenantID); err != nil {
+		return nil, err
+	}
 
 	// Create credential for the source
 	cred, err := s.CreateCredential(ctx, CreateCredentialRequest{
@@ -1580,6 +2017,10 @@ type UpdateSourceAdminRequest struct {
 
 // UpdateSourceAdmin updates a source (admin only)
 func (s *Service) UpdateSourceAdmin(ctx context.Context, sourceID string, req UpdateSourceAdminRequest) (*repository.Source, error) {
+	if err := validateSourceConfig(req.Config); err != nil {
+		return nil, err
+	}
+
 	// Get existing source
 	source, err := s.repo.GetSource(ctx, sourceID)
 	if err != nil {
@@ -1634,8 +2075,16 @@ func (s *Service) UpdateSourceAdmin(ctx context.Context, sourceID string, req Up
 	return s.repo.GetSource(ctx, sourceID)
 }
 
-// DeleteSource deletes a source (admin only)
+// DeleteSource deletes a source (admin only). A source holding locked snapshots
+// cannot be deleted.
 func (s *Service) DeleteSource(ctx context.Context, sourceID string) error {
+	source, err := s.repo.GetSource(ctx, sourceID)
+	if err != nil {
+		return fmt.Errorf("source not found: %w", err)
+	}
+	if err := s.ensureNoLockedSnapshots(ctx, source.TenantID, sourceID); err != nil {
+		return err
+	}
 	return s.repo.DeleteSource(ctx, sourceID)
 }
 
@@ -1896,6 +2345,10 @@ func (s *Service) TriggerBackupAdmin(ctx context.Context, sourceID string) (*rep
 		return nil, fmt.Errorf("source not found: %w", err)
 	}
 
+	if err := s.enforceBackupQuota(ctx, source.TenantID, sourceID); err != nil {
+		return nil, err
+	}
+
 	// Build job payload
 	payload := types.JobPayload{
 		SourceID:     sourceID,
@@ -2007,6 +2460,20 @@ func (s *Service) ProcessDueSchedules(ctx context.Context) (int, error) {
 			continue
 		}
 
+		// A backup over quota is skipped, not retried on every tick
+		if err := s.enforceBackupQuota(ctx, schedule.TenantID, schedule.SourceID); err != nil {
+			log.Printf("scheduler: skipping schedule %s: %v", schedule.ID, err)
+			if errors.Is(err, ErrQuotaExceeded) {
+				s.LogSystemEvent(ctx, "warn", fmt.Sprintf("scheduled backup skipped: %v", err), map[string]any{
+					"schedule_id": schedule.ID,
+					"tenant_id":   schedule.TenantID,
+					"source_id":   schedule.SourceID,
+				})
+				s.advanceSchedule(ctx, schedule, now)
+			}
+			continue
+		}
+
 		// Enqueue backup job
 		job, err := s.EnqueueScheduledBackup(ctx, schedule)
 		if err != nil {
@@ -2017,22 +2484,25 @@ func (s *Service) ProcessDueSchedules(ctx context.Context) (int, error) {
 		log.Printf("scheduler: enqueued backup job %s for schedule %s (source: %s)", job.ID, schedule.ID, schedule.SourceID)
 		jobsCreated++
 
-		// Calculate next run time
-		nextRun, err := s.CalculateNextRun(schedule, now)
-		if err != nil {
-			log.Printf("scheduler: failed to calculate next run for schedule %s: %v", schedule.ID, err)
-			continue
-		}
-
-		// Update schedule with last and next run times
-		if err := s.repo.UpdateScheduleRunTimes(ctx, schedule.ID, now, nextRun); err != nil {
-			log.Printf("scheduler: failed to update run times for schedule %s: %v", schedule.ID, err)
-		}
+		s.advanceSchedule(ctx, schedule, now)
 	}
 
 	return jobsCreated, nil
 }
 
+// advanceSchedule records that a schedule ran at now and sets its next run
+func (s *Service) advanceSchedule(ctx context.Context, schedule *repository.Schedule, now time.Time) {
+	nextRun, err := s.CalculateNextRun(schedule, now)
+	if err != nil {
+		log.Printf("scheduler: failed to calculate next run for schedule %s: %v", schedule.ID, err)
+		return
+	}
+
+	if err := s.repo.UpdateScheduleRunTimes(ctx, schedule.ID, now, nextRun); err != nil {
+		log.Printf("scheduler: failed to update run times for schedule %s: %v", schedule.ID, err)
+	}
+}
+
 // EnqueueScheduledBackup creates and enqueues a backup job for a scheduled backup
 func (s *Service) EnqueueScheduledBackup(ctx context.Context, schedule *repository.Schedule) (*repository.Job, error) {
 	source, err := s.repo.GetSource(ctx, schedule.SourceID)
@@ -2046,6 +2516,9 @@ func (s *Service) EnqueueScheduledBackup(ctx context.Context, schedule *reposito
 		CredentialID: source.CredentialID,
 		SourceConfig: source.Config,
 	}
+	if schedule.FullEvery > 0 {
+		s.planBackupMode(ctx, source, schedule.FullEvery, &payload)
+	}
 
 	payloadJSON, err := json.Marshal(payload)
 	if err != nil {
@@ -2087,6 +2560,33 @@ func (s *Service) EnqueueScheduledBackup(ctx context.Context, schedule *reposito
 	return job, nil
 }
 
+// planBackupMode makes a backup payload incremental against the source's latest
+// completed snapshot when possible. fullEvery > 0 forces a full backup once that many
+// incrementals have been taken since the last full one; 0 means no limit. Anything that
+// prevents an incremental (unsupported source type, no previous snapshot, lookup
+// errors) leaves the payload as a full backup.
+func (s *Service) planBackupMode(ctx context.Context, source *repository.Source, fullEvery int, payload *types.JobPayload) {
+	payload.BackupMode = types.BackupModeFull
+
+	if source.Type != string(types.SourceTypeSSH) && source.Type != string(types.SourceTypeSFTP) {
+		return
+	}
+
+	latest, sinceFull, err := s.repo.GetBackupChainState(ctx, source.ID)
+	if err != nil {
+		s.LogSystemError(ctx, "failed to look up previous snapshot, running full backup", err, map[string]any{
+			"source_id": source.ID,
+		})
+		return
+	}
+	if latest == nil || (fullEvery > 0 && sinceFull >= fullEvery) {
+		return
+	}
+
+	payload.BackupMode = types.BackupModeIncremental
+	payload.BaseSnapshotID = &latest.ID
+}
+
 // CalculateNextRun calculates the next run time for a schedule based on cron or interval
 func (s *Service) CalculateNextRun(schedule *repository.Schedule, from time.Time) (time.Time, error) {
 	// Load timezone
@@ -2235,34 +2735,67 @@ func (s *Service) ListAllLogsAdmin(ctx context.Context, params ListAllLogsAdminP
 type AuditAction string
 
 const (
-	AuditActionCreateSource   AuditAction = "create_source"
-	AuditActionUpdateSource   AuditAction = "update_source"
-	AuditActionDeleteSource   AuditAction = "delete_source"
-	AuditActionCreateSchedule AuditAction = "create_schedule"
-	AuditActionUpdateSchedule AuditAction = "update_schedule"
-	AuditActionDeleteSchedule AuditAction = "delete_schedule"
-	AuditActionDeleteSnapshot AuditAction = "delete_snapshot"
-	AuditActionTriggerBackup  AuditAction = "trigger_backup"
-	AuditActionCreateTenant   AuditAction = "create_tenant"
-	AuditActionDeleteTenant   AuditAction = "delete_tenant"
-	AuditActionCreateUser     AuditAction = "create_user"
-	AuditActionUpdateUser     AuditAction = "update_user"
-	AuditActionDeleteUser     AuditAction = "delete_user"
-	AuditActionUpdateSetting  AuditAction = "update_setting"
-	AuditActionLogin          AuditAction = "login"
-	AuditActionLogout         AuditAction = "logout"
+	AuditActionCreateSource            AuditAction = "create_source"
+	AuditActionUpdateSource            AuditAction = "update_source"
+	AuditActionDeleteSource            AuditAction = "delete_source"
+	AuditActionCreateSchedule          AuditAction = "create_schedule"
+	AuditActionUpdateSchedule          AuditAction = "update_schedule"
+	AuditActionDeleteSchedule          AuditAction = "delete_schedule"
+	AuditActionDeleteSnapshot          AuditAction = "delete_snapshot"
+	AuditActionTriggerBackup           AuditAction = "trigger_backup"
+	AuditActionCreateTenant            AuditAction = "create_tenant"
+	AuditActionDeleteTenant            AuditAction = "delete_tenant"
+	AuditActionCreateUser              AuditAction = "create_user"
+	AuditActionUpdateUser              AuditAction = "update_user"
+	AuditActionDeleteUser              AuditAction = "delete_user"
+	AuditActionUpdateSetting           AuditAction = "update_setting"
+	AuditActionLogin                   AuditAction = "login"
+	AuditActionLogout                  AuditAction = "logout"
+	AuditActionReconcileStorage        AuditAction = "reconcile_storage"
+	AuditActionQuarantineOrphans       AuditAction = "quarantine_orphans"
+	AuditActionPurgeOrphans            AuditAction = "purge_orphans"
+	AuditActionVerifySnapshot          AuditAction = "verify_snapshot"
+	AuditActionCreateVerifySchedule    AuditAction = "create_verify_schedule"
+	AuditActionUpdateVerifySchedule    AuditAction = "update_verify_schedule"
+	AuditActionDeleteVerifySchedule    AuditAction = "delete_verify_schedule"
+	AuditActionReplicateSnapshot       AuditAction = "replicate_snapshot"
+	AuditActionCreateReplicationPolicy AuditAction = "create_replication_policy"
+	AuditActionUpdateReplicationPolicy AuditAction = "update_replication_policy"
+	AuditActionDeleteReplicationPolicy AuditAction = "delete_replication_policy"
+	AuditActionUpdateQuota             AuditAction = "update_quota"
+	AuditActionDeleteQuota             AuditAction = "delete_quota"
+	AuditActionUpdateTenantPlan        AuditAction = "update_tenant_plan"
+	AuditActionHoldSnapshot            AuditAction = "hold_snapshot"
+	AuditActionReleaseSnapshotHold     AuditAction = "release_snapshot_hold"
+	AuditActionLockSnapshot            AuditAction = "lock_snapshot"
+	AuditActionUpdateOffsiteTarget     AuditAction = "update_offsite_target"
+	AuditActionDeleteOffsiteTarget     AuditAction = "delete_offsite_target"
+	AuditActionPushSnapshotOffsite     AuditAction = "push_snapshot_offsite"
+	AuditActionMigrateSnapshot         AuditAction = "migrate_snapshot"
+	AuditActionCreateTieringPolicy     AuditAction = "create_tiering_policy"
+	AuditActionUpdateTieringPolicy     AuditAction = "update_tiering_policy"
+	AuditActionDeleteTieringPolicy     AuditAction = "delete_tiering_policy"
+	AuditActionReencryptSecrets        AuditAction = "reencrypt_secrets"
+	AuditActionRotateTenantKey         AuditAction = "rotate_tenant_key"
+	AuditActionRekeySnapshots          AuditAction = "rekey_snapshots"
+	AuditActionResetWorkerIdentity     AuditAction = "reset_worker_identity"
 )
 
 // AuditTargetType represents the type of resource being audited
 type AuditTargetType string
 
 const (
-	AuditTargetSource   AuditTargetType = "source"
-	AuditTargetSchedule AuditTargetType = "schedule"
-	AuditTargetSnapshot AuditTargetType = "snapshot"
-	AuditTargetTenant   AuditTargetType = "tenant"
-	AuditTargetUser     AuditTargetType = "user"
-	AuditTargetSetting  AuditTargetType = "setting"
+	AuditTargetSource            AuditTargetType = "source"
+	AuditTargetSchedule          AuditTargetType = "schedule"
+	AuditTargetSnapshot          AuditTargetType = "snapshot"
+	AuditTargetTenant            AuditTargetType = "tenant"
+	AuditTargetUser              AuditTargetType = "user"
+	AuditTargetSetting           AuditTargetType = "setting"
+	AuditTargetWorker            AuditTargetType = "worker"
+	AuditTargetReplicationPolicy AuditTargetType = "replication_policy"
+	AuditTargetPlan              AuditTargetType = "plan"
+	AuditTargetTieringPolicy     AuditTargetType = "tiering_policy"
+	AuditTargetKEK               AuditTargetType = "kek"
 )
 
 // CreateAuditEventRequest contains parameters for creating an audit event
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"xvault/pkg/types"
+)
+
+// ErrFileIndexUnavailable is returned when a snapshot has no file index to list,
+// e.g. because it was taken before file indexes were written
+var ErrFileIndexUnavailable = errors.New("snapshot has no file index")
+
+// fileListingKeyPrefix prefixes the Redis keys of file listing tokens, which hold the
+// snapshot each grants access to
+const fileListingKeyPrefix = "xvault:file-listings:"
+
+// fileListingTokenTTL is how long the restore service has to redeem a file listing
+// token
+const fileListingTokenTTL = time.Minute
+
+// SetRestoreServiceURL sets the base URL the hub uses to reach the restore service
+func (s *Service) SetRestoreServiceURL(baseURL string) {
+	s.restoreURL = strings.TrimRight(baseURL, "/")
+}
+
+// ListSnapshotFiles lists the files of a tenant's snapshot below prefix. The restore
+// service decrypts only the snapshot's file index; the archive is not read. The
+// request carries a one-off token instead of the snapshot's locator, which the
+// restore service redeems with the hub through AuthorizeFileListing.
+func (s *Service) ListSnapshotFiles(ctx context.Context, tenantID, snapshotID, prefix string) (*types.SnapshotFileList, error) {
+	snapshot, err := s.repo.GetSnapshot(ctx, snapshotID)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot not found: %w", err)
+	}
+
+	// Verify snapshot belongs to tenant
+	if snapshot.TenantID != tenantID {
+		return nil, fmt.Errorf("snapshot does not belong to tenant")
+	}
+
+	if snapshot.Status != "completed" {
+		return nil, fmt.Errorf("snapshot is not completed (status: %s)", snapshot.Status)
+	}
+
+	if s.restoreURL == "" {
+		return nil, fmt.Errorf("restore service URL is not configured")
+	}
+
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return nil, fmt.Errorf("failed to generate file listing token: %w", err)
+	}
+	token := hex.EncodeToString(tokenBytes)
+	if err := s.redis.Set(ctx, fileListingKeyPrefix+hashTransferToken(token), snapshot.ID, fileListingTokenTTL).Err(); err != nil {
+		return nil, fmt.Errorf("failed to store file listing token: %w", err)
+	}
+
+	query := url.Values{}
+	query.Set("prefix", prefix)
+	endpoint := fmt.Sprintf("%s/internal/snapshots/%s/files?%s", s.restoreURL, url.PathEscape(snapshot.ID), query.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach restore service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrFileIndexUnavailable
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("restore service returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var list types.SnapshotFileList
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("failed to decode file list: %w", err)
+	}
+
+	return &list, nil
+}
+
+// AuthorizeFileListing redeems a file listing token the hub sent the restore service
+// and returns the snapshot it may list. Each token is valid once, for
+// fileListingTokenTTL; an unknown or spent token returns sql.ErrNoRows.
+func (s *Service) AuthorizeFileListing(ctx context.Context, req types.FileListingAuthorizeRequest) (*types.FileListing, error) {
+	snapshotID, err := s.redis.GetDel(ctx, fileListingKeyPrefix+hashTransferToken(req.Token)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, sql.ErrNoRows
+		}
+		return nil, fmt.Errorf("failed to look up file listing token: %w", err)
+	}
+
+	snapshot, err := s.repo.GetSnapshot(ctx, snapshotID)
+	if err != nil {
+		return nil, err
+	}
+	return &types.FileListing{
+		TenantID:   snapshot.TenantID,
+		SourceID:   snapshot.SourceID,
+		SnapshotID: snapshot.ID,
+		Locator:    snapshot.Locator(),
+	}, nil
+}
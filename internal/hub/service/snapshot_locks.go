@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"xvault/internal/hub/repository"
+)
+
+// ErrSnapshotLocked is returned when a snapshot, or a source or tenant holding one,
+// cannot be deleted because the snapshot is under legal hold or locked
+var ErrSnapshotLocked = errors.New("snapshot is under legal hold or locked")
+
+// ErrLockShortened is returned when a snapshot lock would end before the current one
+var ErrLockShortened = errors.New("snapshot lock can only be extended")
+
+// HoldSnapshotRequest is the request to place a legal hold on a snapshot
+type HoldSnapshotRequest struct {
+	Reason string `json:"reason"`
+}
+
+// LockSnapshotRequest is the request to lock a snapshot until a given time
+type LockSnapshotRequest struct {
+	LockedUntil time.Time `json:"locked_until"`
+}
+
+// SnapshotLockStatus tells a worker whether it may delete a snapshot
+type SnapshotLockStatus struct {
+	SnapshotID  string     `json:"snapshot_id"`
+	LegalHold   bool       `json:"legal_hold"`
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+	Locked      bool       `json:"locked"`
+}
+
+// HoldSnapshot places a legal hold on a snapshot. Delete jobs no worker has claimed yet
+// are canceled; workers refuse the others.
+func (s *Service) HoldSnapshot(ctx context.Context, snapshotID string, req HoldSnapshotRequest) (*repository.Snapshot, error) {
+	var reason *string
+	if r := strings.TrimSpace(req.Reason); r != "" {
+		reason = &r
+	}
+	if err := s.repo.SetSnapshotLegalHold(ctx, snapshotID, true, reason); err != nil {
+		return nil, err
+	}
+	s.cancelDeleteJobs(ctx, snapshotID)
+	return s.repo.GetSnapshot(ctx, snapshotID)
+}
+
+// ReleaseSnapshotHold releases the legal hold on a snapshot. A lock still in force keeps
+// protecting it.
+func (s *Service) ReleaseSnapshotHold(ctx context.Context, snapshotID string) (*repository.Snapshot, error) {
+	if err := s.repo.SetSnapshotLegalHold(ctx, snapshotID, false, nil); err != nil {
+		return nil, err
+	}
+	return s.repo.GetSnapshot(ctx, snapshotID)
+}
+
+// LockSnapshot protects a snapshot from deletion until lockedUntil. Like an object
+// lock in compliance mode, the lock can be extended but not shortened or removed.
+func (s *Service) LockSnapshot(ctx context.Context, snapshotID string, req LockSnapshotRequest) (*repository.Snapshot, error) {
+	now := time.Now()
+	if !req.LockedUntil.After(now) {
+		return nil, fmt.Errorf("locked_until must be in the future")
+	}
+
+	snapshot, err := s.repo.GetSnapshot(ctx, snapshotID)
+	if err != nil {
+		return nil, err
+	}
+	if snapshot.LockedUntil != nil && snapshot.LockedUntil.After(req.LockedUntil) {
+		return nil, fmt.Errorf("%w: locked until %s", ErrLockShortened, snapshot.LockedUntil.Format(time.RFC3339))
+	}
+
+	if err := s.repo.ExtendSnapshotLock(ctx, snapshotID, req.LockedUntil); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrLockShortened
+		}
+		return nil, err
+	}
+	s.cancelDeleteJobs(ctx, snapshotID)
+	return s.repo.GetSnapshot(ctx, snapshotID)
+}
+
+// GetSnapshotLockStatus returns whether a snapshot may be deleted, for workers about
+// to run a delete_snapshot job
+func (s *Service) GetSnapshotLockStatus(ctx context.Context, snapshotID string) (*SnapshotLockStatus, error) {
+	snapshot, err := s.repo.GetSnapshot(ctx, snapshotID)
+	if err != nil {
+		return nil, err
+	}
+	return &SnapshotLockStatus{
+		SnapshotID:  snapshot.ID,
+		LegalHold:   snapshot.LegalHold,
+		LockedUntil: snapshot.LockedUntil,
+		Locked:      snapshot.IsLocked(time.Now()),
+	}, nil
+}
+
+// cancelDeleteJobs cancels the queued delete jobs of a snapshot that was just held or
+// locked
+func (s *Service) cancelDeleteJobs(ctx context.Context, snapshotID string) {
+	canceled, err := s.repo.CancelQueuedDeleteJobs(ctx, snapshotID)
+	if err != nil {
+		s.LogSystemError(ctx, "failed to cancel delete jobs of locked snapshot", err, map[string]any{"snapshot_id": snapshotID})
+		return
+	}
+	if canceled > 0 {
+		s.LogSystemEvent(ctx, "info", fmt.Sprintf("canceled %d delete jobs of locked snapshot %s", canceled, snapshotID), map[string]any{"snapshot_id": snapshotID})
+	}
+}
+
+// ensureNoLockedSnapshots refuses to delete a tenant, or one of its sources when
+// sourceID is set, while it holds a locked snapshot
+func (s *Service) ensureNoLockedSnapshots(ctx context.Context, tenantID, sourceID string) error {
+	count, err := s.repo.CountLockedSnapshots(ctx, tenantID, sourceID, time.Now())
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("%w: %d snapshots are under legal hold or locked", ErrSnapshotLocked, count)
+	}
+	return nil
+}
@@ -0,0 +1,304 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"xvault/internal/hub/repository"
+	"xvault/pkg/types"
+)
+
+// migrateJobPriority keeps migrate jobs behind every backup and delete job
+const migrateJobPriority = -1
+
+// ErrSnapshotNotTierable is returned when a snapshot cannot be moved to colder
+// storage: it did not complete, is not on worker disk, is stored in a chunk
+// repository, or its worker has no tier storage for the target backend
+var ErrSnapshotNotTierable = errors.New("snapshot cannot be moved to colder storage")
+
+// canTierTo reports whether the worker can move the snapshots on its disk to backend
+func (w *replicationWorker) canTierTo(backend types.StorageBackend) bool {
+	for _, tier := range w.capabilities.TierStorage {
+		if tier == string(backend) {
+			return true
+		}
+	}
+	return false
+}
+
+// MigrateSnapshot moves a snapshot from its worker's disk to the worker's tier
+// storage now, whatever its age. Returns the migrate_snapshot job.
+func (s *Service) MigrateSnapshot(ctx context.Context, snapshotID string) (*repository.Job, error) {
+	snapshot, err := s.repo.GetSnapshot(ctx, snapshotID)
+	if err != nil {
+		return nil, err
+	}
+	workers, err := s.replicationWorkers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.enqueueMigrateJob(ctx, snapshot, types.StorageBackendS3, workers)
+}
+
+// enqueueMigrateJob creates a migrate_snapshot job for a snapshot, targeted to the
+// worker whose disk holds it
+func (s *Service) enqueueMigrateJob(ctx context.Context, snapshot *repository.Snapshot, backend types.StorageBackend, workers map[string]*replicationWorker) (*repository.Job, error) {
+	if snapshot.Status != "completed" {
+		return nil, fmt.Errorf("%w: status is %s", ErrSnapshotNotTierable, snapshot.Status)
+	}
+	if snapshot.StorageBackend != string(types.StorageBackendLocalFS) {
+		return nil, fmt.Errorf("%w: snapshot is already in %s", ErrSnapshotNotTierable, snapshot.StorageBackend)
+	}
+	if snapshot.WorkerID == nil || *snapshot.WorkerID == "" {
+		return nil, fmt.Errorf("%w: snapshot has no worker_id", ErrSnapshotNotTierable)
+	}
+	var manifest struct {
+		StorageMode types.StorageMode `json:"storage_mode"`
+	}
+	if err := json.Unmarshal(snapshot.ManifestJSON, &manifest); err == nil && manifest.StorageMode == types.StorageModeRepository {
+		return nil, fmt.Errorf("%w: repository snapshots share chunks with the tenant's other snapshots", ErrSnapshotNotTierable)
+	}
+	worker, ok := workers[*snapshot.WorkerID]
+	if !ok || !worker.canTierTo(backend) {
+		return nil, fmt.Errorf("%w: worker %s has no %s tier storage", ErrSnapshotNotTierable, *snapshot.WorkerID, backend)
+	}
+
+	snapshotID := snapshot.ID
+	locator := snapshot.Locator()
+	payloadJSON, err := json.Marshal(types.JobPayload{
+		MigrateSnapshotID: &snapshotID,
+		MigrateLocator:    &locator,
+		MigrateBackend:    backend,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	job, err := s.repo.CreateJobWithTargetWorker(ctx, snapshot.TenantID, types.JobTypeMigrateSnapshot,
+		&snapshot.SourceID, worker.ID, payloadJSON, migrateJobPriority)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create migrate job: %w", err)
+	}
+
+	jobMsg := map[string]any{
+		"job_id":     job.ID,
+		"tenant_id":  snapshot.TenantID,
+		"type":       string(types.JobTypeMigrateSnapshot),
+		"priority":   migrateJobPriority,
+		"created_at": job.CreatedAt.Format(time.RFC3339),
+	}
+	jobMsgJSON, err := json.Marshal(jobMsg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal job message: %w", err)
+	}
+
+	if err := s.redis.LPush(ctx, JobQueueKey, jobMsgJSON).Err(); err != nil {
+		s.LogSystemError(ctx, "Redis: failed to enqueue migrate snapshot job", err, map[string]any{
+			"job_id":      job.ID,
+			"tenant_id":   snapshot.TenantID,
+			"snapshot_id": snapshot.ID,
+		})
+		return nil, fmt.Errorf("failed to enqueue migrate job: %w", err)
+	}
+
+	return job, nil
+}
+
+// recordMigration points a snapshot at the copy its migrate_snapshot job made. The
+// worker removes the snapshot from its disk only once this succeeds.
+func (s *Service) recordMigration(ctx context.Context, job *repository.Job, result *types.SnapshotMigration) error {
+	var payload types.JobPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return fmt.Errorf("failed to parse migrate job payload: %w", err)
+	}
+	if payload.MigrateSnapshotID == nil || *payload.MigrateSnapshotID != result.SnapshotID {
+		return fmt.Errorf("migration is for snapshot %s, not the job's", result.SnapshotID)
+	}
+	locator := result.Locator
+	if locator.StorageBackend != payload.MigrateBackend || locator.Bucket == "" || locator.ObjectKey == "" {
+		return fmt.Errorf("migration locator is not a %s copy", payload.MigrateBackend)
+	}
+
+	if err := s.repo.MoveSnapshot(ctx, result.SnapshotID, locator); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("snapshot %s is no longer on worker disk", result.SnapshotID)
+		}
+		return err
+	}
+
+	log.Printf("tiering: moved snapshot %s to %s://%s/%s", result.SnapshotID, locator.StorageBackend, locator.Bucket, locator.ObjectKey)
+	return nil
+}
+
+// Tiering policies
+
+// CreateTieringPolicyRequest is the request to create a tiering policy. Without a
+// source_id the policy covers every source of the tenant that has no policy of its
+// own.
+type CreateTieringPolicyRequest struct {
+	TenantID      string  `json:"tenant_id"`
+	SourceID      *string `json:"source_id,omitempty"`
+	MinAgeDays    int     `json:"min_age_days"`
+	TargetBackend string  `json:"target_backend,omitempty"` // "s3" (default)
+}
+
+// validateTieringBackend checks a tiering target backend, defaulting to s3
+func validateTieringBackend(backend string) (string, error) {
+	if backend == "" {
+		return string(types.StorageBackendS3), nil
+	}
+	if backend != string(types.StorageBackendS3) {
+		return "", fmt.Errorf("target_backend must be s3")
+	}
+	return backend, nil
+}
+
+// CreateTieringPolicy creates a tiering policy. The tiering scheduler moves the
+// snapshots it covers once they are older than min_age_days.
+func (s *Service) CreateTieringPolicy(ctx context.Context, req CreateTieringPolicyRequest) (*repository.TieringPolicy, error) {
+	if req.MinAgeDays < 1 {
+		return nil, fmt.Errorf("min_age_days must be at least 1")
+	}
+	targetBackend, err := validateTieringBackend(req.TargetBackend)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.repo.GetTenant(ctx, req.TenantID); err != nil {
+		return nil, fmt.Errorf("tenant not found: %w", err)
+	}
+	if req.SourceID != nil {
+		source, err := s.repo.GetSource(ctx, *req.SourceID)
+		if err != nil {
+			return nil, fmt.Errorf("source not found: %w", err)
+		}
+		if source.TenantID != req.TenantID {
+			return nil, fmt.Errorf("source does not belong to tenant")
+		}
+	}
+
+	policy, err := s.repo.CreateTieringPolicy(ctx, req.TenantID, req.SourceID, req.MinAgeDays, targetBackend)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tiering policy: %w", err)
+	}
+	return policy, nil
+}
+
+// UpdateTieringPolicyRequest is the request to update a tiering policy
+type UpdateTieringPolicyRequest struct {
+	MinAgeDays    *int    `json:"min_age_days,omitempty"`
+	TargetBackend *string `json:"target_backend,omitempty"`
+	Status        *string `json:"status,omitempty"` // "enabled" or "disabled"
+}
+
+// UpdateTieringPolicy updates a tiering policy. Snapshots already moved stay where
+// they are.
+func (s *Service) UpdateTieringPolicy(ctx context.Context, policyID string, req UpdateTieringPolicyRequest) (*repository.TieringPolicy, error) {
+	existing, err := s.repo.GetTieringPolicy(ctx, policyID)
+	if err != nil {
+		return nil, fmt.Errorf("tiering policy not found: %w", err)
+	}
+
+	minAgeDays := existing.MinAgeDays
+	if req.MinAgeDays != nil {
+		if *req.MinAgeDays < 1 {
+			return nil, fmt.Errorf("min_age_days must be at least 1")
+		}
+		minAgeDays = *req.MinAgeDays
+	}
+
+	targetBackend := existing.TargetBackend
+	if req.TargetBackend != nil {
+		if targetBackend, err = validateTieringBackend(*req.TargetBackend); err != nil {
+			return nil, err
+		}
+	}
+
+	status := existing.Status
+	if req.Status != nil {
+		if *req.Status != "enabled" && *req.Status != "disabled" {
+			return nil, fmt.Errorf("status must be enabled or disabled")
+		}
+		status = *req.Status
+	}
+
+	policy, err := s.repo.UpdateTieringPolicy(ctx, policyID, minAgeDays, targetBackend, status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update tiering policy: %w", err)
+	}
+	return policy, nil
+}
+
+// GetTieringPolicy retrieves a tiering policy by ID
+func (s *Service) GetTieringPolicy(ctx context.Context, policyID string) (*repository.TieringPolicy, error) {
+	return s.repo.GetTieringPolicy(ctx, policyID)
+}
+
+// ListTieringPolicies lists tiering policies, optionally for a single tenant
+func (s *Service) ListTieringPolicies(ctx context.Context, tenantID string) ([]*repository.TieringPolicy, error) {
+	return s.repo.ListTieringPolicies(ctx, tenantID, false)
+}
+
+// DeleteTieringPolicy deletes a tiering policy
+func (s *Service) DeleteTieringPolicy(ctx context.Context, policyID string) error {
+	return s.repo.DeleteTieringPolicy(ctx, policyID)
+}
+
+// ProcessTieringPolicies enqueues migrate jobs for the snapshots on worker disk that
+// an enabled tiering policy covers and that are older than its min_age_days.
+// Snapshots whose worker has no tier storage are skipped. Returns the number of jobs
+// created.
+func (s *Service) ProcessTieringPolicies(ctx context.Context) (int, error) {
+	policies, err := s.repo.ListTieringPolicies(ctx, "", true)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list tiering policies: %w", err)
+	}
+	if len(policies) == 0 {
+		return 0, nil
+	}
+	workers, err := s.replicationWorkers(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list workers: %w", err)
+	}
+
+	now := time.Now()
+	jobsCreated := 0
+	for _, policy := range policies {
+		sourceID := ""
+		if policy.SourceID != nil {
+			sourceID = *policy.SourceID
+		}
+		snapshotIDs, err := s.repo.ListSnapshotsDueForTiering(ctx, policy.TenantID, sourceID, now.AddDate(0, 0, -policy.MinAgeDays))
+		if err != nil {
+			log.Printf("tiering scheduler: failed to list snapshots for policy %s: %v", policy.ID, err)
+			continue
+		}
+
+		skipped := 0
+		for _, snapshotID := range snapshotIDs {
+			snapshot, err := s.repo.GetSnapshot(ctx, snapshotID)
+			if err != nil {
+				log.Printf("tiering scheduler: failed to get snapshot %s: %v", snapshotID, err)
+				continue
+			}
+			if _, err := s.enqueueMigrateJob(ctx, snapshot, types.StorageBackend(policy.TargetBackend), workers); err != nil {
+				if errors.Is(err, ErrSnapshotNotTierable) {
+					skipped++
+				} else {
+					log.Printf("tiering scheduler: failed to enqueue migrate job for snapshot %s: %v", snapshotID, err)
+				}
+				continue
+			}
+			jobsCreated++
+		}
+		if skipped > 0 {
+			log.Printf("tiering scheduler: policy %s skipped %d snapshot(s) whose worker cannot move them to %s", policy.ID, skipped, policy.TargetBackend)
+		}
+	}
+
+	return jobsCreated, nil
+}
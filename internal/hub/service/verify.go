@@ -0,0 +1,253 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"xvault/internal/hub/repository"
+	"xvault/pkg/types"
+)
+
+// verifyJobPriority keeps verify jobs behind every backup and delete job
+const verifyJobPriority = -1
+
+// ErrSnapshotNotVerifiable is returned when a snapshot cannot be verified because it
+// did not complete or has no worker to read it
+var ErrSnapshotNotVerifiable = errors.New("snapshot cannot be verified")
+
+// VerifySnapshotRequest is the request to verify a snapshot now
+type VerifySnapshotRequest struct {
+	TestDecrypt bool `json:"test_decrypt"`
+}
+
+// EnqueueVerifyJob creates a verify_snapshot job for a snapshot, targeted to the worker
+// that stored it. testDecrypt also decrypts and decompresses the archive.
+func (s *Service) EnqueueVerifyJob(ctx context.Context, snapshotID string, testDecrypt bool) (*repository.Job, error) {
+	snapshot, err := s.repo.GetSnapshot(ctx, snapshotID)
+	if err != nil {
+		return nil, err
+	}
+	if snapshot.Status != "completed" {
+		return nil, fmt.Errorf("%w: status is %s", ErrSnapshotNotVerifiable, snapshot.Status)
+	}
+	if snapshot.WorkerID == nil || *snapshot.WorkerID == "" {
+		return nil, fmt.Errorf("%w: snapshot has no worker_id", ErrSnapshotNotVerifiable)
+	}
+
+	locator := snapshot.Locator()
+	payload := types.JobPayload{
+		VerifySnapshotID: &snapshotID,
+		VerifyLocator:    &locator,
+		VerifyDecrypt:    testDecrypt,
+	}
+	if snapshot.ManifestSignature != nil && snapshot.ManifestSigningKey != nil {
+		payload.VerifyManifestSignature = *snapshot.ManifestSignature
+		payload.VerifyManifestSigningKey = *snapshot.ManifestSigningKey
+	}
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	job, err := s.repo.CreateJobWithTargetWorker(ctx, snapshot.TenantID, types.JobTypeVerifySnapshot,
+		&snapshot.SourceID, *snapshot.WorkerID, payloadJSON, verifyJobPriority)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create verify job: %w", err)
+	}
+
+	jobMsg := map[string]any{
+		"job_id":     job.ID,
+		"tenant_id":  snapshot.TenantID,
+		"type":       string(types.JobTypeVerifySnapshot),
+		"priority":   verifyJobPriority,
+		"created_at": job.CreatedAt.Format(time.RFC3339),
+	}
+	jobMsgJSON, err := json.Marshal(jobMsg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal job message: %w", err)
+	}
+
+	if err := s.redis.LPush(ctx, JobQueueKey, jobMsgJSON).Err(); err != nil {
+		s.LogSystemError(ctx, "Redis: failed to enqueue verify snapshot job", err, map[string]any{
+			"job_id":      job.ID,
+			"tenant_id":   snapshot.TenantID,
+			"snapshot_id": snapshotID,
+		})
+		return nil, fmt.Errorf("failed to enqueue verify job: %w", err)
+	}
+
+	return job, nil
+}
+
+// recordVerification stores the result of a verify_snapshot job on the snapshot
+func (s *Service) recordVerification(ctx context.Context, job *repository.Job, result *types.SnapshotVerification) error {
+	var payload types.JobPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return fmt.Errorf("failed to parse verify job payload: %w", err)
+	}
+	if payload.VerifySnapshotID == nil || *payload.VerifySnapshotID != result.SnapshotID {
+		return fmt.Errorf("verification is for snapshot %s, not the job's", result.SnapshotID)
+	}
+	if result.Status != types.SnapshotIntegrityVerified && result.Status != types.SnapshotIntegrityCorrupt {
+		return fmt.Errorf("invalid verification status: %s", result.Status)
+	}
+
+	if err := s.repo.RecordSnapshotVerification(ctx, result.SnapshotID, string(result.Status), result.Error); err != nil {
+		return err
+	}
+
+	if result.Status == types.SnapshotIntegrityCorrupt {
+		s.LogSystemEvent(ctx, "error", fmt.Sprintf("snapshot %s failed verification: %s", result.SnapshotID, result.Error), map[string]any{
+			"snapshot_id": result.SnapshotID,
+			"tenant_id":   job.TenantID,
+			"job_id":      job.ID,
+			"decrypted":   result.Decrypted,
+		})
+	}
+	return nil
+}
+
+// Verify schedules
+
+// CreateVerifyScheduleRequest is the request to create a verify schedule. Without a
+// source_id the schedule covers every source of the tenant that has no schedule of
+// its own.
+type CreateVerifyScheduleRequest struct {
+	TenantID        string  `json:"tenant_id"`
+	SourceID        *string `json:"source_id,omitempty"`
+	IntervalMinutes int     `json:"interval_minutes"`
+	TestDecrypt     bool    `json:"test_decrypt"`
+}
+
+// CreateVerifySchedule creates a verify schedule; its first run is due immediately
+func (s *Service) CreateVerifySchedule(ctx context.Context, req CreateVerifyScheduleRequest) (*repository.VerifySchedule, error) {
+	if req.IntervalMinutes <= 0 {
+		return nil, fmt.Errorf("interval_minutes must be positive")
+	}
+	if _, err := s.repo.GetTenant(ctx, req.TenantID); err != nil {
+		return nil, fmt.Errorf("tenant not found: %w", err)
+	}
+	if req.SourceID != nil {
+		source, err := s.repo.GetSource(ctx, *req.SourceID)
+		if err != nil {
+			return nil, fmt.Errorf("source not found: %w", err)
+		}
+		if source.TenantID != req.TenantID {
+			return nil, fmt.Errorf("source does not belong to tenant")
+		}
+	}
+
+	schedule, err := s.repo.CreateVerifySchedule(ctx, req.TenantID, req.SourceID, req.IntervalMinutes, req.TestDecrypt, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create verify schedule: %w", err)
+	}
+	return schedule, nil
+}
+
+// UpdateVerifyScheduleRequest is the request to update a verify schedule
+type UpdateVerifyScheduleRequest struct {
+	IntervalMinutes *int    `json:"interval_minutes,omitempty"`
+	TestDecrypt     *bool   `json:"test_decrypt,omitempty"`
+	Status          *string `json:"status,omitempty"` // "enabled" or "disabled"
+}
+
+// UpdateVerifySchedule updates a verify schedule. A new interval takes effect from
+// the last run.
+func (s *Service) UpdateVerifySchedule(ctx context.Context, scheduleID string, req UpdateVerifyScheduleRequest) (*repository.VerifySchedule, error) {
+	existing, err := s.repo.GetVerifySchedule(ctx, scheduleID)
+	if err != nil {
+		return nil, fmt.Errorf("verify schedule not found: %w", err)
+	}
+
+	intervalMinutes := existing.IntervalMinutes
+	var nextRunAt *time.Time
+	if req.IntervalMinutes != nil && *req.IntervalMinutes != existing.IntervalMinutes {
+		if *req.IntervalMinutes <= 0 {
+			return nil, fmt.Errorf("interval_minutes must be positive")
+		}
+		intervalMinutes = *req.IntervalMinutes
+		if existing.LastRunAt != nil {
+			next := existing.LastRunAt.Add(time.Duration(intervalMinutes) * time.Minute)
+			nextRunAt = &next
+		}
+	}
+
+	testDecrypt := existing.TestDecrypt
+	if req.TestDecrypt != nil {
+		testDecrypt = *req.TestDecrypt
+	}
+
+	status := existing.Status
+	if req.Status != nil {
+		if *req.Status != "enabled" && *req.Status != "disabled" {
+			return nil, fmt.Errorf("status must be enabled or disabled")
+		}
+		status = *req.Status
+	}
+
+	schedule, err := s.repo.UpdateVerifySchedule(ctx, scheduleID, intervalMinutes, testDecrypt, status, nextRunAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update verify schedule: %w", err)
+	}
+	return schedule, nil
+}
+
+// GetVerifySchedule retrieves a verify schedule by ID
+func (s *Service) GetVerifySchedule(ctx context.Context, scheduleID string) (*repository.VerifySchedule, error) {
+	return s.repo.GetVerifySchedule(ctx, scheduleID)
+}
+
+// ListVerifySchedules lists verify schedules, optionally for a single tenant
+func (s *Service) ListVerifySchedules(ctx context.Context, tenantID string) ([]*repository.VerifySchedule, error) {
+	return s.repo.ListVerifySchedules(ctx, tenantID)
+}
+
+// DeleteVerifySchedule deletes a verify schedule
+func (s *Service) DeleteVerifySchedule(ctx context.Context, scheduleID string) error {
+	return s.repo.DeleteVerifySchedule(ctx, scheduleID)
+}
+
+// ProcessDueVerifySchedules enqueues verify jobs for the snapshots of every due verify
+// schedule that were not verified within the schedule's interval. Returns the number
+// of jobs created.
+func (s *Service) ProcessDueVerifySchedules(ctx context.Context) (int, error) {
+	now := time.Now()
+
+	schedules, err := s.repo.GetDueVerifySchedules(ctx, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get due verify schedules: %w", err)
+	}
+
+	jobsCreated := 0
+	for _, schedule := range schedules {
+		interval := time.Duration(schedule.IntervalMinutes) * time.Minute
+
+		sourceID := ""
+		if schedule.SourceID != nil {
+			sourceID = *schedule.SourceID
+		}
+		snapshotIDs, err := s.repo.ListSnapshotsDueForVerify(ctx, schedule.TenantID, sourceID, now.Add(-interval))
+		if err != nil {
+			log.Printf("verify scheduler: failed to list snapshots for schedule %s: %v", schedule.ID, err)
+			continue
+		}
+
+		for _, snapshotID := range snapshotIDs {
+			if _, err := s.EnqueueVerifyJob(ctx, snapshotID, schedule.TestDecrypt); err != nil {
+				log.Printf("verify scheduler: failed to enqueue verify job for snapshot %s: %v", snapshotID, err)
+				continue
+			}
+			jobsCreated++
+		}
+
+		if err := s.repo.UpdateVerifyScheduleRunTimes(ctx, schedule.ID, now, now.Add(interval)); err != nil {
+			log.Printf("verify scheduler: failed to update run times for schedule %s: %v", schedule.ID, err)
+		}
+	}
+
+	return jobsCreated, nil
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"time"
 )
 
@@ -28,11 +29,35 @@ func NewHubClient(baseURL string) *HubClient {
 
 // RestoreJobClaimResponse is the response when claiming a restore job
 type RestoreJobClaimResponse struct {
-	JobID      string `json:"job_id"`
-	TenantID   string `json:"tenant_id"`
-	SourceID   string `json:"source_id"`
-	SnapshotID string `json:"snapshot_id"`
-	LocalPath  string `json:"local_path"` // Actual path to snapshot on worker storage
+	JobID      string   `json:"job_id"`
+	TenantID   string   `json:"tenant_id"`
+	SourceID   string   `json:"source_id"`
+	SnapshotID string   `json:"snapshot_id"`
+	LocalPath  string   `json:"local_path"`      // Actual path to snapshot on worker storage
+	Paths      []string `json:"paths,omitempty"` // Restrict the restore to these archive paths
+	// Lay the restore out by the remote paths the trees were backed up from
+	OriginalLocations bool `json:"original_locations,omitempty"`
+
+	StorageBackend string `json:"storage_backend"`
+	Bucket         string `json:"bucket,omitempty"`
+	ObjectKey      string `json:"object_key,omitempty"`
+
+	ManifestSignature  string `json:"manifest_signature,omitempty"`
+	ManifestSigningKey string `json:"manifest_signing_key,omitempty"`
+
+	// Other copies of the snapshot, tried in order when the original cannot be read
+	Replicas []RestoreReplica `json:"replicas,omitempty"`
+}
+
+// RestoreReplica is another copy of the snapshot a restore can read from
+type RestoreReplica struct {
+	WorkerID       string `json:"worker_id"`
+	StorageBackend string `json:"storage_backend"`
+	LocalPath      string `json:"local_path,omitempty"`
+	Bucket         string `json:"bucket,omitempty"`
+	ObjectKey      string `json:"object_key,omitempty"`
+	// For sftp: the offsite target holding the copy under ObjectKey
+	OffsiteTargetID string `json:"offsite_target_id,omitempty"`
 }
 
 // RestoreJobCompleteRequest is the request to complete a restore job
@@ -45,6 +70,8 @@ type RestoreJobCompleteRequest struct {
 	SizeBytes     int64  `json:"size_bytes,omitempty"`
 	ExpiresAt     string `json:"expires_at,omitempty"`
 	DurationMs    int64  `json:"duration_ms,omitempty"`
+	// ManifestVerification is how the manifest was authenticated: "verified" or "unsigned"
+	ManifestVerification string `json:"manifest_verification,omitempty"`
 }
 
 // RegisterServiceRequest is the request to register a restore service
@@ -184,9 +211,15 @@ func (c *HubClient) SendHeartbeat(ctx context.Context, req ServiceHeartbeatReque
 	return nil
 }
 
-// GetTenantPrivateKey fetches and decrypts a tenant's private key from the Hub
-func (c *HubClient) GetTenantPrivateKey(ctx context.Context, tenantID string) (*TenantPrivateKeyResponse, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/internal/tenants/"+tenantID+"/private-key", nil)
+// GetTenantPrivateKey fetches and decrypts a tenant's private key from the Hub. keyID
+// selects the key a manifest names; without it every key the tenant can decrypt with
+// is returned.
+func (c *HubClient) GetTenantPrivateKey(ctx context.Context, tenantID, keyID string) (*TenantPrivateKeyResponse, error) {
+	endpoint := c.baseURL + "/internal/tenants/" + tenantID + "/private-key"
+	if keyID != "" {
+		endpoint += "?key_id=" + url.QueryEscape(keyID)
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -210,6 +243,99 @@ func (c *HubClient) GetTenantPrivateKey(ctx context.Context, tenantID string) (*
 	return &keyResp, nil
 }
 
+// OffsiteTarget is a tenant's SFTP server with its decrypted secret
+type OffsiteTarget struct {
+	ID         string `json:"id"`
+	TenantID   string `json:"tenant_id"`
+	Host       string `json:"host"`
+	Port       int    `json:"port"`
+	Username   string `json:"username"`
+	Password   string `json:"password,omitempty"`
+	PrivateKey string `json:"private_key,omitempty"`
+	BasePath   string `json:"base_path"`
+	HostKey    string `json:"host_key,omitempty"`
+}
+
+// GetOffsiteTarget fetches a tenant's offsite target, with its decrypted secret,
+// from the Hub
+func (c *HubClient) GetOffsiteTarget(ctx context.Context, targetID string) (*OffsiteTarget, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/internal/offsite-targets/"+targetID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get offsite target: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("get offsite target failed: status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	var target OffsiteTarget
+	if err := json.NewDecoder(resp.Body).Decode(&target); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &target, nil
+}
+
+// SnapshotLocator is where the hub recorded a snapshot as stored
+type SnapshotLocator struct {
+	StorageBackend string `json:"storage_backend"`
+	LocalPath      string `json:"local_path,omitempty"`
+	Bucket         string `json:"bucket,omitempty"`
+	ObjectKey      string `json:"object_key,omitempty"`
+}
+
+// FileListing is the snapshot a file listing token grants access to
+type FileListing struct {
+	TenantID   string          `json:"tenant_id"`
+	SourceID   string          `json:"source_id"`
+	SnapshotID string          `json:"snapshot_id"`
+	Locator    SnapshotLocator `json:"locator"`
+}
+
+// AuthorizeFileListing redeems a file listing token the Hub sent with a listing
+// request and returns the snapshot it grants access to. It returns nil when the token
+// is not valid.
+func (c *HubClient) AuthorizeFileListing(ctx context.Context, token string) (*FileListing, error) {
+	body, err := json.Marshal(map[string]string{"token": token})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/internal/file-listings/authorize", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to authorize file listing: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusForbidden {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("authorize file listing failed: status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	var listing FileListing
+	if err := json.NewDecoder(resp.Body).Decode(&listing); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &listing, nil
+}
+
 // DownloadExpirationResponse is the response for download expiration setting
 type DownloadExpirationResponse struct {
 	Hours int `json:"hours"`
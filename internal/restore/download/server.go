@@ -1,12 +1,17 @@
 package download
 
 import (
+	"context"
+	"errors"
+	"io/fs"
 	"log"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"xvault/pkg/types"
 )
 
 // Server handles HTTP downloads for restored backups
@@ -16,6 +21,25 @@ type Server struct {
 	tokens      map[string]*tokenInfo
 	mu          sync.RWMutex
 	downloadExpirationHours int // Configurable expiration time in hours
+	fileLister  FileLister
+}
+
+// FileListRequest identifies the snapshot whose file index the hub wants listed. Token
+// is the hub's one-off listing token; the snapshot's locator is only taken from the
+// hub in exchange for it, never from the request.
+type FileListRequest struct {
+	SnapshotID string
+	Prefix     string
+	Token      string
+}
+
+// ErrListingNotAuthorized is returned by a FileLister when the hub does not accept a
+// request's token for its snapshot
+var ErrListingNotAuthorized = errors.New("file listing not authorized")
+
+// FileLister lists the contents of a snapshot from its file index
+type FileLister interface {
+	ListSnapshotFiles(ctx context.Context, req FileListRequest) (*types.SnapshotFileList, error)
 }
 
 // tokenInfo holds information about a download token
@@ -90,6 +114,51 @@ func (s *Server) setupRoutes() {
 		// Serve the file
 		return c.Download(info.filePath, filepath.Base(info.filePath))
 	})
+
+	// Snapshot file listing, proxied by the hub
+	s.app.Get("/internal/snapshots/:id/files", s.handleListFiles)
+}
+
+// SetFileLister enables the internal snapshot file listing route
+func (s *Server) SetFileLister(lister FileLister) {
+	s.mu.Lock()
+	s.fileLister = lister
+	s.mu.Unlock()
+}
+
+// handleListFiles serves GET /internal/snapshots/:id/files for the hub. The request
+// must carry the hub's listing token as a bearer token.
+func (s *Server) handleListFiles(c *fiber.Ctx) error {
+	s.mu.RLock()
+	lister := s.fileLister
+	s.mu.RUnlock()
+	if lister == nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "file listing is not available"})
+	}
+
+	token, found := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+	if !found || token == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "missing file listing token"})
+	}
+	req := FileListRequest{
+		SnapshotID: c.Params("id"),
+		Prefix:     c.Query("prefix"),
+		Token:      token,
+	}
+
+	list, err := lister.ListSnapshotFiles(c.UserContext(), req)
+	if err != nil {
+		log.Printf("[restore] failed to list files for snapshot %s: %v", req.SnapshotID, err)
+		if errors.Is(err, ErrListingNotAuthorized) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "file listing not authorized"})
+		}
+		if errors.Is(err, fs.ErrNotExist) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "snapshot has no file index"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(list)
 }
 
 // RegisterDownload registers a file for download and returns a token
@@ -0,0 +1,64 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+
+	"xvault/internal/restore/download"
+	"xvault/pkg/snapshot"
+	"xvault/pkg/types"
+)
+
+// ListSnapshotFiles lists the files of a snapshot below req.Prefix. The snapshot's
+// locator comes from the hub in exchange for the request's token. Only the manifest
+// and the snapshot's encrypted file index are read; the archive itself never is.
+func (o *Orchestrator) ListSnapshotFiles(ctx context.Context, req download.FileListRequest) (*types.SnapshotFileList, error) {
+	listing, err := o.hubClient.AuthorizeFileListing(ctx, req.Token)
+	if err != nil {
+		return nil, err
+	}
+	if listing == nil || listing.SnapshotID != req.SnapshotID {
+		return nil, download.ErrListingNotAuthorized
+	}
+
+	loc, err := o.snapshotLocation(ctx, snapshotRef{
+		tenantID:       listing.TenantID,
+		sourceID:       listing.SourceID,
+		snapshotID:     listing.SnapshotID,
+		storageBackend: listing.Locator.StorageBackend,
+		localPath:      listing.Locator.LocalPath,
+		bucket:         listing.Locator.Bucket,
+		objectKey:      listing.Locator.ObjectKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer closeLocation(loc)
+
+	manifest, _, err := snapshot.ReadManifest(ctx, loc)
+	if err != nil {
+		return nil, err
+	}
+	keyResp, err := o.hubClient.GetTenantPrivateKey(ctx, listing.TenantID, snapshot.DecryptionKeyID(manifest))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tenant private key: %w", err)
+	}
+	index, err := snapshot.ReadFileIndex(ctx, loc, keyResp.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	files := index.Under(req.Prefix)
+	if files == nil {
+		files = []types.FileIndexEntry{}
+	}
+	fileCount, totalBytes := types.CountFiles(files)
+
+	return &types.SnapshotFileList{
+		SnapshotID: req.SnapshotID,
+		Prefix:     req.Prefix,
+		FileCount:  fileCount,
+		TotalBytes: totalBytes,
+		Files:      files,
+	}, nil
+}
@@ -0,0 +1,273 @@
+package orchestrator
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"xvault/pkg/types"
+)
+
+// extractArchive unpacks a tar stream and re-materialises it under destDir.
+// Directories, symlinks, hardlinks, modes and mtimes are restored; ownership is applied
+// when running as root and is always returned keyed by archive entry name so it can be
+// carried into the download. When include is non-nil, entries it rejects are skipped.
+// When relocate is non-nil, entries (and hardlink targets) are written at the path it
+// maps their archive name to. Returns the number of regular file bytes written.
+func extractArchive(r io.Reader, destDir string, include func(name string) bool, relocate func(name string) string) (map[string]types.FileOwner, int64, error) {
+	destDir = filepath.Clean(destDir)
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return nil, 0, fmt.Errorf("failed to create extract directory: %w", err)
+	}
+
+	owners := make(map[string]types.FileOwner)
+	canChown := os.Geteuid() == 0
+
+	// Directory attributes are applied last so extracting children does not reset mtimes
+	var dirs []*tar.Header
+
+	var totalBytes int64
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to read tar entry: %w", err)
+		}
+
+		name := strings.TrimSuffix(header.Name, "/")
+		if include != nil && !include(name) {
+			continue
+		}
+		if relocate != nil {
+			name = relocate(name)
+			header.Name = name
+			if header.Typeflag == tar.TypeLink {
+				header.Linkname = relocate(header.Linkname)
+			}
+		}
+
+		target, err := safeJoin(destDir, name)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return nil, 0, fmt.Errorf("failed to create parent directory: %w", err)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return nil, 0, fmt.Errorf("failed to create directory %s: %w", name, err)
+			}
+			dirs = append(dirs, header)
+
+		case tar.TypeReg:
+			n, err := writeRegularFile(target, tr, header)
+			if err != nil {
+				return nil, 0, err
+			}
+			totalBytes += n
+
+		case tar.TypeSymlink:
+			if err := os.Symlink(header.Linkname, target); err != nil {
+				return nil, 0, fmt.Errorf("failed to create symlink %s: %w", name, err)
+			}
+
+		case tar.TypeLink:
+			linkSource, err := safeJoin(destDir, header.Linkname)
+			if err != nil {
+				return nil, 0, err
+			}
+			if err := os.Link(linkSource, target); err != nil {
+				return nil, 0, fmt.Errorf("failed to create hardlink %s: %w", name, err)
+			}
+
+		default:
+			// Device nodes and FIFOs are not re-created in a downloadable restore
+			continue
+		}
+
+		owners[name] = types.FileOwner{
+			UID:   header.Uid,
+			GID:   header.Gid,
+			Uname: header.Uname,
+			Gname: header.Gname,
+		}
+		if canChown {
+			os.Lchown(target, header.Uid, header.Gid)
+		}
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		target, _ := safeJoin(destDir, strings.TrimSuffix(dirs[i].Name, "/"))
+		if err := applyHeaderAttrs(target, dirs[i]); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return owners, totalBytes, nil
+}
+
+// writeRegularFile writes a regular file entry and restores its mode and mtime
+func writeRegularFile(target string, r io.Reader, header *tar.Header) (int64, error) {
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create file %s: %w", header.Name, err)
+	}
+
+	n, err := io.Copy(f, r)
+	if err != nil {
+		f.Close()
+		return 0, fmt.Errorf("failed to write file %s: %w", header.Name, err)
+	}
+	if err := f.Close(); err != nil {
+		return 0, fmt.Errorf("failed to close file %s: %w", header.Name, err)
+	}
+
+	if err := applyHeaderAttrs(target, header); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// applyHeaderAttrs sets the mode and mtime recorded in a tar header on a path
+func applyHeaderAttrs(target string, header *tar.Header) error {
+	if err := os.Chmod(target, header.FileInfo().Mode()&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky)); err != nil {
+		return fmt.Errorf("failed to set mode on %s: %w", header.Name, err)
+	}
+	if err := os.Chtimes(target, header.ModTime, header.ModTime); err != nil {
+		return fmt.Errorf("failed to set mtime on %s: %w", header.Name, err)
+	}
+	return nil
+}
+
+// safeJoin joins an archive entry name onto destDir and rejects names that would
+// escape it, either directly or through a previously extracted symlink
+func safeJoin(destDir, name string) (string, error) {
+	target := filepath.Join(destDir, filepath.FromSlash(name))
+	if target != destDir && !strings.HasPrefix(target, destDir+string(os.PathSeparator)) {
+		return "", fmt.Errorf("archive entry %q escapes the restore directory", name)
+	}
+
+	parent, err := filepath.EvalSymlinks(filepath.Dir(target))
+	if err == nil && parent != destDir && !strings.HasPrefix(parent, destDir+string(os.PathSeparator)) {
+		return "", fmt.Errorf("archive entry %q resolves outside the restore directory", name)
+	}
+
+	return target, nil
+}
+
+// createZipFromDir writes every entry under srcDir to a ZIP file, preserving modes,
+// mtimes and symlinks. Ownership from owners is stored in the Info-ZIP Unix extra field.
+func createZipFromDir(srcDir, zipPath string, owners map[string]types.FileOwner) error {
+	zipFile, err := os.Create(zipPath)
+	if err != nil {
+		return fmt.Errorf("failed to create zip file: %w", err)
+	}
+	defer zipFile.Close()
+
+	zipWriter := zip.NewWriter(zipFile)
+
+	srcDir = filepath.Clean(srcDir)
+	err = filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == srcDir {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return fmt.Errorf("failed to get relative path: %w", err)
+		}
+		name := filepath.ToSlash(relPath)
+
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return fmt.Errorf("failed to build zip header for %s: %w", name, err)
+		}
+		header.Name = name
+		if info.IsDir() {
+			header.Name += "/"
+		} else if info.Mode().IsRegular() {
+			header.Method = zip.Deflate
+		}
+		if owner, ok := owners[name]; ok {
+			header.Extra = append(header.Extra, unixOwnerExtra(owner)...)
+		}
+
+		writer, err := zipWriter.CreateHeader(header)
+		if err != nil {
+			return fmt.Errorf("failed to create zip entry: %w", err)
+		}
+
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			// By convention a zip symlink stores its target as the entry content
+			target, err := os.Readlink(path)
+			if err != nil {
+				return fmt.Errorf("failed to read symlink %s: %w", name, err)
+			}
+			if _, err := io.WriteString(writer, target); err != nil {
+				return fmt.Errorf("failed to write to zip: %w", err)
+			}
+		case info.Mode().IsRegular():
+			f, err := os.Open(path)
+			if err != nil {
+				return fmt.Errorf("failed to open %s: %w", name, err)
+			}
+			defer f.Close()
+			if _, err := io.Copy(writer, f); err != nil {
+				return fmt.Errorf("failed to write to zip: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := zipWriter.Close(); err != nil {
+		return fmt.Errorf("failed to finalize zip: %w", err)
+	}
+	return nil
+}
+
+// unixOwnerExtra encodes uid/gid as an Info-ZIP "ux" (0x7875) extra field
+func unixOwnerExtra(owner types.FileOwner) []byte {
+	b := make([]byte, 15)
+	binary.LittleEndian.PutUint16(b[0:2], 0x7875)
+	binary.LittleEndian.PutUint16(b[2:4], 11)
+	b[4] = 1 // version
+	b[5] = 4 // uid size
+	binary.LittleEndian.PutUint32(b[6:10], uint32(owner.UID))
+	b[10] = 4 // gid size
+	binary.LittleEndian.PutUint32(b[11:15], uint32(owner.GID))
+	return b
+}
+
+// originalLocations maps archive entry names to the remote paths their trees were
+// backed up from (without the leading slash), using the roots recorded in the manifest
+func originalLocations(roots []types.SourceRoot) func(name string) string {
+	return func(name string) string {
+		for _, root := range roots {
+			if root.ArchivePath != "" && name != root.ArchivePath && !strings.HasPrefix(name, root.ArchivePath+"/") {
+				continue
+			}
+			rest := strings.TrimPrefix(strings.TrimPrefix(name, root.ArchivePath), "/")
+			return path.Join(types.ArchivePathFor(root.Path, ""), rest)
+		}
+		return name
+	}
+}
@@ -1,9 +1,8 @@
 package orchestrator
 
 import (
-	"archive/zip"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -14,17 +13,23 @@ import (
 
 	"xvault/internal/restore/client"
 	"xvault/internal/restore/download"
-	"xvault/pkg/crypto"
+	"xvault/pkg/backend"
+	"xvault/pkg/snapshot"
+	"xvault/pkg/types"
 )
 
 // Orchestrator manages the restore service job execution loop
 type Orchestrator struct {
-	serviceID      string
-	hubClient      *client.HubClient
-	downloadServer *download.Server
-	workerStorage  string // Path to worker storage (read-only shared volume)
+	serviceID       string
+	hubClient       *client.HubClient
+	downloadServer  *download.Server
+	workerStorage   string // Path to worker storage (read-only shared volume)
 	downloadBaseURL string
-	pollInterval   time.Duration
+	pollInterval    time.Duration
+
+	// objectStore holds the credentials for snapshots in S3-compatible buckets; nil
+	// when only local snapshots can be restored
+	objectStore *backend.S3Config
 }
 
 // NewOrchestrator creates a new restore service orchestrator
@@ -39,6 +44,12 @@ func NewOrchestrator(serviceID string, hubClient *client.HubClient, workerStorag
 	}
 }
 
+// SetObjectStore enables restoring snapshots that workers uploaded to S3-compatible
+// buckets. The bucket of each snapshot comes from its locator.
+func (o *Orchestrator) SetObjectStore(config backend.S3Config) {
+	o.objectStore = &config
+}
+
 // Run starts the restore service job loop
 func (o *Orchestrator) Run(ctx context.Context) error {
 	log.Printf("restore service %s starting job loop", o.serviceID)
@@ -83,7 +94,7 @@ func (o *Orchestrator) registerService(ctx context.Context) error {
 		Type:      "restore",
 		Name:      fmt.Sprintf("Restore Service %s", o.serviceID),
 		Capabilities: map[string]any{
-			"storage": "local_fs",
+			"storage": o.storageBackends(),
 		},
 	}
 
@@ -135,40 +146,18 @@ func (o *Orchestrator) processRestoreJob(ctx context.Context, job *client.Restor
 
 	log.Printf("restore service %s processing restore for snapshot %s", o.serviceID, job.SnapshotID)
 
-	// Use the LocalPath from the claim response (contains actual directory name)
-	// Fallback to constructed path if LocalPath is empty
-	var snapshotPath string
-	if job.LocalPath != "" {
-		snapshotPath = job.LocalPath
-	} else {
-		snapshotPath = filepath.Join(o.workerStorage, "tenants", job.TenantID, "sources", job.SourceID, "snapshots", job.SnapshotID)
-	}
-	backupPath := filepath.Join(snapshotPath, "backup.tar.zst.enc")
-
-	// Read the manifest to verify encryption info
-	manifestPath := filepath.Join(snapshotPath, "manifest.json")
-	manifestBytes, err := os.ReadFile(manifestPath)
+	loc, manifest, err := o.openManifest(ctx, job)
 	if err != nil {
 		return client.RestoreJobCompleteRequest{
 			ServiceID: o.serviceID,
 			Status:    "failed",
-			Error:     fmt.Sprintf("failed to read manifest: %v", err),
+			Error:     err.Error(),
 		}, err
 	}
+	defer closeLocation(loc)
 
-	var manifest struct {
-		EncryptionAlgorithm string `json:"encryption_algorithm"`
-	}
-	if err := json.Unmarshal(manifestBytes, &manifest); err != nil {
-		return client.RestoreJobCompleteRequest{
-			ServiceID: o.serviceID,
-			Status:    "failed",
-			Error:     fmt.Sprintf("failed to parse manifest: %v", err),
-		}, err
-	}
-
-	// Get tenant private key for decryption
-	keyResp, err := o.hubClient.GetTenantPrivateKey(ctx, job.TenantID)
+	// Get the tenant private key the snapshot is encrypted to
+	keyResp, err := o.hubClient.GetTenantPrivateKey(ctx, job.TenantID, snapshot.DecryptionKeyID(manifest))
 	if err != nil {
 		return client.RestoreJobCompleteRequest{
 			ServiceID: o.serviceID,
@@ -177,18 +166,16 @@ func (o *Orchestrator) processRestoreJob(ctx context.Context, job *client.Restor
 		}, err
 	}
 
-	// Read the encrypted backup file
-	encryptedData, err := os.ReadFile(backupPath)
-	if err != nil {
-		return client.RestoreJobCompleteRequest{
-			ServiceID: o.serviceID,
-			Status:    "failed",
-			Error:     fmt.Sprintf("failed to read encrypted backup: %v", err),
-		}, err
+	// Open the snapshot as a plaintext tar stream; data is decrypted as it is read.
+	// A restore of selected paths reads only the parts of the archive holding them.
+	var tarStream io.ReadCloser
+	var include func(string) bool
+	if len(job.Paths) > 0 {
+		log.Printf("restore service %s restoring %d path(s) from snapshot %s", o.serviceID, len(job.Paths), job.SnapshotID)
+		tarStream, include, err = openSelection(ctx, loc, manifest, keyResp.PrivateKey, job.Paths)
+	} else {
+		tarStream, err = snapshot.OpenTarStream(ctx, loc, manifest, keyResp.PrivateKey)
 	}
-
-	// Decrypt the backup using Age
-	decryptedData, err := crypto.DecryptWithPrivateKey(encryptedData, keyResp.PrivateKey)
 	if err != nil {
 		return client.RestoreJobCompleteRequest{
 			ServiceID: o.serviceID,
@@ -196,8 +183,7 @@ func (o *Orchestrator) processRestoreJob(ctx context.Context, job *client.Restor
 			Error:     fmt.Sprintf("failed to decrypt backup: %v", err),
 		}, err
 	}
-
-	log.Printf("decrypted backup for snapshot %s (%d bytes)", job.SnapshotID, len(decryptedData))
+	defer tarStream.Close()
 
 	// Create temp directory for processing
 	tempDir := filepath.Join(os.TempDir(), "restore-"+job.SnapshotID)
@@ -210,19 +196,45 @@ func (o *Orchestrator) processRestoreJob(ctx context.Context, job *client.Restor
 	}
 	defer os.RemoveAll(tempDir)
 
-	// Write the decrypted tar.zst to temp
-	decryptedPath := filepath.Join(tempDir, "backup.tar.zst")
-	if err := os.WriteFile(decryptedPath, decryptedData, 0644); err != nil {
+	// Trees are laid out by their archive paths unless the original locations were
+	// asked for, which only snapshots recording their roots can provide
+	var relocate func(string) string
+	if job.OriginalLocations {
+		if len(manifest.ContentSummary.Roots) == 0 {
+			err := fmt.Errorf("snapshot %s does not record the original locations of its paths", job.SnapshotID)
+			return client.RestoreJobCompleteRequest{
+				ServiceID: o.serviceID,
+				Status:    "failed",
+				Error:     err.Error(),
+			}, err
+		}
+		relocate = originalLocations(manifest.ContentSummary.Roots)
+	}
+
+	// Re-materialise the archive with its recorded metadata, then package it for download
+	filesDir := filepath.Join(tempDir, "files")
+	owners, restoredBytes, err := extractArchive(tarStream, filesDir, include, relocate)
+	if err != nil {
 		return client.RestoreJobCompleteRequest{
 			ServiceID: o.serviceID,
 			Status:    "failed",
-			Error:     fmt.Sprintf("failed to write decrypted data: %v", err),
+			Error:     fmt.Sprintf("failed to extract backup: %v", err),
 		}, err
 	}
 
-	// Create ZIP file from the decrypted archive
+	// Nothing is published unless the artifact matches the manifest
+	if err := verifyStream(tarStream); err != nil {
+		return client.RestoreJobCompleteRequest{
+			ServiceID: o.serviceID,
+			Status:    "failed",
+			Error:     fmt.Sprintf("failed to verify backup: %v", err),
+		}, err
+	}
+
+	log.Printf("extracted backup for snapshot %s (%d bytes, %d entries)", job.SnapshotID, restoredBytes, len(owners))
+
 	zipPath := filepath.Join(tempDir, "restore-"+job.SnapshotID+".zip")
-	if err := o.createZipFromDecrypted(decryptedPath, zipPath); err != nil {
+	if err := createZipFromDir(filesDir, zipPath, owners); err != nil {
 		return client.RestoreJobCompleteRequest{
 			ServiceID: o.serviceID,
 			Status:    "failed",
@@ -293,61 +305,155 @@ func (o *Orchestrator) processRestoreJob(ctx context.Context, job *client.Restor
 	durationMs := finishTime.Sub(startTime).Milliseconds()
 
 	return client.RestoreJobCompleteRequest{
-		ServiceID:     o.serviceID,
-		Status:        "completed",
-		DownloadURL:   downloadURL,
-		DownloadToken: token,
-		SizeBytes:     zipSize,
-		ExpiresAt:     expiresAt.Format(time.RFC3339),
-		DurationMs:    durationMs,
+		ServiceID:            o.serviceID,
+		Status:               "completed",
+		DownloadURL:          downloadURL,
+		DownloadToken:        token,
+		SizeBytes:            zipSize,
+		ExpiresAt:            expiresAt.Format(time.RFC3339),
+		DurationMs:           durationMs,
+		ManifestVerification: manifestVerification(job),
 	}, nil
 }
 
-// createZipFromDecrypted creates a ZIP file from the decrypted tar.zst archive
-func (o *Orchestrator) createZipFromDecrypted(decryptedPath, zipPath string) error {
-	// Create a new ZIP file
-	zipFile, err := os.Create(zipPath)
-	if err != nil {
-		return fmt.Errorf("failed to create zip file: %w", err)
+// openManifest finds a readable copy of the snapshot: the original first, then each
+// replica in turn. A copy is used once its manifest has been read (any historical
+// format is parsed into the current model) and checked against the recorded signature,
+// so sizes and hashes are never taken from an altered manifest.
+func (o *Orchestrator) openManifest(ctx context.Context, job *client.RestoreJobClaimResponse) (snapshot.Location, *types.SnapshotManifest, error) {
+	refs := []snapshotRef{{
+		tenantID:       job.TenantID,
+		sourceID:       job.SourceID,
+		snapshotID:     job.SnapshotID,
+		storageBackend: job.StorageBackend,
+		localPath:      job.LocalPath,
+		bucket:         job.Bucket,
+		objectKey:      job.ObjectKey,
+	}}
+	for _, replica := range job.Replicas {
+		refs = append(refs, snapshotRef{
+			tenantID:        job.TenantID,
+			sourceID:        job.SourceID,
+			snapshotID:      job.SnapshotID,
+			storageBackend:  replica.StorageBackend,
+			localPath:       replica.LocalPath,
+			bucket:          replica.Bucket,
+			objectKey:       replica.ObjectKey,
+			offsiteTargetID: replica.OffsiteTargetID,
+		})
 	}
-	defer zipFile.Close()
 
-	zipWriter := zip.NewWriter(zipFile)
-	defer zipWriter.Close()
+	var errs []error
+	for i, ref := range refs {
+		loc, manifest, err := o.readManifest(ctx, job, ref)
+		if err == nil {
+			if i > 0 {
+				log.Printf("restore service %s reading snapshot %s from replica on worker %s", o.serviceID, job.SnapshotID, job.Replicas[i-1].WorkerID)
+			}
+			return loc, manifest, nil
+		}
+		if i > 0 {
+			err = fmt.Errorf("replica on worker %s: %w", job.Replicas[i-1].WorkerID, err)
+		}
+		errs = append(errs, err)
+	}
+	return snapshot.Location{}, nil, errors.Join(errs...)
+}
 
-	// Open the decrypted file
-	srcFile, err := os.Open(decryptedPath)
+// readManifest reads and verifies the manifest of one copy of a snapshot
+func (o *Orchestrator) readManifest(ctx context.Context, job *client.RestoreJobClaimResponse, ref snapshotRef) (snapshot.Location, *types.SnapshotManifest, error) {
+	loc, err := o.snapshotLocation(ctx, ref)
 	if err != nil {
-		return fmt.Errorf("failed to open decrypted file: %w", err)
+		return snapshot.Location{}, nil, err
 	}
-	defer srcFile.Close()
-
-	// Get file info
-	srcInfo, err := srcFile.Stat()
+	manifest, manifestBytes, err := snapshot.ReadManifest(ctx, loc)
 	if err != nil {
-		return fmt.Errorf("failed to stat decrypted file: %w", err)
+		closeLocation(loc)
+		return snapshot.Location{}, nil, err
+	}
+	if err := o.verifyManifest(job, manifestBytes); err != nil {
+		closeLocation(loc)
+		return snapshot.Location{}, nil, err
 	}
+	return loc, manifest, nil
+}
 
-	// Create a file in the ZIP
-	header := &zip.FileHeader{
-		Name:   filepath.Base(decryptedPath),
-		Method: zip.Deflate,
+// snapshotRef identifies a snapshot and the locator the hub recorded for it
+type snapshotRef struct {
+	tenantID, sourceID, snapshotID string
+
+	storageBackend string
+	localPath      string
+	bucket         string
+	objectKey      string
+	// offsiteTargetID is the tenant's offsite target holding an sftp copy
+	offsiteTargetID string
+}
+
+// snapshotLocation picks the backend holding a snapshot from its locator. Local
+// snapshots are read from the shared worker volume: the LocalPath recorded by the hub
+// holds the actual directory name, and the path is constructed when it is empty.
+// Copies on an offsite target are read over a connection that closeLocation closes.
+func (o *Orchestrator) snapshotLocation(ctx context.Context, ref snapshotRef) (snapshot.Location, error) {
+	if ref.storageBackend == string(types.StorageBackendSFTP) {
+		target, err := o.hubClient.GetOffsiteTarget(ctx, ref.offsiteTargetID)
+		if err != nil {
+			return snapshot.Location{}, err
+		}
+		if target.TenantID != ref.tenantID {
+			return snapshot.Location{}, fmt.Errorf("offsite target %s does not belong to tenant %s", ref.offsiteTargetID, ref.tenantID)
+		}
+		store, err := backend.NewSFTP(backend.SFTPConfig{
+			Host:       target.Host,
+			Port:       target.Port,
+			Username:   target.Username,
+			Password:   target.Password,
+			PrivateKey: target.PrivateKey,
+			HostKey:    target.HostKey,
+			BasePath:   target.BasePath,
+		})
+		if err != nil {
+			return snapshot.Location{}, fmt.Errorf("failed to connect to offsite target %s: %w", target.Host, err)
+		}
+		return snapshot.Location{Backend: store, Prefix: ref.objectKey}, nil
+	}
+	if ref.storageBackend == string(types.StorageBackendS3) {
+		if o.objectStore == nil {
+			return snapshot.Location{}, fmt.Errorf("snapshot %s is in bucket %s but no object store is configured", ref.snapshotID, ref.bucket)
+		}
+		config := *o.objectStore
+		config.Bucket = ref.bucket
+		store, err := backend.NewS3(config)
+		if err != nil {
+			return snapshot.Location{}, err
+		}
+		return snapshot.Location{Backend: store, Prefix: ref.objectKey}, nil
 	}
-	header.SetModTime(time.Now())
-	header.SetMode(0644)
 
-	writer, err := zipWriter.CreateHeader(header)
+	snapshotPath := ref.localPath
+	if snapshotPath == "" {
+		snapshotPath = filepath.Join(o.workerStorage, "tenants", ref.tenantID, "sources", ref.sourceID, "snapshots", ref.snapshotID)
+	}
+	snapshotPath, err := filepath.Abs(snapshotPath)
 	if err != nil {
-		return fmt.Errorf("failed to create zip entry: %w", err)
+		return snapshot.Location{}, err
 	}
+	return snapshot.Location{Backend: backend.NewLocal("/"), Prefix: strings.TrimPrefix(filepath.ToSlash(snapshotPath), "/")}, nil
+}
 
-	// Copy the file content
-	if _, err := io.Copy(writer, srcFile); err != nil {
-		return fmt.Errorf("failed to write to zip: %w", err)
+// closeLocation closes the connection a location's backend holds, if any
+func closeLocation(loc snapshot.Location) {
+	if closer, ok := loc.Backend.(io.Closer); ok {
+		closer.Close()
 	}
+}
 
-	log.Printf("created zip file: %s (size: %d bytes)", zipPath, srcInfo.Size())
-	return nil
+// storageBackends lists the backends this service can restore from
+func (o *Orchestrator) storageBackends() []string {
+	if o.objectStore != nil {
+		return []string{string(types.StorageBackendLocalFS), string(types.StorageBackendS3), string(types.StorageBackendSFTP)}
+	}
+	return []string{string(types.StorageBackendLocalFS), string(types.StorageBackendSFTP)}
 }
 
 // Shutdown gracefully shuts down the restore service
@@ -0,0 +1,282 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"xvault/pkg/crypto"
+	"xvault/pkg/snapshot"
+	"xvault/pkg/types"
+)
+
+// openSelection returns a tar stream for a restore limited to paths, and a filter for
+// extractArchive (nil when the stream holds only selected entries). When the snapshot's
+// file index records archive offsets, only the zstd frames or repository chunks
+// covering the selected entries are decrypted; older snapshots are streamed whole and
+// filtered.
+func openSelection(ctx context.Context, loc snapshot.Location, manifest *types.SnapshotManifest, privateKey string, paths []string) (io.ReadCloser, func(string) bool, error) {
+	index, err := snapshot.ReadFileIndex(ctx, loc, privateKey)
+	if errors.Is(err, fs.ErrNotExist) {
+		// Snapshot predates file indexes: match names as the archive is read
+		stream, err := snapshot.OpenTarStream(ctx, loc, manifest, privateKey)
+		return stream, func(name string) bool { return underAny(name, paths) }, err
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	entries := selectEntries(index, paths)
+	if len(entries) == 0 {
+		return nil, nil, fmt.Errorf("no archived paths match %s", strings.Join(paths, ", "))
+	}
+
+	var blocks *blockReader
+	if index.Version >= 2 {
+		if manifest.StorageMode == types.StorageModeRepository {
+			blocks, err = repositoryBlocks(ctx, loc, manifest, privateKey)
+		} else if len(index.Frames) > 0 {
+			blocks, err = artifactBlocks(ctx, loc, manifest, index.Frames, privateKey)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if blocks == nil {
+		// No offsets or single-frame artifact: stream it all and keep the selection
+		selected := make(map[string]bool, len(entries))
+		for _, entry := range entries {
+			selected[entry.Path] = true
+		}
+		stream, err := snapshot.OpenTarStream(ctx, loc, manifest, privateKey)
+		return stream, func(name string) bool { return selected[name] }, err
+	}
+
+	return newRangeStream(blocks, archiveRanges(entries)), nil, nil
+}
+
+// selectEntries returns the index entries at or below any of paths in archive order.
+// The first link of each selected hardlink is included so the link can be recreated.
+func selectEntries(index *types.FileIndex, paths []string) []types.FileIndexEntry {
+	byPath := make(map[string]types.FileIndexEntry, len(index.Entries))
+	for _, entry := range index.Entries {
+		byPath[entry.Path] = entry
+	}
+
+	selected := make(map[string]types.FileIndexEntry)
+	for _, path := range paths {
+		for _, entry := range index.Under(path) {
+			selected[entry.Path] = entry
+			if entry.Type == "hardlink" {
+				if target, ok := byPath[entry.LinkTarget]; ok {
+					selected[target.Path] = target
+				}
+			}
+		}
+	}
+
+	entries := make([]types.FileIndexEntry, 0, len(selected))
+	for _, entry := range selected {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ArchiveOffset < entries[j].ArchiveOffset })
+	return entries
+}
+
+// underAny reports whether an archive name is at or below one of paths
+func underAny(name string, paths []string) bool {
+	for _, path := range paths {
+		path = strings.Trim(path, "/")
+		if path == "" || name == path || strings.HasPrefix(name, path+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// archiveRange is a byte range of the plaintext tar stream
+type archiveRange struct {
+	offset int64
+	length int64
+}
+
+// archiveRanges merges the tar stream ranges of entries sorted by offset
+func archiveRanges(entries []types.FileIndexEntry) []archiveRange {
+	var ranges []archiveRange
+	for _, entry := range entries {
+		if n := len(ranges); n > 0 && ranges[n-1].offset+ranges[n-1].length >= entry.ArchiveOffset {
+			end := max(ranges[n-1].offset+ranges[n-1].length, entry.ArchiveOffset+entry.ArchiveLength)
+			ranges[n-1].length = end - ranges[n-1].offset
+			continue
+		}
+		ranges = append(ranges, archiveRange{offset: entry.ArchiveOffset, length: entry.ArchiveLength})
+	}
+	return ranges
+}
+
+// newRangeStream returns a reader over the given ranges of the tar stream. Since each
+// range starts at an entry header, the result is itself a valid tar stream.
+func newRangeStream(blocks *blockReader, ranges []archiveRange) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		defer blocks.Close()
+		for _, r := range ranges {
+			if err := blocks.copyRange(pw, r.offset, r.length); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+		pw.Close()
+	}()
+	return pr
+}
+
+// blockReader gives random access to the tar stream through blocks that are decrypted
+// and decompressed whole: zstd frames of an artifact or chunks of a repository
+type blockReader struct {
+	offsets []int64 // tar stream offset of each block
+	sizes   []int64
+	load    func(i int) ([]byte, error)
+	closer  func() error
+
+	cached int
+	data   []byte
+}
+
+// copyRange writes length bytes of the tar stream starting at offset to w
+func (b *blockReader) copyRange(w io.Writer, offset, length int64) error {
+	for length > 0 {
+		i := sort.Search(len(b.offsets), func(i int) bool { return b.offsets[i]+b.sizes[i] > offset })
+		if i == len(b.offsets) {
+			return fmt.Errorf("offset %d is beyond the end of the archive", offset)
+		}
+
+		data, err := b.block(i)
+		if err != nil {
+			return err
+		}
+
+		start := offset - b.offsets[i]
+		n := min(int64(len(data))-start, length)
+		if _, err := w.Write(data[start : start+n]); err != nil {
+			return err
+		}
+		offset += n
+		length -= n
+	}
+	return nil
+}
+
+// block returns the plaintext of block i, keeping the last one for adjacent ranges
+func (b *blockReader) block(i int) ([]byte, error) {
+	if b.data != nil && b.cached == i {
+		return b.data, nil
+	}
+
+	data, err := b.load(i)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) != b.sizes[i] {
+		return nil, fmt.Errorf("block %d has %d bytes, index expects %d", i, len(data), b.sizes[i])
+	}
+
+	b.cached, b.data = i, data
+	return data, nil
+}
+
+// Close releases the underlying files
+func (b *blockReader) Close() error {
+	if b.closer == nil {
+		return nil
+	}
+	return b.closer()
+}
+
+// artifactBlocks reads the frames of a framed artifact, decrypting only the age STREAM
+// chunks each frame occupies; the volumes of a multi-volume artifact read as one file
+func artifactBlocks(ctx context.Context, loc snapshot.Location, manifest *types.SnapshotManifest, frames []types.ArchiveFrame, privateKey string) (*blockReader, error) {
+	file, size, err := snapshot.OpenArtifactAt(ctx, loc, manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	compressed, _, err := crypto.NewDecryptReaderAt(file, size, privateKey)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	decoder, err := zstd.NewReader(nil, zstd.WithDecoderMaxWindow(types.MaxCompressionWindow))
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to create zstd decoder: %w", err)
+	}
+
+	blocks := &blockReader{
+		offsets: make([]int64, len(frames)),
+		sizes:   make([]int64, len(frames)),
+		closer: func() error {
+			decoder.Close()
+			return file.Close()
+		},
+	}
+	for i, frame := range frames {
+		blocks.offsets[i] = frame.Offset
+		blocks.sizes[i] = frame.Size
+	}
+	algorithm := snapshot.Compression(manifest)
+	blocks.load = func(i int) ([]byte, error) {
+		frame := frames[i]
+		buf := make([]byte, frame.CompressedSize)
+		if _, err := compressed.ReadAt(buf, frame.CompressedOffset); err != nil && err != io.EOF {
+			return nil, fmt.Errorf("failed to read frame %d: %w", i, err)
+		}
+		if algorithm == types.CompressionNone {
+			return buf, nil
+		}
+		data, err := decoder.DecodeAll(buf, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress frame %d: %w", i, err)
+		}
+		return data, nil
+	}
+
+	return blocks, nil
+}
+
+// repositoryBlocks reads a repository-mode snapshot chunk by chunk
+func repositoryBlocks(ctx context.Context, loc snapshot.Location, manifest *types.SnapshotManifest, privateKey string) (*blockReader, error) {
+	index, err := snapshot.ReadChunkIndex(ctx, loc, manifest, privateKey)
+	if err != nil {
+		return nil, err
+	}
+	repoRoot := snapshot.RepositoryRoot(loc.Prefix)
+
+	blocks := &blockReader{
+		offsets: make([]int64, len(index.Chunks)),
+		sizes:   make([]int64, len(index.Chunks)),
+	}
+	var offset int64
+	for i, ref := range index.Chunks {
+		blocks.offsets[i] = offset
+		blocks.sizes[i] = ref.Size
+		offset += ref.Size
+	}
+	blocks.load = func(i int) ([]byte, error) {
+		ref := index.Chunks[i]
+		data, err := snapshot.OpenSealed(ctx, loc.Backend, snapshot.ChunkPath(repoRoot, ref.ID), privateKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read chunk %s: %w", ref.ID, err)
+		}
+		return data, nil
+	}
+
+	return blocks, nil
+}
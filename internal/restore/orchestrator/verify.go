@@ -0,0 +1,52 @@
+package orchestrator
+
+import (
+	"fmt"
+	"io"
+	"log"
+
+	"xvault/internal/restore/client"
+	"xvault/pkg/crypto"
+	"xvault/pkg/snapshot"
+)
+
+// How a restore authenticated the snapshot's manifest, reported to the hub
+const (
+	manifestVerified = "verified"
+	manifestUnsigned = "unsigned"
+)
+
+// manifestVerification returns how verifyManifest authenticates a job's manifest:
+// against the signature the hub recorded, or not at all
+func manifestVerification(job *client.RestoreJobClaimResponse) string {
+	if job.ManifestSignature == "" || job.ManifestSigningKey == "" {
+		return manifestUnsigned
+	}
+	return manifestVerified
+}
+
+// verifyManifest checks the manifest read from worker storage against the signature
+// the hub recorded for the snapshot. Snapshots without a recorded signature were
+// written before workers signed manifests (or by a worker the hub could not verify);
+// they are restored but flagged on the restore record, since their manifest cannot be
+// trusted.
+func (o *Orchestrator) verifyManifest(job *client.RestoreJobClaimResponse, manifestBytes []byte) error {
+	if manifestVerification(job) == manifestUnsigned {
+		log.Printf("restore service %s: WARNING snapshot %s has no verified manifest signature; its manifest and hashes are not authenticated", o.serviceID, job.SnapshotID)
+		return nil
+	}
+
+	if err := crypto.VerifyManifest(manifestBytes, job.ManifestSignature, job.ManifestSigningKey); err != nil {
+		return fmt.Errorf("snapshot %s manifest failed signature verification: %w", job.SnapshotID, err)
+	}
+	return nil
+}
+
+// verifyStream checks the hash of the artifact behind a fully read tar stream. Range
+// streams and repository streams verify what they read as they go.
+func verifyStream(stream io.Reader) error {
+	if v, ok := stream.(snapshot.Stream); ok {
+		return v.Verify()
+	}
+	return nil
+}
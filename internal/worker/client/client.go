@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"time"
 )
 
@@ -142,9 +143,14 @@ func (c *HubClient) GetTenantPublicKey(ctx context.Context, tenantID string) (*T
 }
 
 // GetTenantPrivateKey fetches and decrypts a tenant's private key from the Hub
-// This is used for restore operations (decrypting backup artifacts)
-func (c *HubClient) GetTenantPrivateKey(ctx context.Context, tenantID string) (*TenantPrivateKeyResponse, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/internal/tenants/"+tenantID+"/private-key", nil)
+// This is used for restore operations (decrypting backup artifacts). keyID selects the
+// key a manifest names; without it every key the tenant can decrypt with is returned.
+func (c *HubClient) GetTenantPrivateKey(ctx context.Context, tenantID, keyID string) (*TenantPrivateKeyResponse, error) {
+	endpoint := c.baseURL + "/internal/tenants/" + tenantID + "/private-key"
+	if keyID != "" {
+		endpoint += "?key_id=" + url.QueryEscape(keyID)
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -249,6 +255,136 @@ func (c *HubClient) CreateLog(ctx context.Context, req LogRequest) error {
 	return nil
 }
 
+// ReportSnapshotIntegrity reports the state of a snapshot's files to the Hub. It
+// returns false when the Hub has no record of the snapshot.
+func (c *HubClient) ReportSnapshotIntegrity(ctx context.Context, snapshotID string, req SnapshotIntegrityRequest) (bool, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/internal/snapshots/%s/integrity", c.baseURL, snapshotID)
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return false, fmt.Errorf("failed to report snapshot integrity: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return false, fmt.Errorf("report snapshot integrity failed: status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	var result struct {
+		Recorded bool `json:"recorded"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return result.Recorded, nil
+}
+
+// AuthorizeReplicaTransfer asks the Hub which snapshot copy on the worker a
+// replication token grants access to. It returns nil when the token is not valid.
+func (c *HubClient) AuthorizeReplicaTransfer(ctx context.Context, workerID, token string) (*ReplicaTransfer, error) {
+	body, err := json.Marshal(map[string]string{"worker_id": workerID, "token": token})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/internal/replicas/authorize", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to authorize replica transfer: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusForbidden {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("authorize replica transfer failed: status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	var transfer ReplicaTransfer
+	if err := json.NewDecoder(resp.Body).Decode(&transfer); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &transfer, nil
+}
+
+// GetOffsiteTarget fetches a tenant's offsite target, with its decrypted secret,
+// from the Hub
+func (c *HubClient) GetOffsiteTarget(ctx context.Context, targetID string) (*OffsiteTarget, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/internal/offsite-targets/"+targetID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get offsite target: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("get offsite target failed: status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	var target OffsiteTarget
+	if err := json.NewDecoder(resp.Body).Decode(&target); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &target, nil
+}
+
+// GetSnapshotLockStatus asks the Hub whether a snapshot is under legal hold or
+// locked. It returns nil when the Hub has no record of the snapshot.
+func (c *HubClient) GetSnapshotLockStatus(ctx context.Context, snapshotID string) (*SnapshotLockStatus, error) {
+	url := fmt.Sprintf("%s/internal/snapshots/%s/lock", c.baseURL, snapshotID)
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get snapshot lock status: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("get snapshot lock status failed: status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	var status SnapshotLockStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &status, nil
+}
+
 // Request/Response types matching Hub API
 
 type JobClaimRequest struct {
@@ -266,19 +402,131 @@ type JobClaimResponse struct {
 }
 
 type JobPayload struct {
-	SourceID          string          `json:"source_id"`
-	CredentialID      string          `json:"credential_id"`
-	SourceConfig      json.RawMessage `json:"source_config"`
-	RestoreSnapshotID *string         `json:"restore_snapshot_id,omitempty"`
-	DeleteSnapshotID  *string         `json:"delete_snapshot_id,omitempty"`
+	SourceID          string           `json:"source_id"`
+	CredentialID      string           `json:"credential_id"`
+	SourceConfig      json.RawMessage  `json:"source_config"`
+	RestoreSnapshotID *string          `json:"restore_snapshot_id,omitempty"`
+	DeleteSnapshotID  *string          `json:"delete_snapshot_id,omitempty"`
+	DeleteLocator     *SnapshotLocator `json:"delete_locator,omitempty"`
+	BackupMode        string           `json:"backup_mode,omitempty"`
+	BaseSnapshotID    *string          `json:"base_snapshot_id,omitempty"`
+	// reconcile_storage: "inventory", "quarantine" or "purge"
+	ReconcileAction  string                  `json:"reconcile_action,omitempty"`
+	ReconcileTargets []StorageInventoryEntry `json:"reconcile_targets,omitempty"`
+	// verify_snapshot
+	VerifySnapshotID         *string          `json:"verify_snapshot_id,omitempty"`
+	VerifyLocator            *SnapshotLocator `json:"verify_locator,omitempty"`
+	VerifyDecrypt            bool             `json:"verify_decrypt,omitempty"`
+	VerifyManifestSignature  string           `json:"verify_manifest_signature,omitempty"`
+	VerifyManifestSigningKey string           `json:"verify_manifest_signing_key,omitempty"`
+	// replicate_snapshot
+	ReplicateSnapshotID *string `json:"replicate_snapshot_id,omitempty"`
+	ReplicaID           string  `json:"replica_id,omitempty"`
+	ReplicateSourceURL  string  `json:"replicate_source_url,omitempty"`
+	ReplicateToken      string  `json:"replicate_token,omitempty"`
+	// replicate_snapshot to the tenant's offsite target
+	ReplicateOffsiteTargetID string           `json:"replicate_offsite_target_id,omitempty"`
+	ReplicateLocator         *SnapshotLocator `json:"replicate_locator,omitempty"`
+	// migrate_snapshot
+	MigrateSnapshotID *string          `json:"migrate_snapshot_id,omitempty"`
+	MigrateLocator    *SnapshotLocator `json:"migrate_locator,omitempty"`
+	MigrateBackend    string           `json:"migrate_backend,omitempty"`
+	// rekey_snapshot
+	RekeySnapshotID *string `json:"rekey_snapshot_id,omitempty"`
+	RekeyKeyID      string  `json:"rekey_key_id,omitempty"`
 }
 
 type JobCompleteRequest struct {
-	WorkerID string          `json:"worker_id"`
-	Status   string          `json:"status"`
-	Error    string          `json:"error,omitempty"`
-	Snapshot *SnapshotResult `json:"snapshot,omitempty"`
-	Restore  *RestoreResult  `json:"restore,omitempty"`
+	WorkerID     string                `json:"worker_id"`
+	Status       string                `json:"status"`
+	Error        string                `json:"error,omitempty"`
+	ErrorCode    string                `json:"error_code,omitempty"`
+	Snapshot     *SnapshotResult       `json:"snapshot,omitempty"`
+	Restore      *RestoreResult        `json:"restore,omitempty"`
+	Inventory    *StorageInventory     `json:"inventory,omitempty"`
+	Verification *SnapshotVerification `json:"verification,omitempty"`
+	Replica      *ReplicaResult        `json:"replica,omitempty"`
+	Migration    *SnapshotMigration    `json:"migration,omitempty"`
+	Rekey        *SnapshotRekey        `json:"rekey,omitempty"`
+}
+
+// ReplicaResult is where a replicate_snapshot job stored its copy
+type ReplicaResult struct {
+	ReplicaID string          `json:"replica_id"`
+	Locator   SnapshotLocator `json:"locator"`
+}
+
+// SnapshotMigration is where a migrate_snapshot job moved a snapshot
+type SnapshotMigration struct {
+	SnapshotID string          `json:"snapshot_id"`
+	Locator    SnapshotLocator `json:"locator"`
+}
+
+// SnapshotRekey is a snapshot a rekey_snapshot job re-encrypted
+type SnapshotRekey struct {
+	SnapshotID        string           `json:"snapshot_id"`
+	KeyID             string           `json:"key_id"`
+	ManifestJSON      json.RawMessage  `json:"manifest_json"`
+	ManifestSignature string           `json:"manifest_signature,omitempty"`
+	Volumes           []ArtifactVolume `json:"volumes,omitempty"`
+}
+
+// ReplicaTransfer is the snapshot copy a replication token grants read access to
+type ReplicaTransfer struct {
+	ReplicaID  string          `json:"replica_id"`
+	TenantID   string          `json:"tenant_id"`
+	SourceID   string          `json:"source_id"`
+	SnapshotID string          `json:"snapshot_id"`
+	Locator    SnapshotLocator `json:"locator"`
+}
+
+// OffsiteTarget is a tenant's SFTP server with its decrypted secret
+type OffsiteTarget struct {
+	ID         string `json:"id"`
+	TenantID   string `json:"tenant_id"`
+	Host       string `json:"host"`
+	Port       int    `json:"port"`
+	Username   string `json:"username"`
+	Password   string `json:"password,omitempty"`
+	PrivateKey string `json:"private_key,omitempty"`
+	BasePath   string `json:"base_path"`
+	HostKey    string `json:"host_key,omitempty"`
+}
+
+// SnapshotLockStatus tells whether a snapshot may be deleted
+type SnapshotLockStatus struct {
+	SnapshotID  string     `json:"snapshot_id"`
+	LegalHold   bool       `json:"legal_hold"`
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+	Locked      bool       `json:"locked"`
+}
+
+// StorageInventoryEntry is one snapshot directory in local storage
+type StorageInventoryEntry struct {
+	TenantID      string `json:"tenant_id"`
+	SourceID      string `json:"source_id"`
+	SnapshotID    string `json:"snapshot_id"`
+	LocalPath     string `json:"local_path"`
+	StorageMode   string `json:"storage_mode,omitempty"`
+	ArtifactBytes int64  `json:"artifact_bytes"`
+	TotalBytes    int64  `json:"total_bytes"`
+	Error         string `json:"error,omitempty"`
+}
+
+// StorageInventory is the result of a reconcile_storage inventory job
+type StorageInventory struct {
+	Entries []StorageInventoryEntry `json:"entries"`
+}
+
+// SnapshotVerification is the result of a verify_snapshot job
+type SnapshotVerification struct {
+	SnapshotID string `json:"snapshot_id"`
+	Status     string `json:"status"` // "verified" or "corrupt"
+	Error      string `json:"error,omitempty"`
+	Decrypted  bool   `json:"decrypted"`
+	BytesRead  int64  `json:"bytes_read"`
+	FileCount  int    `json:"file_count"`
+	DurationMs int64  `json:"duration_ms"`
 }
 
 type RestoreResult struct {
@@ -301,6 +549,9 @@ type SnapshotResult struct {
 	ManifestJSON        json.RawMessage `json:"manifest_json"`
 	EncryptionAlgorithm string          `json:"encryption_algorithm"`
 	Locator             SnapshotLocator `json:"locator"`
+	BackupMode          string          `json:"backup_mode,omitempty"`
+	BaseSnapshotID      string          `json:"base_snapshot_id,omitempty"`
+	ManifestSignature   string          `json:"manifest_signature,omitempty"`
 }
 
 type SnapshotLocator struct {
@@ -310,6 +561,15 @@ type SnapshotLocator struct {
 	Bucket         string `json:"bucket,omitempty"`
 	ObjectKey      string `json:"object_key,omitempty"`
 	ETag           string `json:"etag,omitempty"`
+
+	Volumes         []ArtifactVolume `json:"volumes,omitempty"`
+	OffsiteTargetID string           `json:"offsite_target_id,omitempty"`
+}
+
+type ArtifactVolume struct {
+	Name      string `json:"name"`
+	SizeBytes int64  `json:"size_bytes"`
+	SHA256    string `json:"sha256"`
 }
 
 type CredentialResponse struct {
@@ -339,12 +599,29 @@ type WorkerRegisterRequest struct {
 	Name            string         `json:"name"`
 	StorageBasePath string         `json:"storage_base_path"`
 	Capabilities    map[string]any `json:"capabilities"`
+	PublicKey       string         `json:"public_key,omitempty"`
+}
+
+// SnapshotIntegrityRequest reports the state of a snapshot's files
+type SnapshotIntegrityRequest struct {
+	WorkerID string `json:"worker_id"`
+	Status   string `json:"status"` // "incomplete"
+	Error    string `json:"error,omitempty"`
 }
 
 type WorkerHeartbeatRequest struct {
-	WorkerID      string         `json:"worker_id"`
-	Status        string         `json:"status"`
-	SystemMetrics *SystemMetrics `json:"system_metrics,omitempty"`
+	WorkerID      string               `json:"worker_id"`
+	Status        string               `json:"status"`
+	SystemMetrics *SystemMetrics       `json:"system_metrics,omitempty"`
+	StatusReasons []WorkerStatusReason `json:"status_reasons,omitempty"`
+}
+
+// WorkerStatusReason is an admission threshold the worker is over
+type WorkerStatusReason struct {
+	Check   string  `json:"check"`
+	Value   float64 `json:"value"`
+	Limit   float64 `json:"limit"`
+	Message string  `json:"message"`
 }
 
 // SystemMetrics contains system resource usage information
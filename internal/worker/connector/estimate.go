@@ -0,0 +1,70 @@
+package connector
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+
+	"github.com/pkg/sftp"
+
+	"xvault/pkg/types"
+)
+
+// EstimateSize walks the source's paths without downloading anything and returns the
+// total size of the regular files the filter keeps. Files an incremental backup takes
+// from its baseline are counted too, since they are still written to the mirror.
+func (c *SFTPConnector) EstimateSize(sftpClient *sftp.Client) (int64, error) {
+	if err := c.prepareFilter(sftpClient); err != nil {
+		return 0, err
+	}
+	roots, err := c.sourceRoots(sftpClient)
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	var skipped types.SkippedSummary
+	for _, root := range roots {
+		err := c.walkPath(sftpClient, root.Path, &skipped, func(_, _ string, info os.FileInfo) error {
+			if info.Mode().IsRegular() {
+				total += info.Size()
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, fmt.Errorf("failed to walk path %s: %w", root.Path, err)
+		}
+	}
+	return total, nil
+}
+
+// prepareFilter builds the source's file filter, reading the source host's mount
+// points when the filter keeps to one filesystem
+func (c *SFTPConnector) prepareFilter(sftpClient *sftp.Client) error {
+	c.filter = newFileFilter(c.config.Filter)
+	if c.config.Filter.OneFileSystem {
+		mounts, err := readRemoteMounts(sftpClient)
+		if err != nil {
+			return err
+		}
+		c.filter.mounts = mounts
+	}
+	return nil
+}
+
+// EstimateSize returns the size of the database's table data as reported by
+// information_schema. The dump is text, so it can come out larger or smaller than
+// the estimate, but it is of the same order.
+func (c *MySQLConnector) EstimateSize(db *sql.DB) (int64, error) {
+	query := `
+		SELECT COALESCE(SUM(DATA_LENGTH), 0)
+		FROM INFORMATION_SCHEMA.TABLES
+		WHERE TABLE_SCHEMA = ? AND TABLE_TYPE = 'BASE TABLE'
+	`
+
+	var size int64
+	if err := db.QueryRow(query, c.config.Database).Scan(&size); err != nil {
+		return 0, fmt.Errorf("failed to query database size: %w", err)
+	}
+	return size, nil
+}
@@ -0,0 +1,8 @@
+package connector
+
+import "syscall"
+
+// mkfifo creates a named pipe at path; applyAttrs sets its final mode
+func mkfifo(path string) error {
+	return syscall.Mkfifo(path, 0600)
+}
@@ -0,0 +1,197 @@
+package connector
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/sftp"
+	"xvault/pkg/types"
+)
+
+// filterPattern is a compiled gitignore-style pattern (see types.FileFilter)
+type filterPattern struct {
+	segments []string
+	negate   bool
+	dirOnly  bool
+}
+
+func compileFilterPattern(pattern string) filterPattern {
+	var p filterPattern
+	if strings.HasPrefix(pattern, "!") {
+		p.negate = true
+		pattern = pattern[1:]
+	}
+	if strings.HasSuffix(pattern, "/") {
+		p.dirOnly = true
+	}
+	// A pattern without a slash other than a trailing one matches at any depth
+	anchored := strings.Contains(strings.TrimSuffix(pattern, "/"), "/")
+	pattern = strings.Trim(pattern, "/")
+	if !anchored {
+		pattern = "**/" + pattern
+	}
+	p.segments = strings.Split(pattern, "/")
+	return p
+}
+
+// match reports whether rel, a slash path relative to the configured path, matches
+func (p filterPattern) match(rel string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+	return matchSegments(p.segments, strings.Split(rel, "/"))
+}
+
+// matchSegments matches path segments against pattern segments, where "**" stands
+// for any number of segments
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// fileFilter decides which remote entries PullFiles transfers
+type fileFilter struct {
+	include     []filterPattern
+	exclude     []filterPattern
+	maxFileSize int64
+	skipSpecial bool
+	// mounts holds the mount points of the source host when OneFileSystem is set
+	mounts map[string]bool
+}
+
+func newFileFilter(config types.FileFilter) *fileFilter {
+	f := &fileFilter{
+		maxFileSize: config.MaxFileSize,
+		skipSpecial: config.SkipSpecialFiles,
+	}
+	for _, pattern := range config.Include {
+		f.include = append(f.include, compileFilterPattern(pattern))
+	}
+	for _, pattern := range config.Exclude {
+		f.exclude = append(f.exclude, compileFilterPattern(pattern))
+	}
+	return f
+}
+
+// excluded reports whether the exclude patterns drop rel; the last match wins
+func (f *fileFilter) excluded(rel string, isDir bool) bool {
+	excluded := false
+	for _, p := range f.exclude {
+		if p.match(rel, isDir) {
+			excluded = !p.negate
+		}
+	}
+	return excluded
+}
+
+// included reports whether a non-directory entry at rel, or one of its parent
+// directories, matches an include pattern. Without include patterns everything is.
+func (f *fileFilter) included(rel string) bool {
+	if len(f.include) == 0 {
+		return true
+	}
+	for _, p := range f.include {
+		if p.match(rel, false) {
+			return true
+		}
+		for dir := path.Dir(rel); dir != "."; dir = path.Dir(dir) {
+			if p.match(dir, true) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// skip reports whether the entry at rel is left out of the backup and counts it.
+// remotePath is the entry's absolute path on the source host.
+func (f *fileFilter) skip(rel, remotePath string, info os.FileInfo, skipped *types.SkippedSummary) bool {
+	if f.excluded(rel, info.IsDir()) {
+		skipped.Excluded++
+		return true
+	}
+
+	mode := info.Mode()
+	switch {
+	case info.IsDir():
+		if f.mounts != nil && f.mounts[remotePath] {
+			skipped.OtherFilesystem++
+			return true
+		}
+		return false
+	case !f.included(rel):
+		skipped.Excluded++
+		return true
+	case mode.IsRegular() && f.maxFileSize > 0 && info.Size() > f.maxFileSize:
+		skipped.TooLarge++
+		skipped.TooLargeBytes += info.Size()
+		return true
+	case mode&(os.ModeNamedPipe|os.ModeSocket|os.ModeDevice|os.ModeCharDevice) != 0:
+		// Only FIFOs can be reproduced; the rest have no content SFTP can read
+		if f.skipSpecial || mode&os.ModeNamedPipe == 0 {
+			skipped.Special++
+			return true
+		}
+	}
+	return false
+}
+
+// readRemoteMounts returns the mount points of the source host from /proc/mounts
+func readRemoteMounts(sftpClient *sftp.Client) (map[string]bool, error) {
+	f, err := sftpClient.Open("/proc/mounts")
+	if err != nil {
+		return nil, fmt.Errorf("one_file_system needs a readable /proc/mounts on the source: %w", err)
+	}
+	defer f.Close()
+
+	mounts := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// device mountpoint fstype options dump pass; spaces in paths are octal escapes
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		mounts[unescapeMountPath(fields[1])] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read /proc/mounts: %w", err)
+	}
+	return mounts, nil
+}
+
+// unescapeMountPath decodes the \ooo escapes the kernel uses in /proc/mounts
+func unescapeMountPath(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,209 @@
+package connector
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"xvault/pkg/types"
+)
+
+func TestFilterPatternMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		rel     string
+		isDir   bool
+		want    bool
+	}{
+		// Unanchored patterns match at any depth
+		{"*.log", "error.log", false, true},
+		{"*.log", "var/log/error.log", false, true},
+		{"*.log", "error.log.1", false, false},
+		{"node_modules", "app/node_modules", true, true},
+		// A slash other than a trailing one anchors the pattern to the configured path
+		{"/error.log", "error.log", false, true},
+		{"/error.log", "var/error.log", false, false},
+		{"wp-content/cache", "wp-content/cache", true, true},
+		{"wp-content/cache", "site/wp-content/cache", true, false},
+		// ** at the start, in the middle and at the end
+		{"**/cache", "cache", true, true},
+		{"**/cache", "a/b/cache", true, true},
+		{"wp-content/**/*.php", "wp-content/index.php", false, true},
+		{"wp-content/**/*.php", "wp-content/plugins/seo/seo.php", false, true},
+		{"wp-content/**/*.php", "wp-includes/plugins/seo.php", false, false},
+		{"logs/**", "logs/2024/app.log", false, true},
+		{"logs/**", "other/logs/app.log", false, false},
+		// A trailing slash matches directories only
+		{"cache/", "cache", true, true},
+		{"cache/", "app/cache", true, true},
+		{"cache/", "cache", false, false},
+		{"/tmp/", "tmp", true, true},
+		{"/tmp/", "app/tmp", true, false},
+		// Negation does not change what a pattern matches
+		{"!debug.log", "var/debug.log", false, true},
+	}
+
+	for _, tt := range tests {
+		if got := compileFilterPattern(tt.pattern).match(tt.rel, tt.isDir); got != tt.want {
+			t.Errorf("%q.match(%q, dir=%v) = %v, want %v", tt.pattern, tt.rel, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestMatchSegments(t *testing.T) {
+	tests := []struct {
+		pattern []string
+		name    []string
+		want    bool
+	}{
+		{[]string{"a", "b"}, []string{"a", "b"}, true},
+		{[]string{"a", "b"}, []string{"a", "b", "c"}, false},
+		{[]string{"a", "b"}, []string{"a"}, false},
+		{[]string{"**"}, []string{}, true},
+		{[]string{"**"}, []string{"a", "b"}, true},
+		{[]string{"**", "b"}, []string{"b"}, true},
+		{[]string{"a", "**", "b"}, []string{"a", "b"}, true},
+		{[]string{"a", "**", "b"}, []string{"a", "x", "y", "b"}, true},
+		{[]string{"a", "**", "b"}, []string{"a", "x", "b", "c"}, false},
+		{[]string{"**", "*.go", "**"}, []string{"x", "main.go", "y"}, true},
+		{[]string{"[a-"}, []string{"a"}, false},
+	}
+
+	for _, tt := range tests {
+		if got := matchSegments(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("matchSegments(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+// testFileInfo describes a remote entry for fileFilter.skip
+type testFileInfo struct {
+	name string
+	size int64
+	mode os.FileMode
+}
+
+func (fi testFileInfo) Name() string       { return fi.name }
+func (fi testFileInfo) Size() int64        { return fi.size }
+func (fi testFileInfo) Mode() os.FileMode  { return fi.mode }
+func (fi testFileInfo) ModTime() time.Time { return time.Time{} }
+func (fi testFileInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi testFileInfo) Sys() any           { return nil }
+
+func TestFileFilterSkip(t *testing.T) {
+	type entry struct {
+		rel  string
+		mode os.FileMode
+		size int64
+		skip bool
+	}
+	tests := []struct {
+		name    string
+		filter  types.FileFilter
+		mounts  map[string]bool
+		entries []entry
+		want    types.SkippedSummary
+	}{
+		{
+			name:   "later negation keeps a file",
+			filter: types.FileFilter{Exclude: []string{"*.log", "!debug.log"}},
+			entries: []entry{
+				{rel: "error.log", skip: true},
+				{rel: "app/debug.log"},
+				{rel: "index.php"},
+			},
+			want: types.SkippedSummary{Excluded: 1},
+		},
+		{
+			name:   "earlier negation is overridden",
+			filter: types.FileFilter{Exclude: []string{"!debug.log", "*.log"}},
+			entries: []entry{
+				{rel: "error.log", skip: true},
+				{rel: "app/debug.log", skip: true},
+			},
+			want: types.SkippedSummary{Excluded: 2},
+		},
+		{
+			name:   "negation re-includes a directory",
+			filter: types.FileFilter{Exclude: []string{"cache/", "!/wp-content/cache/"}},
+			entries: []entry{
+				{rel: "cache", mode: os.ModeDir, skip: true},
+				{rel: "app/cache", mode: os.ModeDir, skip: true},
+				{rel: "wp-content/cache", mode: os.ModeDir},
+				{rel: "cache.php"},
+			},
+			want: types.SkippedSummary{Excluded: 2},
+		},
+		{
+			name:   "includes keep their parent directories",
+			filter: types.FileFilter{Include: []string{"/wp-content/**/*.php", "uploads/"}},
+			entries: []entry{
+				{rel: "wp-content", mode: os.ModeDir},
+				{rel: "wp-content/plugins", mode: os.ModeDir},
+				{rel: "wp-content/plugins/seo.php"},
+				{rel: "wp-content/plugins/readme.txt", skip: true},
+				{rel: "wp-content/uploads", mode: os.ModeDir},
+				{rel: "wp-content/uploads/2024/logo.png"},
+				{rel: "index.php", skip: true},
+			},
+			want: types.SkippedSummary{Excluded: 2},
+		},
+		{
+			name:   "exclude wins over include",
+			filter: types.FileFilter{Include: []string{"*.php"}, Exclude: []string{"vendor/"}},
+			entries: []entry{
+				{rel: "vendor", mode: os.ModeDir, skip: true},
+				{rel: "index.php"},
+			},
+			want: types.SkippedSummary{Excluded: 1},
+		},
+		{
+			name:   "size and special files",
+			filter: types.FileFilter{MaxFileSize: 100, SkipSpecialFiles: true},
+			entries: []entry{
+				{rel: "small.bin", size: 100},
+				{rel: "large.bin", size: 101, skip: true},
+				{rel: "run/app.sock", mode: os.ModeSocket, skip: true},
+				{rel: "run/app.fifo", mode: os.ModeNamedPipe, skip: true},
+			},
+			want: types.SkippedSummary{TooLarge: 1, TooLargeBytes: 101, Special: 2},
+		},
+		{
+			name:   "fifos are kept unless special files are skipped",
+			filter: types.FileFilter{},
+			entries: []entry{
+				{rel: "run/app.fifo", mode: os.ModeNamedPipe},
+				{rel: "dev/null", mode: os.ModeDevice | os.ModeCharDevice, skip: true},
+			},
+			want: types.SkippedSummary{Special: 1},
+		},
+		{
+			name:   "other filesystems",
+			filter: types.FileFilter{},
+			mounts: map[string]bool{"/srv/site/proc": true},
+			entries: []entry{
+				{rel: "proc", mode: os.ModeDir, skip: true},
+				{rel: "public", mode: os.ModeDir},
+			},
+			want: types.SkippedSummary{OtherFilesystem: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFileFilter(tt.filter)
+			f.mounts = tt.mounts
+			var skipped types.SkippedSummary
+			for _, e := range tt.entries {
+				info := testFileInfo{name: path.Base(e.rel), size: e.size, mode: e.mode}
+				if got := f.skip(e.rel, path.Join("/srv/site", e.rel), info, &skipped); got != e.skip {
+					t.Errorf("skip(%q) = %v, want %v", e.rel, got, e.skip)
+				}
+			}
+			if skipped != tt.want {
+				t.Errorf("skipped = %+v, want %+v", skipped, tt.want)
+			}
+		})
+	}
+}
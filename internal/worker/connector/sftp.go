@@ -1,13 +1,19 @@
 package connector
 
 import (
+	"archive/tar"
+	"bufio"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/pkg/sftp"
 	"golang.org/x/crypto/ssh"
+	"xvault/pkg/types"
 )
 
 // SSHConfig represents SSH connection configuration
@@ -18,11 +24,17 @@ type SSHConfig struct {
 	Password   string // Optional, use Key instead
 	Key        string // Private key content
 	Paths      []string
+	// PathAliases maps a configured path to the archive directory it is stored under
+	PathAliases map[string]string
+	Filter      types.FileFilter
 }
 
 // SFTPConnector handles SSH/SFTP connections for file downloads
 type SFTPConnector struct {
-	config *SSHConfig
+	config    *SSHConfig
+	baseline  *Baseline
+	filter    *fileFilter
+	sshClient *ssh.Client
 }
 
 // NewSFTPConnector creates a new SFTP connector
@@ -32,6 +44,25 @@ func NewSFTPConnector(config *SSHConfig) *SFTPConnector {
 	}
 }
 
+// Baseline is the previous snapshot of a source, used to pull incrementally.
+// Regular files whose size and mtime (and optionally remote SHA-256) match the
+// baseline entry are not transferred; their content is read from the base
+// snapshot instead, so the mirror still ends up as the complete tree.
+type Baseline struct {
+	SnapshotID string
+	// Entries are keyed by mirror-relative slash path
+	Entries map[string]types.FileIndexEntry
+	// VerifyHash additionally compares a remote sha256sum of unchanged-looking files
+	VerifyHash bool
+	// Open returns the plaintext tar stream of the base snapshot
+	Open func() (io.ReadCloser, error)
+}
+
+// SetBaseline makes the next PullFiles incremental against baseline
+func (c *SFTPConnector) SetBaseline(baseline *Baseline) {
+	c.baseline = baseline
+}
+
 // Connect establishes an SSH connection and returns an SFTP client
 func (c *SFTPConnector) Connect() (*sftp.Client, *ssh.Client, error) {
 	// Create SSH client config
@@ -67,96 +98,447 @@ func (c *SFTPConnector) Connect() (*sftp.Client, *ssh.Client, error) {
 		sshClient.Close()
 		return nil, nil, fmt.Errorf("failed to create SFTP client: %w", err)
 	}
+	c.sshClient = sshClient
 
 	return sftpClient, sshClient, nil
 }
 
-// PullFiles downloads files from remote paths to a local temporary directory
+// PullFiles downloads files from remote paths to a local temporary directory. Each
+// path is mirrored under its absolute remote path (or its alias), which is recorded
+// in PullStats.Roots, so paths with the same base name cannot collide.
+// Directories, symlinks, modes and mtimes are reproduced in the mirror; ownership
+// is returned in PullStats.Owners keyed by mirror-relative slash path. With a
+// baseline set, unchanged files are taken from the base snapshot and paths that
+// disappeared since are reported in PullStats.Deleted. Entries the source's filter
+// leaves out are counted in PullStats.Skipped.
 func (c *SFTPConnector) PullFiles(sftpClient *sftp.Client, destDir string) (*PullStats, error) {
 	stats := &PullStats{
 		FilesDownloaded: 0,
 		TotalBytes:      0,
+		Owners:          make(map[string]types.FileOwner),
+		seen:            make(map[string]bool),
+		unchanged:       make(map[string]unchangedFile),
 	}
 
-	for _, path := range c.config.Paths {
-		fileStats, err := c.pullPath(sftpClient, path, destDir)
-		if err != nil {
-			return stats, fmt.Errorf("failed to pull path %s: %w", path, err)
+	// Resolve remote user/group names (best effort; SFTP only reports numeric IDs)
+	users := readRemoteIDNames(sftpClient, "/etc/passwd")
+	groups := readRemoteIDNames(sftpClient, "/etc/group")
+
+	if err := c.prepareFilter(sftpClient); err != nil {
+		return stats, err
+	}
+
+	roots, err := c.sourceRoots(sftpClient)
+	if err != nil {
+		return stats, err
+	}
+	stats.Roots = roots
+
+	for _, root := range roots {
+		if root.ArchivePath == types.ArchivePathFor(root.Path, "") {
+			if err := c.pullParents(sftpClient, root, destDir, stats, users, groups); err != nil {
+				return stats, err
+			}
+		}
+		if err := c.pullPath(sftpClient, root.Path, root.ArchivePath, destDir, stats, users, groups); err != nil {
+			return stats, fmt.Errorf("failed to pull path %s: %w", root.Path, err)
+		}
+	}
+
+	if c.baseline != nil {
+		if err := c.fillUnchanged(sftpClient, destDir, stats); err != nil {
+			return stats, err
+		}
+		for rel := range c.baseline.Entries {
+			if !stats.seen[rel] {
+				stats.Deleted = append(stats.Deleted, rel)
+			}
+		}
+		sort.Strings(stats.Deleted)
+	}
+
+	// Directory attributes are applied last, deepest first, because creating
+	// children would otherwise bump the parent mtime again
+	for i := len(stats.dirs) - 1; i >= 0; i-- {
+		if err := applyAttrs(stats.dirs[i].path, stats.dirs[i].info); err != nil {
+			return stats, err
 		}
-		stats.FilesDownloaded += fileStats.FilesDownloaded
-		stats.TotalBytes += fileStats.TotalBytes
 	}
 
 	return stats, nil
 }
 
-// pullPath recursively downloads a file or directory
-func (c *SFTPConnector) pullPath(sftpClient *sftp.Client, remotePath, destDir string) (*PullStats, error) {
-	stats := &PullStats{
-		FilesDownloaded: 0,
-		TotalBytes:      0,
+// sourceRoots resolves the configured paths to absolute remote paths and decides
+// where each is stored in the archive. Relative paths are relative to the login
+// directory.
+func (c *SFTPConnector) sourceRoots(sftpClient *sftp.Client) ([]types.SourceRoot, error) {
+	roots := make([]types.SourceRoot, 0, len(c.config.Paths))
+	for _, configured := range c.config.Paths {
+		remotePath := filepath.Clean(configured)
+		if !filepath.IsAbs(remotePath) {
+			resolved, err := sftpClient.RealPath(configured)
+			if err != nil {
+				return nil, fmt.Errorf("failed to resolve remote path %s: %w", configured, err)
+			}
+			remotePath = resolved
+		}
+		roots = append(roots, types.SourceRoot{
+			Path:        remotePath,
+			ArchivePath: types.ArchivePathFor(remotePath, c.config.PathAliases[configured]),
+		})
 	}
+	if err := types.ValidateSourceRoots(roots); err != nil {
+		return nil, err
+	}
+	return roots, nil
+}
+
+// pullParents mirrors the directories above a root that is stored under its absolute
+// path, so they carry their remote mode, mtime and ownership rather than defaults
+func (c *SFTPConnector) pullParents(sftpClient *sftp.Client, root types.SourceRoot, destDir string, stats *PullStats, users, groups map[int]string) error {
+	var parents []string
+	for dir := filepath.Dir(root.Path); dir != "/" && dir != "."; dir = filepath.Dir(dir) {
+		parents = append(parents, dir)
+	}
+
+	// Outermost first, so stats.dirs keeps parents ahead of their children
+	for i := len(parents) - 1; i >= 0; i-- {
+		rel := types.ArchivePathFor(parents[i], "")
+		if stats.seen[rel] {
+			continue
+		}
+		info, err := sftpClient.Stat(parents[i])
+		if err != nil {
+			return fmt.Errorf("failed to stat remote path %s: %w", parents[i], err)
+		}
+		localDirPath := filepath.Join(destDir, rel)
+		if err := os.MkdirAll(localDirPath, 0755); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+		stats.dirs = append(stats.dirs, pulledDir{path: localDirPath, info: info})
+		stats.seen[rel] = true
+		recordOwner(stats, rel, info, users, groups)
+	}
+	return nil
+}
 
+// pullPath recursively downloads a file or directory into the mirror at archivePath
+func (c *SFTPConnector) pullPath(sftpClient *sftp.Client, remotePath, archivePath, destDir string, stats *PullStats, users, groups map[int]string) error {
+	return c.walkPath(sftpClient, remotePath, &stats.Skipped, func(entryPath, relPath string, info os.FileInfo) error {
+		rel := filepath.Join(archivePath, relPath)
+		if rel == "." {
+			// Backing up "/": the root is the mirror directory itself
+			return nil
+		}
+
+		if info.IsDir() {
+			localDirPath := filepath.Join(destDir, rel)
+			if err := os.MkdirAll(localDirPath, 0755); err != nil {
+				return fmt.Errorf("failed to create directory: %w", err)
+			}
+			stats.dirs = append(stats.dirs, pulledDir{path: localDirPath, info: info})
+			stats.seen[filepath.ToSlash(rel)] = true
+			recordOwner(stats, rel, info, users, groups)
+			return nil
+		}
+
+		return c.pullEntry(sftpClient, entryPath, info, destDir, rel, stats, users, groups)
+	})
+}
+
+// walkPath calls fn for remotePath and, if it is a directory, for every entry below it
+// that the source's filter keeps, passing the path relative to remotePath ("." for
+// remotePath itself). Entries left out are counted in skipped and directories left out
+// are not descended into.
+func (c *SFTPConnector) walkPath(sftpClient *sftp.Client, remotePath string, skipped *types.SkippedSummary, fn func(entryPath, relPath string, info os.FileInfo) error) error {
 	// Check if remote path exists
 	info, err := sftpClient.Stat(remotePath)
 	if err != nil {
-		return stats, fmt.Errorf("failed to stat remote path: %w", err)
+		return fmt.Errorf("failed to stat remote path: %w", err)
 	}
 
-	// Get the base name for the local path
-	baseName := filepath.Base(remotePath)
-	localPath := filepath.Join(destDir, baseName)
+	if !info.IsDir() {
+		if c.filter.skip(filepath.Base(remotePath), remotePath, info, skipped) {
+			return nil
+		}
+		return fn(remotePath, ".", info)
+	}
 
-	if info.IsDir() {
-		// Recursively pull directory
-		walker := sftpClient.Walk(remotePath)
-		for walker.Step() {
-			if err := walker.Err(); err != nil {
-				return stats, fmt.Errorf("walk error: %w", err)
-			}
+	// Mount points are absolute, so compare against the resolved path
+	root := remotePath
+	if c.filter.mounts != nil {
+		if root, err = sftpClient.RealPath(remotePath); err != nil {
+			return fmt.Errorf("failed to resolve remote path: %w", err)
+		}
+	}
 
-			// Calculate relative path from remotePath
-			relPath, err := filepath.Rel(remotePath, walker.Path())
-			if err != nil {
-				return stats, fmt.Errorf("failed to get relative path: %w", err)
-			}
-			localFilePath := filepath.Join(destDir, baseName, relPath)
+	// Walk uses Lstat, so symlinks are not followed
+	walker := sftpClient.Walk(remotePath)
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			return fmt.Errorf("walk error: %w", err)
+		}
 
+		// Calculate relative path from remotePath
+		relPath, err := filepath.Rel(remotePath, walker.Path())
+		if err != nil {
+			return fmt.Errorf("failed to get relative path: %w", err)
+		}
+
+		if relPath != "." && c.filter.skip(filepath.ToSlash(relPath), filepath.Join(root, relPath), walker.Stat(), skipped) {
 			if walker.Stat().IsDir() {
-				// Create local directory
-				if err := os.MkdirAll(localFilePath, 0755); err != nil {
-					return stats, fmt.Errorf("failed to create directory: %w", err)
-				}
-			} else {
-				// Create parent directory if needed
-				if err := os.MkdirAll(filepath.Dir(localFilePath), 0755); err != nil {
-					return stats, fmt.Errorf("failed to create parent directory: %w", err)
-				}
-
-				// Download file
-				size, err := c.downloadFile(sftpClient, walker.Path(), localFilePath)
-				if err != nil {
-					return stats, fmt.Errorf("failed to download file %s: %w", walker.Path(), err)
-				}
-				stats.FilesDownloaded++
-				stats.TotalBytes += size
+				walker.SkipDir()
 			}
+			continue
 		}
-	} else {
-		// Pull single file
-		if err := os.MkdirAll(destDir, 0755); err != nil {
-			return stats, fmt.Errorf("failed to create destination directory: %w", err)
+
+		if err := fn(walker.Path(), relPath, walker.Stat()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// pullEntry reproduces a single non-directory remote entry at destDir/rel
+func (c *SFTPConnector) pullEntry(sftpClient *sftp.Client, remotePath string, info os.FileInfo, destDir, rel string, stats *PullStats, users, groups map[int]string) error {
+	localPath := filepath.Join(destDir, rel)
+	stats.seen[filepath.ToSlash(rel)] = true
+
+	// Create parent directory if needed
+	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		return fmt.Errorf("failed to create parent directory: %w", err)
+	}
+
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := sftpClient.ReadLink(remotePath)
+		if err != nil {
+			return fmt.Errorf("failed to read symlink %s: %w", remotePath, err)
+		}
+		if err := os.Symlink(target, localPath); err != nil {
+			return fmt.Errorf("failed to create symlink %s: %w", localPath, err)
 		}
+		recordOwner(stats, rel, info, users, groups)
 
+	case info.Mode().IsRegular():
+		if c.isUnchanged(remotePath, filepath.ToSlash(rel), info) {
+			stats.unchanged[filepath.ToSlash(rel)] = unchangedFile{remotePath: remotePath, info: info}
+			recordOwner(stats, rel, info, users, groups)
+			return nil
+		}
 		size, err := c.downloadFile(sftpClient, remotePath, localPath)
 		if err != nil {
-			return stats, fmt.Errorf("failed to download file: %w", err)
+			return fmt.Errorf("failed to download file %s: %w", remotePath, err)
 		}
+		if err := applyAttrs(localPath, info); err != nil {
+			return err
+		}
+		recordOwner(stats, rel, info, users, groups)
 		stats.FilesDownloaded++
 		stats.TotalBytes += size
+
+	case info.Mode()&os.ModeNamedPipe != 0:
+		if err := mkfifo(localPath); err != nil {
+			return fmt.Errorf("failed to create FIFO %s: %w", localPath, err)
+		}
+		if err := applyAttrs(localPath, info); err != nil {
+			return err
+		}
+		recordOwner(stats, rel, info, users, groups)
+
+	default:
+		// Devices and sockets cannot be read over SFTP (the filter counts them as skipped)
 	}
 
-	return stats, nil
+	return nil
+}
+
+// pulledDir is a local directory whose attributes are applied after the walk
+type pulledDir struct {
+	path string
+	info os.FileInfo
+}
+
+// unchangedFile is a remote regular file that matched the baseline
+type unchangedFile struct {
+	remotePath string
+	info       os.FileInfo
+}
+
+// isUnchanged reports whether a remote regular file matches its baseline entry
+func (c *SFTPConnector) isUnchanged(remotePath, rel string, info os.FileInfo) bool {
+	if c.baseline == nil {
+		return false
+	}
+	entry, ok := c.baseline.Entries[rel]
+	if !ok || entry.Type != "file" {
+		return false
+	}
+	if entry.Size != info.Size() || entry.ModTime.Unix() != info.ModTime().Unix() {
+		return false
+	}
+	if c.baseline.VerifyHash {
+		sum, err := c.remoteSHA256(remotePath)
+		if err != nil || entry.SHA256 == "" || sum != entry.SHA256 {
+			return false
+		}
+	}
+	return true
+}
+
+// remoteSHA256 hashes a file on the source host with sha256sum over an SSH session
+func (c *SFTPConnector) remoteSHA256(remotePath string) (string, error) {
+	if c.sshClient == nil {
+		return "", fmt.Errorf("no SSH session available")
+	}
+	session, err := c.sshClient.NewSession()
+	if err != nil {
+		return "", fmt.Errorf("failed to open SSH session: %w", err)
+	}
+	defer session.Close()
+
+	quoted := "'" + strings.ReplaceAll(remotePath, "'", `'\''`) + "'"
+	out, err := session.Output("sha256sum -- " + quoted)
+	if err != nil {
+		return "", fmt.Errorf("failed to run sha256sum: %w", err)
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) == 0 || len(fields[0]) != 64 {
+		return "", fmt.Errorf("unexpected sha256sum output")
+	}
+	return fields[0], nil
+}
+
+// fillUnchanged writes the content of unchanged files into the mirror from the base
+// snapshot, then applies the current remote mode and mtime. Files the base snapshot
+// unexpectedly lacks are downloaded after all.
+func (c *SFTPConnector) fillUnchanged(sftpClient *sftp.Client, destDir string, stats *PullStats) error {
+	if len(stats.unchanged) == 0 {
+		return nil
+	}
+
+	pending := make(map[string]unchangedFile, len(stats.unchanged))
+	for rel, f := range stats.unchanged {
+		pending[rel] = f
+	}
+
+	stream, err := c.baseline.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open base snapshot %s: %w", c.baseline.SnapshotID, err)
+	}
+	defer stream.Close()
+
+	tr := tar.NewReader(stream)
+	for len(pending) > 0 {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read base snapshot %s: %w", c.baseline.SnapshotID, err)
+		}
+		f, ok := pending[header.Name]
+		if !ok || header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		localPath := filepath.Join(destDir, filepath.FromSlash(header.Name))
+		if err := writeMirrorFile(localPath, tr, header.Size); err != nil {
+			return fmt.Errorf("failed to restore unchanged file %s: %w", header.Name, err)
+		}
+		if err := applyAttrs(localPath, f.info); err != nil {
+			return err
+		}
+		stats.FilesUnchanged++
+		stats.BytesUnchanged += header.Size
+		delete(pending, header.Name)
+	}
+
+	for rel, f := range pending {
+		localPath := filepath.Join(destDir, filepath.FromSlash(rel))
+		size, err := c.downloadFile(sftpClient, f.remotePath, localPath)
+		if err != nil {
+			return fmt.Errorf("failed to download file %s: %w", f.remotePath, err)
+		}
+		if err := applyAttrs(localPath, f.info); err != nil {
+			return err
+		}
+		stats.FilesDownloaded++
+		stats.TotalBytes += size
+	}
+
+	return nil
+}
+
+// writeMirrorFile writes size bytes from r to a new file at localPath
+func writeMirrorFile(localPath string, r io.Reader, size int64) error {
+	f, err := os.Create(localPath)
+	if err != nil {
+		return err
+	}
+	if _, err := io.CopyN(f, r, size); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// applyAttrs copies the remote permission bits and mtime onto a local path
+func applyAttrs(localPath string, info os.FileInfo) error {
+	mode := info.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+	if err := os.Chmod(localPath, mode); err != nil {
+		return fmt.Errorf("failed to set mode on %s: %w", localPath, err)
+	}
+	if err := os.Chtimes(localPath, info.ModTime(), info.ModTime()); err != nil {
+		return fmt.Errorf("failed to set mtime on %s: %w", localPath, err)
+	}
+	return nil
+}
+
+// recordOwner stores the remote ownership of rel in stats
+func recordOwner(stats *PullStats, rel string, info os.FileInfo, users, groups map[int]string) {
+	st, ok := info.Sys().(*sftp.FileStat)
+	if !ok {
+		return
+	}
+	owner := types.FileOwner{
+		UID:   int(st.UID),
+		GID:   int(st.GID),
+		Uname: users[int(st.UID)],
+		Gname: groups[int(st.GID)],
+	}
+	stats.Owners[filepath.ToSlash(rel)] = owner
+}
+
+// readRemoteIDNames parses a remote passwd/group style file into an id -> name map.
+// Errors are ignored: names are a convenience, numeric IDs are always recorded.
+func readRemoteIDNames(sftpClient *sftp.Client, path string) map[int]string {
+	names := make(map[int]string)
+
+	f, err := sftpClient.Open(path)
+	if err != nil {
+		return names
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// name:password:id:...
+		fields := strings.Split(scanner.Text(), ":")
+		if len(fields) < 3 {
+			continue
+		}
+		id, err := strconv.Atoi(fields[2])
+		if err != nil {
+			continue
+		}
+		if _, exists := names[id]; !exists {
+			names[id] = fields[0]
+		}
+	}
+
+	return names
 }
 
 // downloadFile downloads a single file from SFTP
@@ -188,4 +570,20 @@ func (c *SFTPConnector) downloadFile(sftpClient *sftp.Client, remotePath, localP
 type PullStats struct {
 	FilesDownloaded int
 	TotalBytes      int64
+	Owners          map[string]types.FileOwner
+
+	// Incremental pulls only
+	FilesUnchanged int
+	BytesUnchanged int64
+	Deleted        []string // baseline paths no longer present at the source
+
+	// Where each configured path is stored in the mirror
+	Roots []types.SourceRoot
+
+	// Entries left out by the source's filter
+	Skipped types.SkippedSummary
+
+	seen      map[string]bool
+	unchanged map[string]unchangedFile
+	dirs      []pulledDir
 }
@@ -0,0 +1,158 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"xvault/internal/worker/client"
+	"xvault/pkg/types"
+)
+
+// AdmissionThresholds are the resource limits past which the worker stops claiming
+// jobs. A zero field disables its check.
+type AdmissionThresholds struct {
+	MinDiskFreeBytes int64   // free space on the storage disk
+	MaxMemoryPercent float64 // memory in use
+	MaxCPUPercent    float64 // CPU in use
+}
+
+// enabled reports whether any check is configured
+func (t AdmissionThresholds) enabled() bool {
+	return t.MinDiskFreeBytes > 0 || t.MaxMemoryPercent > 0 || t.MaxCPUPercent > 0
+}
+
+// admissionRecoveryMargin is the fraction of a limit a metric must get back within
+// before a worker over it claims jobs again, so a metric hovering at the limit does not
+// flip the worker between online and degraded on every poll
+const admissionRecoveryMargin = 0.9
+
+// cpuSamplesOverLimit is how many samples in a row CPU use must be over its limit
+// before the worker stops claiming jobs. Each sample only spans 100ms, so a single one
+// says little more than that something ran at that moment.
+const cpuSamplesOverLimit = 3
+
+// check returns the thresholds metrics are over, given the ones the worker was over
+// before and how many CPU samples in a row were over its limit, which it returns
+// updated. A threshold the worker was over is only cleared once the metric is back
+// within admissionRecoveryMargin of it. Metrics the collector could not read are not
+// checked.
+func (t AdmissionThresholds) check(m *client.SystemMetrics, previous []client.WorkerStatusReason, cpuSamples int) ([]client.WorkerStatusReason, int) {
+	var reasons []client.WorkerStatusReason
+	if t.MinDiskFreeBytes > 0 && m.DiskTotalBytes > 0 {
+		limit := float64(t.MinDiskFreeBytes)
+		if hasAdmissionCheck(previous, types.AdmissionCheckDiskFree) {
+			limit += limit * (1 - admissionRecoveryMargin)
+		}
+		if float64(m.DiskFreeBytes) < limit {
+			reasons = append(reasons, client.WorkerStatusReason{
+				Check:   types.AdmissionCheckDiskFree,
+				Value:   float64(m.DiskFreeBytes),
+				Limit:   float64(t.MinDiskFreeBytes),
+				Message: fmt.Sprintf("storage disk has %d MB free, %s the minimum of %d MB", m.DiskFreeBytes>>20, admissionComparison(m.DiskFreeBytes < t.MinDiskFreeBytes, "below"), t.MinDiskFreeBytes>>20),
+			})
+		}
+	}
+	if t.MaxMemoryPercent > 0 && m.MemoryTotalBytes > 0 {
+		limit := t.MaxMemoryPercent
+		if hasAdmissionCheck(previous, types.AdmissionCheckMemory) {
+			limit *= admissionRecoveryMargin
+		}
+		if m.MemoryPercent > limit {
+			reasons = append(reasons, client.WorkerStatusReason{
+				Check:   types.AdmissionCheckMemory,
+				Value:   m.MemoryPercent,
+				Limit:   t.MaxMemoryPercent,
+				Message: fmt.Sprintf("memory use is %.1f%%, %s the maximum of %.1f%%", m.MemoryPercent, admissionComparison(m.MemoryPercent > t.MaxMemoryPercent, "above"), t.MaxMemoryPercent),
+			})
+		}
+	}
+	if t.MaxCPUPercent > 0 {
+		wasOver := hasAdmissionCheck(previous, types.AdmissionCheckCPU)
+		limit := t.MaxCPUPercent
+		if wasOver {
+			limit *= admissionRecoveryMargin
+		}
+		if m.CPUPercent > limit {
+			cpuSamples++
+		} else {
+			cpuSamples = 0
+		}
+		if cpuSamples > 0 && (wasOver || cpuSamples >= cpuSamplesOverLimit) {
+			reasons = append(reasons, client.WorkerStatusReason{
+				Check:   types.AdmissionCheckCPU,
+				Value:   m.CPUPercent,
+				Limit:   t.MaxCPUPercent,
+				Message: fmt.Sprintf("CPU use is %.1f%%, %s the maximum of %.1f%%", m.CPUPercent, admissionComparison(m.CPUPercent > t.MaxCPUPercent, "above"), t.MaxCPUPercent),
+			})
+		}
+	}
+	return reasons, cpuSamples
+}
+
+// admissionComparison describes a metric against its limit: past it, or still within
+// the recovery margin after having been past it
+func admissionComparison(past bool, word string) string {
+	if past {
+		return word
+	}
+	return "not yet clear of"
+}
+
+// hasAdmissionCheck reports whether reasons include check
+func hasAdmissionCheck(reasons []client.WorkerStatusReason, check string) bool {
+	for _, reason := range reasons {
+		if reason.Check == check {
+			return true
+		}
+	}
+	return false
+}
+
+// SetAdmissionThresholds makes the worker stop claiming jobs, and report itself
+// degraded, while its metrics are past any of the thresholds
+func (o *Orchestrator) SetAdmissionThresholds(thresholds AdmissionThresholds) {
+	o.admission = thresholds
+}
+
+// evaluateAdmission checks metrics against the admission thresholds and returns the
+// ones the worker is over. Going over or back under a threshold is logged to the hub,
+// and reported as changed so the caller can send a heartbeat straight away.
+func (o *Orchestrator) evaluateAdmission(ctx context.Context, m *client.SystemMetrics) ([]client.WorkerStatusReason, bool) {
+	o.admissionMu.Lock()
+	previous := o.statusReasons
+	reasons, cpuSamples := o.admission.check(m, previous, o.cpuSamplesOver)
+	o.statusReasons, o.cpuSamplesOver = reasons, cpuSamples
+	o.admissionMu.Unlock()
+
+	if admissionChecks(previous) == admissionChecks(reasons) {
+		return reasons, false
+	}
+
+	if len(reasons) == 0 {
+		log.Printf("worker %s is back under its admission thresholds, claiming jobs again", o.workerID)
+		o.logToHub(ctx, "info", "worker is back under its admission thresholds, claiming jobs again", nil, nil, nil, nil, nil)
+		return reasons, true
+	}
+
+	messages := make([]string, len(reasons))
+	for i, reason := range reasons {
+		messages[i] = reason.Message
+	}
+	message := fmt.Sprintf("worker stopped claiming jobs: %s", strings.Join(messages, "; "))
+	log.Printf("worker %s %s", o.workerID, strings.TrimPrefix(message, "worker "))
+	o.logToHub(ctx, "warn", message, nil, nil, nil, nil, map[string]any{
+		"status_reasons": reasons,
+	})
+	return reasons, true
+}
+
+// admissionChecks names the checks a worker is over, to tell when that changes
+func admissionChecks(reasons []client.WorkerStatusReason) string {
+	checks := make([]string, len(reasons))
+	for i, reason := range reasons {
+		checks[i] = reason.Check
+	}
+	return strings.Join(checks, ",")
+}
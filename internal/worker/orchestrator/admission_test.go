@@ -0,0 +1,114 @@
+package orchestrator
+
+import (
+	"strings"
+	"testing"
+
+	"xvault/internal/worker/client"
+)
+
+func TestAdmissionThresholdsCheck(t *testing.T) {
+	const gb = 1 << 30
+	disk := func(free int64) client.SystemMetrics {
+		return client.SystemMetrics{DiskTotalBytes: 100 * gb, DiskFreeBytes: free}
+	}
+	memory := func(percent float64) client.SystemMetrics {
+		return client.SystemMetrics{MemoryTotalBytes: 16 * gb, MemoryPercent: percent}
+	}
+	cpu := func(percent float64) client.SystemMetrics {
+		return client.SystemMetrics{CPUPercent: percent}
+	}
+
+	type step struct {
+		metrics client.SystemMetrics
+		// want names the checks the worker is over after the sample, comma separated
+		want string
+		// message, when set, is part of the first reason's message
+		message string
+	}
+	tests := []struct {
+		name       string
+		thresholds AdmissionThresholds
+		steps      []step
+	}{
+		{
+			name:       "disk enters, holds within the margin and recovers",
+			thresholds: AdmissionThresholds{MinDiskFreeBytes: 10 * gb},
+			steps: []step{
+				{metrics: disk(12 * gb)},
+				{metrics: disk(10 * gb)},
+				{metrics: disk(9 * gb), want: "disk_free_bytes", message: "below the minimum"},
+				{metrics: disk(10*gb + gb/2), want: "disk_free_bytes", message: "not yet clear of the minimum"},
+				{metrics: disk(11 * gb)},
+				{metrics: disk(10*gb + gb/2)},
+			},
+		},
+		{
+			name:       "memory enters, holds within the margin and recovers",
+			thresholds: AdmissionThresholds{MaxMemoryPercent: 80},
+			steps: []step{
+				{metrics: memory(80)},
+				{metrics: memory(85), want: "memory_percent", message: "above the maximum"},
+				{metrics: memory(75), want: "memory_percent", message: "not yet clear of the maximum"},
+				{metrics: memory(72)},
+				{metrics: memory(79)},
+			},
+		},
+		{
+			name:       "cpu enters after three samples, holds within the margin and recovers",
+			thresholds: AdmissionThresholds{MaxCPUPercent: 90},
+			steps: []step{
+				{metrics: cpu(95)},
+				{metrics: cpu(95)},
+				{metrics: cpu(95), want: "cpu_percent", message: "above the maximum"},
+				{metrics: cpu(85), want: "cpu_percent", message: "not yet clear of the maximum"},
+				{metrics: cpu(80)},
+				{metrics: cpu(95)},
+			},
+		},
+		{
+			name:       "cpu samples under the limit restart the count",
+			thresholds: AdmissionThresholds{MaxCPUPercent: 90},
+			steps: []step{
+				{metrics: cpu(95)},
+				{metrics: cpu(95)},
+				{metrics: cpu(50)},
+				{metrics: cpu(95)},
+				{metrics: cpu(95)},
+				{metrics: cpu(95), want: "cpu_percent"},
+			},
+		},
+		{
+			name:       "each check holds on its own",
+			thresholds: AdmissionThresholds{MinDiskFreeBytes: 10 * gb, MaxMemoryPercent: 80},
+			steps: []step{
+				{metrics: client.SystemMetrics{DiskTotalBytes: 100 * gb, DiskFreeBytes: 9 * gb, MemoryTotalBytes: 16 * gb, MemoryPercent: 85}, want: "disk_free_bytes,memory_percent"},
+				{metrics: client.SystemMetrics{DiskTotalBytes: 100 * gb, DiskFreeBytes: 12 * gb, MemoryTotalBytes: 16 * gb, MemoryPercent: 75}, want: "memory_percent"},
+				{metrics: client.SystemMetrics{DiskTotalBytes: 100 * gb, DiskFreeBytes: 10 * gb, MemoryTotalBytes: 16 * gb, MemoryPercent: 70}},
+			},
+		},
+		{
+			name:       "metrics the collector could not read are not checked",
+			thresholds: AdmissionThresholds{MinDiskFreeBytes: 10 * gb, MaxMemoryPercent: 80},
+			steps: []step{
+				{metrics: client.SystemMetrics{MemoryPercent: 100}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var reasons []client.WorkerStatusReason
+			var cpuSamples int
+			for i, s := range tt.steps {
+				reasons, cpuSamples = tt.thresholds.check(&s.metrics, reasons, cpuSamples)
+				if got := admissionChecks(reasons); got != s.want {
+					t.Fatalf("sample %d: over %q, want %q", i+1, got, s.want)
+				}
+				if s.message != "" && !strings.Contains(reasons[0].Message, s.message) {
+					t.Errorf("sample %d: message %q does not say %q", i+1, reasons[0].Message, s.message)
+				}
+			}
+		})
+	}
+}
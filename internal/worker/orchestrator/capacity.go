@@ -0,0 +1,41 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"xvault/internal/worker/client"
+	"xvault/internal/worker/storage"
+	"xvault/pkg/types"
+)
+
+// checkCapacity checks that a backup of a source estimated at sourceBytes fits in this
+// worker's scratch directory and storage before any of it is pulled. When it does not,
+// the returned request releases the job, with an error code saying which ran short, so
+// the hub can hand it to a worker with more room instead of it failing mid-transfer.
+func (o *Orchestrator) checkCapacity(ctx context.Context, job *client.JobClaimResponse, sourceBytes int64) (*client.JobCompleteRequest, error) {
+	err := o.storage.CheckCapacity(sourceBytes)
+	if err == nil {
+		log.Printf("source of job %s is estimated at %d bytes", job.JobID, sourceBytes)
+		return nil, nil
+	}
+
+	req := &client.JobCompleteRequest{
+		WorkerID: o.workerID,
+		Status:   "failed",
+		Error:    fmt.Sprintf("failed to check capacity: %v", err),
+	}
+	switch {
+	case errors.Is(err, storage.ErrInsufficientScratchSpace):
+		req.Status, req.ErrorCode, req.Error = "released", types.JobErrorInsufficientScratchSpace, err.Error()
+	case errors.Is(err, storage.ErrInsufficientStorageSpace):
+		req.Status, req.ErrorCode, req.Error = "released", types.JobErrorInsufficientStorageSpace, err.Error()
+	}
+	o.logToHub(ctx, "warn", req.Error, &job.JobID, nil, &job.SourceID, nil, map[string]any{
+		"estimated_bytes": sourceBytes,
+		"error_code":      req.ErrorCode,
+	})
+	return req, err
+}
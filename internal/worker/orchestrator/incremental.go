@@ -0,0 +1,93 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+
+	"xvault/internal/worker/client"
+	"xvault/internal/worker/connector"
+	"xvault/internal/worker/packager"
+	"xvault/pkg/snapshot"
+	"xvault/pkg/types"
+)
+
+// loadBaseline prepares an incremental pull for a backup job. It returns nil for full
+// backups, and also when the base snapshot's file index cannot be read (for example
+// because the base lives on another worker); the job then runs as a full backup.
+func (o *Orchestrator) loadBaseline(ctx context.Context, job *client.JobClaimResponse, verifyHash bool) *connector.Baseline {
+	if job.Payload.BackupMode != string(types.BackupModeIncremental) || job.Payload.BaseSnapshotID == nil {
+		return nil
+	}
+	baseID := *job.Payload.BaseSnapshotID
+
+	fallback := func(err error) *connector.Baseline {
+		msg := fmt.Sprintf("base snapshot %s unusable, running full backup: %v", baseID, err)
+		log.Print(msg)
+		o.logToHub(ctx, "warn", msg, &job.JobID, nil, &job.SourceID, nil, map[string]any{
+			"base_snapshot_id": baseID,
+		})
+		return nil
+	}
+
+	// Snapshots from before roots were recorded mirror each path under its base name,
+	// so none of their entries would line up with the current layout
+	manifest, _, err := snapshot.ReadManifest(ctx, o.storage.Location(job.TenantID, job.SourceID, baseID))
+	if err != nil {
+		return fallback(err)
+	}
+	if len(manifest.ContentSummary.Roots) == 0 {
+		return fallback(fmt.Errorf("base snapshot predates the absolute path layout"))
+	}
+
+	// The file index and archive are encrypted to the tenant key the manifest names
+	keyResp, err := o.hubClient.GetTenantPrivateKey(ctx, job.TenantID, snapshot.DecryptionKeyID(manifest))
+	if err != nil {
+		return fallback(fmt.Errorf("failed to get tenant private key: %w", err))
+	}
+
+	index, err := o.storage.ReadFileIndex(ctx, job.TenantID, job.SourceID, baseID, keyResp.PrivateKey)
+	if err != nil {
+		return fallback(err)
+	}
+
+	entries := make(map[string]types.FileIndexEntry, len(index.Entries))
+	for _, entry := range index.Entries {
+		entries[entry.Path] = entry
+	}
+
+	privateKey := keyResp.PrivateKey
+	return &connector.Baseline{
+		SnapshotID: baseID,
+		Entries:    entries,
+		VerifyHash: verifyHash,
+		Open: func() (io.ReadCloser, error) {
+			return o.storage.OpenSnapshotStream(ctx, job.TenantID, job.SourceID, baseID, privateKey)
+		},
+	}
+}
+
+// markIncremental records an incremental pull on the packager so the manifest and
+// file index describe it
+func markIncremental(pkg *packager.Packager, baseline *connector.Baseline, stats *connector.PullStats) {
+	if baseline == nil {
+		return
+	}
+	pkg.SetIncremental(types.IncrementalSummary{
+		BaseSnapshotID:   baseline.SnapshotID,
+		FilesTransferred: stats.FilesDownloaded,
+		BytesTransferred: stats.TotalBytes,
+		FilesUnchanged:   stats.FilesUnchanged,
+		BytesUnchanged:   stats.BytesUnchanged,
+		FilesDeleted:     len(stats.Deleted),
+	}, stats.Deleted)
+}
+
+// baseSnapshotID returns the base of an incremental snapshot, or "" for a full one
+func baseSnapshotID(manifest types.SnapshotManifest) string {
+	if manifest.Incremental == nil {
+		return ""
+	}
+	return manifest.Incremental.BaseSnapshotID
+}
@@ -0,0 +1,138 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"xvault/internal/worker/client"
+	"xvault/internal/worker/storage"
+	"xvault/pkg/backend"
+	"xvault/pkg/types"
+)
+
+// processOffsitePushJob pushes this worker's copy of a snapshot, still encrypted, to
+// the tenant's offsite target. The copy uses the same key layout as local storage
+// and object stores, below the target's base path.
+func (o *Orchestrator) processOffsitePushJob(ctx context.Context, job *client.JobClaimResponse) (client.JobCompleteRequest, error) {
+	payload := job.Payload
+	if payload.ReplicateSnapshotID == nil || *payload.ReplicateSnapshotID == "" || payload.ReplicaID == "" {
+		o.logToHub(ctx, "error", "replicate_snapshot_id and replica_id are required in payload", &job.JobID, nil, nil, nil, nil)
+		return client.JobCompleteRequest{
+			WorkerID: o.workerID,
+			Status:   "failed",
+			Error:    "replicate_snapshot_id and replica_id are required in payload",
+		}, fmt.Errorf("missing offsite push payload")
+	}
+	snapshotID := *payload.ReplicateSnapshotID
+	start := time.Now()
+
+	failed := func(err error) (client.JobCompleteRequest, error) {
+		o.logToHub(ctx, "error", fmt.Sprintf("failed to push snapshot %s offsite: %v", snapshotID, err), &job.JobID, &snapshotID, &job.SourceID, nil, nil)
+		return client.JobCompleteRequest{
+			WorkerID: o.workerID,
+			Status:   "failed",
+			Error:    fmt.Sprintf("failed to push snapshot offsite: %v", err),
+		}, err
+	}
+
+	// Read the snapshot from the backend its locator names; without a locator it is
+	// in this worker's storage
+	src := o.storage.Location(job.TenantID, job.SourceID, snapshotID)
+	if locator := payload.ReplicateLocator; locator != nil {
+		if locator.StorageBackend == string(types.StorageBackendS3) {
+			var err error
+			if src, err = o.storage.ObjectLocation(locator.Bucket, locator.ObjectKey); err != nil {
+				return failed(err)
+			}
+		} else {
+			src = o.storage.LocalLocation(job.TenantID, job.SourceID, snapshotID)
+		}
+	}
+
+	target, err := o.openOffsiteTarget(ctx, job.TenantID, payload.ReplicateOffsiteTargetID)
+	if err != nil {
+		return failed(err)
+	}
+	defer target.Close()
+
+	log.Printf("worker %s pushing snapshot %s offsite", o.workerID, snapshotID)
+	o.logToHub(ctx, "info", fmt.Sprintf("pushing snapshot %s offsite", snapshotID), &job.JobID, &snapshotID, &job.SourceID, nil, map[string]any{
+		"replica_id":        payload.ReplicaID,
+		"offsite_target_id": payload.ReplicateOffsiteTargetID,
+	})
+
+	prefix := o.storage.SnapshotKey(job.TenantID, job.SourceID, snapshotID)
+	manifest, err := storage.PushSnapshot(ctx, src, target, prefix)
+	if err != nil {
+		return failed(err)
+	}
+
+	locator := o.snapshotLocator("", *manifest)
+	locator.StorageBackend = string(types.StorageBackendSFTP)
+	locator.ObjectKey = prefix
+	locator.OffsiteTargetID = payload.ReplicateOffsiteTargetID
+
+	durationMs := time.Since(start).Milliseconds()
+	log.Printf("worker %s pushed snapshot %s offsite (%d bytes)", o.workerID, snapshotID, manifest.SizeBytes)
+	o.logToHub(ctx, "info", fmt.Sprintf("snapshot %s pushed offsite", snapshotID), &job.JobID, &snapshotID, &job.SourceID, nil, map[string]any{
+		"replica_id":        payload.ReplicaID,
+		"offsite_target_id": payload.ReplicateOffsiteTargetID,
+		"duration_ms":       durationMs,
+	})
+
+	return client.JobCompleteRequest{
+		WorkerID: o.workerID,
+		Status:   "completed",
+		Replica: &client.ReplicaResult{
+			ReplicaID: payload.ReplicaID,
+			Locator:   locator,
+		},
+	}, nil
+}
+
+// openOffsiteTarget connects to a tenant's offsite target
+func (o *Orchestrator) openOffsiteTarget(ctx context.Context, tenantID, targetID string) (*backend.SFTP, error) {
+	if targetID == "" {
+		return nil, fmt.Errorf("offsite target ID is required")
+	}
+	target, err := o.hubClient.GetOffsiteTarget(ctx, targetID)
+	if err != nil {
+		return nil, err
+	}
+	if target.TenantID != tenantID {
+		return nil, fmt.Errorf("offsite target %s does not belong to tenant %s", targetID, tenantID)
+	}
+
+	sftp, err := backend.NewSFTP(backend.SFTPConfig{
+		Host:       target.Host,
+		Port:       target.Port,
+		Username:   target.Username,
+		Password:   target.Password,
+		PrivateKey: target.PrivateKey,
+		HostKey:    target.HostKey,
+		BasePath:   target.BasePath,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to offsite target %s: %w", target.Host, err)
+	}
+	return sftp, nil
+}
+
+// deleteOffsite removes a copy of a snapshot from a tenant's offsite target
+func (o *Orchestrator) deleteOffsite(ctx context.Context, tenantID string, locator *client.SnapshotLocator) error {
+	if locator.ObjectKey == "" {
+		return fmt.Errorf("offsite copy has no object key")
+	}
+	target, err := o.openOffsiteTarget(ctx, tenantID, locator.OffsiteTargetID)
+	if err != nil {
+		return err
+	}
+	defer target.Close()
+
+	if _, err := backend.DeletePrefix(ctx, target, locator.ObjectKey); err != nil {
+		return fmt.Errorf("failed to delete offsite copy: %w", err)
+	}
+	return nil
+}
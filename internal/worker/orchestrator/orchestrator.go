@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -13,6 +14,7 @@ import (
 	"xvault/internal/worker/metrics"
 	"xvault/internal/worker/packager"
 	"xvault/internal/worker/storage"
+	"xvault/pkg/backend"
 	"xvault/pkg/crypto"
 	"xvault/pkg/types"
 )
@@ -27,6 +29,20 @@ type Orchestrator struct {
 	metricsCollector *metrics.Collector
 	activeJobs       int32
 	storageBasePath  string
+	repositoryMode   bool
+	replicationURL   string
+
+	// Resource limits past which the worker stops claiming jobs, the ones it was last
+	// found over, and how many CPU samples in a row were over the CPU limit
+	admission      AdmissionThresholds
+	admissionMu    sync.Mutex
+	statusReasons  []client.WorkerStatusReason
+	cpuSamplesOver int
+
+	// Ed25519 identity: the public half is registered with the hub, the private half
+	// signs snapshot manifests
+	identityPublicKey string
+	identityKey       string
 }
 
 // NewOrchestrator creates a new worker orchestrator
@@ -45,6 +61,60 @@ func NewOrchestrator(workerID string, hubClient *client.HubClient, storageBase,
 	return o
 }
 
+// SetRepositoryMode switches backups between standalone artifacts and the
+// per-tenant deduplicated chunk repository
+func (o *Orchestrator) SetRepositoryMode(enabled bool) {
+	o.repositoryMode = enabled
+}
+
+// SetIdentityKey sets the worker's Ed25519 identity keypair (see crypto.LoadOrCreateSigningKey)
+func (o *Orchestrator) SetIdentityKey(publicKey, privateKey string) {
+	o.identityPublicKey = publicKey
+	o.identityKey = privateKey
+}
+
+// SetObjectStore uploads finished snapshots to an S3-compatible bucket instead of
+// keeping them on local storage
+func (o *Orchestrator) SetObjectStore(config backend.S3Config) error {
+	return o.storage.SetObjectStore(config)
+}
+
+// SetTierStore lets migrate_snapshot jobs move snapshots from local storage to an
+// S3-compatible bucket
+func (o *Orchestrator) SetTierStore(config backend.S3Config) error {
+	return o.storage.SetTierStore(config)
+}
+
+// SetReplicationURL advertises the base URL of this worker's replication server, from
+// which other workers copy the snapshots it holds
+func (o *Orchestrator) SetReplicationURL(url string) {
+	o.replicationURL = url
+}
+
+// Storage returns the worker's snapshot storage
+func (o *Orchestrator) Storage() *storage.Storage {
+	return o.storage
+}
+
+// storageBackends lists the backends this worker writes snapshots to
+func (o *Orchestrator) storageBackends() []string {
+	if o.storage.ObjectStore() != nil {
+		return []string{string(types.StorageBackendS3)}
+	}
+	return []string{string(types.StorageBackendLocalFS)}
+}
+
+// SetArtifactVolumeSize splits new artifacts into numbered volumes of size bytes;
+// 0 keeps each artifact in a single file
+func (o *Orchestrator) SetArtifactVolumeSize(size int64) {
+	o.storage.SetVolumeSize(size)
+}
+
+// SetScratchDir sets the directory sources are mirrored into before packaging
+func (o *Orchestrator) SetScratchDir(dir string) {
+	o.storage.SetScratchDir(dir)
+}
+
 // logToHub sends a log entry to the hub
 func (o *Orchestrator) logToHub(ctx context.Context, level, message string, jobID, snapshotID, sourceID, scheduleID *string, details map[string]any) {
 	detailsJSON, _ := json.Marshal(details)
@@ -77,6 +147,11 @@ func (o *Orchestrator) Run(ctx context.Context) error {
 		log.Printf("initial heartbeat failed: %v", err)
 	}
 
+	// Clean up after a crash before claiming any job
+	if err := o.recoverStorage(ctx); err != nil {
+		return fmt.Errorf("failed to recover storage: %w", err)
+	}
+
 	// Start heartbeat in separate goroutine so it continues during job processing
 	go func() {
 		heartbeatTicker := time.NewTicker(30 * time.Second)
@@ -119,11 +194,18 @@ func (o *Orchestrator) registerWorker(ctx context.Context) error {
 		WorkerID:        o.workerID,
 		Name:            fmt.Sprintf("Worker %s", o.workerID),
 		StorageBasePath: o.storage.SnapshotPath("", "", ""), // Get base path
+		PublicKey:       o.identityPublicKey,
 		Capabilities: map[string]any{
 			"connectors": []string{"ssh", "sftp", "mysql"},
-			"storage":    []string{"local_fs"},
+			"storage":    o.storageBackends(),
 		},
 	}
+	if o.replicationURL != "" {
+		req.Capabilities["replication_url"] = o.replicationURL
+	}
+	if o.storage.TierStore() != nil {
+		req.Capabilities["tier_storage"] = []string{string(types.StorageBackendS3)}
+	}
 
 	// Extract base path from storage
 	req.StorageBasePath = "/var/lib/xvault/backups" // Default from env
@@ -131,10 +213,52 @@ func (o *Orchestrator) registerWorker(ctx context.Context) error {
 	return o.hubClient.RegisterWorker(ctx, req)
 }
 
-// sendHeartbeat sends a heartbeat to the hub with system metrics
+// recoverStorage sweeps job temp directories and uncommitted snapshots left by a
+// previous run and flags hub records of snapshots whose files are incomplete
+func (o *Orchestrator) recoverStorage(ctx context.Context) error {
+	report, err := o.storage.Recover()
+	if err != nil {
+		return err
+	}
+	log.Printf("storage recovery: removed %d temp dirs and %d partial snapshots, found %d incomplete snapshots",
+		report.TempDirs, len(report.Partial), len(report.Incomplete))
+
+	// A partial snapshot normally has no hub record, since the job reports completion
+	// only after the commit; report them anyway in case it raced a crash
+	for _, damaged := range append(report.Partial, report.Incomplete...) {
+		req := client.SnapshotIntegrityRequest{
+			WorkerID: o.workerID,
+			Status:   string(types.SnapshotIntegrityIncomplete),
+			Error:    damaged.Problem,
+		}
+		recorded, err := o.hubClient.ReportSnapshotIntegrity(ctx, damaged.SnapshotID, req)
+		if err != nil {
+			log.Printf("failed to report incomplete snapshot %s: %v", damaged.SnapshotID, err)
+			continue
+		}
+		if recorded {
+			log.Printf("flagged snapshot %s as incomplete: %s", damaged.SnapshotID, damaged.Problem)
+		}
+	}
+	return nil
+}
+
+// sendHeartbeat sends a heartbeat to the hub with system metrics. An online worker
+// over an admission threshold reports itself degraded, with the reasons.
 func (o *Orchestrator) sendHeartbeat(ctx context.Context, status string) error {
 	// Collect system metrics
 	sysMetrics := o.metricsCollector.Collect()
+
+	var reasons []client.WorkerStatusReason
+	if status == "online" && o.admission.enabled() {
+		reasons, _ = o.evaluateAdmission(ctx, sysMetrics)
+	}
+	return o.reportStatus(ctx, status, sysMetrics, reasons)
+}
+
+// reportStatus sends a heartbeat with metrics already collected and checked against
+// the admission thresholds
+func (o *Orchestrator) reportStatus(ctx context.Context, status string, sysMetrics *client.SystemMetrics, reasons []client.WorkerStatusReason) error {
 	// Update active jobs count from atomic counter
 	sysMetrics.ActiveJobs = int(atomic.LoadInt32(&o.activeJobs))
 
@@ -143,11 +267,30 @@ func (o *Orchestrator) sendHeartbeat(ctx context.Context, status string) error {
 		Status:        status,
 		SystemMetrics: sysMetrics,
 	}
+	if status == "online" && len(reasons) > 0 {
+		req.Status = "degraded"
+		req.StatusReasons = reasons
+	}
 	return o.hubClient.SendHeartbeat(ctx, req)
 }
 
 // processNextJob attempts to claim and process the next available job
 func (o *Orchestrator) processNextJob(ctx context.Context) error {
+	// Leave jobs to other workers while over an admission threshold; a change is
+	// reported to the hub without waiting for the next heartbeat
+	if o.admission.enabled() {
+		sysMetrics := o.metricsCollector.Collect()
+		reasons, changed := o.evaluateAdmission(ctx, sysMetrics)
+		if changed {
+			if err := o.reportStatus(ctx, "online", sysMetrics, reasons); err != nil {
+				log.Printf("heartbeat failed: %v", err)
+			}
+		}
+		if len(reasons) > 0 {
+			return nil
+		}
+	}
+
 	// Claim a job
 	claimResp, err := o.hubClient.ClaimJob(ctx, o.workerID)
 	if err != nil {
@@ -173,6 +316,16 @@ func (o *Orchestrator) processNextJob(ctx context.Context) error {
 		completeReq, err = o.processBackupJob(ctx, claimResp)
 	case "delete_snapshot":
 		completeReq, err = o.processDeleteSnapshotJob(ctx, claimResp)
+	case "reconcile_storage":
+		completeReq, err = o.processReconcileStorageJob(ctx, claimResp)
+	case "verify_snapshot":
+		completeReq, err = o.processVerifySnapshotJob(ctx, claimResp)
+	case "replicate_snapshot":
+		completeReq, err = o.processReplicateSnapshotJob(ctx, claimResp)
+	case "migrate_snapshot":
+		completeReq, err = o.processMigrateSnapshotJob(ctx, claimResp)
+	case "rekey_snapshot":
+		completeReq, err = o.processRekeySnapshotJob(ctx, claimResp)
 	case "restore":
 		// Restore jobs are handled by the separate restore service
 		completeReq = client.JobCompleteRequest{
@@ -194,8 +347,16 @@ func (o *Orchestrator) processNextJob(ctx context.Context) error {
 		return err
 	}
 
+	// A migrated snapshot leaves local storage only once the hub locates it in the
+	// tier store
+	if completeReq.Migration != nil {
+		o.removeMigratedSnapshot(ctx, claimResp)
+	}
+
 	// Log job completion with error details if failed
-	if completeReq.Error != "" {
+	if completeReq.Status == "released" {
+		log.Printf("worker %s released job %s (%s): %s", o.workerID, claimResp.JobID, completeReq.ErrorCode, completeReq.Error)
+	} else if completeReq.Error != "" {
 		log.Printf("worker %s completed job %s with status: %s, error: %s", o.workerID, claimResp.JobID, completeReq.Status, completeReq.Error)
 		o.logToHub(ctx, "error", fmt.Sprintf("completed job %s with error: %s", claimResp.JobID, completeReq.Error), &claimResp.JobID, nil, nil, nil, map[string]any{
 			"status": completeReq.Status,
@@ -203,9 +364,13 @@ func (o *Orchestrator) processNextJob(ctx context.Context) error {
 		})
 	} else {
 		log.Printf("worker %s completed job %s with status: %s", o.workerID, claimResp.JobID, completeReq.Status)
-		o.logToHub(ctx, "info", fmt.Sprintf("completed job %s successfully", claimResp.JobID), &claimResp.JobID, nil, nil, nil, map[string]any{
+		details := map[string]any{
 			"status": completeReq.Status,
-		})
+		}
+		if completeReq.Snapshot != nil {
+			addCompressionDetails(details, completeReq.Snapshot.ManifestJSON)
+		}
+		o.logToHub(ctx, "info", fmt.Sprintf("completed job %s successfully", claimResp.JobID), &claimResp.JobID, nil, nil, nil, details)
 	}
 	return nil
 }
@@ -304,13 +469,17 @@ func (o *Orchestrator) processSSHBackup(ctx context.Context, job *client.JobClai
 
 	// Create SSH connector
 	sshConfig := &connector.SSHConfig{
-		Host:     sourceConfig.Host,
-		Port:     sourceConfig.Port,
-		Username: sourceConfig.Username,
-		Password: password,
-		Paths:    sourceConfig.Paths,
+		Host:        sourceConfig.Host,
+		Port:        sourceConfig.Port,
+		Username:    sourceConfig.Username,
+		Password:    password,
+		Paths:       sourceConfig.Paths,
+		PathAliases: sourceConfig.PathAliases,
+		Filter:      sourceConfig.FileFilter,
 	}
 	sftpConn := connector.NewSFTPConnector(sshConfig)
+	baseline := o.loadBaseline(ctx, job, sourceConfig.IncrementalHash)
+	sftpConn.SetBaseline(baseline)
 
 	// Connect and pull files
 	sftpClient, sshClient, err := sftpConn.Connect()
@@ -325,6 +494,20 @@ func (o *Orchestrator) processSSHBackup(ctx context.Context, job *client.JobClai
 	defer sftpClient.Close()
 	defer sshClient.Close()
 
+	// Make sure the source fits before mirroring it
+	sourceBytes, err := sftpConn.EstimateSize(sftpClient)
+	if err != nil {
+		o.logToHub(ctx, "error", fmt.Sprintf("failed to estimate source size: %v", err), &job.JobID, nil, nil, nil, nil)
+		return client.JobCompleteRequest{
+			WorkerID: o.workerID,
+			Status:   "failed",
+			Error:    fmt.Sprintf("failed to estimate source size: %v", err),
+		}, err
+	}
+	if declined, err := o.checkCapacity(ctx, job, sourceBytes); declined != nil {
+		return *declined, err
+	}
+
 	mirrorDir := tempDir + "/source-mirror"
 	stats, err := sftpConn.PullFiles(sftpClient, mirrorDir)
 	if err != nil {
@@ -336,43 +519,55 @@ func (o *Orchestrator) processSSHBackup(ctx context.Context, job *client.JobClai
 		}, err
 	}
 
-	log.Printf("pulled %d files (%d bytes) from source", stats.FilesDownloaded, stats.TotalBytes)
-	o.logToHub(ctx, "info", fmt.Sprintf("pulled %d files (%d bytes) from source", stats.FilesDownloaded, stats.TotalBytes), &job.JobID, nil, &job.SourceID, nil, map[string]any{
+	pullMessage := fmt.Sprintf("pulled %d files (%d bytes) from source", stats.FilesDownloaded, stats.TotalBytes)
+	if skipped := stats.Skipped.Total(); skipped > 0 {
+		pullMessage += fmt.Sprintf(", skipped %d entries", skipped)
+	}
+	log.Print(pullMessage)
+	pullDetails := map[string]any{
 		"files_downloaded": stats.FilesDownloaded,
 		"total_bytes":      stats.TotalBytes,
-	})
-
-	// Package and encrypt
-	pkg := packager.NewPackager(keyResp.PublicKey)
-	pkgResult, err := pkg.PackageBackup(mirrorDir, snapshotID, job.TenantID, job.SourceID, job.JobID, o.workerID)
-	if err != nil {
-		o.logToHub(ctx, "error", fmt.Sprintf("failed to package backup: %v", err), &job.JobID, &snapshotID, nil, nil, nil)
-		return client.JobCompleteRequest{
-			WorkerID: o.workerID,
-			Status:   "failed",
-			Error:    fmt.Sprintf("failed to package backup: %v", err),
-		}, err
 	}
+	if baseline != nil {
+		pullDetails["base_snapshot_id"] = baseline.SnapshotID
+		pullDetails["files_unchanged"] = stats.FilesUnchanged
+		pullDetails["bytes_unchanged"] = stats.BytesUnchanged
+		pullDetails["files_deleted"] = len(stats.Deleted)
+	}
+	if stats.Skipped.Total() > 0 {
+		pullDetails["skipped"] = stats.Skipped
+	}
+	o.logToHub(ctx, "info", pullMessage, &job.JobID, nil, &job.SourceID, nil, pullDetails)
 
-	// Write to local storage
-	localPath, sizeBytes, err := o.storage.WriteSnapshot(job.TenantID, job.SourceID, snapshotID, pkgResult.Artifact, pkgResult.Manifest)
+	// Package, encrypt and write to local storage
+	pkg := packager.NewPackager(keyResp.PublicKey)
+	pkg.SetEncryptionKeyID(keyResp.ID)
+	pkg.SetSigningKey(o.identityKey)
+	pkg.SetOwners(stats.Owners)
+	pkg.SetRoots(stats.Roots)
+	pkg.SetSkipped(stats.Skipped)
+	pkg.SetCompression(sourceConfig.Compression)
+	markIncremental(pkg, baseline, stats)
+	pkgResult, locator, sizeBytes, err := o.packageSnapshot(ctx, job, pkg, keyResp.PublicKey, mirrorDir, snapshotID)
 	if err != nil {
-		o.logToHub(ctx, "error", fmt.Sprintf("failed to write snapshot: %v", err), &job.JobID, &snapshotID, &job.SourceID, nil, map[string]any{
-			"local_path": localPath,
-			"size_bytes": sizeBytes,
-		})
+		o.logToHub(ctx, "error", err.Error(), &job.JobID, &snapshotID, &job.SourceID, nil, nil)
 		return client.JobCompleteRequest{
 			WorkerID: o.workerID,
 			Status:   "failed",
-			Error:    fmt.Sprintf("failed to write snapshot: %v", err),
+			Error:    err.Error(),
 		}, err
 	}
 
-	log.Printf("snapshot %s written to %s (%d bytes)", snapshotID, localPath, sizeBytes)
-	o.logToHub(ctx, "info", fmt.Sprintf("snapshot %s written to %s (%d bytes)", snapshotID, localPath, sizeBytes), &job.JobID, &snapshotID, &job.SourceID, nil, map[string]any{
-		"local_path": localPath,
-		"size_bytes": sizeBytes,
-	})
+	location, details := locatorDetails(locator)
+	details["size_bytes"] = sizeBytes
+	details["uncompressed_size"] = pkgResult.UncompressedSize
+	details["compressed_size"] = pkgResult.CompressedSize
+	details["sha256"] = pkgResult.SHA256
+	details["storage_mode"] = pkgResult.ManifestObj.StorageMode
+	details["logical_bytes"] = pkgResult.ManifestObj.LogicalSizeBytes
+	details["new_bytes"] = pkgResult.ManifestObj.NewBytes
+	log.Printf("snapshot %s written to %s (%d bytes)", snapshotID, location, sizeBytes)
+	o.logToHub(ctx, "info", fmt.Sprintf("snapshot %s written to %s (%d bytes)", snapshotID, location, sizeBytes), &job.JobID, &snapshotID, &job.SourceID, nil, details)
 
 	// Build success response
 	finishTime := time.Now()
@@ -390,11 +585,10 @@ func (o *Orchestrator) processSSHBackup(ctx context.Context, job *client.JobClai
 			DurationMs:          durationMs,
 			ManifestJSON:        pkgResult.Manifest,
 			EncryptionAlgorithm: "age-x25519",
-			Locator: client.SnapshotLocator{
-				StorageBackend: "local_fs",
-				WorkerID:       o.workerID,
-				LocalPath:      localPath,
-			},
+			Locator:             locator,
+			ManifestSignature:   pkgResult.ManifestSignature,
+			BackupMode:          string(pkgResult.ManifestObj.BackupMode),
+			BaseSnapshotID:      baseSnapshotID(pkgResult.ManifestObj),
 		},
 	}, nil
 }
@@ -494,6 +688,20 @@ func (o *Orchestrator) processMySQLBackup(ctx context.Context, job *client.JobCl
 	}
 	defer db.Close()
 
+	// Make sure the dump fits before writing it
+	sourceBytes, err := mysqlConn.EstimateSize(db)
+	if err != nil {
+		o.logToHub(ctx, "error", fmt.Sprintf("failed to estimate database size: %v", err), &job.JobID, nil, nil, nil, nil)
+		return client.JobCompleteRequest{
+			WorkerID: o.workerID,
+			Status:   "failed",
+			Error:    fmt.Sprintf("failed to estimate database size: %v", err),
+		}, err
+	}
+	if declined, err := o.checkCapacity(ctx, job, sourceBytes); declined != nil {
+		return *declined, err
+	}
+
 	// Dump database to file
 	dumpPath := tempDir + "/dump.sql"
 	stats, err := mysqlConn.DumpDatabase(db, dumpPath)
@@ -513,37 +721,31 @@ func (o *Orchestrator) processMySQLBackup(ctx context.Context, job *client.JobCl
 		"size_bytes":       stats.SizeBytes,
 	})
 
-	// Package and encrypt
+	// Package, encrypt and write to local storage
 	pkg := packager.NewPackager(keyResp.PublicKey)
-	pkgResult, err := pkg.PackageBackup(tempDir, snapshotID, job.TenantID, job.SourceID, job.JobID, o.workerID)
-	if err != nil {
-		o.logToHub(ctx, "error", fmt.Sprintf("failed to package backup: %v", err), &job.JobID, &snapshotID, nil, nil, nil)
-		return client.JobCompleteRequest{
-			WorkerID: o.workerID,
-			Status:   "failed",
-			Error:    fmt.Sprintf("failed to package backup: %v", err),
-		}, err
-	}
-
-	// Write to local storage
-	localPath, sizeBytes, err := o.storage.WriteSnapshot(job.TenantID, job.SourceID, snapshotID, pkgResult.Artifact, pkgResult.Manifest)
+	pkg.SetEncryptionKeyID(keyResp.ID)
+	pkg.SetSigningKey(o.identityKey)
+	pkg.SetCompression(sourceConfig.Compression)
+	pkgResult, locator, sizeBytes, err := o.packageSnapshot(ctx, job, pkg, keyResp.PublicKey, tempDir, snapshotID)
 	if err != nil {
-		o.logToHub(ctx, "error", fmt.Sprintf("failed to write snapshot: %v", err), &job.JobID, &snapshotID, &job.SourceID, nil, map[string]any{
-			"local_path": localPath,
-			"size_bytes": sizeBytes,
-		})
+		o.logToHub(ctx, "error", err.Error(), &job.JobID, &snapshotID, &job.SourceID, nil, nil)
 		return client.JobCompleteRequest{
 			WorkerID: o.workerID,
 			Status:   "failed",
-			Error:    fmt.Sprintf("failed to write snapshot: %v", err),
+			Error:    err.Error(),
 		}, err
 	}
 
-	log.Printf("snapshot %s written to %s (%d bytes)", snapshotID, localPath, sizeBytes)
-	o.logToHub(ctx, "info", fmt.Sprintf("snapshot %s written to %s (%d bytes)", snapshotID, localPath, sizeBytes), &job.JobID, &snapshotID, &job.SourceID, nil, map[string]any{
-		"local_path": localPath,
-		"size_bytes": sizeBytes,
-	})
+	location, details := locatorDetails(locator)
+	details["size_bytes"] = sizeBytes
+	details["uncompressed_size"] = pkgResult.UncompressedSize
+	details["compressed_size"] = pkgResult.CompressedSize
+	details["sha256"] = pkgResult.SHA256
+	details["storage_mode"] = pkgResult.ManifestObj.StorageMode
+	details["logical_bytes"] = pkgResult.ManifestObj.LogicalSizeBytes
+	details["new_bytes"] = pkgResult.ManifestObj.NewBytes
+	log.Printf("snapshot %s written to %s (%d bytes)", snapshotID, location, sizeBytes)
+	o.logToHub(ctx, "info", fmt.Sprintf("snapshot %s written to %s (%d bytes)", snapshotID, location, sizeBytes), &job.JobID, &snapshotID, &job.SourceID, nil, details)
 
 	// Build success response
 	finishTime := time.Now()
@@ -561,11 +763,8 @@ func (o *Orchestrator) processMySQLBackup(ctx context.Context, job *client.JobCl
 			DurationMs:          durationMs,
 			ManifestJSON:        pkgResult.Manifest,
 			EncryptionAlgorithm: "age-x25519",
-			Locator: client.SnapshotLocator{
-				StorageBackend: "local_fs",
-				WorkerID:       o.workerID,
-				LocalPath:      localPath,
-			},
+			Locator:             locator,
+			ManifestSignature:   pkgResult.ManifestSignature,
 		},
 	}, nil
 }
@@ -584,11 +783,31 @@ func (o *Orchestrator) processDeleteSnapshotJob(ctx context.Context, job *client
 
 	snapshotID := *job.Payload.DeleteSnapshotID
 
+	// The hub refuses to delete held or locked snapshots; check again in case the
+	// snapshot was locked after the job was queued. Without an answer nothing is deleted.
+	if err := o.checkSnapshotUnlocked(ctx, snapshotID); err != nil {
+		o.logToHub(ctx, "warn", fmt.Sprintf("refusing to delete snapshot %s: %v", snapshotID, err), &job.JobID, &snapshotID, &job.SourceID, nil, nil)
+		return client.JobCompleteRequest{
+			WorkerID: o.workerID,
+			Status:   "failed",
+			Error:    fmt.Sprintf("refusing to delete snapshot: %v", err),
+		}, err
+	}
+
 	log.Printf("worker %s deleting snapshot %s", o.workerID, snapshotID)
 	o.logToHub(ctx, "info", fmt.Sprintf("deleting snapshot %s", snapshotID), &job.JobID, &snapshotID, &job.SourceID, nil, nil)
 
-	// Delete snapshot from local storage
-	err := o.storage.DeleteSnapshot(job.TenantID, job.SourceID, snapshotID)
+	// Delete the snapshot from the backend its locator names; jobs queued before
+	// locators were sent only ever referred to local storage
+	var err error
+	switch locator := job.Payload.DeleteLocator; {
+	case locator != nil && locator.StorageBackend == string(types.StorageBackendS3):
+		err = o.storage.DeleteObjects(ctx, locator.Bucket, locator.ObjectKey)
+	case locator != nil && locator.StorageBackend == string(types.StorageBackendSFTP):
+		err = o.deleteOffsite(ctx, job.TenantID, locator)
+	default:
+		err = o.storage.DeleteSnapshot(job.TenantID, job.SourceID, snapshotID)
+	}
 	if err != nil {
 		o.logToHub(ctx, "error", fmt.Sprintf("failed to delete snapshot: %v", err), &job.JobID, &snapshotID, &job.SourceID, nil, nil)
 		return client.JobCompleteRequest{
@@ -607,6 +826,27 @@ func (o *Orchestrator) processDeleteSnapshotJob(ctx context.Context, job *client
 	}, nil
 }
 
+// checkSnapshotUnlocked returns an error unless the hub confirms a snapshot is neither
+// under legal hold nor locked
+func (o *Orchestrator) checkSnapshotUnlocked(ctx context.Context, snapshotID string) error {
+	status, err := o.hubClient.GetSnapshotLockStatus(ctx, snapshotID)
+	if err != nil {
+		return fmt.Errorf("failed to check snapshot lock: %w", err)
+	}
+	if status == nil {
+		return nil
+	}
+	switch {
+	case status.LegalHold:
+		return fmt.Errorf("snapshot is under legal hold")
+	case status.Locked && status.LockedUntil != nil:
+		return fmt.Errorf("snapshot is locked until %s", status.LockedUntil.Format(time.RFC3339))
+	case status.Locked:
+		return fmt.Errorf("snapshot is locked")
+	}
+	return nil
+}
+
 // Shutdown gracefully shuts down the worker
 func (o *Orchestrator) Shutdown(ctx context.Context) error {
 	log.Printf("worker %s shutting down...", o.workerID)
@@ -0,0 +1,113 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"xvault/internal/worker/client"
+	"xvault/pkg/types"
+)
+
+// processReconcileStorageJob inventories this worker's snapshot directories for the
+// hub to diff against its records, or quarantines or purges the orphaned directories
+// the hub names
+func (o *Orchestrator) processReconcileStorageJob(ctx context.Context, job *client.JobClaimResponse) (client.JobCompleteRequest, error) {
+	action := job.Payload.ReconcileAction
+	switch types.ReconcileAction(action) {
+	case "", types.ReconcileActionInventory:
+		return o.inventoryStorage(ctx, job)
+	case types.ReconcileActionQuarantine, types.ReconcileActionPurge:
+		return o.resolveOrphans(ctx, job)
+	}
+
+	err := fmt.Errorf("unknown reconcile action: %s", action)
+	return client.JobCompleteRequest{
+		WorkerID: o.workerID,
+		Status:   "failed",
+		Error:    err.Error(),
+	}, err
+}
+
+// inventoryStorage reports every committed snapshot directory
+func (o *Orchestrator) inventoryStorage(ctx context.Context, job *client.JobClaimResponse) (client.JobCompleteRequest, error) {
+	entries, err := o.storage.Inventory()
+	if err != nil {
+		o.logToHub(ctx, "error", fmt.Sprintf("storage inventory failed: %v", err), &job.JobID, nil, nil, nil, nil)
+		return client.JobCompleteRequest{
+			WorkerID: o.workerID,
+			Status:   "failed",
+			Error:    fmt.Sprintf("storage inventory failed: %v", err),
+		}, err
+	}
+
+	inventory := &client.StorageInventory{Entries: make([]client.StorageInventoryEntry, len(entries))}
+	for i, entry := range entries {
+		inventory.Entries[i] = client.StorageInventoryEntry{
+			TenantID:      entry.TenantID,
+			SourceID:      entry.SourceID,
+			SnapshotID:    entry.SnapshotID,
+			LocalPath:     entry.Path,
+			StorageMode:   string(entry.StorageMode),
+			ArtifactBytes: entry.ArtifactBytes,
+			TotalBytes:    entry.TotalBytes,
+			Error:         entry.Error,
+		}
+	}
+
+	log.Printf("worker %s inventoried %d snapshot directories", o.workerID, len(entries))
+	o.logToHub(ctx, "info", fmt.Sprintf("inventoried %d snapshot directories", len(entries)), &job.JobID, nil, nil, nil, nil)
+
+	return client.JobCompleteRequest{
+		WorkerID:  o.workerID,
+		Status:    "completed",
+		Inventory: inventory,
+	}, nil
+}
+
+// resolveOrphans quarantines or purges the snapshot directories named in the job.
+// Every target is attempted; the job fails if any of them could not be handled.
+func (o *Orchestrator) resolveOrphans(ctx context.Context, job *client.JobClaimResponse) (client.JobCompleteRequest, error) {
+	action := types.ReconcileAction(job.Payload.ReconcileAction)
+
+	var failures []string
+	for _, target := range job.Payload.ReconcileTargets {
+		var err error
+		details := map[string]any{
+			"action":    action,
+			"tenant_id": target.TenantID,
+			"source_id": target.SourceID,
+		}
+		if action == types.ReconcileActionQuarantine {
+			var quarantinePath string
+			quarantinePath, err = o.storage.QuarantineSnapshot(target.TenantID, target.SourceID, target.SnapshotID)
+			details["quarantine_path"] = quarantinePath
+		} else {
+			err = o.storage.PurgeSnapshot(target.TenantID, target.SourceID, target.SnapshotID)
+		}
+
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", target.SnapshotID, err))
+			details["error"] = err.Error()
+			o.logToHub(ctx, "error", fmt.Sprintf("failed to %s orphaned snapshot %s", action, target.SnapshotID), &job.JobID, nil, nil, nil, details)
+			continue
+		}
+		log.Printf("worker %s: %s orphaned snapshot %s", o.workerID, action, target.SnapshotID)
+		o.logToHub(ctx, "info", fmt.Sprintf("%s orphaned snapshot %s", action, target.SnapshotID), &job.JobID, nil, nil, nil, details)
+	}
+
+	if len(failures) > 0 {
+		err := fmt.Errorf("failed to %s %d of %d orphans: %s", action, len(failures), len(job.Payload.ReconcileTargets), strings.Join(failures, "; "))
+		return client.JobCompleteRequest{
+			WorkerID: o.workerID,
+			Status:   "failed",
+			Error:    err.Error(),
+		}, err
+	}
+
+	return client.JobCompleteRequest{
+		WorkerID: o.workerID,
+		Status:   "completed",
+	}, nil
+}
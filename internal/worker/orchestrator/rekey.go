@@ -0,0 +1,85 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"xvault/internal/worker/client"
+	"xvault/pkg/crypto"
+)
+
+// processRekeySnapshotJob re-encrypts a snapshot on this worker's local storage to
+// the tenant key named in the payload, decrypting it with whichever of the tenant's
+// usable keys it was encrypted to. The new manifest is signed and reported; a
+// snapshot already on the key is reported again, so a job whose report was lost can
+// simply be rerun.
+func (o *Orchestrator) processRekeySnapshotJob(ctx context.Context, job *client.JobClaimResponse) (client.JobCompleteRequest, error) {
+	payload := job.Payload
+	if payload.RekeySnapshotID == nil || *payload.RekeySnapshotID == "" || payload.RekeyKeyID == "" {
+		o.logToHub(ctx, "error", "rekey_snapshot_id and rekey_key_id are required in payload", &job.JobID, nil, nil, nil, nil)
+		return client.JobCompleteRequest{
+			WorkerID: o.workerID,
+			Status:   "failed",
+			Error:    "rekey_snapshot_id and rekey_key_id are required in payload",
+		}, fmt.Errorf("missing rekey_snapshot_id or rekey_key_id")
+	}
+	snapshotID := *payload.RekeySnapshotID
+	start := time.Now()
+
+	failed := func(err error) (client.JobCompleteRequest, error) {
+		o.logToHub(ctx, "error", fmt.Sprintf("failed to re-encrypt snapshot %s: %v", snapshotID, err), &job.JobID, &snapshotID, &job.SourceID, nil, nil)
+		return client.JobCompleteRequest{
+			WorkerID: o.workerID,
+			Status:   "failed",
+			Error:    fmt.Sprintf("failed to re-encrypt snapshot: %v", err),
+		}, err
+	}
+
+	newKey, err := o.hubClient.GetTenantPublicKey(ctx, job.TenantID)
+	if err != nil {
+		return failed(fmt.Errorf("failed to get tenant public key: %w", err))
+	}
+	if newKey.ID != payload.RekeyKeyID {
+		return failed(fmt.Errorf("key %s is no longer the tenant's active key", payload.RekeyKeyID))
+	}
+	// Every usable key, since which one the snapshot needs is only known from its manifest
+	oldKeys, err := o.hubClient.GetTenantPrivateKey(ctx, job.TenantID, "")
+	if err != nil {
+		return failed(fmt.Errorf("failed to get tenant private key: %w", err))
+	}
+
+	log.Printf("worker %s re-encrypting snapshot %s to key %s", o.workerID, snapshotID, newKey.ID)
+	manifest, manifestJSON, err := o.storage.RekeySnapshot(ctx, job.TenantID, job.SourceID, snapshotID, oldKeys.PrivateKey, newKey.PublicKey, newKey.ID)
+	if err != nil {
+		return failed(err)
+	}
+
+	var signature string
+	if o.identityKey != "" {
+		if signature, err = crypto.SignManifest(manifestJSON, o.identityKey); err != nil {
+			return failed(fmt.Errorf("failed to sign manifest: %w", err))
+		}
+	}
+
+	durationMs := time.Since(start).Milliseconds()
+	log.Printf("worker %s re-encrypted snapshot %s (%d bytes)", o.workerID, snapshotID, manifest.SizeBytes)
+	o.logToHub(ctx, "info", fmt.Sprintf("snapshot %s re-encrypted", snapshotID), &job.JobID, &snapshotID, &job.SourceID, nil, map[string]any{
+		"key_id":      newKey.ID,
+		"size_bytes":  manifest.SizeBytes,
+		"duration_ms": durationMs,
+	})
+
+	return client.JobCompleteRequest{
+		WorkerID: o.workerID,
+		Status:   "completed",
+		Rekey: &client.SnapshotRekey{
+			SnapshotID:        snapshotID,
+			KeyID:             newKey.ID,
+			ManifestJSON:      manifestJSON,
+			ManifestSignature: signature,
+			Volumes:           o.snapshotLocator("", *manifest).Volumes,
+		},
+	}, nil
+}
@@ -0,0 +1,85 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"xvault/internal/worker/client"
+	"xvault/internal/worker/replication"
+	"xvault/pkg/backend"
+	"xvault/pkg/snapshot"
+)
+
+// processReplicateSnapshotJob copies a snapshot from the replication server of the
+// worker holding it into this worker's storage. The files stay encrypted; the copy is
+// verified against the source's manifest before it is committed, then moved to the
+// object store when one is configured. Pushes to a tenant's offsite target are
+// handled by processOffsitePushJob.
+func (o *Orchestrator) processReplicateSnapshotJob(ctx context.Context, job *client.JobClaimResponse) (client.JobCompleteRequest, error) {
+	payload := job.Payload
+	if payload.ReplicateOffsiteTargetID != "" {
+		return o.processOffsitePushJob(ctx, job)
+	}
+	if payload.ReplicateSnapshotID == nil || *payload.ReplicateSnapshotID == "" || payload.ReplicaID == "" || payload.ReplicateSourceURL == "" {
+		o.logToHub(ctx, "error", "replicate_snapshot_id, replica_id and replicate_source_url are required in payload", &job.JobID, nil, nil, nil, nil)
+		return client.JobCompleteRequest{
+			WorkerID: o.workerID,
+			Status:   "failed",
+			Error:    "replicate_snapshot_id, replica_id and replicate_source_url are required in payload",
+		}, fmt.Errorf("missing replication payload")
+	}
+	snapshotID := *payload.ReplicateSnapshotID
+	start := time.Now()
+
+	failed := func(err error) (client.JobCompleteRequest, error) {
+		o.logToHub(ctx, "error", fmt.Sprintf("failed to replicate snapshot %s: %v", snapshotID, err), &job.JobID, &snapshotID, &job.SourceID, nil, nil)
+		return client.JobCompleteRequest{
+			WorkerID: o.workerID,
+			Status:   "failed",
+			Error:    fmt.Sprintf("failed to replicate snapshot: %v", err),
+		}, err
+	}
+
+	log.Printf("worker %s replicating snapshot %s from %s", o.workerID, snapshotID, payload.ReplicateSourceURL)
+	o.logToHub(ctx, "info", fmt.Sprintf("replicating snapshot %s", snapshotID), &job.JobID, &snapshotID, &job.SourceID, nil, map[string]any{
+		"replica_id": payload.ReplicaID,
+		"source_url": payload.ReplicateSourceURL,
+	})
+
+	sourceURL := strings.TrimSuffix(payload.ReplicateSourceURL, "/") + replication.FilesPath(snapshotID)
+	source, err := backend.NewHTTP(sourceURL, payload.ReplicateToken)
+	if err != nil {
+		return failed(err)
+	}
+
+	localPath, manifest, err := o.storage.ReceiveSnapshot(ctx, snapshot.Location{Backend: source}, job.TenantID, job.SourceID, snapshotID)
+	if err != nil {
+		return failed(err)
+	}
+
+	locator := o.snapshotLocator(localPath, *manifest)
+	if err := o.uploadSnapshot(ctx, job.TenantID, job.SourceID, snapshotID, &locator); err != nil {
+		o.storage.DeleteSnapshot(job.TenantID, job.SourceID, snapshotID)
+		return failed(err)
+	}
+
+	durationMs := time.Since(start).Milliseconds()
+	log.Printf("worker %s replicated snapshot %s (%d bytes)", o.workerID, snapshotID, manifest.SizeBytes)
+	o.logToHub(ctx, "info", fmt.Sprintf("snapshot %s replicated", snapshotID), &job.JobID, &snapshotID, &job.SourceID, nil, map[string]any{
+		"replica_id":      payload.ReplicaID,
+		"storage_backend": locator.StorageBackend,
+		"duration_ms":     durationMs,
+	})
+
+	return client.JobCompleteRequest{
+		WorkerID: o.workerID,
+		Status:   "completed",
+		Replica: &client.ReplicaResult{
+			ReplicaID: payload.ReplicaID,
+			Locator:   locator,
+		},
+	}, nil
+}
@@ -0,0 +1,156 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"xvault/internal/worker/client"
+	"xvault/internal/worker/packager"
+	"xvault/pkg/crypto"
+	"xvault/pkg/types"
+)
+
+// packageSnapshot packages sourceDir with pkg and writes the snapshot to local storage,
+// either as a standalone artifact or into the tenant's chunk repository, then uploads
+// it when an object store is configured. Any partially written snapshot is removed on
+// failure.
+func (o *Orchestrator) packageSnapshot(ctx context.Context, job *client.JobClaimResponse, pkg *packager.Packager, publicKey, sourceDir, snapshotID string) (*packager.PackageResult, client.SnapshotLocator, int64, error) {
+	if o.repositoryMode {
+		pkgResult, localPath, sizeBytes, err := o.packageToRepository(job, pkg, publicKey, sourceDir, snapshotID)
+		if err != nil {
+			return nil, client.SnapshotLocator{}, 0, err
+		}
+		return pkgResult, o.snapshotLocator(localPath, pkgResult.ManifestObj), sizeBytes, nil
+	}
+
+	// Stream the artifact straight into the snapshot directory
+	artifact, err := o.storage.CreateArtifact(job.TenantID, job.SourceID, snapshotID)
+	if err != nil {
+		return nil, client.SnapshotLocator{}, 0, fmt.Errorf("failed to prepare snapshot directory: %w", err)
+	}
+
+	pkgResult, err := pkg.PackageBackup(sourceDir, artifact, snapshotID, job.TenantID, job.SourceID, job.JobID, o.workerID)
+	if err != nil {
+		o.storage.DeleteSnapshot(job.TenantID, job.SourceID, snapshotID)
+		return nil, client.SnapshotLocator{}, 0, fmt.Errorf("failed to package backup: %w", err)
+	}
+
+	localPath, sizeBytes, err := o.storage.WriteSnapshot(job.TenantID, job.SourceID, snapshotID, artifact, pkgResult.FileIndex, pkgResult.Manifest)
+	if err != nil {
+		o.storage.DeleteSnapshot(job.TenantID, job.SourceID, snapshotID)
+		return nil, client.SnapshotLocator{}, 0, fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	locator := o.snapshotLocator(localPath, pkgResult.ManifestObj)
+	if err := o.uploadSnapshot(ctx, job.TenantID, job.SourceID, snapshotID, &locator); err != nil {
+		o.storage.DeleteSnapshot(job.TenantID, job.SourceID, snapshotID)
+		return nil, client.SnapshotLocator{}, 0, err
+	}
+
+	return pkgResult, locator, sizeBytes, nil
+}
+
+// uploadSnapshot moves a committed snapshot to the object store, when one is
+// configured, and points its locator there
+func (o *Orchestrator) uploadSnapshot(ctx context.Context, tenantID, sourceID, snapshotID string, locator *client.SnapshotLocator) error {
+	store := o.storage.ObjectStore()
+	if store == nil {
+		return nil
+	}
+	objectKey, etag, err := o.storage.UploadSnapshot(ctx, tenantID, sourceID, snapshotID)
+	if err != nil {
+		return fmt.Errorf("failed to upload snapshot: %w", err)
+	}
+	locator.StorageBackend = string(types.StorageBackendS3)
+	locator.LocalPath = ""
+	locator.Bucket = store.Bucket()
+	locator.ObjectKey = objectKey
+	locator.ETag = etag
+	return nil
+}
+
+// packageToRepository stores the archive as deduplicated chunks and writes the
+// snapshot's sealed index. The reported size is what the snapshot newly stored.
+func (o *Orchestrator) packageToRepository(job *client.JobClaimResponse, pkg *packager.Packager, publicKey, sourceDir, snapshotID string) (*packager.PackageResult, string, int64, error) {
+	chunkKey, err := crypto.DeriveChunkKey(o.encryptionKEK, job.TenantID)
+	if err != nil {
+		return nil, "", 0, fmt.Errorf("failed to derive chunk key: %w", err)
+	}
+	repo := o.storage.Repository(job.TenantID, chunkKey, publicKey)
+
+	pkgResult, err := pkg.PackageToRepository(sourceDir, repo, snapshotID, job.TenantID, job.SourceID, job.JobID, o.workerID)
+	if err != nil {
+		return nil, "", 0, fmt.Errorf("failed to package backup: %w", err)
+	}
+
+	localPath, _, err := o.storage.WriteRepositorySnapshot(job.TenantID, job.SourceID, snapshotID, pkgResult.SealedIndex, pkgResult.ChunkIDs, pkgResult.FileIndex, pkgResult.Manifest)
+	if err != nil {
+		return nil, "", 0, fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	return pkgResult, localPath, pkgResult.ManifestObj.SizeBytes, nil
+}
+
+// snapshotLocator describes a snapshot written to this worker's local storage
+func (o *Orchestrator) snapshotLocator(localPath string, manifest types.SnapshotManifest) client.SnapshotLocator {
+	locator := client.SnapshotLocator{
+		StorageBackend: string(types.StorageBackendLocalFS),
+		WorkerID:       o.workerID,
+		LocalPath:      localPath,
+	}
+	for _, volume := range manifest.Volumes {
+		locator.Volumes = append(locator.Volumes, client.ArtifactVolume{
+			Name:      volume.Name,
+			SizeBytes: volume.SizeBytes,
+			SHA256:    volume.SHA256,
+		})
+	}
+	return locator
+}
+
+// addCompressionDetails adds the compression settings, ratio and packaging throughput
+// recorded in a snapshot manifest to job log details
+func addCompressionDetails(details map[string]any, manifestJSON json.RawMessage) {
+	var manifest types.SnapshotManifest
+	if err := json.Unmarshal(manifestJSON, &manifest); err != nil || manifest.Compression == nil {
+		return
+	}
+
+	details["compression_algorithm"] = manifest.Compression.Algorithm
+	details["compression_level"] = manifest.Compression.Level
+	details["compression_window_size"] = manifest.Compression.WindowSize
+	details["compression_threads"] = manifest.Compression.Threads
+
+	uncompressed := manifest.UncompressedSizeBytes
+	if uncompressed == 0 {
+		uncompressed = manifest.LogicalSizeBytes
+	}
+	details["uncompressed_bytes"] = uncompressed
+
+	if manifest.CompressedSizeBytes > 0 {
+		details["compressed_bytes"] = manifest.CompressedSizeBytes
+		details["compression_ratio"] = round2(float64(uncompressed) / float64(manifest.CompressedSizeBytes))
+	}
+	if manifest.DurationMs > 0 {
+		// Packaging throughput in MB/s of tar stream
+		details["throughput_mb_s"] = round2(float64(uncompressed) / 1e6 / (float64(manifest.DurationMs) / 1000))
+	}
+}
+
+// locatorDetails describes where a snapshot was written, for logs
+func locatorDetails(locator client.SnapshotLocator) (string, map[string]any) {
+	if locator.StorageBackend == string(types.StorageBackendS3) {
+		return fmt.Sprintf("s3://%s/%s", locator.Bucket, locator.ObjectKey), map[string]any{
+			"bucket":     locator.Bucket,
+			"object_key": locator.ObjectKey,
+			"etag":       locator.ETag,
+		}
+	}
+	return locator.LocalPath, map[string]any{"local_path": locator.LocalPath}
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
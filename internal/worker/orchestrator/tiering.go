@@ -0,0 +1,94 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"xvault/internal/worker/client"
+	"xvault/pkg/types"
+)
+
+// processMigrateSnapshotJob moves a snapshot from this worker's local storage to its
+// tier store. The copy is verified against the manifest before it is reported; the
+// local copy is removed by removeMigratedSnapshot once the hub has recorded the move,
+// so the snapshot stays readable if reporting fails.
+func (o *Orchestrator) processMigrateSnapshotJob(ctx context.Context, job *client.JobClaimResponse) (client.JobCompleteRequest, error) {
+	payload := job.Payload
+	if payload.MigrateSnapshotID == nil || *payload.MigrateSnapshotID == "" {
+		o.logToHub(ctx, "error", "migrate_snapshot_id is required in payload", &job.JobID, nil, nil, nil, nil)
+		return client.JobCompleteRequest{
+			WorkerID: o.workerID,
+			Status:   "failed",
+			Error:    "migrate_snapshot_id is required in payload",
+		}, fmt.Errorf("missing migrate_snapshot_id")
+	}
+	snapshotID := *payload.MigrateSnapshotID
+	start := time.Now()
+
+	failed := func(err error) (client.JobCompleteRequest, error) {
+		o.logToHub(ctx, "error", fmt.Sprintf("failed to migrate snapshot %s: %v", snapshotID, err), &job.JobID, &snapshotID, &job.SourceID, nil, nil)
+		return client.JobCompleteRequest{
+			WorkerID: o.workerID,
+			Status:   "failed",
+			Error:    fmt.Sprintf("failed to migrate snapshot: %v", err),
+		}, err
+	}
+
+	if payload.MigrateBackend != string(types.StorageBackendS3) {
+		return failed(fmt.Errorf("unsupported tier backend %q", payload.MigrateBackend))
+	}
+	if locator := payload.MigrateLocator; locator != nil && locator.StorageBackend != string(types.StorageBackendLocalFS) {
+		return failed(fmt.Errorf("snapshot is in %s, not local storage", locator.StorageBackend))
+	}
+	store := o.storage.TierStore()
+	if store == nil {
+		return failed(fmt.Errorf("no tier store is configured"))
+	}
+
+	log.Printf("worker %s migrating snapshot %s to s3://%s", o.workerID, snapshotID, store.Bucket())
+	o.logToHub(ctx, "info", fmt.Sprintf("migrating snapshot %s", snapshotID), &job.JobID, &snapshotID, &job.SourceID, nil, map[string]any{
+		"bucket": store.Bucket(),
+	})
+
+	manifest, objectKey, etag, err := o.storage.MigrateSnapshot(ctx, job.TenantID, job.SourceID, snapshotID)
+	if err != nil {
+		return failed(err)
+	}
+
+	locator := o.snapshotLocator("", *manifest)
+	locator.StorageBackend = string(types.StorageBackendS3)
+	locator.Bucket = store.Bucket()
+	locator.ObjectKey = objectKey
+	locator.ETag = etag
+
+	durationMs := time.Since(start).Milliseconds()
+	log.Printf("worker %s migrated snapshot %s (%d bytes)", o.workerID, snapshotID, manifest.SizeBytes)
+	o.logToHub(ctx, "info", fmt.Sprintf("snapshot %s migrated", snapshotID), &job.JobID, &snapshotID, &job.SourceID, nil, map[string]any{
+		"bucket":      locator.Bucket,
+		"object_key":  locator.ObjectKey,
+		"duration_ms": durationMs,
+	})
+
+	return client.JobCompleteRequest{
+		WorkerID: o.workerID,
+		Status:   "completed",
+		Migration: &client.SnapshotMigration{
+			SnapshotID: snapshotID,
+			Locator:    locator,
+		},
+	}, nil
+}
+
+// removeMigratedSnapshot removes the local copy of a snapshot the hub now locates in
+// the tier store. A copy that cannot be removed shows up as an orphan in storage
+// reconciliation.
+func (o *Orchestrator) removeMigratedSnapshot(ctx context.Context, job *client.JobClaimResponse) {
+	snapshotID := *job.Payload.MigrateSnapshotID
+	if err := o.storage.DeleteSnapshot(job.TenantID, job.SourceID, snapshotID); err != nil {
+		o.logToHub(ctx, "warn", fmt.Sprintf("failed to remove local copy of migrated snapshot %s: %v", snapshotID, err), &job.JobID, &snapshotID, &job.SourceID, nil, nil)
+		return
+	}
+	log.Printf("worker %s removed local copy of migrated snapshot %s", o.workerID, snapshotID)
+}
@@ -0,0 +1,130 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"xvault/internal/worker/client"
+	"xvault/internal/worker/storage"
+	"xvault/pkg/crypto"
+	"xvault/pkg/snapshot"
+	"xvault/pkg/types"
+)
+
+// processVerifySnapshotJob reads a stored snapshot back and checks it against its
+// manifest, and the manifest against the signature the hub recorded. A snapshot whose
+// data does not match (snapshot.IsCorrupt) is reported as corrupt and the job fails; a
+// job that could not run the check (unreachable storage, no tenant key, cancelled)
+// fails without a verdict.
+func (o *Orchestrator) processVerifySnapshotJob(ctx context.Context, job *client.JobClaimResponse) (client.JobCompleteRequest, error) {
+	if job.Payload.VerifySnapshotID == nil || *job.Payload.VerifySnapshotID == "" {
+		o.logToHub(ctx, "error", "verify_snapshot_id is required in payload", &job.JobID, nil, nil, nil, nil)
+		return client.JobCompleteRequest{
+			WorkerID: o.workerID,
+			Status:   "failed",
+			Error:    "verify_snapshot_id is required in payload",
+		}, fmt.Errorf("missing verify_snapshot_id")
+	}
+	snapshotID := *job.Payload.VerifySnapshotID
+	start := time.Now()
+
+	log.Printf("worker %s verifying snapshot %s", o.workerID, snapshotID)
+	o.logToHub(ctx, "info", fmt.Sprintf("verifying snapshot %s", snapshotID), &job.JobID, &snapshotID, &job.SourceID, nil, map[string]any{
+		"test_decrypt": job.Payload.VerifyDecrypt,
+	})
+
+	verification := &client.SnapshotVerification{SnapshotID: snapshotID}
+	corrupt := func(err error) (client.JobCompleteRequest, error) {
+		verification.Status = string(types.SnapshotIntegrityCorrupt)
+		verification.Error = err.Error()
+		verification.DurationMs = time.Since(start).Milliseconds()
+		o.logToHub(ctx, "error", fmt.Sprintf("snapshot %s failed verification: %v", snapshotID, err), &job.JobID, &snapshotID, &job.SourceID, nil, nil)
+		return client.JobCompleteRequest{
+			WorkerID:     o.workerID,
+			Status:       "failed",
+			Error:        fmt.Sprintf("snapshot failed verification: %v", err),
+			Verification: verification,
+		}, err
+	}
+	failed := func(err error) (client.JobCompleteRequest, error) {
+		o.logToHub(ctx, "error", err.Error(), &job.JobID, &snapshotID, &job.SourceID, nil, nil)
+		return client.JobCompleteRequest{
+			WorkerID: o.workerID,
+			Status:   "failed",
+			Error:    err.Error(),
+		}, err
+	}
+
+	// Read the snapshot from the backend its locator names; without a locator it is
+	// in this worker's storage
+	loc := o.storage.Location(job.TenantID, job.SourceID, snapshotID)
+	if locator := job.Payload.VerifyLocator; locator != nil {
+		if locator.StorageBackend == string(types.StorageBackendS3) {
+			var err error
+			if loc, err = o.storage.ObjectLocation(locator.Bucket, locator.ObjectKey); err != nil {
+				return failed(err)
+			}
+		} else {
+			loc = o.storage.LocalLocation(job.TenantID, job.SourceID, snapshotID)
+		}
+	}
+
+	manifest, manifestBytes, err := snapshot.ReadManifest(ctx, loc)
+	if err != nil {
+		if snapshot.IsCorrupt(err) {
+			return corrupt(err)
+		}
+		return failed(err)
+	}
+	if signature, key := job.Payload.VerifyManifestSignature, job.Payload.VerifyManifestSigningKey; signature != "" && key != "" {
+		if err := crypto.VerifyManifest(manifestBytes, signature, key); err != nil {
+			err = fmt.Errorf("manifest failed signature verification: %w", err)
+			if snapshot.IsCorrupt(err) {
+				return corrupt(err)
+			}
+			return failed(err)
+		}
+	}
+
+	var privateKey string
+	if job.Payload.VerifyDecrypt || manifest.StorageMode == types.StorageModeRepository {
+		keyResp, err := o.hubClient.GetTenantPrivateKey(ctx, job.TenantID, snapshot.DecryptionKeyID(manifest))
+		if err != nil {
+			return failed(fmt.Errorf("failed to get tenant private key: %w", err))
+		}
+		privateKey = keyResp.PrivateKey
+	}
+
+	result, err := storage.VerifySnapshot(ctx, loc, manifest, privateKey)
+	if err != nil {
+		if ctx.Err() != nil {
+			return failed(fmt.Errorf("verification interrupted: %w", err))
+		}
+		if snapshot.IsCorrupt(err) {
+			return corrupt(err)
+		}
+		return failed(fmt.Errorf("failed to read snapshot: %w", err))
+	}
+
+	verification.Status = string(types.SnapshotIntegrityVerified)
+	verification.Decrypted = result.Decrypted
+	verification.BytesRead = result.BytesRead
+	verification.FileCount = result.FileCount
+	verification.DurationMs = time.Since(start).Milliseconds()
+
+	log.Printf("worker %s verified snapshot %s (%d bytes)", o.workerID, snapshotID, result.BytesRead)
+	o.logToHub(ctx, "info", fmt.Sprintf("snapshot %s verified", snapshotID), &job.JobID, &snapshotID, &job.SourceID, nil, map[string]any{
+		"decrypted":   result.Decrypted,
+		"bytes_read":  result.BytesRead,
+		"file_count":  result.FileCount,
+		"duration_ms": verification.DurationMs,
+	})
+
+	return client.JobCompleteRequest{
+		WorkerID:     o.workerID,
+		Status:       "completed",
+		Verification: verification,
+	}, nil
+}
@@ -0,0 +1,197 @@
+package packager
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"xvault/pkg/types"
+)
+
+// FrameSize is how much of the tar stream goes into each independently compressed
+// zstd frame of an artifact. Restoring a single file decompresses at most the frames
+// that overlap it. Frames grow to the window size when a larger window is configured.
+const FrameSize = 4 << 20
+
+// frameMemory bounds the tar stream held in frames waiting to be compressed together.
+// Large windows make large frames, so they are compressed on fewer threads.
+const frameMemory = 256 << 20
+
+// frameSize returns the size of the frames written with the given settings
+func frameSize(cfg types.CompressionConfig) int {
+	return max(FrameSize, cfg.WindowSize)
+}
+
+// frameThreads returns how many frames of the given settings are compressed at once
+// within frameMemory, never more than configured and at least one
+func frameThreads(cfg types.CompressionConfig) int {
+	return max(1, min(cfg.Threads, frameMemory/frameSize(cfg)))
+}
+
+// Seek table in the zstd seekable format: a skippable frame holding one entry per
+// frame, which standard zstd decoders pass over
+const (
+	skippableFrameMagic = 0x184D2A5E
+	seekableMagic       = 0x8F92EAB1
+)
+
+// frameWriter cuts everything written to it into frames of frameSize input bytes and
+// compresses each one independently, up to threads frames at a time. For zstd the
+// output ends with a seek table on Close and is still a valid zstd stream for
+// sequential decoders; with compression "none" frames are stored as they are.
+type frameWriter struct {
+	w         io.Writer
+	encoder   *zstd.Encoder // nil when storing uncompressed
+	frameSize int
+	threads   int
+
+	buf     []byte
+	pending [][]byte // full frames waiting to be compressed
+	frames  []types.ArchiveFrame
+	offset  int64 // input bytes consumed
+	written int64 // output bytes emitted
+}
+
+// newFrameWriter creates a frame writer with the given effective settings (see
+// effectiveCompression) that writes its output to w
+func newFrameWriter(w io.Writer, cfg types.CompressionConfig) (*frameWriter, error) {
+	f := &frameWriter{
+		w:         w,
+		frameSize: frameSize(cfg),
+		threads:   frameThreads(cfg),
+	}
+	f.buf = make([]byte, 0, f.frameSize)
+
+	if cfg.Algorithm == types.CompressionNone {
+		return f, nil
+	}
+
+	opts := []zstd.EOption{
+		zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(cfg.Level)),
+		zstd.WithEncoderConcurrency(f.threads),
+	}
+	if cfg.WindowSize > 0 {
+		opts = append(opts, zstd.WithWindowSize(cfg.WindowSize))
+	}
+	encoder, err := zstd.NewWriter(nil, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create zstd encoder: %w", err)
+	}
+	f.encoder = encoder
+	return f, nil
+}
+
+func (f *frameWriter) Write(p []byte) (int, error) {
+	n := 0
+	for len(p) > 0 {
+		take := min(f.frameSize-len(f.buf), len(p))
+		f.buf = append(f.buf, p[:take]...)
+		p = p[take:]
+		n += take
+
+		if len(f.buf) == f.frameSize {
+			f.pending = append(f.pending, f.buf)
+			f.buf = make([]byte, 0, f.frameSize)
+			if len(f.pending) == f.threads {
+				if err := f.flushPending(); err != nil {
+					return n, err
+				}
+			}
+		}
+	}
+	return n, nil
+}
+
+// flushPending compresses the pending frames in parallel and writes them in order
+func (f *frameWriter) flushPending() error {
+	if len(f.pending) == 0 {
+		return nil
+	}
+
+	out := f.pending
+	if f.encoder != nil {
+		out = make([][]byte, len(f.pending))
+		var wg sync.WaitGroup
+		for i, frame := range f.pending {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				out[i] = f.encoder.EncodeAll(frame, nil)
+			}()
+		}
+		wg.Wait()
+	}
+
+	for i, data := range out {
+		if _, err := f.w.Write(data); err != nil {
+			return err
+		}
+		size := int64(len(f.pending[i]))
+		f.frames = append(f.frames, types.ArchiveFrame{
+			Offset:           f.offset,
+			Size:             size,
+			CompressedOffset: f.written,
+			CompressedSize:   int64(len(data)),
+		})
+		f.offset += size
+		f.written += int64(len(data))
+	}
+
+	f.pending = f.pending[:0]
+	return nil
+}
+
+// Close flushes the remaining frames and, for zstd, appends the seek table
+func (f *frameWriter) Close() error {
+	if f.encoder != nil {
+		defer f.encoder.Close()
+	}
+
+	if len(f.buf) > 0 {
+		f.pending = append(f.pending, f.buf)
+		f.buf = nil
+	}
+	if err := f.flushPending(); err != nil {
+		return err
+	}
+
+	if f.encoder == nil {
+		return nil
+	}
+	_, err := f.w.Write(seekTable(f.frames))
+	return err
+}
+
+// abort releases the encoder after a failed write
+func (f *frameWriter) abort() {
+	if f.encoder != nil {
+		f.encoder.Close()
+	}
+}
+
+// Frames returns the frames written so far
+func (f *frameWriter) Frames() []types.ArchiveFrame {
+	return f.frames
+}
+
+// seekTable encodes frames as a zstd seekable format seek table, without checksums
+func seekTable(frames []types.ArchiveFrame) []byte {
+	const entrySize, footerSize = 8, 9
+	contentSize := len(frames)*entrySize + footerSize
+
+	b := make([]byte, 8, 8+contentSize)
+	binary.LittleEndian.PutUint32(b[0:4], skippableFrameMagic)
+	binary.LittleEndian.PutUint32(b[4:8], uint32(contentSize))
+
+	for _, frame := range frames {
+		b = binary.LittleEndian.AppendUint32(b, uint32(frame.CompressedSize))
+		b = binary.LittleEndian.AppendUint32(b, uint32(frame.Size))
+	}
+
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(frames)))
+	b = append(b, 0) // descriptor: no checksums
+	b = binary.LittleEndian.AppendUint32(b, seekableMagic)
+	return b
+}
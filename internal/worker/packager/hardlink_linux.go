@@ -0,0 +1,21 @@
+package packager
+
+import (
+	"os"
+	"syscall"
+)
+
+// inodeKey identifies a file on the local filesystem
+type inodeKey struct {
+	dev uint64
+	ino uint64
+}
+
+// hardlinkKey returns the inode key of a file that has more than one link
+func hardlinkKey(info os.FileInfo) (inodeKey, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || st.Nlink < 2 {
+		return inodeKey{}, false
+	}
+	return inodeKey{dev: uint64(st.Dev), ino: st.Ino}, true
+}
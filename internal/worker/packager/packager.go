@@ -1,6 +1,7 @@
 package packager
 
 import (
+	"archive/tar"
 	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
@@ -9,151 +10,450 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"time"
-	"strconv"
 
 	"github.com/klauspost/compress/zstd"
+	"xvault/internal/worker/storage"
 	"xvault/pkg/crypto"
+	"xvault/pkg/snapshot"
 	"xvault/pkg/types"
 )
 
 // Packager handles backup packaging, compression, and encryption
 type Packager struct {
 	tenantPublicKey string
+	encryptionKeyID string
+	owners          map[string]types.FileOwner
+	incremental     *types.IncrementalSummary
+	deleted         []string
+	skipped         *types.SkippedSummary
+	roots           []types.SourceRoot
+	compression     types.CompressionConfig
+	signingKey      string
 }
 
 // NewPackager creates a new packager for a tenant
 func NewPackager(tenantPublicKey string) *Packager {
 	return &Packager{
 		tenantPublicKey: tenantPublicKey,
+		encryptionKeyID: tenantPublicKey[:16],
+		compression:     effectiveCompression(nil),
 	}
 }
 
-// PackageBackup creates an encrypted backup artifact from a source directory
-func (p *Packager) PackageBackup(sourceDir, snapshotID, tenantID, sourceID, jobID, workerID string) (*PackageResult, error) {
+// SetEncryptionKeyID sets the ID of the tenant key row tenantPublicKey belongs to,
+// recorded in the manifest so restores ask for the matching private key. Without one
+// the manifest names the key by the first 16 characters of its public key, as hubs
+// that predate key rotation expect.
+func (p *Packager) SetEncryptionKeyID(keyID string) {
+	if keyID != "" {
+		p.encryptionKeyID = keyID
+	}
+}
+
+// SetCompression applies a source's compression settings; nil keeps the defaults
+func (p *Packager) SetCompression(cfg *types.CompressionConfig) {
+	p.compression = effectiveCompression(cfg)
+}
+
+// effectiveCompression fills in the defaults for unset compression settings
+func effectiveCompression(cfg *types.CompressionConfig) types.CompressionConfig {
+	var c types.CompressionConfig
+	if cfg != nil {
+		c = *cfg
+	}
+	if c.Algorithm == "" {
+		c.Algorithm = types.CompressionZstd
+	}
+	if c.Threads <= 0 {
+		c.Threads = runtime.GOMAXPROCS(0)
+	}
+	if c.Algorithm == types.CompressionNone {
+		c.Level = 0
+		c.WindowSize = 0
+	} else if c.Level == 0 {
+		c.Level = 3
+	}
+	c.Threads = frameThreads(c)
+	return c
+}
+
+// SetSigningKey sets the worker identity key that signs snapshot manifests; without
+// one manifests are left unsigned
+func (p *Packager) SetSigningKey(privateKey string) {
+	p.signingKey = privateKey
+}
+
+// signManifest signs manifestJSON with the worker identity key, if any
+func (p *Packager) signManifest(manifestJSON []byte) (string, error) {
+	if p.signingKey == "" {
+		return "", nil
+	}
+	signature, err := crypto.SignManifest(manifestJSON, p.signingKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign manifest: %w", err)
+	}
+	return signature, nil
+}
+
+// SetOwners sets source-side ownership for entries in the directory being packaged,
+// keyed by slash-separated path relative to the source directory. Entries without an
+// owner keep the ownership of the local file.
+func (p *Packager) SetOwners(owners map[string]types.FileOwner) {
+	p.owners = owners
+}
+
+// SetIncremental marks the snapshot as an incremental backup. The directory being
+// packaged is still the complete tree; summary and the paths deleted since the base
+// snapshot are recorded in the manifest and file index.
+func (p *Packager) SetIncremental(summary types.IncrementalSummary, deleted []string) {
+	p.incremental = &summary
+	p.deleted = deleted
+}
+
+// SetSkipped records in the manifest's content summary what the source's filter
+// left out
+func (p *Packager) SetSkipped(summary types.SkippedSummary) {
+	if summary.Total() > 0 {
+		p.skipped = &summary
+	}
+}
+
+// SetRoots records where each configured path of a file source is stored in the
+// archive, so a restore can put the trees back where they came from
+func (p *Packager) SetRoots(roots []types.SourceRoot) {
+	p.roots = roots
+}
+
+// PackageBackup streams an encrypted backup artifact of sourceDir to artifact, which
+// may split it into volumes. Data flows tar -> zstd -> age -> (file + sha256) without
+// buffering the archive in memory; sizes and the hash are measured as the bytes pass
+// through each stage. The artifact is closed on success and removed on failure.
+func (p *Packager) PackageBackup(sourceDir string, artifact *storage.ArtifactWriter, snapshotID, tenantID, sourceID, jobID, workerID string) (*PackageResult, error) {
 	startTime := time.Now()
 
-	// Create a buffer for the tar archive
-	var tarBuf bytes.Buffer
+	counts, entries, frames, sha256Hash, err := p.writeArtifact(sourceDir, artifact)
+	if err != nil {
+		artifact.Abort()
+		return nil, err
+	}
+
+	if err := artifact.Close(); err != nil {
+		artifact.Abort()
+		return nil, err
+	}
+
+	// The file index doubles as the content listing, so no separate walk is needed
+	fileCount, _ := types.CountFiles(entries)
 
-	// Calculate total size and count files
-	fileCount, _, err := p.walkSourceDir(sourceDir)
+	finishTime := time.Now()
+	durationMs := finishTime.Sub(startTime).Milliseconds()
+
+	// Create manifest
+	manifest := types.SnapshotManifest{
+		TenantID:              tenantID,
+		SourceID:              sourceID,
+		SnapshotID:            snapshotID,
+		JobID:                 jobID,
+		WorkerID:              workerID,
+		StartedAt:             startTime.Format(time.RFC3339),
+		FinishedAt:            finishTime.Format(time.RFC3339),
+		DurationMs:            durationMs,
+		SizeBytes:             counts.encrypted,
+		SHA256:                sha256Hash,
+		EncryptionAlgorithm:   "age-x25519",
+		EncryptionKeyID:       p.encryptionKeyID,
+		EncryptionRecipient:   p.tenantPublicKey,
+		StorageMode:           types.StorageModeArtifact,
+		FrameSize:             int64(frameSize(p.compression)),
+		FrameCount:            len(frames),
+		VolumeSize:            artifact.VolumeSize(),
+		Volumes:               artifact.Volumes(),
+		Compression:           &p.compression,
+		UncompressedSizeBytes: counts.uncompressed,
+		CompressedSizeBytes:   counts.compressed,
+		ContentSummary: types.ContentSummary{
+			Type:      "files",
+			FileCount: fileCount,
+			Roots:     p.roots,
+			Skipped:   p.skipped,
+		},
+	}
+	p.setBackupMode(&manifest)
+
+	manifestJSON, err := snapshot.EncodeManifest(&manifest)
 	if err != nil {
-		return nil, fmt.Errorf("failed to walk source directory: %w", err)
+		artifact.Abort()
+		return nil, err
 	}
 
-	// Create tar archive
-	tarSize, err := p.createTarArchive(sourceDir, &tarBuf)
+	signature, err := p.signManifest(manifestJSON)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create tar archive: %w", err)
+		artifact.Abort()
+		return nil, err
 	}
 
-	// Compress with zstd
-	compressed, err := p.compressZstd(tarBuf.Bytes())
+	fileIndex, err := p.sealFileIndex(snapshotID, entries, frames)
 	if err != nil {
-		return nil, fmt.Errorf("failed to compress: %w", err)
+		artifact.Abort()
+		return nil, err
 	}
 
-	// Encrypt with Age
-	encrypted, err := crypto.EncryptToPublicKey(compressed, p.tenantPublicKey)
+	return &PackageResult{
+		FileIndex:         fileIndex,
+		Manifest:          manifestJSON,
+		ManifestSignature: signature,
+		ManifestObj:       manifest,
+		UncompressedSize:  counts.uncompressed,
+		CompressedSize:    counts.compressed,
+		EncryptedSize:     counts.encrypted,
+		SHA256:            sha256Hash,
+	}, nil
+}
+
+// PackageToRepository streams a tar archive of sourceDir into a tenant chunk repository.
+// Only chunks the repository does not already hold are written; the returned result
+// carries the sealed chunk index instead of an artifact path.
+func (p *Packager) PackageToRepository(sourceDir string, repo *storage.Repository, snapshotID, tenantID, sourceID, jobID, workerID string) (*PackageResult, error) {
+	startTime := time.Now()
+
+	// Chunks are always zstd-compressed; "none" only selects the fastest level
+	compression := types.CompressionConfig{Algorithm: types.CompressionZstd, Level: p.compression.Level}
+	if p.compression.Algorithm == types.CompressionNone {
+		compression.Level = 1
+	}
+	repo.SetCompressionLevel(compression.Level)
+
+	// The tar writer runs in its own goroutine and feeds the chunker through a pipe
+	var entries []types.FileIndexEntry
+	pr, pw := io.Pipe()
+	go func() {
+		var err error
+		entries, _, err = p.createTarArchive(sourceDir, pw)
+		pw.CloseWithError(err)
+	}()
+
+	index, stats, err := repo.StoreStream(pr)
+	pr.Close()
 	if err != nil {
-		return nil, fmt.Errorf("failed to encrypt: %w", err)
+		return nil, fmt.Errorf("failed to store archive in repository: %w", err)
 	}
 
-	// Calculate SHA256 of encrypted artifact
-	hash := sha256.Sum256(encrypted)
+	sealedIndex, err := repo.SealIndex(index)
+	if err != nil {
+		repo.Release(index.UniqueIDs())
+		return nil, err
+	}
+
+	hash := sha256.Sum256(sealedIndex)
 	sha256Hash := hex.EncodeToString(hash[:])
+	fileCount, _ := types.CountFiles(entries)
 
 	finishTime := time.Now()
-	durationMs := finishTime.Sub(startTime).Milliseconds()
 
-	// Create manifest
 	manifest := types.SnapshotManifest{
-		TenantID:   tenantID,
-		SourceID:   sourceID,
-		SnapshotID: snapshotID,
-		JobID:      jobID,
-		WorkerID:   workerID,
-		StartedAt:  startTime.Format(time.RFC3339),
-		FinishedAt: finishTime.Format(time.RFC3339),
-		DurationMs: durationMs,
-		SizeBytes:  int64(len(encrypted)),
-		SHA256:     sha256Hash,
+		TenantID:            tenantID,
+		SourceID:            sourceID,
+		SnapshotID:          snapshotID,
+		JobID:               jobID,
+		WorkerID:            workerID,
+		StartedAt:           startTime.Format(time.RFC3339),
+		FinishedAt:          finishTime.Format(time.RFC3339),
+		DurationMs:          finishTime.Sub(startTime).Milliseconds(),
+		SizeBytes:           stats.NewBytes + int64(len(sealedIndex)),
+		SHA256:              sha256Hash,
 		EncryptionAlgorithm: "age-x25519",
-		EncryptionKeyID:     p.tenantPublicKey[:16], // First 16 chars of public key as ID
+		EncryptionKeyID:     p.encryptionKeyID,
 		EncryptionRecipient: p.tenantPublicKey,
+		StorageMode:         types.StorageModeRepository,
+		LogicalSizeBytes:    stats.LogicalBytes,
+		NewBytes:            stats.NewBytes,
+		ChunkCount:          stats.ChunkCount,
+		NewChunkCount:       stats.NewChunks,
+		Compression:         &compression,
 		ContentSummary: types.ContentSummary{
 			Type:      "files",
 			FileCount: fileCount,
+			Roots:     p.roots,
+			Skipped:   p.skipped,
 		},
 	}
+	p.setBackupMode(&manifest)
+
+	manifestJSON, err := snapshot.EncodeManifest(&manifest)
+	if err != nil {
+		repo.Release(index.UniqueIDs())
+		return nil, err
+	}
 
-	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	signature, err := p.signManifest(manifestJSON)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal manifest: %w", err)
+		repo.Release(index.UniqueIDs())
+		return nil, err
+	}
+
+	fileIndex, err := p.sealFileIndex(snapshotID, entries, nil)
+	if err != nil {
+		repo.Release(index.UniqueIDs())
+		return nil, err
 	}
 
 	return &PackageResult{
-		Artifact:         encrypted,
-		Manifest:         manifestJSON,
-		ManifestObj:      manifest,
-		UncompressedSize: tarSize,
-		CompressedSize:   int64(len(compressed)),
-		EncryptedSize:    int64(len(encrypted)),
-		SHA256:           sha256Hash,
+		SealedIndex:       sealedIndex,
+		FileIndex:         fileIndex,
+		ChunkIDs:          index.UniqueIDs(),
+		Manifest:          manifestJSON,
+		ManifestSignature: signature,
+		ManifestObj:       manifest,
+		UncompressedSize:  stats.LogicalBytes,
+		EncryptedSize:     stats.NewBytes,
+		SHA256:            sha256Hash,
 	}, nil
 }
 
-// walkSourceDir walks the source directory and counts files/bytes
-func (p *Packager) walkSourceDir(sourceDir string) (fileCount int, totalBytes int64, err error) {
-	err = filepath.Walk(sourceDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if !info.IsDir() {
-			fileCount++
-			totalBytes += info.Size()
-		}
-		return nil
-	})
-	return fileCount, totalBytes, err
+// stageCounts holds the number of bytes that passed through each pipeline stage
+type stageCounts struct {
+	uncompressed int64
+	compressed   int64
+	encrypted    int64
 }
 
-// createTarArchive creates a tar archive of the source directory
-func (p *Packager) createTarArchive(sourceDir string, w io.Writer) (int64, error) {
-	// Use a simple tar implementation
-	// For production, consider using archive/tar for better cross-platform support
-	return createSimpleTar(sourceDir, w)
+// setBackupMode records whether the manifest describes a full or incremental backup
+func (p *Packager) setBackupMode(manifest *types.SnapshotManifest) {
+	manifest.BackupMode = types.BackupModeFull
+	if p.incremental != nil {
+		manifest.BackupMode = types.BackupModeIncremental
+		manifest.Incremental = p.incremental
+	}
 }
 
-// compressZstd compresses data with zstandard
-func (p *Packager) compressZstd(data []byte) ([]byte, error) {
-	encoder, err := zstd.NewWriter(nil)
+// sealFileIndex serializes the archived entries and the artifact's compression frames
+// as a file index, compressed with zstd and encrypted to the tenant key
+func (p *Packager) sealFileIndex(snapshotID string, entries []types.FileIndexEntry, frames []types.ArchiveFrame) ([]byte, error) {
+	index := types.FileIndex{
+		Version:    types.FileIndexVersion,
+		SnapshotID: snapshotID,
+		Entries:    entries,
+		Deleted:    p.deleted,
+		Frames:     frames,
+	}
+	indexJSON, err := json.Marshal(index)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal file index: %w", err)
+	}
+
+	var buf bytes.Buffer
+	ageWriter, err := crypto.NewEncryptWriter(&buf, p.tenantPublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt file index: %w", err)
+	}
+	encoder, err := zstd.NewWriter(ageWriter)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create zstd encoder: %w", err)
 	}
-	defer encoder.Close()
+	if _, err := encoder.Write(indexJSON); err != nil {
+		encoder.Close()
+		return nil, fmt.Errorf("failed to compress file index: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress file index: %w", err)
+	}
+	if err := ageWriter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encrypt file index: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// writeArtifact runs the tar -> compressed frames -> age pipeline into dst and returns the
+// per-stage byte counts, the archived entries, the compression frames and the hex
+// SHA-256 of the encrypted stream
+func (p *Packager) writeArtifact(sourceDir string, dst io.Writer) (stageCounts, []types.FileIndexEntry, []types.ArchiveFrame, string, error) {
+	var counts stageCounts
+
+	// Encrypted bytes go to the file and the hasher at the same time
+	hasher := sha256.New()
+	encCounter := &countingWriter{w: io.MultiWriter(dst, hasher)}
+
+	ageWriter, err := crypto.NewEncryptWriter(encCounter, p.tenantPublicKey)
+	if err != nil {
+		return counts, nil, nil, "", fmt.Errorf("failed to encrypt: %w", err)
+	}
+
+	compCounter := &countingWriter{w: ageWriter}
+	frames, err := newFrameWriter(compCounter, p.compression)
+	if err != nil {
+		return counts, nil, nil, "", err
+	}
+
+	tarCounter := &countingWriter{w: frames}
+	entries, _, err := p.createTarArchive(sourceDir, tarCounter)
+	if err != nil {
+		frames.abort()
+		return counts, nil, nil, "", fmt.Errorf("failed to create tar archive: %w", err)
+	}
+
+	// Close in pipeline order so each stage flushes into the next
+	if err := frames.Close(); err != nil {
+		return counts, nil, nil, "", fmt.Errorf("failed to compress: %w", err)
+	}
+	if err := ageWriter.Close(); err != nil {
+		return counts, nil, nil, "", fmt.Errorf("failed to encrypt: %w", err)
+	}
+
+	counts.uncompressed = tarCounter.n
+	counts.compressed = compCounter.n
+	counts.encrypted = encCounter.n
+
+	return counts, entries, frames.Frames(), hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
 
-	compressed := encoder.EncodeAll(data, nil)
-	return compressed, nil
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	return n, err
+}
+
+// createTarArchive creates a tar archive of the source directory
+func (p *Packager) createTarArchive(sourceDir string, w io.Writer) ([]types.FileIndexEntry, int64, error) {
+	return createTar(sourceDir, w, p.owners)
 }
 
 // PackageResult contains the result of packaging a backup
 type PackageResult struct {
-	Artifact         []byte
-	Manifest         []byte
-	ManifestObj      types.SnapshotManifest
-	UncompressedSize int64
-	CompressedSize   int64
-	EncryptedSize    int64
-	SHA256           string
+	FileIndex         []byte   // sealed types.FileIndex
+	SealedIndex       []byte   // repository mode only
+	ChunkIDs          []string // repository mode only
+	Manifest          []byte
+	ManifestSignature string // empty when the packager has no signing key
+	ManifestObj       types.SnapshotManifest
+	UncompressedSize  int64
+	CompressedSize    int64
+	EncryptedSize     int64
+	SHA256            string
 }
 
-// createSimpleTar creates a simple tar archive
-// For v0, this is a simplified implementation. For production, use archive/tar.
-func createSimpleTar(sourceDir string, w io.Writer) (int64, error) {
+// createTar writes a tar archive of sourceDir to w using archive/tar.
+// Directories (including empty ones), symlinks, hardlinks, mode, mtime and ownership
+// are recorded; long names are emitted as PAX records automatically. Returns an index
+// entry per archived path and the number of regular file content bytes archived.
+func createTar(sourceDir string, w io.Writer, owners map[string]types.FileOwner) ([]types.FileIndexEntry, int64, error) {
 	sourceDir = filepath.Clean(sourceDir)
+	// Entry offsets in the tar stream are recorded in the file index for random access
+	counter := &countingWriter{w: w}
+	tw := tar.NewWriter(counter)
 
+	// First archived path for each inode with more than one link
+	seenInodes := make(map[inodeKey]string)
+
+	var entries []types.FileIndexEntry
 	var totalSize int64
 	err := filepath.Walk(sourceDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -169,118 +469,134 @@ func createSimpleTar(sourceDir string, w io.Writer) (int64, error) {
 		if err != nil {
 			return fmt.Errorf("failed to get relative path: %w", err)
 		}
+		name := filepath.ToSlash(relPath)
 
-		if info.IsDir() {
-			// For directories, we could create directory entries
-			// For simplicity, we'll rely on file paths to imply structure
+		// Sockets have no archive representation
+		if info.Mode()&os.ModeSocket != 0 {
 			return nil
 		}
 
-		// Read file
-		data, err := os.ReadFile(path)
+		var linkTarget string
+		if info.Mode()&os.ModeSymlink != 0 {
+			linkTarget, err = os.Readlink(path)
+			if err != nil {
+				return fmt.Errorf("failed to read symlink %s: %w", path, err)
+			}
+		}
+
+		header, err := tar.FileInfoHeader(info, linkTarget)
 		if err != nil {
-			return fmt.Errorf("failed to read file %s: %w", path, err)
+			return fmt.Errorf("failed to build header for %s: %w", path, err)
+		}
+		header.Name = name
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		// atime/ctime change on every read and would make archives non-reproducible
+		header.AccessTime = time.Time{}
+		header.ChangeTime = time.Time{}
+
+		if owner, ok := owners[name]; ok {
+			header.Uid = owner.UID
+			header.Gid = owner.GID
+			header.Uname = owner.Uname
+			header.Gname = owner.Gname
 		}
 
-		// Write a simple tar header (ustar format)
-		header := makeTarHeader(relPath, info.Size(), info.Mode())
-		if _, err := w.Write(header); err != nil {
-			return fmt.Errorf("failed to write header: %w", err)
+		if info.Mode().IsRegular() {
+			if key, ok := hardlinkKey(info); ok {
+				if first, seen := seenInodes[key]; seen {
+					header.Typeflag = tar.TypeLink
+					header.Linkname = first
+					header.Size = 0
+				} else {
+					seenInodes[key] = name
+				}
+			}
 		}
 
-		// Write file data
-		if _, err := w.Write(data); err != nil {
-			return fmt.Errorf("failed to write file data: %w", err)
+		// Pad out the previous entry so the count is where this entry's header begins
+		if err := tw.Flush(); err != nil {
+			return fmt.Errorf("failed to write tar padding: %w", err)
 		}
+		offset := counter.n
 
-		// Write padding to 512-byte boundary
-		padding := (512 - (info.Size() % 512)) % 512
-		if padding > 0 {
-			if _, err := w.Write(make([]byte, padding)); err != nil {
-				return fmt.Errorf("failed to write padding: %w", err)
-			}
+		if err := tw.WriteHeader(header); err != nil {
+			return fmt.Errorf("failed to write header for %s: %w", name, err)
+		}
+
+		entry := indexEntry(name, header)
+		entry.ArchiveOffset = offset
+		if header.Typeflag != tar.TypeReg {
+			entries = append(entries, entry)
+			return nil
 		}
 
-		totalSize += info.Size()
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open file %s: %w", path, err)
+		}
+		defer file.Close()
+
+		// Stream file data, hashing it for the file index on the way
+		hasher := sha256.New()
+		if _, err := io.CopyN(io.MultiWriter(tw, hasher), file, header.Size); err != nil {
+			return fmt.Errorf("failed to write file data: %w", err)
+		}
+		entry.SHA256 = hex.EncodeToString(hasher.Sum(nil))
+		entries = append(entries, entry)
+
+		totalSize += header.Size
 		return nil
 	})
 
 	if err != nil {
-		return 0, err
+		return nil, 0, err
 	}
 
-	// Write two 512-byte zero blocks to end the archive
-	endBlocks := make([]byte, 1024)
-	if _, err := w.Write(endBlocks); err != nil {
-		return 0, fmt.Errorf("failed to write end blocks: %w", err)
+	if err := tw.Flush(); err != nil {
+		return nil, 0, fmt.Errorf("failed to write tar padding: %w", err)
 	}
-
-	return totalSize, nil
-}
-
-// makeTarHeader creates a simple tar header
-func makeTarHeader(name string, size int64, mode os.FileMode) []byte {
-	header := make([]byte, 512)
-
-	// Name (100 bytes)
-	copy(header[0:100], name)
-	// Mode (8 bytes) - octal
-	modeStr := fmt.Sprintf("%06o ", mode&0777)
-	copy(header[100:108], modeStr)
-	// UID (8 bytes)
-	copy(header[108:116], "0000000 ")
-	// GID (8 bytes)
-	copy(header[116:124], "0000000 ")
-	// Size (12 bytes) - octal
-	sizeStr := fmt.Sprintf("%011o ", size)
-	copy(header[124:136], sizeStr)
-	// Mtime (12 bytes) - octal (use 0 for simplicity)
-	copy(header[136:148], "00000000000 ")
-	// Type flag (1 byte) - regular file
-	header[156] = 0x30 // '0'
-	// Magic (6 bytes) + version (2 bytes)
-	copy(header[257:263], "ustar")
-	header[263] = 0x30 // '0'
-	header[264] = 0x30 // '0'
-	// Prefix (155 bytes) - empty for simplicity
-
-	// Calculate checksum
-	sum := checksum(header)
-	sumStr := fmt.Sprintf("%06o", sum)
-	copy(header[148:155], sumStr)
-	header[155] = ' ' // null terminated
-
-	return header
-}
-
-// checksum calculates the tar header checksum
-func checksum(header []byte) int64 {
-	var sum int64
-	for i := 0; i < 512; i++ {
-		// Skip the checksum field itself (bytes 148-155)
-		if i >= 148 && i < 156 {
-			sum += int64(' ')
-		} else {
-			sum += int64(header[i])
+	// Each entry runs up to the next one's header; the last up to the end-of-archive blocks
+	for i := range entries {
+		end := counter.n
+		if i+1 < len(entries) {
+			end = entries[i+1].ArchiveOffset
 		}
+		entries[i].ArchiveLength = end - entries[i].ArchiveOffset
+	}
+
+	// Write the end-of-archive blocks
+	if err := tw.Close(); err != nil {
+		return nil, 0, fmt.Errorf("failed to finalize tar archive: %w", err)
 	}
-	return sum
+
+	return entries, totalSize, nil
 }
 
-// parseOctal parses an octal string from a byte slice
-func parseOctal(b []byte) int64 {
-	// Find null terminator
-	end := len(b)
-	for i, c := range b {
-		if c == 0 || c == ' ' {
-			end = i
-			break
-		}
+// indexEntry describes an archived path for the file index
+func indexEntry(name string, header *tar.Header) types.FileIndexEntry {
+	entry := types.FileIndexEntry{
+		Path:    strings.TrimSuffix(name, "/"),
+		Size:    header.Size,
+		Mode:    header.Mode,
+		ModTime: header.ModTime,
+		UID:     header.Uid,
+		GID:     header.Gid,
 	}
-	s := string(b[:end])
-	val, err := strconv.ParseInt(s, 8, 64)
-	if err != nil {
-		return 0
+	switch header.Typeflag {
+	case tar.TypeDir:
+		entry.Type = "dir"
+	case tar.TypeSymlink:
+		entry.Type = "symlink"
+		entry.LinkTarget = header.Linkname
+	case tar.TypeLink:
+		entry.Type = "hardlink"
+		entry.LinkTarget = header.Linkname
+	case tar.TypeReg:
+		entry.Type = "file"
+	default:
+		entry.Type = "special"
 	}
-	return val
+	return entry
 }
@@ -0,0 +1,111 @@
+package replication
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"xvault/internal/worker/client"
+	"xvault/internal/worker/storage"
+	"xvault/pkg/backend"
+	"xvault/pkg/snapshot"
+	"xvault/pkg/types"
+)
+
+// snapshotsPath is the root of the snapshot file routes
+const snapshotsPath = "/replication/snapshots/"
+
+// FilesPath returns the path under which the server serves a snapshot's files
+func FilesPath(snapshotID string) string {
+	return snapshotsPath + snapshotID + "/files"
+}
+
+// Server serves the snapshots this worker holds to workers replicating them. Every
+// request carries the one-off token the hub issued for a replica as a bearer token;
+// the hub tells the server which snapshot copy the token grants access to. It uses
+// net/http rather than fiber so artifacts are streamed instead of buffered.
+type Server struct {
+	workerID  string
+	hubClient *client.HubClient
+	storage   *storage.Storage
+	srv       *http.Server
+}
+
+// NewServer creates a replication server for the snapshots in store
+func NewServer(listenAddr, workerID string, hubClient *client.HubClient, store *storage.Storage) *Server {
+	s := &Server{
+		workerID:  workerID,
+		hubClient: hubClient,
+		storage:   store,
+	}
+	s.srv = &http.Server{
+		Addr:              listenAddr,
+		Handler:           s,
+		ReadHeaderTimeout: 30 * time.Second,
+	}
+	return s
+}
+
+// Start serves requests in the background
+func (s *Server) Start() {
+	go func() {
+		log.Printf("replication server listening on %s", s.srv.Addr)
+		if err := s.srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("replication server error: %v", err)
+		}
+	}()
+}
+
+// Shutdown stops the server, waiting for transfers in progress
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.srv.Shutdown(ctx)
+}
+
+// ServeHTTP serves GET/HEAD /replication/snapshots/:id/files[/<name>]
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	snapshotID, _, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, snapshotsPath), "/files")
+	if !strings.HasPrefix(r.URL.Path, snapshotsPath) || !ok || snapshotID == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found || token == "" {
+		http.Error(w, "missing transfer token", http.StatusUnauthorized)
+		return
+	}
+	transfer, err := s.hubClient.AuthorizeReplicaTransfer(r.Context(), s.workerID, token)
+	if err != nil {
+		log.Printf("failed to authorize replica transfer: %v", err)
+		http.Error(w, "failed to authorize transfer", http.StatusBadGateway)
+		return
+	}
+	if transfer == nil || normalizeID(transfer.SnapshotID) != normalizeID(snapshotID) {
+		http.Error(w, "transfer not authorized", http.StatusForbidden)
+		return
+	}
+
+	loc, err := s.location(transfer)
+	if err != nil {
+		log.Printf("failed to locate snapshot %s for replica %s: %v", transfer.SnapshotID, transfer.ReplicaID, err)
+		http.Error(w, "snapshot not available", http.StatusInternalServerError)
+		return
+	}
+	http.StripPrefix(FilesPath(snapshotID), backend.NewHandler(loc.Backend, loc.Prefix)).ServeHTTP(w, r)
+}
+
+// location returns where this worker holds the snapshot copy a transfer reads
+func (s *Server) location(transfer *client.ReplicaTransfer) (snapshot.Location, error) {
+	if transfer.Locator.StorageBackend == string(types.StorageBackendS3) {
+		return s.storage.ObjectLocation(transfer.Locator.Bucket, transfer.Locator.ObjectKey)
+	}
+	return s.storage.LocalLocation(transfer.TenantID, transfer.SourceID, transfer.SnapshotID), nil
+}
+
+// normalizeID drops the dashes of a UUID, which snapshot IDs lose on disk
+func normalizeID(id string) string {
+	return strings.ReplaceAll(id, "-", "")
+}
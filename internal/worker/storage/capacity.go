@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+
+	"xvault/internal/worker/metrics"
+)
+
+// Errors returned by CheckCapacity
+var (
+	ErrInsufficientScratchSpace = errors.New("insufficient scratch space")
+	ErrInsufficientStorageSpace = errors.New("insufficient storage space")
+)
+
+// CheckCapacity checks that a backup of a source estimated at sourceBytes fits on
+// this worker before anything is pulled: the source is mirrored into the scratch
+// directory, and the snapshot is written to local storage (before any upload) at no
+// more than the source's size. Each requires a tenth on top of the estimate as
+// headroom, and a filesystem holding both must fit both.
+func (s *Storage) CheckCapacity(sourceBytes int64) error {
+	if err := os.MkdirAll(s.scratchDir, 0755); err != nil {
+		return fmt.Errorf("failed to create scratch directory: %w", err)
+	}
+	if err := os.MkdirAll(s.basePath, 0755); err != nil {
+		return fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	required := sourceBytes + sourceBytes/10
+	scratch, err := freeSpace(s.scratchDir)
+	if err != nil {
+		return err
+	}
+	stored, err := freeSpace(s.basePath)
+	if err != nil {
+		return err
+	}
+
+	if scratch.device == stored.device {
+		if scratch.free < 2*required {
+			return fmt.Errorf("%w: %s and %s share a filesystem with %d bytes free, %d needed",
+				ErrInsufficientScratchSpace, s.scratchDir, s.basePath, scratch.free, 2*required)
+		}
+		return nil
+	}
+	if scratch.free < required {
+		return fmt.Errorf("%w: %s has %d bytes free, %d needed", ErrInsufficientScratchSpace, s.scratchDir, scratch.free, required)
+	}
+	if stored.free < required {
+		return fmt.Errorf("%w: %s has %d bytes free, %d needed", ErrInsufficientStorageSpace, s.basePath, stored.free, required)
+	}
+	return nil
+}
+
+// filesystemSpace is the space left on the filesystem holding a path
+type filesystemSpace struct {
+	device uint64
+	free   int64
+}
+
+// freeSpace returns the space available to the worker on the filesystem holding path
+func freeSpace(path string) (filesystemSpace, error) {
+	var stat metrics.StatFs
+	if err := metrics.Statfs(path, &stat); err != nil {
+		return filesystemSpace{}, fmt.Errorf("failed to stat filesystem of %s: %w", path, err)
+	}
+	space := filesystemSpace{free: int64(stat.Bavail) * stat.Bsize}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return filesystemSpace{}, fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+	if sys, ok := info.Sys().(*syscall.Stat_t); ok {
+		space.device = uint64(sys.Dev)
+	}
+	return space, nil
+}
@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+)
+
+// Content-defined chunk size bounds. Boundaries are chosen by a gear rolling hash,
+// so an insertion near the start of a stream only changes the chunks around it.
+const (
+	MinChunkSize = 512 * 1024
+	AvgChunkSize = 1024 * 1024
+	MaxChunkSize = 4 * 1024 * 1024
+)
+
+// Normalized chunking masks: harder to cut before the average size, easier after
+const (
+	maskSmall uint64 = 0x037279322de00000 // 21 bits set
+	maskLarge uint64 = 0x0da5a02629c00000 // 17 bits set
+)
+
+// gearTable holds 256 pseudo-random values for the gear hash. It is derived
+// deterministically so every worker cuts identical streams at identical offsets.
+var gearTable = func() [256]uint64 {
+	var table [256]uint64
+	for i := range table {
+		sum := sha256.Sum256([]byte{'x', 'v', 'a', 'u', 'l', 't', '-', 'g', 'e', 'a', 'r', byte(i)})
+		table[i] = binary.LittleEndian.Uint64(sum[:8])
+	}
+	return table
+}()
+
+// Chunker splits a stream into content-defined chunks
+type Chunker struct {
+	r   io.Reader
+	buf []byte
+	n   int // valid bytes in buf
+	eof bool
+}
+
+// NewChunker creates a chunker reading from r
+func NewChunker(r io.Reader) *Chunker {
+	return &Chunker{
+		r:   r,
+		buf: make([]byte, MaxChunkSize),
+	}
+}
+
+// Next returns the next chunk. The returned slice is only valid until the next call.
+// It returns io.EOF once the stream is exhausted.
+func (c *Chunker) Next() ([]byte, error) {
+	// Top up the buffer so a full max-size window is available
+	for !c.eof && c.n < len(c.buf) {
+		read, err := c.r.Read(c.buf[c.n:])
+		c.n += read
+		if err == io.EOF {
+			c.eof = true
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if c.n == 0 {
+		return nil, io.EOF
+	}
+
+	cut := findBoundary(c.buf[:c.n])
+
+	// Hand out a copy so the buffer can be compacted for the next call
+	chunk := make([]byte, cut)
+	copy(chunk, c.buf[:cut])
+	c.n = copy(c.buf, c.buf[cut:c.n])
+
+	return chunk, nil
+}
+
+// findBoundary returns the length of the next chunk in data
+func findBoundary(data []byte) int {
+	if len(data) <= MinChunkSize {
+		return len(data)
+	}
+
+	limit := len(data)
+	if limit > MaxChunkSize {
+		limit = MaxChunkSize
+	}
+	normal := AvgChunkSize
+	if normal > limit {
+		normal = limit
+	}
+
+	var hash uint64
+	i := MinChunkSize
+	for ; i < normal; i++ {
+		hash = (hash << 1) + gearTable[data[i]]
+		if hash&maskSmall == 0 {
+			return i + 1
+		}
+	}
+	for ; i < limit; i++ {
+		hash = (hash << 1) + gearTable[data[i]]
+		if hash&maskLarge == 0 {
+			return i + 1
+		}
+	}
+	return limit
+}
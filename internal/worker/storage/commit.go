@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"xvault/pkg/backend"
+	"xvault/pkg/snapshot"
+	"xvault/pkg/types"
+)
+
+// StagingSuffix marks a snapshot directory that is still being written. Snapshots are
+// staged under <snapshot>.partial, fsynced and verified, then renamed to their final
+// path, so a directory without the suffix is always complete.
+const StagingSuffix = ".partial"
+
+// StagingPath returns the directory a snapshot is written to before it is committed
+func (s *Storage) StagingPath(tenantID, sourceID, snapshotID string) string {
+	return s.SnapshotPath(tenantID, sourceID, snapshotID) + StagingSuffix
+}
+
+// createStaging creates an empty staging directory for a snapshot, discarding anything
+// left there by an earlier attempt
+func (s *Storage) createStaging(tenantID, sourceID, snapshotID string) (string, error) {
+	stagingPath := s.StagingPath(tenantID, sourceID, snapshotID)
+	if err := os.RemoveAll(stagingPath); err != nil {
+		return "", fmt.Errorf("failed to clear staging directory: %w", err)
+	}
+	if err := os.MkdirAll(stagingPath, 0755); err != nil {
+		return "", fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	return stagingPath, nil
+}
+
+// commitSnapshot checks the staged files of a snapshot against its manifest and
+// renames the staging directory to the final snapshot path. The directory entries
+// are fsynced on both sides of the rename so the commit survives a crash.
+func (s *Storage) commitSnapshot(stagingPath string, manifest []byte) (string, error) {
+	if err := verifyStaged(stagingPath, manifest); err != nil {
+		return "", fmt.Errorf("staged snapshot failed verification: %w", err)
+	}
+	if err := syncDir(stagingPath); err != nil {
+		return "", fmt.Errorf("failed to sync snapshot directory: %w", err)
+	}
+
+	snapshotPath := strings.TrimSuffix(stagingPath, StagingSuffix)
+	if err := os.Rename(stagingPath, snapshotPath); err != nil {
+		return "", fmt.Errorf("failed to commit snapshot: %w", err)
+	}
+	if err := syncDir(filepath.Dir(snapshotPath)); err != nil {
+		return "", fmt.Errorf("failed to sync snapshots directory: %w", err)
+	}
+	return snapshotPath, nil
+}
+
+// verifyStaged reads back what was written to a staging directory and compares it with
+// the hash recorded in the manifest: the whole artifact (and each volume) for an
+// artifact snapshot, the sealed chunk index for a repository snapshot
+func verifyStaged(stagingPath string, manifestJSON []byte) error {
+	manifest, err := snapshot.ParseManifest(manifestJSON)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	loc := snapshot.Location{Backend: backend.NewLocal(stagingPath)}
+
+	if manifest.StorageMode == types.StorageModeRepository {
+		index, err := backend.ReadAll(ctx, loc.Backend, snapshot.ChunkIndexFileName)
+		if err != nil {
+			return fmt.Errorf("failed to read chunk index: %w", err)
+		}
+		if sum := sha256.Sum256(index); hex.EncodeToString(sum[:]) != manifest.SHA256 {
+			return fmt.Errorf("chunk index failed SHA-256 verification")
+		}
+		return nil
+	}
+
+	artifact, err := snapshot.OpenArtifact(ctx, loc, manifest)
+	if err != nil {
+		return err
+	}
+	defer artifact.Close()
+	if _, err := io.Copy(io.Discard, artifact); err != nil {
+		return fmt.Errorf("failed to read encrypted backup: %w", err)
+	}
+	return artifact.Verify()
+}
+
+// writeFileSync writes data to path and fsyncs it before returning
+func writeFileSync(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// syncDir fsyncs a directory so entries created or renamed in it are durable
+func syncDir(path string) error {
+	dir, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
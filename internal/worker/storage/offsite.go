@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"xvault/pkg/backend"
+	"xvault/pkg/snapshot"
+	"xvault/pkg/types"
+)
+
+// PushSnapshot copies a snapshot's files, still encrypted, from src to prefix in dst,
+// e.g. a tenant's offsite target. The manifest is copied last, so a copy whose
+// manifest is present is complete; a failed push removes what it copied. Returns the
+// snapshot's manifest.
+func PushSnapshot(ctx context.Context, src snapshot.Location, dst backend.Backend, prefix string) (*types.SnapshotManifest, error) {
+	manifest, manifestJSON, err := snapshot.ReadManifest(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	if manifest.StorageMode == types.StorageModeRepository {
+		return nil, fmt.Errorf("repository-mode snapshots cannot be pushed: their chunks are shared with the tenant's other snapshots")
+	}
+
+	listPrefix := src.Prefix
+	if listPrefix != "" {
+		listPrefix = strings.TrimSuffix(listPrefix, "/") + "/"
+	}
+	objects, err := src.Backend.List(ctx, listPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshot: %w", err)
+	}
+
+	for _, object := range objects {
+		name := strings.TrimPrefix(object.Key, listPrefix)
+		if name == snapshot.ManifestFileName || strings.Contains(name, "/") {
+			continue
+		}
+		if err := pushFile(ctx, src, dst, name, backend.Join(prefix, name), object.Size); err != nil {
+			backend.DeletePrefix(context.WithoutCancel(ctx), dst, prefix)
+			return nil, err
+		}
+	}
+	if _, err := dst.Put(ctx, backend.Join(prefix, snapshot.ManifestFileName), bytes.NewReader(manifestJSON)); err != nil {
+		backend.DeletePrefix(context.WithoutCancel(ctx), dst, prefix)
+		return nil, fmt.Errorf("failed to push manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+// pushFile copies one file of a snapshot and checks the copy is complete
+func pushFile(ctx context.Context, src snapshot.Location, dst backend.Backend, name, key string, size int64) error {
+	body, err := src.Backend.Get(ctx, src.Key(name))
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	defer body.Close()
+
+	info, err := dst.Put(ctx, key, body)
+	if err != nil {
+		return fmt.Errorf("failed to push %s: %w", name, err)
+	}
+	if info.Size != size {
+		return fmt.Errorf("pushed %d bytes of %s, expected %d", info.Size, name, size)
+	}
+	return nil
+}
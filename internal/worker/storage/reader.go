@@ -0,0 +1,27 @@
+package storage
+
+import (
+	"context"
+	"io"
+
+	"xvault/pkg/snapshot"
+	"xvault/pkg/types"
+)
+
+// OpenSnapshotStream returns the plaintext tar stream of a snapshot stored by this
+// worker, whether it is a standalone artifact or references chunks in the tenant
+// repository
+func (s *Storage) OpenSnapshotStream(ctx context.Context, tenantID, sourceID, snapshotID, privateKey string) (io.ReadCloser, error) {
+	loc := s.Location(tenantID, sourceID, snapshotID)
+
+	manifest, _, err := snapshot.ReadManifest(ctx, loc)
+	if err != nil {
+		return nil, err
+	}
+	return snapshot.OpenTarStream(ctx, loc, manifest, privateKey)
+}
+
+// ReadFileIndex decrypts the file index of a snapshot stored by this worker
+func (s *Storage) ReadFileIndex(ctx context.Context, tenantID, sourceID, snapshotID, privateKey string) (*types.FileIndex, error) {
+	return snapshot.ReadFileIndex(ctx, s.Location(tenantID, sourceID, snapshotID), privateKey)
+}
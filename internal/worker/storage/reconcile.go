@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"xvault/pkg/snapshot"
+	"xvault/pkg/types"
+)
+
+// InventoryEntry describes a committed snapshot directory in local storage. The IDs
+// are the directory's path components; Error says when its meta.json is unreadable or
+// names a different snapshot.
+type InventoryEntry struct {
+	TenantID      string
+	SourceID      string
+	SnapshotID    string
+	Path          string
+	StorageMode   types.StorageMode
+	ArtifactBytes int64 // artifact file or volumes
+	TotalBytes    int64 // every file in the directory
+	Error         string
+}
+
+// Inventory describes every committed snapshot directory under
+// tenants/*/sources/*/snapshots. Staging and re-encryption directories are skipped.
+func (s *Storage) Inventory() ([]InventoryEntry, error) {
+	dirs, err := s.snapshotDirs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshot directories: %w", err)
+	}
+
+	entries := make([]InventoryEntry, 0, len(dirs))
+	for _, dir := range dirs {
+		if isWorkDir(dir.name) {
+			continue
+		}
+		entry := InventoryEntry{
+			TenantID:    dir.tenantID,
+			SourceID:    dir.sourceID,
+			SnapshotID:  dir.name,
+			Path:        dir.path,
+			StorageMode: types.StorageModeArtifact,
+		}
+
+		files, err := os.ReadDir(dir.path)
+		if err != nil {
+			entry.Error = fmt.Sprintf("failed to read directory: %v", err)
+			entries = append(entries, entry)
+			continue
+		}
+		for _, file := range files {
+			info, err := file.Info()
+			if err != nil || !info.Mode().IsRegular() {
+				continue
+			}
+			entry.TotalBytes += info.Size()
+			switch name := file.Name(); {
+			case name == snapshot.ArtifactFileName || strings.HasPrefix(name, snapshot.ArtifactFileName+"."):
+				entry.ArtifactBytes += info.Size()
+			case name == snapshot.ChunkRefsFileName:
+				entry.StorageMode = types.StorageModeRepository
+			}
+		}
+
+		if err := checkMeta(dir); err != nil {
+			entry.Error = err.Error()
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// checkMeta checks that a snapshot directory's meta.json names the snapshot its path does
+func checkMeta(dir snapshotDir) error {
+	data, err := os.ReadFile(filepath.Join(dir.path, snapshot.MetaFileName))
+	if err != nil {
+		return fmt.Errorf("failed to read meta.json: %w", err)
+	}
+	var meta Meta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return fmt.Errorf("failed to parse meta.json: %w", err)
+	}
+	if meta.TenantID != dir.tenantID || meta.SourceID != dir.sourceID || strings.ReplaceAll(meta.SnapshotID, "-", "") != dir.name {
+		return fmt.Errorf("meta.json names snapshot %s of tenant %s, source %s", meta.SnapshotID, meta.TenantID, meta.SourceID)
+	}
+	return nil
+}
+
+// QuarantinePath returns where QuarantineSnapshot moves a snapshot directory
+func (s *Storage) QuarantinePath(tenantID, sourceID, snapshotID string) string {
+	return filepath.Join(s.basePath, "quarantine", tenantID, sourceID, snapshotID)
+}
+
+// QuarantineSnapshot moves a snapshot directory out of the snapshot tree, to
+// quarantine/<tenant>/<source>/<snapshot> under the storage base. Its files and chunk
+// references are kept until an operator removes it. Returns the new path.
+func (s *Storage) QuarantineSnapshot(tenantID, sourceID, snapshotID string) (string, error) {
+	if err := validatePathIDs(tenantID, sourceID, snapshotID); err != nil {
+		return "", err
+	}
+
+	snapshotPath := s.SnapshotPath(tenantID, sourceID, snapshotID)
+	if _, err := os.Stat(snapshotPath); err != nil {
+		return "", fmt.Errorf("snapshot not found: %w", err)
+	}
+
+	quarantinePath := s.QuarantinePath(tenantID, sourceID, snapshotID)
+	if err := os.MkdirAll(filepath.Dir(quarantinePath), 0755); err != nil {
+		return "", fmt.Errorf("failed to create quarantine directory: %w", err)
+	}
+	if err := os.Rename(snapshotPath, quarantinePath); err != nil {
+		return "", fmt.Errorf("failed to quarantine snapshot: %w", err)
+	}
+	return quarantinePath, nil
+}
+
+// PurgeSnapshot deletes a snapshot directory found by Inventory, releasing its chunk
+// references
+func (s *Storage) PurgeSnapshot(tenantID, sourceID, snapshotID string) error {
+	if err := validatePathIDs(tenantID, sourceID, snapshotID); err != nil {
+		return err
+	}
+	return s.DeleteSnapshot(tenantID, sourceID, snapshotID)
+}
+
+var pathIDPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// validatePathIDs rejects IDs that are unsafe as path components
+func validatePathIDs(ids ...string) error {
+	for _, id := range ids {
+		if !pathIDPattern.MatchString(id) {
+			return fmt.Errorf("invalid ID %q in snapshot path", id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"xvault/pkg/snapshot"
+	"xvault/pkg/types"
+)
+
+// DamagedSnapshot is a snapshot whose files Recover found unusable
+type DamagedSnapshot struct {
+	TenantID   string
+	SourceID   string
+	SnapshotID string
+	Problem    string
+}
+
+// RecoveryReport lists what Recover cleaned up or found damaged
+type RecoveryReport struct {
+	TempDirs int
+	// Partial snapshots were still being written when the worker stopped; their
+	// staging directories have been removed
+	Partial []DamagedSnapshot
+	// Incomplete snapshots have a final directory with missing or truncated files,
+	// left by a worker that predates staged commits or by damage to the disk. They
+	// are kept for inspection.
+	Incomplete []DamagedSnapshot
+}
+
+// Recover cleans up after a worker that stopped mid-job. It must run before any job
+// starts: it removes every job temp directory in the scratch directory, removes
+// staging directories of uncommitted snapshots (releasing their chunk references) and
+// leftover temporary files in the chunk repositories, finishes or undoes interrupted
+// re-encryptions, and checks that every committed snapshot has the files its manifest
+// lists.
+func (s *Storage) Recover() (*RecoveryReport, error) {
+	report := &RecoveryReport{}
+
+	tempDirs, err := os.ReadDir(s.scratchDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read temp directory: %w", err)
+	}
+	for _, entry := range tempDirs {
+		// The scratch directory may be shared, so only job directories are swept
+		if _, err := uuid.Parse(entry.Name()); err != nil || !entry.IsDir() {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(s.scratchDir, entry.Name())); err != nil {
+			return nil, fmt.Errorf("failed to remove temp directory: %w", err)
+		}
+		report.TempDirs++
+	}
+
+	tmpFiles, err := filepath.Glob(filepath.Join(s.basePath, "tenants", "*", "repository", "chunks", "*", "*.tmp"))
+	if err != nil {
+		return nil, err
+	}
+	refcountTmp, _ := filepath.Glob(filepath.Join(s.basePath, "tenants", "*", "repository", "*.tmp"))
+	for _, path := range append(tmpFiles, refcountTmp...) {
+		os.Remove(path)
+	}
+
+	dirs, err := s.snapshotDirs()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.recoverRekeys(dirs); err != nil {
+		return nil, err
+	}
+	if dirs, err = s.snapshotDirs(); err != nil {
+		return nil, err
+	}
+	for _, dir := range dirs {
+		damaged := DamagedSnapshot{TenantID: dir.tenantID, SourceID: dir.sourceID, SnapshotID: dir.name}
+
+		if strings.HasSuffix(dir.name, StagingSuffix) {
+			damaged.SnapshotID = strings.TrimSuffix(dir.name, StagingSuffix)
+			damaged.Problem = "snapshot was not committed before the worker stopped"
+			if err := s.removeSnapshotDir(dir.tenantID, dir.path); err != nil {
+				return nil, fmt.Errorf("failed to remove staged snapshot %s: %w", damaged.SnapshotID, err)
+			}
+			report.Partial = append(report.Partial, damaged)
+			continue
+		}
+
+		if err := checkSnapshotFiles(dir.path); err != nil {
+			damaged.Problem = err.Error()
+			report.Incomplete = append(report.Incomplete, damaged)
+		}
+	}
+
+	return report, nil
+}
+
+// snapshotDir is a directory in the snapshot tree, committed or staged
+type snapshotDir struct {
+	path     string
+	tenantID string
+	sourceID string
+	name     string // snapshot ID, with StagingSuffix while staged
+}
+
+// snapshotDirs lists the directories matching tenants/*/sources/*/snapshots/*
+func (s *Storage) snapshotDirs() ([]snapshotDir, error) {
+	paths, err := filepath.Glob(filepath.Join(s.basePath, "tenants", "*", "sources", "*", "snapshots", "*"))
+	if err != nil {
+		return nil, err
+	}
+
+	var dirs []snapshotDir
+	for _, path := range paths {
+		if info, err := os.Stat(path); err != nil || !info.IsDir() {
+			continue
+		}
+		rel, err := filepath.Rel(s.basePath, path)
+		if err != nil {
+			continue
+		}
+		// tenants/<tenant>/sources/<source>/snapshots/<snapshot>
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		dirs = append(dirs, snapshotDir{path: path, tenantID: parts[1], sourceID: parts[3], name: parts[5]})
+	}
+	return dirs, nil
+}
+
+// checkSnapshotFiles checks that a snapshot directory has a readable manifest and
+// every file it lists, with the recorded sizes. It does not read the artifact.
+func checkSnapshotFiles(dir string) error {
+	manifestJSON, err := os.ReadFile(filepath.Join(dir, snapshot.ManifestFileName))
+	if err != nil {
+		return fmt.Errorf("failed to read manifest: %w", err)
+	}
+	manifest, err := snapshot.ParseManifest(manifestJSON)
+	if err != nil {
+		return err
+	}
+
+	// A size of -1 is not recorded in the manifest and only checked for presence
+	var expected []types.ArtifactVolume
+	switch {
+	case manifest.StorageMode == types.StorageModeRepository:
+		expected = []types.ArtifactVolume{
+			{Name: snapshot.ChunkIndexFileName, SizeBytes: -1},
+			{Name: snapshot.ChunkRefsFileName, SizeBytes: -1},
+		}
+	case len(manifest.Volumes) > 0:
+		expected = manifest.Volumes
+	default:
+		expected = []types.ArtifactVolume{{Name: snapshot.ArtifactFileName, SizeBytes: manifest.SizeBytes}}
+	}
+
+	for _, file := range expected {
+		info, err := os.Stat(filepath.Join(dir, file.Name))
+		if err != nil {
+			return fmt.Errorf("missing %s", file.Name)
+		}
+		if file.SizeBytes >= 0 && info.Size() != file.SizeBytes {
+			return fmt.Errorf("%s has %d bytes, manifest expects %d", file.Name, info.Size(), file.SizeBytes)
+		}
+	}
+	return nil
+}
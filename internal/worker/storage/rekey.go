@@ -0,0 +1,210 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"xvault/pkg/crypto"
+	"xvault/pkg/snapshot"
+	"xvault/pkg/types"
+)
+
+// RekeySuffix marks the directory RekeySnapshot writes a re-encrypted snapshot to, and
+// RekeyOldSuffix the snapshot's previous files while the two are swapped. Recover
+// removes the former and finishes or undoes a swap the worker stopped in.
+const (
+	RekeySuffix    = ".rekey"
+	RekeyOldSuffix = ".rekey-old"
+)
+
+// isWorkDir reports whether a directory in the snapshot tree holds a snapshot still
+// being written or re-encrypted rather than a committed one
+func isWorkDir(name string) bool {
+	return strings.HasSuffix(name, StagingSuffix) || strings.HasSuffix(name, RekeySuffix) || strings.HasSuffix(name, RekeyOldSuffix)
+}
+
+// RekeySnapshot re-encrypts an artifact snapshot on local storage to another tenant
+// key. The artifact and file index are decrypted with privateKey and encrypted to
+// publicKey in <snapshot>.rekey, keeping the snapshot's volume size; the compressed
+// stream is copied as it is, so frame offsets stay valid. The copy is checked against
+// its new manifest before the directories are swapped. Returns the new manifest and
+// its encoding; a snapshot already encrypted to keyID is returned unchanged.
+func (s *Storage) RekeySnapshot(ctx context.Context, tenantID, sourceID, snapshotID, privateKey, publicKey, keyID string) (*types.SnapshotManifest, []byte, error) {
+	loc := s.LocalLocation(tenantID, sourceID, snapshotID)
+	manifest, manifestJSON, err := snapshot.ReadManifest(ctx, loc)
+	if err != nil {
+		return nil, nil, err
+	}
+	if manifest.StorageMode == types.StorageModeRepository {
+		return nil, nil, fmt.Errorf("repository snapshots share chunks with the tenant's other snapshots and cannot be re-encrypted")
+	}
+	if manifest.EncryptionKeyID == keyID {
+		return manifest, manifestJSON, nil
+	}
+
+	snapshotPath := s.SnapshotPath(tenantID, sourceID, snapshotID)
+	rekeyPath := snapshotPath + RekeySuffix
+	if err := os.RemoveAll(rekeyPath); err != nil {
+		return nil, nil, fmt.Errorf("failed to clear re-encryption directory: %w", err)
+	}
+	if err := os.MkdirAll(rekeyPath, 0755); err != nil {
+		return nil, nil, fmt.Errorf("failed to create re-encryption directory: %w", err)
+	}
+
+	manifestJSON, err = rekeyFiles(ctx, loc, snapshotPath, rekeyPath, manifest, privateKey, publicKey, keyID)
+	if err == nil {
+		err = verifyStaged(rekeyPath, manifestJSON)
+	}
+	if err == nil {
+		err = syncDir(rekeyPath)
+	}
+	if err != nil {
+		os.RemoveAll(rekeyPath)
+		return nil, nil, err
+	}
+
+	if err := swapRekeyed(snapshotPath); err != nil {
+		os.RemoveAll(rekeyPath)
+		return nil, nil, err
+	}
+	return manifest, manifestJSON, nil
+}
+
+// rekeyFiles writes the re-encrypted artifact, file index, manifest and meta.json of
+// the snapshot in snapshotPath to rekeyPath, updating manifest to match. The old
+// artifact is checked against its hash as it is read.
+func rekeyFiles(ctx context.Context, loc snapshot.Location, snapshotPath, rekeyPath string, manifest *types.SnapshotManifest, privateKey, publicKey, keyID string) ([]byte, error) {
+	artifact, err := snapshot.OpenArtifact(ctx, loc, manifest)
+	if err != nil {
+		return nil, err
+	}
+	defer artifact.Close()
+	plaintext, err := crypto.NewDecryptReader(artifact, privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &ArtifactWriter{dir: rekeyPath, volumeSize: manifest.VolumeSize}
+	if out.volumeSize == 0 {
+		if err := out.open(snapshot.ArtifactFileName); err != nil {
+			return nil, err
+		}
+	}
+	hasher := sha256.New()
+	encrypted, err := crypto.NewEncryptWriter(io.MultiWriter(out, hasher), publicKey)
+	if err != nil {
+		out.Abort()
+		return nil, err
+	}
+	if _, err := io.Copy(encrypted, plaintext); err != nil {
+		out.Abort()
+		return nil, fmt.Errorf("failed to re-encrypt backup: %w", err)
+	}
+	if err := encrypted.Close(); err != nil {
+		out.Abort()
+		return nil, fmt.Errorf("failed to finalize encryption: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		out.Abort()
+		return nil, err
+	}
+	if err := artifact.Verify(); err != nil {
+		return nil, err
+	}
+
+	// Snapshots written before file indexes existed have none
+	sealedIndex, err := os.ReadFile(filepath.Join(snapshotPath, snapshot.FileIndexFileName))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read file index: %w", err)
+	}
+	if err == nil {
+		index, err := crypto.DecryptWithPrivateKey(sealedIndex, privateKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt file index: %w", err)
+		}
+		if sealedIndex, err = crypto.EncryptToPublicKey(index, publicKey); err != nil {
+			return nil, fmt.Errorf("failed to encrypt file index: %w", err)
+		}
+		if err := writeFileSync(filepath.Join(rekeyPath, snapshot.FileIndexFileName), sealedIndex); err != nil {
+			return nil, fmt.Errorf("failed to write file index: %w", err)
+		}
+	}
+
+	meta, err := os.ReadFile(filepath.Join(snapshotPath, snapshot.MetaFileName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read meta: %w", err)
+	}
+	if err := writeFileSync(filepath.Join(rekeyPath, snapshot.MetaFileName), meta); err != nil {
+		return nil, fmt.Errorf("failed to write meta: %w", err)
+	}
+
+	manifest.SizeBytes = out.Size()
+	manifest.SHA256 = hex.EncodeToString(hasher.Sum(nil))
+	manifest.Volumes = out.Volumes()
+	manifest.EncryptionKeyID = keyID
+	manifest.EncryptionRecipient = publicKey
+	manifestJSON, err := snapshot.EncodeManifest(manifest)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFileSync(filepath.Join(rekeyPath, snapshot.ManifestFileName), manifestJSON); err != nil {
+		return nil, fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return manifestJSON, nil
+}
+
+// swapRekeyed moves a re-encrypted snapshot into place: the snapshot's directory is
+// renamed aside, the re-encrypted one takes its path, and the old files are removed
+func swapRekeyed(snapshotPath string) error {
+	oldPath := snapshotPath + RekeyOldSuffix
+	if err := os.RemoveAll(oldPath); err != nil {
+		return fmt.Errorf("failed to clear previous snapshot directory: %w", err)
+	}
+	if err := os.Rename(snapshotPath, oldPath); err != nil {
+		return fmt.Errorf("failed to move snapshot aside: %w", err)
+	}
+	if err := os.Rename(snapshotPath+RekeySuffix, snapshotPath); err != nil {
+		os.Rename(oldPath, snapshotPath)
+		return fmt.Errorf("failed to move re-encrypted snapshot into place: %w", err)
+	}
+	if err := syncDir(filepath.Dir(snapshotPath)); err != nil {
+		return fmt.Errorf("failed to sync snapshots directory: %w", err)
+	}
+	return os.RemoveAll(oldPath)
+}
+
+// recoverRekeys cleans up re-encryptions the worker stopped in. A snapshot moved aside
+// but not replaced is moved back; otherwise its old files are removed, as is any
+// re-encrypted copy not yet in place.
+func (s *Storage) recoverRekeys(dirs []snapshotDir) error {
+	for _, dir := range dirs {
+		if !strings.HasSuffix(dir.name, RekeyOldSuffix) {
+			continue
+		}
+		snapshotPath := strings.TrimSuffix(dir.path, RekeyOldSuffix)
+		if _, err := os.Stat(snapshotPath); os.IsNotExist(err) {
+			if err := os.Rename(dir.path, snapshotPath); err != nil {
+				return fmt.Errorf("failed to restore snapshot %s: %w", filepath.Base(snapshotPath), err)
+			}
+			continue
+		}
+		if err := os.RemoveAll(dir.path); err != nil {
+			return fmt.Errorf("failed to remove previous files of snapshot %s: %w", filepath.Base(snapshotPath), err)
+		}
+	}
+	for _, dir := range dirs {
+		if !strings.HasSuffix(dir.name, RekeySuffix) {
+			continue
+		}
+		if err := os.RemoveAll(dir.path); err != nil {
+			return fmt.Errorf("failed to remove re-encrypted copy of snapshot %s: %w", strings.TrimSuffix(dir.name, RekeySuffix), err)
+		}
+	}
+	return nil
+}
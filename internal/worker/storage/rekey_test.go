@@ -0,0 +1,304 @@
+package storage
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"xvault/pkg/crypto"
+	"xvault/pkg/snapshot"
+	"xvault/pkg/types"
+)
+
+const (
+	testTenantID   = "tenant"
+	testSourceID   = "source"
+	testSnapshotID = "snapshot"
+)
+
+// writeTestSnapshot commits an uncompressed artifact snapshot of one file, encrypted
+// to publicKey and recorded as keyID
+func writeTestSnapshot(t *testing.T, s *Storage, publicKey, keyID string, content []byte) {
+	t.Helper()
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	if err := tw.WriteHeader(&tar.Header{Name: "data.bin", Mode: 0644, Size: int64(len(content))}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	artifact, err := s.CreateArtifact(testTenantID, testSourceID, testSnapshotID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hasher := sha256.New()
+	encrypted, err := crypto.NewEncryptWriter(io.MultiWriter(artifact, hasher), publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := encrypted.Write(archive.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if err := encrypted.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := artifact.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	encoder, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, err := crypto.EncryptToPublicKey(encoder.EncodeAll([]byte(`{"version":1}`), nil), publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifestJSON, err := snapshot.EncodeManifest(&types.SnapshotManifest{
+		TenantID:            testTenantID,
+		SourceID:            testSourceID,
+		SnapshotID:          testSnapshotID,
+		SizeBytes:           artifact.Size(),
+		SHA256:              hex.EncodeToString(hasher.Sum(nil)),
+		EncryptionAlgorithm: "age-x25519",
+		EncryptionKeyID:     keyID,
+		EncryptionRecipient: publicKey,
+		StorageMode:         types.StorageModeArtifact,
+		VolumeSize:          artifact.VolumeSize(),
+		Volumes:             artifact.Volumes(),
+		Compression:         &types.CompressionConfig{Algorithm: types.CompressionNone},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.WriteSnapshot(testTenantID, testSourceID, testSnapshotID, artifact, index, manifestJSON); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readKeyID returns the key the manifest of the test snapshot names
+func readKeyID(t *testing.T, s *Storage) string {
+	t.Helper()
+	manifest, _, err := snapshot.ReadManifest(context.Background(), s.LocalLocation(testTenantID, testSourceID, testSnapshotID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return manifest.EncryptionKeyID
+}
+
+// assertNoWorkDirs fails if a re-encryption left its working directories behind
+func assertNoWorkDirs(t *testing.T, snapshotPath string) {
+	t.Helper()
+	for _, suffix := range []string{RekeySuffix, RekeyOldSuffix} {
+		if _, err := os.Stat(snapshotPath + suffix); !os.IsNotExist(err) {
+			t.Errorf("%s left behind (err %v)", filepath.Base(snapshotPath+suffix), err)
+		}
+	}
+}
+
+func TestRekeySnapshot(t *testing.T) {
+	oldPublic, oldPrivate, err := crypto.GenerateX25519KeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	newPublic, newPrivate, err := crypto.GenerateX25519KeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, volumeSize := range []int64{0, 4096} {
+		s := NewStorage(t.TempDir())
+		s.SetVolumeSize(volumeSize)
+		writeTestSnapshot(t, s, oldPublic, "old-key", bytes.Repeat([]byte("rekey "), 5000))
+		ctx := context.Background()
+
+		manifest, manifestJSON, err := s.RekeySnapshot(ctx, testTenantID, testSourceID, testSnapshotID, oldPrivate, newPublic, "new-key")
+		if err != nil {
+			t.Fatalf("volume size %d: %v", volumeSize, err)
+		}
+		if manifest.EncryptionKeyID != "new-key" || manifest.EncryptionRecipient != newPublic {
+			t.Fatalf("volume size %d: manifest names key %s", volumeSize, manifest.EncryptionKeyID)
+		}
+		if volumeSize > 0 && len(manifest.Volumes) < 2 {
+			t.Fatalf("volume size %d: got %d volumes", volumeSize, len(manifest.Volumes))
+		}
+		stored, err := os.ReadFile(filepath.Join(s.SnapshotPath(testTenantID, testSourceID, testSnapshotID), snapshot.ManifestFileName))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(stored, manifestJSON) {
+			t.Fatalf("volume size %d: stored manifest differs from the one returned", volumeSize)
+		}
+		assertNoWorkDirs(t, s.SnapshotPath(testTenantID, testSourceID, testSnapshotID))
+
+		loc := s.LocalLocation(testTenantID, testSourceID, testSnapshotID)
+		result, err := VerifySnapshot(ctx, loc, manifest, newPrivate)
+		if err != nil {
+			t.Fatalf("volume size %d: re-encrypted snapshot failed verification: %v", volumeSize, err)
+		}
+		if result.FileCount != 1 {
+			t.Fatalf("volume size %d: got %d entries, want 1", volumeSize, result.FileCount)
+		}
+		if _, err := VerifySnapshot(ctx, loc, manifest, oldPrivate); !errors.Is(err, crypto.ErrDecrypt) {
+			t.Fatalf("volume size %d: old key: got %v, want ErrDecrypt", volumeSize, err)
+		}
+		if _, err := snapshot.ReadFileIndex(ctx, loc, newPrivate); err != nil {
+			t.Fatalf("volume size %d: file index: %v", volumeSize, err)
+		}
+
+		// A snapshot already on the key is left alone, even without its old key
+		if _, _, err := s.RekeySnapshot(ctx, testTenantID, testSourceID, testSnapshotID, "", newPublic, "new-key"); err != nil {
+			t.Fatalf("volume size %d: rekey to the current key: %v", volumeSize, err)
+		}
+	}
+}
+
+func TestRekeySnapshotWrongKeyLeavesSnapshot(t *testing.T) {
+	oldPublic, _, _ := crypto.GenerateX25519KeyPair()
+	newPublic, otherPrivate, _ := crypto.GenerateX25519KeyPair()
+	s := NewStorage(t.TempDir())
+	writeTestSnapshot(t, s, oldPublic, "old-key", []byte("data"))
+
+	_, _, err := s.RekeySnapshot(context.Background(), testTenantID, testSourceID, testSnapshotID, otherPrivate, newPublic, "new-key")
+	if !errors.Is(err, crypto.ErrDecrypt) {
+		t.Fatalf("got %v, want ErrDecrypt", err)
+	}
+	if got := readKeyID(t, s); got != "old-key" {
+		t.Fatalf("snapshot now names key %s", got)
+	}
+	assertNoWorkDirs(t, s.SnapshotPath(testTenantID, testSourceID, testSnapshotID))
+}
+
+func TestSwapRekeyed(t *testing.T) {
+	snapshotPath := filepath.Join(t.TempDir(), "snapshot")
+	for path, content := range map[string]string{snapshotPath: "old", snapshotPath + RekeySuffix: "new"} {
+		if err := os.MkdirAll(path, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(path, "marker"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Left by an earlier swap that stopped before removing the old files
+	if err := os.MkdirAll(snapshotPath+RekeyOldSuffix, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := swapRekeyed(snapshotPath); err != nil {
+		t.Fatal(err)
+	}
+	marker, err := os.ReadFile(filepath.Join(snapshotPath, "marker"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(marker) != "new" {
+		t.Fatalf("snapshot holds the %s files", marker)
+	}
+	assertNoWorkDirs(t, snapshotPath)
+}
+
+// TestRecoverRekeys stops a re-encryption at each step and checks Recover rolls it
+// back or forward to a single snapshot directory
+func TestRecoverRekeys(t *testing.T) {
+	oldPublic, _, _ := crypto.GenerateX25519KeyPair()
+
+	tests := []struct {
+		name string
+		// stop leaves the snapshot directory as a re-encryption stopped at some step
+		stop    func(t *testing.T, snapshotPath string)
+		wantKey string
+	}{
+		{
+			name: "copy not yet in place",
+			stop: func(t *testing.T, snapshotPath string) {
+				writeMarkerDir(t, snapshotPath+RekeySuffix)
+			},
+			wantKey: "old-key",
+		},
+		{
+			name: "moved aside, not replaced",
+			stop: func(t *testing.T, snapshotPath string) {
+				writeMarkerDir(t, snapshotPath+RekeySuffix)
+				if err := os.Rename(snapshotPath, snapshotPath+RekeyOldSuffix); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantKey: "old-key",
+		},
+		{
+			name: "replaced, old files not removed",
+			stop: func(t *testing.T, snapshotPath string) {
+				writeMarkerDir(t, snapshotPath+RekeyOldSuffix)
+				rewriteKeyID(t, snapshotPath, "new-key")
+			},
+			wantKey: "new-key",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewStorage(t.TempDir())
+			s.SetScratchDir(t.TempDir())
+			writeTestSnapshot(t, s, oldPublic, "old-key", []byte("data"))
+			snapshotPath := s.SnapshotPath(testTenantID, testSourceID, testSnapshotID)
+			tt.stop(t, snapshotPath)
+
+			report, err := s.Recover()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(report.Incomplete) > 0 || len(report.Partial) > 0 {
+				t.Fatalf("recovery reported damage: %+v", report)
+			}
+			if got := readKeyID(t, s); got != tt.wantKey {
+				t.Fatalf("snapshot names key %s, want %s", got, tt.wantKey)
+			}
+			assertNoWorkDirs(t, snapshotPath)
+		})
+	}
+}
+
+// writeMarkerDir creates a directory holding a single file
+func writeMarkerDir(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(path, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(path, "marker"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// rewriteKeyID changes the key the manifest in snapshotPath names, standing in for a
+// re-encrypted copy
+func rewriteKeyID(t *testing.T, snapshotPath, keyID string) {
+	t.Helper()
+	manifestPath := filepath.Join(snapshotPath, snapshot.ManifestFileName)
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := snapshot.ParseManifest(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest.EncryptionKeyID = keyID
+	if data, err = snapshot.EncodeManifest(manifest); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(manifestPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
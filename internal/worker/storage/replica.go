@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"xvault/pkg/snapshot"
+	"xvault/pkg/types"
+)
+
+// ReceiveSnapshot copies a snapshot's files, still encrypted, from another worker's
+// storage into local storage. The copy is staged and committed like a snapshot taken
+// here, so it is verified against the source's manifest before it becomes visible.
+// A copy already committed by an earlier attempt is kept if it verifies. Returns the
+// snapshot directory and its manifest.
+func (s *Storage) ReceiveSnapshot(ctx context.Context, src snapshot.Location, tenantID, sourceID, snapshotID string) (string, *types.SnapshotManifest, error) {
+	if err := validatePathIDs(tenantID, sourceID, snapshotID); err != nil {
+		return "", nil, err
+	}
+
+	manifest, manifestJSON, err := snapshot.ReadManifest(ctx, src)
+	if err != nil {
+		return "", nil, err
+	}
+	if manifest.StorageMode == types.StorageModeRepository {
+		return "", nil, fmt.Errorf("repository-mode snapshots cannot be replicated: their chunks are shared with the tenant's other snapshots")
+	}
+
+	snapshotPath := s.SnapshotPath(tenantID, sourceID, snapshotID)
+	if _, err := os.Stat(snapshotPath); err == nil {
+		if err := verifyStaged(snapshotPath, manifestJSON); err != nil {
+			return "", nil, fmt.Errorf("snapshot already stored and does not match the source: %w", err)
+		}
+		return snapshotPath, manifest, nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return "", nil, fmt.Errorf("failed to check snapshot directory: %w", err)
+	}
+
+	listPrefix := src.Prefix
+	if listPrefix != "" {
+		listPrefix = strings.TrimSuffix(listPrefix, "/") + "/"
+	}
+	objects, err := src.Backend.List(ctx, listPrefix)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to list source snapshot: %w", err)
+	}
+
+	stagingPath, err := s.createStaging(tenantID, sourceID, snapshotID)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, object := range objects {
+		name := strings.TrimPrefix(object.Key, listPrefix)
+		if name == snapshot.ManifestFileName || strings.Contains(name, "/") {
+			continue
+		}
+		if err := receiveFile(ctx, src, name, filepath.Join(stagingPath, name)); err != nil {
+			os.RemoveAll(stagingPath)
+			return "", nil, err
+		}
+	}
+	if err := writeFileSync(filepath.Join(stagingPath, snapshot.ManifestFileName), manifestJSON); err != nil {
+		os.RemoveAll(stagingPath)
+		return "", nil, fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	snapshotPath, err = s.commitSnapshot(stagingPath, manifestJSON)
+	if err != nil {
+		os.RemoveAll(stagingPath)
+		return "", nil, err
+	}
+	return snapshotPath, manifest, nil
+}
+
+// receiveFile downloads one file of a snapshot and fsyncs it
+func receiveFile(ctx context.Context, src snapshot.Location, name, path string) error {
+	body, err := src.Backend.Get(ctx, src.Key(name))
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %w", name, err)
+	}
+	defer body.Close()
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", name, err)
+	}
+	if _, err := io.Copy(f, body); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to download %s: %w", name, err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to sync %s: %w", name, err)
+	}
+	return f.Close()
+}
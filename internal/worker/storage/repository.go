@@ -0,0 +1,350 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"xvault/pkg/crypto"
+	"xvault/pkg/snapshot"
+)
+
+// Repository is a per-tenant content-addressed chunk store on the worker's disk.
+// Chunks are compressed and encrypted to the tenant key individually and stored once;
+// snapshots reference them through an encrypted index. A plaintext reference count
+// per chunk ID lets the worker garbage collect without the tenant's private key.
+type Repository struct {
+	root      string
+	chunkKey  []byte
+	publicKey string
+	mu        *sync.Mutex
+	level     zstd.EncoderLevel
+}
+
+// RepositoryStats reports what storing a stream cost
+type RepositoryStats struct {
+	LogicalBytes int64 // plaintext bytes in the stream
+	NewBytes     int64 // encrypted bytes newly written to disk
+	ChunkCount   int
+	NewChunks    int
+}
+
+// repositoryLocks serializes refcount updates per repository root
+var repositoryLocks sync.Map
+
+// Repository returns the chunk repository for a tenant. chunkKey names chunks (see
+// crypto.DeriveChunkKey) and publicKey is the tenant key new chunks are encrypted to.
+func (s *Storage) Repository(tenantID string, chunkKey []byte, publicKey string) *Repository {
+	return openRepository(s.RepositoryPath(tenantID), chunkKey, publicKey)
+}
+
+// RepositoryPath returns the repository root for a tenant
+func (s *Storage) RepositoryPath(tenantID string) string {
+	return filepath.Join(s.basePath, "tenants", tenantID, "repository")
+}
+
+func openRepository(root string, chunkKey []byte, publicKey string) *Repository {
+	mu, _ := repositoryLocks.LoadOrStore(root, &sync.Mutex{})
+	return &Repository{
+		root:      root,
+		chunkKey:  chunkKey,
+		publicKey: publicKey,
+		mu:        mu.(*sync.Mutex),
+		level:     zstd.SpeedDefault,
+	}
+}
+
+// SetCompressionLevel sets the zstd level (1-22) new chunks are compressed with.
+// Chunks are shared between sources, so they are always zstd; only the level varies.
+func (r *Repository) SetCompressionLevel(level int) {
+	r.level = zstd.EncoderLevelFromZstd(level)
+}
+
+// ChunkPath returns the on-disk path of a chunk
+func (r *Repository) ChunkPath(id string) string {
+	return snapshot.ChunkPath(r.root, id)
+}
+
+// StoreStream splits src into content-defined chunks, writes the ones the repository
+// does not have yet and takes one reference on every distinct chunk in the stream.
+// On error, references taken so far are released again.
+func (r *Repository) StoreStream(src io.Reader) (*snapshot.ChunkIndex, *RepositoryStats, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	refs, err := r.loadRefcounts()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	index := &snapshot.ChunkIndex{Version: snapshot.ChunkIndexVersion}
+	stats := &RepositoryStats{}
+	retained := make(map[string]bool)
+
+	fail := func(err error) (*snapshot.ChunkIndex, *RepositoryStats, error) {
+		for id := range retained {
+			refs[id]--
+			if refs[id] <= 0 {
+				delete(refs, id)
+			}
+		}
+		r.saveRefcounts(refs)
+		return nil, nil, err
+	}
+
+	chunker := NewChunker(src)
+	for {
+		chunk, err := chunker.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fail(fmt.Errorf("failed to read stream: %w", err))
+		}
+
+		id := r.chunkID(chunk)
+		index.Chunks = append(index.Chunks, snapshot.ChunkRef{ID: id, Size: int64(len(chunk))})
+		stats.LogicalBytes += int64(len(chunk))
+		stats.ChunkCount++
+
+		if retained[id] {
+			continue
+		}
+
+		if refs[id] == 0 {
+			written, err := r.writeChunk(id, chunk)
+			if err != nil {
+				return fail(err)
+			}
+			stats.NewBytes += written
+			stats.NewChunks++
+		}
+
+		refs[id]++
+		retained[id] = true
+	}
+
+	if err := r.saveRefcounts(refs); err != nil {
+		return fail(err)
+	}
+
+	index.LogicalBytes = stats.LogicalBytes
+	return index, stats, nil
+}
+
+// Release drops one reference on each chunk ID and deletes chunks that are no longer
+// referenced by any snapshot. Returns the number of bytes freed.
+func (r *Repository) Release(ids []string) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	refs, err := r.loadRefcounts()
+	if err != nil {
+		return 0, err
+	}
+
+	var freed int64
+	for _, id := range ids {
+		if refs[id] > 1 {
+			refs[id]--
+			continue
+		}
+		delete(refs, id)
+
+		path := r.ChunkPath(id)
+		if info, err := os.Stat(path); err == nil {
+			freed += info.Size()
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return freed, fmt.Errorf("failed to delete chunk %s: %w", id, err)
+		}
+	}
+
+	if err := r.saveRefcounts(refs); err != nil {
+		return freed, err
+	}
+	return freed, nil
+}
+
+// SealIndex serializes, compresses and encrypts a chunk index to the tenant key
+func (r *Repository) SealIndex(index *snapshot.ChunkIndex) ([]byte, error) {
+	indexJSON, err := json.Marshal(index)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal chunk index: %w", err)
+	}
+
+	sealed, err := r.seal(indexJSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to seal chunk index: %w", err)
+	}
+	return sealed, nil
+}
+
+// chunkID returns the keyed hash naming a chunk
+func (r *Repository) chunkID(chunk []byte) string {
+	mac := hmac.New(sha256.New, r.chunkKey)
+	mac.Write(chunk)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// writeChunk compresses, encrypts and atomically writes a chunk, returning its size on disk
+func (r *Repository) writeChunk(id string, chunk []byte) (int64, error) {
+	sealed, err := r.seal(chunk)
+	if err != nil {
+		return 0, fmt.Errorf("failed to seal chunk %s: %w", id, err)
+	}
+
+	path := r.ChunkPath(id)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, fmt.Errorf("failed to create chunk directory: %w", err)
+	}
+	if err := writeFileAtomic(path, sealed); err != nil {
+		return 0, fmt.Errorf("failed to write chunk %s: %w", id, err)
+	}
+	return int64(len(sealed)), nil
+}
+
+// seal compresses data with zstd and encrypts it to the tenant public key
+func (r *Repository) seal(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	ageWriter, err := crypto.NewEncryptWriter(&buf, r.publicKey)
+	if err != nil {
+		return nil, err
+	}
+	encoder, err := zstd.NewWriter(ageWriter, zstd.WithEncoderLevel(r.level))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create zstd encoder: %w", err)
+	}
+	if _, err := encoder.Write(data); err != nil {
+		encoder.Close()
+		return nil, fmt.Errorf("failed to compress: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress: %w", err)
+	}
+	if err := ageWriter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encrypt: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// refcountsPath is the plaintext chunk reference count table
+func (r *Repository) refcountsPath() string {
+	return filepath.Join(r.root, "refcounts.json")
+}
+
+func (r *Repository) loadRefcounts() (map[string]int, error) {
+	refs := make(map[string]int)
+	data, err := os.ReadFile(r.refcountsPath())
+	if os.IsNotExist(err) {
+		return refs, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read refcounts: %w", err)
+	}
+	if err := json.Unmarshal(data, &refs); err != nil {
+		return nil, fmt.Errorf("failed to parse refcounts: %w", err)
+	}
+	return refs, nil
+}
+
+func (r *Repository) saveRefcounts(refs map[string]int) error {
+	data, err := json.Marshal(refs)
+	if err != nil {
+		return fmt.Errorf("failed to marshal refcounts: %w", err)
+	}
+	if err := os.MkdirAll(r.root, 0755); err != nil {
+		return fmt.Errorf("failed to create repository directory: %w", err)
+	}
+	if err := writeFileAtomic(r.refcountsPath(), data); err != nil {
+		return fmt.Errorf("failed to write refcounts: %w", err)
+	}
+	return nil
+}
+
+// writeFileAtomic writes and fsyncs data in a temporary file next to path and renames
+// it into place
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := writeFileSync(tmp, data); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// WriteRepositorySnapshot writes a repository-mode snapshot: the sealed chunk index,
+// the plaintext list of referenced chunk IDs (used for garbage collection), the sealed
+// file index and the metadata files. Returns the snapshot path and the size of the
+// sealed chunk index. The files are staged and committed like an artifact snapshot's;
+// on failure the staging directory is removed and the snapshot's chunk references
+// released.
+func (s *Storage) WriteRepositorySnapshot(tenantID, sourceID, snapshotID string, sealedIndex []byte, chunkIDs []string, fileIndex, manifest []byte) (string, int64, error) {
+	snapshotPath, err := s.writeRepositorySnapshotFiles(tenantID, sourceID, snapshotID, sealedIndex, chunkIDs, fileIndex, manifest)
+	if err != nil {
+		os.RemoveAll(s.StagingPath(tenantID, sourceID, snapshotID))
+		openRepository(s.RepositoryPath(tenantID), nil, "").Release(chunkIDs)
+		return "", 0, err
+	}
+
+	return snapshotPath, int64(len(sealedIndex)), nil
+}
+
+func (s *Storage) writeRepositorySnapshotFiles(tenantID, sourceID, snapshotID string, sealedIndex []byte, chunkIDs []string, fileIndex, manifest []byte) (string, error) {
+	stagingPath, err := s.createStaging(tenantID, sourceID, snapshotID)
+	if err != nil {
+		return "", err
+	}
+
+	refs := strings.Join(chunkIDs, "\n") + "\n"
+	if err := writeFileSync(filepath.Join(stagingPath, snapshot.ChunkRefsFileName), []byte(refs)); err != nil {
+		return "", fmt.Errorf("failed to write chunk refs: %w", err)
+	}
+
+	if err := writeFileSync(filepath.Join(stagingPath, snapshot.ChunkIndexFileName), sealedIndex); err != nil {
+		return "", fmt.Errorf("failed to write chunk index: %w", err)
+	}
+
+	if err := s.writeSnapshotMetadata(stagingPath, tenantID, sourceID, snapshotID, fileIndex, manifest); err != nil {
+		return "", err
+	}
+
+	return s.commitSnapshot(stagingPath, manifest)
+}
+
+// readChunkRefs reads the chunk IDs referenced by a repository-mode snapshot.
+// ok is false when the snapshot is a standalone artifact.
+func readChunkRefs(snapshotPath string) (ids []string, ok bool, err error) {
+	f, err := os.Open(filepath.Join(snapshotPath, snapshot.ChunkRefsFileName))
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to open chunk refs: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			ids = append(ids, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, false, fmt.Errorf("failed to read chunk refs: %w", err)
+	}
+	return ids, true, nil
+}
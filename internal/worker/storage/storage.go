@@ -1,29 +1,193 @@
 package storage
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+
+	"xvault/pkg/backend"
+	"xvault/pkg/snapshot"
 )
 
-// Storage handles local storage of backup artifacts
+// Storage handles local storage of backup artifacts. Snapshots are always written to
+// local storage first; with an object store configured they are uploaded once
+// complete and the local copy is removed.
 type Storage struct {
-	basePath string
+	basePath   string
+	scratchDir string
+	volumeSize int64
+	local      *backend.Local
+
+	objectStore       *backend.S3
+	objectStoreConfig backend.S3Config
+
+	// Bucket snapshots on local storage are moved to by migrate_snapshot jobs
+	tierStore       *backend.S3
+	tierStoreConfig backend.S3Config
 }
 
 // NewStorage creates a new storage manager
 func NewStorage(basePath string) *Storage {
 	return &Storage{
-		basePath: basePath,
+		basePath:   basePath,
+		scratchDir: defaultScratchDir,
+		local:      backend.NewLocal(basePath),
+	}
+}
+
+// SetScratchDir sets the directory that holds the per-job temporary directories
+// sources are mirrored into before packaging
+func (s *Storage) SetScratchDir(dir string) {
+	s.scratchDir = dir
+}
+
+// SetObjectStore uploads finished snapshots to an S3-compatible bucket
+func (s *Storage) SetObjectStore(config backend.S3Config) error {
+	store, err := backend.NewS3(config)
+	if err != nil {
+		return fmt.Errorf("failed to configure object store: %w", err)
 	}
+	s.objectStore, s.objectStoreConfig = store, config
+	return nil
+}
+
+// ObjectStore returns the bucket finished snapshots are uploaded to, or nil when
+// snapshots stay on local storage
+func (s *Storage) ObjectStore() *backend.S3 {
+	return s.objectStore
+}
+
+// SetTierStore lets migrate_snapshot jobs move snapshots from local storage to an
+// S3-compatible bucket
+func (s *Storage) SetTierStore(config backend.S3Config) error {
+	store, err := backend.NewS3(config)
+	if err != nil {
+		return fmt.Errorf("failed to configure tier store: %w", err)
+	}
+	s.tierStore, s.tierStoreConfig = store, config
+	return nil
+}
+
+// TierStore returns the bucket snapshots are moved to, or nil when tiering is not
+// configured
+func (s *Storage) TierStore() *backend.S3 {
+	return s.tierStore
+}
+
+// SetVolumeSize splits new artifacts into numbered volumes of size bytes; 0 writes
+// each artifact as a single file
+func (s *Storage) SetVolumeSize(size int64) {
+	s.volumeSize = size
+}
+
+// SnapshotKey returns the key prefix of a snapshot's files, relative to the storage
+// base or bucket. Snapshot IDs are generated as plain hex but come back from the hub
+// in UUID form, so dashes are dropped.
+func (s *Storage) SnapshotKey(tenantID, sourceID, snapshotID string) string {
+	return backend.SnapshotPrefix(tenantID, sourceID, strings.ReplaceAll(snapshotID, "-", ""))
 }
 
-// SnapshotPath returns the path for a snapshot
+// SnapshotPath returns the local path for a snapshot
 func (s *Storage) SnapshotPath(tenantID, sourceID, snapshotID string) string {
-	return filepath.Join(s.basePath, "tenants", tenantID, "sources", sourceID, "snapshots", snapshotID)
+	return s.local.Path(s.SnapshotKey(tenantID, sourceID, snapshotID))
+}
+
+// Location returns where this worker stores a snapshot: the object store when one is
+// configured, local storage otherwise
+func (s *Storage) Location(tenantID, sourceID, snapshotID string) snapshot.Location {
+	loc := snapshot.Location{Backend: s.local, Prefix: s.SnapshotKey(tenantID, sourceID, snapshotID)}
+	if s.objectStore != nil {
+		loc.Backend = s.objectStore
+	}
+	return loc
+}
+
+// UploadSnapshot copies a finished snapshot from local storage to the object store
+// and removes the local copy. The manifest is uploaded last, so a snapshot whose
+// manifest is present is complete. It returns the key prefix and the manifest's ETag.
+func (s *Storage) UploadSnapshot(ctx context.Context, tenantID, sourceID, snapshotID string) (string, string, error) {
+	key := s.SnapshotKey(tenantID, sourceID, snapshotID)
+	snapshotPath := s.local.Path(key)
+
+	entries, err := os.ReadDir(snapshotPath)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read snapshot directory: %w", err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Name() != snapshot.ManifestFileName && entry.Type().IsRegular() {
+			names = append(names, entry.Name())
+		}
+	}
+	names = append(names, snapshot.ManifestFileName)
+
+	var etag string
+	for _, name := range names {
+		info, err := s.uploadFile(ctx, filepath.Join(snapshotPath, name), backend.Join(key, name))
+		if err != nil {
+			backend.DeletePrefix(context.WithoutCancel(ctx), s.objectStore, key)
+			return "", "", err
+		}
+		etag = info.ETag
+	}
+
+	if err := os.RemoveAll(snapshotPath); err != nil {
+		return "", "", fmt.Errorf("failed to remove local copy: %w", err)
+	}
+	return key, etag, nil
+}
+
+func (s *Storage) uploadFile(ctx context.Context, path, key string) (backend.ObjectInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return backend.ObjectInfo{}, fmt.Errorf("failed to open %s: %w", filepath.Base(path), err)
+	}
+	defer f.Close()
+
+	info, err := s.objectStore.Put(ctx, key, f)
+	if err != nil {
+		return backend.ObjectInfo{}, fmt.Errorf("failed to upload %s: %w", filepath.Base(path), err)
+	}
+	return info, nil
+}
+
+// DeleteObjects removes a snapshot stored under prefix in an object store bucket.
+// Snapshots uploaded before the worker moved to another bucket are deleted with the
+// same credentials.
+func (s *Storage) DeleteObjects(ctx context.Context, bucket, prefix string) error {
+	store, err := s.bucketStore(bucket)
+	if err != nil {
+		return err
+	}
+
+	if _, err := backend.DeletePrefix(ctx, store, prefix); err != nil {
+		return fmt.Errorf("failed to delete snapshot objects: %w", err)
+	}
+	return nil
+}
+
+// bucketStore returns the object store for a bucket, using the credentials of the
+// configured object store, or else of the tier store, when the bucket is neither
+func (s *Storage) bucketStore(bucket string) (*backend.S3, error) {
+	for _, store := range []*backend.S3{s.objectStore, s.tierStore} {
+		if store != nil && bucket == store.Bucket() {
+			return store, nil
+		}
+	}
+	config := s.objectStoreConfig
+	if s.objectStore == nil {
+		if s.tierStore == nil {
+			return nil, fmt.Errorf("snapshot is in bucket %s but no object store is configured", bucket)
+		}
+		config = s.tierStoreConfig
+	}
+	config.Bucket = bucket
+	return backend.NewS3(config)
 }
 
 // GetSnapshotPath is an alias for SnapshotPath for clarity
@@ -36,50 +200,88 @@ func (s *Storage) GetSnapshotPath(tenantID, sourceID, snapshotID string) (string
 	return path, nil
 }
 
-// WriteSnapshot writes the encrypted backup artifact and metadata to disk
-func (s *Storage) WriteSnapshot(tenantID, sourceID, snapshotID string, artifact []byte, manifest []byte) (string, int64, error) {
-	snapshotPath := s.SnapshotPath(tenantID, sourceID, snapshotID)
+// WriteSnapshot finalizes a snapshot on disk once its artifact has been written and
+// closed: it writes the sealed file index and metadata files next to it in the
+// staging directory, verifies the artifact against the manifest and commits the
+// snapshot. Returns the snapshot directory and the artifact size.
+func (s *Storage) WriteSnapshot(tenantID, sourceID, snapshotID string, artifact *ArtifactWriter, fileIndex, manifest []byte) (string, int64, error) {
+	if err := s.writeSnapshotMetadata(artifact.dir, tenantID, sourceID, snapshotID, fileIndex, manifest); err != nil {
+		return "", 0, err
+	}
 
-	// Create the snapshot directory
-	if err := os.MkdirAll(snapshotPath, 0755); err != nil {
-		return "", 0, fmt.Errorf("failed to create snapshot directory: %w", err)
+	snapshotPath, err := s.commitSnapshot(artifact.dir, manifest)
+	if err != nil {
+		return "", 0, err
 	}
 
-	// Write the encrypted artifact
-	artifactPath := filepath.Join(snapshotPath, "backup.tar.zst.enc")
-	if err := os.WriteFile(artifactPath, artifact, 0644); err != nil {
-		return "", 0, fmt.Errorf("failed to write artifact: %w", err)
+	return snapshotPath, artifact.Size(), nil
+}
+
+// writeSnapshotMetadata writes the sealed file index (if any), manifest.json and
+// meta.json into a snapshot directory
+func (s *Storage) writeSnapshotMetadata(snapshotPath, tenantID, sourceID, snapshotID string, fileIndex, manifest []byte) error {
+	if fileIndex != nil {
+		if err := writeFileSync(filepath.Join(snapshotPath, snapshot.FileIndexFileName), fileIndex); err != nil {
+			return fmt.Errorf("failed to write file index: %w", err)
+		}
 	}
 
 	// Write the manifest
-	manifestPath := filepath.Join(snapshotPath, "manifest.json")
-	if err := os.WriteFile(manifestPath, manifest, 0644); err != nil {
-		return "", 0, fmt.Errorf("failed to write manifest: %w", err)
+	manifestPath := filepath.Join(snapshotPath, snapshot.ManifestFileName)
+	if err := writeFileSync(manifestPath, manifest); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
 	}
 
 	// Write meta.json
 	meta := Meta{
-		TenantID:  tenantID,
-		SourceID:  sourceID,
+		TenantID:   tenantID,
+		SourceID:   sourceID,
 		SnapshotID: snapshotID,
 	}
 	metaJSON, err := json.MarshalIndent(meta, "", "  ")
 	if err != nil {
-		return "", 0, fmt.Errorf("failed to marshal meta: %w", err)
+		return fmt.Errorf("failed to marshal meta: %w", err)
 	}
-	metaPath := filepath.Join(snapshotPath, "meta.json")
-	if err := os.WriteFile(metaPath, metaJSON, 0644); err != nil {
-		return "", 0, fmt.Errorf("failed to write meta: %w", err)
+	metaPath := filepath.Join(snapshotPath, snapshot.MetaFileName)
+	if err := writeFileSync(metaPath, metaJSON); err != nil {
+		return fmt.Errorf("failed to write meta: %w", err)
 	}
 
-	return snapshotPath, int64(len(artifact)), nil
+	return nil
 }
 
-// DeleteSnapshot removes a snapshot from local storage
+// DeleteSnapshot removes a snapshot from local storage, including every volume of a
+// multi-volume artifact and anything still in its staging or re-encryption
+// directories. For repository-mode snapshots the directory is removed first and the
+// chunk references are released afterwards, so an interrupted delete can only leak
+// chunks, never drop ones still in use.
 func (s *Storage) DeleteSnapshot(tenantID, sourceID, snapshotID string) error {
 	snapshotPath := s.SnapshotPath(tenantID, sourceID, snapshotID)
-	if err := os.RemoveAll(snapshotPath); err != nil {
-		return fmt.Errorf("failed to delete snapshot: %w", err)
+	for _, path := range []string{snapshotPath, snapshotPath + StagingSuffix, snapshotPath + RekeySuffix, snapshotPath + RekeyOldSuffix} {
+		if err := s.removeSnapshotDir(tenantID, path); err != nil {
+			return fmt.Errorf("failed to delete snapshot: %w", err)
+		}
+	}
+	return nil
+}
+
+// removeSnapshotDir removes a snapshot or staging directory and releases the chunks it
+// references
+func (s *Storage) removeSnapshotDir(tenantID, path string) error {
+	chunkIDs, isRepository, err := readChunkRefs(path)
+	if err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(path); err != nil {
+		return err
+	}
+
+	if isRepository {
+		repo := openRepository(s.RepositoryPath(tenantID), nil, "")
+		if _, err := repo.Release(chunkIDs); err != nil {
+			return fmt.Errorf("failed to release snapshot chunks: %w", err)
+		}
 	}
 	return nil
 }
@@ -92,9 +294,13 @@ func (s *Storage) CleanupTempDir(tempDir string) error {
 	return nil
 }
 
-// CreateTempDir creates a temporary directory for a job
+// defaultScratchDir holds the per-job temporary directories unless SetScratchDir
+// moves them
+var defaultScratchDir = filepath.Join("/tmp", "gobackup")
+
+// CreateTempDir creates a temporary directory for a job in the scratch directory
 func (s *Storage) CreateTempDir(jobID string) (string, error) {
-	tempDir := filepath.Join("/tmp", "gobackup", jobID)
+	tempDir := filepath.Join(s.scratchDir, jobID)
 	if err := os.MkdirAll(tempDir, 0755); err != nil {
 		return "", fmt.Errorf("failed to create temp directory: %w", err)
 	}
@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"xvault/pkg/backend"
+	"xvault/pkg/snapshot"
+	"xvault/pkg/types"
+)
+
+// MigrateSnapshot copies a snapshot from local storage to the tier store and checks
+// the copy against the manifest's hashes. The local copy is kept, to be removed with
+// DeleteSnapshot once the hub has recorded the move; a copy that fails the check is
+// removed. Returns the manifest, the key prefix and the manifest's ETag.
+func (s *Storage) MigrateSnapshot(ctx context.Context, tenantID, sourceID, snapshotID string) (*types.SnapshotManifest, string, string, error) {
+	if s.tierStore == nil {
+		return nil, "", "", fmt.Errorf("no tier store is configured")
+	}
+	key := s.SnapshotKey(tenantID, sourceID, snapshotID)
+
+	manifest, err := PushSnapshot(ctx, s.LocalLocation(tenantID, sourceID, snapshotID), s.tierStore, key)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	if _, err := VerifySnapshot(ctx, snapshot.Location{Backend: s.tierStore, Prefix: key}, manifest, ""); err != nil {
+		backend.DeletePrefix(context.WithoutCancel(ctx), s.tierStore, key)
+		return nil, "", "", fmt.Errorf("copy failed verification: %w", err)
+	}
+	info, err := s.tierStore.Stat(ctx, backend.Join(key, snapshot.ManifestFileName))
+	if err != nil {
+		backend.DeletePrefix(context.WithoutCancel(ctx), s.tierStore, key)
+		return nil, "", "", fmt.Errorf("failed to stat copied manifest: %w", err)
+	}
+	return manifest, key, info.ETag, nil
+}
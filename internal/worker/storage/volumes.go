@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"os"
+	"path/filepath"
+
+	"xvault/pkg/snapshot"
+	"xvault/pkg/types"
+)
+
+// ArtifactWriter writes the encrypted artifact of a snapshot into its staging directory. With
+// a volume size it cuts the stream into numbered volumes of exactly that many bytes
+// (the last may be shorter) and hashes each one; otherwise it writes a single
+// backup.tar.zst.enc.
+type ArtifactWriter struct {
+	dir        string
+	volumeSize int64
+
+	file    *os.File
+	hasher  hash.Hash
+	written int64 // bytes in the current file
+	size    int64 // bytes in all files
+	volumes []types.ArtifactVolume
+	paths   []string
+}
+
+// CreateArtifact creates the snapshot's staging directory and returns a writer for its
+// artifact, split into volumes of the configured volume size. WriteSnapshot moves the
+// staged files into place.
+func (s *Storage) CreateArtifact(tenantID, sourceID, snapshotID string) (*ArtifactWriter, error) {
+	stagingPath, err := s.createStaging(tenantID, sourceID, snapshotID)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &ArtifactWriter{dir: stagingPath, volumeSize: s.volumeSize}
+	if a.volumeSize == 0 {
+		if err := a.open(snapshot.ArtifactFileName); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// open starts the next file of the artifact
+func (a *ArtifactWriter) open(name string) error {
+	path := filepath.Join(a.dir, name)
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create artifact file: %w", err)
+	}
+	a.paths = append(a.paths, path)
+	a.file = file
+	a.hasher = sha256.New()
+	a.written = 0
+	return nil
+}
+
+// finish flushes and closes the current volume and records its size and hash
+func (a *ArtifactWriter) finish() error {
+	if err := a.file.Sync(); err != nil {
+		a.file.Close()
+		return fmt.Errorf("failed to sync artifact file: %w", err)
+	}
+	if err := a.file.Close(); err != nil {
+		return fmt.Errorf("failed to close artifact file: %w", err)
+	}
+	if a.volumeSize > 0 {
+		a.volumes = append(a.volumes, types.ArtifactVolume{
+			Name:      filepath.Base(a.file.Name()),
+			SizeBytes: a.written,
+			SHA256:    hex.EncodeToString(a.hasher.Sum(nil)),
+		})
+	}
+	a.file = nil
+	return nil
+}
+
+func (a *ArtifactWriter) Write(p []byte) (int, error) {
+	n := 0
+	for len(p) > 0 {
+		if a.file == nil {
+			if err := a.open(snapshot.ArtifactVolumeName(len(a.volumes) + 1)); err != nil {
+				return n, err
+			}
+		}
+
+		chunk := p
+		if a.volumeSize > 0 {
+			chunk = p[:min(int64(len(p)), a.volumeSize-a.written)]
+		}
+		m, err := a.file.Write(chunk)
+		a.hasher.Write(chunk[:m])
+		a.written += int64(m)
+		a.size += int64(m)
+		n += m
+		if err != nil {
+			return n, err
+		}
+		p = p[m:]
+
+		if a.volumeSize > 0 && a.written == a.volumeSize {
+			if err := a.finish(); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// Close closes the last file of the artifact
+func (a *ArtifactWriter) Close() error {
+	if a.file == nil {
+		return nil
+	}
+	return a.finish()
+}
+
+// Abort closes and removes everything written so far
+func (a *ArtifactWriter) Abort() {
+	if a.file != nil {
+		a.file.Close()
+		a.file = nil
+	}
+	for _, path := range a.paths {
+		os.Remove(path)
+	}
+}
+
+// VolumeSize returns the configured volume size, 0 for a single-file artifact
+func (a *ArtifactWriter) VolumeSize() int64 {
+	return a.volumeSize
+}
+
+// Volumes returns the closed volumes in order; nil for a single-file artifact
+func (a *ArtifactWriter) Volumes() []types.ArtifactVolume {
+	return a.volumes
+}
+
+// Size returns the number of bytes written across all files
+func (a *ArtifactWriter) Size() int64 {
+	return a.size
+}
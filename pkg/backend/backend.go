@@ -0,0 +1,179 @@
+// Package backend stores snapshot files as objects addressed by slash-separated keys,
+// either on a local filesystem or in an S3-compatible bucket. The worker writes
+// through it and the worker, delete jobs and the restore service read through it,
+// picking the implementation from a snapshot's locator.
+package backend
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// Backend is an object store for snapshot files. Missing objects are reported with
+// errors that wrap fs.ErrNotExist.
+type Backend interface {
+	// Put stores the content of r under key, replacing any existing object
+	Put(ctx context.Context, key string, r io.Reader) (ObjectInfo, error)
+	// Get returns the content of the object at key
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// GetRange returns length bytes of the object at key starting at offset
+	GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error)
+	// Stat returns the size and ETag of the object at key
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+	// Delete removes the object at key; deleting a missing object is not an error
+	Delete(ctx context.Context, key string) error
+	// List returns the objects whose keys start with prefix, sorted by key
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+}
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Key     string
+	Size    int64
+	ETag    string // empty for backends without ETags
+	ModTime time.Time
+}
+
+// SnapshotPrefix is the key prefix of a snapshot's files
+func SnapshotPrefix(tenantID, sourceID, snapshotID string) string {
+	return path.Join("tenants", tenantID, "sources", sourceID, "snapshots", snapshotID)
+}
+
+// Join builds a key from a prefix and a name
+func Join(prefix, name string) string {
+	return path.Join(prefix, name)
+}
+
+// ReadAll reads a whole object into memory
+func ReadAll(ctx context.Context, b Backend, key string) ([]byte, error) {
+	r, err := b.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// DeletePrefix removes every object whose key starts with prefix/ and returns how
+// many bytes were freed
+func DeletePrefix(ctx context.Context, b Backend, prefix string) (int64, error) {
+	objects, err := b.List(ctx, strings.TrimSuffix(prefix, "/")+"/")
+	if err != nil {
+		return 0, err
+	}
+	var freed int64
+	for _, object := range objects {
+		if err := b.Delete(ctx, object.Key); err != nil {
+			return freed, err
+		}
+		freed += object.Size
+	}
+	return freed, nil
+}
+
+// validKey rejects keys that are empty, absolute or escape the store
+func validKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || path.Clean(key) != key || key == ".." || strings.HasPrefix(key, "../") {
+		return fmt.Errorf("invalid object key %q", key)
+	}
+	return nil
+}
+
+// ReadAtCloser gives random access to an object
+type ReadAtCloser interface {
+	io.ReaderAt
+	io.Closer
+}
+
+// OpenReaderAt opens the object at key for random access and returns its size. Local
+// objects are read from their files; other backends use range requests, reading
+// ahead so that small sequential reads do not each cost a request.
+func OpenReaderAt(ctx context.Context, b Backend, key string) (ReadAtCloser, int64, error) {
+	if local, ok := b.(*Local); ok {
+		if err := validKey(key); err != nil {
+			return nil, 0, err
+		}
+		f, err := os.Open(local.Path(key))
+		if err != nil {
+			return nil, 0, err
+		}
+		info, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, 0, err
+		}
+		return f, info.Size(), nil
+	}
+
+	info, err := b.Stat(ctx, key)
+	if err != nil {
+		return nil, 0, err
+	}
+	return &rangeReader{ctx: ctx, backend: b, key: key, size: info.Size}, info.Size, nil
+}
+
+// readAhead is the smallest range rangeReader requests
+const readAhead = 4 << 20
+
+// rangeReader reads an object with range requests, keeping the last range fetched
+type rangeReader struct {
+	ctx     context.Context
+	backend Backend
+	key     string
+	size    int64
+
+	bufOffset int64
+	buf       []byte
+}
+
+func (r *rangeReader) ReadAt(p []byte, off int64) (int, error) {
+	n := 0
+	for len(p) > 0 {
+		if off >= r.size {
+			return n, io.EOF
+		}
+		if off < r.bufOffset || off >= r.bufOffset+int64(len(r.buf)) {
+			if err := r.fetch(off, max(int64(len(p)), readAhead)); err != nil {
+				return n, err
+			}
+		}
+		m := copy(p, r.buf[off-r.bufOffset:])
+		n += m
+		off += int64(m)
+		p = p[m:]
+	}
+	return n, nil
+}
+
+// fetch replaces the buffer with up to length bytes starting at off
+func (r *rangeReader) fetch(off, length int64) error {
+	length = min(length, r.size-off)
+	body, err := r.backend.GetRange(r.ctx, r.key, off, length)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(body, buf); err != nil {
+		return fmt.Errorf("failed to read %s: %w", r.key, err)
+	}
+	r.bufOffset, r.buf = off, buf
+	return nil
+}
+
+func (r *rangeReader) Close() error {
+	r.buf = nil
+	return nil
+}
+
+// notExist wraps fs.ErrNotExist for a key
+func notExist(key string) error {
+	return fmt.Errorf("object %s: %w", key, fs.ErrNotExist)
+}
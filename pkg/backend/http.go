@@ -0,0 +1,267 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// errReadOnly is returned by the write methods of HTTP
+var errReadOnly = errors.New("http backend is read-only")
+
+// HTTP reads objects that another process serves with Handler. It is read-only:
+// workers use it to pull a snapshot's files from the worker holding them. Requests
+// carry the token as a bearer token.
+type HTTP struct {
+	base   *url.URL
+	token  string
+	client *http.Client
+}
+
+// NewHTTP returns a backend for the objects served under baseURL
+func NewHTTP(baseURL, token string) (*HTTP, error) {
+	base, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil || base.Scheme == "" || base.Host == "" {
+		return nil, fmt.Errorf("invalid backend url %q", baseURL)
+	}
+	return &HTTP{base: base, token: token, client: &http.Client{}}, nil
+}
+
+// Put is not supported
+func (h *HTTP) Put(ctx context.Context, key string, r io.Reader) (ObjectInfo, error) {
+	return ObjectInfo{}, errReadOnly
+}
+
+// Get downloads the object
+func (h *HTTP) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	if err := validKey(key); err != nil {
+		return nil, err
+	}
+	resp, err := h.do(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// GetRange downloads a byte range of the object
+func (h *HTTP) GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	if err := validKey(key); err != nil {
+		return nil, err
+	}
+	if length <= 0 {
+		return io.NopCloser(bytes.NewReader(nil)), nil
+	}
+	header := http.Header{"Range": {fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)}}
+	resp, err := h.do(ctx, http.MethodGet, key, header)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to read range of %s: server ignored the range", key)
+	}
+	return resp.Body, nil
+}
+
+// Stat returns the object's size
+func (h *HTTP) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	if err := validKey(key); err != nil {
+		return ObjectInfo{}, err
+	}
+	resp, err := h.do(ctx, http.MethodHead, key, nil)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	resp.Body.Close()
+	return ObjectInfo{Key: key, Size: resp.ContentLength}, nil
+}
+
+// Delete is not supported
+func (h *HTTP) Delete(ctx context.Context, key string) error {
+	return errReadOnly
+}
+
+// List returns the served objects whose keys start with prefix
+func (h *HTTP) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	resp, err := h.do(ctx, http.MethodGet, "", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", prefix, err)
+	}
+	defer resp.Body.Close()
+
+	var listed []httpObject
+	if err := json.NewDecoder(resp.Body).Decode(&listed); err != nil {
+		return nil, fmt.Errorf("failed to parse listing of %s: %w", prefix, err)
+	}
+
+	var objects []ObjectInfo
+	for _, object := range listed {
+		if strings.HasPrefix(object.Key, prefix) {
+			objects = append(objects, ObjectInfo{Key: object.Key, Size: object.Size})
+		}
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+// do sends a request for key (the listing when key is empty) and turns error
+// statuses into errors; 404s wrap fs.ErrNotExist
+func (h *HTTP) do(ctx context.Context, method, key string, header http.Header) (*http.Response, error) {
+	u := *h.base
+	u.Path += "/" + key
+	u.RawPath = h.base.EscapedPath() + "/" + escapePath(key)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if h.token != "" {
+		req.Header.Set("Authorization", "Bearer "+h.token)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, notExist(key)
+		}
+		return nil, fmt.Errorf("%s %s: %s: %s", method, key, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+// httpObject is an entry of the listing Handler serves
+type httpObject struct {
+	Key  string `json:"key"`
+	Size int64  `json:"size"`
+}
+
+// NewHandler serves the objects of b under prefix read-only, for HTTP to read: a
+// GET of the root lists them with keys relative to prefix, and GET (with an optional
+// single Range) or HEAD of /<key> reads one. Callers authorize requests before
+// passing them on.
+func NewHandler(b Backend, prefix string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		name := strings.TrimPrefix(r.URL.Path, "/")
+		if name == "" {
+			serveListing(w, r, b, prefix)
+			return
+		}
+		if err := validKey(name); err != nil {
+			http.Error(w, "invalid object key", http.StatusBadRequest)
+			return
+		}
+		serveObject(w, r, b, Join(prefix, name))
+	})
+}
+
+// serveListing writes the objects under prefix as JSON
+func serveListing(w http.ResponseWriter, r *http.Request, b Backend, prefix string) {
+	dir := prefix
+	if dir != "" {
+		dir = strings.TrimSuffix(dir, "/") + "/"
+	}
+	objects, err := b.List(r.Context(), dir)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	listed := make([]httpObject, 0, len(objects))
+	for _, object := range objects {
+		listed = append(listed, httpObject{Key: strings.TrimPrefix(object.Key, dir), Size: object.Size})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(listed)
+}
+
+// serveObject writes one object, or the range of it the request asks for
+func serveObject(w http.ResponseWriter, r *http.Request, b Backend, key string) {
+	ctx := r.Context()
+	info, err := b.Stat(ctx, key)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			http.Error(w, "object not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	status := http.StatusOK
+	offset, length := int64(0), info.Size
+	if spec := r.Header.Get("Range"); spec != "" {
+		var ok bool
+		if offset, length, ok = parseRange(spec, info.Size); !ok {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", info.Size))
+			http.Error(w, "invalid range", http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		status = http.StatusPartialContent
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, offset+length-1, info.Size))
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.FormatInt(length, 10))
+	w.Header().Set("Accept-Ranges", "bytes")
+	if r.Method == http.MethodHead {
+		w.WriteHeader(status)
+		return
+	}
+
+	var body io.ReadCloser
+	if status == http.StatusPartialContent {
+		body, err = b.GetRange(ctx, key, offset, length)
+	} else {
+		body, err = b.Get(ctx, key)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer body.Close()
+
+	w.WriteHeader(status)
+	io.Copy(w, body)
+}
+
+// parseRange parses a single "bytes=first-last" range within an object of size bytes
+func parseRange(spec string, size int64) (offset, length int64, ok bool) {
+	first, last, found := strings.Cut(strings.TrimPrefix(spec, "bytes="), "-")
+	if !found || !strings.HasPrefix(spec, "bytes=") {
+		return 0, 0, false
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 || start >= size {
+		return 0, 0, false
+	}
+	end := size - 1
+	if last != "" {
+		if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < start {
+			return 0, 0, false
+		}
+		end = min(end, size-1)
+	}
+	return start, end - start + 1, true
+}
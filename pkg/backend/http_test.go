@@ -0,0 +1,98 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestHTTP serves the objects under prefix of a local backend with NewHandler,
+// behind a check for the bearer token, and returns a client for them
+func newTestHTTP(t *testing.T, token string) (*HTTP, *Local) {
+	t.Helper()
+	local := NewLocal(t.TempDir())
+	handler := NewHandler(local, "snapshots/s1")
+	srv := httptest.NewServer(http.StripPrefix("/files", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})))
+	t.Cleanup(srv.Close)
+
+	h, err := NewHTTP(srv.URL+"/files", token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h, local
+}
+
+func TestHTTP(t *testing.T) {
+	ctx := context.Background()
+	h, local := newTestHTTP(t, "secret")
+	for key, data := range map[string]string{
+		"snapshots/s1/manifest.json":      `{"version":1}`,
+		"snapshots/s1/backup.tar.zst.age": "0123456789",
+		"snapshots/s2/manifest.json":      "other snapshot",
+	} {
+		if _, err := local.Put(ctx, key, strings.NewReader(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	objects, err := h.List(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 2 || objects[0].Key != "backup.tar.zst.age" || objects[0].Size != 10 || objects[1].Key != "manifest.json" {
+		t.Fatalf("List = %+v, want the two objects of s1 relative to its prefix", objects)
+	}
+
+	data, err := ReadAll(ctx, h, "manifest.json")
+	if err != nil || string(data) != `{"version":1}` {
+		t.Errorf("Get = %q, %v", data, err)
+	}
+
+	body, err := h.GetRange(ctx, "backup.tar.zst.age", 3, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ = io.ReadAll(body)
+	body.Close()
+	if string(data) != "3456" {
+		t.Errorf("GetRange = %q, want %q", data, "3456")
+	}
+
+	info, err := h.Stat(ctx, "backup.tar.zst.age")
+	if err != nil || info.Size != 10 {
+		t.Errorf("Stat = %+v, %v; want size 10", info, err)
+	}
+
+	if _, err := h.Get(ctx, "missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Get of a missing object = %v, want fs.ErrNotExist", err)
+	}
+	if _, err := h.Stat(ctx, "../s2/manifest.json"); err == nil {
+		t.Error("Stat read an object outside the served prefix")
+	}
+	if _, err := h.Put(ctx, "new", strings.NewReader("x")); err == nil {
+		t.Error("Put succeeded on a read-only backend")
+	}
+}
+
+func TestHTTPRejectsWrongToken(t *testing.T) {
+	h, local := newTestHTTP(t, "secret")
+	if _, err := local.Put(context.Background(), "snapshots/s1/manifest.json", strings.NewReader("{}")); err != nil {
+		t.Fatal(err)
+	}
+
+	h.token = "guess"
+	if _, err := h.Get(context.Background(), "manifest.json"); err == nil || errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Get with a wrong token = %v, want an authorization error", err)
+	}
+}
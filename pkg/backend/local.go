@@ -0,0 +1,163 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Local stores objects as files below a root directory; keys map to relative paths
+type Local struct {
+	root string
+}
+
+// NewLocal returns a backend rooted at root
+func NewLocal(root string) *Local {
+	return &Local{root: root}
+}
+
+// Root returns the directory objects are stored in
+func (l *Local) Root() string {
+	return l.root
+}
+
+// Path returns the file path of the object at key
+func (l *Local) Path(key string) string {
+	return filepath.Join(l.root, filepath.FromSlash(key))
+}
+
+// Put writes the object to a temporary file next to its final path and renames it
+// into place, so readers never see a partial object
+func (l *Local) Put(ctx context.Context, key string, r io.Reader) (ObjectInfo, error) {
+	if err := validKey(key); err != nil {
+		return ObjectInfo{}, err
+	}
+	target := l.Path(key)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return ObjectInfo{}, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".*")
+	if err != nil {
+		return ObjectInfo{}, fmt.Errorf("failed to create %s: %w", key, err)
+	}
+	size, err := io.Copy(f, r)
+	if err == nil {
+		err = f.Close()
+	} else {
+		f.Close()
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), target)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return ObjectInfo{}, fmt.Errorf("failed to write %s: %w", key, err)
+	}
+	return ObjectInfo{Key: key, Size: size}, nil
+}
+
+// Get opens the object for reading
+func (l *Local) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	if err := validKey(key); err != nil {
+		return nil, err
+	}
+	return os.Open(l.Path(key))
+}
+
+// GetRange returns a section of the object
+func (l *Local) GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	if err := validKey(key); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(l.Path(key))
+	if err != nil {
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.NewSectionReader(f, offset, length), f}, nil
+}
+
+// Stat returns the object's size and modification time
+func (l *Local) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	if err := validKey(key); err != nil {
+		return ObjectInfo{}, err
+	}
+	info, err := os.Stat(l.Path(key))
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	if info.IsDir() {
+		return ObjectInfo{}, notExist(key)
+	}
+	return ObjectInfo{Key: key, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+// Delete removes the object and any directories it leaves empty
+func (l *Local) Delete(ctx context.Context, key string) error {
+	if err := validKey(key); err != nil {
+		return err
+	}
+	if err := os.Remove(l.Path(key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete %s: %w", key, err)
+	}
+	for dir := filepath.Dir(l.Path(key)); dir != l.root && strings.HasPrefix(dir, l.root); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// List walks the directory holding prefix and returns the files whose keys match
+func (l *Local) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	// Walk from the deepest directory the prefix names completely
+	dir := ""
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		dir = prefix[:i]
+	}
+
+	var objects []ObjectInfo
+	err := filepath.WalkDir(l.Path(dir), func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(l.root, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, ObjectInfo{Key: key, Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", prefix, err)
+	}
+
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
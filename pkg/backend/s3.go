@@ -0,0 +1,462 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultPartSize is the multipart upload part size used when none is configured
+const DefaultPartSize = 64 << 20
+
+// minPartSize is the smallest part S3 accepts, except for the last one
+const minPartSize = 5 << 20
+
+// S3Config configures an S3-compatible backend
+type S3Config struct {
+	// Endpoint is the service URL, e.g. https://s3.eu-west-1.amazonaws.com or
+	// http://minio:9000
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// PathStyle addresses the bucket as endpoint/bucket/key rather than
+	// bucket.endpoint/key; MinIO and most self-hosted stores need it
+	PathStyle bool
+	// PartSize is the multipart upload part size; objects smaller than one part are
+	// uploaded with a single PUT
+	PartSize int64
+}
+
+// S3 stores objects in an S3-compatible bucket. Requests are signed with AWS
+// Signature Version 4 and payloads are hashed, so the service can reject corrupted
+// uploads.
+type S3 struct {
+	config   S3Config
+	endpoint *url.URL
+	client   *http.Client
+}
+
+// NewS3 returns a backend for the bucket in config
+func NewS3(config S3Config) (*S3, error) {
+	if config.Bucket == "" {
+		return nil, fmt.Errorf("s3 bucket is required")
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	if config.PartSize == 0 {
+		config.PartSize = DefaultPartSize
+	}
+	if config.PartSize < minPartSize {
+		return nil, fmt.Errorf("s3 part size %d is below the minimum of %d bytes", config.PartSize, minPartSize)
+	}
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil || endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint %q", config.Endpoint)
+	}
+	return &S3{config: config, endpoint: endpoint, client: &http.Client{}}, nil
+}
+
+// Bucket returns the bucket objects are stored in
+func (s *S3) Bucket() string {
+	return s.config.Bucket
+}
+
+// Put uploads the object, using a multipart upload once it exceeds one part
+func (s *S3) Put(ctx context.Context, key string, r io.Reader) (ObjectInfo, error) {
+	if err := validKey(key); err != nil {
+		return ObjectInfo{}, err
+	}
+
+	part := make([]byte, s.config.PartSize)
+	n, err := io.ReadFull(r, part)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		etag, err := s.putObject(ctx, key, part[:n])
+		if err != nil {
+			return ObjectInfo{}, err
+		}
+		return ObjectInfo{Key: key, Size: int64(n), ETag: etag}, nil
+	}
+	if err != nil {
+		return ObjectInfo{}, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+
+	return s.putMultipart(ctx, key, part, r)
+}
+
+func (s *S3) putObject(ctx context.Context, key string, data []byte) (string, error) {
+	resp, err := s.do(ctx, http.MethodPut, key, nil, nil, data)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload %s: %w", key, err)
+	}
+	resp.Body.Close()
+	return trimETag(resp.Header.Get("ETag")), nil
+}
+
+// putMultipart uploads first and the rest of r as the parts of a multipart upload.
+// The upload is aborted on failure so no parts are left billed in the bucket.
+func (s *S3) putMultipart(ctx context.Context, key string, first []byte, r io.Reader) (ObjectInfo, error) {
+	uploadID, err := s.createMultipartUpload(ctx, key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	var parts []completedPart
+	var size int64
+	fail := func(err error) (ObjectInfo, error) {
+		s.abortMultipartUpload(context.WithoutCancel(ctx), key, uploadID)
+		return ObjectInfo{}, err
+	}
+
+	buf := first
+	for number := 1; ; number++ {
+		query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {uploadID}}
+		resp, err := s.do(ctx, http.MethodPut, key, query, nil, buf)
+		if err != nil {
+			return fail(fmt.Errorf("failed to upload part %d of %s: %w", number, key, err))
+		}
+		resp.Body.Close()
+		parts = append(parts, completedPart{PartNumber: number, ETag: resp.Header.Get("ETag")})
+		size += int64(len(buf))
+
+		if len(buf) < len(first) {
+			break
+		}
+		buf = first[:cap(first)]
+		n, err := io.ReadFull(r, buf)
+		if err == io.EOF {
+			break
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return fail(fmt.Errorf("failed to read %s: %w", key, err))
+		}
+		buf = buf[:n]
+	}
+
+	body, err := xml.Marshal(completeMultipartUpload{Parts: parts})
+	if err != nil {
+		return fail(fmt.Errorf("failed to encode part list: %w", err))
+	}
+	resp, err := s.do(ctx, http.MethodPost, key, url.Values{"uploadId": {uploadID}}, nil, body)
+	if err != nil {
+		return fail(fmt.Errorf("failed to complete upload of %s: %w", key, err))
+	}
+	defer resp.Body.Close()
+
+	// S3 can report a failed completion with a 200 status and an error document
+	var result completeMultipartUploadResult
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fail(fmt.Errorf("failed to complete upload of %s: %w", key, err))
+	}
+	if result.XMLName.Local == "Error" || result.ETag == "" {
+		return fail(fmt.Errorf("failed to complete upload of %s: %s", key, result.Message))
+	}
+
+	return ObjectInfo{Key: key, Size: size, ETag: trimETag(result.ETag)}, nil
+}
+
+func (s *S3) createMultipartUpload(ctx context.Context, key string) (string, error) {
+	resp, err := s.do(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, nil, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to start upload of %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil || result.UploadID == "" {
+		return "", fmt.Errorf("failed to start upload of %s: no upload ID in response", key)
+	}
+	return result.UploadID, nil
+}
+
+func (s *S3) abortMultipartUpload(ctx context.Context, key, uploadID string) {
+	if resp, err := s.do(ctx, http.MethodDelete, key, url.Values{"uploadId": {uploadID}}, nil, nil); err == nil {
+		resp.Body.Close()
+	}
+}
+
+// Get downloads the object
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	if err := validKey(key); err != nil {
+		return nil, err
+	}
+	resp, err := s.do(ctx, http.MethodGet, key, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// GetRange downloads a byte range of the object
+func (s *S3) GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	if err := validKey(key); err != nil {
+		return nil, err
+	}
+	if length <= 0 {
+		return io.NopCloser(bytes.NewReader(nil)), nil
+	}
+	header := http.Header{"Range": {fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)}}
+	resp, err := s.do(ctx, http.MethodGet, key, nil, header, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Stat returns the object's size and ETag
+func (s *S3) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	if err := validKey(key); err != nil {
+		return ObjectInfo{}, err
+	}
+	resp, err := s.do(ctx, http.MethodHead, key, nil, nil, nil)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	resp.Body.Close()
+
+	modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return ObjectInfo{
+		Key:     key,
+		Size:    resp.ContentLength,
+		ETag:    trimETag(resp.Header.Get("ETag")),
+		ModTime: modTime,
+	}, nil
+}
+
+// Delete removes the object
+func (s *S3) Delete(ctx context.Context, key string) error {
+	if err := validKey(key); err != nil {
+		return err
+	}
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete %s: %w", key, err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// List returns the objects under prefix, following continuation tokens
+func (s *S3) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := s.do(ctx, http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", prefix, err)
+		}
+		var result listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse listing of %s: %w", prefix, err)
+		}
+
+		for _, c := range result.Contents {
+			objects = append(objects, ObjectInfo{Key: c.Key, Size: c.Size, ETag: trimETag(c.ETag), ModTime: c.LastModified})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+// do sends a signed request for key (the bucket itself when key is empty) and turns
+// error statuses into errors; 404s wrap fs.ErrNotExist
+func (s *S3) do(ctx context.Context, method, key string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	u := *s.endpoint
+	escapedKey := escapePath(key)
+	if s.config.PathStyle {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.config.Bucket
+		if key != "" {
+			u.Path += "/" + key
+			u.RawPath = strings.TrimSuffix(s.endpoint.EscapedPath(), "/") + "/" + escapePath(s.config.Bucket) + "/" + escapedKey
+		}
+	} else {
+		u.Host = s.config.Bucket + "." + u.Host
+		u.Path = "/" + key
+		u.RawPath = "/" + escapedKey
+	}
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	s.sign(req, body, time.Now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			if method == http.MethodDelete {
+				return resp, nil
+			}
+			return nil, notExist(key)
+		}
+		var s3err struct {
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+		}
+		xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&s3err)
+		return nil, fmt.Errorf("s3 %s %s: %s %s %s", method, key, resp.Status, s3err.Code, s3err.Message)
+	}
+	return resp, nil
+}
+
+// sign adds AWS Signature Version 4 headers to req
+func (s *S3) sign(req *http.Request, body []byte, now time.Time) {
+	payloadHash := sha256Hex(body)
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	// Every header set so far is signed
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		names = append(names, strings.ToLower(name))
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(req.Header.Get(name)) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.config.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+s.config.SecretAccessKey), date)
+	key = hmacSHA256(key, s.config.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.config.AccessKeyID, scope, signedHeaders, signature))
+	req.Header.Del("Host")
+	req.Host = req.URL.Host
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// escapePath URI-encodes each segment of a key as SigV4 requires
+func escapePath(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = uriEncode(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// canonicalQuery encodes query parameters sorted by name, as SigV4 requires
+func canonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var parts []string
+	for _, name := range names {
+		for _, value := range query[name] {
+			parts = append(parts, uriEncode(name)+"="+uriEncode(value))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode percent-encodes everything except unreserved characters
+func uriEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func trimETag(etag string) string {
+	return strings.Trim(etag, `"`)
+}
+
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+type completeMultipartUpload struct {
+	XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+	Parts   []completedPart `xml:"Part"`
+}
+
+type completeMultipartUploadResult struct {
+	XMLName xml.Name
+	ETag    string `xml:"ETag"`
+	Message string `xml:"Message"`
+}
+
+type listBucketResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		ETag         string    `xml:"ETag"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
@@ -0,0 +1,350 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeS3 is an in-process stand-in for an S3-compatible service. It implements the
+// subset of the API the backend uses, checks that requests carry a SigV4
+// authorization and a matching payload hash, and lists at most two keys per page so
+// pagination is exercised.
+type fakeS3 struct {
+	mu      sync.Mutex
+	bucket  string
+	objects map[string][]byte
+	etags   map[string]string
+	uploads map[string]map[int][]byte
+	nextID  int
+	aborted int
+}
+
+func newFakeS3(bucket string) *fakeS3 {
+	return &fakeS3{
+		bucket:  bucket,
+		objects: make(map[string][]byte),
+		etags:   make(map[string]string),
+		uploads: make(map[string]map[int][]byte),
+	}
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=test-key/") {
+		http.Error(w, "missing signature", http.StatusForbidden)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	sum := sha256.Sum256(body)
+	if r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+		http.Error(w, "payload hash mismatch", http.StatusBadRequest)
+		return
+	}
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != f.bucket {
+		http.Error(w, "no such bucket", http.StatusNotFound)
+		return
+	}
+	query := r.URL.Query()
+
+	switch {
+	case r.Method == http.MethodGet && key == "":
+		f.list(w, query)
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		f.nextID++
+		id := fmt.Sprintf("upload-%d", f.nextID)
+		f.uploads[id] = make(map[int][]byte)
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>", id)
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		parts, ok := f.uploads[query.Get("uploadId")]
+		if !ok {
+			http.Error(w, "no such upload", http.StatusNotFound)
+			return
+		}
+		number, _ := strconv.Atoi(query.Get("partNumber"))
+		parts[number] = body
+		w.Header().Set("ETag", etag(body))
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		f.complete(w, key, query.Get("uploadId"), body)
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		delete(f.uploads, query.Get("uploadId"))
+		f.aborted++
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut:
+		f.objects[key] = body
+		f.etags[key] = etag(body)
+		w.Header().Set("ETag", f.etags[key])
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		data, ok := f.objects[key]
+		if !ok {
+			http.Error(w, "no such key", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("ETag", f.etags[key])
+		if rng := r.Header.Get("Range"); rng != "" {
+			var start, end int
+			fmt.Sscanf(rng, "bytes=%d-%d", &start, &end)
+			end = min(end, len(data)-1)
+			w.Header().Set("Content-Length", strconv.Itoa(end-start+1))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(data[start : end+1])
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		if r.Method == http.MethodGet {
+			w.Write(data)
+		}
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "unsupported", http.StatusNotImplemented)
+	}
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, query map[string][]string) {
+	prefix := first(query["prefix"])
+	var keys []string
+	for key := range f.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	start := 0
+	if token := first(query["continuation-token"]); token != "" {
+		start, _ = strconv.Atoi(token)
+	}
+	end := min(start+2, len(keys))
+
+	var b strings.Builder
+	b.WriteString("<ListBucketResult>")
+	for _, key := range keys[start:end] {
+		fmt.Fprintf(&b, "<Contents><Key>%s</Key><Size>%d</Size><ETag>%s</ETag><LastModified>2024-01-01T00:00:00.000Z</LastModified></Contents>",
+			key, len(f.objects[key]), f.etags[key])
+	}
+	if end < len(keys) {
+		fmt.Fprintf(&b, "<IsTruncated>true</IsTruncated><NextContinuationToken>%d</NextContinuationToken>", end)
+	}
+	b.WriteString("</ListBucketResult>")
+	io.WriteString(w, b.String())
+}
+
+func (f *fakeS3) complete(w http.ResponseWriter, key, uploadID string, body []byte) {
+	parts, ok := f.uploads[uploadID]
+	if !ok {
+		http.Error(w, "no such upload", http.StatusNotFound)
+		return
+	}
+	var request completeMultipartUpload
+	if err := xml.Unmarshal(body, &request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var data []byte
+	for i, part := range request.Parts {
+		content, ok := parts[part.PartNumber]
+		if !ok || part.PartNumber != i+1 || part.ETag != etag(content) {
+			fmt.Fprint(w, "<Error><Code>InvalidPart</Code><Message>invalid part</Message></Error>")
+			return
+		}
+		if i < len(request.Parts)-1 && len(content) < minPartSize {
+			fmt.Fprint(w, "<Error><Code>EntityTooSmall</Code><Message>part too small</Message></Error>")
+			return
+		}
+		data = append(data, content...)
+	}
+	delete(f.uploads, uploadID)
+	f.objects[key] = data
+	f.etags[key] = fmt.Sprintf(`"%x-%d"`, md5.Sum(data), len(request.Parts))
+	fmt.Fprintf(w, "<CompleteMultipartUploadResult><ETag>%s</ETag></CompleteMultipartUploadResult>", f.etags[key])
+}
+
+func etag(data []byte) string {
+	return fmt.Sprintf(`"%x"`, md5.Sum(data))
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func newTestS3(t *testing.T) (*S3, *fakeS3) {
+	t.Helper()
+	fake := newFakeS3("snapshots")
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	s3, err := NewS3(S3Config{
+		Endpoint:        server.URL,
+		Bucket:          "snapshots",
+		AccessKeyID:     "test-key",
+		SecretAccessKey: "test-secret",
+		PathStyle:       true,
+		PartSize:        minPartSize,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s3, fake
+}
+
+// testBackend exercises the Backend contract shared by every implementation
+func testBackend(t *testing.T, b Backend) {
+	ctx := context.Background()
+	prefix := SnapshotPrefix("tenant", "source", "snap 1")
+
+	small := []byte("manifest")
+	if _, err := b.Put(ctx, Join(prefix, "manifest.json"), bytes.NewReader(small)); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	large := bytes.Repeat([]byte("0123456789abcdef"), (2*minPartSize+1024)/16)
+	info, err := b.Put(ctx, Join(prefix, "artifact.tar.zst.age"), bytes.NewReader(large))
+	if err != nil {
+		t.Fatalf("Put large: %v", err)
+	}
+	if info.Size != int64(len(large)) {
+		t.Errorf("Put size = %d, want %d", info.Size, len(large))
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		if _, err := b.Put(ctx, Join(prefix, "chunks/"+name), strings.NewReader(name)); err != nil {
+			t.Fatalf("Put %s: %v", name, err)
+		}
+	}
+
+	got, err := ReadAll(ctx, b, Join(prefix, "artifact.tar.zst.age"))
+	if err != nil || !bytes.Equal(got, large) {
+		t.Fatalf("Get returned %d bytes, err %v; want %d bytes", len(got), err, len(large))
+	}
+
+	stat, err := b.Stat(ctx, Join(prefix, "manifest.json"))
+	if err != nil || stat.Size != int64(len(small)) {
+		t.Errorf("Stat = %+v, %v", stat, err)
+	}
+
+	ra, size, err := OpenReaderAt(ctx, b, Join(prefix, "artifact.tar.zst.age"))
+	if err != nil || size != int64(len(large)) {
+		t.Fatalf("OpenReaderAt = %d, %v; want %d bytes", size, err, len(large))
+	}
+	defer ra.Close()
+	section := make([]byte, 100)
+	if _, err := ra.ReadAt(section, minPartSize-50); err != nil {
+		t.Fatalf("ReadAt: %v", err)
+	}
+	if !bytes.Equal(section, large[minPartSize-50:minPartSize+50]) {
+		t.Error("ReadAt returned the wrong bytes")
+	}
+	tail := make([]byte, 100)
+	if n, err := ra.ReadAt(tail, int64(len(large))-10); n != 10 || err != io.EOF {
+		t.Errorf("ReadAt past end = %d, %v; want 10, EOF", n, err)
+	}
+
+	objects, err := b.List(ctx, prefix+"/")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	var keys []string
+	for _, object := range objects {
+		keys = append(keys, strings.TrimPrefix(object.Key, prefix+"/"))
+	}
+	want := "artifact.tar.zst.age chunks/a chunks/b chunks/c manifest.json"
+	if strings.Join(keys, " ") != want {
+		t.Errorf("List = %v, want %s", keys, want)
+	}
+
+	if _, err := b.Stat(ctx, Join(prefix, "missing")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Stat missing = %v, want fs.ErrNotExist", err)
+	}
+	if _, err := b.Get(ctx, Join(prefix, "missing")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Get missing = %v, want fs.ErrNotExist", err)
+	}
+	if err := b.Delete(ctx, Join(prefix, "missing")); err != nil {
+		t.Errorf("Delete missing = %v", err)
+	}
+
+	freed, err := DeletePrefix(ctx, b, prefix)
+	if err != nil {
+		t.Fatalf("DeletePrefix: %v", err)
+	}
+	if freed != int64(len(small)+len(large)+3) {
+		t.Errorf("DeletePrefix freed %d bytes", freed)
+	}
+	if objects, _ := b.List(ctx, prefix+"/"); len(objects) != 0 {
+		t.Errorf("objects left after DeletePrefix: %v", objects)
+	}
+
+	if _, err := b.Put(ctx, "../escape", strings.NewReader("x")); err == nil {
+		t.Error("Put accepted a key outside the store")
+	}
+}
+
+func TestLocal(t *testing.T) {
+	testBackend(t, NewLocal(t.TempDir()))
+}
+
+func TestS3(t *testing.T) {
+	s3, fake := newTestS3(t)
+	testBackend(t, s3)
+	if len(fake.uploads) != 0 {
+		t.Errorf("%d multipart uploads left open", len(fake.uploads))
+	}
+}
+
+func TestS3MultipartETag(t *testing.T) {
+	s3, _ := newTestS3(t)
+	data := bytes.Repeat([]byte{'x'}, minPartSize+1)
+
+	info, err := s3.Put(context.Background(), "big", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(info.ETag, "-2") || strings.Contains(info.ETag, `"`) {
+		t.Errorf("ETag = %q, want an unquoted two-part multipart ETag", info.ETag)
+	}
+	stat, err := s3.Stat(context.Background(), "big")
+	if err != nil || stat.ETag != info.ETag {
+		t.Errorf("Stat ETag = %q, %v; want %q", stat.ETag, err, info.ETag)
+	}
+}
+
+func TestS3AbortsFailedUpload(t *testing.T) {
+	s3, fake := newTestS3(t)
+	r := io.MultiReader(bytes.NewReader(make([]byte, minPartSize)), &failingReader{})
+
+	if _, err := s3.Put(context.Background(), "broken", r); err == nil {
+		t.Fatal("Put succeeded with a failing reader")
+	}
+	if fake.aborted != 1 || len(fake.uploads) != 0 {
+		t.Errorf("aborted %d uploads, %d left open; want the upload aborted", fake.aborted, len(fake.uploads))
+	}
+	if _, ok := fake.objects["broken"]; ok {
+		t.Error("failed upload left an object behind")
+	}
+}
+
+type failingReader struct{}
+
+func (*failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("disk error")
+}
@@ -0,0 +1,260 @@
+package backend
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// SFTPConfig configures an SFTP backend
+type SFTPConfig struct {
+	Host string
+	Port int // 22 when zero
+	// Username authenticates with PrivateKey (PEM) when set, Password otherwise
+	Username   string
+	Password   string
+	PrivateKey string
+	// HostKey is the server's public key in authorized_keys format. It is required:
+	// a server presenting any other key is refused.
+	HostKey string
+	// BasePath is the remote directory keys are relative to; a relative path is
+	// relative to the login directory
+	BasePath string
+}
+
+// SFTP stores objects as files below a directory on an SFTP server, such as a
+// storage box or a NAS. It holds one SSH connection until it is closed.
+type SFTP struct {
+	root   string
+	conn   *ssh.Client
+	client *sftp.Client
+}
+
+// NewSFTP connects to the SFTP server in config
+func NewSFTP(config SFTPConfig) (*SFTP, error) {
+	if config.Host == "" || config.Username == "" {
+		return nil, fmt.Errorf("sftp host and username are required")
+	}
+	if config.HostKey == "" {
+		return nil, fmt.Errorf("sftp host key is required")
+	}
+	hostKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(config.HostKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse host key: %w", err)
+	}
+	if config.Port == 0 {
+		config.Port = 22
+	}
+
+	sshConfig := &ssh.ClientConfig{
+		User:            config.Username,
+		HostKeyCallback: ssh.FixedHostKey(hostKey),
+		Timeout:         30 * time.Second,
+	}
+	switch {
+	case config.PrivateKey != "":
+		signer, err := ssh.ParsePrivateKey([]byte(config.PrivateKey))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse private key: %w", err)
+		}
+		sshConfig.Auth = []ssh.AuthMethod{ssh.PublicKeys(signer)}
+	case config.Password != "":
+		sshConfig.Auth = []ssh.AuthMethod{ssh.Password(config.Password)}
+	default:
+		return nil, fmt.Errorf("no authentication method provided")
+	}
+	conn, err := ssh.Dial("tcp", net.JoinHostPort(config.Host, strconv.Itoa(config.Port)), sshConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial SSH: %w", err)
+	}
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create SFTP client: %w", err)
+	}
+	return &SFTP{root: path.Clean(config.BasePath), conn: conn, client: client}, nil
+}
+
+// Close closes the connection
+func (s *SFTP) Close() error {
+	s.client.Close()
+	return s.conn.Close()
+}
+
+// path returns the remote path of the object at key
+func (s *SFTP) path(key string) string {
+	return path.Join(s.root, key)
+}
+
+// Put uploads the object to a temporary file next to its final path and renames it
+// into place, so readers never see a partial object
+func (s *SFTP) Put(ctx context.Context, key string, r io.Reader) (ObjectInfo, error) {
+	if err := validKey(key); err != nil {
+		return ObjectInfo{}, err
+	}
+	if err := ctx.Err(); err != nil {
+		return ObjectInfo{}, err
+	}
+	target := s.path(key)
+	if err := s.client.MkdirAll(path.Dir(target)); err != nil {
+		return ObjectInfo{}, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return ObjectInfo{}, err
+	}
+	tmp := path.Join(path.Dir(target), "."+path.Base(target)+"."+hex.EncodeToString(suffix))
+	f, err := s.client.Create(tmp)
+	if err != nil {
+		return ObjectInfo{}, fmt.Errorf("failed to create %s: %w", key, err)
+	}
+	size, err := io.Copy(f, r)
+	if err == nil {
+		err = f.Close()
+	} else {
+		f.Close()
+	}
+	if err == nil {
+		err = s.rename(tmp, target)
+	}
+	if err != nil {
+		s.client.Remove(tmp)
+		return ObjectInfo{}, fmt.Errorf("failed to write %s: %w", key, err)
+	}
+	return ObjectInfo{Key: key, Size: size}, nil
+}
+
+// rename moves a file over target. Servers without the posix-rename extension
+// refuse to rename over an existing file, so target is removed first for them.
+func (s *SFTP) rename(from, target string) error {
+	if err := s.client.PosixRename(from, target); err == nil {
+		return nil
+	}
+	if err := s.client.Remove(target); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return s.client.Rename(from, target)
+}
+
+// Get opens the object for reading
+func (s *SFTP) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	if err := validKey(key); err != nil {
+		return nil, err
+	}
+	f, err := s.client.Open(s.path(key))
+	if err != nil {
+		return nil, s.wrapError(key, err)
+	}
+	return f, nil
+}
+
+// GetRange returns a section of the object
+func (s *SFTP) GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	if err := validKey(key); err != nil {
+		return nil, err
+	}
+	f, err := s.client.Open(s.path(key))
+	if err != nil {
+		return nil, s.wrapError(key, err)
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.NewSectionReader(f, offset, length), f}, nil
+}
+
+// Stat returns the object's size and modification time
+func (s *SFTP) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	if err := validKey(key); err != nil {
+		return ObjectInfo{}, err
+	}
+	info, err := s.client.Stat(s.path(key))
+	if err != nil {
+		return ObjectInfo{}, s.wrapError(key, err)
+	}
+	if info.IsDir() {
+		return ObjectInfo{}, notExist(key)
+	}
+	return ObjectInfo{Key: key, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+// Delete removes the object and any directories it leaves empty
+func (s *SFTP) Delete(ctx context.Context, key string) error {
+	if err := validKey(key); err != nil {
+		return err
+	}
+	if err := s.client.Remove(s.path(key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete %s: %w", key, err)
+	}
+	for dir := path.Dir(key); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if s.client.RemoveDirectory(s.path(dir)) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// List walks the directory holding prefix and returns the files whose keys match
+func (s *SFTP) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	// Walk from the deepest directory the prefix names completely
+	dir := ""
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		dir = prefix[:i]
+	}
+
+	var objects []ObjectInfo
+	walker := s.client.Walk(s.path(dir))
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to list %s: %w", prefix, err)
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if walker.Stat().IsDir() {
+			continue
+		}
+		key := s.key(walker.Path())
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		objects = append(objects, ObjectInfo{Key: key, Size: walker.Stat().Size(), ModTime: walker.Stat().ModTime()})
+	}
+
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+// key returns the key of the object at a remote path below the root
+func (s *SFTP) key(remotePath string) string {
+	if s.root == "." {
+		return strings.TrimPrefix(remotePath, "./")
+	}
+	return strings.TrimPrefix(strings.TrimPrefix(remotePath, s.root), "/")
+}
+
+// wrapError reports missing files with notExist, so callers can test for
+// fs.ErrNotExist
+func (s *SFTP) wrapError(key string, err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return notExist(key)
+	}
+	return fmt.Errorf("failed to read %s: %w", key, err)
+}
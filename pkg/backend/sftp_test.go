@@ -0,0 +1,177 @@
+package backend
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// newTestSFTPServer serves a temporary directory over SFTP from an in-process SSH
+// server to user "backup" with password "secret". It returns a config for the
+// server and the directory.
+func newTestSFTPServer(t *testing.T) (SFTPConfig, string) {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverConfig := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() == "backup" && string(password) == "secret" {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("password rejected for %s", conn.User())
+		},
+	}
+	serverConfig.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	root := t.TempDir()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveTestSFTP(conn, serverConfig, root)
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+	return SFTPConfig{
+		Host:     host,
+		Port:     portNumber,
+		Username: "backup",
+		Password: "secret",
+		HostKey:  string(ssh.MarshalAuthorizedKey(signer.PublicKey())),
+		BasePath: "storage-box/xvault",
+	}, root
+}
+
+// serveTestSFTP serves the sftp subsystem of each session on conn, rooted at root
+func serveTestSFTP(conn net.Conn, config *ssh.ServerConfig, root string) {
+	_, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			for req := range requests {
+				ok := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+				req.Reply(ok, nil)
+				if !ok {
+					continue
+				}
+				server, err := sftp.NewServer(channel, sftp.WithServerWorkingDirectory(root))
+				if err != nil {
+					channel.Close()
+					return
+				}
+				go func() {
+					server.Serve()
+					channel.Close()
+				}()
+			}
+		}()
+	}
+}
+
+func newTestSFTP(t *testing.T) (*SFTP, string) {
+	t.Helper()
+	config, root := newTestSFTPServer(t)
+	s, err := NewSFTP(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s, root
+}
+
+func TestSFTP(t *testing.T) {
+	s, root := newTestSFTP(t)
+	testBackend(t, s)
+
+	// DeletePrefix leaves no empty snapshot directories behind
+	entries, err := os.ReadDir(filepath.Join(root, "storage-box", "xvault"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("%d entries left under the base path", len(entries))
+	}
+}
+
+func TestSFTPLayout(t *testing.T) {
+	s, root := newTestSFTP(t)
+	key := Join(SnapshotPrefix("t1", "s1", "snap"), "manifest.json")
+	if _, err := s.Put(context.Background(), key, strings.NewReader("first")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Put(context.Background(), key, strings.NewReader("second")); err != nil {
+		t.Fatalf("Put over an existing object: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(root, "storage-box", "xvault", filepath.FromSlash(key)))
+	if err != nil || string(data) != "second" {
+		t.Errorf("remote file = %q, %v; want %q", data, err, "second")
+	}
+	entries, _ := os.ReadDir(filepath.Dir(filepath.Join(root, "storage-box", "xvault", filepath.FromSlash(key))))
+	if len(entries) != 1 {
+		t.Errorf("%d files in the snapshot directory, want only the manifest", len(entries))
+	}
+}
+
+func TestSFTPRejectsWrongCredentials(t *testing.T) {
+	config, _ := newTestSFTPServer(t)
+
+	wrongPassword := config
+	wrongPassword.Password = "wrong"
+	if _, err := NewSFTP(wrongPassword); err == nil {
+		t.Error("NewSFTP accepted a wrong password")
+	}
+
+	_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+	otherSigner, _ := ssh.NewSignerFromKey(otherKey)
+	wrongHostKey := config
+	wrongHostKey.HostKey = string(ssh.MarshalAuthorizedKey(otherSigner.PublicKey()))
+	if _, err := NewSFTP(wrongHostKey); err == nil {
+		t.Error("NewSFTP accepted a server with a different host key")
+	}
+
+	noHostKey := config
+	noHostKey.HostKey = ""
+	if _, err := NewSFTP(noHostKey); err == nil {
+		t.Error("NewSFTP connected without a host key to check")
+	}
+}
@@ -2,10 +2,14 @@ package crypto
 
 import (
 	"bytes"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
+	"strings"
 
 	"filippo.io/age"
 )
@@ -45,27 +49,128 @@ func EncryptToPublicKey(plaintext []byte, publicKey string) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// ErrDecrypt is returned when ciphertext does not decrypt with a private key: it was
+// encrypted to another key, or altered or truncated since
+var ErrDecrypt = errors.New("decryption failed")
+
+// parseIdentities parses a private key for decryption. It may hold several
+// identities, one per line, e.g. a tenant's active key and the keys it rotated out;
+// age picks whichever the ciphertext was encrypted to.
+func parseIdentities(privateKey string) ([]age.Identity, error) {
+	identities, err := age.ParseIdentities(strings.NewReader(privateKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+	return identities, nil
+}
+
 // DecryptWithPrivateKey decrypts data using the private key
 func DecryptWithPrivateKey(ciphertext []byte, privateKey string) ([]byte, error) {
-	identity, err := age.ParseX25519Identity(privateKey)
+	identities, err := parseIdentities(privateKey)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse private key: %w", err)
+		return nil, err
 	}
 
 	r := bytes.NewReader(ciphertext)
-	rdr, err := age.Decrypt(r, identity)
+	rdr, err := age.Decrypt(r, identities...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create decryption reader: %w", err)
+		return nil, fmt.Errorf("failed to create decryption reader: %w: %w", ErrDecrypt, err)
 	}
 
 	plaintext, err := io.ReadAll(rdr)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read decrypted data: %w", err)
+		return nil, fmt.Errorf("failed to read decrypted data: %w: %w", ErrDecrypt, err)
 	}
 
 	return plaintext, nil
 }
 
+// NewEncryptWriter returns a writer that encrypts everything written to it
+// for the recipient's public key and writes the ciphertext to dst.
+// The caller must Close the returned writer to flush the final chunk.
+func NewEncryptWriter(dst io.Writer, publicKey string) (io.WriteCloser, error) {
+	recipient, err := age.ParseX25519Recipient(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %w", err)
+	}
+
+	w, err := age.Encrypt(dst, recipient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create encryption writer: %w", err)
+	}
+
+	return w, nil
+}
+
+// NewDecryptReader returns a reader that decrypts ciphertext read from src
+// using the private key. Errors reading src are returned as they are; anything else
+// that fails is an ErrDecrypt.
+func NewDecryptReader(src io.Reader, privateKey string) (io.Reader, error) {
+	identities, err := parseIdentities(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	source := &sourceReader{r: src}
+	r, err := age.Decrypt(source, identities...)
+	if err != nil {
+		if source.err != nil {
+			return nil, source.err
+		}
+		return nil, fmt.Errorf("failed to create decryption reader: %w: %w", ErrDecrypt, err)
+	}
+
+	return &decryptReader{r: r, source: source}, nil
+}
+
+// sourceReader remembers the first error its reader returned other than io.EOF
+type sourceReader struct {
+	r   io.Reader
+	err error
+}
+
+func (s *sourceReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	if err != nil && err != io.EOF && s.err == nil {
+		s.err = err
+	}
+	return n, err
+}
+
+// decryptReader tells failures to read the ciphertext from failures to decrypt it
+type decryptReader struct {
+	r      io.Reader
+	source *sourceReader
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	if err != nil && err != io.EOF {
+		if d.source.err != nil {
+			return n, d.source.err
+		}
+		return n, fmt.Errorf("%w: %w", ErrDecrypt, err)
+	}
+	return n, err
+}
+
+// NewDecryptReaderAt returns random access to the plaintext of an age file of
+// encryptedSize bytes, along with the plaintext size. Only the STREAM chunks covering
+// a read are decrypted.
+func NewDecryptReaderAt(src io.ReaderAt, encryptedSize int64, privateKey string) (io.ReaderAt, int64, error) {
+	identities, err := parseIdentities(privateKey)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	r, size, err := age.DecryptReaderAt(src, encryptedSize, identities...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create decryption reader: %w", err)
+	}
+
+	return r, size, nil
+}
+
 // EncryptBase64 encrypts data and returns base64-encoded ciphertext
 func EncryptBase64(plaintext []byte, publicKey string) (string, error) {
 	ciphertext, err := EncryptToPublicKey(plaintext, publicKey)
@@ -93,35 +198,10 @@ func GenerateKEK() (string, error) {
 	return base64.StdEncoding.EncodeToString(key), nil
 }
 
-// EncryptForStorage encrypts a tenant private key using the platform KEK
-// This is envelope encryption: KEK encrypts the tenant private key
-func EncryptForStorage(plaintext []byte, kek string) (string, error) {
-	kekBytes, err := base64.StdEncoding.DecodeString(kek)
-	if err != nil {
-		return "", fmt.Errorf("invalid KEK format: %w", err)
-	}
-
-	if len(kekBytes) != 32 {
-		return "", fmt.Errorf("KEK must be 32 bytes (base64-encoded)")
-	}
-
-	// Simple XOR for demo - in production, use AES-GCM or similar
-	// This is NOT secure for production, only for initial development
-	ciphertext := make([]byte, len(plaintext))
-	for i := range plaintext {
-		ciphertext[i] = plaintext[i] ^ kekBytes[i%32]
-	}
-
-	return base64.StdEncoding.EncodeToString(ciphertext), nil
-}
-
-// DecryptFromStorage decrypts a tenant private key using the platform KEK
-func DecryptFromStorage(ciphertextB64 string, kek string) ([]byte, error) {
-	ciphertext, err := base64.StdEncoding.DecodeString(ciphertextB64)
-	if err != nil {
-		return nil, fmt.Errorf("invalid ciphertext format: %w", err)
-	}
-
+// DeriveChunkKey derives a per-tenant key for naming deduplicated chunks.
+// Chunk IDs are HMAC-SHA256(chunkKey, data), so identical content in two tenants
+// yields unrelated IDs and an ID reveals nothing about the data without the key.
+func DeriveChunkKey(kek string, tenantID string) ([]byte, error) {
 	kekBytes, err := base64.StdEncoding.DecodeString(kek)
 	if err != nil {
 		return nil, fmt.Errorf("invalid KEK format: %w", err)
@@ -131,11 +211,7 @@ func DecryptFromStorage(ciphertextB64 string, kek string) ([]byte, error) {
 		return nil, fmt.Errorf("KEK must be 32 bytes (base64-encoded)")
 	}
 
-	// Simple XOR for demo - matches EncryptForStorage
-	plaintext := make([]byte, len(ciphertext))
-	for i := range ciphertext {
-		plaintext[i] = ciphertext[i] ^ kekBytes[i%32]
-	}
-
-	return plaintext, nil
+	mac := hmac.New(sha256.New, kekBytes)
+	mac.Write([]byte("xvault-chunk-id-v1:" + tenantID))
+	return mac.Sum(nil), nil
 }
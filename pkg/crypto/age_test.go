@@ -0,0 +1,103 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+// TestDecryptWithRotatedKeys decrypts with an identity file holding a tenant's active
+// key and the key it rotated out, as the hub hands out after a rotation
+func TestDecryptWithRotatedKeys(t *testing.T) {
+	oldPublic, oldPrivate, err := GenerateX25519KeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	newPublic, newPrivate, err := GenerateX25519KeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	identities := newPrivate + "\n" + oldPrivate
+
+	for _, publicKey := range []string{oldPublic, newPublic} {
+		plaintext := []byte("snapshot encrypted to " + publicKey)
+		ciphertext, err := EncryptToPublicKey(plaintext, publicKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := DecryptWithPrivateKey(ciphertext, identities)
+		if err != nil {
+			t.Fatalf("%s: %v", publicKey, err)
+		}
+		if !bytes.Equal(got, plaintext) {
+			t.Fatalf("got %q, want %q", got, plaintext)
+		}
+	}
+
+	ciphertext, _ := EncryptToPublicKey([]byte("secret"), oldPublic)
+	if _, err := DecryptWithPrivateKey(ciphertext, newPrivate); err == nil {
+		t.Fatal("decrypted with a key the data was not encrypted to")
+	}
+}
+
+// failingReader returns its data and then err, like a connection dropping mid-read
+type failingReader struct {
+	data []byte
+	err  error
+}
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	if len(f.data) == 0 {
+		return 0, f.err
+	}
+	n := copy(p, f.data)
+	f.data = f.data[n:]
+	return n, nil
+}
+
+// TestDecryptReaderErrors tells altered or truncated ciphertext, which is ErrDecrypt,
+// from a source that fails, whose error is returned as it is
+func TestDecryptReaderErrors(t *testing.T) {
+	publicKey, privateKey, err := GenerateX25519KeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := EncryptToPublicKey(bytes.Repeat([]byte("snapshot "), 20000), publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := bytes.Clone(ciphertext)
+	tampered[len(tampered)-10] ^= 0xff
+	errSource := errors.New("connection reset")
+
+	tests := []struct {
+		name string
+		src  io.Reader
+		want error
+	}{
+		{"tampered", bytes.NewReader(tampered), ErrDecrypt},
+		{"truncated", bytes.NewReader(ciphertext[:len(ciphertext)-100]), ErrDecrypt},
+		{"source fails in payload", &failingReader{data: ciphertext[:len(ciphertext)/2], err: errSource}, errSource},
+		{"source fails in header", &failingReader{err: errSource}, errSource},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewDecryptReader(tt.src, privateKey)
+			if err == nil {
+				_, err = io.Copy(io.Discard, r)
+			}
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+			if tt.want == errSource && errors.Is(err, ErrDecrypt) {
+				t.Fatalf("source error %v reported as a decryption failure", err)
+			}
+		})
+	}
+
+	_, otherPrivate, _ := GenerateX25519KeyPair()
+	if _, err := NewDecryptReader(bytes.NewReader(ciphertext), otherPrivate); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("wrong key: got %v, want ErrDecrypt", err)
+	}
+}
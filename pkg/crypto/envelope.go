@@ -0,0 +1,167 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Secrets the hub stores under the platform KEK (tenant private keys, credentials) are
+// sealed with AES-256-GCM in the format
+//
+//	v1:aes-256-gcm:<kek id>:<base64 nonce>:<base64 ciphertext and tag>
+//
+// The header up to the KEK id is authenticated as additional data. Values without the
+// version prefix are in the legacy format: base64 of the plaintext XORed with the KEK.
+// They are still read, until ReencryptLegacySecrets has rewritten them.
+const (
+	envelopeVersion   = "v1"
+	envelopeAlgorithm = "aes-256-gcm"
+)
+
+// ErrKEKMismatch is returned when a secret was sealed under a different KEK than the
+// one given to open it
+var ErrKEKMismatch = errors.New("secret was encrypted with a different KEK")
+
+// KEKID identifies a KEK without revealing it: the first 8 bytes of a SHA-256 over it,
+// in hex. It is recorded in every secret sealed under the KEK.
+func KEKID(kek string) (string, error) {
+	kekBytes, err := decodeKEK(kek)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(append([]byte("xvault-kek-id-v1:"), kekBytes...))
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+// IsLegacyStorageCiphertext reports whether a secret from EncryptForStorage predates
+// the versioned format and should be re-encrypted
+func IsLegacyStorageCiphertext(ciphertext string) bool {
+	return !strings.HasPrefix(ciphertext, envelopeVersion+":")
+}
+
+// EncryptForStorage seals a secret, e.g. a tenant private key or a source credential,
+// under the platform KEK
+func EncryptForStorage(plaintext []byte, kek string) (string, error) {
+	kekBytes, err := decodeKEK(kek)
+	if err != nil {
+		return "", err
+	}
+	kekID, err := KEKID(kek)
+	if err != nil {
+		return "", err
+	}
+	aead, err := newEnvelopeAEAD(kekBytes)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	header := strings.Join([]string{envelopeVersion, envelopeAlgorithm, kekID}, ":")
+	sealed := aead.Seal(nil, nonce, plaintext, []byte(header))
+	return strings.Join([]string{
+		header,
+		base64.StdEncoding.EncodeToString(nonce),
+		base64.StdEncoding.EncodeToString(sealed),
+	}, ":"), nil
+}
+
+// DecryptFromStorage opens a secret sealed by EncryptForStorage, in either the
+// versioned or the legacy format
+func DecryptFromStorage(ciphertext string, kek string) ([]byte, error) {
+	kekBytes, err := decodeKEK(kek)
+	if err != nil {
+		return nil, err
+	}
+	if IsLegacyStorageCiphertext(ciphertext) {
+		return decryptLegacy(ciphertext, kekBytes)
+	}
+
+	parts := strings.Split(ciphertext, ":")
+	if len(parts) != 5 {
+		return nil, fmt.Errorf("invalid ciphertext format: expected 5 fields, got %d", len(parts))
+	}
+	if parts[1] != envelopeAlgorithm {
+		return nil, fmt.Errorf("unsupported ciphertext algorithm %q", parts[1])
+	}
+	kekID, err := KEKID(kek)
+	if err != nil {
+		return nil, err
+	}
+	if parts[2] != kekID {
+		return nil, fmt.Errorf("%w: sealed under KEK %s, have %s", ErrKEKMismatch, parts[2], kekID)
+	}
+
+	nonce, err := base64.StdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return nil, fmt.Errorf("invalid ciphertext nonce: %w", err)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, fmt.Errorf("invalid ciphertext format: %w", err)
+	}
+	aead, err := newEnvelopeAEAD(kekBytes)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("invalid ciphertext nonce: %d bytes", len(nonce))
+	}
+
+	header := strings.Join(parts[:3], ":")
+	plaintext, err := aead.Open(nil, nonce, sealed, []byte(header))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt secret: %w", err)
+	}
+	return plaintext, nil
+}
+
+// decryptLegacy reads the format EncryptForStorage wrote before v1, which is
+// unauthenticated: a wrong KEK yields garbage rather than an error
+func decryptLegacy(ciphertextB64 string, kekBytes []byte) ([]byte, error) {
+	ciphertext, err := base64.StdEncoding.DecodeString(ciphertextB64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ciphertext format: %w", err)
+	}
+
+	plaintext := make([]byte, len(ciphertext))
+	for i := range ciphertext {
+		plaintext[i] = ciphertext[i] ^ kekBytes[i%32]
+	}
+	return plaintext, nil
+}
+
+// newEnvelopeAEAD returns AES-256-GCM keyed with the KEK
+func newEnvelopeAEAD(kekBytes []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(kekBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+	return aead, nil
+}
+
+// decodeKEK decodes a base64 platform KEK and checks it is 32 bytes
+func decodeKEK(kek string) ([]byte, error) {
+	kekBytes, err := base64.StdEncoding.DecodeString(kek)
+	if err != nil {
+		return nil, fmt.Errorf("invalid KEK format: %w", err)
+	}
+	if len(kekBytes) != 32 {
+		return nil, fmt.Errorf("KEK must be 32 bytes (base64-encoded)")
+	}
+	return kekBytes, nil
+}
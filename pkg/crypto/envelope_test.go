@@ -0,0 +1,92 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func testKEK(t *testing.T) string {
+	t.Helper()
+	kek, err := GenerateKEK()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return kek
+}
+
+func TestStorageEnvelopeRoundTrip(t *testing.T) {
+	kek := testKEK(t)
+	plaintext := []byte("AGE-SECRET-KEY-1EXAMPLE")
+
+	ciphertext, err := EncryptForStorage(plaintext, kek)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kekID, _ := KEKID(kek)
+	if !strings.HasPrefix(ciphertext, "v1:aes-256-gcm:"+kekID+":") {
+		t.Fatalf("ciphertext %q does not record version, algorithm and KEK id", ciphertext)
+	}
+	if IsLegacyStorageCiphertext(ciphertext) {
+		t.Fatal("v1 ciphertext reported as legacy")
+	}
+
+	got, err := DecryptFromStorage(ciphertext, kek)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Fatalf("got %q, want %q", got, plaintext)
+	}
+
+	again, _ := EncryptForStorage(plaintext, kek)
+	if again == ciphertext {
+		t.Fatal("two encryptions share a nonce")
+	}
+}
+
+// TestStorageEnvelopeLegacy reads a secret in the XOR format written before v1
+func TestStorageEnvelopeLegacy(t *testing.T) {
+	kek := testKEK(t)
+	kekBytes, _ := base64.StdEncoding.DecodeString(kek)
+	plaintext := []byte(`{"username":"backup","password":"secret"}`)
+
+	xored := make([]byte, len(plaintext))
+	for i := range plaintext {
+		xored[i] = plaintext[i] ^ kekBytes[i%32]
+	}
+	legacy := base64.StdEncoding.EncodeToString(xored)
+	if !IsLegacyStorageCiphertext(legacy) {
+		t.Fatal("legacy ciphertext not recognized")
+	}
+
+	got, err := DecryptFromStorage(legacy, kek)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Fatalf("got %q, want %q", got, plaintext)
+	}
+}
+
+func TestStorageEnvelopeRejects(t *testing.T) {
+	kek := testKEK(t)
+	ciphertext, err := EncryptForStorage([]byte("secret"), kek)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := DecryptFromStorage(ciphertext, testKEK(t)); !errors.Is(err, ErrKEKMismatch) {
+		t.Fatalf("other KEK: got %v, want ErrKEKMismatch", err)
+	}
+
+	parts := strings.Split(ciphertext, ":")
+	sealed, _ := base64.StdEncoding.DecodeString(parts[4])
+	sealed[0] ^= 1
+	parts[4] = base64.StdEncoding.EncodeToString(sealed)
+	if _, err := DecryptFromStorage(strings.Join(parts, ":"), kek); err == nil {
+		t.Fatal("tampered ciphertext decrypted")
+	}
+}
@@ -0,0 +1,128 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrInvalidSignature is returned when a manifest signature does not verify
+var ErrInvalidSignature = errors.New("manifest signature does not verify")
+
+// GenerateSigningKeyPair generates an Ed25519 identity keypair for a worker. Both
+// halves are base64; the private key is the 32-byte seed.
+func GenerateSigningKeyPair() (publicKey string, privateKey string, err error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate signing key: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(pub), base64.StdEncoding.EncodeToString(priv.Seed()), nil
+}
+
+// LoadOrCreateSigningKey reads the identity key stored at path, generating and storing
+// a new one (readable only by the owner) if the file does not exist
+func LoadOrCreateSigningKey(path string) (publicKey string, privateKey string, err error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		privateKey = strings.TrimSpace(string(data))
+		key, err := parseSigningKey(privateKey)
+		if err != nil {
+			return "", "", err
+		}
+		return base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)), privateKey, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return "", "", fmt.Errorf("failed to read signing key: %w", err)
+	}
+
+	publicKey, privateKey, err = GenerateSigningKeyPair()
+	if err != nil {
+		return "", "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", "", fmt.Errorf("failed to create signing key directory: %w", err)
+	}
+	// O_EXCL so two processes starting together cannot overwrite each other's key
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create signing key: %w", err)
+	}
+	if _, err := file.WriteString(privateKey + "\n"); err != nil {
+		file.Close()
+		return "", "", fmt.Errorf("failed to write signing key: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return "", "", fmt.Errorf("failed to write signing key: %w", err)
+	}
+	return publicKey, privateKey, nil
+}
+
+// SignManifest signs the canonical form of a snapshot manifest and returns the base64
+// signature. Formatting of the manifest does not affect the signature.
+func SignManifest(manifest []byte, privateKey string) (string, error) {
+	key, err := parseSigningKey(privateKey)
+	if err != nil {
+		return "", err
+	}
+	canonical, err := canonicalJSON(manifest)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(key, canonical)), nil
+}
+
+// VerifyManifest checks a SignManifest signature against the signer's public key
+func VerifyManifest(manifest []byte, signature, publicKey string) error {
+	pub, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid signing public key")
+	}
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("invalid manifest signature encoding: %w", err)
+	}
+	canonical, err := canonicalJSON(manifest)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(ed25519.PublicKey(pub), canonical, sig) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// parseSigningKey decodes a base64 Ed25519 seed
+func parseSigningKey(privateKey string) (ed25519.PrivateKey, error) {
+	seed, err := base64.StdEncoding.DecodeString(privateKey)
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("invalid signing private key")
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// canonicalJSON re-encodes a JSON document with sorted object keys, no insignificant
+// whitespace and numbers exactly as written
+func canonicalJSON(data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var v any
+	if err := decoder.Decode(&v); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return nil, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
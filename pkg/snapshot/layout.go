@@ -0,0 +1,82 @@
+// Package snapshot reads snapshots from a storage backend: the versioned manifest, the
+// encrypted artifact (single file or volumes), repository chunk indexes and file
+// indexes. The worker and the restore service share it so every historical layout is
+// understood in one place.
+package snapshot
+
+import (
+	"fmt"
+	"path"
+	"sort"
+
+	"xvault/pkg/backend"
+)
+
+// Location addresses the files of a snapshot in a storage backend
+type Location struct {
+	Backend backend.Backend
+	// Prefix is the key of the snapshot directory
+	Prefix string
+}
+
+// Key returns the key of a file of the snapshot
+func (l Location) Key(name string) string {
+	return path.Join(l.Prefix, name)
+}
+
+// Files inside a snapshot directory
+const (
+	ManifestFileName   = "manifest.json"
+	MetaFileName       = "meta.json"
+	ArtifactFileName   = "backup.tar.zst.enc"
+	FileIndexFileName  = "index.json.zst.enc"
+	ChunkIndexFileName = "chunks.idx.enc"
+	ChunkRefsFileName  = "chunks.refs"
+)
+
+// ArtifactVolumeName is the file name of volume i (counting from 1) of a multi-volume artifact
+func ArtifactVolumeName(i int) string {
+	return fmt.Sprintf("%s.%04d", ArtifactFileName, i)
+}
+
+// RepositoryRoot maps a snapshot directory to its tenant's chunk repository:
+// <base>/tenants/<tenant>/sources/<source>/snapshots/<snapshot> -> <base>/tenants/<tenant>/repository.
+// Paths are slash-separated, so this works for keys and local paths alike.
+func RepositoryRoot(snapshotPath string) string {
+	return path.Join(snapshotPath, "..", "..", "..", "..", "repository")
+}
+
+// ChunkPath is where a repository stores a chunk
+func ChunkPath(repoRoot, id string) string {
+	return path.Join(repoRoot, "chunks", id[:2], id)
+}
+
+// ChunkIndexVersion is the format version of the encrypted chunk index
+const ChunkIndexVersion = 1
+
+// ChunkRef is one entry of a snapshot's chunk index
+type ChunkRef struct {
+	ID   string `json:"id"`
+	Size int64  `json:"size"`
+}
+
+// ChunkIndex lists, in stream order, the chunks that make up a snapshot
+type ChunkIndex struct {
+	Version      int        `json:"version"`
+	LogicalBytes int64      `json:"logical_bytes"`
+	Chunks       []ChunkRef `json:"chunks"`
+}
+
+// UniqueIDs returns the distinct chunk IDs referenced by the index, sorted
+func (idx *ChunkIndex) UniqueIDs() []string {
+	seen := make(map[string]bool, len(idx.Chunks))
+	ids := make([]string, 0, len(idx.Chunks))
+	for _, c := range idx.Chunks {
+		if !seen[c.ID] {
+			seen[c.ID] = true
+			ids = append(ids, c.ID)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
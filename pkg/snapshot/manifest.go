@@ -0,0 +1,104 @@
+package snapshot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"xvault/pkg/backend"
+	"xvault/pkg/types"
+)
+
+// Manifest format versions. Every version ever written must stay readable.
+const (
+	// FormatVersionLegacy covers manifests written before format_version existed.
+	// Their layout is inferred from optional fields: storage_mode (absent: artifact),
+	// frame_size (absent: one zstd frame), compression (absent: zstd), volumes (absent:
+	// a single backup.tar.zst.enc) and backup_mode (absent: full).
+	FormatVersionLegacy = 1
+	// FormatVersionExplicit manifests record format_version, storage_mode,
+	// compression and backup_mode unconditionally.
+	FormatVersionExplicit = 2
+
+	// FormatVersion is what new snapshots are written as
+	FormatVersion = FormatVersionExplicit
+)
+
+// EncodeManifest stamps a manifest with the current format version and encodes it as
+// written to manifest.json
+func EncodeManifest(manifest *types.SnapshotManifest) ([]byte, error) {
+	manifest.FormatVersion = FormatVersion
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	return data, nil
+}
+
+// ParseManifest decodes a manifest of any known format version into the current
+// model. Fields a version left implicit are filled in, so callers never need to know
+// which version they read. Manifests from a newer format are rejected rather than
+// misread.
+func ParseManifest(data []byte) (*types.SnapshotManifest, error) {
+	var manifest types.SnapshotManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w: %w", ErrMalformed, err)
+	}
+
+	switch manifest.FormatVersion {
+	case 0:
+		manifest.FormatVersion = FormatVersionLegacy
+	case FormatVersionLegacy, FormatVersionExplicit:
+	default:
+		return nil, fmt.Errorf("unsupported manifest format version %d (this reader supports up to %d)", manifest.FormatVersion, FormatVersion)
+	}
+
+	if manifest.StorageMode == "" {
+		manifest.StorageMode = types.StorageModeArtifact
+	}
+	if manifest.BackupMode == "" {
+		manifest.BackupMode = types.BackupModeFull
+	}
+	if manifest.Compression == nil {
+		manifest.Compression = &types.CompressionConfig{Algorithm: types.CompressionZstd}
+	} else if manifest.Compression.Algorithm == "" {
+		manifest.Compression.Algorithm = types.CompressionZstd
+	}
+
+	switch manifest.StorageMode {
+	case types.StorageModeArtifact, types.StorageModeRepository:
+	default:
+		return nil, fmt.Errorf("unsupported storage mode %q", manifest.StorageMode)
+	}
+	switch manifest.Compression.Algorithm {
+	case types.CompressionZstd, types.CompressionNone:
+	default:
+		return nil, fmt.Errorf("unsupported compression algorithm %q", manifest.Compression.Algorithm)
+	}
+
+	return &manifest, nil
+}
+
+// ReadManifest reads and parses the manifest of the snapshot at loc. The raw bytes are
+// returned as well, since manifest signatures cover them.
+func ReadManifest(ctx context.Context, loc Location) (*types.SnapshotManifest, []byte, error) {
+	data, err := backend.ReadAll(ctx, loc.Backend, loc.Key(ManifestFileName))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	manifest, err := ParseManifest(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return manifest, data, nil
+}
+
+// DecryptionKeyID is the tenant key to ask the hub for to decrypt a snapshot: the key
+// its manifest names, or "" for every key the tenant can decrypt with. Repository
+// snapshots need the latter, since they reuse chunks encrypted to earlier keys.
+func DecryptionKeyID(manifest *types.SnapshotManifest) string {
+	if manifest.StorageMode == types.StorageModeRepository {
+		return ""
+	}
+	return manifest.EncryptionKeyID
+}
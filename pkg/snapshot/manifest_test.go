@@ -0,0 +1,106 @@
+package snapshot
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"xvault/pkg/types"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files")
+
+// TestParseManifestGolden parses a manifest of every historical format version and
+// compares the normalized model with its golden file. Run with -update after an
+// intentional model change.
+func TestParseManifestGolden(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "manifests", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, input := range inputs {
+		if strings.HasSuffix(input, ".golden.json") {
+			continue
+		}
+		name := strings.TrimSuffix(filepath.Base(input), ".json")
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			manifest, err := ParseManifest(data)
+			if err != nil {
+				t.Fatalf("ParseManifest: %v", err)
+			}
+			got, err := json.MarshalIndent(manifest, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, '\n')
+
+			golden := strings.TrimSuffix(input, ".json") + ".golden.json"
+			if *update {
+				if err := os.WriteFile(golden, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("missing golden file (run with -update): %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("parsed manifest differs from %s:\n got: %s\nwant: %s", golden, got, want)
+			}
+		})
+	}
+}
+
+func TestParseManifestRejects(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+	}{
+		{"future format version", `{"format_version": 3}`},
+		{"negative format version", `{"format_version": -1}`},
+		{"unknown storage mode", `{"format_version": 2, "storage_mode": "tape"}`},
+		{"unknown compression", `{"compression": {"algorithm": "lz4"}}`},
+		{"malformed json", `{"format_version": `},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseManifest([]byte(tt.manifest)); err == nil {
+				t.Errorf("ParseManifest(%s) succeeded, want error", tt.manifest)
+			}
+		})
+	}
+}
+
+func TestEncodeManifestRoundTrip(t *testing.T) {
+	manifest := types.SnapshotManifest{
+		SnapshotID:  "snap-1",
+		StorageMode: types.StorageModeArtifact,
+		BackupMode:  types.BackupModeFull,
+		Compression: &types.CompressionConfig{Algorithm: types.CompressionZstd, Level: 3},
+	}
+
+	data, err := EncodeManifest(&manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseManifest(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.FormatVersion != FormatVersion {
+		t.Errorf("FormatVersion = %d, want %d", parsed.FormatVersion, FormatVersion)
+	}
+	if parsed.SnapshotID != manifest.SnapshotID || parsed.Compression.Level != 3 {
+		t.Errorf("round trip lost fields: %+v", parsed)
+	}
+}
@@ -0,0 +1,261 @@
+package snapshot
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"xvault/pkg/backend"
+	"xvault/pkg/crypto"
+	"xvault/pkg/types"
+)
+
+// Errors showing that stored snapshot data differs from what was written, as opposed
+// to it not being readable right now
+var (
+	ErrHashMismatch = errors.New("failed SHA-256 verification")
+	ErrSizeMismatch = errors.New("size does not match the manifest")
+	ErrMalformed    = errors.New("malformed data")
+)
+
+// IsCorrupt reports whether err shows a snapshot to be corrupt: a hash or size that
+// does not match its manifest, data that does not decrypt or decode, or a manifest
+// whose signature does not verify
+func IsCorrupt(err error) bool {
+	return errors.Is(err, ErrHashMismatch) || errors.Is(err, ErrSizeMismatch) || errors.Is(err, ErrMalformed) ||
+		errors.Is(err, crypto.ErrDecrypt) || errors.Is(err, crypto.ErrInvalidSignature)
+}
+
+// Stream is the plaintext tar stream of a snapshot. Verify checks what was read
+// against the manifest; call it once the archive has been consumed.
+type Stream interface {
+	io.ReadCloser
+	Verify() error
+}
+
+// OpenTarStream returns the plaintext tar stream of a snapshot, for both standalone
+// artifacts and repository-mode snapshots whose chunks live in the tenant repository.
+// Data is decrypted and decompressed as it is read.
+func OpenTarStream(ctx context.Context, loc Location, manifest *types.SnapshotManifest, privateKey string) (Stream, error) {
+	if manifest.StorageMode == types.StorageModeRepository {
+		return openRepositoryStream(ctx, loc, manifest, privateKey)
+	}
+
+	encryptedFile, err := OpenArtifact(ctx, loc, manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	decrypted, err := crypto.NewDecryptReader(encryptedFile, privateKey)
+	if err != nil {
+		encryptedFile.Close()
+		return nil, err
+	}
+
+	source := &decryptedReader{r: decrypted}
+	if Compression(manifest) == types.CompressionNone {
+		return &artifactStream{reader: source, source: source, file: encryptedFile}, nil
+	}
+
+	decoder, err := zstd.NewReader(source, zstd.WithDecoderMaxWindow(types.MaxCompressionWindow))
+	if err != nil {
+		encryptedFile.Close()
+		return nil, fmt.Errorf("failed to create zstd decoder: %w", err)
+	}
+
+	return &artifactStream{reader: decoder, decoder: decoder, source: source, file: encryptedFile}, nil
+}
+
+// Compression returns how a snapshot's archive is compressed
+func Compression(manifest *types.SnapshotManifest) types.CompressionAlgorithm {
+	if manifest.Compression == nil || manifest.Compression.Algorithm == "" {
+		return types.CompressionZstd
+	}
+	return manifest.Compression.Algorithm
+}
+
+// artifactStream is a decrypted, decompressed view of a snapshot's artifact
+type artifactStream struct {
+	reader  io.Reader
+	decoder *zstd.Decoder // nil for uncompressed artifacts
+	source  *decryptedReader
+	file    *ArtifactReader
+}
+
+// Read returns errors reading or decrypting the artifact as they are, and anything
+// the decoder rejects as ErrMalformed
+func (a *artifactStream) Read(p []byte) (int, error) {
+	n, err := a.reader.Read(p)
+	if err != nil && err != io.EOF {
+		if a.source.err != nil {
+			return n, a.source.err
+		}
+		return n, fmt.Errorf("failed to decompress archive: %w: %w", ErrMalformed, err)
+	}
+	return n, err
+}
+
+// decryptedReader remembers the first error reading the decrypted artifact returned
+// other than io.EOF
+type decryptedReader struct {
+	r   io.Reader
+	err error
+}
+
+func (d *decryptedReader) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	if err != nil && err != io.EOF && d.err == nil {
+		d.err = err
+	}
+	return n, err
+}
+
+// Verify checks the artifact against the manifest hash once the archive has been read.
+// The decoder is drained first so nothing else is reading the artifact.
+func (a *artifactStream) Verify() error {
+	if _, err := io.Copy(io.Discard, a); err != nil {
+		return fmt.Errorf("failed to read encrypted backup: %w", err)
+	}
+	return a.file.Verify()
+}
+
+func (a *artifactStream) Close() error {
+	if a.decoder != nil {
+		a.decoder.Close()
+	}
+	return a.file.Close()
+}
+
+// openRepositoryStream decrypts a snapshot's chunk index and returns a reader that
+// yields its chunks in order
+func openRepositoryStream(ctx context.Context, loc Location, manifest *types.SnapshotManifest, privateKey string) (Stream, error) {
+	index, err := ReadChunkIndex(ctx, loc, manifest, privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &chunkStream{
+		ctx:        ctx,
+		backend:    loc.Backend,
+		repoRoot:   RepositoryRoot(loc.Prefix),
+		privateKey: privateKey,
+		index:      *index,
+	}, nil
+}
+
+// ReadChunkIndex decrypts the chunk index of a repository-mode snapshot after checking
+// it against the manifest, whose SHA-256 covers the sealed index
+func ReadChunkIndex(ctx context.Context, loc Location, manifest *types.SnapshotManifest, privateKey string) (*ChunkIndex, error) {
+	sealed, err := backend.ReadAll(ctx, loc.Backend, loc.Key(ChunkIndexFileName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open chunk index: %w", err)
+	}
+	if sum := sha256.Sum256(sealed); manifest.SHA256 != "" && hex.EncodeToString(sum[:]) != manifest.SHA256 {
+		return nil, fmt.Errorf("chunk index %w", ErrHashMismatch)
+	}
+
+	indexJSON, err := Unseal(sealed, privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open chunk index: %w", err)
+	}
+
+	var index ChunkIndex
+	if err := json.Unmarshal(indexJSON, &index); err != nil {
+		return nil, fmt.Errorf("failed to parse chunk index: %w: %w", ErrMalformed, err)
+	}
+	if index.Version < 1 || index.Version > ChunkIndexVersion {
+		return nil, fmt.Errorf("unsupported chunk index version %d", index.Version)
+	}
+	return &index, nil
+}
+
+// chunkStream concatenates the plaintext of a snapshot's chunks
+type chunkStream struct {
+	ctx        context.Context
+	backend    backend.Backend
+	repoRoot   string
+	privateKey string
+	index      ChunkIndex
+	next       int
+	current    []byte
+}
+
+func (c *chunkStream) Read(p []byte) (int, error) {
+	for len(c.current) == 0 {
+		if c.next >= len(c.index.Chunks) {
+			return 0, io.EOF
+		}
+		ref := c.index.Chunks[c.next]
+		data, err := OpenSealed(c.ctx, c.backend, ChunkPath(c.repoRoot, ref.ID), c.privateKey)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read chunk %s: %w", ref.ID, err)
+		}
+		if int64(len(data)) != ref.Size {
+			return 0, fmt.Errorf("%w: chunk %s has %d bytes, index expects %d", ErrSizeMismatch, ref.ID, len(data), ref.Size)
+		}
+		c.current = data
+		c.next++
+	}
+
+	n := copy(p, c.current)
+	c.current = c.current[n:]
+	return n, nil
+}
+
+// Verify is a no-op: the chunk index was checked against the manifest when opened and
+// every chunk is authenticated by its encryption
+func (c *chunkStream) Verify() error { return nil }
+
+func (c *chunkStream) Close() error { return nil }
+
+// ReadFileIndex decrypts the file index stored next to a snapshot
+func ReadFileIndex(ctx context.Context, loc Location, privateKey string) (*types.FileIndex, error) {
+	indexJSON, err := OpenSealed(ctx, loc.Backend, loc.Key(FileIndexFileName), privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file index: %w", err)
+	}
+
+	var index types.FileIndex
+	if err := json.Unmarshal(indexJSON, &index); err != nil {
+		return nil, fmt.Errorf("failed to parse file index: %w", err)
+	}
+	if index.Version < 1 || index.Version > types.FileIndexVersion {
+		return nil, fmt.Errorf("unsupported file index version %d", index.Version)
+	}
+
+	return &index, nil
+}
+
+// OpenSealed reads a small zstd-compressed, age-encrypted object into memory
+func OpenSealed(ctx context.Context, b backend.Backend, key, privateKey string) ([]byte, error) {
+	ciphertext, err := backend.ReadAll(ctx, b, key)
+	if err != nil {
+		return nil, err
+	}
+	return Unseal(ciphertext, privateKey)
+}
+
+// Unseal decrypts and decompresses the contents of a sealed file
+func Unseal(ciphertext []byte, privateKey string) ([]byte, error) {
+	compressed, err := crypto.DecryptWithPrivateKey(ciphertext, privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder, err := zstd.NewReader(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create zstd decoder: %w", err)
+	}
+	defer decoder.Close()
+
+	plaintext, err := decoder.DecodeAll(compressed, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress: %w: %w", ErrMalformed, err)
+	}
+	return plaintext, nil
+}
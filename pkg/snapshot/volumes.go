@@ -0,0 +1,246 @@
+package snapshot
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"sort"
+
+	"xvault/pkg/backend"
+	"xvault/pkg/types"
+)
+
+// OpenArtifact opens the encrypted artifact of a snapshot for sequential reading. The
+// volumes of a multi-volume artifact are read in order and each is checked against
+// the size and SHA-256 recorded in the manifest as it is consumed; Verify checks the
+// hash of the whole artifact.
+func OpenArtifact(ctx context.Context, loc Location, manifest *types.SnapshotManifest) (*ArtifactReader, error) {
+	if len(manifest.Volumes) == 0 {
+		file, err := loc.Backend.Get(ctx, loc.Key(ArtifactFileName))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open encrypted backup: %w", err)
+		}
+		return &ArtifactReader{r: file, hasher: sha256.New(), expected: manifest.SHA256}, nil
+	}
+
+	// Fail before extracting anything when a volume is missing
+	for _, volume := range manifest.Volumes {
+		if _, err := loc.Backend.Stat(ctx, loc.Key(volume.Name)); err != nil {
+			return nil, fmt.Errorf("failed to open artifact volume: %w", err)
+		}
+	}
+	volumes := &volumeReader{ctx: ctx, loc: loc, volumes: manifest.Volumes}
+	return &ArtifactReader{r: volumes, hasher: sha256.New(), expected: manifest.SHA256}, nil
+}
+
+// ArtifactReader hashes the encrypted artifact as it is read
+type ArtifactReader struct {
+	r        io.ReadCloser
+	hasher   hash.Hash
+	expected string // manifest SHA-256; empty skips the check
+	eof      bool
+}
+
+func (a *ArtifactReader) Read(p []byte) (int, error) {
+	n, err := a.r.Read(p)
+	a.hasher.Write(p[:n])
+	if err == io.EOF {
+		a.eof = true
+	}
+	return n, err
+}
+
+// Verify reads whatever the decoders left unread, such as the seek table, and
+// compares the hash of the whole artifact with the manifest
+func (a *ArtifactReader) Verify() error {
+	if !a.eof {
+		if _, err := io.Copy(io.Discard, a); err != nil {
+			return fmt.Errorf("failed to read encrypted backup: %w", err)
+		}
+	}
+	if a.expected != "" && hex.EncodeToString(a.hasher.Sum(nil)) != a.expected {
+		return fmt.Errorf("encrypted backup %w", ErrHashMismatch)
+	}
+	return nil
+}
+
+func (a *ArtifactReader) Close() error {
+	return a.r.Close()
+}
+
+// volumeReader concatenates the volumes of an artifact, verifying each one
+type volumeReader struct {
+	ctx     context.Context
+	loc     Location
+	volumes []types.ArtifactVolume
+	next    int
+
+	file   io.ReadCloser
+	hasher hash.Hash
+	read   int64
+}
+
+func (v *volumeReader) Read(p []byte) (int, error) {
+	for {
+		if v.file == nil {
+			if v.next >= len(v.volumes) {
+				return 0, io.EOF
+			}
+			file, err := v.loc.Backend.Get(v.ctx, v.loc.Key(v.volumes[v.next].Name))
+			if err != nil {
+				return 0, fmt.Errorf("failed to open artifact volume: %w", err)
+			}
+			v.file, v.hasher, v.read = file, sha256.New(), 0
+		}
+
+		n, err := v.file.Read(p)
+		v.hasher.Write(p[:n])
+		v.read += int64(n)
+		if err == io.EOF {
+			if err := v.finish(); err != nil {
+				return n, err
+			}
+			if n == 0 {
+				continue
+			}
+			return n, nil
+		}
+		return n, err
+	}
+}
+
+// finish closes the current volume and checks it against the manifest
+func (v *volumeReader) finish() error {
+	volume := v.volumes[v.next]
+	v.file.Close()
+	v.file = nil
+	v.next++
+
+	if v.read != volume.SizeBytes {
+		return fmt.Errorf("%w: artifact volume %s has %d bytes, manifest expects %d", ErrSizeMismatch, volume.Name, v.read, volume.SizeBytes)
+	}
+	if sum := hex.EncodeToString(v.hasher.Sum(nil)); sum != volume.SHA256 {
+		return fmt.Errorf("artifact volume %s %w", volume.Name, ErrHashMismatch)
+	}
+	return nil
+}
+
+func (v *volumeReader) Close() error {
+	if v.file == nil {
+		return nil
+	}
+	return v.file.Close()
+}
+
+// OpenArtifactAt opens the encrypted artifact of a snapshot for random access and
+// returns its total size. Each volume (or the single artifact file) is hashed and
+// checked against the manifest the first time it is read from, so a restore of a
+// few files reads whole volumes once but never trusts an unverified one.
+func OpenArtifactAt(ctx context.Context, loc Location, manifest *types.SnapshotManifest) (*ArtifactReaderAt, int64, error) {
+	names := []string{ArtifactFileName}
+	sums := []string{manifest.SHA256}
+	if len(manifest.Volumes) > 0 {
+		names, sums = names[:0], sums[:0]
+		for _, volume := range manifest.Volumes {
+			names = append(names, volume.Name)
+			sums = append(sums, volume.SHA256)
+		}
+	}
+
+	r := &ArtifactReaderAt{ctx: ctx, loc: loc, names: names, sums: sums, verified: make([]bool, len(names))}
+	var size int64
+	for i, name := range names {
+		file, fileSize, err := backend.OpenReaderAt(ctx, loc.Backend, loc.Key(name))
+		if err != nil {
+			r.Close()
+			return nil, 0, fmt.Errorf("failed to open encrypted backup: %w", err)
+		}
+		r.files = append(r.files, file)
+
+		if len(manifest.Volumes) > 0 && fileSize != manifest.Volumes[i].SizeBytes {
+			r.Close()
+			return nil, 0, fmt.Errorf("%w: artifact volume %s has %d bytes, manifest expects %d", ErrSizeMismatch, name, fileSize, manifest.Volumes[i].SizeBytes)
+		}
+		r.offsets = append(r.offsets, size)
+		size += fileSize
+	}
+	r.size = size
+	return r, size, nil
+}
+
+// ArtifactReaderAt reads the volumes of an artifact as one contiguous file
+type ArtifactReaderAt struct {
+	ctx      context.Context
+	loc      Location
+	names    []string
+	files    []backend.ReadAtCloser
+	offsets  []int64 // artifact offset of each file
+	sums     []string
+	verified []bool
+	size     int64
+}
+
+func (r *ArtifactReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n := 0
+	for len(p) > 0 {
+		if off >= r.size {
+			return n, io.EOF
+		}
+		i := sort.Search(len(r.offsets), func(i int) bool { return r.offsets[i] > off }) - 1
+		end := r.size
+		if i+1 < len(r.offsets) {
+			end = r.offsets[i+1]
+		}
+		if err := r.verify(i); err != nil {
+			return n, err
+		}
+
+		chunk := p[:min(int64(len(p)), end-off)]
+		m, err := r.files[i].ReadAt(chunk, off-r.offsets[i])
+		n += m
+		off += int64(m)
+		p = p[m:]
+		if err != nil && !(err == io.EOF && m == len(chunk)) {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// verify hashes file i once and compares it with the manifest
+func (r *ArtifactReaderAt) verify(i int) error {
+	if r.verified[i] || r.sums[i] == "" {
+		return nil
+	}
+
+	// Hash with one sequential read rather than through the random access reader
+	file, err := r.loc.Backend.Get(r.ctx, r.loc.Key(r.names[i]))
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", r.names[i], err)
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return fmt.Errorf("failed to read %s: %w", r.names[i], err)
+	}
+	if hex.EncodeToString(hasher.Sum(nil)) != r.sums[i] {
+		return fmt.Errorf("%s %w", r.names[i], ErrHashMismatch)
+	}
+	r.verified[i] = true
+	return nil
+}
+
+// Close closes every volume
+func (r *ArtifactReaderAt) Close() error {
+	var firstErr error
+	for _, file := range r.files {
+		if err := file.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -4,6 +4,9 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"fmt"
+	"path"
+	"strings"
+	"time"
 )
 
 // JobType represents the type of job to execute
@@ -13,6 +16,20 @@ const (
 	JobTypeBackup         JobType = "backup"
 	JobTypeRestore        JobType = "restore"
 	JobTypeDeleteSnapshot JobType = "delete_snapshot"
+	// JobTypeReconcileStorage compares a worker's disk with the hub's snapshot
+	// records; it is not tied to a tenant
+	JobTypeReconcileStorage JobType = "reconcile_storage"
+	// JobTypeVerifySnapshot reads a stored snapshot back and checks it against its
+	// manifest
+	JobTypeVerifySnapshot JobType = "verify_snapshot"
+	// JobTypeReplicateSnapshot copies a snapshot's stored files, still encrypted,
+	// from the worker holding them to another worker's storage
+	JobTypeReplicateSnapshot JobType = "replicate_snapshot"
+	// JobTypeMigrateSnapshot moves a snapshot from a worker's disk to colder storage
+	JobTypeMigrateSnapshot JobType = "migrate_snapshot"
+	// JobTypeRekeySnapshot re-encrypts a snapshot on a worker's disk to the tenant's
+	// current key
+	JobTypeRekeySnapshot JobType = "rekey_snapshot"
 )
 
 // JobStatus represents the current status of a job
@@ -27,6 +44,16 @@ const (
 	JobStatusCanceled   JobStatus = "canceled"
 )
 
+// JobReleased is reported by a worker handing a claimed job back to the queue
+// instead of running it; jobs are never stored with it
+const JobReleased JobStatus = "released"
+
+// Error codes a worker reports with a failed or released job
+const (
+	JobErrorInsufficientScratchSpace = "insufficient_scratch_space"
+	JobErrorInsufficientStorageSpace = "insufficient_storage_space"
+)
+
 // SourceType represents the type of source to back up
 type SourceType string
 
@@ -47,12 +74,52 @@ const (
 	SnapshotStatusFailed    SnapshotStatus = "failed"
 )
 
+// SnapshotIntegrity is the result of checking a snapshot's stored files
+type SnapshotIntegrity string
+
+const (
+	// SnapshotIntegrityIncomplete marks a snapshot whose files were found missing or
+	// truncated, typically after a worker crashed while writing it
+	SnapshotIntegrityIncomplete SnapshotIntegrity = "incomplete"
+	// SnapshotIntegrityMissing marks a snapshot whose directory is gone from the
+	// worker that stored it
+	SnapshotIntegrityMissing SnapshotIntegrity = "missing"
+	// SnapshotIntegrityVerified marks a snapshot that passed its last verify job
+	SnapshotIntegrityVerified SnapshotIntegrity = "verified"
+	// SnapshotIntegrityCorrupt marks a snapshot that failed its last verify job: a
+	// hash mismatch, a file that could not be read, or a stream that did not decrypt
+	SnapshotIntegrityCorrupt SnapshotIntegrity = "corrupt"
+)
+
 // StorageBackend represents the storage backend type
 type StorageBackend string
 
 const (
 	StorageBackendLocalFS StorageBackend = "local_fs"
 	StorageBackendS3      StorageBackend = "s3"
+	// StorageBackendSFTP holds copies pushed to a tenant's offsite target
+	StorageBackendSFTP StorageBackend = "sftp"
+)
+
+// ReplicaStatus is the state of a copy of a snapshot made by a replicate_snapshot job
+type ReplicaStatus string
+
+const (
+	ReplicaStatusPending   ReplicaStatus = "pending"
+	ReplicaStatusCompleted ReplicaStatus = "completed"
+	ReplicaStatusFailed    ReplicaStatus = "failed"
+)
+
+// QuotaOverageAction is what the hub does with a backup that would exceed a tenant's
+// quota
+type QuotaOverageAction string
+
+const (
+	QuotaOverageReject QuotaOverageAction = "reject"
+	QuotaOverageWarn   QuotaOverageAction = "warn"
+	// QuotaOverageForceRetention deletes the tenant's oldest snapshots until the
+	// backup fits
+	QuotaOverageForceRetention QuotaOverageAction = "force_retention"
 )
 
 // JobPayload is the JSON payload stored in the jobs table
@@ -63,19 +130,93 @@ type JobPayload struct {
 	SourceConfig json.RawMessage `json:"source_config"` // Type-specific config
 	// For restore jobs
 	RestoreSnapshotID *string `json:"restore_snapshot_id,omitempty"`
-	// For delete jobs
-	DeleteSnapshotID *string `json:"delete_snapshot_id,omitempty"`
+	// RestorePaths limits a restore to these archive paths and everything below them
+	RestorePaths []string `json:"restore_paths,omitempty"`
+	// RestoreOriginalLocations lays the restore out by each tree's original remote
+	// path instead of its archive path
+	RestoreOriginalLocations bool `json:"restore_original_locations,omitempty"`
+	// For delete jobs; DeleteLocator tells the worker which backend holds the snapshot
+	DeleteSnapshotID *string          `json:"delete_snapshot_id,omitempty"`
+	DeleteLocator    *SnapshotLocator `json:"delete_locator,omitempty"`
+	// DeleteReplicaID is set when the job deletes a replica rather than the
+	// snapshot's primary copy
+	DeleteReplicaID *string `json:"delete_replica_id,omitempty"`
+	// For backup jobs: incremental backups only transfer files that changed since
+	// BaseSnapshotID; workers fall back to a full backup if the base is unusable
+	BackupMode     BackupMode `json:"backup_mode,omitempty"`
+	BaseSnapshotID *string    `json:"base_snapshot_id,omitempty"`
+	// For reconcile_storage jobs: the action and, for quarantine and purge, the
+	// orphaned snapshot directories to act on
+	ReconcileAction  ReconcileAction         `json:"reconcile_action,omitempty"`
+	ReconcileTargets []StorageInventoryEntry `json:"reconcile_targets,omitempty"`
+	// For verify_snapshot jobs; VerifyDecrypt also decrypts and decompresses the
+	// archive, discarding the plaintext
+	VerifySnapshotID *string          `json:"verify_snapshot_id,omitempty"`
+	VerifyLocator    *SnapshotLocator `json:"verify_locator,omitempty"`
+	VerifyDecrypt    bool             `json:"verify_decrypt,omitempty"`
+	// The manifest signature the hub recorded and the key that verifies it; empty for
+	// unsigned snapshots
+	VerifyManifestSignature  string `json:"verify_manifest_signature,omitempty"`
+	VerifyManifestSigningKey string `json:"verify_manifest_signing_key,omitempty"`
+	// For replicate_snapshot jobs: the replica to create and the replication server
+	// of the worker to copy it from, which accepts the one-off token for this replica
+	ReplicateSnapshotID *string `json:"replicate_snapshot_id,omitempty"`
+	ReplicaID           string  `json:"replica_id,omitempty"`
+	ReplicateSourceURL  string  `json:"replicate_source_url,omitempty"`
+	ReplicateToken      string  `json:"replicate_token,omitempty"`
+	// Set instead of the source URL when the replica is pushed to the tenant's
+	// offsite target from ReplicateLocator, this worker's copy of the snapshot
+	ReplicateOffsiteTargetID string           `json:"replicate_offsite_target_id,omitempty"`
+	ReplicateLocator         *SnapshotLocator `json:"replicate_locator,omitempty"`
+	// For migrate_snapshot jobs: the snapshot's current locator and the backend to
+	// move it to
+	MigrateSnapshotID *string          `json:"migrate_snapshot_id,omitempty"`
+	MigrateLocator    *SnapshotLocator `json:"migrate_locator,omitempty"`
+	MigrateBackend    StorageBackend   `json:"migrate_backend,omitempty"`
+	// For rekey_snapshot jobs: the snapshot and the tenant key row to re-encrypt it to
+	RekeySnapshotID *string `json:"rekey_snapshot_id,omitempty"`
+	RekeyKeyID      string  `json:"rekey_key_id,omitempty"`
 }
 
+// ReconcileAction is what a reconcile_storage job does on the worker
+type ReconcileAction string
+
+const (
+	// ReconcileActionInventory reports every snapshot directory on the worker
+	ReconcileActionInventory ReconcileAction = "inventory"
+	// ReconcileActionQuarantine moves orphaned snapshot directories out of the
+	// snapshot tree, keeping their files
+	ReconcileActionQuarantine ReconcileAction = "quarantine"
+	// ReconcileActionPurge deletes orphaned snapshot directories
+	ReconcileActionPurge ReconcileAction = "purge"
+)
+
+// BackupMode is whether a backup transfers every file or only changed ones
+type BackupMode string
+
+const (
+	BackupModeFull        BackupMode = "full"
+	BackupModeIncremental BackupMode = "incremental"
+)
+
 // SourceConfigSSH represents SSH/SFTP connection config
 type SourceConfigSSH struct {
 	Host     string   `json:"host"`
 	Port     int      `json:"port"`
 	Username string   `json:"username"`
 	Paths    []string `json:"paths"`
+	// PathAliases stores a configured path under a directory of its own choosing in
+	// the archive instead of under its absolute path
+	PathAliases map[string]string `json:"path_aliases,omitempty"`
+	FileFilter
 	// For SSH key auth (preferred over password)
 	// Password is NOT stored here - it's in credentials
 	UsePassword bool `json:"use_password,omitempty"`
+	// IncrementalHash makes incremental backups also compare a remote sha256sum
+	// for files whose size and mtime are unchanged
+	IncrementalHash bool `json:"incremental_hash,omitempty"`
+	// Compression tunes how the archive is compressed (default zstd level 3)
+	Compression *CompressionConfig `json:"compression,omitempty"`
 }
 
 // SourceConfigFTP represents FTP connection config
@@ -85,6 +226,119 @@ type SourceConfigFTP struct {
 	Username string   `json:"username"`
 	Paths    []string `json:"paths"`
 	Passive  bool     `json:"passive,omitempty"`
+	// PathAliases stores a configured path under a directory of its own choosing in
+	// the archive instead of under its absolute path
+	PathAliases map[string]string `json:"path_aliases,omitempty"`
+	FileFilter
+	// Compression tunes how the archive is compressed (default zstd level 3)
+	Compression *CompressionConfig `json:"compression,omitempty"`
+}
+
+// FileFilter selects what a file source backs up. Include and Exclude hold
+// gitignore-style patterns matched against paths relative to each configured path:
+// a pattern without a slash matches a name at any depth, a leading or inner slash
+// anchors it, a trailing slash matches directories only and "**" matches any number
+// of directories.
+type FileFilter struct {
+	// Include limits the backup to matching paths and everything below matching
+	// directories; empty includes everything
+	Include []string `json:"include,omitempty"`
+	// Exclude drops matching paths (a directory with everything below it). The last
+	// matching pattern wins, and a leading "!" re-includes what an earlier one excluded.
+	Exclude []string `json:"exclude,omitempty"`
+	// MaxFileSize skips regular files larger than this many bytes; 0 means no limit
+	MaxFileSize int64 `json:"max_file_size,omitempty"`
+	// SkipSpecialFiles skips FIFOs as well as device nodes and sockets, which cannot
+	// be read over SFTP and are always skipped
+	SkipSpecialFiles bool `json:"skip_special_files,omitempty"`
+	// OneFileSystem does not descend into filesystems mounted below a configured path
+	OneFileSystem bool `json:"one_file_system,omitempty"`
+}
+
+// Validate checks the filter patterns and limits
+func (f *FileFilter) Validate() error {
+	for _, pattern := range f.Include {
+		if strings.HasPrefix(pattern, "!") {
+			return fmt.Errorf("invalid include pattern %q: negation is only allowed in exclude", pattern)
+		}
+		if err := validateFilterPattern(pattern); err != nil {
+			return err
+		}
+	}
+	for _, pattern := range f.Exclude {
+		if err := validateFilterPattern(strings.TrimPrefix(pattern, "!")); err != nil {
+			return err
+		}
+	}
+	if f.MaxFileSize < 0 {
+		return fmt.Errorf("invalid max_file_size %d: must not be negative", f.MaxFileSize)
+	}
+	return nil
+}
+
+func validateFilterPattern(pattern string) error {
+	trimmed := strings.Trim(pattern, "/")
+	if trimmed == "" {
+		return fmt.Errorf("invalid filter pattern %q: empty", pattern)
+	}
+	for _, segment := range strings.Split(trimmed, "/") {
+		if _, err := path.Match(segment, ""); err != nil {
+			return fmt.Errorf("invalid filter pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// SourceRoot records where a configured remote path of a file source is stored in
+// the archive
+type SourceRoot struct {
+	Path        string `json:"path"`         // absolute path on the source host
+	ArchivePath string `json:"archive_path"` // slash path in the archive, "" for the archive root
+}
+
+// ArchivePathFor returns where a remote path is stored in the archive: under its alias
+// when one is given, otherwise under its absolute path without the leading slash
+func ArchivePathFor(remotePath, alias string) string {
+	if alias != "" {
+		return strings.Trim(path.Clean("/"+alias), "/")
+	}
+	return strings.Trim(path.Clean("/"+remotePath), "/")
+}
+
+// ValidatePathAliases checks that every alias belongs to a configured path and is a
+// relative path that stays inside the archive
+func ValidatePathAliases(paths []string, aliases map[string]string) error {
+	configured := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		configured[p] = true
+	}
+	for remotePath, alias := range aliases {
+		if !configured[remotePath] {
+			return fmt.Errorf("invalid path alias for %q: not one of the configured paths", remotePath)
+		}
+		if alias == "" || path.IsAbs(alias) || strings.Contains("/"+alias+"/", "/../") || ArchivePathFor("", alias) == "" {
+			return fmt.Errorf("invalid path alias %q for %q: must be a relative path inside the archive", alias, remotePath)
+		}
+	}
+	return nil
+}
+
+// ValidateSourceRoots checks that no two roots are stored at the same archive path
+// or one inside the other, which would make their trees overwrite each other
+func ValidateSourceRoots(roots []SourceRoot) error {
+	for i, a := range roots {
+		for _, b := range roots[i+1:] {
+			if pathWithin(a.ArchivePath, b.ArchivePath) || pathWithin(b.ArchivePath, a.ArchivePath) {
+				return fmt.Errorf("paths %q and %q overlap in the archive (%q, %q); set path_aliases to separate them", a.Path, b.Path, a.ArchivePath, b.ArchivePath)
+			}
+		}
+	}
+	return nil
+}
+
+// pathWithin reports whether slash path p is dir or below it
+func pathWithin(p, dir string) bool {
+	return dir == "" || p == dir || strings.HasPrefix(p, dir+"/")
 }
 
 // SourceConfigMySQL represents MySQL connection config
@@ -97,6 +351,8 @@ type SourceConfigMySQL struct {
 	SSHHost     string `json:"ssh_host,omitempty"`
 	SSHPort     int    `json:"ssh_port,omitempty"`
 	SSHUsername string `json:"ssh_username,omitempty"`
+	// Compression tunes how the archive is compressed (default zstd level 3)
+	Compression *CompressionConfig `json:"compression,omitempty"`
 }
 
 // SourceConfigPostgres represents PostgreSQL connection config
@@ -108,10 +364,64 @@ type SourceConfigPostgres struct {
 	UseSSH   bool   `json:"use_ssh,omitempty"`
 	SSHHost  string `json:"ssh_host,omitempty"`
 	SSHPort  int    `json:"ssh_port,omitempty"`
+	// Compression tunes how the archive is compressed (default zstd level 3)
+	Compression *CompressionConfig `json:"compression,omitempty"`
 }
 
-// SnapshotManifest represents the manifest.json stored with each snapshot
+// CompressionAlgorithm is how the archive stream of a snapshot is compressed
+type CompressionAlgorithm string
+
+const (
+	CompressionZstd CompressionAlgorithm = "zstd"
+	CompressionNone CompressionAlgorithm = "none"
+)
+
+// Bounds of the zstd window size, in bytes
+const (
+	MinCompressionWindow = 1 << 10
+	MaxCompressionWindow = 1 << 29
+)
+
+// CompressionConfig selects the archive compression for a source. Zero values mean
+// the defaults: zstd at level 3, the level's default window and one thread per CPU.
+type CompressionConfig struct {
+	Algorithm CompressionAlgorithm `json:"algorithm,omitempty"`
+	// Level is a zstd level from 1 to 22; the encoder maps it to its nearest speed setting
+	Level int `json:"level,omitempty"`
+	// WindowSize is the zstd window in bytes, a power of two. Larger windows find
+	// long-distance matches (e.g. in database dumps) at the cost of memory.
+	WindowSize int `json:"window_size,omitempty"`
+	// Threads is how many frames are compressed in parallel
+	Threads int `json:"threads,omitempty"`
+}
+
+// Validate checks the compression settings
+func (c *CompressionConfig) Validate() error {
+	switch c.Algorithm {
+	case "", CompressionZstd, CompressionNone:
+	default:
+		return fmt.Errorf("invalid compression algorithm %q: must be zstd or none", c.Algorithm)
+	}
+	if c.Level < 0 || c.Level > 22 {
+		return fmt.Errorf("invalid compression level %d: must be between 1 and 22", c.Level)
+	}
+	if c.WindowSize != 0 {
+		if c.WindowSize < MinCompressionWindow || c.WindowSize > MaxCompressionWindow || c.WindowSize&(c.WindowSize-1) != 0 {
+			return fmt.Errorf("invalid compression window size %d: must be a power of two between %d and %d", c.WindowSize, MinCompressionWindow, MaxCompressionWindow)
+		}
+	}
+	if c.Threads < 0 {
+		return fmt.Errorf("invalid compression threads %d: must not be negative", c.Threads)
+	}
+	return nil
+}
+
+// SnapshotManifest represents the manifest.json stored with each snapshot. Read
+// manifests through pkg/snapshot, which understands every FormatVersion.
 type SnapshotManifest struct {
+	// Layout version of the snapshot (see snapshot.FormatVersion); absent before versioning
+	FormatVersion int `json:"format_version,omitempty"`
+
 	TenantID   string `json:"tenant_id"`
 	SourceID   string `json:"source_id"`
 	SnapshotID string `json:"snapshot_id"`
@@ -123,15 +433,180 @@ type SnapshotManifest struct {
 	SizeBytes  int64  `json:"size_bytes"`
 	SHA256     string `json:"sha256"`
 
-	// Encryption metadata
+	// Encryption metadata. EncryptionKeyID is the tenant key the snapshot is encrypted
+	// to; manifests written before keys could rotate hold the first 16 characters of
+	// its public key instead of its ID.
 	EncryptionAlgorithm string `json:"encryption_algorithm"`
 	EncryptionKeyID     string `json:"encryption_key_id"`
 	EncryptionRecipient string `json:"encryption_recipient,omitempty"`
 
+	// Storage layout: "artifact" (single backup.tar.zst.enc) or "repository"
+	// (deduplicated chunks referenced by an encrypted index)
+	StorageMode StorageMode `json:"storage_mode,omitempty"`
+	// Artifact mode: the tar stream is compressed as independent zstd frames of at most
+	// FrameSize bytes, followed by a seek table, so ranges can be read without
+	// decompressing the whole artifact. Zero for artifacts written as a single frame.
+	FrameSize  int64 `json:"frame_size,omitempty"`
+	FrameCount int   `json:"frame_count,omitempty"`
+	// Artifact mode: the encrypted artifact split into numbered volumes of VolumeSize
+	// bytes (the last may be shorter). SHA256 above still covers the whole encrypted
+	// stream. Absent for artifacts stored as a single backup.tar.zst.enc.
+	VolumeSize int64            `json:"volume_size,omitempty"`
+	Volumes    []ArtifactVolume `json:"volumes,omitempty"`
+	// Compression actually used for the archive stream; absent means zstd. Restore
+	// picks its decoder from Algorithm.
+	Compression *CompressionConfig `json:"compression,omitempty"`
+	// Bytes of tar stream and of compressed output
+	UncompressedSizeBytes int64 `json:"uncompressed_size_bytes,omitempty"`
+	CompressedSizeBytes   int64 `json:"compressed_size_bytes,omitempty"`
+	// Repository mode: bytes in the archive stream vs. bytes this snapshot newly stored
+	LogicalSizeBytes int64 `json:"logical_size_bytes,omitempty"`
+	NewBytes         int64 `json:"new_bytes,omitempty"`
+	ChunkCount       int   `json:"chunk_count,omitempty"`
+	NewChunkCount    int   `json:"new_chunk_count,omitempty"`
+
+	// Incremental backups: the snapshot is still a complete tree, the summary
+	// records how much of it was transferred versus taken from the base snapshot
+	BackupMode  BackupMode          `json:"backup_mode,omitempty"`
+	Incremental *IncrementalSummary `json:"incremental,omitempty"`
+
 	// Content summary
 	ContentSummary ContentSummary `json:"content_summary"`
 }
 
+// ArtifactVolume is one numbered file of a multi-volume artifact
+type ArtifactVolume struct {
+	Name      string `json:"name"`
+	SizeBytes int64  `json:"size_bytes"`
+	SHA256    string `json:"sha256"`
+}
+
+// ArtifactVolumes is the volume list stored with a snapshot record
+type ArtifactVolumes []ArtifactVolume
+
+// Scan implements the sql.Scanner interface
+func (v *ArtifactVolumes) Scan(value any) error {
+	if value == nil {
+		*v = nil
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return fmt.Errorf("failed to unmarshal artifact volumes: %T", value)
+	}
+	return json.Unmarshal(bytes, v)
+}
+
+// Value implements the driver.Valuer interface
+func (v ArtifactVolumes) Value() (driver.Value, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
+
+// IncrementalSummary describes an incremental snapshot relative to its base
+type IncrementalSummary struct {
+	BaseSnapshotID   string `json:"base_snapshot_id"`
+	FilesTransferred int    `json:"files_transferred"`
+	BytesTransferred int64  `json:"bytes_transferred"`
+	FilesUnchanged   int    `json:"files_unchanged"`
+	BytesUnchanged   int64  `json:"bytes_unchanged"`
+	FilesDeleted     int    `json:"files_deleted"`
+}
+
+// FileIndexVersion is the format version of the encrypted per-snapshot file index.
+// Version 2 adds archive offsets and the compression frame table.
+const FileIndexVersion = 2
+
+// FileIndex lists every path archived in a snapshot. It is stored encrypted next
+// to the snapshot and is the baseline the next incremental backup compares against.
+type FileIndex struct {
+	Version    int              `json:"version"`
+	SnapshotID string           `json:"snapshot_id"`
+	Entries    []FileIndexEntry `json:"entries"`
+	// Deleted lists paths of the base snapshot that no longer exist at the source
+	Deleted []string `json:"deleted,omitempty"`
+	// Frames is the seek table of a framed artifact; empty for repository snapshots
+	Frames []ArchiveFrame `json:"frames,omitempty"`
+}
+
+// ArchiveFrame is one independently compressed zstd frame of an artifact. Offset and
+// Size locate its content in the tar stream; the compressed fields locate the frame
+// in the decrypted artifact.
+type ArchiveFrame struct {
+	Offset           int64 `json:"offset"`
+	Size             int64 `json:"size"`
+	CompressedOffset int64 `json:"compressed_offset"`
+	CompressedSize   int64 `json:"compressed_size"`
+}
+
+// FileIndexEntry is one archived path, relative to the archive root
+type FileIndexEntry struct {
+	Path       string    `json:"path"`
+	Type       string    `json:"type"` // "file", "dir", "symlink", "hardlink", "special"
+	Size       int64     `json:"size"`
+	Mode       int64     `json:"mode"`
+	ModTime    time.Time `json:"mtime"`
+	LinkTarget string    `json:"link_target,omitempty"`
+	SHA256     string    `json:"sha256,omitempty"`
+	UID        int       `json:"uid"`
+	GID        int       `json:"gid"`
+	// The entry's bytes in the tar stream, from its first header block to the end of
+	// its data padding. Set from file index version 2.
+	ArchiveOffset int64 `json:"archive_offset"`
+	ArchiveLength int64 `json:"archive_length,omitempty"`
+}
+
+// Under returns the entries at or below prefix, a slash-separated path relative to
+// the archive root. An empty prefix matches every entry.
+func (fi *FileIndex) Under(prefix string) []FileIndexEntry {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return fi.Entries
+	}
+
+	var entries []FileIndexEntry
+	for _, entry := range fi.Entries {
+		if entry.Path == prefix || strings.HasPrefix(entry.Path, prefix+"/") {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// SnapshotFileList is a listing of a snapshot's file index, optionally narrowed to a prefix
+type SnapshotFileList struct {
+	SnapshotID string           `json:"snapshot_id"`
+	Prefix     string           `json:"prefix,omitempty"`
+	FileCount  int              `json:"file_count"`  // entries other than directories
+	TotalBytes int64            `json:"total_bytes"` // sum of regular file sizes
+	Files      []FileIndexEntry `json:"files"`
+}
+
+// CountFiles returns how many entries are not directories and the total size of the
+// regular files among them
+func CountFiles(entries []FileIndexEntry) (fileCount int, totalBytes int64) {
+	for _, entry := range entries {
+		if entry.Type == "dir" {
+			continue
+		}
+		fileCount++
+		if entry.Type == "file" {
+			totalBytes += entry.Size
+		}
+	}
+	return fileCount, totalBytes
+}
+
+// StorageMode is how a snapshot is laid out on worker storage
+type StorageMode string
+
+const (
+	StorageModeArtifact   StorageMode = "artifact"
+	StorageModeRepository StorageMode = "repository"
+)
+
 // ContentSummary describes what's in the snapshot
 type ContentSummary struct {
 	Type      string   `json:"type"` // "files", "database", "wordpress"
@@ -140,6 +615,35 @@ type ContentSummary struct {
 	// For databases
 	DatabaseName string `json:"database_name,omitempty"`
 	DatabaseSize int64  `json:"database_size,omitempty"`
+	// For file sources: where each configured path is stored in the archive, and
+	// what the source's filter left out
+	Roots   []SourceRoot    `json:"roots,omitempty"`
+	Skipped *SkippedSummary `json:"skipped,omitempty"`
+}
+
+// SkippedSummary counts the entries a file source's filter left out of a snapshot.
+// An excluded directory counts once, whatever it contains.
+type SkippedSummary struct {
+	Excluded        int   `json:"excluded,omitempty"`
+	TooLarge        int   `json:"too_large,omitempty"`
+	TooLargeBytes   int64 `json:"too_large_bytes,omitempty"`
+	Special         int   `json:"special,omitempty"`
+	OtherFilesystem int   `json:"other_filesystem,omitempty"`
+}
+
+// Total returns the number of skipped entries
+func (s SkippedSummary) Total() int {
+	return s.Excluded + s.TooLarge + s.Special + s.OtherFilesystem
+}
+
+// FileOwner is the ownership of a file as recorded at the source.
+// Workers cannot always chown the local mirror, so ownership is carried
+// alongside it and written into the archive headers.
+type FileOwner struct {
+	UID   int    `json:"uid"`
+	GID   int    `json:"gid"`
+	Uname string `json:"uname,omitempty"`
+	Gname string `json:"gname,omitempty"`
 }
 
 // SnapshotLocator represents where a snapshot is stored
@@ -151,6 +655,10 @@ type SnapshotLocator struct {
 	Bucket         string         `json:"bucket,omitempty"`     // For S3
 	ObjectKey      string         `json:"object_key,omitempty"` // For S3
 	ETag           string         `json:"etag,omitempty"`       // For S3
+	// Multi-volume artifacts: the volumes in order, relative to LocalPath
+	Volumes []ArtifactVolume `json:"volumes,omitempty"`
+	// For sftp: the offsite target holding the copy under ObjectKey
+	OffsiteTargetID string `json:"offsite_target_id,omitempty"`
 }
 
 // JSONB wrapper for database storage
@@ -195,11 +703,107 @@ type JobClaimResponse struct {
 
 // JobCompleteRequest is the request body for a worker to report job completion
 type JobCompleteRequest struct {
-	WorkerID string          `json:"worker_id"`
-	Status   JobStatus       `json:"status"`
-	Error    string          `json:"error,omitempty"`
-	Snapshot *SnapshotResult `json:"snapshot,omitempty"`
-	Restore  *RestoreResult  `json:"restore,omitempty"`
+	WorkerID string    `json:"worker_id"`
+	Status   JobStatus `json:"status"`
+	Error    string    `json:"error,omitempty"`
+	// ErrorCode classifies Error, e.g. JobErrorInsufficientScratchSpace
+	ErrorCode string          `json:"error_code,omitempty"`
+	Snapshot  *SnapshotResult `json:"snapshot,omitempty"`
+	Restore   *RestoreResult  `json:"restore,omitempty"`
+	// Reported by reconcile_storage inventory jobs
+	Inventory *StorageInventory `json:"inventory,omitempty"`
+	// Reported by verify_snapshot jobs
+	Verification *SnapshotVerification `json:"verification,omitempty"`
+	// Reported by replicate_snapshot jobs
+	Replica *ReplicaResult `json:"replica,omitempty"`
+	// Reported by migrate_snapshot jobs
+	Migration *SnapshotMigration `json:"migration,omitempty"`
+	// Reported by rekey_snapshot jobs
+	Rekey *SnapshotRekey `json:"rekey,omitempty"`
+}
+
+// SnapshotRekey is a snapshot a rekey_snapshot job re-encrypted: its new manifest,
+// signed like a backup's, and its new artifact volumes
+type SnapshotRekey struct {
+	SnapshotID        string           `json:"snapshot_id"`
+	KeyID             string           `json:"key_id"`
+	ManifestJSON      json.RawMessage  `json:"manifest_json"`
+	ManifestSignature string           `json:"manifest_signature,omitempty"`
+	Volumes           []ArtifactVolume `json:"volumes,omitempty"`
+}
+
+// SnapshotMigration is where a migrate_snapshot job moved a snapshot
+type SnapshotMigration struct {
+	SnapshotID string          `json:"snapshot_id"`
+	Locator    SnapshotLocator `json:"locator"`
+}
+
+// ReplicaResult is where a replicate_snapshot job stored its copy
+type ReplicaResult struct {
+	ReplicaID string          `json:"replica_id"`
+	Locator   SnapshotLocator `json:"locator"`
+}
+
+// SnapshotVerification is the result of a verify_snapshot job
+type SnapshotVerification struct {
+	SnapshotID string            `json:"snapshot_id"`
+	Status     SnapshotIntegrity `json:"status"` // verified or corrupt
+	Error      string            `json:"error,omitempty"`
+	Decrypted  bool              `json:"decrypted"`  // the archive was decrypted and decompressed
+	BytesRead  int64             `json:"bytes_read"` // encrypted bytes, or plaintext bytes when decrypted
+	FileCount  int               `json:"file_count"` // archive entries, when decrypted
+	DurationMs int64             `json:"duration_ms"`
+}
+
+// StorageInventoryEntry is one snapshot directory found on a worker's disk. The IDs are
+// the directory's path components; Error says when its meta.json is unreadable or
+// disagrees with them.
+type StorageInventoryEntry struct {
+	TenantID      string      `json:"tenant_id"`
+	SourceID      string      `json:"source_id"`
+	SnapshotID    string      `json:"snapshot_id"`
+	LocalPath     string      `json:"local_path"`
+	StorageMode   StorageMode `json:"storage_mode,omitempty"`
+	ArtifactBytes int64       `json:"artifact_bytes"` // artifact file or volumes
+	TotalBytes    int64       `json:"total_bytes"`    // every file in the directory
+	Error         string      `json:"error,omitempty"`
+}
+
+// StorageInventory is what a reconcile_storage inventory job reports
+type StorageInventory struct {
+	Entries []StorageInventoryEntry `json:"entries"`
+}
+
+// StorageReconciliation is the diff between a worker's disk and the hub's records of
+// the snapshots stored on it
+type StorageReconciliation struct {
+	WorkerID       string `json:"worker_id"`
+	JobID          string `json:"job_id"`
+	InventoryCount int    `json:"inventory_count"`
+	// Records whose snapshot directory is not on the worker
+	Missing []ReconcileMissing `json:"missing"`
+	// Directories on the worker with no snapshot record
+	Orphaned []StorageInventoryEntry `json:"orphaned"`
+	// Artifact snapshots whose artifact size differs from the recorded size
+	SizeMismatches []ReconcileSizeMismatch `json:"size_mismatches"`
+}
+
+// ReconcileMissing is a snapshot record whose directory was not found
+type ReconcileMissing struct {
+	SnapshotID string `json:"snapshot_id"`
+	TenantID   string `json:"tenant_id"`
+	SourceID   string `json:"source_id"`
+	LocalPath  string `json:"local_path"`
+	ReplicaID  string `json:"replica_id,omitempty"` // set when the record is a replica
+}
+
+// ReconcileSizeMismatch is a snapshot whose stored artifact size differs from its record
+type ReconcileSizeMismatch struct {
+	SnapshotID    string `json:"snapshot_id"`
+	LocalPath     string `json:"local_path"`
+	RecordedBytes int64  `json:"recorded_bytes"`
+	StoredBytes   int64  `json:"stored_bytes"`
+	ReplicaID     string `json:"replica_id,omitempty"` // set when the record is a replica
 }
 
 // SnapshotResult is the snapshot metadata reported by the worker
@@ -213,6 +817,10 @@ type SnapshotResult struct {
 	ManifestJSON        json.RawMessage `json:"manifest_json"`
 	EncryptionAlgorithm string          `json:"encryption_algorithm"`
 	Locator             SnapshotLocator `json:"locator"`
+	BackupMode          BackupMode      `json:"backup_mode,omitempty"`
+	BaseSnapshotID      string          `json:"base_snapshot_id,omitempty"`
+	// Worker's Ed25519 signature over the canonical form of ManifestJSON
+	ManifestSignature string `json:"manifest_signature,omitempty"`
 }
 
 // RestoreResult is the restore metadata reported by the worker
@@ -232,13 +840,87 @@ type WorkerRegisterRequest struct {
 	Name            string         `json:"name"`
 	StorageBasePath string         `json:"storage_base_path"`
 	Capabilities    map[string]any `json:"capabilities"`
+	// Base64 Ed25519 key that verifies the worker's snapshot manifest signatures
+	PublicKey string `json:"public_key,omitempty"`
 }
 
 // WorkerHeartbeatRequest is the request body for worker heartbeats
 type WorkerHeartbeatRequest struct {
 	WorkerID      string         `json:"worker_id"`
-	Status        string         `json:"status"` // "online", "offline", "draining"
+	Status        string         `json:"status"` // "online", "offline", "draining", "degraded"
 	SystemMetrics *SystemMetrics `json:"system_metrics,omitempty"`
+	// Why a degraded worker is not claiming jobs
+	StatusReasons []WorkerStatusReason `json:"status_reasons,omitempty"`
+}
+
+// Admission checks a worker runs on its own metrics before claiming a job, named
+// after the SystemMetrics field they read
+const (
+	AdmissionCheckDiskFree = "disk_free_bytes"
+	AdmissionCheckMemory   = "memory_percent"
+	AdmissionCheckCPU      = "cpu_percent"
+)
+
+// WorkerStatusReason is an admission threshold a worker is over, which keeps it from
+// claiming jobs until it recovers
+type WorkerStatusReason struct {
+	Check   string  `json:"check"`
+	Value   float64 `json:"value"`
+	Limit   float64 `json:"limit"`
+	Message string  `json:"message"`
+}
+
+// SnapshotIntegrityRequest is the request body for a worker reporting the state of
+// a snapshot's files
+type SnapshotIntegrityRequest struct {
+	WorkerID string            `json:"worker_id"`
+	Status   SnapshotIntegrity `json:"status"`
+	Error    string            `json:"error,omitempty"`
+}
+
+// ReplicaTransferRequest asks the hub whether a replication token lets another
+// worker copy a snapshot from the worker presenting it
+type ReplicaTransferRequest struct {
+	WorkerID string `json:"worker_id"`
+	Token    string `json:"token"`
+}
+
+// ReplicaTransfer is the snapshot copy a replication token grants read access to
+type ReplicaTransfer struct {
+	ReplicaID  string          `json:"replica_id"`
+	TenantID   string          `json:"tenant_id"`
+	SourceID   string          `json:"source_id"`
+	SnapshotID string          `json:"snapshot_id"`
+	Locator    SnapshotLocator `json:"locator"` // the copy on the serving worker
+}
+
+// FileListingAuthorizeRequest asks the hub which snapshot a file listing token lets
+// the restore service list
+type FileListingAuthorizeRequest struct {
+	Token string `json:"token"`
+}
+
+// FileListing is the snapshot a file listing token grants the restore service access
+// to, with the locator the hub recorded for it
+type FileListing struct {
+	TenantID   string          `json:"tenant_id"`
+	SourceID   string          `json:"source_id"`
+	SnapshotID string          `json:"snapshot_id"`
+	Locator    SnapshotLocator `json:"locator"`
+}
+
+// OffsiteTargetAccess is what a worker or restore service needs to connect to a
+// tenant's offsite target, including its decrypted secret
+type OffsiteTargetAccess struct {
+	ID         string `json:"id"`
+	TenantID   string `json:"tenant_id"`
+	Host       string `json:"host"`
+	Port       int    `json:"port"`
+	Username   string `json:"username"`
+	Password   string `json:"password,omitempty"`
+	PrivateKey string `json:"private_key,omitempty"`
+	BasePath   string `json:"base_path"`
+	HostKey    string `json:"host_key,omitempty"`
 }
 
 // SystemMetrics contains system resource usage information from a worker
@@ -122,6 +122,126 @@ func TestRetentionPolicyNormalize(t *testing.T) {
 	})
 }
 
+func TestCompressionConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  CompressionConfig
+		wantErr bool
+	}{
+		{"defaults", CompressionConfig{}, false},
+		{"zstd level 19", CompressionConfig{Algorithm: CompressionZstd, Level: 19}, false},
+		{"none", CompressionConfig{Algorithm: CompressionNone}, false},
+		{"window 128 MiB", CompressionConfig{WindowSize: 128 << 20, Threads: 4}, false},
+		{"unknown algorithm", CompressionConfig{Algorithm: "gzip"}, true},
+		{"level too high", CompressionConfig{Level: 23}, true},
+		{"window not a power of two", CompressionConfig{WindowSize: 3 << 20}, true},
+		{"window too large", CompressionConfig{WindowSize: 1 << 30}, true},
+		{"negative threads", CompressionConfig{Threads: -1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFileFilterValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  FileFilter
+		wantErr bool
+	}{
+		{"empty", FileFilter{}, false},
+		{"typical wordpress", FileFilter{Exclude: []string{"wp-content/cache/", "node_modules/", "*.log", "!debug.log"}, MaxFileSize: 1 << 30}, false},
+		{"anchored include", FileFilter{Include: []string{"/wp-content/**/*.php"}}, false},
+		{"negated include", FileFilter{Include: []string{"!*.log"}}, true},
+		{"empty pattern", FileFilter{Exclude: []string{"/"}}, true},
+		{"bad character class", FileFilter{Exclude: []string{"[a-"}}, true},
+		{"negative size", FileFilter{MaxFileSize: -1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.filter.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestArchivePathFor(t *testing.T) {
+	tests := []struct {
+		remotePath string
+		alias      string
+		expected   string
+	}{
+		{"/var/www/site/uploads", "", "var/www/site/uploads"},
+		{"/home/bob/uploads/", "", "home/bob/uploads"},
+		{"/", "", ""},
+		{"/home/bob/uploads", "bob-uploads", "bob-uploads"},
+		{"/home/bob/uploads", "/sites//bob/", "sites/bob"},
+	}
+
+	for _, tt := range tests {
+		if got := ArchivePathFor(tt.remotePath, tt.alias); got != tt.expected {
+			t.Errorf("ArchivePathFor(%q, %q) = %q, want %q", tt.remotePath, tt.alias, got, tt.expected)
+		}
+	}
+}
+
+func TestValidateSourceRoots(t *testing.T) {
+	tests := []struct {
+		name    string
+		roots   []SourceRoot
+		wantErr bool
+	}{
+		{"same base name", []SourceRoot{{"/var/www/site/uploads", "var/www/site/uploads"}, {"/home/bob/uploads", "home/bob/uploads"}}, false},
+		{"sibling prefix", []SourceRoot{{"/srv/a", "srv/a"}, {"/srv/ab", "srv/ab"}}, false},
+		{"same alias", []SourceRoot{{"/srv/a", "uploads"}, {"/srv/b", "uploads"}}, true},
+		{"nested", []SourceRoot{{"/var/www", "var/www"}, {"/var/www/uploads", "var/www/uploads"}}, true},
+		{"filesystem root", []SourceRoot{{"/", ""}, {"/srv", "srv"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateSourceRoots(tt.roots)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateSourceRoots() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidatePathAliases(t *testing.T) {
+	paths := []string{"/var/www/site/uploads", "/home/bob/uploads"}
+	tests := []struct {
+		name    string
+		aliases map[string]string
+		wantErr bool
+	}{
+		{"none", nil, false},
+		{"relative alias", map[string]string{"/home/bob/uploads": "bob/uploads"}, false},
+		{"unknown path", map[string]string{"/srv": "srv"}, true},
+		{"absolute alias", map[string]string{"/home/bob/uploads": "/bob"}, true},
+		{"escaping alias", map[string]string{"/home/bob/uploads": "../bob"}, true},
+		{"empty alias", map[string]string{"/home/bob/uploads": ""}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePathAliases(paths, tt.aliases)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidatePathAliases() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func intPtr(i int) *int {
 	return &i
 }
@@ -1,7 +1,7 @@
 import { defineStore } from 'pinia'
 import { ref } from 'vue'
 import api from '@/lib/api'
-import type { Snapshot } from '@/types'
+import type { Snapshot, SnapshotFileList } from '@/types'
 
 export const useSnapshotsStore = defineStore('snapshots', () => {
   const snapshots = ref<Snapshot[]>([])
@@ -48,12 +48,39 @@ export const useSnapshotsStore = defineStore('snapshots', () => {
     }
   }
 
-  async function triggerRestore(snapshotId: string): Promise<{ job_id: string }> {
+  async function fetchSnapshotFiles(snapshotId: string, prefix?: string): Promise<SnapshotFileList> {
     isLoading.value = true
     error.value = null
 
     try {
-      const response = await api.post<{ job_id: string }>(`/v1/snapshots/${snapshotId}/restore`, {})
+      const queryParams = new URLSearchParams()
+      if (prefix) {
+        queryParams.append('prefix', prefix)
+      }
+
+      const url = queryParams.toString()
+        ? `/v1/snapshots/${snapshotId}/files?${queryParams.toString()}`
+        : `/v1/snapshots/${snapshotId}/files`
+
+      const response = await api.get<SnapshotFileList>(url)
+      return response.data
+    } catch (err) {
+      error.value = err instanceof Error ? err.message : 'Failed to fetch snapshot files'
+      throw err
+    } finally {
+      isLoading.value = false
+    }
+  }
+
+  async function triggerRestore(snapshotId: string, paths?: string[]): Promise<{ job_id: string }> {
+    isLoading.value = true
+    error.value = null
+
+    try {
+      const response = await api.post<{ job_id: string }>(
+        `/v1/snapshots/${snapshotId}/restore`,
+        paths?.length ? { paths } : {}
+      )
       return response.data
     } catch (err) {
       error.value = err instanceof Error ? err.message : 'Failed to trigger restore'
@@ -92,6 +119,7 @@ export const useSnapshotsStore = defineStore('snapshots', () => {
     error,
     fetchSnapshots,
     fetchSnapshot,
+    fetchSnapshotFiles,
     triggerRestore,
     generateDownloadLink,
     clearCurrentSnapshot,
@@ -158,6 +158,27 @@ export interface Snapshot {
   manifest: Record<string, unknown> | null
 }
 
+// Entry in a snapshot's file index
+export interface SnapshotFile {
+  path: string
+  type: 'file' | 'dir' | 'symlink' | 'hardlink' | 'special'
+  size: number
+  mode: number
+  mtime: string
+  link_target?: string
+  sha256?: string
+  uid: number
+  gid: number
+}
+
+export interface SnapshotFileList {
+  snapshot_id: string
+  prefix?: string
+  file_count: number
+  total_bytes: number
+  files: SnapshotFile[]
+}
+
 // Admin Snapshot type (includes tenant/source info)
 export interface AdminSnapshot {
   id: string
@@ -177,10 +198,95 @@ export interface AdminSnapshot {
   download_token?: string
   download_expires_at?: string
   download_url?: string
+  download_manifest_verification?: 'verified' | 'unsigned'
+  integrity_status?: 'verified' | 'corrupt' | 'incomplete' | 'missing'
+  integrity_error?: string
+  last_verified_at?: string
+  legal_hold?: boolean
+  locked_until?: string
+  tiered_at?: string
+  created_at: string
+  updated_at: string
+}
+
+export interface SnapshotReplica {
+  id: string
+  snapshot_id: string
+  job_id?: string
+  worker_id: string
+  source_worker_id?: string
+  status: 'pending' | 'completed' | 'failed'
+  storage_backend: string
+  local_path?: string
+  bucket?: string
+  object_key?: string
+  offsite_target_id?: string
+  error?: string
+  created_at: string
+  updated_at: string
+}
+
+// Offsite target types
+export interface OffsiteTarget {
+  id: string
+  tenant_id: string
+  host: string
+  port: number
+  username: string
+  use_private_key: boolean
+  base_path: string
+  host_key: string
+  status: 'enabled' | 'disabled'
+  retired_at?: string
+  created_at: string
+  updated_at: string
+}
+
+// Tenant encryption key types
+export interface TenantKey {
+  id: string
+  tenant_id: string
+  algorithm: string
+  public_key: string
+  key_status: 'active' | 'rotated' | 'disabled'
+  rotated_at?: string
+  created_at: string
+  snapshot_count: number
+}
+
+export interface RotateTenantKeyResponse {
+  key: TenantKey
+  rekey_job_ids: string[]
+}
+
+// Quota types
+export type QuotaOverageAction = 'reject' | 'warn' | 'force_retention'
+
+export interface Quota {
+  id: string
+  tenant_id?: string
+  plan?: string
+  max_storage_bytes: number | null
+  max_sources: number | null
+  max_snapshots_per_source: number | null
+  overage_action: QuotaOverageAction
   created_at: string
   updated_at: string
 }
 
+export interface TenantQuotaStatus {
+  tenant_id: string
+  plan: string
+  quota: Quota | null
+  usage: {
+    storage_bytes: number
+    sources: number
+    snapshots: number
+    by_source: { source_id: string; source_name: string; snapshots: number; storage_bytes: number }[]
+  }
+  limits_reached: string[]
+}
+
 // Job types
 export type JobStatus = 'pending' | 'claimed' | 'running' | 'completed' | 'failed'
 export type JobType = 'backup' | 'restore' | 'delete' | 'retention_eval'
@@ -218,6 +324,7 @@ export interface Schedule {
     keep_last_n?: number
     keep_within_duration?: string
   }
+  full_every: number
   last_run_at?: string | null
   next_run_at?: string | null
   created_at: string
@@ -243,6 +350,7 @@ export interface AdminCreateScheduleRequest {
   cron?: string
   interval_minutes?: number
   timezone?: string
+  full_every?: number
   retention_policy?: {
     mode: 'all' | 'latest_n' | 'within_duration'
     keep_last_n?: number
@@ -255,6 +363,7 @@ export interface AdminUpdateScheduleRequest {
   interval_minutes?: number
   timezone?: string
   status?: 'enabled' | 'disabled'
+  full_every?: number
   retention_policy?: {
     mode: 'all' | 'latest_n' | 'within_duration'
     keep_last_n?: number
@@ -302,7 +411,7 @@ export interface UpdateSettingRequest {
 }
 
 // Worker types
-export type WorkerStatus = 'online' | 'offline' | 'draining'
+export type WorkerStatus = 'online' | 'offline' | 'draining' | 'degraded'
 export type WorkerHealth = 'healthy' | 'warning' | 'critical' | 'offline'
 
 export interface SystemMetrics {
@@ -326,11 +435,20 @@ export interface Worker {
   capabilities: Record<string, unknown>
   storage_base_path: string
   system_metrics?: SystemMetrics
+  status_reasons?: WorkerStatusReason[]
   last_seen_at?: string
   created_at: string
   updated_at: string
 }
 
+// An admission threshold keeping a degraded worker from claiming jobs
+export interface WorkerStatusReason {
+  check: 'disk_free_bytes' | 'memory_percent' | 'cpu_percent'
+  value: number
+  limit: number
+  message: string
+}
+
 export interface WorkersResponse {
   workers: Worker[]
   total: number